@@ -199,6 +199,13 @@ const (
 	REQUESTS_1000MS  = "requests_1000ms"
 	REQUESTS_5000MS  = "requests_5000ms"
 
+	REJECTED_REQUESTS = "rejected_requests"
+
+	RESULT_CACHE_HITS      = "result_cache_hits"
+	RESULT_CACHE_MISSES    = "result_cache_misses"
+	RESULT_CACHE_ENTRIES   = "result_cache_entries"
+	RESULT_CACHE_EVICTIONS = "result_cache_evictions"
+
 	DURATION_0MS    = 0 * time.Millisecond
 	DURATION_250MS  = 250 * time.Millisecond
 	DURATION_500MS  = 500 * time.Millisecond
@@ -209,7 +216,8 @@ const (
 var metricNames = []string{REQUESTS, SELECTS, UPDATES, INSERTS, DELETES, ACTIVE_REQUESTS,
 	QUEUED_REQUESTS, INVALID_REQUESTS, REQUEST_TIME, SERVICE_TIME, RESULT_COUNT, RESULT_SIZE, ERRORS,
 	REQUESTS_250MS, REQUESTS_500MS, REQUESTS_1000MS, REQUESTS_5000MS,
-	WARNINGS, MUTATIONS}
+	WARNINGS, MUTATIONS, RESULT_CACHE_HITS, RESULT_CACHE_MISSES, RESULT_CACHE_ENTRIES,
+	RESULT_CACHE_EVICTIONS, REJECTED_REQUESTS}
 
 // Map each duration to its metrics
 var slowMetricsMap = map[time.Duration][]string{
@@ -0,0 +1,175 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package active tracks the requests being serviced, so that they can
+be listed and cancelled.
+*/
+package active
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+/*
+Request describes an active request. Its state, cancellation,
+statement fingerprint and mutation progress are provided by the
+server servicing it.
+*/
+type Request struct {
+	id          string
+	clientId    string
+	statement   string
+	users       []string
+	requestTime time.Time
+	state       func() string
+	fingerprint func() string
+	progress    func() map[string]interface{}
+	cancel      func()
+}
+
+func NewRequest(id, clientId, statement string, users []string, requestTime time.Time,
+	state, fingerprint func() string, progress func() map[string]interface{}, cancel func()) *Request {
+	return &Request{
+		id:          id,
+		clientId:    clientId,
+		statement:   statement,
+		users:       users,
+		requestTime: requestTime,
+		state:       state,
+		fingerprint: fingerprint,
+		progress:    progress,
+		cancel:      cancel,
+	}
+}
+
+func (this *Request) Id() string {
+	return this.id
+}
+
+func (this *Request) ClientId() string {
+	return this.clientId
+}
+
+func (this *Request) Statement() string {
+	return this.statement
+}
+
+func (this *Request) Users() []string {
+	return this.users
+}
+
+func (this *Request) RequestTime() time.Time {
+	return this.requestTime
+}
+
+func (this *Request) State() string {
+	return this.state()
+}
+
+/*
+The statement with its literals replaced by parameters, or the
+empty string if it has none.
+*/
+func (this *Request) Fingerprint() string {
+	if this.fingerprint == nil {
+		return ""
+	}
+
+	return this.fingerprint()
+}
+
+/*
+The documents examined and mutated so far by the UPDATE, DELETE and
+other mutations of the request, or nil if it has not mutated any.
+*/
+func (this *Request) Progress() map[string]interface{} {
+	if this.progress == nil {
+		return nil
+	}
+
+	return this.progress()
+}
+
+type requests struct {
+	sync.RWMutex
+	requests map[string]*Request
+}
+
+var _REQUESTS = &requests{
+	requests: make(map[string]*Request),
+}
+
+func Add(request *Request) {
+	_REQUESTS.Lock()
+	defer _REQUESTS.Unlock()
+	_REQUESTS.requests[request.id] = request
+}
+
+func Remove(id string) {
+	_REQUESTS.Lock()
+	defer _REQUESTS.Unlock()
+	delete(_REQUESTS.requests, id)
+}
+
+func Get(id string) *Request {
+	_REQUESTS.RLock()
+	defer _REQUESTS.RUnlock()
+	return _REQUESTS.requests[id]
+}
+
+// Cancel an active request. Returns false if there is no such
+// request.
+func Cancel(id string) bool {
+	request := Get(id)
+	if request == nil {
+		return false
+	}
+
+	request.cancel()
+	return true
+}
+
+// All active requests, oldest first.
+func Requests() []*Request {
+	_REQUESTS.RLock()
+	rv := make([]*Request, 0, len(_REQUESTS.requests))
+	for _, request := range _REQUESTS.requests {
+		rv = append(rv, request)
+	}
+	_REQUESTS.RUnlock()
+
+	sort.Sort(byRequestTime(rv))
+	return rv
+}
+
+func Count() int {
+	_REQUESTS.RLock()
+	defer _REQUESTS.RUnlock()
+	return len(_REQUESTS.requests)
+}
+
+type byRequestTime []*Request
+
+func (this byRequestTime) Len() int {
+	return len(this)
+}
+
+func (this byRequestTime) Less(i, j int) bool {
+	if this[i].requestTime.Equal(this[j].requestTime) {
+		return this[i].id < this[j].id
+	}
+	return this[i].requestTime.Before(this[j].requestTime)
+}
+
+func (this byRequestTime) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
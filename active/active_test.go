@@ -0,0 +1,50 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package active
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCancel(t *testing.T) {
+	now := time.Now()
+	state := "running"
+	cancel := func() { state = "stopped" }
+	running := func() string { return "running" }
+
+	Add(NewRequest("r2", "", "SELECT 2", nil, now, running, nil, nil, func() {}))
+	Add(NewRequest("r1", "c1", "SELECT 1", []string{"bob"}, now.Add(-time.Second),
+		func() string { return state }, func() string { return "SELECT $1" }, nil, cancel))
+	defer Remove("r1")
+	defer Remove("r2")
+
+	requests := Requests()
+	if len(requests) != 2 || requests[0].Id() != "r1" || requests[1].Id() != "r2" {
+		t.Errorf("Expected requests r1 and r2 oldest first, got %v", requests)
+	}
+
+	if requests[0].Fingerprint() != "SELECT $1" || requests[1].Fingerprint() != "" {
+		t.Errorf("Expected fingerprint of r1 only")
+	}
+
+	if Cancel("r3") {
+		t.Errorf("Expected no request r3")
+	}
+
+	if !Cancel("r1") || Get("r1").State() != "stopped" {
+		t.Errorf("Expected request r1 to be stopped")
+	}
+
+	Remove("r2")
+	if Count() != 1 {
+		t.Errorf("Expected 1 active request, got %d", Count())
+	}
+}
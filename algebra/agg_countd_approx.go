@@ -0,0 +1,227 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function COUNTD_APPROX(expr). It
+returns an estimate of the number of distinct non-NULL, non-MISSING
+values in the group, computed by HyperLogLog in bounded memory. The
+WITH option precision, between 4 and 18, sets the number of
+registers, 2^precision bytes, and the relative standard error of
+the estimate, 1.04 / sqrt(2^precision). Type CountdApprox is a
+struct that inherits from AggregateBase.
+*/
+type CountdApprox struct {
+	AggregateBase
+	options   value.Value
+	precision uint
+}
+
+/*
+The function NewCountdApprox calls NewAggregateBase to
+create an aggregate function named COUNTD_APPROX with
+one expression as input.
+*/
+func NewCountdApprox(operand expression.Expression) Aggregate {
+	rv := &CountdApprox{
+		AggregateBase: *NewAggregateBase("countd_approx", operand),
+		precision:     _HLL_DEFAULT_PRECISION,
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *CountdApprox) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type NUMBER.
+*/
+func (this *CountdApprox) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *CountdApprox) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+The constructor returns a NewCountdApprox with the input operand
+cast to a Function as the FunctionConstructor. The options are
+kept.
+*/
+func (this *CountdApprox) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		rv := NewCountdApprox(operands[0])
+		if this.options != nil {
+			rv.(*CountdApprox).options = this.options
+			rv.(*CountdApprox).precision = this.precision
+		}
+
+		return rv
+	}
+}
+
+/*
+Return the WITH options, or nil.
+*/
+func (this *CountdApprox) Options() value.Value {
+	return this.options
+}
+
+/*
+Validate and set the WITH options.
+*/
+func (this *CountdApprox) SetOptions(options value.Value) error {
+	fields, ok := options.Actual().(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Options of %s must be an object.", this.Name())
+	}
+
+	precision := uint(_HLL_DEFAULT_PRECISION)
+	for name, v := range fields {
+		switch name {
+		case "precision":
+			p, ok := value.NewValue(v).Actual().(float64)
+			if !ok || p != float64(int(p)) || p < _HLL_MIN_PRECISION || p > _HLL_MAX_PRECISION {
+				return fmt.Errorf("Option precision of %s must be an integer between %d and %d.",
+					this.Name(), _HLL_MIN_PRECISION, _HLL_MAX_PRECISION)
+			}
+
+			precision = uint(p)
+		default:
+			return fmt.Errorf("Invalid option %s of %s.", name, this.Name())
+		}
+	}
+
+	this.options = options
+	this.precision = precision
+	return nil
+}
+
+/*
+Return the relative standard error of the estimate.
+*/
+func (this *CountdApprox) StandardError() float64 {
+	return hllStandardError(this.precision)
+}
+
+/*
+If no input to the COUNTD_APPROX function, then the default value
+returned is a zero value.
+*/
+func (this *CountdApprox) Default() value.Value { return value.ZERO_VALUE }
+
+/*
+Aggregates input data by evaluating operands. Null and missing
+values are ignored. Other values are added to the sketch by
+their JSON encoding.
+*/
+func (this *CountdApprox) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	item, e := this.Operand().Evaluate(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	if item.Type() <= value.NULL {
+		return cumulative, nil
+	}
+
+	data, e := item.MarshalJSON()
+	if e != nil {
+		return nil, e
+	}
+
+	av, ok := cumulative.(value.AnnotatedValue)
+	if !ok {
+		av = value.NewAnnotatedValue(cumulative)
+		av.SetAttachment("hll", newHyperLogLog(this.precision))
+	}
+
+	hll, e := getHyperLogLog(av)
+	if e != nil {
+		return nil, e
+	}
+
+	hll.add(data)
+	return av, nil
+}
+
+/*
+Aggregates intermediate results and return them. If the partial
+value is a zero value return the cumulative value, and if the
+cumulative value is zero then return the partial value.
+*/
+func (this *CountdApprox) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	if part == value.ZERO_VALUE {
+		return cumulative, nil
+	} else if cumulative == value.ZERO_VALUE {
+		return part, nil
+	}
+
+	phll, e := getHyperLogLog(part)
+	if e != nil {
+		return nil, e
+	}
+
+	chll, e := getHyperLogLog(cumulative)
+	if e != nil {
+		return nil, e
+	}
+
+	chll.merge(phll)
+	return cumulative, nil
+}
+
+/*
+Compute the Final result. If input cumulative value is
+a zero value return it. Return the estimate of the sketch.
+*/
+func (this *CountdApprox) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	if cumulative == value.ZERO_VALUE {
+		return cumulative, nil
+	}
+
+	hll, e := getHyperLogLog(cumulative)
+	if e != nil {
+		return nil, e
+	}
+
+	return value.NewValue(hll.estimate()), nil
+}
+
+func getHyperLogLog(item value.Value) (*hyperLogLog, error) {
+	av, ok := item.(value.AnnotatedValue)
+	if !ok {
+		return nil, fmt.Errorf("Invalid COUNTD_APPROX %v of type %T.", item, item)
+	}
+
+	switch hll := av.GetAttachment("hll").(type) {
+	case *hyperLogLog:
+		return hll, nil
+	default:
+		return nil, fmt.Errorf("Invalid COUNTD_APPROX sketch %v of type %T.", hll, hll)
+	}
+}
@@ -0,0 +1,189 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"math"
+	"sort"
+)
+
+/*
+Default compression of t-digests. Higher compression keeps more
+centroids, and gives more accurate quantiles.
+*/
+const _DIGEST_COMPRESSION = 100.0
+
+/*
+Values are buffered, and merged into the centroids once the buffer
+holds this many times the compression.
+*/
+const _DIGEST_BUFFER = 5
+
+type centroid struct {
+	mean   float64
+	weight float64
+}
+
+/*
+tdigest is a merging t-digest, which estimates quantiles in bounded
+memory. Values are summarized by centroids, which are kept small near
+the extreme quantiles, so that the tails are estimated accurately.
+Digests of partial inputs can be merged, so that they can be computed
+in parallel.
+*/
+type tdigest struct {
+	compression float64
+	centroids   []centroid
+	buffer      []centroid
+	total       float64
+	min         float64
+	max         float64
+}
+
+func newTDigest(compression float64) *tdigest {
+	return &tdigest{
+		compression: compression,
+		buffer:      make([]centroid, 0, int(compression)*_DIGEST_BUFFER),
+	}
+}
+
+func (this *tdigest) add(x float64) {
+	this.addCentroid(centroid{x, 1})
+}
+
+func (this *tdigest) merge(other quantiles) {
+	od := other.(*tdigest)
+	od.compress()
+	for _, c := range od.centroids {
+		this.addCentroid(c)
+	}
+
+	if od.total > 0 {
+		this.min = math.Min(this.min, od.min)
+		this.max = math.Max(this.max, od.max)
+	}
+}
+
+func (this *tdigest) addCentroid(c centroid) {
+	if this.total == 0 {
+		this.min, this.max = c.mean, c.mean
+	} else if c.mean < this.min {
+		this.min = c.mean
+	} else if c.mean > this.max {
+		this.max = c.mean
+	}
+
+	this.buffer = append(this.buffer, c)
+	this.total += c.weight
+
+	if len(this.buffer) == cap(this.buffer) {
+		this.compress()
+	}
+}
+
+/*
+Merge the buffered values into the centroids. Adjacent centroids are
+combined while the combined weight stays within the bound for its
+quantiles, q(1-q) * 4 * total / compression.
+*/
+func (this *tdigest) compress() {
+	if len(this.buffer) == 0 {
+		return
+	}
+
+	all := make([]centroid, 0, len(this.centroids)+len(this.buffer))
+	all = append(all, this.centroids...)
+	all = append(all, this.buffer...)
+	sort.Sort(centroids(all))
+
+	merged := all[:1]
+	soFar := 0.0
+	for _, c := range all[1:] {
+		last := &merged[len(merged)-1]
+		proposed := last.weight + c.weight
+		q0 := soFar / this.total
+		q2 := (soFar + proposed) / this.total
+		limit := 4 * this.total * math.Min(q0*(1-q0), q2*(1-q2)) / this.compression
+
+		if proposed <= limit {
+			last.mean += (c.mean - last.mean) * c.weight / proposed
+			last.weight = proposed
+		} else {
+			soFar += last.weight
+			merged = append(merged, c)
+		}
+	}
+
+	this.centroids = merged
+	this.buffer = this.buffer[:0]
+}
+
+/*
+Estimate the quantile p. Continuous quantiles interpolate between
+the centers of adjacent centroids; discrete quantiles return the
+mean of the centroid that holds the rank.
+*/
+func (this *tdigest) quantile(p float64, discrete bool) float64 {
+	this.compress()
+
+	n := len(this.centroids)
+	if p <= 0 || n == 1 && this.centroids[0].weight == 1 {
+		return this.min
+	} else if p >= 1 {
+		return this.max
+	}
+
+	target := p * this.total
+	cum := 0.0
+
+	if discrete {
+		for _, c := range this.centroids {
+			cum += c.weight
+			if cum >= target {
+				return c.mean
+			}
+		}
+
+		return this.max
+	}
+
+	for i, c := range this.centroids {
+		center := cum + c.weight/2
+		if target < center {
+			if i == 0 {
+				return this.min + (c.mean-this.min)*target/center
+			}
+
+			prev := this.centroids[i-1]
+			prevCenter := cum - prev.weight/2
+			return prev.mean + (c.mean-prev.mean)*(target-prevCenter)/(center-prevCenter)
+		}
+
+		cum += c.weight
+	}
+
+	last := this.centroids[n-1]
+	lastCenter := this.total - last.weight/2
+	return last.mean + (this.max-last.mean)*(target-lastCenter)/(this.total-lastCenter)
+}
+
+type centroids []centroid
+
+func (this centroids) Len() int {
+	return len(this)
+}
+
+func (this centroids) Less(i, j int) bool {
+	return this[i].mean < this[j].mean
+}
+
+func (this centroids) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
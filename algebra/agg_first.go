@@ -0,0 +1,98 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function FIRST(expr ORDER BY keys).
+It returns the value of expr for the item of the group that sorts
+first by the keys, ignoring items where expr is missing. Type
+First is a struct that inherits from orderedBase.
+*/
+type First struct {
+	orderedBase
+}
+
+/*
+The function NewFirst calls newOrderedBase to create an
+aggregate function named FIRST with one expression and the
+ORDER BY keys as input.
+*/
+func NewFirst(operand expression.Expression, keys expression.Expressions,
+	descending []bool) Aggregate {
+	rv := &First{
+		*newOrderedBase("first", operand, keys, descending),
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *First) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type JSON.
+*/
+func (this *First) Type() value.Type { return value.JSON }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *First) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+The constructor returns a NewFirst with the first operand as
+the argument and the rest as the keys, keeping their directions.
+*/
+func (this *First) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		return NewFirst(operands[0], operands[1:], this.descending)
+	}
+}
+
+/*
+Aggregates input data by evaluating the argument and the keys,
+and keeping the item that sorts first.
+*/
+func (this *First) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	part, e := this.orderedPart(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	return this.cumulateOrdered(part, cumulative, false)
+}
+
+/*
+Aggregates intermediate results and return them.
+*/
+func (this *First) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	return this.cumulateOrdered(part, cumulative, false)
+}
+
+/*
+Returns the value of the item that sorts first.
+*/
+func (this *First) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	return this.computeOrdered(cumulative)
+}
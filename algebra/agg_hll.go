@@ -0,0 +1,120 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"hash/fnv"
+	"math"
+	"math/bits"
+)
+
+/*
+Bounds and default of the HyperLogLog precision, the number of
+bits of the hash that select a register.
+*/
+const (
+	_HLL_MIN_PRECISION     = 4
+	_HLL_MAX_PRECISION     = 18
+	_HLL_DEFAULT_PRECISION = 14
+)
+
+/*
+hyperLogLog estimates the number of distinct values in 2^p
+registers of one byte each. Each register keeps the longest run
+of leading zeros among the hashes it was selected by. Sketches of
+partial inputs are merged by taking the maximum of each register.
+*/
+type hyperLogLog struct {
+	precision uint
+	registers []uint8
+}
+
+func newHyperLogLog(precision uint) *hyperLogLog {
+	return &hyperLogLog{
+		precision: precision,
+		registers: make([]uint8, 1<<precision),
+	}
+}
+
+func (this *hyperLogLog) add(data []byte) {
+	h := fnv.New64a()
+	h.Write(data)
+	x := mix64(h.Sum64())
+
+	i := x >> (64 - this.precision)
+	rho := uint8(bits.LeadingZeros64(x<<this.precision|1<<(this.precision-1)) + 1)
+	if rho > this.registers[i] {
+		this.registers[i] = rho
+	}
+}
+
+func (this *hyperLogLog) merge(other *hyperLogLog) {
+	for i, r := range other.registers {
+		if r > this.registers[i] {
+			this.registers[i] = r
+		}
+	}
+}
+
+/*
+The raw estimate is corrected by linear counting while registers
+are still empty. The 64-bit hash needs no large range correction.
+*/
+func (this *hyperLogLog) estimate() float64 {
+	m := float64(len(this.registers))
+	sum := 0.0
+	zeros := 0
+	for _, r := range this.registers {
+		sum += 1 / float64(uint64(1)<<r)
+		if r == 0 {
+			zeros++
+		}
+	}
+
+	e := hllAlpha(m) * m * m / sum
+	if e <= 2.5*m && zeros > 0 {
+		e = m * math.Log(m/float64(zeros))
+	}
+
+	return math.Floor(e + 0.5)
+}
+
+/*
+The relative standard error of estimates with the given precision.
+*/
+func hllStandardError(precision uint) float64 {
+	return 1.04 / math.Sqrt(float64(uint64(1)<<precision))
+}
+
+func hllAlpha(m float64) float64 {
+	switch m {
+	case 16:
+		return 0.673
+	case 32:
+		return 0.697
+	case 64:
+		return 0.709
+	default:
+		return 0.7213 / (1 + 1.079/m)
+	}
+}
+
+/*
+FNV hashes of short inputs do not spread over the high bits, which
+select the register; the finalizer of MurmurHash3 mixes them.
+*/
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
@@ -0,0 +1,101 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function MEDIAN(expr). It returns
+the median of all the number values in the group, interpolating
+between the two middle values if their count is even. Type Median
+is a struct that inherits from quantileBase.
+*/
+type Median struct {
+	quantileBase
+}
+
+/*
+The function NewMedian calls newQuantileBase to
+create an aggregate function named MEDIAN with
+one expression as input.
+*/
+func NewMedian(operand expression.Expression) Aggregate {
+	rv := &Median{
+		*newQuantileBase("median", operand),
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Median) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type NUMBER.
+*/
+func (this *Median) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *Median) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+The constructor returns a NewMedian with the input operand
+cast to a Function as the FunctionConstructor. The options are
+kept.
+*/
+func (this *Median) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		rv := NewMedian(operands[0])
+		rv.(*Median).copyOptions(&this.quantileBase)
+		return rv
+	}
+}
+
+/*
+If no input to the MEDIAN function, then the default value
+returned is a null.
+*/
+func (this *Median) Default() value.Value { return value.NULL_VALUE }
+
+/*
+Aggregates input data by evaluating operands. Values other than
+numbers are ignored.
+*/
+func (this *Median) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	return this.quantileAdd(item, cumulative, context)
+}
+
+/*
+Aggregates intermediate results and return them.
+*/
+func (this *Median) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	return cumulateQuantiles(part, cumulative)
+}
+
+/*
+Compute the continuous quantile 0.5.
+*/
+func (this *Median) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	return computeQuantile(cumulative, 0.5, false)
+}
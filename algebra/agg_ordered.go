@@ -0,0 +1,153 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Base class for the aggregates FIRST and LAST, which return the
+value of their argument for the item that sorts first or last by
+the ORDER BY keys. The keys follow the argument in the operands.
+The cumulative value is an object holding the keys and the value
+of the item kept so far, or null if there is none.
+*/
+type orderedBase struct {
+	AggregateBase
+	descending []bool
+}
+
+func newOrderedBase(name string, operand expression.Expression,
+	keys expression.Expressions, descending []bool) *orderedBase {
+	operands := make(expression.Expressions, 0, len(keys)+1)
+	operands = append(operands, operand)
+	operands = append(operands, keys...)
+
+	return &orderedBase{
+		AggregateBase: AggregateBase{
+			UnaryFunctionBase: expression.UnaryFunctionBase{
+				FunctionBase: *expression.NewFunctionBase(name, operands...),
+			},
+		},
+		descending: descending,
+	}
+}
+
+/*
+The argument is the only leading operand.
+*/
+func (this *orderedBase) Arguments() int {
+	return 1
+}
+
+/*
+Return the direction of each ORDER BY key.
+*/
+func (this *orderedBase) Descending() []bool {
+	return this.descending
+}
+
+/*
+If no input to the function, then the default value returned is
+a null.
+*/
+func (this *orderedBase) Default() value.Value { return value.NULL_VALUE }
+
+/*
+Evaluate the argument and the keys of the input item. Items whose
+argument is missing are ignored.
+*/
+func (this *orderedBase) orderedPart(item value.Value, context Context) (value.Value, error) {
+	val, e := this.Operand().Evaluate(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	if val.Type() == value.MISSING {
+		return value.NULL_VALUE, nil
+	}
+
+	ops := this.Operands()
+	keys := make([]interface{}, len(ops)-1)
+	for i, op := range ops[1:] {
+		keys[i], e = op.Evaluate(item, context)
+		if e != nil {
+			return nil, e
+		}
+	}
+
+	return value.NewValue(map[string]interface{}{
+		"keys":  keys,
+		"value": val,
+	}), nil
+}
+
+/*
+Keep the partial value if it sorts before the cumulative value,
+or after it if last is true. Ties keep the cumulative value.
+*/
+func (this *orderedBase) cumulateOrdered(part, cumulative value.Value, last bool) (value.Value, error) {
+	if part == value.NULL_VALUE {
+		return cumulative, nil
+	} else if cumulative == value.NULL_VALUE {
+		return part, nil
+	}
+
+	pk, ok := part.Field("keys")
+	if !ok {
+		return nil, fmt.Errorf("Invalid partial %s %v.", this.Name(), part)
+	}
+
+	ck, ok := cumulative.Field("keys")
+	if !ok {
+		return nil, fmt.Errorf("Invalid cumulative %s %v.", this.Name(), cumulative)
+	}
+
+	for i, desc := range this.descending {
+		p, _ := pk.Index(i)
+		c, _ := ck.Index(i)
+
+		cmp := p.Collate(c)
+		if desc {
+			cmp = -cmp
+		}
+
+		if cmp != 0 {
+			if (cmp < 0) != last {
+				return part, nil
+			}
+
+			break
+		}
+	}
+
+	return cumulative, nil
+}
+
+/*
+Return the value of the item that was kept, or null if there was
+none.
+*/
+func (this *orderedBase) computeOrdered(cumulative value.Value) (value.Value, error) {
+	if cumulative == value.NULL_VALUE {
+		return cumulative, nil
+	}
+
+	val, ok := cumulative.Field("value")
+	if !ok {
+		return nil, fmt.Errorf("Invalid cumulative %s %v.", this.Name(), cumulative)
+	}
+
+	return val, nil
+}
@@ -0,0 +1,118 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function PERCENTILE_DISC(expr,
+fraction). It returns the first of the sorted number values in the
+group whose cumulative distribution is at least the given fraction.
+The fraction must be a constant between 0 and 1. Type
+PercentileDisc is a struct that inherits from quantileBase.
+*/
+type PercentileDisc struct {
+	quantileBase
+}
+
+/*
+The function NewPercentileDisc calls newQuantileBase to
+create an aggregate function named PERCENTILE_DISC with
+two expressions as input.
+*/
+func NewPercentileDisc(operand, fraction expression.Expression) Aggregate {
+	rv := &PercentileDisc{
+		*newQuantileBase("percentile_disc", operand, fraction),
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *PercentileDisc) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type NUMBER.
+*/
+func (this *PercentileDisc) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *PercentileDisc) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+Minimum input arguments required is 2.
+*/
+func (this *PercentileDisc) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments allowed is 2.
+*/
+func (this *PercentileDisc) MaxArgs() int { return 2 }
+
+/*
+The constructor returns a NewPercentileDisc with the input operands
+cast to a Function as the FunctionConstructor. The options are
+kept.
+*/
+func (this *PercentileDisc) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		rv := NewPercentileDisc(operands[0], operands[1])
+		rv.(*PercentileDisc).copyOptions(&this.quantileBase)
+		return rv
+	}
+}
+
+/*
+If no input to the PERCENTILE_DISC function, then the default value
+returned is a null.
+*/
+func (this *PercentileDisc) Default() value.Value { return value.NULL_VALUE }
+
+/*
+Aggregates input data by evaluating operands. Values other than
+numbers are ignored.
+*/
+func (this *PercentileDisc) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	return this.quantileAdd(item, cumulative, context)
+}
+
+/*
+Aggregates intermediate results and return them.
+*/
+func (this *PercentileDisc) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	return cumulateQuantiles(part, cumulative)
+}
+
+/*
+Compute the discrete quantile at the fraction. If the fraction
+is not a constant between 0 and 1, return an error.
+*/
+func (this *PercentileDisc) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	p, e := this.fraction()
+	if e != nil {
+		return nil, e
+	}
+
+	return computeQuantile(cumulative, p, true)
+}
@@ -0,0 +1,257 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+	"math"
+	"sort"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Base class for the quantile aggregates MEDIAN, PERCENTILE_CONT and
+PERCENTILE_DISC. By default, all the number values of a group are
+kept, and the quantile is exact. The WITH option approximate
+selects a t-digest instead, which uses bounded memory for large
+groups; the option compression trades memory for accuracy.
+*/
+type quantileBase struct {
+	AggregateBase
+	options     value.Value
+	approximate bool
+	compression float64
+}
+
+func newQuantileBase(name string, operands ...expression.Expression) *quantileBase {
+	return &quantileBase{
+		AggregateBase: AggregateBase{
+			UnaryFunctionBase: expression.UnaryFunctionBase{
+				FunctionBase: *expression.NewFunctionBase(name, operands...),
+			},
+		},
+		compression: _DIGEST_COMPRESSION,
+	}
+}
+
+/*
+Return the WITH options, or nil.
+*/
+func (this *quantileBase) Options() value.Value {
+	return this.options
+}
+
+/*
+Validate and set the WITH options.
+*/
+func (this *quantileBase) SetOptions(options value.Value) error {
+	fields, ok := options.Actual().(map[string]interface{})
+	if !ok {
+		return fmt.Errorf("Options of %s must be an object.", this.Name())
+	}
+
+	approximate := false
+	compression := _DIGEST_COMPRESSION
+	for name, v := range fields {
+		v := value.NewValue(v)
+		switch name {
+		case "approximate":
+			approximate, ok = v.Actual().(bool)
+			if !ok {
+				return fmt.Errorf("Option approximate of %s must be a boolean.", this.Name())
+			}
+		case "compression":
+			compression, ok = v.Actual().(float64)
+			if !ok || compression < 1 {
+				return fmt.Errorf("Option compression of %s must be a number of at least 1.",
+					this.Name())
+			}
+		default:
+			return fmt.Errorf("Invalid option %s of %s.", name, this.Name())
+		}
+	}
+
+	this.options = options
+	this.approximate = approximate
+	this.compression = compression
+	return nil
+}
+
+func (this *quantileBase) copyOptions(other *quantileBase) {
+	if other.options != nil {
+		this.options = other.options
+		this.approximate = other.approximate
+		this.compression = other.compression
+	}
+}
+
+/*
+Return the fraction of PERCENTILE_CONT and PERCENTILE_DISC, which
+must be a constant between 0 and 1.
+*/
+func (this *quantileBase) fraction() (float64, error) {
+	v := this.Operands()[1].Value()
+	if v != nil && v.Type() == value.NUMBER {
+		f := v.Actual().(float64)
+		if f >= 0 && f <= 1 {
+			return f, nil
+		}
+	}
+
+	return 0, fmt.Errorf("The fraction of %s must be a constant number between 0 and 1.",
+		this.Name())
+}
+
+/*
+Add the input item, if it is a number, to the cumulative
+quantiles. The quantiles are created on the first number.
+*/
+func (this *quantileBase) quantileAdd(item, cumulative value.Value, context Context) (value.Value, error) {
+	item, e := this.Operand().Evaluate(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	if item.Type() != value.NUMBER {
+		return cumulative, nil
+	}
+
+	av, ok := cumulative.(value.AnnotatedValue)
+	if !ok {
+		av = value.NewAnnotatedValue(cumulative)
+		if this.approximate {
+			av.SetAttachment("quantiles", newTDigest(this.compression))
+		} else {
+			av.SetAttachment("quantiles", &exactQuantiles{})
+		}
+	}
+
+	q, e := getQuantiles(av)
+	if e != nil {
+		return nil, e
+	}
+
+	q.add(item.Actual().(float64))
+	return av, nil
+}
+
+/*
+Aggregate intermediate quantiles.
+*/
+func cumulateQuantiles(part, cumulative value.Value) (value.Value, error) {
+	if part == value.NULL_VALUE {
+		return cumulative, nil
+	} else if cumulative == value.NULL_VALUE {
+		return part, nil
+	}
+
+	pq, e := getQuantiles(part)
+	if e != nil {
+		return nil, e
+	}
+
+	cq, e := getQuantiles(cumulative)
+	if e != nil {
+		return nil, e
+	}
+
+	cq.merge(pq)
+	return cumulative, nil
+}
+
+/*
+Compute the quantile p of the cumulative quantiles. If there
+were no numbers, return a null value.
+*/
+func computeQuantile(cumulative value.Value, p float64, discrete bool) (value.Value, error) {
+	if cumulative == value.NULL_VALUE {
+		return cumulative, nil
+	}
+
+	q, e := getQuantiles(cumulative)
+	if e != nil {
+		return nil, e
+	}
+
+	return value.NewValue(q.quantile(p, discrete)), nil
+}
+
+func getQuantiles(item value.Value) (quantiles, error) {
+	av, ok := item.(value.AnnotatedValue)
+	if !ok {
+		return nil, fmt.Errorf("Invalid quantiles %v of type %T.", item, item)
+	}
+
+	switch q := av.GetAttachment("quantiles").(type) {
+	case quantiles:
+		return q, nil
+	default:
+		return nil, fmt.Errorf("Invalid quantiles %v of type %T.", q, q)
+	}
+}
+
+/*
+quantiles summarizes the number values of a group. The group has
+at least one value.
+*/
+type quantiles interface {
+	add(x float64)
+	merge(other quantiles)
+	quantile(p float64, discrete bool) float64
+}
+
+/*
+exactQuantiles keeps all the values, and sorts them once the
+quantile is computed.
+*/
+type exactQuantiles struct {
+	values []float64
+	sorted bool
+}
+
+func (this *exactQuantiles) add(x float64) {
+	this.values = append(this.values, x)
+	this.sorted = false
+}
+
+func (this *exactQuantiles) merge(other quantiles) {
+	this.values = append(this.values, other.(*exactQuantiles).values...)
+	this.sorted = false
+}
+
+/*
+Continuous quantiles interpolate linearly between the values
+at positions floor and ceil of p * (n - 1). Discrete quantiles
+return the first value whose cumulative distribution is at
+least p.
+*/
+func (this *exactQuantiles) quantile(p float64, discrete bool) float64 {
+	if !this.sorted {
+		sort.Float64s(this.values)
+		this.sorted = true
+	}
+
+	n := len(this.values)
+	if discrete {
+		i := int(math.Ceil(p*float64(n))) - 1
+		if i < 0 {
+			i = 0
+		}
+
+		return this.values[i]
+	}
+
+	pos := p * float64(n-1)
+	lo := math.Floor(pos)
+	hi := math.Ceil(pos)
+	return this.values[int(lo)] + (this.values[int(hi)]-this.values[int(lo)])*(pos-lo)
+}
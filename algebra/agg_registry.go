@@ -48,13 +48,21 @@ var _DISTINCT_AGGREGATES = map[string]Aggregate{
 /*
 Non Distinct Aggregate functions. The variable represents a
 map from string to Aggregate Function. Contains aggregate
-functions ARRAY_AGG, AVG, COUNT, MAX, MIN and SUM.
+functions ARRAY_AGG, AVG, COUNT, MAX, MIN and SUM, the
+statistical aggregates MEDIAN, PERCENTILE_CONT, PERCENTILE_DISC,
+STDDEV and VARIANCE, and the approximate COUNTD_APPROX.
 */
 var _OTHER_AGGREGATES = map[string]Aggregate{
-	"array_agg": &ArrayAgg{},
-	"avg":       &Avg{},
-	"count":     &Count{},
-	"max":       &Max{},
-	"min":       &Min{},
-	"sum":       &Sum{},
+	"array_agg":       &ArrayAgg{},
+	"avg":             &Avg{},
+	"count":           &Count{},
+	"countd_approx":   &CountdApprox{},
+	"max":             &Max{},
+	"median":          &Median{},
+	"min":             &Min{},
+	"percentile_cont": &PercentileCont{},
+	"percentile_disc": &PercentileDisc{},
+	"stddev":          &Stddev{},
+	"sum":             &Sum{},
+	"variance":        &Variance{},
 }
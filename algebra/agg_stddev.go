@@ -0,0 +1,118 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"math"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function STDDEV(expr). It returns
+the sample standard deviation of all the number values in the group,
+or 0 if there is only one. Type Stddev is a struct that inherits from
+AggregateBase.
+*/
+type Stddev struct {
+	AggregateBase
+}
+
+/*
+The function NewStddev calls NewAggregateBase to
+create an aggregate function named STDDEV with
+one expression as input.
+*/
+func NewStddev(operand expression.Expression) Aggregate {
+	rv := &Stddev{
+		*NewAggregateBase("stddev", operand),
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Stddev) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type NUMBER.
+*/
+func (this *Stddev) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *Stddev) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+The constructor returns a NewStddev with the input operand
+cast to a Function as the FunctionConstructor.
+*/
+func (this *Stddev) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		return NewStddev(operands[0])
+	}
+}
+
+/*
+If no input to the STDDEV function, then the default value
+returned is a null.
+*/
+func (this *Stddev) Default() value.Value { return value.NULL_VALUE }
+
+/*
+Aggregates input data by evaluating operands. Values other than
+numbers are ignored.
+*/
+func (this *Stddev) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	item, e := this.Operand().Evaluate(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	if item.Type() != value.NUMBER {
+		return cumulative, nil
+	}
+
+	return cumulateVariance(variancePart(item), cumulative, "STDDEV")
+}
+
+/*
+Aggregates intermediate results and return them.
+*/
+func (this *Stddev) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	return cumulateVariance(part, cumulative, "STDDEV")
+}
+
+/*
+Compute the square root of the sample variance.
+*/
+func (this *Stddev) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	if cumulative == value.NULL_VALUE {
+		return cumulative, nil
+	}
+
+	variance, e := computeVariance(cumulative, "STDDEV")
+	if e != nil {
+		return nil, e
+	}
+
+	return value.NewValue(math.Sqrt(variance)), nil
+}
@@ -0,0 +1,180 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+This represents the Aggregate function VARIANCE(expr). It returns
+the sample variance of all the number values in the group, or 0 if
+there is only one. Type Variance is a struct that inherits from
+AggregateBase.
+*/
+type Variance struct {
+	AggregateBase
+}
+
+/*
+The function NewVariance calls NewAggregateBase to
+create an aggregate function named VARIANCE with
+one expression as input.
+*/
+func NewVariance(operand expression.Expression) Aggregate {
+	rv := &Variance{
+		*NewAggregateBase("variance", operand),
+	}
+
+	rv.SetExpr(rv)
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Variance) Accept(visitor expression.Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value of type NUMBER.
+*/
+func (this *Variance) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the evaluate method for aggregate functions and passes in the
+receiver, current item and current context.
+*/
+func (this *Variance) Evaluate(item value.Value, context expression.Context) (result value.Value, e error) {
+	return this.evaluate(this, item, context)
+}
+
+/*
+The constructor returns a NewVariance with the input operand
+cast to a Function as the FunctionConstructor.
+*/
+func (this *Variance) Constructor() expression.FunctionConstructor {
+	return func(operands ...expression.Expression) expression.Function {
+		return NewVariance(operands[0])
+	}
+}
+
+/*
+If no input to the VARIANCE function, then the default value
+returned is a null.
+*/
+func (this *Variance) Default() value.Value { return value.NULL_VALUE }
+
+/*
+Aggregates input data by evaluating operands. Values other than
+numbers are ignored.
+*/
+func (this *Variance) CumulateInitial(item, cumulative value.Value, context Context) (value.Value, error) {
+	item, e := this.Operand().Evaluate(item, context)
+	if e != nil {
+		return nil, e
+	}
+
+	if item.Type() != value.NUMBER {
+		return cumulative, nil
+	}
+
+	return cumulateVariance(variancePart(item), cumulative, "VARIANCE")
+}
+
+/*
+Aggregates intermediate results and return them.
+*/
+func (this *Variance) CumulateIntermediate(part, cumulative value.Value, context Context) (value.Value, error) {
+	return cumulateVariance(part, cumulative, "VARIANCE")
+}
+
+/*
+Compute the sample variance from the count, mean and sum of
+squared deviations.
+*/
+func (this *Variance) ComputeFinal(cumulative value.Value, context Context) (value.Value, error) {
+	if cumulative == value.NULL_VALUE {
+		return cumulative, nil
+	}
+
+	variance, e := computeVariance(cumulative, "VARIANCE")
+	if e != nil {
+		return nil, e
+	}
+
+	return value.NewValue(variance), nil
+}
+
+func variancePart(item value.Value) value.Value {
+	return value.NewValue(map[string]interface{}{"count": 1, "mean": item.Actual(), "m2": 0})
+}
+
+/*
+Aggregate partial counts, means and sums of squared deviations,
+as in the parallel algorithm of Chan et al., which is stable for
+large counts.
+*/
+func cumulateVariance(part, cumulative value.Value, name string) (value.Value, error) {
+	if part == value.NULL_VALUE {
+		return cumulative, nil
+	} else if cumulative == value.NULL_VALUE {
+		return part, nil
+	}
+
+	pcount, pmean, pm2, e := varianceFields(part, name)
+	if e != nil {
+		return nil, e
+	}
+
+	ccount, cmean, cm2, e := varianceFields(cumulative, name)
+	if e != nil {
+		return nil, e
+	}
+
+	count := pcount + ccount
+	delta := pmean - cmean
+	cumulative.SetField("count", count)
+	cumulative.SetField("mean", cmean+delta*pcount/count)
+	cumulative.SetField("m2", cm2+pm2+delta*delta*pcount*ccount/count)
+	return cumulative, nil
+}
+
+func computeVariance(cumulative value.Value, name string) (float64, error) {
+	count, _, m2, e := varianceFields(cumulative, name)
+	if e != nil {
+		return 0, e
+	}
+
+	if count <= 1 {
+		return 0, nil
+	}
+
+	return m2 / (count - 1), nil
+}
+
+func varianceFields(item value.Value, name string) (count, mean, m2 float64, e error) {
+	c, _ := item.Field("count")
+	m, _ := item.Field("mean")
+	s, _ := item.Field("m2")
+
+	if c.Type() != value.NUMBER || m.Type() != value.NUMBER || s.Type() != value.NUMBER {
+		e = fmt.Errorf("Missing or invalid count, mean or m2 in %s: %v, %v, %v.",
+			name, c.Actual(), m.Actual(), s.Actual())
+		return
+	}
+
+	return c.Actual().(float64), m.Actual().(float64), s.Actual().(float64), nil
+}
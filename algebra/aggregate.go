@@ -65,6 +65,32 @@ type Aggregate interface {
 	ComputeFinal(cumulative value.Value, context Context) (value.Value, error)
 }
 
+/*
+Aggregates that accept WITH options, such as the approximate
+quantile aggregates.
+*/
+type OptionsAggregate interface {
+	Aggregate
+	expression.OptionsFunction
+
+	/*
+	   Validates and sets the options.
+	*/
+	SetOptions(options value.Value) error
+}
+
+/*
+Aggregates whose results are estimates, such as COUNTD_APPROX.
+*/
+type ApproximateAggregate interface {
+	Aggregate
+
+	/*
+	   Returns the relative standard error of the estimates.
+	*/
+	StandardError() float64
+}
+
 /*
 Base class for Aggregate functions. It inherits from
 expressions UnaryFunctionBase, and has field text
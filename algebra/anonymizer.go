@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Anonymizer replaces the literals of a statement by positional
+parameters, numbered in the order they are found. Null, missing and
+boolean literals are kept, as they carry no data values. Array and
+object literals are replaced by a single parameter, so that IN lists
+of different lengths look the same.
+*/
+type Anonymizer struct {
+	expression.MapperBase
+	count int
+}
+
+func NewAnonymizer() *Anonymizer {
+	rv := &Anonymizer{}
+	rv.SetMapper(rv)
+	return rv
+}
+
+func (this *Anonymizer) VisitConstant(expr *expression.Constant) (interface{}, error) {
+	switch expr.Value().Type() {
+	case value.NULL, value.MISSING, value.BOOLEAN:
+		return expr, nil
+	}
+
+	return this.placeholder(), nil
+}
+
+func (this *Anonymizer) VisitArrayConstruct(expr *expression.ArrayConstruct) (interface{}, error) {
+	if expr.Value() != nil {
+		return this.placeholder(), nil
+	}
+
+	return expr, expr.MapChildren(this)
+}
+
+func (this *Anonymizer) VisitObjectConstruct(expr *expression.ObjectConstruct) (interface{}, error) {
+	if expr.Value() != nil {
+		return this.placeholder(), nil
+	}
+
+	return expr, expr.MapChildren(this)
+}
+
+/*
+The children of a subquery are copies of its expressions, so the
+subquery itself is mapped.
+*/
+func (this *Anonymizer) VisitSubquery(expr expression.Subquery) (interface{}, error) {
+	if subq, ok := expr.(*Subquery); ok {
+		return expr, subq.Select().MapExpressions(this)
+	}
+
+	return expr, nil
+}
+
+func (this *Anonymizer) placeholder() expression.Expression {
+	this.count++
+	return NewPositionalParameter(this.count)
+}
+
+/*
+Return the fingerprint of the statement: its formatted text on a
+single line, with the literals replaced by parameters. Statements
+that differ only in their literals and layout have the same
+fingerprint, which can be logged without leaking data values. The
+statement is rewritten in place, so it must not be executed
+afterwards.
+*/
+func Fingerprint(stmt Statement) (string, error) {
+	err := stmt.MapExpressions(NewAnonymizer())
+	if err != nil {
+		return "", err
+	}
+
+	text, err := NewFormatter(0, false).Format(stmt)
+	if err != nil {
+		return "", err
+	}
+
+	return strings.Replace(text, "\n", " ", -1), nil
+}
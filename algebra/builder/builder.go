@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package builder constructs N1QL statements programmatically, without
+the parser. Statements are built fluently and produce algebra nodes
+directly, as in
+
+	stmt, err := builder.Select(builder.Term(builder.I("b.name"))).
+		From("b").
+		Where(expression.NewEq(builder.I("b.type"), builder.P("type"))).
+		OrderBy(builder.Asc(builder.I("b.name"))).
+		Limit(builder.C(10)).
+		Build()
+
+Names are taken verbatim, and values are passed as constants or
+parameters, so that no text is ever parsed. Expressions are built by
+the constructors of the expression package, with the helpers below
+for the common cases. Build formalizes the statement, as the parser
+does, so that it is ready to be planned.
+*/
+package builder
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+A path of identifiers separated by dots, e.g. b.address.city. The
+first identifier is a variable or keyspace alias, and the others are
+field names.
+*/
+func I(path string) expression.Path {
+	names := strings.Split(path, ".")
+	var rv expression.Path = expression.NewIdentifier(names[0])
+	for _, name := range names[1:] {
+		rv = expression.NewField(rv, expression.NewFieldName(name, false))
+	}
+
+	return rv
+}
+
+// A constant of any JSON value.
+func C(val interface{}) expression.Expression {
+	return expression.NewConstant(val)
+}
+
+// A named parameter, e.g. $type, bound when the statement is executed.
+func P(name string) expression.Expression {
+	return algebra.NewNamedParameter(name)
+}
+
+// A subquery.
+func Subquery(query *algebra.Select) expression.Expression {
+	return algebra.NewSubquery(query)
+}
+
+// A result term.
+func Term(expr expression.Expression) *algebra.ResultTerm {
+	return algebra.NewResultTerm(expr, false, "")
+}
+
+// A result term with an alias.
+func As(expr expression.Expression, alias string) *algebra.ResultTerm {
+	return algebra.NewResultTerm(expr, false, alias)
+}
+
+// All the fields of an object, or of the item if expr is nil.
+func Star(expr expression.Expression) *algebra.ResultTerm {
+	return algebra.NewResultTerm(expr, true, "")
+}
+
+// An ascending sort term.
+func Asc(expr expression.Expression) *algebra.SortTerm {
+	return algebra.NewSortTerm(expr, false)
+}
+
+// A descending sort term.
+func Desc(expr expression.Expression) *algebra.SortTerm {
+	return algebra.NewSortTerm(expr, true)
+}
+
+// A namespace:keyspace name, or a keyspace name alone.
+func splitKeyspace(name string) (string, string) {
+	if i := strings.Index(name, ":"); i >= 0 {
+		return name[:i], name[i+1:]
+	}
+
+	return "", name
+}
@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package builder
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+func TestBuilder(t *testing.T) {
+	sel, err := Select(Term(I("b.name")), As(I("c.city"), "city")).
+		From("default:b").
+		Join("c", I("b.cid")).
+		Where(expression.NewEq(I("b.type"), P("type"))).
+		OrderBy(Desc(I("b.name"))).
+		Limit(C(10)).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update, err := Update("b").UseKeys(C("k1")).
+		Set(I("b.status"), C("done")).
+		Unset(I("b.pending")).
+		Returning(Star(I("b"))).
+		Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	insert, err := Insert("b").Values(C("k2"), C("v2")).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	del, err := Delete("b").Where(expression.NewIsMissing(I("b.status"))).Build()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cases := []struct {
+		stmt  algebra.Statement
+		query string
+	}{
+		{sel, "SELECT b.name, c.city AS city FROM default:b JOIN c ON KEYS b.cid " +
+			"WHERE b.type = $type ORDER BY b.name DESC LIMIT 10"},
+		{update, "UPDATE b USE KEYS \"k1\" SET b.status = \"done\" UNSET b.pending RETURNING b.*"},
+		{insert, "INSERT INTO b VALUES (\"k2\", \"v2\")"},
+		{del, "DELETE FROM b WHERE b.status IS MISSING"},
+	}
+
+	formatter := algebra.NewFormatter(0, false)
+	for _, c := range cases {
+		parsed, err := n1ql.ParseStatement(c.query)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		expected, err := formatter.Format(parsed)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		actual, err := formatter.Format(c.stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if actual != expected {
+			t.Errorf("Expected %s, got %s", expected, actual)
+		}
+	}
+}
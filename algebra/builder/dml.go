@@ -0,0 +1,253 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package builder
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+The RETURNING clause of a DML statement: result terms, or a raw
+expression.
+*/
+type returning struct {
+	terms algebra.ResultTerms
+	raw   expression.Expression
+}
+
+func (this *returning) projection() *algebra.Projection {
+	if this.raw != nil {
+		return algebra.NewRawProjection(false, this.raw, "")
+	} else if len(this.terms) > 0 {
+		return algebra.NewProjection(false, this.terms)
+	}
+
+	return nil
+}
+
+func keyspaceRef(keyspace, alias string) *algebra.KeyspaceRef {
+	namespace, name := splitKeyspace(keyspace)
+	return algebra.NewKeyspaceRef(namespace, name, alias)
+}
+
+func build(stmt algebra.Statement) (algebra.Statement, error) {
+	err := stmt.Formalize()
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+/*
+DeleteBuilder builds a DELETE statement.
+*/
+type DeleteBuilder struct {
+	returning
+	keyspace string
+	alias    string
+	keys     expression.Expression
+	where    expression.Expression
+	limit    expression.Expression
+}
+
+func Delete(keyspace string) *DeleteBuilder {
+	return &DeleteBuilder{keyspace: keyspace}
+}
+
+func (this *DeleteBuilder) As(alias string) *DeleteBuilder {
+	this.alias = alias
+	return this
+}
+
+func (this *DeleteBuilder) UseKeys(keys expression.Expression) *DeleteBuilder {
+	this.keys = keys
+	return this
+}
+
+func (this *DeleteBuilder) Where(cond expression.Expression) *DeleteBuilder {
+	this.where = cond
+	return this
+}
+
+func (this *DeleteBuilder) Limit(limit expression.Expression) *DeleteBuilder {
+	this.limit = limit
+	return this
+}
+
+func (this *DeleteBuilder) Returning(terms ...*algebra.ResultTerm) *DeleteBuilder {
+	this.terms = append(this.terms, terms...)
+	return this
+}
+
+func (this *DeleteBuilder) ReturningRaw(expr expression.Expression) *DeleteBuilder {
+	this.raw = expr
+	return this
+}
+
+func (this *DeleteBuilder) Build() (*algebra.Delete, error) {
+	stmt, err := build(algebra.NewDelete(keyspaceRef(this.keyspace, this.alias), this.keys, nil, nil,
+		this.where, this.limit, this.projection()))
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.(*algebra.Delete), nil
+}
+
+/*
+UpdateBuilder builds an UPDATE statement.
+*/
+type UpdateBuilder struct {
+	returning
+	keyspace string
+	alias    string
+	keys     expression.Expression
+	set      algebra.SetTerms
+	unset    algebra.UnsetTerms
+	where    expression.Expression
+	limit    expression.Expression
+}
+
+func Update(keyspace string) *UpdateBuilder {
+	return &UpdateBuilder{keyspace: keyspace}
+}
+
+func (this *UpdateBuilder) As(alias string) *UpdateBuilder {
+	this.alias = alias
+	return this
+}
+
+func (this *UpdateBuilder) UseKeys(keys expression.Expression) *UpdateBuilder {
+	this.keys = keys
+	return this
+}
+
+// SET path = value.
+func (this *UpdateBuilder) Set(path expression.Path, value expression.Expression) *UpdateBuilder {
+	this.set = append(this.set, algebra.NewSetTerm(path, value, nil))
+	return this
+}
+
+// UNSET path.
+func (this *UpdateBuilder) Unset(path expression.Path) *UpdateBuilder {
+	this.unset = append(this.unset, algebra.NewUnsetTerm(path, nil))
+	return this
+}
+
+func (this *UpdateBuilder) Where(cond expression.Expression) *UpdateBuilder {
+	this.where = cond
+	return this
+}
+
+func (this *UpdateBuilder) Limit(limit expression.Expression) *UpdateBuilder {
+	this.limit = limit
+	return this
+}
+
+func (this *UpdateBuilder) Returning(terms ...*algebra.ResultTerm) *UpdateBuilder {
+	this.terms = append(this.terms, terms...)
+	return this
+}
+
+func (this *UpdateBuilder) ReturningRaw(expr expression.Expression) *UpdateBuilder {
+	this.raw = expr
+	return this
+}
+
+func (this *UpdateBuilder) Build() (*algebra.Update, error) {
+	var set *algebra.Set
+	if len(this.set) > 0 {
+		set = algebra.NewSet(this.set)
+	}
+
+	var unset *algebra.Unset
+	if len(this.unset) > 0 {
+		unset = algebra.NewUnset(this.unset)
+	}
+
+	stmt, err := build(algebra.NewUpdate(keyspaceRef(this.keyspace, this.alias), this.keys, nil, nil,
+		set, unset, this.where, this.limit, this.projection()))
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt.(*algebra.Update), nil
+}
+
+/*
+InsertBuilder builds an INSERT or UPSERT statement, of either VALUES
+or the results of a query.
+*/
+type InsertBuilder struct {
+	returning
+	upsert   bool
+	keyspace string
+	values   algebra.Pairs
+	key      expression.Expression
+	value    expression.Expression
+	query    *SelectBuilder
+}
+
+func Insert(keyspace string) *InsertBuilder {
+	return &InsertBuilder{keyspace: keyspace}
+}
+
+func Upsert(keyspace string) *InsertBuilder {
+	return &InsertBuilder{keyspace: keyspace, upsert: true}
+}
+
+// VALUES (key, value); may be repeated.
+func (this *InsertBuilder) Values(key, value expression.Expression) *InsertBuilder {
+	this.values = append(this.values, &algebra.Pair{Key: key, Value: value})
+	return this
+}
+
+/*
+(KEY key, VALUE value) query. The value is optional; without it, the
+results of the query are inserted.
+*/
+func (this *InsertBuilder) Select(key, value expression.Expression, query *SelectBuilder) *InsertBuilder {
+	this.key = key
+	this.value = value
+	this.query = query
+	return this
+}
+
+func (this *InsertBuilder) Returning(terms ...*algebra.ResultTerm) *InsertBuilder {
+	this.terms = append(this.terms, terms...)
+	return this
+}
+
+func (this *InsertBuilder) ReturningRaw(expr expression.Expression) *InsertBuilder {
+	this.raw = expr
+	return this
+}
+
+/*
+Build the statement, an *algebra.Insert or an *algebra.Upsert.
+*/
+func (this *InsertBuilder) Build() (algebra.Statement, error) {
+	ref := keyspaceRef(this.keyspace, "")
+	var stmt algebra.Statement
+	switch {
+	case this.query != nil && this.upsert:
+		stmt = algebra.NewUpsertSelect(ref, this.key, this.value, this.query.Query(), this.projection())
+	case this.query != nil:
+		stmt = algebra.NewInsertSelect(ref, this.key, this.value, this.query.Query(), this.projection())
+	case this.upsert:
+		stmt = algebra.NewUpsertValues(ref, this.values, this.projection())
+	default:
+		stmt = algebra.NewInsertValues(ref, this.values, this.projection())
+	}
+
+	return build(stmt)
+}
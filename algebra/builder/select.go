@@ -0,0 +1,244 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package builder
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+SelectBuilder builds a SELECT statement. The FROM clause is a
+keyspace followed by any joins, nests and unnests, and As() and
+UseKeys() apply to the term added last.
+*/
+type SelectBuilder struct {
+	distinct bool
+	raw      expression.Expression
+	terms    algebra.ResultTerms
+	from     []*fromItem
+	let      expression.Bindings
+	where    expression.Expression
+	groupBy  expression.Expressions
+	letting  expression.Bindings
+	having   expression.Expression
+	order    algebra.SortTerms
+	offset   expression.Expression
+	limit    expression.Expression
+}
+
+// SELECT of the result terms.
+func Select(terms ...*algebra.ResultTerm) *SelectBuilder {
+	return &SelectBuilder{terms: terms}
+}
+
+// SELECT RAW of an expression.
+func SelectRaw(expr expression.Expression) *SelectBuilder {
+	return &SelectBuilder{raw: expr}
+}
+
+func (this *SelectBuilder) Distinct() *SelectBuilder {
+	this.distinct = true
+	return this
+}
+
+func (this *SelectBuilder) From(keyspace string) *SelectBuilder {
+	this.from = []*fromItem{{kind: _KEYSPACE, keyspace: keyspace}}
+	return this
+}
+
+/*
+The alias of the keyspace, join, nest or unnest added last.
+*/
+func (this *SelectBuilder) As(alias string) *SelectBuilder {
+	if n := len(this.from); n > 0 {
+		this.from[n-1].alias = alias
+	}
+
+	return this
+}
+
+/*
+The keys of the keyspace added last, or the ON KEYS of the join or
+nest added last.
+*/
+func (this *SelectBuilder) UseKeys(keys expression.Expression) *SelectBuilder {
+	if n := len(this.from); n > 0 {
+		this.from[n-1].expr = keys
+	}
+
+	return this
+}
+
+// JOIN keyspace ON KEYS keys.
+func (this *SelectBuilder) Join(keyspace string, keys expression.Expression) *SelectBuilder {
+	return this.addFrom(_JOIN, false, keyspace, keys)
+}
+
+// LEFT OUTER JOIN keyspace ON KEYS keys.
+func (this *SelectBuilder) LeftJoin(keyspace string, keys expression.Expression) *SelectBuilder {
+	return this.addFrom(_JOIN, true, keyspace, keys)
+}
+
+// NEST keyspace ON KEYS keys.
+func (this *SelectBuilder) Nest(keyspace string, keys expression.Expression) *SelectBuilder {
+	return this.addFrom(_NEST, false, keyspace, keys)
+}
+
+// LEFT OUTER NEST keyspace ON KEYS keys.
+func (this *SelectBuilder) LeftNest(keyspace string, keys expression.Expression) *SelectBuilder {
+	return this.addFrom(_NEST, true, keyspace, keys)
+}
+
+// UNNEST expr; its alias is set by As().
+func (this *SelectBuilder) Unnest(expr expression.Expression) *SelectBuilder {
+	return this.addFrom(_UNNEST, false, "", expr)
+}
+
+// LEFT OUTER UNNEST expr; its alias is set by As().
+func (this *SelectBuilder) LeftUnnest(expr expression.Expression) *SelectBuilder {
+	return this.addFrom(_UNNEST, true, "", expr)
+}
+
+func (this *SelectBuilder) Let(variable string, expr expression.Expression) *SelectBuilder {
+	this.let = append(this.let, expression.NewBinding(variable, expr))
+	return this
+}
+
+func (this *SelectBuilder) Where(cond expression.Expression) *SelectBuilder {
+	this.where = cond
+	return this
+}
+
+func (this *SelectBuilder) GroupBy(exprs ...expression.Expression) *SelectBuilder {
+	this.groupBy = append(this.groupBy, exprs...)
+	return this
+}
+
+func (this *SelectBuilder) Letting(variable string, expr expression.Expression) *SelectBuilder {
+	this.letting = append(this.letting, expression.NewBinding(variable, expr))
+	return this
+}
+
+func (this *SelectBuilder) Having(cond expression.Expression) *SelectBuilder {
+	this.having = cond
+	return this
+}
+
+func (this *SelectBuilder) OrderBy(terms ...*algebra.SortTerm) *SelectBuilder {
+	this.order = append(this.order, terms...)
+	return this
+}
+
+func (this *SelectBuilder) Offset(offset expression.Expression) *SelectBuilder {
+	this.offset = offset
+	return this
+}
+
+func (this *SelectBuilder) Limit(limit expression.Expression) *SelectBuilder {
+	this.limit = limit
+	return this
+}
+
+/*
+Build the statement, formalized and ready to be planned. It is built
+afresh by each call.
+*/
+func (this *SelectBuilder) Build() (*algebra.Select, error) {
+	stmt := this.build()
+	err := stmt.Formalize()
+	if err != nil {
+		return nil, err
+	}
+
+	return stmt, nil
+}
+
+/*
+Build the statement without formalizing it, for use as a subquery or
+as the source of an INSERT or UPSERT, which are formalized with their
+enclosing statement.
+*/
+func (this *SelectBuilder) Query() *algebra.Select {
+	return this.build()
+}
+
+func (this *SelectBuilder) build() *algebra.Select {
+	var projection *algebra.Projection
+	if this.raw != nil {
+		projection = algebra.NewRawProjection(this.distinct, this.raw, "")
+	} else {
+		projection = algebra.NewProjection(this.distinct, this.terms)
+	}
+
+	var group *algebra.Group
+	if len(this.groupBy) > 0 || this.letting != nil || this.having != nil {
+		group = algebra.NewGroup(this.groupBy, nil, this.letting, this.having)
+	}
+
+	var order *algebra.Order
+	if len(this.order) > 0 {
+		order = algebra.NewOrder(this.order)
+	}
+
+	subselect := algebra.NewSubselect(this.buildFrom(), this.let, this.where, group, projection)
+	return algebra.NewSelect(subselect, order, this.offset, this.limit)
+}
+
+type fromKind int
+
+const (
+	_KEYSPACE fromKind = iota
+	_JOIN
+	_NEST
+	_UNNEST
+)
+
+/*
+A term of the FROM clause, kept until the statement is built so that
+As() and UseKeys() can amend it. The expression is the keys of a
+keyspace, join or nest, or the path of an unnest.
+*/
+type fromItem struct {
+	kind     fromKind
+	outer    bool
+	keyspace string
+	alias    string
+	expr     expression.Expression
+}
+
+func (this *SelectBuilder) addFrom(kind fromKind, outer bool, keyspace string,
+	expr expression.Expression) *SelectBuilder {
+	this.from = append(this.from, &fromItem{kind: kind, outer: outer, keyspace: keyspace, expr: expr})
+	return this
+}
+
+func (this *SelectBuilder) buildFrom() algebra.FromTerm {
+	var from algebra.FromTerm
+	for _, item := range this.from {
+		if item.kind == _UNNEST {
+			from = algebra.NewUnnest(from, item.outer, item.expr, item.alias)
+			continue
+		}
+
+		namespace, name := splitKeyspace(item.keyspace)
+		term := algebra.NewKeyspaceTerm(namespace, name, nil, item.alias, item.expr, nil)
+		switch item.kind {
+		case _JOIN:
+			from = algebra.NewJoin(from, item.outer, term)
+		case _NEST:
+			from = algebra.NewNest(from, item.outer, term)
+		default:
+			from = term
+		}
+	}
+
+	return from
+}
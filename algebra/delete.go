@@ -29,6 +29,7 @@ type Delete struct {
 
 	keyspace  *KeyspaceRef          `json:"keyspace"`
 	keys      expression.Expression `json:"keys"`
+	keyRange  *KeyRange             `json:"key_range"`
 	indexes   IndexRefs             `json:"indexes"`
 	where     expression.Expression `json:"where"`
 	limit     expression.Expression `json:"limit"`
@@ -40,11 +41,12 @@ The function NewDelete returns a pointer to the Delete
 struct by assigning the input attributes to the fields
 of the struct
 */
-func NewDelete(keyspace *KeyspaceRef, keys expression.Expression, indexes IndexRefs,
+func NewDelete(keyspace *KeyspaceRef, keys expression.Expression, keyRange *KeyRange, indexes IndexRefs,
 	where, limit expression.Expression, returning *Projection) *Delete {
 	rv := &Delete{
 		keyspace:  keyspace,
 		keys:      keys,
+		keyRange:  keyRange,
 		indexes:   indexes,
 		where:     where,
 		limit:     limit,
@@ -86,6 +88,13 @@ func (this *Delete) MapExpressions(mapper expression.Mapper) (err error) {
 		}
 	}
 
+	if this.keyRange != nil {
+		err = this.keyRange.MapExpressions(mapper)
+		if err != nil {
+			return err
+		}
+	}
+
 	if this.where != nil {
 		this.where, err = mapper.Map(this.where)
 		if err != nil {
@@ -117,6 +126,10 @@ func (this *Delete) Expressions() expression.Expressions {
 		exprs = append(exprs, this.keys)
 	}
 
+	if this.keyRange != nil {
+		exprs = append(exprs, this.keyRange.Expressions()...)
+	}
+
 	if this.where != nil {
 		exprs = append(exprs, this.where)
 	}
@@ -166,6 +179,13 @@ func (this *Delete) Formalize() (err error) {
 		}
 	}
 
+	if this.keyRange != nil {
+		err = this.keyRange.Formalize()
+		if err != nil {
+			return
+		}
+	}
+
 	if this.where != nil {
 		this.where, err = f.Map(this.where)
 		if err != nil {
@@ -202,6 +222,14 @@ func (this *Delete) Keys() expression.Expression {
 	return this.keys
 }
 
+/*
+Returns the key range defined by the use keys between
+clause, if any.
+*/
+func (this *Delete) KeyRange() *KeyRange {
+	return this.keyRange
+}
+
 /*
 Returns the indexes defined by the use index clause.
 */
@@ -16,6 +16,16 @@ import (
 	"github.com/couchbase/query/value"
 )
 
+/*
+Output formats of EXPLAIN: the plan as JSON, as a Graphviz DOT graph,
+or as nested JSON for flame graphs.
+*/
+const (
+	EXPLAIN_JSON  = "json"
+	EXPLAIN_DOT   = "dot"
+	EXPLAIN_FLAME = "flame"
+)
+
 /*
 Represents the explain text for a query. Type Explain is
 a struct that represents the explain json statement.
@@ -23,16 +33,22 @@ a struct that represents the explain json statement.
 type Explain struct {
 	statementBase
 
-	stmt Statement `json:"stmt"`
+	stmt    Statement   `json:"stmt"`
+	indexes []Statement `json:"indexes"`
+	format  string      `json:"format"`
 }
 
 /*
 The function NewExplain returns a pointer to the Explain
 struct that has its field stmt set to the input Statement.
+The indexes, if any, are CREATE INDEX statements declaring
+virtual indexes, which exist only for planning.
 */
-func NewExplain(stmt Statement) *Explain {
+func NewExplain(stmt Statement, indexes []Statement, format string) *Explain {
 	rv := &Explain{
-		stmt: stmt,
+		stmt:    stmt,
+		indexes: indexes,
+		format:  format,
 	}
 
 	rv.statementBase.stmt = rv
@@ -89,3 +105,17 @@ Return the explain statement.
 func (this *Explain) Statement() Statement {
 	return this.stmt
 }
+
+/*
+Return the virtual indexes.
+*/
+func (this *Explain) Indexes() []Statement {
+	return this.indexes
+}
+
+/*
+Return the output format.
+*/
+func (this *Explain) Format() string {
+	return this.format
+}
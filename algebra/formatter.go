@@ -0,0 +1,673 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+Formatter reprints statements as canonical N1QL text, with each
+clause on its own line. Joins and subqueries in the FROM clause are
+indented by the given number of spaces per level, and keywords are
+written in upper or lower case. Expressions are printed by the
+expression Stringer, so formatting a statement twice yields the
+same text.
+*/
+type Formatter struct {
+	indent string
+	upper  bool
+	depth  int
+}
+
+func NewFormatter(indent int, upper bool) *Formatter {
+	if indent < 0 {
+		indent = 0
+	}
+
+	return &Formatter{
+		indent: strings.Repeat(" ", indent),
+		upper:  upper,
+	}
+}
+
+/*
+Return the formatted text of the statement.
+*/
+func (this *Formatter) Format(stmt Statement) (string, error) {
+	s, err := stmt.Accept(this)
+	if err != nil {
+		return "", err
+	}
+
+	if this.upper {
+		return upperKeywords(s.(string)), nil
+	}
+
+	return s.(string), nil
+}
+
+func (this *Formatter) VisitSelect(stmt *Select) (interface{}, error) {
+	s, err := stmt.Subresult().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	lines := []string{s.(string)}
+
+	if stmt.Order() != nil {
+		lines = this.clause(lines, "order by", stmt.Order().Terms().String())
+	}
+
+	if stmt.Limit() != nil {
+		lines = this.clause(lines, "limit", stmt.Limit().String())
+	}
+
+	if stmt.Offset() != nil {
+		lines = this.clause(lines, "offset", stmt.Offset().String())
+	}
+
+	if stmt.Outfile() != nil {
+		lines = append(lines, this.prefix()+stmt.Outfile().String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) VisitInsert(stmt *Insert) (interface{}, error) {
+	return this.visitInsert("insert", stmt.KeyspaceRef(), stmt.Key(), stmt.Value(),
+		stmt.Values(), stmt.Select(), stmt.Returning())
+}
+
+func (this *Formatter) VisitUpsert(stmt *Upsert) (interface{}, error) {
+	return this.visitInsert("upsert", stmt.KeyspaceRef(), stmt.Key(), stmt.Value(),
+		stmt.Values(), stmt.Select(), stmt.Returning())
+}
+
+func (this *Formatter) VisitImport(stmt *Import) (interface{}, error) {
+	lines := this.clause(nil, "import into", formatKeyspaceRef(stmt.KeyspaceRef()))
+
+	if stmt.Key() != nil {
+		s := "(key " + stmt.Key().String()
+		if stmt.Value() != nil {
+			s += ", value " + stmt.Value().String()
+		}
+
+		lines[0] += " " + s + ")"
+	}
+
+	lines = append(lines, this.prefix()+stmt.Options())
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) visitInsert(verb string, ksref *KeyspaceRef, key, val expression.Expression,
+	values Pairs, sel *Select, returning *Projection) (interface{}, error) {
+	lines := this.clause(nil, verb+" into", formatKeyspaceRef(ksref))
+
+	if sel != nil {
+		s := "(key " + key.String()
+		if val != nil {
+			s += ", value " + val.String()
+		}
+
+		lines[0] += " " + s + ")"
+
+		s, err := this.visitNested(sel)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = append(lines, s)
+	} else {
+		for i, pair := range values {
+			s := "(" + pair.Key.String() + ", " + pair.Value.String() + ")"
+			if i == 0 {
+				lines = this.clause(lines, "values", s)
+			} else {
+				lines[len(lines)-1] += ","
+				lines = append(lines, this.prefix()+this.indent+s)
+			}
+		}
+	}
+
+	if returning != nil {
+		lines = this.clause(lines, "returning", returning.String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) VisitDelete(stmt *Delete) (interface{}, error) {
+	lines := this.clause(nil, "delete from", formatKeyspaceRef(stmt.KeyspaceRef()))
+	lines = this.use(lines, stmt.Keys(), stmt.KeyRange(), stmt.Indexes())
+
+	if stmt.Where() != nil {
+		lines = this.clause(lines, "where", stmt.Where().String())
+	}
+
+	if stmt.Limit() != nil {
+		lines = this.clause(lines, "limit", stmt.Limit().String())
+	}
+
+	if stmt.Returning() != nil {
+		lines = this.clause(lines, "returning", stmt.Returning().String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) VisitUpdate(stmt *Update) (interface{}, error) {
+	lines := this.clause(nil, "update", formatKeyspaceRef(stmt.KeyspaceRef()))
+	lines = this.use(lines, stmt.Keys(), stmt.KeyRange(), stmt.Indexes())
+
+	if stmt.Set() != nil {
+		terms := make([]string, len(stmt.Set().Terms()))
+		for i, term := range stmt.Set().Terms() {
+			terms[i] = formatPath(term.Path()) + " = " + term.Value().String() +
+				formatUpdateFor(term.UpdateFor())
+		}
+
+		lines = this.clause(lines, "set", strings.Join(terms, ", "))
+	}
+
+	if stmt.Unset() != nil {
+		terms := make([]string, len(stmt.Unset().Terms()))
+		for i, term := range stmt.Unset().Terms() {
+			terms[i] = formatPath(term.Path()) + formatUpdateFor(term.UpdateFor())
+		}
+
+		lines = this.clause(lines, "unset", strings.Join(terms, ", "))
+	}
+
+	if stmt.Where() != nil {
+		lines = this.clause(lines, "where", stmt.Where().String())
+	}
+
+	if stmt.Limit() != nil {
+		lines = this.clause(lines, "limit", stmt.Limit().String())
+	}
+
+	if stmt.Returning() != nil {
+		lines = this.clause(lines, "returning", stmt.Returning().String())
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) VisitMerge(stmt *Merge) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of MERGE")
+}
+
+func (this *Formatter) VisitCreatePrimaryIndex(stmt *CreatePrimaryIndex) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE PRIMARY INDEX")
+}
+
+func (this *Formatter) VisitCreateIndex(stmt *CreateIndex) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE INDEX")
+}
+
+func (this *Formatter) VisitDropIndex(stmt *DropIndex) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of DROP INDEX")
+}
+
+func (this *Formatter) VisitAlterIndex(stmt *AlterIndex) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of ALTER INDEX")
+}
+
+func (this *Formatter) VisitBuildIndexes(stmt *BuildIndexes) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of BUILD INDEX")
+}
+
+func (this *Formatter) VisitCreatePolicy(stmt *CreatePolicy) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE POLICY")
+}
+
+func (this *Formatter) VisitDropPolicy(stmt *DropPolicy) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of DROP POLICY")
+}
+
+func (this *Formatter) VisitCreateMask(stmt *CreateMask) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE MASK")
+}
+
+func (this *Formatter) VisitDropMask(stmt *DropMask) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of DROP MASK")
+}
+
+func (this *Formatter) VisitCreateView(stmt *CreateView) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE VIEW")
+}
+
+func (this *Formatter) VisitDropView(stmt *DropView) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of DROP VIEW")
+}
+
+func (this *Formatter) VisitRefreshView(stmt *RefreshView) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of REFRESH MATERIALIZED VIEW")
+}
+
+func (this *Formatter) VisitCreateKeyspace(stmt *CreateKeyspace) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of CREATE TEMP KEYSPACE")
+}
+
+func (this *Formatter) VisitDropKeyspace(stmt *DropKeyspace) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of DROP KEYSPACE")
+}
+
+func (this *Formatter) VisitGrantRole(stmt *GrantRole) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of GRANT")
+}
+
+func (this *Formatter) VisitRevokeRole(stmt *RevokeRole) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of REVOKE")
+}
+
+func (this *Formatter) VisitSessionSet(stmt *SessionSet) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of SET")
+}
+
+func (this *Formatter) VisitSetVariable(stmt *SetVariable) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of SET")
+}
+
+func (this *Formatter) VisitExplain(stmt *Explain) (interface{}, error) {
+	if len(stmt.Indexes()) > 0 {
+		return nil, errors.NewNotImplemented("formatting of EXPLAIN WITH indexes")
+	}
+
+	s, err := stmt.Statement().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Format() != EXPLAIN_JSON {
+		return "explain with {\"format\": \"" + stmt.Format() + "\"}\n" + s.(string), nil
+	}
+
+	return "explain " + s.(string), nil
+}
+
+func (this *Formatter) VisitAdvise(stmt *Advise) (interface{}, error) {
+	s, err := stmt.Statement().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	return "advise " + s.(string), nil
+}
+
+func (this *Formatter) VisitPrepare(stmt *Prepare) (interface{}, error) {
+	s, err := stmt.Statement().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	if stmt.Name() != "" {
+		return "prepare `" + stmt.Name() + "` as\n" + s.(string), nil
+	}
+
+	return "prepare " + s.(string), nil
+}
+
+func (this *Formatter) VisitExecute(stmt *Execute) (interface{}, error) {
+	return nil, errors.NewNotImplemented("formatting of EXECUTE")
+}
+
+func (this *Formatter) VisitSelectTerm(node *SelectTerm) (interface{}, error) {
+	return this.visitNested(node.Select())
+}
+
+func (this *Formatter) VisitSubselect(node *Subselect) (interface{}, error) {
+	lines := this.clause(nil, "select", node.Projection().String())
+
+	if node.From() != nil {
+		s, err := node.From().Accept(this)
+		if err != nil {
+			return nil, err
+		}
+
+		lines = this.clause(lines, "from", s.(string))
+	}
+
+	if node.Let() != nil {
+		lines = this.clause(lines, "let", stringBindings(node.Let()))
+	}
+
+	if node.Where() != nil {
+		lines = this.clause(lines, "where", node.Where().String())
+	}
+
+	if group := node.Group(); group != nil {
+		if group.By() != nil {
+			s := group.By()[0].String()
+			for _, by := range group.By()[1:] {
+				s += ", " + by.String()
+			}
+
+			if group.Limit() != nil {
+				s += " each limit " + group.Limit().String()
+			}
+
+			lines = this.clause(lines, "group by", s)
+		}
+
+		if group.Letting() != nil {
+			lines = this.clause(lines, "letting", stringBindings(group.Letting()))
+		}
+
+		if group.Having() != nil {
+			lines = this.clause(lines, "having", group.Having().String())
+		}
+	}
+
+	return strings.Join(lines, "\n"), nil
+}
+
+func (this *Formatter) VisitKeyspaceTerm(node *KeyspaceTerm) (interface{}, error) {
+	s := node.String() + formatIndexRefs(node.Indexes())
+	if node.Consistency() != nil {
+		s += " " + node.Consistency().String()
+	}
+
+	return s, nil
+}
+
+func (this *Formatter) VisitSubqueryTerm(node *SubqueryTerm) (interface{}, error) {
+	s, err := this.visitNested(node.Subquery())
+	if err != nil {
+		return nil, err
+	}
+
+	return "(\n" + s + "\n" + this.prefix() + ") as `" + node.Alias() + "`", nil
+}
+
+func (this *Formatter) VisitJoin(node *Join) (interface{}, error) {
+	return this.visitJoin(node.Left(), node.Outer(), "join", node.Right().toString(true))
+}
+
+func (this *Formatter) VisitNest(node *Nest) (interface{}, error) {
+	return this.visitJoin(node.Left(), node.Outer(), "nest", node.Right().toString(true))
+}
+
+func (this *Formatter) VisitUnnest(node *Unnest) (interface{}, error) {
+	s := node.Expression().String()
+	if node.As() != "" {
+		s += " as `" + node.As() + "`"
+	}
+
+	return this.visitJoin(node.Left(), node.Outer(), "unnest", s)
+}
+
+func (this *Formatter) visitJoin(left FromTerm, outer bool, op, right string) (interface{}, error) {
+	s, err := left.Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	if outer {
+		op = "left outer " + op
+	}
+
+	return s.(string) + "\n" + this.prefix() + this.indent + op + " " + right, nil
+}
+
+func (this *Formatter) VisitUnion(node *Union) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "union")
+}
+
+func (this *Formatter) VisitUnionAll(node *UnionAll) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "union all")
+}
+
+func (this *Formatter) VisitIntersect(node *Intersect) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "intersect")
+}
+
+func (this *Formatter) VisitIntersectAll(node *IntersectAll) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "intersect all")
+}
+
+func (this *Formatter) VisitExcept(node *Except) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "except")
+}
+
+func (this *Formatter) VisitExceptAll(node *ExceptAll) (interface{}, error) {
+	return this.visitSetOp(&node.setOp, "except all")
+}
+
+func (this *Formatter) visitSetOp(node *setOp, op string) (interface{}, error) {
+	first, err := node.First().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	second, err := node.Second().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	return first.(string) + "\n" + this.prefix() + op + "\n" + second.(string), nil
+}
+
+/*
+Format a subquery one level deeper than the enclosing statement.
+*/
+func (this *Formatter) visitNested(sel *Select) (string, error) {
+	this.depth++
+	defer func() { this.depth-- }()
+
+	s, err := sel.Accept(this)
+	if err != nil {
+		return "", err
+	}
+
+	return s.(string), nil
+}
+
+func (this *Formatter) prefix() string {
+	return strings.Repeat(this.indent, this.depth)
+}
+
+func (this *Formatter) clause(lines []string, keyword, text string) []string {
+	return append(lines, this.prefix()+keyword+" "+text)
+}
+
+func (this *Formatter) use(lines []string, keys expression.Expression, keyRange *KeyRange,
+	indexes IndexRefs) []string {
+	if keys != nil {
+		lines = this.clause(lines, "use keys", keys.String())
+	} else if keyRange != nil {
+		lines = this.clause(lines, "use keys between",
+			keyRange.Low().String()+" and "+keyRange.High().String())
+	} else if indexes != nil {
+		lines = append(lines, this.prefix()+strings.TrimPrefix(formatIndexRefs(indexes), " "))
+	}
+
+	return lines
+}
+
+func formatKeyspaceRef(ksref *KeyspaceRef) string {
+	s := ""
+	if ksref.Namespace() != "" {
+		s += "`" + ksref.Namespace() + "`:"
+	}
+
+	s += keyspaceString(ksref.Keyspace())
+	if ksref.As() != "" {
+		s += " as `" + ksref.As() + "`"
+	}
+
+	return s
+}
+
+func formatIndexRefs(indexes IndexRefs) string {
+	if indexes == nil {
+		return ""
+	}
+
+	refs := make([]string, len(indexes))
+	for i, index := range indexes {
+		refs[i] = "`" + index.Name() + "`"
+		if index.Using() != datastore.DEFAULT {
+			refs[i] += " using " + strings.ToLower(string(index.Using()))
+		}
+	}
+
+	return " use index (" + strings.Join(refs, ", ") + ")"
+}
+
+/*
+Paths are printed without the parentheses of the Stringer, which
+the grammar does not accept in SET, UNSET and FOR terms.
+*/
+func formatPath(path expression.Expression) string {
+	switch path := path.(type) {
+	case *expression.Field:
+		if name, ok := path.Second().(*expression.FieldName); ok {
+			s := formatPath(path.First()) + ".`" + name.Alias() + "`"
+			if name.CaseInsensitive() {
+				s += "i"
+			}
+
+			return s
+		}
+
+		return formatPath(path.First()) + ".[" + path.Second().String() + "]"
+	case *expression.Element:
+		return formatPath(path.First()) + "[" + path.Second().String() + "]"
+	default:
+		return path.String()
+	}
+}
+
+func formatUpdateFor(updateFor *UpdateFor) string {
+	if updateFor == nil {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString(" for ")
+	for i, b := range updateFor.Bindings() {
+		if i > 0 {
+			buf.WriteString(", ")
+		}
+
+		buf.WriteString("`" + b.Variable() + "`")
+		if b.Descend() {
+			buf.WriteString(" within ")
+		} else {
+			buf.WriteString(" in ")
+		}
+
+		buf.WriteString(formatPath(b.Expression()))
+	}
+
+	if updateFor.When() != nil {
+		buf.WriteString(" when " + updateFor.When().String())
+	}
+
+	buf.WriteString(" end")
+	return buf.String()
+}
+
+/*
+Upper-case the keywords of formatted text, leaving quoted strings,
+escaped identifiers and parameter names as they are.
+*/
+func upperKeywords(text string) string {
+	buf := make([]byte, 0, len(text))
+	for i := 0; i < len(text); {
+		c := text[i]
+		switch {
+		case c == '"' || c == '`':
+			j := i + 1
+			for j < len(text) && text[j] != c {
+				if c == '"' && text[j] == '\\' {
+					j++
+				}
+				j++
+			}
+
+			if j < len(text) {
+				j++
+			}
+
+			buf = append(buf, text[i:j]...)
+			i = j
+		case isWordByte(c) && !(c >= '0' && c <= '9'):
+			j := i + 1
+			for j < len(text) && isWordByte(text[j]) {
+				j++
+			}
+
+			word := text[i:j]
+			if _KEYWORDS[word] && (i == 0 || text[i-1] != '$') {
+				word = strings.ToUpper(word)
+			}
+
+			buf = append(buf, word...)
+			i = j
+		case isWordByte(c):
+			j := i + 1
+			for j < len(text) && isWordByte(text[j]) {
+				j++
+			}
+
+			buf = append(buf, text[i:j]...)
+			i = j
+		default:
+			buf = append(buf, c)
+			i++
+		}
+	}
+
+	return string(buf)
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+var _KEYWORDS = map[string]bool{}
+
+func init() {
+	for _, k := range strings.Fields(_KEYWORD_LIST + _CONTEXTUAL_KEYWORD_LIST) {
+		_KEYWORDS[strings.ToLower(k)] = true
+	}
+}
+
+const _KEYWORD_LIST = `
+ALL ALTER ANALYZE AND ANY ARRAY AS ASC BEGIN BETWEEN BINARY BOOLEAN BREAK
+BUCKET BUILD BY CALL CASE CAST CLUSTER COLLATE COLLECTION COMMIT CONNECT
+CONTINUE CORRELATE COVER CREATE DATABASE DATASET DATASTORE DECLARE
+DECREMENT DELETE DERIVED DESC DESCRIBE DISTINCT DO DROP EACH ELEMENT ELSE
+END EVERY EXCEPT EXCLUDE EXECUTE EXISTS EXPLAIN FALSE FETCH FIRST FLATTEN
+FOR FORCE FROM FUNCTION GRANT GROUP GSI HAVING IF IGNORE ILIKE IN INCLUDE
+INCREMENT INDEX INLINE INNER INSERT INTERSECT INTO IS JOIN KEY KEYS
+KEYSPACE LAST LEFT LET LETTING LIKE LIMIT LSM MAP MAPPING MATCHED
+MATERIALIZED MERGE MINUS MISSING NAMESPACE NEST NOT NULL NUMBER OBJECT
+OFFSET ON OPTION OR ORDER OUTER OVER PARSE PARTITION PASSWORD PATH POOL
+PREPARE PRIMARY PRIVATE PRIVILEGE PROCEDURE PUBLIC RAW REALM REDUCE
+RENAME RETURN RETURNING REVOKE RIGHT ROLE ROLLBACK SATISFIES SCHEMA SELECT
+SELF SET SHOW SOME START STATISTICS STRING SYSTEM THEN TO TRANSACTION
+TRIGGER TRUE TRUNCATE UNDER UNION UNIQUE UNNEST UNSET UPDATE UPSERT USE
+USER USING VALIDATE VALUE VALUED VALUES VIA VIEW WHEN WHERE WHILE WITH
+WITHIN WORK XOR
+`
+
+// Words that are keywords only where the grammar expects them
+const _CONTEXTUAL_KEYWORD_LIST = `
+ADVISE CONSISTENCY NOT_BOUNDED REQUEST_PLUS AT_PLUS
+`
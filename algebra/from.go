@@ -86,12 +86,13 @@ keyspace as strings, projection as the path, the alias
 string as, and the keys expression.
 */
 type KeyspaceTerm struct {
-	namespace  string
-	keyspace   string
-	projection expression.Path
-	as         string
-	keys       expression.Expression
-	indexes    IndexRefs
+	namespace   string
+	keyspace    string
+	projection  expression.Path
+	as          string
+	keys        expression.Expression
+	indexes     IndexRefs
+	consistency *Consistency
 }
 
 /*
@@ -100,7 +101,7 @@ struct by assigning the input attributes to the fields of the struct.
 */
 func NewKeyspaceTerm(namespace, keyspace string, projection expression.Path, as string,
 	keys expression.Expression, indexes IndexRefs) *KeyspaceTerm {
-	return &KeyspaceTerm{namespace, keyspace, projection, as, keys, indexes}
+	return &KeyspaceTerm{namespace, keyspace, projection, as, keys, indexes, nil}
 }
 
 /*
@@ -132,6 +133,10 @@ func (this *KeyspaceTerm) MapExpressions(mapper expression.Mapper) (err error) {
 		}
 	}
 
+	if this.consistency != nil {
+		err = this.consistency.MapExpressions(mapper)
+	}
+
 	return
 }
 
@@ -149,6 +154,10 @@ func (this *KeyspaceTerm) Expressions() expression.Expressions {
 		exprs = append(exprs, this.keys)
 	}
 
+	if this.consistency != nil {
+		exprs = append(exprs, this.consistency.Expressions()...)
+	}
+
 	return exprs
 }
 
@@ -178,7 +187,7 @@ func (this *KeyspaceTerm) toString(join bool) string {
 		s += "`" + this.namespace + "`:"
 	}
 
-	s += "`" + this.keyspace + "`"
+	s += keyspaceString(this.keyspace)
 
 	if this.projection != nil {
 		s += "." + this.projection.String()
@@ -217,6 +226,13 @@ func (this *KeyspaceTerm) Formalize(parent *expression.Formalizer) (f *expressio
 		}
 	}
 
+	if this.consistency != nil {
+		err = this.consistency.Formalize()
+		if err != nil {
+			return
+		}
+	}
+
 	_, ok := parent.Allowed.Field(keyspace)
 	if ok {
 		err = errors.NewDuplicateAliasError("subquery", keyspace, "plan.keyspace.duplicate_alias")
@@ -242,7 +258,8 @@ func (this *KeyspaceTerm) PrimaryTerm() FromTerm {
 /*
 Returns the Alias string. If as is not empty then return it.
 If it is not set, then check the path (projection) and return
-its alias, otherwise return the keyspace string.
+its alias, otherwise return the keyspace string, or the last
+name of a keyspace path.
 */
 func (this *KeyspaceTerm) Alias() string {
 	if this.as != "" {
@@ -250,7 +267,8 @@ func (this *KeyspaceTerm) Alias() string {
 	} else if this.projection != nil {
 		return this.projection.Alias()
 	} else {
-		return this.keyspace
+		_, _, keyspace := datastore.SplitKeyspacePath(this.keyspace)
+		return keyspace
 	}
 }
 
@@ -284,6 +302,40 @@ func (this *KeyspaceTerm) Projection() expression.Path {
 	return this.projection
 }
 
+/*
+Returns the scope and keyspace that a projection of two field names
+spells after the keyspace, which then names a bucket. Whether they
+name a keyspace path or a path within the documents of the bucket is
+decided by the namespace.
+*/
+func (this *KeyspaceTerm) KeyspacePath() (scope, keyspace string, ok bool) {
+	field, ok := this.projection.(*expression.Field)
+	if !ok {
+		return
+	}
+
+	first, ok := field.First().(*expression.Identifier)
+	if !ok {
+		return
+	}
+
+	second, ok := field.Second().(*expression.FieldName)
+	if !ok {
+		return
+	}
+
+	return first.Identifier(), second.Alias(), true
+}
+
+/*
+Names the keyspace by its keyspace path, which the projection
+spelled. The alias, the last name, is unchanged.
+*/
+func (this *KeyspaceTerm) SetKeyspacePath(scope, keyspace string) {
+	this.keyspace = datastore.KeyspacePath(this.keyspace, scope, keyspace)
+	this.projection = nil
+}
+
 /*
 Returns the alias.
 */
@@ -306,6 +358,21 @@ func (this *KeyspaceTerm) Indexes() IndexRefs {
 	return this.indexes
 }
 
+/*
+Returns the scan consistency defined by the use consistency
+clause, or nil to scan at the consistency of the request.
+*/
+func (this *KeyspaceTerm) Consistency() *Consistency {
+	return this.consistency
+}
+
+/*
+Set the scan consistency of the use consistency clause.
+*/
+func (this *KeyspaceTerm) SetConsistency(consistency *Consistency) {
+	this.consistency = consistency
+}
+
 /*
 Marshals the input keyspace into a byte array.
 */
@@ -20,10 +20,13 @@ letting clause and the having clause represented by
 expression bindings and expressions respectively.
 Aliases in the LETTING clause create new names that
 may be referred to in the HAVING, SELECT, and ORDER
-BY clauses. Having specifies a condition.
+BY clauses. Having specifies a condition. EACH LIMIT returns at
+most limit items of each group, instead of one, in no particular
+order; all the items of a group share its aggregates.
 */
 type Group struct {
 	by      expression.Expressions `json:by`
+	limit   expression.Expression  `json:"limit"`
 	letting expression.Bindings    `json:"letting"`
 	having  expression.Expression  `json:"having"`
 }
@@ -31,11 +34,14 @@ type Group struct {
 /*
 The function NewGroup returns a pointer to the Group
 struct that has its field sort terms set to the input
-argument expressions.
+argument expressions. The limit is nil unless EACH LIMIT
+is specified.
 */
-func NewGroup(by expression.Expressions, letting expression.Bindings, having expression.Expression) *Group {
+func NewGroup(by expression.Expressions, limit expression.Expression,
+	letting expression.Bindings, having expression.Expression) *Group {
 	return &Group{
 		by:      by,
+		limit:   limit,
 		letting: letting,
 		having:  having,
 	}
@@ -86,6 +92,13 @@ func (this *Group) MapExpressions(mapper expression.Mapper) (err error) {
 		}
 	}
 
+	if this.limit != nil {
+		this.limit, err = mapper.Map(this.limit)
+		if err != nil {
+			return
+		}
+	}
+
 	if this.letting != nil {
 		err = this.letting.MapExpressions(mapper)
 		if err != nil {
@@ -110,6 +123,10 @@ func (this *Group) Expressions() expression.Expressions {
 		exprs = append(exprs, this.by...)
 	}
 
+	if this.limit != nil {
+		exprs = append(exprs, this.limit)
+	}
+
 	if this.letting != nil {
 		exprs = append(exprs, this.letting.Expressions()...)
 	}
@@ -139,6 +156,10 @@ func (this *Group) String() string {
 		}
 	}
 
+	if this.limit != nil {
+		s += " each limit " + this.limit.String()
+	}
+
 	if this.letting != nil {
 		s += " letting " + stringBindings(this.letting)
 	}
@@ -157,6 +178,13 @@ func (this *Group) By() expression.Expressions {
 	return this.by
 }
 
+/*
+Returns the EACH LIMIT expression, or nil.
+*/
+func (this *Group) Limit() expression.Expression {
+	return this.limit
+}
+
 /*
 Returns the letting expression bindings.
 */
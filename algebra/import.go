@@ -0,0 +1,235 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+	"math"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+// Besides the OUTFILE formats, IMPORT reads zip files of JSON files.
+const IMPORT_ZIP = "zip"
+
+/*
+Represents the IMPORT statement, which upserts the rows of a file on
+the query server into a keyspace. The format is json, a JSON array of
+documents; jsonl, one document per line; csv, one document per row,
+whose fields are named by the header; or zip, one document per JSON
+file in the archive. If no format is given, it is that of the file
+extension, or else jsonl.
+
+The key and value expressions are evaluated against each row; by
+default, the key is a UUID, or the name of the JSON file in a zip, and
+the value is the row itself. Batch is the number of documents per
+upsert, and rows that cannot be read or keyed are written to the
+errors file, if any, instead of failing the statement.
+*/
+type Import struct {
+	statementBase
+
+	keyspace *KeyspaceRef          `json:"keyspace"`
+	key      expression.Expression `json:"key"`
+	value    expression.Expression `json:"value"`
+	path     string                `json:"path"`
+	format   string                `json:"format"`
+	batch    int                   `json:"batch"`
+	errors   string                `json:"errors"`
+}
+
+/*
+The options are an object of the FORMAT, BATCH and ERRORS clauses, by
+their lower-case names, or nil.
+*/
+func NewImport(keyspace *KeyspaceRef, key, val expression.Expression, path string,
+	options value.Value) (*Import, error) {
+	rv := &Import{
+		keyspace: keyspace,
+		key:      key,
+		value:    val,
+		path:     path,
+	}
+
+	if options != nil {
+		for name, _ := range options.Fields() {
+			option, _ := options.Field(name)
+			switch name {
+			case "format":
+				format, ok := option.Actual().(string)
+				if !ok {
+					return nil, fmt.Errorf("Invalid IMPORT format %v.", option)
+				}
+				rv.format = strings.ToLower(format)
+			case "batch":
+				batch, ok := option.Actual().(float64)
+				if !ok || batch < 1 || batch != math.Trunc(batch) {
+					return nil, fmt.Errorf("IMPORT batch must be a positive integer.")
+				}
+				rv.batch = int(batch)
+			case "errors":
+				errors, ok := option.Actual().(string)
+				if !ok || errors == "" {
+					return nil, fmt.Errorf("IMPORT errors must be a file name.")
+				}
+				rv.errors = errors
+			default:
+				return nil, fmt.Errorf("Invalid IMPORT clause %s; expected FORMAT, BATCH or ERRORS.",
+					strings.ToUpper(name))
+			}
+		}
+	}
+
+	if rv.format == "" {
+		rv.format = strings.ToLower(strings.TrimPrefix(filepath.Ext(path), "."))
+		switch rv.format {
+		case OUTFILE_JSON, OUTFILE_JSONL, OUTFILE_CSV, IMPORT_ZIP:
+		default:
+			rv.format = OUTFILE_JSONL
+		}
+	}
+
+	switch rv.format {
+	case OUTFILE_JSON, OUTFILE_JSONL, OUTFILE_CSV, IMPORT_ZIP:
+	default:
+		return nil, fmt.Errorf("Invalid IMPORT format %s; expected json, jsonl, csv or zip.", rv.format)
+	}
+
+	rv.stmt = rv
+	return rv, nil
+}
+
+/*
+It calls the VisitImport method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Import) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitImport(this)
+}
+
+/*
+IMPORT returns no results.
+*/
+func (this *Import) Signature() value.Value {
+	return nil
+}
+
+/*
+Applies mapper to the key and value expressions.
+*/
+func (this *Import) MapExpressions(mapper expression.Mapper) (err error) {
+	if this.key != nil {
+		this.key, err = mapper.Map(this.key)
+		if err != nil {
+			return
+		}
+	}
+
+	if this.value != nil {
+		this.value, err = mapper.Map(this.value)
+	}
+
+	return
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *Import) Expressions() expression.Expressions {
+	exprs := make(expression.Expressions, 0, 2)
+
+	if this.key != nil {
+		exprs = append(exprs, this.key)
+	}
+
+	if this.value != nil {
+		exprs = append(exprs, this.value)
+	}
+
+	return exprs
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *Import) Privileges() (datastore.Privileges, errors.Error) {
+	privs := datastore.NewPrivileges()
+	privs[this.keyspace.Namespace()+":"+this.keyspace.Keyspace()] = datastore.PRIV_WRITE
+
+	subprivs, err := subqueryPrivileges(this.Expressions())
+	if err != nil {
+		return nil, err
+	}
+
+	privs.Add(subprivs)
+	return privs, nil
+}
+
+/*
+The key and value expressions refer to the fields of the rows, as in
+UPSERT ... SELECT, so only the keyspace is formalized.
+*/
+func (this *Import) Formalize() (err error) {
+	_, err = this.keyspace.Formalize()
+	return
+}
+
+func (this *Import) KeyspaceRef() *KeyspaceRef {
+	return this.keyspace
+}
+
+func (this *Import) Key() expression.Expression {
+	return this.key
+}
+
+func (this *Import) Value() expression.Expression {
+	return this.value
+}
+
+// The path of the file, relative to the import directory of the server.
+func (this *Import) Path() string {
+	return this.path
+}
+
+func (this *Import) Format() string {
+	return this.format
+}
+
+// The number of documents per upsert; zero is the pipeline batch.
+func (this *Import) Batch() int {
+	return this.batch
+}
+
+// The path of the errors file, or empty.
+func (this *Import) Errors() string {
+	return this.errors
+}
+
+/*
+The FROM, FORMAT, BATCH and ERRORS clauses.
+*/
+func (this *Import) Options() string {
+	s := "from " + strconv.Quote(this.path) + " format " + this.format
+	if this.batch > 0 {
+		s += " batch " + strconv.Itoa(this.batch)
+	}
+
+	if this.errors != "" {
+		s += " errors " + strconv.Quote(this.errors)
+	}
+
+	return s
+}
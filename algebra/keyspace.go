@@ -12,6 +12,7 @@ package algebra
 import (
 	"encoding/json"
 
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/value"
@@ -89,13 +90,15 @@ func (this *KeyspaceRef) As() string {
 
 /*
 Returns the alias as the keyspace or the as string
-based on if as is empty.
+based on if as is empty. The alias of a keyspace path
+is its last name.
 */
 func (this *KeyspaceRef) Alias() string {
 	if this.as != "" {
 		return this.as
 	} else {
-		return this.keyspace
+		_, _, keyspace := datastore.SplitKeyspacePath(this.keyspace)
+		return keyspace
 	}
 }
 
@@ -109,3 +112,16 @@ func (this *KeyspaceRef) MarshalJSON() ([]byte, error) {
 	r["namespace"] = this.namespace
 	return json.Marshal(r)
 }
+
+/*
+Representation of a keyspace name, or of each name in a keyspace
+path, as a N1QL identifier.
+*/
+func keyspaceString(keyspace string) string {
+	bucket, scope, keyspace := datastore.SplitKeyspacePath(keyspace)
+	if bucket == "" {
+		return "`" + keyspace + "`"
+	}
+
+	return "`" + bucket + "`.`" + scope + "`.`" + keyspace + "`"
+}
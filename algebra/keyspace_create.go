@@ -0,0 +1,106 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Create temp keyspace ddl statement. A temporary
+keyspace belongs to the session of the request, or else to the
+request, and is dropped when it ends.
+*/
+type CreateKeyspace struct {
+	statementBase
+
+	keyspace *KeyspaceRef `json:"keyspace"`
+}
+
+/*
+The function NewCreateKeyspace returns a pointer to the
+CreateKeyspace struct with the input argument values as fields.
+*/
+func NewCreateKeyspace(keyspace *KeyspaceRef) *CreateKeyspace {
+	rv := &CreateKeyspace{
+		keyspace: keyspace,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreateKeyspace method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreateKeyspace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateKeyspace(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateKeyspace) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateKeyspace) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateKeyspace) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateKeyspace) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *CreateKeyspace) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	}, nil
+}
+
+/*
+Returns the name of the keyspace.
+*/
+func (this *CreateKeyspace) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreateKeyspace) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createKeyspace"}
+	r["keyspaceRef"] = this.keyspace
+	r["temp"] = true
+	return json.Marshal(r)
+}
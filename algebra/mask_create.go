@@ -0,0 +1,129 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Create mask ddl statement. Type CreateMask is
+a struct that contains fields mapping to each clause in the
+create mask statement, namely the keyspace, the masked path
+and the mask type of the USING clause.
+
+The path refers to the fields of the documents of the keyspace,
+like the keys of an index, and is not formalized.
+*/
+type CreateMask struct {
+	statementBase
+
+	keyspace *KeyspaceRef    `json:"keyspace"`
+	path     expression.Path `json:"path"`
+	maskType string          `json:"type"`
+}
+
+/*
+The function NewCreateMask returns a pointer to the
+CreateMask struct with the input argument values as fields.
+*/
+func NewCreateMask(keyspace *KeyspaceRef, path expression.Path, maskType string) *CreateMask {
+	rv := &CreateMask{
+		keyspace: keyspace,
+		path:     path,
+		maskType: maskType,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreateMask method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreateMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateMask(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateMask) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateMask) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil; the masked path is not mapped.
+*/
+func (this *CreateMask) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *CreateMask) Expressions() expression.Expressions {
+	return expression.Expressions{this.path}
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *CreateMask) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	}, nil
+}
+
+/*
+Returns the keyspace that the mask is created on.
+*/
+func (this *CreateMask) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the masked path.
+*/
+func (this *CreateMask) Path() expression.Path {
+	return this.path
+}
+
+/*
+Returns the mask type of the USING clause.
+*/
+func (this *CreateMask) MaskType() string {
+	return this.maskType
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreateMask) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createMask"}
+	r["keyspaceRef"] = this.keyspace
+	r["path"] = expression.NewStringer().Visit(this.path)
+	r["maskType"] = this.maskType
+	return json.Marshal(r)
+}
@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Drop mask ddl statement. Type DropMask is
+a struct that contains the keyspace and the path whose mask
+is dropped.
+*/
+type DropMask struct {
+	statementBase
+
+	keyspace *KeyspaceRef    `json:"keyspace"`
+	path     expression.Path `json:"path"`
+}
+
+/*
+The function NewDropMask returns a pointer to the
+DropMask struct with the input argument values as fields.
+*/
+func NewDropMask(keyspace *KeyspaceRef, path expression.Path) *DropMask {
+	rv := &DropMask{
+		keyspace: keyspace,
+		path:     path,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitDropMask method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *DropMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropMask(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *DropMask) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropMask) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropMask) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *DropMask) Expressions() expression.Expressions {
+	return expression.Expressions{this.path}
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *DropMask) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	}, nil
+}
+
+/*
+Return the keyspace.
+*/
+func (this *DropMask) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the masked path.
+*/
+func (this *DropMask) Path() expression.Path {
+	return this.path
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *DropMask) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "dropMask"}
+	r["keyspaceRef"] = this.keyspace
+	r["path"] = expression.NewStringer().Visit(this.path)
+	return json.Marshal(r)
+}
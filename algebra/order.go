@@ -143,6 +143,19 @@ func (this SortTerms) Expressions() expression.Expressions {
 	return exprs
 }
 
+/*
+   Returns the direction of each term.
+*/
+func (this SortTerms) Descending() []bool {
+	descending := make([]bool, len(this))
+
+	for i, term := range this {
+		descending[i] = term.descending
+	}
+
+	return descending
+}
+
 /*
    Representation as a N1QL string.
 */
@@ -0,0 +1,62 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+const (
+	OUTFILE_JSON  = "json"
+	OUTFILE_JSONL = "jsonl"
+	OUTFILE_CSV   = "csv"
+)
+
+/*
+Represents the INTO OUTFILE clause of a SELECT statement, which
+writes the results to a file on the query server instead of returning
+them. The format is json, a JSON array of the results; jsonl, one
+result per line; or csv, one row per result, with a header of the
+column names.
+*/
+type Outfile struct {
+	path   string
+	format string
+}
+
+func NewOutfile(path, format string) (*Outfile, error) {
+	format = strings.ToLower(format)
+	switch format {
+	case "":
+		format = OUTFILE_JSON
+	case OUTFILE_JSON, OUTFILE_JSONL, OUTFILE_CSV:
+	default:
+		return nil, fmt.Errorf("Invalid OUTFILE format %s; expected json, jsonl or csv.", format)
+	}
+
+	return &Outfile{
+		path:   path,
+		format: format,
+	}, nil
+}
+
+func (this *Outfile) Path() string {
+	return this.path
+}
+
+func (this *Outfile) Format() string {
+	return this.format
+}
+
+func (this *Outfile) String() string {
+	return "into outfile " + strconv.Quote(this.path) + " format " + this.format
+}
@@ -0,0 +1,120 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Create policy ddl statement. Type CreatePolicy is
+a struct that contains fields mapping to each clause in the
+create policy statement, namely the keyspace and the predicate
+of the USING clause.
+
+The predicate refers to the fields of the documents of the
+keyspace, like the keys of an index, and is not formalized.
+*/
+type CreatePolicy struct {
+	statementBase
+
+	keyspace  *KeyspaceRef          `json:"keyspace"`
+	predicate expression.Expression `json:"predicate"`
+}
+
+/*
+The function NewCreatePolicy returns a pointer to the
+CreatePolicy struct with the input argument values as fields.
+*/
+func NewCreatePolicy(keyspace *KeyspaceRef, predicate expression.Expression) *CreatePolicy {
+	rv := &CreatePolicy{
+		keyspace:  keyspace,
+		predicate: predicate,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreatePolicy method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreatePolicy) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *CreatePolicy) Formalize() error {
+	return nil
+}
+
+/*
+This method maps the predicate of the create policy statement.
+*/
+func (this *CreatePolicy) MapExpressions(mapper expression.Mapper) (err error) {
+	this.predicate, err = mapper.Map(this.predicate)
+	return
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *CreatePolicy) Expressions() expression.Expressions {
+	return expression.Expressions{this.predicate}
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *CreatePolicy) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	}, nil
+}
+
+/*
+Returns the keyspace that the policy is created on.
+*/
+func (this *CreatePolicy) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the predicate of the USING clause.
+*/
+func (this *CreatePolicy) Predicate() expression.Expression {
+	return this.predicate
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreatePolicy) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createPolicy"}
+	r["keyspaceRef"] = this.keyspace
+	r["predicate"] = expression.NewStringer().Visit(this.predicate)
+	return json.Marshal(r)
+}
@@ -0,0 +1,147 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Grant role dcl statement. Type GrantRole is a
+struct that contains the roles, the keyspaces they apply to, and
+the users. Without keyspaces, the roles apply to all keyspaces.
+*/
+type GrantRole struct {
+	statementBase
+
+	roles     []string       `json:"roles"`
+	keyspaces []*KeyspaceRef `json:"keyspaces"`
+	users     []string       `json:"users"`
+}
+
+/*
+The function NewGrantRole returns a pointer to the
+GrantRole struct with the input argument values as fields.
+*/
+func NewGrantRole(roles []string, keyspaces []*KeyspaceRef, users []string) *GrantRole {
+	rv := &GrantRole{
+		roles:     roles,
+		keyspaces: keyspaces,
+		users:     users,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitGrantRole method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *GrantRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitGrantRole(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *GrantRole) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *GrantRole) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *GrantRole) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *GrantRole) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *GrantRole) Privileges() (datastore.Privileges, errors.Error) {
+	return rolePrivileges(this.roles, this.keyspaces), nil
+}
+
+/*
+Returns the roles.
+*/
+func (this *GrantRole) Roles() []string {
+	return this.roles
+}
+
+/*
+Returns the keyspaces, or nil for all keyspaces.
+*/
+func (this *GrantRole) Keyspaces() []*KeyspaceRef {
+	return this.keyspaces
+}
+
+/*
+Returns the users.
+*/
+func (this *GrantRole) Users() []string {
+	return this.users
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *GrantRole) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "grantRole"}
+	r["roles"] = this.roles
+	r["keyspaces"] = this.keyspaces
+	r["users"] = this.users
+	return json.Marshal(r)
+}
+
+/*
+Managing the roles on a keyspace requires the DDL privilege on it.
+Managing roles on all keyspaces, or the admin role, requires the
+admin privilege.
+*/
+func rolePrivileges(roles []string, keyspaces []*KeyspaceRef) datastore.Privileges {
+	if len(keyspaces) == 0 {
+		return datastore.AdminPrivileges()
+	}
+
+	for _, role := range roles {
+		if role == datastore.ROLE_ADMIN {
+			return datastore.AdminPrivileges()
+		}
+	}
+
+	privs := datastore.NewPrivileges()
+	for _, ks := range keyspaces {
+		privs[ks.Namespace()+":"+ks.Keyspace()] = datastore.PRIV_DDL
+	}
+
+	return privs
+}
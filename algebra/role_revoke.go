@@ -0,0 +1,123 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Revoke role dcl statement. Type RevokeRole is a
+struct that contains the roles, the keyspaces they apply to, and
+the users. Without keyspaces, the roles apply to all keyspaces.
+*/
+type RevokeRole struct {
+	statementBase
+
+	roles     []string       `json:"roles"`
+	keyspaces []*KeyspaceRef `json:"keyspaces"`
+	users     []string       `json:"users"`
+}
+
+/*
+The function NewRevokeRole returns a pointer to the
+RevokeRole struct with the input argument values as fields.
+*/
+func NewRevokeRole(roles []string, keyspaces []*KeyspaceRef, users []string) *RevokeRole {
+	rv := &RevokeRole{
+		roles:     roles,
+		keyspaces: keyspaces,
+		users:     users,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitRevokeRole method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *RevokeRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRevokeRole(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *RevokeRole) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *RevokeRole) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *RevokeRole) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *RevokeRole) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *RevokeRole) Privileges() (datastore.Privileges, errors.Error) {
+	return rolePrivileges(this.roles, this.keyspaces), nil
+}
+
+/*
+Returns the roles.
+*/
+func (this *RevokeRole) Roles() []string {
+	return this.roles
+}
+
+/*
+Returns the keyspaces, or nil for all keyspaces.
+*/
+func (this *RevokeRole) Keyspaces() []*KeyspaceRef {
+	return this.keyspaces
+}
+
+/*
+Returns the users.
+*/
+func (this *RevokeRole) Users() []string {
+	return this.users
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *RevokeRole) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "revokeRole"}
+	r["roles"] = this.roles
+	r["keyspaces"] = this.keyspaces
+	r["users"] = this.users
+	return json.Marshal(r)
+}
@@ -33,6 +33,7 @@ type Select struct {
 	order     *Order                `json:"order"`
 	offset    expression.Expression `json:"offset"`
 	limit     expression.Expression `json:"limit"`
+	outfile   *Outfile              `json:"outfile"`
 }
 
 /*
@@ -151,6 +152,12 @@ func (this *Select) Privileges() (datastore.Privileges, errors.Error) {
 	}
 
 	privs.Add(subprivs)
+
+	// OUTFILE writes files on the server
+	if this.outfile != nil {
+		privs.Add(datastore.AdminPrivileges())
+	}
+
 	return privs, nil
 }
 
@@ -172,6 +179,10 @@ func (this *Select) String() string {
 		s += " offset " + this.offset.String()
 	}
 
+	if this.outfile != nil {
+		s += " " + this.outfile.String()
+	}
+
 	return s
 }
 
@@ -247,6 +258,17 @@ func (this *Select) SetLimit(limit expression.Expression) {
 	this.limit = limit
 }
 
+/*
+Returns the INTO OUTFILE clause, if any.
+*/
+func (this *Select) Outfile() *Outfile {
+	return this.outfile
+}
+
+func (this *Select) SetOutfile(outfile *Outfile) {
+	this.outfile = outfile
+}
+
 /*
 The Subresult interface represents the intermediate result of a
 select statement. It inherits from Node.
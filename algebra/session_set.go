@@ -0,0 +1,113 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the SET statement, which makes a setting for the
+current session. Type SessionSet is a struct that contains
+the name of the setting and its constant value.
+*/
+type SessionSet struct {
+	statementBase
+
+	name  string      `json:"name"`
+	value value.Value `json:"value"`
+}
+
+/*
+The function NewSessionSet returns a pointer to the SessionSet
+struct with the input argument values as fields.
+*/
+func NewSessionSet(name string, expr expression.Expression) *SessionSet {
+	rv := &SessionSet{
+		name:  name,
+		value: expr.Value(),
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitSessionSet method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *SessionSet) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSessionSet(this)
+}
+
+/*
+The result is the session id and settings.
+*/
+func (this *SessionSet) Signature() value.Value {
+	return value.NewValue(value.JSON.String())
+}
+
+/*
+Returns nil.
+*/
+func (this *SessionSet) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *SessionSet) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *SessionSet) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *SessionSet) Privileges() (datastore.Privileges, errors.Error) {
+	return nil, nil
+}
+
+/*
+Returns the name of the setting.
+*/
+func (this *SessionSet) Name() string {
+	return this.name
+}
+
+/*
+Returns the value of the setting.
+*/
+func (this *SessionSet) Value() value.Value {
+	return this.value
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *SessionSet) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "sessionSet"}
+	r["name"] = this.name
+	r["value"] = this.value
+	return json.Marshal(r)
+}
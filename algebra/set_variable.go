@@ -0,0 +1,119 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the SET statement for script variables, which binds the
+value of an expression to a named parameter. The variable can be
+used by the later statements of the request, and of the session if
+the request has one.
+*/
+type SetVariable struct {
+	statementBase
+
+	name string                `json:"name"`
+	expr expression.Expression `json:"expr"`
+}
+
+/*
+The function NewSetVariable returns a pointer to the SetVariable
+struct with the input argument values as fields.
+*/
+func NewSetVariable(name string, expr expression.Expression) *SetVariable {
+	rv := &SetVariable{
+		name: name,
+		expr: expr,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitSetVariable method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+/*
+The result is the name and value of the variable.
+*/
+func (this *SetVariable) Signature() value.Value {
+	return value.NewValue(map[string]interface{}{
+		"name":  value.STRING.String(),
+		"value": value.JSON.String(),
+	})
+}
+
+/*
+The expression cannot refer to any keyspace.
+*/
+func (this *SetVariable) Formalize() (err error) {
+	this.expr, err = expression.NewFormalizer().Map(this.expr)
+	return
+}
+
+/*
+Maps the expression.
+*/
+func (this *SetVariable) MapExpressions(mapper expression.Mapper) (err error) {
+	this.expr, err = mapper.Map(this.expr)
+	return
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *SetVariable) Expressions() expression.Expressions {
+	return expression.Expressions{this.expr}
+}
+
+/*
+Returns the privileges required by any subqueries.
+*/
+func (this *SetVariable) Privileges() (datastore.Privileges, errors.Error) {
+	return subqueryPrivileges(this.Expressions())
+}
+
+/*
+Returns the name of the variable, without the leading $.
+*/
+func (this *SetVariable) Name() string {
+	return this.name
+}
+
+/*
+Returns the expression of the variable.
+*/
+func (this *SetVariable) Expression() expression.Expression {
+	return this.expr
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *SetVariable) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "setVariable"}
+	r["name"] = this.name
+	r["expr"] = expression.NewStringer().Visit(this.expr)
+	return json.Marshal(r)
+}
@@ -69,6 +69,14 @@ func (this *Subquery) Evaluate(item value.Value, context expression.Context) (va
 	return context.(Context).EvaluateSubquery(this.query, item)
 }
 
+/*
+Return nil. A subquery reads data, even when its expressions only
+depend on query parameters.
+*/
+func (this *Subquery) Static() expression.Expression {
+	return nil
+}
+
 /*
 Return false. Subquery cannot be used as a secondary
 index key.
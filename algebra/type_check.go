@@ -0,0 +1,182 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+TypeChecker checks the static types of the expressions of a
+statement against their usage, e.g. arithmetic on strings or ORDER
+BY on objects. Such expressions evaluate to NULL or sort in
+surprising ways at runtime; in strict mode they are rejected when
+the statement is prepared. Only types known before execution are
+checked, so identifiers and other JSON values always pass.
+*/
+type TypeChecker struct {
+	expression.MapperBase
+}
+
+func NewTypeChecker() *TypeChecker {
+	rv := &TypeChecker{}
+	rv.SetMapper(rv)
+	return rv
+}
+
+/*
+Check the types of the statement after it is formalized. The error
+is a semantic error for the first mistyped expression.
+*/
+func TypeCheck(stmt Statement) errors.Error {
+	err := NewTypeChecker().checkStatement(stmt)
+	if err == nil {
+		return nil
+	}
+
+	if e, ok := err.(errors.Error); ok {
+		return e
+	}
+
+	return errors.NewSemanticError(err, "Type check failed.")
+}
+
+func (this *TypeChecker) checkStatement(stmt Statement) error {
+	switch stmt := stmt.(type) {
+	case *Prepare:
+		return this.checkStatement(stmt.Statement())
+	case *Explain:
+		return this.checkStatement(stmt.Statement())
+	case *Advise:
+		return this.checkStatement(stmt.Statement())
+	case *Select:
+		return this.checkSelect(stmt)
+	case *Insert:
+		if stmt.Select() != nil {
+			if err := this.checkOrder(stmt.Select()); err != nil {
+				return err
+			}
+		}
+	case *Upsert:
+		if stmt.Select() != nil {
+			if err := this.checkOrder(stmt.Select()); err != nil {
+				return err
+			}
+		}
+	}
+
+	return stmt.MapExpressions(this)
+}
+
+func (this *TypeChecker) checkSelect(sel *Select) error {
+	err := this.checkOrder(sel)
+	if err != nil {
+		return err
+	}
+
+	return sel.MapExpressions(this)
+}
+
+func (this *TypeChecker) checkOrder(sel *Select) error {
+	if sel.Order() != nil {
+		for _, term := range sel.Order().Terms() {
+			if term.Expression().Type() == value.OBJECT {
+				return mistyped(term.Expression(), "ORDER BY", value.OBJECT)
+			}
+		}
+	}
+
+	if err := checkType(sel.Offset(), "OFFSET", value.NUMBER); err != nil {
+		return err
+	}
+
+	return checkType(sel.Limit(), "LIMIT", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitAdd(expr *expression.Add) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitDiv(expr *expression.Div) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitMod(expr *expression.Mod) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitMult(expr *expression.Mult) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitNeg(expr *expression.Neg) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitSub(expr *expression.Sub) (interface{}, error) {
+	return this.visitOperands(expr, "arithmetic", value.NUMBER)
+}
+
+func (this *TypeChecker) VisitConcat(expr *expression.Concat) (interface{}, error) {
+	return this.visitOperands(expr, "concatenation", value.STRING)
+}
+
+func (this *TypeChecker) VisitLike(expr *expression.Like) (interface{}, error) {
+	return this.visitOperands(expr, "LIKE", value.STRING)
+}
+
+/*
+The children of a subquery are copies of its expressions, so the
+subquery itself is checked.
+*/
+func (this *TypeChecker) VisitSubquery(expr expression.Subquery) (interface{}, error) {
+	if subq, ok := expr.(*Subquery); ok {
+		return expr, this.checkSelect(subq.Select())
+	}
+
+	return expr, nil
+}
+
+func (this *TypeChecker) visitOperands(expr expression.Function, usage string,
+	want value.Type) (interface{}, error) {
+	for _, op := range expr.Operands() {
+		if err := checkType(op, usage, want); err != nil {
+			return nil, err
+		}
+	}
+
+	return expr, expr.MapChildren(this)
+}
+
+/*
+An expression is mistyped if its static type is a known type other
+than the one wanted. NULL, MISSING and non-specific JSON pass.
+*/
+func checkType(expr expression.Expression, usage string, want value.Type) error {
+	if expr == nil {
+		return nil
+	}
+
+	switch typ := expr.Type(); typ {
+	case want, value.MISSING, value.NULL, value.JSON:
+		return nil
+	default:
+		return mistyped(expr, usage, typ)
+	}
+}
+
+func mistyped(expr expression.Expression, usage string, typ value.Type) error {
+	return errors.NewSemanticError(nil, fmt.Sprintf("Invalid %s value for %s in %s.",
+		typ.String(), usage, expr.String()))
+}
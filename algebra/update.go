@@ -32,6 +32,7 @@ type Update struct {
 
 	keyspace  *KeyspaceRef          `json:"keyspace"`
 	keys      expression.Expression `json:"keys"`
+	keyRange  *KeyRange             `json:"key_range"`
 	indexes   IndexRefs             `json:"indexes"`
 	set       *Set                  `json:"set"`
 	unset     *Unset                `json:"unset"`
@@ -45,11 +46,12 @@ The function NewUpdate returns a pointer to the Update
 struct by assigning the input attributes to the fields
 of the struct.
 */
-func NewUpdate(keyspace *KeyspaceRef, keys expression.Expression, indexes IndexRefs,
+func NewUpdate(keyspace *KeyspaceRef, keys expression.Expression, keyRange *KeyRange, indexes IndexRefs,
 	set *Set, unset *Unset, where, limit expression.Expression, returning *Projection) *Update {
 	rv := &Update{
 		keyspace:  keyspace,
 		keys:      keys,
+		keyRange:  keyRange,
 		indexes:   indexes,
 		set:       set,
 		unset:     unset,
@@ -93,6 +95,13 @@ func (this *Update) MapExpressions(mapper expression.Mapper) (err error) {
 		}
 	}
 
+	if this.keyRange != nil {
+		err = this.keyRange.MapExpressions(mapper)
+		if err != nil {
+			return
+		}
+	}
+
 	if this.set != nil {
 		err = this.set.MapExpressions(mapper)
 		if err != nil {
@@ -138,6 +147,10 @@ func (this *Update) Expressions() expression.Expressions {
 		exprs = append(exprs, this.keys)
 	}
 
+	if this.keyRange != nil {
+		exprs = append(exprs, this.keyRange.Expressions()...)
+	}
+
 	if this.set != nil {
 		exprs = append(exprs, this.set.Expressions()...)
 	}
@@ -196,6 +209,13 @@ func (this *Update) Formalize() (err error) {
 		}
 	}
 
+	if this.keyRange != nil {
+		err = this.keyRange.Formalize()
+		if err != nil {
+			return
+		}
+	}
+
 	if this.set != nil {
 		err = this.set.Formalize(f)
 		if err != nil {
@@ -246,6 +266,14 @@ func (this *Update) Keys() expression.Expression {
 	return this.keys
 }
 
+/*
+Returns the key range defined by the use keys between
+clause, if any.
+*/
+func (this *Update) KeyRange() *KeyRange {
+	return this.keyRange
+}
+
 /*
 Returns the indexes defined by the use index clause.
 */
@@ -10,24 +10,175 @@
 package algebra
 
 import (
+	"strings"
+
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/expression"
 )
 
 var EMPTY_USE = NewUse(nil, nil)
 
 type Use struct {
-	keys    expression.Expression
-	indexes IndexRefs
+	keys        expression.Expression
+	keyRange    *KeyRange
+	indexes     IndexRefs
+	consistency *Consistency
 }
 
 func NewUse(keys expression.Expression, indexes IndexRefs) *Use {
-	return &Use{keys, indexes}
+	return &Use{keys: keys, indexes: indexes}
+}
+
+func NewUseKeyRange(keyRange *KeyRange) *Use {
+	return &Use{keyRange: keyRange}
+}
+
+/*
+Add the USE CONSISTENCY clause, which can follow USE INDEX.
+*/
+func NewUseConsistency(use *Use, consistency *Consistency) *Use {
+	return &Use{keys: use.keys, keyRange: use.keyRange, indexes: use.indexes, consistency: consistency}
 }
 
 func (this *Use) Keys() expression.Expression {
 	return this.keys
 }
 
+func (this *Use) KeyRange() *KeyRange {
+	return this.keyRange
+}
+
 func (this *Use) Indexes() IndexRefs {
 	return this.indexes
 }
+
+func (this *Use) Consistency() *Consistency {
+	return this.consistency
+}
+
+/*
+KeyRange represents USE KEYS BETWEEN low AND high, which selects the
+documents whose keys are in the range, bounds included, by scanning
+the primary index.
+*/
+type KeyRange struct {
+	low  expression.Expression
+	high expression.Expression
+}
+
+func NewKeyRange(low, high expression.Expression) *KeyRange {
+	return &KeyRange{low, high}
+}
+
+func (this *KeyRange) Low() expression.Expression {
+	return this.low
+}
+
+func (this *KeyRange) High() expression.Expression {
+	return this.high
+}
+
+func (this *KeyRange) MapExpressions(mapper expression.Mapper) (err error) {
+	this.low, err = mapper.Map(this.low)
+	if err != nil {
+		return
+	}
+
+	this.high, err = mapper.Map(this.high)
+	return
+}
+
+func (this *KeyRange) Expressions() expression.Expressions {
+	return expression.Expressions{this.low, this.high}
+}
+
+/*
+The bounds cannot refer to the keyspace.
+*/
+func (this *KeyRange) Formalize() (err error) {
+	empty := expression.NewFormalizer()
+	_, err = this.low.Accept(empty)
+	if err != nil {
+		return
+	}
+
+	_, err = this.high.Accept(empty)
+	return
+}
+
+/*
+Consistency represents USE CONSISTENCY, the scan consistency of the
+index scans of a keyspace term, which overrides the consistency of
+the request. AT_PLUS takes a scan vector, usually a parameter.
+*/
+type Consistency struct {
+	level  datastore.ScanConsistency
+	vector expression.Expression
+}
+
+func NewConsistency(level datastore.ScanConsistency, vector expression.Expression) *Consistency {
+	return &Consistency{level, vector}
+}
+
+/*
+Returns the scan consistency of a level named as in the request
+parameter scan_consistency, and whether the level takes a vector.
+*/
+func ConsistencyLevel(name string) (level datastore.ScanConsistency, vector bool, ok bool) {
+	switch strings.ToLower(name) {
+	case "not_bounded":
+		return datastore.UNBOUNDED, false, true
+	case "request_plus", "statement_plus":
+		return datastore.SCAN_PLUS, false, true
+	case "at_plus":
+		return datastore.AT_PLUS, true, true
+	default:
+		return "", false, false
+	}
+}
+
+func (this *Consistency) Level() datastore.ScanConsistency {
+	return this.level
+}
+
+func (this *Consistency) Vector() expression.Expression {
+	return this.vector
+}
+
+func (this *Consistency) MapExpressions(mapper expression.Mapper) (err error) {
+	if this.vector != nil {
+		this.vector, err = mapper.Map(this.vector)
+	}
+
+	return
+}
+
+func (this *Consistency) Expressions() expression.Expressions {
+	if this.vector == nil {
+		return nil
+	}
+
+	return expression.Expressions{this.vector}
+}
+
+/*
+The vector cannot refer to the keyspace.
+*/
+func (this *Consistency) Formalize() (err error) {
+	if this.vector != nil {
+		_, err = this.vector.Accept(expression.NewFormalizer())
+	}
+
+	return
+}
+
+func (this *Consistency) String() string {
+	switch this.level {
+	case datastore.UNBOUNDED:
+		return "use consistency not_bounded"
+	case datastore.SCAN_PLUS:
+		return "use consistency request_plus"
+	default:
+		return "use consistency at_plus(" + this.vector.String() + ")"
+	}
+}
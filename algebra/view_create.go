@@ -0,0 +1,136 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Create view and Create materialized view ddl
+statements. Type CreateView is a struct that contains fields mapping
+to each clause in the create view statement, namely the name of the
+view, and the query that defines it. The results of a materialized
+view are held by a keyspace of that name; a view that is not
+materialized is expanded into the queries that read it.
+*/
+type CreateView struct {
+	statementBase
+
+	keyspace     *KeyspaceRef `json:"keyspace"`
+	query        *Select      `json:"query"`
+	materialized bool         `json:"materialized"`
+}
+
+/*
+The function NewCreateView returns a pointer to the
+CreateView struct with the input argument values as fields.
+*/
+func NewCreateView(keyspace *KeyspaceRef, query *Select, materialized bool) *CreateView {
+	rv := &CreateView{
+		keyspace:     keyspace,
+		query:        query,
+		materialized: materialized,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitCreateView method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *CreateView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateView(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *CreateView) Signature() value.Value {
+	return nil
+}
+
+/*
+Fully qualify identifiers of the query.
+*/
+func (this *CreateView) Formalize() error {
+	return this.query.Formalize()
+}
+
+/*
+Map expressions of the query.
+*/
+func (this *CreateView) MapExpressions(mapper expression.Mapper) error {
+	return this.query.MapExpressions(mapper)
+}
+
+/*
+Returns all contained Expressions.
+*/
+func (this *CreateView) Expressions() expression.Expressions {
+	return this.query.Expressions()
+}
+
+/*
+Returns all required privileges: DDL on the name of the view, and
+running its query.
+*/
+func (this *CreateView) Privileges() (datastore.Privileges, errors.Error) {
+	privs, err := this.query.Privileges()
+	if err != nil {
+		return nil, err
+	}
+
+	privs.Add(datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	})
+	return privs, nil
+}
+
+/*
+Returns the name of the view, and of the keyspace that holds a
+materialized view.
+*/
+func (this *CreateView) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns the query that defines the view.
+*/
+func (this *CreateView) Query() *Select {
+	return this.query
+}
+
+/*
+Returns true for CREATE MATERIALIZED VIEW.
+*/
+func (this *CreateView) Materialized() bool {
+	return this.materialized
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *CreateView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "createView"}
+	r["keyspaceRef"] = this.keyspace
+	r["select"] = this.query
+	r["materialized"] = this.materialized
+	return json.Marshal(r)
+}
@@ -0,0 +1,114 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Drop view and Drop materialized view ddl statements.
+Dropping a materialized view also drops the keyspace that holds it.
+*/
+type DropView struct {
+	statementBase
+
+	keyspace     *KeyspaceRef `json:"keyspace"`
+	materialized bool         `json:"materialized"`
+}
+
+/*
+The function NewDropView returns a pointer to the
+DropView struct with the input argument values as fields.
+*/
+func NewDropView(keyspace *KeyspaceRef, materialized bool) *DropView {
+	rv := &DropView{
+		keyspace:     keyspace,
+		materialized: materialized,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitDropView method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *DropView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropView(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *DropView) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropView) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropView) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *DropView) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges.
+*/
+func (this *DropView) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_DDL,
+	}, nil
+}
+
+/*
+Returns the name of the view.
+*/
+func (this *DropView) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Returns true for DROP MATERIALIZED VIEW.
+*/
+func (this *DropView) Materialized() bool {
+	return this.materialized
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *DropView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "dropView"}
+	r["keyspaceRef"] = this.keyspace
+	r["materialized"] = this.materialized
+	return json.Marshal(r)
+}
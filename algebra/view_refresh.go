@@ -0,0 +1,105 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package algebra
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Represents the Refresh materialized view statement, which recomputes
+the results of the view from its definition.
+*/
+type RefreshView struct {
+	statementBase
+
+	keyspace *KeyspaceRef `json:"keyspace"`
+}
+
+/*
+The function NewRefreshView returns a pointer to the
+RefreshView struct with the input argument values as fields.
+*/
+func NewRefreshView(keyspace *KeyspaceRef) *RefreshView {
+	rv := &RefreshView{
+		keyspace: keyspace,
+	}
+
+	rv.stmt = rv
+	return rv
+}
+
+/*
+It calls the VisitRefreshView method by passing in the
+receiver and returns the interface. It is a visitor
+pattern.
+*/
+func (this *RefreshView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRefreshView(this)
+}
+
+/*
+Returns nil.
+*/
+func (this *RefreshView) Signature() value.Value {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *RefreshView) Formalize() error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *RefreshView) MapExpressions(mapper expression.Mapper) error {
+	return nil
+}
+
+/*
+Returns nil.
+*/
+func (this *RefreshView) Expressions() expression.Expressions {
+	return nil
+}
+
+/*
+Returns all required privileges. The view is rewritten; the query of
+its definition was authorized when the view was created.
+*/
+func (this *RefreshView) Privileges() (datastore.Privileges, errors.Error) {
+	return datastore.Privileges{
+		this.keyspace.Namespace() + ":" + this.keyspace.Keyspace(): datastore.PRIV_WRITE,
+	}, nil
+}
+
+/*
+Returns the keyspace that holds the view.
+*/
+func (this *RefreshView) Keyspace() *KeyspaceRef {
+	return this.keyspace
+}
+
+/*
+Marshals input receiver into byte array.
+*/
+func (this *RefreshView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"type": "refreshView"}
+	r["keyspaceRef"] = this.keyspace
+	return json.Marshal(r)
+}
@@ -25,6 +25,7 @@ type Visitor interface {
 	VisitDelete(stmt *Delete) (interface{}, error)
 	VisitUpdate(stmt *Update) (interface{}, error)
 	VisitMerge(stmt *Merge) (interface{}, error)
+	VisitImport(stmt *Import) (interface{}, error)
 
 	/*
 	   Visitor for DDL statements. N1QL provides index
@@ -37,11 +38,54 @@ type Visitor interface {
 	VisitAlterIndex(stmt *AlterIndex) (interface{}, error)
 	VisitBuildIndexes(stmt *BuildIndexes) (interface{}, error)
 
+	/*
+	   Visitor for the security policy statements Create policy,
+	   Drop policy, Create mask and Drop mask.
+	*/
+	VisitCreatePolicy(stmt *CreatePolicy) (interface{}, error)
+	VisitDropPolicy(stmt *DropPolicy) (interface{}, error)
+	VisitCreateMask(stmt *CreateMask) (interface{}, error)
+	VisitDropMask(stmt *DropMask) (interface{}, error)
+
+	/*
+	   Visitor for the materialized view statements Create
+	   materialized view, Drop materialized view and Refresh
+	   materialized view.
+	*/
+	VisitCreateView(stmt *CreateView) (interface{}, error)
+	VisitDropView(stmt *DropView) (interface{}, error)
+	VisitRefreshView(stmt *RefreshView) (interface{}, error)
+
+	/*
+	   Visitor for the temporary keyspace statements Create temp
+	   keyspace and Drop keyspace.
+	*/
+	VisitCreateKeyspace(stmt *CreateKeyspace) (interface{}, error)
+	VisitDropKeyspace(stmt *DropKeyspace) (interface{}, error)
+
+	/*
+	   Visitor for the role statements Grant role and Revoke
+	   role.
+	*/
+	VisitGrantRole(stmt *GrantRole) (interface{}, error)
+	VisitRevokeRole(stmt *RevokeRole) (interface{}, error)
+
+	/*
+	   Visitor for SET statements.
+	*/
+	VisitSessionSet(stmt *SessionSet) (interface{}, error)
+	VisitSetVariable(stmt *SetVariable) (interface{}, error)
+
 	/*
 	   Visitor for EXPLAIN statements.
 	*/
 	VisitExplain(stmt *Explain) (interface{}, error)
 
+	/*
+	   Visitor for ADVISE statements.
+	*/
+	VisitAdvise(stmt *Advise) (interface{}, error)
+
 	/*
 	   Visitor for PREPAREd statements.
 	*/
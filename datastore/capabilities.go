@@ -0,0 +1,136 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+)
+
+/*
+Capabilities are the features of a datastore or keyspace that the
+planner and execution rely on, so that they can branch on them rather
+than on the errors of unsupported operations.
+*/
+type Capabilities uint32
+
+const (
+	CAPABILITY_DML             Capabilities = 1 << iota // INSERT, UPSERT, UPDATE and DELETE
+	CAPABILITY_CAS                                      // Updates conditional on CAS; see CasKeyspace
+	CAPABILITY_TTL                                      // Document expiration
+	CAPABILITY_SECONDARY_INDEX                          // Indexes on document fields
+	CAPABILITY_COUNT                                    // COUNT(*) pushed down to Count()
+	CAPABILITY_PROJECTION                               // Projections pushed down to Fetch
+	CAPABILITY_SNAPSHOT                                 // Consistent views; see SnapshotKeyspace
+	CAPABILITY_DURABILITY                               // Durable mutations; see DurableKeyspace
+	CAPABILITY_FEED                                     // Mutation feeds; see FeedKeyspace
+	CAPABILITY_SIZE                                     // Keyspace sizes; see SizedKeyspace
+)
+
+var _CAPABILITY_NAMES = []string{
+	"dml",
+	"cas",
+	"ttl",
+	"secondary_index",
+	"count",
+	"projection",
+	"snapshot",
+	"durability",
+	"feed",
+	"size",
+}
+
+func (this Capabilities) Has(capabilities Capabilities) bool {
+	return this&capabilities == capabilities
+}
+
+// The names of the capabilities, in order.
+func (this Capabilities) Names() []string {
+	names := make([]string, 0, len(_CAPABILITY_NAMES))
+	for i, name := range _CAPABILITY_NAMES {
+		if this.Has(1 << uint(i)) {
+			names = append(names, name)
+		}
+	}
+
+	return names
+}
+
+func (this Capabilities) String() string {
+	return strings.Join(this.Names(), ",")
+}
+
+// The capability of the given name; false if there is none.
+func ParseCapability(name string) (Capabilities, bool) {
+	name = strings.ToLower(name)
+	for i, n := range _CAPABILITY_NAMES {
+		if n == name {
+			return 1 << uint(i), true
+		}
+	}
+
+	return 0, false
+}
+
+/*
+CapableDatastore and CapableKeyspace are implemented by datastores and
+keyspaces that declare their capabilities. The capabilities of others
+are assumed; see DatastoreCapabilities and KeyspaceCapabilities.
+*/
+type CapableDatastore interface {
+	Datastore
+	Capabilities() Capabilities
+}
+
+type CapableKeyspace interface {
+	Keyspace
+	Capabilities() Capabilities
+}
+
+// Datastores that do not declare their capabilities accept DML unless
+// they are read-only.
+func DatastoreCapabilities(store Datastore) Capabilities {
+	if capable, ok := store.(CapableDatastore); ok {
+		return capable.Capabilities()
+	}
+
+	if IsReadonly(store) {
+		return 0
+	}
+
+	return CAPABILITY_DML
+}
+
+// Keyspaces that do not declare their capabilities are assumed to
+// accept DML, secondary indexes and pushdowns, as well as the
+// capabilities of the optional interfaces they implement.
+func KeyspaceCapabilities(keyspace Keyspace) Capabilities {
+	if capable, ok := keyspace.(CapableKeyspace); ok {
+		return capable.Capabilities()
+	}
+
+	rv := CAPABILITY_DML | CAPABILITY_SECONDARY_INDEX | CAPABILITY_COUNT | CAPABILITY_PROJECTION
+	if _, ok := keyspace.(CasKeyspace); ok {
+		rv |= CAPABILITY_CAS
+	}
+	if _, ok := keyspace.(SnapshotKeyspace); ok {
+		rv |= CAPABILITY_SNAPSHOT
+	}
+	if _, ok := keyspace.(DurableKeyspace); ok {
+		rv |= CAPABILITY_DURABILITY
+	}
+	if _, ok := keyspace.(FeedKeyspace); ok {
+		rv |= CAPABILITY_FEED
+	}
+	if _, ok := keyspace.(SizedKeyspace); ok {
+		rv |= CAPABILITY_SIZE
+	}
+
+	return rv
+}
@@ -0,0 +1,48 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+/*
+CasKeyspace is implemented by keyspaces that can update documents only
+if they are unchanged since they were fetched, as identified by the
+CAS in the metadata of the updated values. Documents modified
+concurrently are not updated, and their keys are returned so that
+the update can be retried.
+*/
+type CasKeyspace interface {
+	Keyspace
+	UpdateCas(updates []Pair) ([]Pair, []string, errors.Error) // Updated pairs, and keys modified since fetched
+}
+
+// The CAS of a fetched document; zero, which matches any CAS, if its
+// keyspace does not report one.
+func FetchedCas(v value.Value) uint64 {
+	av, ok := v.(value.AnnotatedValue)
+	if !ok {
+		return 0
+	}
+
+	meta, _ := av.GetAttachment("meta").(map[string]interface{})
+	switch cas := meta["cas"].(type) {
+	case uint64:
+		return cas
+	case int64:
+		return uint64(cas)
+	case float64:
+		return uint64(cas)
+	}
+
+	return 0
+}
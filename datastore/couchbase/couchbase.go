@@ -88,7 +88,13 @@ func doAuth(username, password, bucket string, requested datastore.Privilege) (b
 		return false, err
 	}
 
-	if requested == datastore.PRIV_DDL {
+	if requested == datastore.PRIV_ADMIN {
+		authResult, err := creds.IsAdmin()
+		if err != nil || authResult == false {
+			return false, err
+		}
+
+	} else if requested == datastore.PRIV_DDL {
 		authResult, err := creds.CanDDLBucket(bucket)
 		if err != nil || authResult == false {
 			return false, err
@@ -192,6 +198,11 @@ func (s *site) Authorize(privileges datastore.Privileges, credentials datastore.
 	return nil
 }
 
+// Roles are managed by the Couchbase server.
+func (s *site) RoleCatalog() (datastore.RoleCatalog, errors.Error) {
+	return nil, errors.NewOtherNotImplementedError(nil, "role catalog")
+}
+
 func (s *site) SetLogLevel(level logging.Level) {
 	for _, n := range s.namespaceCache {
 		defer n.lock.Unlock()
@@ -622,6 +633,11 @@ func (b *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.
 	}
 }
 
+func (b *keyspace) Capabilities() datastore.Capabilities {
+	return datastore.CAPABILITY_DML | datastore.CAPABILITY_CAS | datastore.CAPABILITY_TTL |
+		datastore.CAPABILITY_SECONDARY_INDEX | datastore.CAPABILITY_COUNT | datastore.CAPABILITY_PROJECTION
+}
+
 func (b *keyspace) Indexers() ([]datastore.Indexer, errors.Error) {
 	indexers := make([]datastore.Indexer, 0, 2)
 	if b.gsiIndexer != nil {
@@ -733,13 +749,16 @@ func getMeta(key string, meta map[string]interface{}) (cas uint64, flags uint32,
 
 }
 
-func (b *keyspace) performOp(op int, inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+// Returns the pairs mutated, and the keys of updates that failed
+// because of concurrent modifications.
+func (b *keyspace) performOp(op int, inserts []datastore.Pair) ([]datastore.Pair, []string, errors.Error) {
 
 	if len(inserts) == 0 {
-		return nil, nil
+		return nil, nil, nil
 	}
 
 	insertedKeys := make([]datastore.Pair, 0, len(inserts))
+	var mismatched []string
 	var err error
 
 	for _, kv := range inserts {
@@ -790,6 +809,7 @@ func (b *keyspace) performOp(op int, inserts []datastore.Pair) ([]datastore.Pair
 		if err != nil {
 			if isEExistError(err) {
 				logging.Errorf("Failed to perform update on key %s. CAS mismatch due to concurrent modifications", key)
+				mismatched = append(mismatched, key)
 			} else {
 				logging.Errorf("Failed to perform %s on key %s for Keyspace %s Error %v", opToString(op), key, b.Name(), err)
 			}
@@ -799,24 +819,38 @@ func (b *keyspace) performOp(op int, inserts []datastore.Pair) ([]datastore.Pair
 	}
 
 	if len(insertedKeys) == 0 {
-		return nil, errors.NewCbDMLError(err, "Failed to perform "+opToString(op))
+		return nil, mismatched, errors.NewCbDMLError(err, "Failed to perform "+opToString(op))
 	}
 
-	return insertedKeys, nil
+	return insertedKeys, mismatched, nil
 
 }
 
 func (b *keyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(INSERT, inserts)
+	rv, _, err := b.performOp(INSERT, inserts)
+	return rv, err
 
 }
 
 func (b *keyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(UPDATE, updates)
+	rv, _, err := b.performOp(UPDATE, updates)
+	return rv, err
+}
+
+func (b *keyspace) UpdateCas(updates []datastore.Pair) ([]datastore.Pair, []string, errors.Error) {
+	rv, mismatched, err := b.performOp(UPDATE, updates)
+
+	// Only concurrent modifications, which can be retried
+	if len(mismatched) == len(updates) {
+		err = nil
+	}
+
+	return rv, mismatched, err
 }
 
 func (b *keyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(UPSERT, upserts)
+	rv, _, err := b.performOp(UPSERT, upserts)
+	return rv, err
 }
 
 func (b *keyspace) Delete(deletes []string) ([]string, errors.Error) {
@@ -23,8 +23,11 @@ plans before query Beta / GA.
 package datastore
 
 import (
+	"strings"
+
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/timestamp"
 	"github.com/couchbase/query/value"
 )
 
@@ -40,9 +43,25 @@ type Datastore interface {
 	NamespaceById(id string) (Namespace, errors.Error)     // Find a namespace in this datastore using the namespace's Id
 	NamespaceByName(name string) (Namespace, errors.Error) // Find a namespace in this datastore using the namespace's name
 	Authorize(Privileges, Credentials) errors.Error        // Perform authorization and return nil if successful
+	RoleCatalog() (RoleCatalog, errors.Error)              // Roles granted to users
 	SetLogLevel(level logging.Level)                       // Set log level of in-process indexers
 }
 
+/*
+ReadonlyDatastore is implemented by datastores that can be mounted
+read-only. Statements that would change a read-only datastore are
+rejected when they are planned.
+*/
+type ReadonlyDatastore interface {
+	Datastore
+	Readonly() bool
+}
+
+func IsReadonly(store Datastore) bool {
+	readonly, ok := store.(ReadonlyDatastore)
+	return ok && readonly.Readonly()
+}
+
 // Namespace represents a logical boundary that is within a datastore and above
 // a keyspace. In the query language, a namespace is only used as a namespace
 // to qualify keyspace names. No assumptions are made about namespaces and
@@ -57,6 +76,24 @@ type Namespace interface {
 	KeyspaceByName(name string) (Keyspace, errors.Error) // Find a keyspace in this namespace using the keyspace's name
 }
 
+/*
+KeyspaceManager is implemented by namespaces that can create and drop
+keyspaces, e.g. the keyspaces that hold materialized views.
+*/
+type KeyspaceManager interface {
+	Namespace
+	CreateKeyspace(name string) (Keyspace, errors.Error) // Create an empty keyspace
+	DropKeyspace(name string) errors.Error               // Drop a keyspace and its documents
+}
+
+/*
+Temporary keyspaces are named with a leading '#'. They belong to a
+session or a request, and are only visible to it.
+*/
+func IsTemporary(keyspace string) bool {
+	return strings.HasPrefix(keyspace, "#")
+}
+
 // Keyspace is a map of key-value entries (typically key-document, but
 // also key-counter, key-blob, etc.). Keys are unique within a
 // keyspace.
@@ -82,6 +119,61 @@ type Keyspace interface {
 	Release() // Release any resources held by this object
 }
 
+/*
+SnapshotKeyspace is implemented by keyspaces that can give a request
+a consistent view. Once a request opens a snapshot, its scans and its
+fetches through FetchSnapshot observe the keyspace as of the opening,
+until the snapshot is released.
+*/
+type SnapshotKeyspace interface {
+	Keyspace
+	OpenSnapshot(requestId string) errors.Error
+	FetchSnapshot(requestId string, keys []string) ([]AnnotatedPair, []errors.Error)
+	ReleaseSnapshot(requestId string)
+}
+
+/*
+SizedKeyspace is implemented by keyspaces that can report their
+approximate size. Their documents also report their own size in
+META().size.
+*/
+type SizedKeyspace interface {
+	Keyspace
+	Size() (int64, errors.Error) // Approximate size in bytes of the documents in this keyspace
+}
+
+/*
+FeedKeyspace is implemented by keyspaces that expose a feed of their
+mutations, for change data capture. A feed delivers the mutations
+applied after it is opened, in order, until it is closed.
+*/
+type FeedKeyspace interface {
+	Keyspace
+	Subscribe() (MutationFeed, errors.Error)
+}
+
+type MutationFeed interface {
+	Mutations() <-chan *Mutation // Closed when the feed is closed or fails
+	Err() errors.Error           // Why the feed failed, if it did
+	Close()
+}
+
+// Operations of mutations
+const (
+	MUTATION_INSERT = "insert"
+	MUTATION_UPDATE = "update"
+	MUTATION_UPSERT = "upsert"
+	MUTATION_DELETE = "delete"
+)
+
+// A mutation of a document, and the vector of the keyspace after it.
+type Mutation struct {
+	Key    string
+	Op     string
+	Cas    uint64
+	Vector timestamp.Vector
+}
+
 // Key-value pair
 type Pair struct {
 	Key   string
@@ -99,22 +191,19 @@ var _DATASTORE Datastore
 
 func SetDatastore(datastore Datastore) {
 	_DATASTORE = datastore
+	InvalidateKeyspaces()
 }
 
 func GetDatastore() Datastore {
 	return _DATASTORE
 }
 
+// Unqualified keyspaces are looked up along the search path.
 func GetKeyspace(namespace, keyspace string) (Keyspace, errors.Error) {
 	datastore := GetDatastore()
 	if datastore == nil {
 		return nil, errors.NewError(nil, "Datastore not set.")
 	}
 
-	ns, err := datastore.NamespaceByName(namespace)
-	if err != nil {
-		return nil, err
-	}
-
-	return ns.KeyspaceByName(keyspace)
+	return ResolveKeyspace(datastore, namespace, keyspace)
 }
@@ -0,0 +1,54 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+)
+
+/*
+DurableKeyspace is implemented by keyspaces whose mutations can be
+made durable before they are acknowledged. Keyspaces that do not
+implement it achieve no durability beyond that of their storage.
+*/
+type DurableKeyspace interface {
+	Keyspace
+	Durable(durability Durability) Keyspace // This keyspace, with mutations made durable as given
+}
+
+// How durable mutations are before they are acknowledged.
+type Durability string
+
+const (
+	DURABILITY_NONE     Durability = "none"               // Left to the storage to flush
+	DURABILITY_BATCH    Durability = "fsync_per_batch"    // Flushed after each batch of mutations
+	DURABILITY_MUTATION Durability = "fsync_per_mutation" // Flushed after each mutation
+)
+
+var _DURABILITY_RANKS = map[Durability]int{
+	DURABILITY_NONE:     0,
+	DURABILITY_BATCH:    1,
+	DURABILITY_MUTATION: 2,
+}
+
+func ParseDurability(s string) (Durability, bool) {
+	durability := Durability(strings.ToLower(s))
+	_, ok := _DURABILITY_RANKS[durability]
+	return durability, ok
+}
+
+// The weaker of two durabilities; an empty durability is ignored.
+func WeakerDurability(d1, d2 Durability) Durability {
+	if d1 == "" || (d2 != "" && _DURABILITY_RANKS[d2] < _DURABILITY_RANKS[d1]) {
+		return d2
+	}
+
+	return d1
+}
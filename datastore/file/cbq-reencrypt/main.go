@@ -0,0 +1,72 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+cbq-reencrypt rewrites the files of a file datastore with a new
+encryption key, for key rotation. The keys are hex encoded, and are
+taken from the environment, so that they do not show in process
+listings: the old key from CBQ_FILE_OLD_KEY, and the new key from
+CBQ_FILE_KEY, the variable that cbq-engine reads it from. An empty old
+key encrypts a datastore written in the clear; an empty new key
+decrypts a datastore. Stop cbq-engine before rewriting its datastore.
+
+	CBQ_FILE_OLD_KEY=... CBQ_FILE_KEY=... cbq-reencrypt -datastore ./data
+*/
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/couchbase/query/datastore/file"
+)
+
+const OLD_KEY_ENV = "CBQ_FILE_OLD_KEY"
+
+var DATASTORE = flag.String("datastore", "", "Path of the file datastore")
+
+func main() {
+	flag.Parse()
+
+	if *DATASTORE == "" {
+		fmt.Fprintln(os.Stderr, "No datastore given.")
+		flag.Usage()
+		os.Exit(1)
+	}
+
+	oldKey := key(OLD_KEY_ENV)
+	newKey := key(file.KEY_ENV)
+	if oldKey == nil && newKey == nil {
+		fmt.Fprintf(os.Stderr, "Neither %s nor %s is set.\n", OLD_KEY_ENV, file.KEY_ENV)
+		os.Exit(1)
+	}
+
+	n, err := file.Reencrypt(*DATASTORE, oldKey, newKey)
+	if err != nil {
+		fmt.Fprintln(os.Stderr, err.Error())
+		os.Exit(1)
+	}
+
+	fmt.Printf("Rewrote %d files.\n", n)
+}
+
+func key(env string) []byte {
+	if os.Getenv(env) == "" {
+		return nil
+	}
+
+	rv, err := file.ParseKey(os.Getenv(env))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "%s: %v\n", env, err)
+		os.Exit(1)
+	}
+
+	return rv
+}
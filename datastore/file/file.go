@@ -19,16 +19,21 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"net/url"
 	"os"
 	"path/filepath"
+	"sort"
 	"strings"
 	"sync"
+	"time"
 
+	atomic "github.com/couchbase/go-couchbase/platform"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
 )
 
@@ -37,6 +42,13 @@ type store struct {
 	path           string
 	namespaces     map[string]*namespace
 	namespaceNames []string
+	roles          *roleCatalog
+	manifests      bool        // Keep a sorted key manifest per keyspace
+	journals       bool        // Keep a journal of mutations per keyspace
+	cipher         *fileCipher // Encrypts the files at rest; nil if not encrypted
+	checksums      bool        // Verify documents against sidecar checksums
+	readonly       bool        // Refuse changes, and take no locks
+	lockTimeout    time.Duration
 }
 
 func (s *store) Id() string {
@@ -60,16 +72,33 @@ func (s *store) NamespaceById(id string) (p datastore.Namespace, e errors.Error)
 }
 
 func (s *store) NamespaceByName(name string) (p datastore.Namespace, e errors.Error) {
-	p, ok := s.namespaces[strings.ToUpper(name)]
+	ns, ok := s.namespaces[strings.ToUpper(name)]
 	if !ok {
-		e = errors.NewFileNamespaceNotFoundError(nil, name)
+		return nil, errors.NewFileNamespaceNotFoundError(nil, name)
 	}
 
-	return
+	if len(ns.scopes) > 0 {
+		return &scopedNamespace{ns}, nil
+	}
+
+	return ns, nil
 }
 
-func (s *store) Authorize(datastore.Privileges, datastore.Credentials) errors.Error {
-	return nil
+func (s *store) Authorize(privileges datastore.Privileges, credentials datastore.Credentials) errors.Error {
+	users := make([]string, 0, len(credentials))
+	for user, _ := range credentials {
+		users = append(users, user)
+	}
+
+	return datastore.AuthorizeRoles(s.roles, privileges, users)
+}
+
+func (s *store) RoleCatalog() (datastore.RoleCatalog, errors.Error) {
+	return s.roles, nil
+}
+
+func (s *store) Readonly() bool {
+	return s.readonly
 }
 
 func (s *store) SetLogLevel(level logging.Level) {
@@ -77,19 +106,71 @@ func (s *store) SetLogLevel(level logging.Level) {
 }
 
 // NewStore creates a new file-based store for the given filepath.
+// The path may be followed by ?manifest=true, to keep a sorted key
+// manifest per keyspace for faster span scans, by journal=true, to
+// keep a journal of mutations per keyspace for mutation feeds, and by
+// encrypt=true, to encrypt the files at rest with the key of the key
+// provider; see SetKeyProvider, by checksums=true, to verify
+// documents against their checksums and quarantine corrupt ones, by
+// lock_timeout=<duration>, to wait that long for the locks of
+// keyspaces held by other processes, and by readonly=true, to refuse
+// all changes and take no locks.
 func NewDatastore(path string) (s datastore.Datastore, e errors.Error) {
+	manifests := false
+	journals := false
+	encrypt := false
+	checksums := false
+	readonly := false
+	lockTimeout := _LOCK_TIMEOUT
+	if i := strings.LastIndex(path, "?"); i >= 0 {
+		options, er := url.ParseQuery(path[i+1:])
+		if er != nil {
+			return nil, errors.NewFileDatastoreError(er, "")
+		}
+
+		path = path[:i]
+		manifests = options.Get("manifest") == "true"
+		journals = options.Get("journal") == "true"
+		encrypt = options.Get("encrypt") == "true"
+		checksums = options.Get("checksums") == "true"
+		readonly = options.Get("readonly") == "true"
+
+		if timeout := options.Get("lock_timeout"); timeout != "" {
+			lockTimeout, er = time.ParseDuration(timeout)
+			if er != nil {
+				return nil, errors.NewFileDatastoreError(er, "Invalid lock_timeout "+timeout)
+			}
+		}
+	}
+
 	path, er := filepath.Abs(path)
 	if er != nil {
 		return nil, errors.NewFileDatastoreError(er, "")
 	}
 
-	fs := &store{path: path}
+	fs := &store{path: path, manifests: manifests, journals: journals, checksums: checksums,
+		readonly: readonly, lockTimeout: lockTimeout}
+
+	if encrypt {
+		key, er := _KEY_PROVIDER()
+		if er == nil {
+			fs.cipher, er = newFileCipher(key)
+		}
+		if er != nil {
+			return nil, errors.NewFileDatastoreError(er, "")
+		}
+	}
 
 	e = fs.loadNamespaces()
 	if e != nil {
 		return
 	}
 
+	fs.roles, e = newRoleCatalog(filepath.Join(path, ROLES_FILE), readonly)
+	if e != nil {
+		return
+	}
+
 	s = fs
 	return
 }
@@ -126,10 +207,13 @@ func (s *store) loadNamespaces() (e errors.Error) {
 
 // namespace represents a file-based Namespace.
 type namespace struct {
+	sync.RWMutex  // Keyspaces are added and removed by CreateKeyspace and DropKeyspace
 	store         *store
 	name          string
 	keyspaces     map[string]*keyspace
 	keyspaceNames []string
+	bucketNames   []string
+	scopes        map[string][]string // Scope names of the buckets holding scopes
 }
 
 func (p *namespace) DatastoreId() string {
@@ -149,6 +233,8 @@ func (p *namespace) KeyspaceIds() ([]string, errors.Error) {
 }
 
 func (p *namespace) KeyspaceNames() ([]string, errors.Error) {
+	p.RLock()
+	defer p.RUnlock()
 	return p.keyspaceNames, nil
 }
 
@@ -157,6 +243,13 @@ func (p *namespace) KeyspaceById(id string) (b datastore.Keyspace, e errors.Erro
 }
 
 func (p *namespace) KeyspaceByName(name string) (b datastore.Keyspace, e errors.Error) {
+	if _, ok := p.scopes[strings.ToUpper(name)]; ok {
+		name = datastore.KeyspacePath(name, datastore.DEFAULT_SCOPE, datastore.DEFAULT_COLLECTION)
+	}
+
+	p.RLock()
+	defer p.RUnlock()
+
 	b, ok := p.keyspaces[strings.ToUpper(name)]
 	if !ok {
 		e = errors.NewFileKeyspaceNotFoundError(nil, name)
@@ -165,6 +258,79 @@ func (p *namespace) KeyspaceByName(name string) (b datastore.Keyspace, e errors.
 	return
 }
 
+/*
+A keyspace is created as an empty directory of the namespace. Its
+name must not contain dots or path separators, so that it is not
+taken for the index or journal directories, and must not be that of
+a temporary keyspace.
+*/
+func (p *namespace) CreateKeyspace(name string) (datastore.Keyspace, errors.Error) {
+	if name == "" || strings.ContainsAny(name, "./\\") || datastore.IsTemporary(name) {
+		return nil, errors.NewFileDatastoreError(nil, "Invalid keyspace name "+name)
+	}
+
+	if e := p.store.writable("namespace " + p.name); e != nil {
+		return nil, e
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	nameu := strings.ToUpper(name)
+	if _, ok := p.keyspaces[nameu]; ok {
+		return nil, errors.NewFileDuplicateKeyspaceError(nil, name)
+	}
+
+	if _, ok := p.scopes[nameu]; ok {
+		return nil, errors.NewFileDuplicateKeyspaceError(nil, name)
+	}
+
+	er := os.Mkdir(filepath.Join(p.path(), name), 0755)
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	e := p.addKeyspace(name, name)
+	if e != nil {
+		os.Remove(filepath.Join(p.path(), name))
+		return nil, e
+	}
+
+	return p.keyspaces[nameu], nil
+}
+
+// Only keyspaces outside buckets can be dropped.
+func (p *namespace) DropKeyspace(name string) errors.Error {
+	if e := p.store.writable("namespace " + p.name); e != nil {
+		return e
+	}
+
+	p.Lock()
+	defer p.Unlock()
+
+	nameu := strings.ToUpper(name)
+	b, ok := p.keyspaces[nameu]
+	if !ok || b.dir != b.name {
+		return errors.NewFileKeyspaceNotFoundError(nil, name)
+	}
+
+	er := os.RemoveAll(b.path())
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	delete(p.keyspaces, nameu)
+	names := make([]string, 0, len(p.keyspaceNames))
+	for _, n := range p.keyspaceNames {
+		if n != b.name {
+			names = append(names, n)
+		}
+	}
+
+	p.keyspaceNames = names
+	return nil
+}
+
 func (p *namespace) path() string {
 	return filepath.Join(p.store.path, p.name)
 }
@@ -187,34 +353,155 @@ func (p *namespace) loadKeyspaces() (e errors.Error) {
 
 	p.keyspaces = make(map[string]*keyspace, len(dirEntries))
 	p.keyspaceNames = make([]string, 0, len(dirEntries))
+	p.scopes = make(map[string][]string)
+	p.bucketNames = make([]string, 0)
 
-	var b *keyspace
 	for _, dirEntry := range dirEntries {
 		if dirEntry.IsDir() {
-			diru := strings.ToUpper(dirEntry.Name())
-			if _, ok := p.keyspaces[diru]; ok {
-				return errors.NewFileDuplicateKeyspaceError(nil, dirEntry.Name())
+			scoped, er := isBucketDir(filepath.Join(p.path(), dirEntry.Name()))
+			if er != nil {
+				return errors.NewFileDatastoreError(er, "")
+			}
+
+			if scoped {
+				e = p.loadBucket(dirEntry.Name())
+			} else {
+				e = p.addKeyspace(dirEntry.Name(), dirEntry.Name())
 			}
 
-			b, e = newKeyspace(p, dirEntry.Name())
 			if e != nil {
 				return
 			}
-
-			p.keyspaces[diru] = b
-			p.keyspaceNames = append(p.keyspaceNames, b.Name())
 		}
 	}
 
 	return
 }
 
+/*
+A bucket directory holds scope directories, which hold collection
+directories. It is told from a keyspace directory by holding no
+documents, only directories.
+*/
+func isBucketDir(path string) (bool, error) {
+	dirEntries, er := ioutil.ReadDir(path)
+	if er != nil {
+		return false, er
+	}
+
+	scoped := false
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			return false, nil
+		}
+
+		switch dirEntry.Name() {
+		case INDEX_DIR, JOURNAL_DIR, CHECKSUM_DIR, QUARANTINE_DIR:
+		default:
+			scoped = true
+		}
+	}
+
+	return scoped, nil
+}
+
+// Each collection of the bucket is a keyspace named by its path.
+func (p *namespace) loadBucket(bucket string) errors.Error {
+	bucketu := strings.ToUpper(bucket)
+	if _, ok := p.scopes[bucketu]; ok {
+		return errors.NewFileDuplicateKeyspaceError(nil, bucket)
+	}
+
+	scopeEntries, er := ioutil.ReadDir(filepath.Join(p.path(), bucket))
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	scopes := make([]string, 0, len(scopeEntries))
+	for _, scopeEntry := range scopeEntries {
+		scope := scopeEntry.Name()
+		if scope == INDEX_DIR {
+			continue
+		}
+
+		collectionEntries, er := ioutil.ReadDir(filepath.Join(p.path(), bucket, scope))
+		if er != nil {
+			return errors.NewFileDatastoreError(er, "")
+		}
+
+		for _, collectionEntry := range collectionEntries {
+			if collectionEntry.IsDir() {
+				collection := collectionEntry.Name()
+				e := p.addKeyspace(datastore.KeyspacePath(bucket, scope, collection),
+					filepath.Join(bucket, scope, collection))
+				if e != nil {
+					return e
+				}
+			}
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	p.scopes[bucketu] = scopes
+	p.bucketNames = append(p.bucketNames, bucket)
+	return nil
+}
+
+func (p *namespace) addKeyspace(name, dir string) errors.Error {
+	nameu := strings.ToUpper(name)
+	if _, ok := p.keyspaces[nameu]; ok {
+		return errors.NewFileDuplicateKeyspaceError(nil, name)
+	}
+
+	b, e := newKeyspace(p, name, dir)
+	if e != nil {
+		return e
+	}
+
+	p.keyspaces[nameu] = b
+	names := make([]string, len(p.keyspaceNames), len(p.keyspaceNames)+1)
+	copy(names, p.keyspaceNames)
+	p.keyspaceNames = append(names, b.Name())
+	return nil
+}
+
+// scopedNamespace is a file-based namespace with buckets holding scopes.
+type scopedNamespace struct {
+	*namespace
+}
+
+func (p *scopedNamespace) BucketNames() ([]string, errors.Error) {
+	return p.bucketNames, nil
+}
+
+func (p *scopedNamespace) ScopeNames(bucket string) ([]string, errors.Error) {
+	scopes, ok := p.scopes[strings.ToUpper(bucket)]
+	if !ok {
+		return nil, errors.NewFileKeyspaceNotFoundError(nil, bucket)
+	}
+
+	return scopes, nil
+}
+
 // keyspace is a file-based keyspace.
 type keyspace struct {
+	// Aligned ints need to be delared right at the top
+	// of the struct to avoid alignment issues on x86 platforms
+	seqno atomic.AlignedUint64 // Number of mutations
+
 	namespace *namespace
 	name      string
-	fi        datastore.Indexer
+	dir       string // Directory within the namespace
+	fi        *fileIndexer
 	fileLock  sync.Mutex
+	guard     string    // Changes whenever the keyspace is loaded
+	manifest  *manifest // Sorted keys, if the store keeps manifests
+
+	// Guarded by fileLock
+	generation uint64                // Mutation batches applied
+	snapshots  map[string]*snapshot  // Open snapshots by request id
+	preImages  map[string][]preImage // Pre-images read by open snapshots
 }
 
 func (b *keyspace) NamespaceId() string {
@@ -234,7 +521,39 @@ func (b *keyspace) Count() (int64, errors.Error) {
 	if er != nil {
 		return 0, errors.NewFileDatastoreError(er, "")
 	}
-	return int64(len(dirEntries)), nil
+
+	var count int64
+	for _, entry := range dirEntries {
+		if !entry.IsDir() {
+			count++
+		}
+	}
+	return count, nil
+}
+
+func (b *keyspace) Size() (int64, errors.Error) {
+	dirEntries, er := ioutil.ReadDir(b.path())
+	if er != nil {
+		return 0, errors.NewFileDatastoreError(er, "")
+	}
+
+	var size int64
+	for _, entry := range dirEntries {
+		if !entry.IsDir() {
+			size += entry.Size()
+		}
+	}
+	return size, nil
+}
+
+func (b *keyspace) Capabilities() datastore.Capabilities {
+	rv := datastore.CAPABILITY_SECONDARY_INDEX | datastore.CAPABILITY_COUNT | datastore.CAPABILITY_PROJECTION |
+		datastore.CAPABILITY_SNAPSHOT | datastore.CAPABILITY_FEED | datastore.CAPABILITY_SIZE
+	if !b.namespace.store.readonly {
+		rv |= datastore.CAPABILITY_DML | datastore.CAPABILITY_CAS | datastore.CAPABILITY_DURABILITY
+	}
+
+	return rv
 }
 
 func (b *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
@@ -246,6 +565,12 @@ func (b *keyspace) Indexers() ([]datastore.Indexer, errors.Error) {
 }
 
 func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	unlock, err := b.lock(false)
+	if err != nil {
+		return nil, []errors.Error{err}
+	}
+	defer unlock()
+
 	var errs []errors.Error
 	rv := make([]datastore.AnnotatedPair, 0, len(keys))
 	for _, k := range keys {
@@ -263,12 +588,6 @@ func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Err
 			continue
 		}
 
-		if item != nil {
-			item.SetAttachment("meta", map[string]interface{}{
-				"id": k,
-			})
-		}
-
 		rv = append(rv, datastore.AnnotatedPair{
 			Key:   k,
 			Value: item,
@@ -279,13 +598,20 @@ func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Err
 }
 
 func (b *keyspace) fetchOne(key string) (value.AnnotatedValue, errors.Error) {
-	path := filepath.Join(b.path(), key+".json")
-	item, e := fetch(path)
+	// Stat first, so that a concurrent write changes the CAS
+	fi, er := os.Stat(filepath.Join(b.path(), key+".json"))
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	bytes, e := b.readDoc(key)
 	if e != nil {
-		item = nil
+		return nil, e
 	}
 
-	return item, e
+	doc := newDoc(key, bytes)
+	doc.GetAttachment("meta").(map[string]interface{})["cas"] = docCas(fi)
+	return doc, nil
 }
 
 const (
@@ -308,26 +634,44 @@ func opToString(op int) string {
 	return "unknown operation"
 }
 
-func (b *keyspace) performOp(op int, kvPairs []datastore.Pair) ([]datastore.Pair, errors.Error) {
+func (b *keyspace) performOp(op int, kvPairs []datastore.Pair,
+	durability datastore.Durability) ([]datastore.Pair, errors.Error) {
 
 	if len(kvPairs) == 0 {
 		return nil, errors.NewFileNoKeysInsertError(nil, "keyspace "+b.Name())
 	}
 
-	insertedKeys := make([]datastore.Pair, 0)
-	var returnErr errors.Error
-
 	// this lock can be mode more granular FIXME
 	b.fileLock.Lock()
 	defer b.fileLock.Unlock()
 
+	unlock, err := b.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	return b.writePairs(op, kvPairs, durability)
+}
+
+// Write the documents of a mutation; the caller holds the locks.
+func (b *keyspace) writePairs(op int, kvPairs []datastore.Pair,
+	durability datastore.Durability) ([]datastore.Pair, errors.Error) {
+
+	insertedKeys := make([]datastore.Pair, 0)
+	var returnErr errors.Error
+	var mutations []journalEntry
+	var written []string
+
 	for _, kv := range kvPairs {
 		var file *os.File
 		var err error
 
 		key := kv.Key
-		value, _ := json.Marshal(kv.Value.Actual())
+		doc, _ := json.Marshal(kv.Value.Actual())
+		value := b.cipher().seal(doc)
 		filename := filepath.Join(b.path(), key+".json")
+		b.recordPreImage(key)
 
 		switch op {
 
@@ -338,8 +682,7 @@ func (b *keyspace) performOp(op int, kvPairs []datastore.Pair) ([]datastore.Pair
 			} else {
 				// create and write the file
 				if file, err = os.Create(filename); err == nil {
-					_, err = file.Write(value)
-					file.Close()
+					err = writeDoc(file, value, durability)
 				}
 			}
 		case UPDATE:
@@ -347,57 +690,109 @@ func (b *keyspace) performOp(op int, kvPairs []datastore.Pair) ([]datastore.Pair
 			if _, err = os.Stat(filename); err == nil {
 				// open and write the file
 				if file, err = os.OpenFile(filename, os.O_TRUNC|os.O_RDWR, 0666); err == nil {
-					_, err = file.Write(value)
-					file.Close()
+					err = writeDoc(file, value, durability)
 				}
 			}
 
 		case UPSERT:
 			// open the file for writing, if doesn't exist then create
 			if file, err = os.OpenFile(filename, os.O_CREATE|os.O_TRUNC|os.O_RDWR, 0666); err == nil {
-				_, err = file.Write(value)
-				file.Close()
+				err = writeDoc(file, value, durability)
 			}
 		}
 
+		if err == nil {
+			err = b.writeChecksum(key, doc)
+		}
+
+		if err == nil && durability == datastore.DURABILITY_MUTATION {
+			err = syncPath(b.path())
+		}
+
 		if err != nil {
 			returnErr = errors.NewFileDMLError(returnErr, opToString(op)+" Failed "+err.Error())
 		} else {
 			insertedKeys = append(insertedKeys, kv)
+			written = append(written, filename)
+			b.fi.update(key, kv.Value)
+			mutations = append(mutations, journalEntry{Key: key, Op: opToString(op)})
 		}
 	}
 
+	if durability == datastore.DURABILITY_BATCH {
+		if err := syncPaths(b.path(), written); err != nil {
+			returnErr = errors.NewFileDMLError(returnErr, opToString(op)+" Failed "+err.Error())
+		}
+	}
+
+	b.generation++
+	b.journal(mutations)
+	b.mutated(len(insertedKeys))
 	return insertedKeys, returnErr
 
 }
 
 func (b *keyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(INSERT, inserts)
+	return b.performOp(INSERT, inserts, datastore.DURABILITY_NONE)
 }
 
 func (b *keyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(UPDATE, updates)
+	return b.performOp(UPDATE, updates, datastore.DURABILITY_NONE)
 }
 
 func (b *keyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
-	return b.performOp(UPSERT, upserts)
+	return b.performOp(UPSERT, upserts, datastore.DURABILITY_NONE)
 }
 
 func (b *keyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return b.performDelete(deletes, datastore.DURABILITY_NONE)
+}
+
+func (b *keyspace) performDelete(deletes []string, durability datastore.Durability) ([]string, errors.Error) {
 
 	var fileError []string
 	var deleted []string
+	var mutations []journalEntry
+
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	unlock, err := b.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
 	for _, key := range deletes {
 		filename := filepath.Join(b.path(), key+".json")
+		b.recordPreImage(key)
 		if err := os.Remove(filename); err != nil {
 			if !os.IsNotExist(err) {
 				fileError = append(fileError, err.Error())
 			}
 		} else {
+			b.removeChecksum(key)
+			if durability == datastore.DURABILITY_MUTATION {
+				if err := syncPath(b.path()); err != nil {
+					fileError = append(fileError, err.Error())
+				}
+			}
 			deleted = append(deleted, key)
+			b.fi.update(key, nil)
+			mutations = append(mutations, journalEntry{Key: key, Op: datastore.MUTATION_DELETE})
+		}
+	}
+
+	if durability == datastore.DURABILITY_BATCH && len(deleted) > 0 {
+		if err := syncPath(b.path()); err != nil {
+			fileError = append(fileError, err.Error())
 		}
 	}
 
+	b.generation++
+	b.journal(mutations)
+	b.mutated(len(deleted))
+
 	if len(fileError) > 0 {
 		errLine := fmt.Sprintf("Delete failed on some keys %v", fileError)
 		return deleted, errors.NewFileDatastoreError(nil, errLine)
@@ -409,15 +804,69 @@ func (b *keyspace) Delete(deletes []string) ([]string, errors.Error) {
 func (b *keyspace) Release() {
 }
 
+func (b *keyspace) mutated(count int) {
+	atomic.AddUint64(&b.seqno, uint64(count))
+}
+
+// The file keyspace has a single partition, and all mutations are
+// visible to scans as soon as they are applied.
+func (b *keyspace) vector() timestamp.Vector {
+	return timestamp.NewVector([]timestamp.Entry{
+		timestamp.NewEntry(0, b.guard, atomic.LoadUint64(&b.seqno)),
+	})
+}
+
+// Scans at AT_PLUS consistency cannot be satisfied by a vector the
+// keyspace has not reached, or one from a different history.
+func (b *keyspace) checkVector(cons datastore.ScanConsistency, vector timestamp.Vector) errors.Error {
+	if cons != datastore.AT_PLUS || vector == nil {
+		return nil
+	}
+
+	if !timestamp.Covers(b.vector(), vector) {
+		return errors.NewFileDatastoreError(nil,
+			fmt.Sprintf("Scan vector %v not reached by keyspace %s.", vector.Entries(), b.name))
+	}
+
+	return nil
+}
+
 func (b *keyspace) path() string {
-	return filepath.Join(b.namespace.path(), b.name)
+	return filepath.Join(b.namespace.path(), b.dir)
+}
+
+func (b *keyspace) cipher() *fileCipher {
+	return b.namespace.store.cipher
+}
+
+// Read the document of a key, decrypting and verifying it if needed.
+func (b *keyspace) readDoc(key string) ([]byte, errors.Error) {
+	path := filepath.Join(b.path(), key+".json")
+	doc, er := ioutil.ReadFile(path)
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	doc, er = b.cipher().open(doc)
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "Cannot decrypt "+path)
+	}
+
+	e := b.verifyChecksum(key, doc)
+	if e != nil {
+		return nil, e
+	}
+
+	return doc, nil
 }
 
 // newKeyspace creates a new keyspace.
-func newKeyspace(p *namespace, dir string) (b *keyspace, e errors.Error) {
+func newKeyspace(p *namespace, name, dir string) (b *keyspace, e errors.Error) {
 	b = new(keyspace)
 	b.namespace = p
-	b.name = dir
+	b.name = name
+	b.dir = dir
+	b.guard, _ = util.UUID()
 
 	fi, er := os.Stat(b.path())
 	if er != nil {
@@ -428,19 +877,25 @@ func newKeyspace(p *namespace, dir string) (b *keyspace, e errors.Error) {
 		return nil, errors.NewFileKeyspaceNotDirError(nil, "Keyspace path "+dir)
 	}
 
+	if p.store.manifests {
+		b.manifest = &manifest{}
+	}
+
 	b.fi = newFileIndexer(b)
 	b.fi.CreatePrimaryIndex("", "#primary", nil)
+	b.fi.loadIndexes()
 
 	return
 }
 
 type fileIndexer struct {
+	sync.RWMutex
 	keyspace *keyspace
 	indexes  map[string]datastore.Index
 	primary  datastore.PrimaryIndex
 }
 
-func newFileIndexer(keyspace *keyspace) datastore.Indexer {
+func newFileIndexer(keyspace *keyspace) *fileIndexer {
 
 	return &fileIndexer{
 		keyspace: keyspace,
@@ -457,6 +912,9 @@ func (fi *fileIndexer) Name() datastore.IndexType {
 }
 
 func (fi *fileIndexer) IndexIds() ([]string, errors.Error) {
+	fi.RLock()
+	defer fi.RUnlock()
+
 	rv := make([]string, 0, len(fi.indexes))
 	for name, _ := range fi.indexes {
 		rv = append(rv, name)
@@ -465,6 +923,9 @@ func (fi *fileIndexer) IndexIds() ([]string, errors.Error) {
 }
 
 func (fi *fileIndexer) IndexNames() ([]string, errors.Error) {
+	fi.RLock()
+	defer fi.RUnlock()
+
 	rv := make([]string, 0, len(fi.indexes))
 	for name, _ := range fi.indexes {
 		rv = append(rv, name)
@@ -477,6 +938,9 @@ func (fi *fileIndexer) IndexById(id string) (datastore.Index, errors.Error) {
 }
 
 func (fi *fileIndexer) IndexByName(name string) (datastore.Index, errors.Error) {
+	fi.RLock()
+	defer fi.RUnlock()
+
 	index, ok := fi.indexes[name]
 	if !ok {
 		return nil, errors.NewFileIdxNotFound(nil, name)
@@ -488,8 +952,25 @@ func (fi *fileIndexer) PrimaryIndexes() ([]datastore.PrimaryIndex, errors.Error)
 	return []datastore.PrimaryIndex{fi.primary}, nil
 }
 
+// The primary index, followed by the secondary indexes by name.
 func (fi *fileIndexer) Indexes() ([]datastore.Index, errors.Error) {
-	return []datastore.Index{fi.primary}, nil
+	fi.RLock()
+	defer fi.RUnlock()
+
+	names := make([]string, 0, len(fi.indexes))
+	for name, index := range fi.indexes {
+		if index != fi.primary {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	rv := make([]datastore.Index, 0, len(fi.indexes))
+	rv = append(rv, fi.primary)
+	for _, name := range names {
+		rv = append(rv, fi.indexes[name])
+	}
+	return rv, nil
 }
 
 func (fi *fileIndexer) CreatePrimaryIndex(requestId, name string, with value.Value) (
@@ -505,13 +986,115 @@ func (fi *fileIndexer) CreatePrimaryIndex(requestId, name string, with value.Val
 	return fi.primary, nil
 }
 
-func (b *fileIndexer) CreateIndex(requestId, name string, equalKey, rangeKey expression.Expressions,
+// Secondary indexes are built when they are created.
+func (fi *fileIndexer) CreateIndex(requestId, name string, equalKey, rangeKey expression.Expressions,
 	where expression.Expression, with value.Value) (datastore.Index, errors.Error) {
-	return nil, errors.NewFileNotSupported(nil, "CREATE INDEX is not supported for file-based datastore.")
+	if len(equalKey) > 0 {
+		return nil, errors.NewFileNotSupported(nil, "PARTITION BY is not supported for file-based datastore.")
+	}
+
+	b := fi.keyspace
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	unlock, err := b.lock(true)
+	if err != nil {
+		return nil, err
+	}
+	defer unlock()
+
+	fi.Lock()
+	if _, ok := fi.indexes[name]; ok {
+		fi.Unlock()
+		return nil, errors.NewFileDatastoreError(nil, "Index "+name+" already exists.")
+	}
+
+	index, err := newFileIndex(b, name, rangeKey, where)
+	if err == nil {
+		err = index.saveDefinition()
+	}
+	if err != nil {
+		fi.Unlock()
+		return nil, err
+	}
+
+	fi.indexes[name] = index
+	fi.Unlock()
+
+	err = index.build()
+	if err != nil {
+		return nil, err
+	}
+
+	return index, nil
+}
+
+// Rebuild secondary indexes from the documents, e.g. after the
+// documents were changed outside the engine.
+func (fi *fileIndexer) BuildIndexes(requestId string, names ...string) errors.Error {
+	b := fi.keyspace
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	unlock, err := b.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	for _, name := range names {
+		index, err := fi.IndexByName(name)
+		if err != nil {
+			return err
+		}
+
+		findex, ok := index.(*fileIndex)
+		if !ok {
+			return errors.NewFileNotSupported(nil, "BUILD INDEX is not supported for primary index "+name+".")
+		}
+
+		err = findex.build()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
 }
 
-func (b *fileIndexer) BuildIndexes(requestId string, names ...string) errors.Error {
-	return errors.NewFileNotSupported(nil, "BUILD INDEXES is not supported for file-based datastore.")
+func (fi *fileIndexer) loadIndexes() {
+	dirEntries, er := ioutil.ReadDir(filepath.Join(fi.keyspace.path(), INDEX_DIR))
+	if er != nil {
+		return
+	}
+
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+
+		index, err := loadFileIndex(fi.keyspace, dirEntry.Name())
+		if err != nil {
+			logging.Errorp("Index not loaded", logging.Pair{"index", dirEntry.Name()},
+				logging.Pair{"keyspace", fi.keyspace.name}, logging.Pair{"error", err})
+			continue
+		}
+
+		fi.indexes[index.name] = index
+	}
+}
+
+// Index a mutated document; a nil document is removed. Must be
+// called with the keyspace fileLock held.
+func (fi *fileIndexer) update(key string, doc value.Value) {
+	fi.RLock()
+	defer fi.RUnlock()
+
+	for _, index := range fi.indexes {
+		if index, ok := index.(*fileIndex); ok {
+			index.update(key, doc)
+		}
+	}
 }
 
 func (b *fileIndexer) Refresh() errors.Error {
@@ -578,6 +1161,11 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
+	if err := pi.keyspace.checkVector(cons, vector); err != nil {
+		conn.Error(err)
+		return
+	}
+
 	// For primary indexes, bounds must always be strings, so we
 	// can just enforce that directly
 	low, high := "", ""
@@ -606,76 +1194,79 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 		}
 	}
 
-	dirEntries, er := ioutil.ReadDir(pi.keyspace.path())
-	if er != nil {
-		conn.Error(errors.NewFileDatastoreError(er, ""))
+	keys, e := pi.keyspace.scanKeys(requestId)
+	if e != nil {
+		conn.Error(e)
 		return
 	}
 
-	var n int64 = 0
-	for _, dirEntry := range dirEntries {
+	// Seek to the low bound, and stop at the high bound
+	start := 0
+	if low != "" {
+		start = seekKeys(keys, low, span.Range.Inclusion)
+	}
 
-		fmt.Printf("Dir entry being scanned %v", dirEntry.Name())
-		if limit > 0 && n > limit {
+	var n int64 = 0
+	for _, id := range keys[start:] {
+		if limit > 0 && n >= limit {
 			break
 		}
 
-		id := documentPathToId(dirEntry.Name())
-
-		if low != "" &&
-			(id < low ||
-				(id == low && (span.Range.Inclusion&datastore.LOW == 0))) {
-			continue
-		}
-
-		low = ""
-
 		if high != "" &&
 			(id > high ||
 				(id == high && (span.Range.Inclusion&datastore.HIGH == 0))) {
 			break
 		}
 
-		if !dirEntry.IsDir() {
-			entry := datastore.IndexEntry{PrimaryKey: id}
-			conn.EntryChannel() <- &entry
-			n++
-		}
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, id)
+		n++
 	}
 }
 
 func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	pi.ScanEntriesOffset(requestId, 0, limit, cons, vector, conn)
+}
+
+// Documents are scanned in key order, so an offset skips the same
+// entries on every scan.
+func (pi *primaryIndex) ScanEntriesOffset(requestId string, offset, limit int64, cons datastore.ScanConsistency,
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
-	dirEntries, er := ioutil.ReadDir(pi.keyspace.path())
-	if er != nil {
-		conn.Error(errors.NewFileDatastoreError(er, ""))
+	if err := pi.keyspace.checkVector(cons, vector); err != nil {
+		conn.Error(err)
 		return
 	}
 
-	for i, dirEntry := range dirEntries {
-		if limit > 0 && int64(i) > limit {
-			break
-		}
-		if !dirEntry.IsDir() {
-			entry := datastore.IndexEntry{PrimaryKey: documentPathToId(dirEntry.Name())}
-			conn.EntryChannel() <- &entry
-		}
+	keys, e := pi.keyspace.scanKeys(requestId)
+	if e != nil {
+		conn.Error(e)
+		return
 	}
-}
 
-func fetch(path string) (item value.AnnotatedValue, e errors.Error) {
-	bytes, er := ioutil.ReadFile(path)
-	if er != nil {
-		return nil, errors.NewFileDatastoreError(er, "")
+	if offset >= int64(len(keys)) {
+		return
+	}
+
+	keys = keys[offset:]
+	if limit > 0 && limit < int64(len(keys)) {
+		keys = keys[:limit]
 	}
 
+	for _, id := range keys {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, id)
+	}
+}
+
+func newDoc(key string, bytes []byte) value.AnnotatedValue {
 	doc := value.NewAnnotatedValue(value.NewValue(bytes))
-	doc.SetAttachment("meta", map[string]interface{}{"id": documentPathToId(path)})
-	item = doc
+	doc.SetAttachment("meta", map[string]interface{}{
+		"id":   key,
+		"size": len(bytes),
+	})
 
-	return
+	return doc
 }
 
 func documentPathToId(p string) string {
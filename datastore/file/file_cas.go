@@ -0,0 +1,75 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"os"
+	"path/filepath"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+The CAS of a document is the modification time of its file, which
+changes with each mutation of the document. Updates with the CAS of
+their documents when fetched are not applied to documents written
+since.
+*/
+func docCas(fi os.FileInfo) uint64 {
+	return uint64(fi.ModTime().UnixNano())
+}
+
+func (b *keyspace) UpdateCas(updates []datastore.Pair) ([]datastore.Pair, []string, errors.Error) {
+	return b.performCasUpdate(updates, datastore.DURABILITY_NONE)
+}
+
+func (b *durableKeyspace) UpdateCas(updates []datastore.Pair) ([]datastore.Pair, []string, errors.Error) {
+	return b.performCasUpdate(updates, b.durability)
+}
+
+func (b *keyspace) performCasUpdate(updates []datastore.Pair,
+	durability datastore.Durability) ([]datastore.Pair, []string, errors.Error) {
+
+	if len(updates) == 0 {
+		return nil, nil, errors.NewFileNoKeysInsertError(nil, "keyspace "+b.Name())
+	}
+
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	unlock, err := b.lock(true)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer unlock()
+
+	var mismatched []string
+	matched := make([]datastore.Pair, 0, len(updates))
+	for _, kv := range updates {
+		cas := datastore.FetchedCas(kv.Value)
+		if cas != 0 {
+			fi, er := os.Stat(filepath.Join(b.path(), kv.Key+".json"))
+			if er == nil && docCas(fi) != cas {
+				mismatched = append(mismatched, kv.Key)
+				continue
+			}
+		}
+
+		matched = append(matched, kv)
+	}
+
+	if len(matched) == 0 {
+		return nil, mismatched, nil
+	}
+
+	updated, err := b.writePairs(UPDATE, matched, durability)
+	return updated, mismatched, err
+}
@@ -0,0 +1,111 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"hash/crc32"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+When the store is opened with the checksums option, the CRC32 of each
+document is kept in a sidecar file under the CHECKSUM_DIR directory of
+the keyspace. The checksum is of the JSON, before any encryption, so
+re-encrypting the store leaves it valid. A document that fails its
+checksum is moved to the QUARANTINE_DIR directory of the keyspace, and
+fetches report it as a warning rather than return it. Documents
+without a checksum, such as those written by other tools, are not
+verified.
+*/
+const (
+	CHECKSUM_DIR   = ".checksums"
+	QUARANTINE_DIR = ".quarantine"
+)
+
+const _CHECKSUM_EXT = ".crc"
+
+var _CHECKSUM_TABLE = crc32.MakeTable(crc32.Castagnoli)
+
+func (b *keyspace) checksumPath(key string) string {
+	return filepath.Join(b.path(), CHECKSUM_DIR, key+_CHECKSUM_EXT)
+}
+
+// Record the checksum of a document. Called under fileLock.
+func (b *keyspace) writeChecksum(key string, doc []byte) error {
+	if !b.namespace.store.checksums {
+		return nil
+	}
+
+	er := os.MkdirAll(filepath.Join(b.path(), CHECKSUM_DIR), 0755)
+	if er != nil {
+		return er
+	}
+
+	sum := fmt.Sprintf("%08x", crc32.Checksum(doc, _CHECKSUM_TABLE))
+	return ioutil.WriteFile(b.checksumPath(key), []byte(sum), 0666)
+}
+
+// Remove the checksum of a deleted document.
+func (b *keyspace) removeChecksum(key string) {
+	if b.namespace.store.checksums {
+		os.Remove(b.checksumPath(key))
+	}
+}
+
+// Verify a document against its checksum, quarantining it on mismatch.
+func (b *keyspace) verifyChecksum(key string, doc []byte) errors.Error {
+	if !b.namespace.store.checksums {
+		return nil
+	}
+
+	bytes, er := ioutil.ReadFile(b.checksumPath(key))
+	if er != nil {
+		if os.IsNotExist(er) {
+			return nil
+		}
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	sum, er := strconv.ParseUint(strings.TrimSpace(string(bytes)), 16, 32)
+	if er == nil && uint32(sum) == crc32.Checksum(doc, _CHECKSUM_TABLE) {
+		return nil
+	}
+
+	return b.quarantine(key)
+}
+
+// Move a corrupt document out of the keyspace, so that scans and
+// fetches no longer see it. A read-only store leaves it in place.
+func (b *keyspace) quarantine(key string) errors.Error {
+	if b.namespace.store.readonly {
+		return errors.NewFileCorruptDocumentError(key, b.name, "")
+	}
+
+	dir := filepath.Join(b.path(), QUARANTINE_DIR)
+	path := filepath.Join(dir, key+".json")
+
+	er := os.MkdirAll(dir, 0755)
+	if er == nil {
+		er = os.Rename(filepath.Join(b.path(), key+".json"), path)
+	}
+	if er != nil && !os.IsNotExist(er) {
+		return errors.NewFileDatastoreError(er, "Cannot quarantine "+key)
+	}
+
+	os.Remove(b.checksumPath(key))
+	return errors.NewFileCorruptDocumentError(key, b.name, path)
+}
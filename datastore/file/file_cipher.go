@@ -0,0 +1,154 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"os"
+	"strings"
+)
+
+/*
+Encryption at rest. A store opened with the encrypt option writes its
+document files, index definitions and index segments encrypted with
+AES-GCM. Encrypted files start with a header, which JSON cannot start
+with, so that the files written before encryption was enabled can
+still be read; they are encrypted when they are next written, or by
+Reencrypt. Each record of an index segment is encrypted on its own,
+and written in base64, so that segments can still be appended to.
+
+Journals and the roles file are not encrypted.
+*/
+
+// Environment variable holding the hex encoded key of the default
+// key provider.
+const KEY_ENV = "CBQ_FILE_KEY"
+
+var _SEALED = []byte("\x00cbqe1")
+
+/*
+A KeyProvider returns the key of a store opened with the encrypt
+option: 16, 24 or 32 bytes, for AES-128, AES-192 or AES-256. It may,
+for example, fetch the key from a key management service.
+*/
+type KeyProvider func() ([]byte, error)
+
+var _KEY_PROVIDER KeyProvider = EnvKey
+
+func SetKeyProvider(provider KeyProvider) {
+	_KEY_PROVIDER = provider
+}
+
+// The key in the CBQ_FILE_KEY environment variable.
+func EnvKey() ([]byte, error) {
+	return ParseKey(os.Getenv(KEY_ENV))
+}
+
+// Parse a hex encoded key.
+func ParseKey(s string) ([]byte, error) {
+	s = strings.TrimSpace(s)
+	if s == "" {
+		return nil, fmt.Errorf("No encryption key in %s.", KEY_ENV)
+	}
+
+	key, er := hex.DecodeString(s)
+	if er != nil {
+		return nil, fmt.Errorf("Encryption key is not hex encoded.")
+	}
+
+	return key, nil
+}
+
+type fileCipher struct {
+	aead cipher.AEAD
+}
+
+func newFileCipher(key []byte) (*fileCipher, error) {
+	block, er := aes.NewCipher(key)
+	if er != nil {
+		return nil, er
+	}
+
+	aead, er := cipher.NewGCM(block)
+	if er != nil {
+		return nil, er
+	}
+
+	return &fileCipher{aead: aead}, nil
+}
+
+// A nil cipher leaves the data in the clear.
+func (this *fileCipher) seal(plain []byte) []byte {
+	if this == nil {
+		return plain
+	}
+
+	size := len(_SEALED) + this.aead.NonceSize()
+	rv := make([]byte, size, size+len(plain)+this.aead.Overhead())
+	copy(rv, _SEALED)
+	nonce := rv[len(_SEALED):]
+	rand.Read(nonce)
+	return this.aead.Seal(rv, nonce, plain, nil)
+}
+
+func (this *fileCipher) open(data []byte) ([]byte, error) {
+	if !bytes.HasPrefix(data, _SEALED) {
+		return data, nil
+	}
+
+	if this == nil {
+		return nil, fmt.Errorf("File is encrypted, and the datastore was opened without encryption.")
+	}
+
+	data = data[len(_SEALED):]
+	if len(data) < this.aead.NonceSize() {
+		return nil, fmt.Errorf("Encrypted file is truncated.")
+	}
+
+	nonce := data[:this.aead.NonceSize()]
+	return this.aead.Open(nil, nonce, data[len(nonce):], nil)
+}
+
+// Records of index segments are lines.
+func (this *fileCipher) sealRecord(plain []byte) []byte {
+	if this == nil {
+		return plain
+	}
+
+	sealed := this.seal(plain)
+	rv := make([]byte, base64.StdEncoding.EncodedLen(len(sealed)))
+	base64.StdEncoding.Encode(rv, sealed)
+	return rv
+}
+
+func (this *fileCipher) openRecord(line []byte) ([]byte, error) {
+	line = bytes.TrimRight(line, "\n")
+	if len(line) == 0 || line[0] == '{' {
+		return line, nil
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(line)))
+	n, er := base64.StdEncoding.Decode(sealed, line)
+	if er != nil {
+		return nil, er
+	}
+
+	if !bytes.HasPrefix(sealed[:n], _SEALED) {
+		return nil, fmt.Errorf("Invalid encrypted record.")
+	}
+
+	return this.open(sealed[:n])
+}
@@ -0,0 +1,97 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"os"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+durableKeyspace is a keyspace whose mutations are flushed to stable
+storage before they are acknowledged: each document and the keyspace
+directory after each mutation, or once after each batch.
+*/
+type durableKeyspace struct {
+	*keyspace
+	durability datastore.Durability
+}
+
+func (b *keyspace) Durable(durability datastore.Durability) datastore.Keyspace {
+	if durability == datastore.DURABILITY_NONE {
+		return b
+	}
+
+	return &durableKeyspace{keyspace: b, durability: durability}
+}
+
+func (b *durableKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(INSERT, inserts, b.durability)
+}
+
+func (b *durableKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(UPDATE, updates, b.durability)
+}
+
+func (b *durableKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(UPSERT, upserts, b.durability)
+}
+
+func (b *durableKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return b.performDelete(deletes, b.durability)
+}
+
+// Write and close a document, flushing it if each mutation is durable.
+func writeDoc(file *os.File, doc []byte, durability datastore.Durability) error {
+	_, er := file.Write(doc)
+	if er == nil && durability == datastore.DURABILITY_MUTATION {
+		er = file.Sync()
+	}
+
+	cer := file.Close()
+	if er == nil {
+		er = cer
+	}
+
+	return er
+}
+
+// Flush a file or directory to stable storage.
+func syncPath(path string) error {
+	file, er := os.Open(path)
+	if er != nil {
+		return er
+	}
+
+	er = file.Sync()
+	cer := file.Close()
+	if er == nil {
+		er = cer
+	}
+
+	return er
+}
+
+// Flush the documents written by a batch, then their directory.
+func syncPaths(dir string, paths []string) error {
+	if len(paths) == 0 {
+		return nil
+	}
+
+	for _, path := range paths {
+		if er := syncPath(path); er != nil {
+			return er
+		}
+	}
+
+	return syncPath(dir)
+}
@@ -0,0 +1,59 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build !windows
+
+package file
+
+import (
+	"os"
+	"syscall"
+	"time"
+)
+
+// Take an advisory lock on a file or directory, waiting up to the
+// timeout for other processes to release it.
+func flock(path string, exclusive bool, timeout time.Duration) (*os.File, error) {
+	file, er := os.Open(path)
+	if er != nil {
+		return nil, er
+	}
+
+	how := syscall.LOCK_SH
+	if exclusive {
+		how = syscall.LOCK_EX
+	}
+
+	deadline := time.Now().Add(timeout)
+	for {
+		er = syscall.Flock(int(file.Fd()), how|syscall.LOCK_NB)
+		if er != syscall.EWOULDBLOCK && er != syscall.EINTR {
+			break
+		}
+
+		if !time.Now().Before(deadline) {
+			er = errLockTimeout
+			break
+		}
+
+		time.Sleep(_LOCK_POLL)
+	}
+
+	if er != nil {
+		file.Close()
+		return nil, er
+	}
+
+	return file, nil
+}
+
+func funlock(file *os.File) {
+	syscall.Flock(int(file.Fd()), syscall.LOCK_UN)
+	file.Close()
+}
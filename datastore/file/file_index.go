@@ -0,0 +1,858 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Secondary indexes of the file datastore are kept in memory and
+persisted under the INDEX_DIR directory of their keyspace, which is
+not read as a document. Each index has a definition and a sequence
+of segments. A segment is a log of records, one per line, each
+holding all the entries of one document; a document without entries
+is not in the index. The segments are replayed in order when the
+keyspace is loaded.
+
+Every mutation appends a record, and a new segment is started every
+SEGMENT_RECORDS records, so the segments fragment as documents
+change. Compaction rewrites the live entries into a single segment.
+*/
+const INDEX_DIR = ".indexes"
+
+const SEGMENT_RECORDS = 1024
+
+const (
+	_DEFINITION  = "index.json"
+	_SEGMENT_EXT = ".seg"
+	_TEMP_EXT    = ".tmp"
+)
+
+type indexDefinition struct {
+	Keys      []string `json:"keys"`
+	Condition string   `json:"condition,omitempty"`
+}
+
+/*
+Each key of an entry is encoded as an empty array if it is missing,
+or else as an array holding its value.
+*/
+type indexRecord struct {
+	Id      string            `json:"id"`
+	Entries [][][]interface{} `json:"entries,omitempty"`
+}
+
+type fileIndex struct {
+	sync.RWMutex
+	name          string
+	keyspace      *keyspace
+	rangeKey      expression.Expressions
+	condition     expression.Expression
+	keys          expression.Expressions    // Range keys formalized for evaluation
+	cond          expression.Expression     // Condition formalized for evaluation
+	state         datastore.IndexState      // BUILDING, ONLINE or OFFLINE
+	msg           string                    // Progress or failure of the last build or compaction
+	entries       map[string][]value.Values // Entries of each document
+	sorted        []*datastore.IndexEntry   // Entries in key order; nil when stale
+	segments      []int                     // Sequence numbers of the segments, ascending
+	next          int                       // Sequence number of the next segment
+	active        *os.File                  // Segment that records are appended to
+	activeRecords int
+	records       int // Records in all the segments
+	compacting    bool
+}
+
+func newFileIndex(b *keyspace, name string, rangeKey expression.Expressions,
+	condition expression.Expression) (*fileIndex, errors.Error) {
+	rv := &fileIndex{
+		name:      name,
+		keyspace:  b,
+		rangeKey:  rangeKey,
+		condition: condition,
+		state:     datastore.BUILDING,
+		entries:   make(map[string][]value.Values),
+		next:      1,
+	}
+
+	formalizer := expression.NewFormalizer()
+	formalizer.Keyspace = b.name
+
+	rv.keys = make(expression.Expressions, len(rangeKey))
+	for i, key := range rangeKey {
+		key, err := formalizer.Map(key.Copy())
+		if err != nil {
+			return nil, errors.NewFileDatastoreError(err, "")
+		}
+		rv.keys[i] = key
+	}
+
+	if condition != nil {
+		cond, err := formalizer.Map(condition.Copy())
+		if err != nil {
+			return nil, errors.NewFileDatastoreError(err, "")
+		}
+		rv.cond = cond
+	}
+
+	return rv, nil
+}
+
+// Load an index from its definition and segments. An index whose
+// segments cannot be replayed is OFFLINE until it is rebuilt.
+func loadFileIndex(b *keyspace, name string) (*fileIndex, errors.Error) {
+	path := filepath.Join(b.path(), INDEX_DIR, name, _DEFINITION)
+	bytes, er := ioutil.ReadFile(path)
+	if er == nil {
+		bytes, er = b.cipher().open(bytes)
+	}
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	var def indexDefinition
+	er = json.Unmarshal(bytes, &def)
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "Invalid index definition "+path)
+	}
+
+	rangeKey := make(expression.Expressions, len(def.Keys))
+	for i, key := range def.Keys {
+		rangeKey[i], er = parser.Parse(key)
+		if er != nil {
+			return nil, errors.NewFileDatastoreError(er, "Invalid index key "+key)
+		}
+	}
+
+	var condition expression.Expression
+	if def.Condition != "" {
+		condition, er = parser.Parse(def.Condition)
+		if er != nil {
+			return nil, errors.NewFileDatastoreError(er, "Invalid index condition "+def.Condition)
+		}
+	}
+
+	rv, err := newFileIndex(b, name, rangeKey, condition)
+	if err != nil {
+		return nil, err
+	}
+
+	err = rv.load()
+	if err != nil {
+		logging.Errorp("Index segments not loaded", logging.Pair{"index", name},
+			logging.Pair{"keyspace", b.name}, logging.Pair{"error", err})
+		rv.state = datastore.OFFLINE
+		rv.msg = err.Error()
+	} else {
+		rv.state = datastore.ONLINE
+	}
+
+	return rv, nil
+}
+
+func (this *fileIndex) KeyspaceId() string {
+	return this.keyspace.Id()
+}
+
+func (this *fileIndex) Id() string {
+	return this.Name()
+}
+
+func (this *fileIndex) Name() string {
+	return this.name
+}
+
+func (this *fileIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (this *fileIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (this *fileIndex) RangeKey() expression.Expressions {
+	return this.rangeKey
+}
+
+func (this *fileIndex) Condition() expression.Expression {
+	return this.condition
+}
+
+func (this *fileIndex) IsPrimary() bool {
+	return false
+}
+
+// The message reports the progress of a build or compaction, or
+// why the index is OFFLINE.
+func (this *fileIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+	return this.state, this.msg, nil
+}
+
+func (this *fileIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (this *fileIndex) Drop(requestId string) errors.Error {
+	b := this.keyspace
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	unlock, err := b.lock(true)
+	if err != nil {
+		return err
+	}
+	defer unlock()
+
+	b.fi.Lock()
+	delete(b.fi.indexes, this.name)
+	b.fi.Unlock()
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.closeSegment()
+	this.state = datastore.OFFLINE
+	er := os.RemoveAll(this.path())
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	return nil
+}
+
+/*
+Entry keys are compared as arrays, so a bound that is shorter than
+the entry keys compares below the entries it is a prefix of. The
+entries between the bounds are then filtered on the range of each
+key, if the span has them.
+*/
+func (this *fileIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	if err := this.keyspace.checkVector(cons, vector); err != nil {
+		conn.Error(err)
+		return
+	}
+
+	entries := this.sortedEntries()
+	low, high := span.Range.Low, span.Range.High
+	inclusion := span.Range.Inclusion
+
+	start := 0
+	if len(low) > 0 {
+		start = sort.Search(len(entries), func(i int) bool {
+			c := compareEntryKeys(entries[i].EntryKey, low)
+			return c > 0 || (c == 0 && inclusion&datastore.LOW != 0)
+		})
+	}
+
+	var n int64 = 0
+	for _, entry := range entries[start:] {
+		if limit > 0 && n >= limit {
+			break
+		}
+
+		if len(high) > 0 {
+			c := compareEntryKeys(entry.EntryKey, high)
+			if c > 0 || (c == 0 && inclusion&datastore.HIGH == 0) {
+				break
+			}
+		}
+
+		if len(span.Ranges) > 0 && !span.Ranges.Contains(entry.EntryKey) {
+			continue
+		}
+
+		conn.EntryChannel() <- &datastore.IndexEntry{
+			EntryKey:   entry.EntryKey,
+			PrimaryKey: entry.PrimaryKey,
+		}
+		n++
+	}
+}
+
+/*
+Rewrite the live entries into a single segment, replacing the
+segments that have accumulated. Mutations applied meanwhile go to
+later segments, which are replayed after the compacted one.
+*/
+func (this *fileIndex) Compact(requestId string) errors.Error {
+	if e := this.keyspace.namespace.store.writable("index " + this.name); e != nil {
+		return e
+	}
+
+	this.Lock()
+	if this.state != datastore.ONLINE || this.compacting {
+		this.Unlock()
+		return nil
+	}
+
+	if len(this.segments) <= 1 && this.records == len(this.entries) {
+		this.Unlock()
+		return nil
+	}
+
+	this.closeSegment()
+	old := len(this.segments)
+	seq := this.next
+	this.next++
+	records := this.records
+	snapshot := make(map[string][]value.Values, len(this.entries))
+	for id, entries := range this.entries {
+		snapshot[id] = entries
+	}
+	this.compacting = true
+	this.msg = fmt.Sprintf("Compacting %d segments.", old)
+	this.Unlock()
+
+	er := this.writeSegment(seq, snapshot, func(done, total int) {
+		this.Lock()
+		defer this.Unlock()
+		this.msg = fmt.Sprintf("Compacting %d segments: %d of %d documents written.", old, done, total)
+	})
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.compacting = false
+	if er != nil {
+		this.msg = "Compaction failed: " + er.Error()
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	for _, s := range this.segments[:old] {
+		os.Remove(this.segmentPath(s))
+	}
+
+	this.segments = append([]int{seq}, this.segments[old:]...)
+	this.records = len(snapshot) + this.records - records
+	this.msg = ""
+	return nil
+}
+
+/*
+Index all the documents of the keyspace, replacing the segments.
+Must be called with the keyspace fileLock held, so that no mutation
+is applied during the build.
+*/
+func (this *fileIndex) build() errors.Error {
+	this.Lock()
+	if this.compacting {
+		this.Unlock()
+		return errors.NewFileDatastoreError(nil, "Index "+this.name+" is being compacted.")
+	}
+
+	this.closeSegment()
+	this.state = datastore.BUILDING
+	this.msg = ""
+	old := len(this.segments)
+	seq := this.next
+	this.next++
+	this.Unlock()
+
+	err := this.buildSegment(seq)
+
+	this.Lock()
+	defer this.Unlock()
+
+	if err != nil {
+		this.state = datastore.OFFLINE
+		this.msg = "Build failed: " + err.Error()
+		return err
+	}
+
+	for _, s := range this.segments[:old] {
+		os.Remove(this.segmentPath(s))
+	}
+
+	this.segments = append([]int{seq}, this.segments[old:]...)
+	this.records = len(this.entries)
+	this.state = datastore.ONLINE
+	this.msg = ""
+	return nil
+}
+
+func (this *fileIndex) buildSegment(seq int) errors.Error {
+	ids, err := this.keyspace.keys()
+	if err != nil {
+		return err
+	}
+
+	entries := make(map[string][]value.Values, len(ids))
+	for i, id := range ids {
+		doc, err := this.keyspace.fetchOne(id)
+		if err != nil {
+			if os.IsNotExist(err.Cause()) {
+				continue
+			}
+			return err
+		}
+
+		if e := this.evaluate(id, doc); len(e) > 0 {
+			entries[id] = e
+		}
+
+		if (i+1)%SEGMENT_RECORDS == 0 {
+			this.Lock()
+			this.msg = fmt.Sprintf("Building: %d of %d documents indexed.", i+1, len(ids))
+			this.Unlock()
+		}
+	}
+
+	er := os.MkdirAll(this.path(), 0755)
+	if er == nil {
+		er = this.writeSegment(seq, entries, nil)
+	}
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	this.Lock()
+	this.entries = entries
+	this.sorted = nil
+	this.Unlock()
+	return nil
+}
+
+/*
+Index a mutated document; a nil document is removed. Must be called
+with the keyspace fileLock held. If its segment cannot be written,
+the index goes OFFLINE until it is rebuilt.
+*/
+func (this *fileIndex) update(id string, doc value.Value) {
+	var entries []value.Values
+	if doc != nil {
+		entries = this.evaluate(id, doc)
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.state == datastore.OFFLINE {
+		return
+	}
+
+	if _, ok := this.entries[id]; !ok && len(entries) == 0 {
+		return
+	}
+
+	this.apply(id, entries)
+	er := this.log(id, entries)
+	if er != nil {
+		logging.Errorp("Index segment not written", logging.Pair{"index", this.name},
+			logging.Pair{"keyspace", this.keyspace.name}, logging.Pair{"error", er})
+		this.closeSegment()
+		this.state = datastore.OFFLINE
+		this.msg = "Segment write failed: " + er.Error()
+	}
+}
+
+/*
+Returns the entries of a document. Documents whose leading key is
+missing, or which do not satisfy the condition, have no entries. An
+array key adds an entry for each distinct element.
+*/
+func (this *fileIndex) evaluate(id string, doc value.Value) []value.Values {
+	if av, ok := doc.(value.AnnotatedValue); ok {
+		doc = av.GetValue()
+	}
+
+	item := value.NewAnnotatedValue(doc)
+	item.SetAttachment("meta", map[string]interface{}{
+		"id": id,
+	})
+
+	scope := value.NewScopeValue(map[string]interface{}{this.keyspace.name: item}, nil)
+	context := expression.NewIndexContext()
+
+	if this.cond != nil {
+		cond, err := this.cond.Evaluate(scope, context)
+		if err != nil || !cond.Truth() {
+			return nil
+		}
+	}
+
+	entry := make(value.Values, len(this.keys))
+	array := -1
+	for i, key := range this.keys {
+		v, err := key.Evaluate(scope, context)
+		if err != nil || (i == 0 && v.Type() == value.MISSING) {
+			return nil
+		}
+
+		entry[i] = v
+		if _, ok := key.(*expression.Array); ok && array < 0 {
+			array = i
+		}
+	}
+
+	if array < 0 {
+		return []value.Values{entry}
+	}
+
+	elems, ok := entry[array].Actual().([]interface{})
+	if !ok {
+		return nil
+	}
+
+	rv := make([]value.Values, 0, len(elems))
+elems:
+	for _, elem := range elems {
+		ev := value.NewValue(elem)
+		for _, e := range rv {
+			if e[array].Collate(ev) == 0 {
+				continue elems
+			}
+		}
+
+		e := make(value.Values, len(entry))
+		copy(e, entry)
+		e[array] = ev
+		rv = append(rv, e)
+	}
+
+	return rv
+}
+
+func (this *fileIndex) sortedEntries() []*datastore.IndexEntry {
+	this.RLock()
+	sorted := this.sorted
+	this.RUnlock()
+
+	if sorted != nil {
+		return sorted
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.sorted == nil {
+		n := 0
+		for _, entries := range this.entries {
+			n += len(entries)
+		}
+
+		sorted = make([]*datastore.IndexEntry, 0, n)
+		for id, entries := range this.entries {
+			for _, entry := range entries {
+				sorted = append(sorted, &datastore.IndexEntry{EntryKey: entry, PrimaryKey: id})
+			}
+		}
+
+		sort.Sort(byEntryKey(sorted))
+		this.sorted = sorted
+	}
+
+	return this.sorted
+}
+
+// Must be called with the lock held.
+func (this *fileIndex) apply(id string, entries []value.Values) {
+	if len(entries) == 0 {
+		delete(this.entries, id)
+	} else {
+		this.entries[id] = entries
+	}
+
+	this.sorted = nil
+}
+
+func (this *fileIndex) path() string {
+	return filepath.Join(this.keyspace.path(), INDEX_DIR, this.name)
+}
+
+func (this *fileIndex) segmentPath(seq int) string {
+	return filepath.Join(this.path(), fmt.Sprintf("%08d%s", seq, _SEGMENT_EXT))
+}
+
+func (this *fileIndex) saveDefinition() errors.Error {
+	def := indexDefinition{
+		Keys: make([]string, len(this.rangeKey)),
+	}
+
+	for i, key := range this.rangeKey {
+		def.Keys[i] = key.String()
+	}
+
+	if this.condition != nil {
+		def.Condition = this.condition.String()
+	}
+
+	bytes, er := json.Marshal(def)
+	if er == nil {
+		er = os.MkdirAll(this.path(), 0755)
+	}
+	if er == nil {
+		er = ioutil.WriteFile(filepath.Join(this.path(), _DEFINITION), this.keyspace.cipher().seal(bytes), 0666)
+	}
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	return nil
+}
+
+// Replay the segments. Temporary files left by an interrupted build
+// or compaction are removed, unless the store is read-only.
+func (this *fileIndex) load() errors.Error {
+	dirEntries, er := ioutil.ReadDir(this.path())
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	for _, dirEntry := range dirEntries {
+		name := dirEntry.Name()
+		if strings.HasSuffix(name, _TEMP_EXT) {
+			if this.keyspace.namespace.store.readonly {
+				continue
+			}
+			os.Remove(filepath.Join(this.path(), name))
+		} else if strings.HasSuffix(name, _SEGMENT_EXT) {
+			seq, er := strconv.Atoi(strings.TrimSuffix(name, _SEGMENT_EXT))
+			if er == nil {
+				this.segments = append(this.segments, seq)
+			}
+		}
+	}
+
+	sort.Ints(this.segments)
+	for _, seq := range this.segments {
+		n, err := this.replay(seq)
+		if err != nil {
+			return err
+		}
+
+		this.records += n
+		this.next = seq + 1
+	}
+
+	return nil
+}
+
+// A last record without a newline was torn by a crash, and is
+// discarded. Records are never appended to a replayed segment.
+func (this *fileIndex) replay(seq int) (int, errors.Error) {
+	file, er := os.Open(this.segmentPath(seq))
+	if er != nil {
+		return 0, errors.NewFileDatastoreError(er, "")
+	}
+	defer file.Close()
+
+	reader := bufio.NewReader(file)
+	n := 0
+	for {
+		line, er := reader.ReadBytes('\n')
+		if er == io.EOF {
+			return n, nil
+		} else if er != nil {
+			return n, errors.NewFileDatastoreError(er, "")
+		}
+
+		var record indexRecord
+		line, er = this.keyspace.cipher().openRecord(line)
+		if er == nil {
+			er = json.Unmarshal(line, &record)
+		}
+		if er != nil {
+			return n, errors.NewFileDatastoreError(er,
+				fmt.Sprintf("Invalid record %d in segment %s", n+1, this.segmentPath(seq)))
+		}
+
+		this.apply(record.Id, decodeEntries(record.Entries))
+		n++
+	}
+}
+
+// Append a record to the active segment, starting a new segment if
+// it is full. Must be called with the lock held.
+func (this *fileIndex) log(id string, entries []value.Values) error {
+	if this.active == nil || this.activeRecords >= SEGMENT_RECORDS {
+		this.closeSegment()
+
+		seq := this.next
+		file, er := os.OpenFile(this.segmentPath(seq), os.O_CREATE|os.O_EXCL|os.O_WRONLY|os.O_APPEND, 0666)
+		if er != nil {
+			return er
+		}
+
+		this.next++
+		this.segments = append(this.segments, seq)
+		this.active = file
+		this.activeRecords = 0
+	}
+
+	bytes, er := json.Marshal(indexRecord{Id: id, Entries: encodeEntries(entries)})
+	if er != nil {
+		return er
+	}
+
+	bytes = this.keyspace.cipher().sealRecord(bytes)
+	_, er = this.active.Write(append(bytes, '\n'))
+	if er != nil {
+		return er
+	}
+
+	this.activeRecords++
+	this.records++
+	return nil
+}
+
+// Must be called with the lock held.
+func (this *fileIndex) closeSegment() {
+	if this.active != nil {
+		this.active.Close()
+		this.active = nil
+	}
+}
+
+// Write the entries to a new segment. The segment is written to a
+// temporary file, which is renamed once it is complete.
+func (this *fileIndex) writeSegment(seq int, entries map[string][]value.Values,
+	progress func(done, total int)) error {
+	ids := make([]string, 0, len(entries))
+	for id, _ := range entries {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	path := this.segmentPath(seq)
+	file, er := os.Create(path + _TEMP_EXT)
+	if er != nil {
+		return er
+	}
+
+	cipher := this.keyspace.cipher()
+	writer := bufio.NewWriter(file)
+	for i, id := range ids {
+		var bytes []byte
+		bytes, er = json.Marshal(indexRecord{Id: id, Entries: encodeEntries(entries[id])})
+		if er == nil {
+			_, er = writer.Write(append(cipher.sealRecord(bytes), '\n'))
+		}
+		if er != nil {
+			break
+		}
+
+		if progress != nil && (i+1)%SEGMENT_RECORDS == 0 {
+			progress(i+1, len(ids))
+		}
+	}
+
+	if er == nil {
+		er = writer.Flush()
+	}
+	if er == nil {
+		er = file.Sync()
+	}
+	if cer := file.Close(); er == nil {
+		er = cer
+	}
+	if er == nil {
+		er = os.Rename(path+_TEMP_EXT, path)
+	}
+	if er != nil {
+		os.Remove(path + _TEMP_EXT)
+	}
+
+	return er
+}
+
+func encodeEntries(entries []value.Values) [][][]interface{} {
+	if len(entries) == 0 {
+		return nil
+	}
+
+	rv := make([][][]interface{}, len(entries))
+	for i, entry := range entries {
+		keys := make([][]interface{}, len(entry))
+		for j, key := range entry {
+			if key.Type() == value.MISSING {
+				keys[j] = []interface{}{}
+			} else {
+				keys[j] = []interface{}{key.Actual()}
+			}
+		}
+		rv[i] = keys
+	}
+
+	return rv
+}
+
+func decodeEntries(encoded [][][]interface{}) []value.Values {
+	if len(encoded) == 0 {
+		return nil
+	}
+
+	rv := make([]value.Values, len(encoded))
+	for i, keys := range encoded {
+		entry := make(value.Values, len(keys))
+		for j, key := range keys {
+			if len(key) == 0 {
+				entry[j] = value.MISSING_VALUE
+			} else {
+				entry[j] = value.NewValue(key[0])
+			}
+		}
+		rv[i] = entry
+	}
+
+	return rv
+}
+
+// Compare entry keys as arrays.
+func compareEntryKeys(keys1, keys2 value.Values) int {
+	for i := 0; i < len(keys1) && i < len(keys2); i++ {
+		if c := keys1[i].Collate(keys2[i]); c != 0 {
+			return c
+		}
+	}
+
+	return len(keys1) - len(keys2)
+}
+
+type byEntryKey []*datastore.IndexEntry
+
+func (this byEntryKey) Len() int {
+	return len(this)
+}
+
+func (this byEntryKey) Less(i, j int) bool {
+	c := compareEntryKeys(this[i].EntryKey, this[j].EntryKey)
+	if c == 0 {
+		return this[i].PrimaryKey < this[j].PrimaryKey
+	}
+	return c < 0
+}
+
+func (this byEntryKey) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
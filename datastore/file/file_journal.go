@@ -0,0 +1,236 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/timestamp"
+)
+
+/*
+The journal of a keyspace is kept under the JOURNAL_DIR directory of
+the keyspace, when the store is opened with the journal option. Each
+mutation batch appends one JSON line per document, and mutation feeds
+tail the journal, so that they also observe the mutations of other
+processes sharing the directory.
+*/
+const JOURNAL_DIR = ".journal"
+
+const _JOURNAL_FILE = "mutations.jsonl"
+
+// How often feeds poll the journal for new mutations.
+var _JOURNAL_POLL = 50 * time.Millisecond
+
+type journalEntry struct {
+	Key   string `json:"key"`
+	Op    string `json:"op"`
+	Cas   uint64 `json:"cas"`
+	Guard string `json:"guard"`
+	Seqno uint64 `json:"seqno"`
+}
+
+func (b *keyspace) journalPath() string {
+	return filepath.Join(b.path(), JOURNAL_DIR, _JOURNAL_FILE)
+}
+
+/*
+Append the mutations of a batch, numbered after the mutations so far.
+Called under fileLock, once the documents are written, so a failure
+to journal is logged rather than failing the mutations.
+*/
+func (b *keyspace) journal(mutations []journalEntry) {
+	if !b.namespace.store.journals || len(mutations) == 0 {
+		return
+	}
+
+	seqno := atomic.LoadUint64(&b.seqno)
+	cas := uint64(time.Now().UnixNano())
+	buf := &bytes.Buffer{}
+	for i, m := range mutations {
+		m.Cas = cas
+		m.Guard = b.guard
+		m.Seqno = seqno + uint64(i) + 1
+		line, _ := json.Marshal(m)
+		buf.Write(line)
+		buf.WriteByte('\n')
+	}
+
+	er := os.MkdirAll(filepath.Dir(b.journalPath()), 0755)
+	if er == nil {
+		var file *os.File
+		file, er = os.OpenFile(b.journalPath(), os.O_CREATE|os.O_APPEND|os.O_WRONLY, 0644)
+		if er == nil {
+			_, er = file.Write(buf.Bytes())
+			if e := file.Close(); er == nil {
+				er = e
+			}
+		}
+	}
+
+	if er != nil {
+		logging.Errorp("Error writing keyspace journal", logging.Pair{"keyspace", b.name},
+			logging.Pair{"error", er})
+	}
+}
+
+/*
+Feeds start at the end of the journal, so they deliver the mutations
+journaled after they are opened.
+*/
+func (b *keyspace) Subscribe() (datastore.MutationFeed, errors.Error) {
+	if !b.namespace.store.journals {
+		return nil, errors.NewFileNotSupported(nil,
+			"- mutation feeds require the journal option of the store, for keyspace "+b.name)
+	}
+
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	var offset int64
+	info, er := os.Stat(b.journalPath())
+	if er == nil {
+		offset = info.Size()
+	} else if !os.IsNotExist(er) {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	feed := &journalFeed{
+		path:      b.journalPath(),
+		offset:    offset,
+		mutations: make(chan *datastore.Mutation, 256),
+		stop:      make(chan bool),
+	}
+
+	go feed.tail()
+	return feed, nil
+}
+
+type journalFeed struct {
+	path      string
+	offset    int64
+	partial   []byte // An incomplete line read last
+	mutations chan *datastore.Mutation
+	stop      chan bool
+	stopOnce  sync.Once
+	err       errors.Error
+}
+
+func (this *journalFeed) Mutations() <-chan *datastore.Mutation {
+	return this.mutations
+}
+
+// Valid once the mutations channel is closed.
+func (this *journalFeed) Err() errors.Error {
+	return this.err
+}
+
+func (this *journalFeed) Close() {
+	this.stopOnce.Do(func() { close(this.stop) })
+}
+
+func (this *journalFeed) tail() {
+	defer close(this.mutations)
+
+	for {
+		er := this.read()
+		if er != nil {
+			this.err = errors.NewFileDatastoreError(er, "")
+			return
+		}
+
+		select {
+		case <-this.stop:
+			return
+		case <-time.After(_JOURNAL_POLL):
+		}
+	}
+}
+
+// Send the lines appended since the last read.
+func (this *journalFeed) read() error {
+	file, er := os.Open(this.path)
+	if os.IsNotExist(er) {
+		return nil
+	} else if er != nil {
+		return er
+	}
+	defer file.Close()
+
+	info, er := file.Stat()
+	if er != nil {
+		return er
+	}
+
+	// The journal was truncated or replaced
+	if info.Size() < this.offset {
+		this.offset = 0
+		this.partial = nil
+	}
+
+	if info.Size() == this.offset {
+		return nil
+	}
+
+	_, er = file.Seek(this.offset, io.SeekStart)
+	if er != nil {
+		return er
+	}
+
+	data := make([]byte, info.Size()-this.offset)
+	n, er := io.ReadFull(file, data)
+	if er != nil && er != io.ErrUnexpectedEOF {
+		return er
+	}
+
+	this.offset += int64(n)
+	data = append(this.partial, data[:n]...)
+	end := bytes.LastIndexByte(data, '\n')
+	this.partial = append([]byte(nil), data[end+1:]...)
+
+	for _, line := range bytes.Split(data[:end+1], []byte{'\n'}) {
+		if len(line) == 0 {
+			continue
+		}
+
+		var entry journalEntry
+		er = json.Unmarshal(line, &entry)
+		if er != nil {
+			return er
+		}
+
+		mutation := &datastore.Mutation{
+			Key: entry.Key,
+			Op:  entry.Op,
+			Cas: entry.Cas,
+			Vector: timestamp.NewVector([]timestamp.Entry{
+				timestamp.NewEntry(0, entry.Guard, entry.Seqno),
+			}),
+		}
+
+		select {
+		case this.mutations <- mutation:
+		case <-this.stop:
+			return nil
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,60 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Processes sharing a directory serialize their changes to a keyspace
+with an advisory lock on the keyspace directory: mutations and index
+changes hold it exclusively, and fetches shared, so that no document
+is read while another process writes it. A lock that is not had
+within the lock timeout of the store fails the operation. A store
+opened read-only takes no locks, and refuses all changes.
+*/
+const _LOCK_TIMEOUT = 10 * time.Second
+
+// How often a lock held by another process is tried again.
+var _LOCK_POLL = 10 * time.Millisecond
+
+var errLockTimeout = fmt.Errorf("lock timeout")
+
+// Lock the keyspace, returning the function to unlock it.
+func (b *keyspace) lock(exclusive bool) (func(), errors.Error) {
+	store := b.namespace.store
+	if store.readonly {
+		if exclusive {
+			return nil, errors.NewFileReadonlyError(nil, "- cannot change keyspace "+b.name)
+		}
+		return func() {}, nil
+	}
+
+	file, er := flock(b.path(), exclusive, store.lockTimeout)
+	if er == errLockTimeout {
+		return nil, errors.NewFileLockTimeoutError(nil, b.name)
+	} else if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	return func() { funlock(file) }, nil
+}
+
+func (s *store) writable(what string) errors.Error {
+	if s.readonly {
+		return errors.NewFileReadonlyError(nil, "- cannot change "+what)
+	}
+
+	return nil
+}
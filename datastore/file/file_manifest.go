@@ -0,0 +1,99 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+manifest is the sorted list of the keys of a keyspace, kept when the
+store is opened with the manifest option. It is rebuilt when the
+keyspace directory changes, or after a mutation by this process, so
+scans that seek within it do not need to list the directory.
+*/
+type manifest struct {
+	sync.Mutex
+	keys    []string
+	modTime time.Time
+	seqno   uint64
+}
+
+/*
+Returns the keys of the keyspace in ascending order. The directory
+listing is in filename order, which is not key order when one key is
+a prefix of another, so the keys are sorted after the extension is
+removed.
+*/
+func (b *keyspace) keys() ([]string, errors.Error) {
+	if b.manifest == nil {
+		return b.readKeys()
+	}
+
+	info, er := os.Stat(b.path())
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	seqno := atomic.LoadUint64(&b.seqno)
+
+	b.manifest.Lock()
+	defer b.manifest.Unlock()
+
+	if b.manifest.keys != nil && b.manifest.seqno == seqno &&
+		b.manifest.modTime.Equal(info.ModTime()) {
+		return b.manifest.keys, nil
+	}
+
+	keys, e := b.readKeys()
+	if e != nil {
+		return nil, e
+	}
+
+	b.manifest.keys = keys
+	b.manifest.modTime = info.ModTime()
+	b.manifest.seqno = seqno
+	return keys, nil
+}
+
+func (b *keyspace) readKeys() ([]string, errors.Error) {
+	dirEntries, er := ioutil.ReadDir(b.path())
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	keys := make([]string, 0, len(dirEntries))
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			keys = append(keys, documentPathToId(dirEntry.Name()))
+		}
+	}
+
+	sort.Strings(keys)
+	return keys, nil
+}
+
+// Returns the position of the first key within the low bound.
+func seekKeys(keys []string, low string, inclusion datastore.Inclusion) int {
+	i := sort.SearchStrings(keys, low)
+	if i < len(keys) && keys[i] == low && inclusion&datastore.LOW == 0 {
+		i++
+	}
+
+	return i
+}
@@ -0,0 +1,175 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"bufio"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Rewrite the document files, index definitions and index segments of
+the datastore at path with the new key, for key rotation. Files are
+decrypted with the old key; a nil old key reads files in the clear,
+and a nil new key writes them in the clear. Files already written
+with the new key are left as they are, so that an interrupted rewrite
+can be run again. The datastore must not be open while it is
+rewritten. Returns the number of files rewritten.
+*/
+func Reencrypt(path string, oldKey, newKey []byte) (int, errors.Error) {
+	var oldCipher, newCipher *fileCipher
+	var er error
+	if oldKey != nil {
+		oldCipher, er = newFileCipher(oldKey)
+		if er != nil {
+			return 0, errors.NewFileDatastoreError(er, "Invalid old key")
+		}
+	}
+	if newKey != nil {
+		newCipher, er = newFileCipher(newKey)
+		if er != nil {
+			return 0, errors.NewFileDatastoreError(er, "Invalid new key")
+		}
+	}
+
+	dirEntries, er := ioutil.ReadDir(path)
+	if er != nil {
+		return 0, errors.NewFileDatastoreError(er, "")
+	}
+
+	n := 0
+	for _, dirEntry := range dirEntries {
+		if !dirEntry.IsDir() {
+			continue
+		}
+
+		er = filepath.Walk(filepath.Join(path, dirEntry.Name()),
+			func(p string, info os.FileInfo, er error) error {
+				if er != nil {
+					return er
+				}
+
+				name := info.Name()
+				if info.IsDir() {
+					switch name {
+					case JOURNAL_DIR, CHECKSUM_DIR, QUARANTINE_DIR:
+						return filepath.SkipDir
+					}
+					return nil
+				}
+
+				inIndex := filepath.Base(filepath.Dir(filepath.Dir(p))) == INDEX_DIR
+				var rewritten bool
+				switch {
+				case inIndex && strings.HasSuffix(name, _SEGMENT_EXT):
+					rewritten, er = reencryptSegment(p, oldCipher, newCipher)
+				case inIndex && name == _DEFINITION, !inIndex && strings.HasSuffix(name, ".json"):
+					rewritten, er = reencryptFile(p, oldCipher, newCipher)
+				}
+
+				if rewritten {
+					n++
+				}
+				return er
+			})
+		if er != nil {
+			return n, errors.NewFileDatastoreError(er, "")
+		}
+	}
+
+	return n, nil
+}
+
+// Data that the old cipher cannot open may already be written with
+// the new one.
+func reopen(data []byte, oldCipher, newCipher *fileCipher,
+	open func(*fileCipher, []byte) ([]byte, error)) ([]byte, bool, error) {
+	plain, er := open(oldCipher, data)
+	if er == nil {
+		return plain, true, nil
+	}
+
+	if _, ner := open(newCipher, data); ner == nil {
+		return nil, false, nil
+	}
+
+	return nil, false, er
+}
+
+func reencryptFile(path string, oldCipher, newCipher *fileCipher) (bool, error) {
+	data, er := ioutil.ReadFile(path)
+	if er != nil {
+		return false, er
+	}
+
+	plain, ok, er := reopen(data, oldCipher, newCipher, (*fileCipher).open)
+	if !ok {
+		return false, er
+	}
+
+	return true, replaceFile(path, newCipher.seal(plain))
+}
+
+// A last record without a newline was torn by a crash, and is
+// discarded, as replay would.
+func reencryptSegment(path string, oldCipher, newCipher *fileCipher) (bool, error) {
+	data, er := ioutil.ReadFile(path)
+	if er != nil {
+		return false, er
+	}
+
+	var buf bytes.Buffer
+	reader := bufio.NewReader(bytes.NewReader(data))
+	changed := false
+	for {
+		line, er := reader.ReadBytes('\n')
+		if er != nil {
+			break
+		}
+
+		plain, ok, er := reopen(line, oldCipher, newCipher, (*fileCipher).openRecord)
+		if er != nil {
+			return false, er
+		}
+
+		if ok {
+			line = append(newCipher.sealRecord(plain), '\n')
+			changed = true
+		}
+		buf.Write(line)
+	}
+
+	if !changed {
+		return false, nil
+	}
+
+	return true, replaceFile(path, buf.Bytes())
+}
+
+// The file is written to a temporary file, which is renamed once it
+// is complete.
+func replaceFile(path string, data []byte) error {
+	temp := path + _TEMP_EXT
+	er := ioutil.WriteFile(temp, data, 0666)
+	if er == nil {
+		er = os.Rename(temp, path)
+	}
+	if er != nil {
+		os.Remove(temp)
+	}
+
+	return er
+}
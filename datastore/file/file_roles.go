@@ -0,0 +1,143 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+// Roles are kept in this file at the root of the datastore, as an
+// array of users and their roles.
+const ROLES_FILE = "roles.json"
+
+// roleCatalog is a file-based RoleCatalog.
+type roleCatalog struct {
+	sync.RWMutex
+	path     string
+	readonly bool
+	users    map[string][]datastore.Role
+}
+
+func newRoleCatalog(path string, readonly bool) (*roleCatalog, errors.Error) {
+	rv := &roleCatalog{
+		path:     path,
+		readonly: readonly,
+		users:    make(map[string][]datastore.Role),
+	}
+
+	bytes, er := ioutil.ReadFile(path)
+	if os.IsNotExist(er) {
+		return rv, nil
+	} else if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	var infos []datastore.UserInfo
+	er = json.Unmarshal(bytes, &infos)
+	if er != nil {
+		return nil, errors.NewFileDatastoreError(er, "")
+	}
+
+	for _, info := range infos {
+		if len(info.Roles) > 0 {
+			rv.users[info.Id] = info.Roles
+		}
+	}
+
+	return rv, nil
+}
+
+func (this *roleCatalog) GrantRole(user string, role datastore.Role) errors.Error {
+	if this.readonly {
+		return errors.NewFileReadonlyError(nil, "- cannot change roles")
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	roles := this.users[user]
+	for _, r := range roles {
+		if r == role {
+			return nil
+		}
+	}
+
+	this.users[user] = append(roles, role)
+	return this.save()
+}
+
+func (this *roleCatalog) RevokeRole(user string, role datastore.Role) errors.Error {
+	if this.readonly {
+		return errors.NewFileReadonlyError(nil, "- cannot change roles")
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	roles := this.users[user]
+	for i, r := range roles {
+		if r == role {
+			rest := make([]datastore.Role, 0, len(roles)-1)
+			rest = append(rest, roles[:i]...)
+			rest = append(rest, roles[i+1:]...)
+			if len(rest) > 0 {
+				this.users[user] = rest
+			} else {
+				delete(this.users, user)
+			}
+			return this.save()
+		}
+	}
+
+	return nil
+}
+
+func (this *roleCatalog) Users() ([]datastore.UserInfo, errors.Error) {
+	this.RLock()
+	defer this.RUnlock()
+	return this.userInfo(), nil
+}
+
+func (this *roleCatalog) userInfo() []datastore.UserInfo {
+	ids := make([]string, 0, len(this.users))
+	for id, _ := range this.users {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+
+	rv := make([]datastore.UserInfo, len(ids))
+	for i, id := range ids {
+		rv[i] = datastore.UserInfo{Id: id, Roles: this.users[id]}
+	}
+
+	return rv
+}
+
+// Caller must hold the lock.
+func (this *roleCatalog) save() errors.Error {
+	bytes, er := json.MarshalIndent(this.userInfo(), "", "    ")
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	er = ioutil.WriteFile(this.path, bytes, 0666)
+	if er != nil {
+		return errors.NewFileDatastoreError(er, "")
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+func TestRoles(t *testing.T) {
+	dir, er := ioutil.TempDir("", "roles")
+	if er != nil {
+		t.Fatalf("failed to create directory: %v", er)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "default", "beer"), 0777)
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	read := datastore.Privileges{"default:beer": datastore.PRIV_READ}
+	if err := store.Authorize(read, nil); err != nil {
+		t.Errorf("expected no authorization without roles, got %v", err)
+	}
+
+	catalog, _ := store.RoleCatalog()
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "default:beer"})
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "default:beer"})
+
+	if err := store.Authorize(read, datastore.Credentials{"bob": ""}); err != nil {
+		t.Errorf("expected bob to be authorized, got %v", err)
+	}
+
+	if err := store.Authorize(read, datastore.Credentials{"alice": ""}); err == nil {
+		t.Errorf("expected alice not to be authorized")
+	}
+
+	write := datastore.Privileges{"default:beer": datastore.PRIV_WRITE}
+	if err := store.Authorize(write, datastore.Credentials{"bob": ""}); err == nil {
+		t.Errorf("expected bob not to be authorized to write")
+	}
+
+	// Roles are persisted
+	store, err = NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	catalog, _ = store.RoleCatalog()
+	users, _ := catalog.Users()
+	if len(users) != 1 || users[0].Id != "bob" || len(users[0].Roles) != 1 {
+		t.Errorf("unexpected users %v", users)
+	}
+
+	catalog.RevokeRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "default:beer"})
+	users, _ = catalog.Users()
+	if len(users) != 0 {
+		t.Errorf("expected no users, got %v", users)
+	}
+}
+
+func TestAdminRoles(t *testing.T) {
+	dir, er := ioutil.TempDir("", "roles")
+	if er != nil {
+		t.Fatalf("failed to create directory: %v", er)
+	}
+	defer os.RemoveAll(dir)
+
+	os.MkdirAll(filepath.Join(dir, "default", "beer"), 0777)
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	privileges := func(stmt string) datastore.Privileges {
+		s, er := n1ql.ParseStatement(stmt)
+		if er != nil {
+			t.Fatalf("failed to parse %s: %v", stmt, er)
+		}
+
+		privs, _ := s.Privileges()
+		return privs
+	}
+
+	grantAdmin := privileges("GRANT ROLE admin TO eve")
+	grantAll := privileges("GRANT ROLE query_select TO eve")
+	revokeAll := privileges("REVOKE ROLE query_select FROM eve")
+	grantBeer := privileges("GRANT ROLE query_select ON default:beer TO eve")
+
+	// Without roles, no one is an admin
+	if err := store.Authorize(grantAdmin, datastore.Credentials{"eve": ""}); err == nil {
+		t.Errorf("expected eve not to grant admin without roles")
+	}
+
+	catalog, _ := store.RoleCatalog()
+	catalog.GrantRole("root", datastore.Role{Name: datastore.ROLE_ADMIN})
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_MANAGE_INDEX})
+
+	for _, privs := range []datastore.Privileges{grantAdmin, grantAll, revokeAll} {
+		if err := store.Authorize(privs, datastore.Credentials{"bob": ""}); err == nil {
+			t.Errorf("expected bob not to be authorized for %v", privs)
+		}
+
+		if err := store.Authorize(privs, datastore.Credentials{"root": ""}); err != nil {
+			t.Errorf("expected root to be authorized for %v, got %v", privs, err)
+		}
+	}
+
+	if err := store.Authorize(grantBeer, datastore.Credentials{"bob": ""}); err != nil {
+		t.Errorf("expected bob to manage roles on default:beer, got %v", err)
+	}
+
+	if err := store.Authorize(grantBeer, datastore.Credentials{"eve": ""}); err == nil {
+		t.Errorf("expected eve not to manage roles on default:beer")
+	}
+}
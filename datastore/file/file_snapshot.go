@@ -0,0 +1,186 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package file
+
+import (
+	"os"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Snapshots are versioned by generation, the number of mutation batches
+applied to the keyspace. While any snapshot is open, each mutation
+first records the pre-image of the documents it changes, tagged with
+its generation. A snapshot taken at generation g reads a document from
+its first pre-image of generation g or later, and from the file if
+the document has not changed since.
+*/
+type snapshot struct {
+	generation uint64
+	keys       []string // Sorted keys as of the snapshot
+}
+
+type preImage struct {
+	generation uint64
+	doc        []byte // nil if the document did not exist
+}
+
+func (b *keyspace) OpenSnapshot(requestId string) errors.Error {
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	if _, ok := b.snapshots[requestId]; ok {
+		return nil
+	}
+
+	keys, e := b.readKeys()
+	if e != nil {
+		return e
+	}
+
+	if b.snapshots == nil {
+		b.snapshots = make(map[string]*snapshot, 4)
+		b.preImages = make(map[string][]preImage)
+	}
+
+	b.snapshots[requestId] = &snapshot{
+		generation: b.generation,
+		keys:       keys,
+	}
+
+	return nil
+}
+
+func (b *keyspace) ReleaseSnapshot(requestId string) {
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	delete(b.snapshots, requestId)
+	if len(b.snapshots) == 0 {
+		b.snapshots = nil
+		b.preImages = nil
+		return
+	}
+
+	// Drop the pre-images that no open snapshot reads
+	oldest := b.generation
+	for _, snap := range b.snapshots {
+		if snap.generation < oldest {
+			oldest = snap.generation
+		}
+	}
+
+	for key, images := range b.preImages {
+		i := 0
+		for i < len(images) && images[i].generation < oldest {
+			i++
+		}
+
+		if i == len(images) {
+			delete(b.preImages, key)
+		} else {
+			b.preImages[key] = images[i:]
+		}
+	}
+}
+
+func (b *keyspace) FetchSnapshot(requestId string, keys []string) (
+	[]datastore.AnnotatedPair, []errors.Error) {
+	b.fileLock.Lock()
+	defer b.fileLock.Unlock()
+
+	snap, ok := b.snapshots[requestId]
+	if !ok {
+		return b.Fetch(keys)
+	}
+
+	unlock, err := b.lock(false)
+	if err != nil {
+		return nil, []errors.Error{err}
+	}
+	defer unlock()
+
+	var errs []errors.Error
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		doc, found := b.snapshotDoc(snap, k)
+		if !found {
+			var e errors.Error
+			doc, e = b.readDoc(k)
+			if e != nil {
+				if !os.IsNotExist(e.Cause()) {
+					errs = append(errs, e)
+				}
+				continue
+			}
+		}
+
+		// The document did not exist at the snapshot
+		if doc == nil {
+			continue
+		}
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: newDoc(k, doc),
+		})
+	}
+
+	return rv, errs
+}
+
+// Scans see the keys of the request snapshot, if any.
+func (b *keyspace) scanKeys(requestId string) ([]string, errors.Error) {
+	b.fileLock.Lock()
+	snap, ok := b.snapshots[requestId]
+	b.fileLock.Unlock()
+
+	if ok {
+		return snap.keys, nil
+	}
+
+	return b.keys()
+}
+
+// Must be called with fileLock held.
+func (b *keyspace) snapshotDoc(snap *snapshot, key string) ([]byte, bool) {
+	for _, image := range b.preImages[key] {
+		if image.generation >= snap.generation {
+			return image.doc, true
+		}
+	}
+
+	return nil, false
+}
+
+// Record the pre-image of a document about to be mutated, if any
+// snapshot is open. Must be called with fileLock held.
+func (b *keyspace) recordPreImage(key string) {
+	if len(b.snapshots) == 0 {
+		return
+	}
+
+	images := b.preImages[key]
+	if n := len(images); n > 0 && images[n-1].generation == b.generation {
+		return
+	}
+
+	doc, er := b.readDoc(key)
+	if er != nil {
+		doc = nil
+	}
+
+	b.preImages[key] = append(images, preImage{
+		generation: b.generation,
+		doc:        doc,
+	})
+}
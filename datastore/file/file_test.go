@@ -11,11 +11,19 @@ package file
 
 import (
 	"fmt"
+	"io/ioutil"
 	"math"
+	"os"
+	"path/filepath"
+	"runtime"
 	"testing"
+	"time"
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/value"
 )
 
 func TestFile(t *testing.T) {
@@ -106,16 +114,30 @@ func TestFile(t *testing.T) {
 
 	go index.ScanEntries("", math.MaxInt64, datastore.UNBOUNDED, nil, conn)
 
+	var scanned []string
 	ok := true
 	for ok {
 		entry, ok := <-conn.EntryChannel()
 		if ok {
 			fmt.Printf("\nScanned %s", entry.PrimaryKey)
+			scanned = append(scanned, entry.PrimaryKey)
 		} else {
 			break
 		}
 	}
 
+	conn = datastore.NewIndexConnection(context)
+	go index.(datastore.OffsetPrimaryIndex).ScanEntriesOffset("", 1, 1, datastore.UNBOUNDED, nil, conn)
+
+	var skipped []string
+	for entry := range conn.EntryChannel() {
+		skipped = append(skipped, entry.PrimaryKey)
+	}
+
+	if len(scanned) < 2 || len(skipped) != 1 || skipped[0] != scanned[1] {
+		t.Errorf("Expected offset scan to return the second entry of %v, got %v", scanned, skipped)
+	}
+
 	freds, errs := keyspace.Fetch([]string{"fred"})
 	if errs != nil || len(freds) == 0 {
 		t.Errorf("failed to fetch fred: %v", errs)
@@ -180,6 +202,149 @@ func TestFile(t *testing.T) {
 
 }
 
+func TestFileScanSpan(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "keys")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	// Filename order differs from key order: a-b.json < a.json
+	for _, key := range []string{"a", "a-b", "ab", "b"} {
+		er = ioutil.WriteFile(filepath.Join(path, key+".json"), []byte("{}"), 0644)
+		if er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	for _, uri := range []string{dir, dir + "?manifest=true"} {
+		store, err := NewDatastore(uri)
+		if err != nil {
+			t.Fatalf("failed to create store %s: %v", uri, err)
+		}
+
+		namespace, _ := store.NamespaceByName("default")
+		keyspace, _ := namespace.KeyspaceByName("keys")
+		indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+		primaries, _ := indexer.PrimaryIndexes()
+
+		span := &datastore.Span{}
+		span.Range.Low = value.Values{value.NewValue("a")}
+		span.Range.High = value.Values{value.NewValue("b")}
+		span.Range.Inclusion = datastore.HIGH
+
+		conn := datastore.NewIndexConnection(&testingContext{t})
+		go primaries[0].Scan("", span, false, 0, datastore.UNBOUNDED, nil, conn)
+
+		var keys []string
+		for entry := range conn.EntryChannel() {
+			keys = append(keys, entry.PrimaryKey)
+		}
+
+		if fmt.Sprint(keys) != "[a-b ab b]" {
+			t.Errorf("Expected [a-b ab b] from %s, got %v", uri, keys)
+		}
+
+		_, err = keyspace.Insert([]datastore.Pair{{Key: "aa", Value: value.NewValue(map[string]interface{}{})}})
+		if err != nil {
+			t.Fatalf("failed to insert aa: %v", err)
+		}
+
+		conn = datastore.NewIndexConnection(&testingContext{t})
+		go primaries[0].Scan("", span, false, 2, datastore.UNBOUNDED, nil, conn)
+
+		keys = keys[:0]
+		for entry := range conn.EntryChannel() {
+			keys = append(keys, entry.PrimaryKey)
+		}
+
+		if fmt.Sprint(keys) != "[a-b aa]" {
+			t.Errorf("Expected [a-b aa] from %s after insert, got %v", uri, keys)
+		}
+
+		keyspace.Delete([]string{"aa"})
+	}
+}
+
+func TestFileSnapshot(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	for _, key := range []string{"a", "b"} {
+		er = ioutil.WriteFile(filepath.Join(path, key+".json"), []byte(`{"v":1}`), 0644)
+		if er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	docs, _ := namespace.KeyspaceByName("docs")
+	snapshots := docs.(datastore.SnapshotKeyspace)
+
+	err = snapshots.OpenSnapshot("r1")
+	if err != nil {
+		t.Fatalf("failed to open snapshot: %v", err)
+	}
+
+	v2 := value.NewValue(map[string]interface{}{"v": 2})
+	_, err = docs.Update([]datastore.Pair{{Key: "a", Value: v2}})
+	if err != nil {
+		t.Fatalf("failed to update a: %v", err)
+	}
+
+	_, err = docs.Insert([]datastore.Pair{{Key: "c", Value: v2}})
+	if err != nil {
+		t.Fatalf("failed to insert c: %v", err)
+	}
+
+	_, err = docs.Delete([]string{"b"})
+	if err != nil {
+		t.Fatalf("failed to delete b: %v", err)
+	}
+
+	keys, err := docs.(*keyspace).scanKeys("r1")
+	if err != nil || fmt.Sprint(keys) != "[a b]" {
+		t.Errorf("Expected snapshot keys [a b], got %v %v", keys, err)
+	}
+
+	pairs, errs := snapshots.FetchSnapshot("r1", []string{"a", "b", "c"})
+	if len(errs) > 0 || len(pairs) != 2 {
+		t.Fatalf("Expected a and b from snapshot, got %v %v", pairs, errs)
+	}
+
+	for _, pair := range pairs {
+		v, _ := pair.Value.Field("v")
+		if v.Actual() != 1.0 {
+			t.Errorf("Expected snapshot %s.v to be 1, got %v", pair.Key, v)
+		}
+	}
+
+	snapshots.ReleaseSnapshot("r1")
+
+	pairs, _ = snapshots.FetchSnapshot("r1", []string{"a", "b", "c"})
+	if len(pairs) != 2 || pairs[0].Key != "a" || pairs[1].Key != "c" {
+		t.Errorf("Expected a and c after release, got %v", pairs)
+	}
+}
+
 type testingContext struct {
 	t *testing.T
 }
@@ -195,3 +360,582 @@ func (this *testingContext) Warning(wrn errors.Error) {
 func (this *testingContext) Fatal(fatal errors.Error) {
 	this.t.Logf("scan fatal: %v", fatal)
 }
+
+func TestFileJournal(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	if er = os.MkdirAll(filepath.Join(dir, "default", "docs"), 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	// Two stores over the same directory, as in two processes
+	keyspaces := make([]datastore.Keyspace, 2)
+	for i := range keyspaces {
+		store, err := NewDatastore(dir + "?journal=true")
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+
+		namespace, _ := store.NamespaceByName("default")
+		keyspaces[i], _ = namespace.KeyspaceByName("docs")
+	}
+
+	feed, err := keyspaces[0].(datastore.FeedKeyspace).Subscribe()
+	if err != nil {
+		t.Fatalf("failed to subscribe: %v", err)
+	}
+	defer feed.Close()
+
+	v := value.NewValue(map[string]interface{}{"v": 1})
+	_, err = keyspaces[0].Insert([]datastore.Pair{{Key: "a", Value: v}})
+	if err == nil {
+		_, err = keyspaces[1].Upsert([]datastore.Pair{{Key: "b", Value: v}})
+	}
+	if err == nil {
+		_, err = keyspaces[0].Delete([]string{"a"})
+	}
+	if err != nil {
+		t.Fatalf("failed to mutate: %v", err)
+	}
+
+	for _, expected := range []string{"insert a", "upsert b", "delete a"} {
+		select {
+		case m := <-feed.Mutations():
+			if m.Op+" "+m.Key != expected {
+				t.Errorf("expected mutation %s, got %s %s", expected, m.Op, m.Key)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("expected mutation %s", expected)
+		}
+	}
+
+	count, _ := keyspaces[0].Count()
+	if count != 1 {
+		t.Errorf("expected the journal not to count as a document, got count %d", count)
+	}
+}
+
+func TestFileSize(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	docs := map[string]string{"a": `{"v":1}`, "b": `{"v":[1,2,3]}`}
+	for key, doc := range docs {
+		er = ioutil.WriteFile(filepath.Join(path, key+".json"), []byte(doc), 0644)
+		if er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	keyspace, _ := namespace.KeyspaceByName("docs")
+
+	size, err := keyspace.(datastore.SizedKeyspace).Size()
+	if err != nil || size != int64(len(docs["a"])+len(docs["b"])) {
+		t.Errorf("Unexpected keyspace size %v %v", size, err)
+	}
+
+	pairs, errs := keyspace.Fetch([]string{"b"})
+	if len(errs) > 0 || len(pairs) != 1 {
+		t.Fatalf("Expected b, got %v %v", pairs, errs)
+	}
+
+	meta := pairs[0].Value.GetAttachment("meta").(map[string]interface{})
+	if meta["id"] != "b" || meta["size"] != len(docs["b"]) {
+		t.Errorf("Unexpected meta %v", meta)
+	}
+}
+
+func TestFileIndex(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	for i := 0; i < 10; i++ {
+		doc := fmt.Sprintf(`{"v":%d}`, i)
+		er = ioutil.WriteFile(filepath.Join(path, fmt.Sprintf("k%d.json", i)), []byte(doc), 0644)
+		if er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	keyspace, _ := namespace.KeyspaceByName("docs")
+	indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+
+	key, _ := parser.Parse("v")
+	index, err := indexer.CreateIndex("", "iv", nil, expression.Expressions{key}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	// Fragment the index: every update appends a record
+	for i := 0; i < SEGMENT_RECORDS+10; i++ {
+		pair := datastore.Pair{
+			Key:   fmt.Sprintf("k%d", i%10),
+			Value: value.NewValue(map[string]interface{}{"v": i}),
+		}
+		if _, err = keyspace.Update([]datastore.Pair{pair}); err != nil {
+			t.Fatalf("failed to update: %v", err)
+		}
+	}
+	keyspace.Delete([]string{"k9"})
+
+	count, _ := keyspace.Count()
+	if count != 9 {
+		t.Errorf("Expected 9 documents, got %v", count)
+	}
+
+	fi := index.(*fileIndex)
+	if len(fi.segments) != 3 {
+		t.Errorf("Expected 3 segments, got %v", fi.segments)
+	}
+
+	err = index.(datastore.CompactableIndex).Compact("")
+	if err != nil {
+		t.Fatalf("failed to compact: %v", err)
+	}
+
+	if len(fi.segments) != 1 || fi.records != 9 {
+		t.Errorf("Expected 1 segment of 9 records, got %v %v", fi.segments, fi.records)
+	}
+
+	// Reload the keyspace from its files
+	store, err = NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to reload store: %v", err)
+	}
+
+	namespace, _ = store.NamespaceByName("default")
+	keyspace, _ = namespace.KeyspaceByName("docs")
+	indexer, _ = keyspace.Indexer(datastore.DEFAULT)
+	index, err = indexer.IndexByName("iv")
+	if err != nil {
+		t.Fatalf("failed to load index: %v", err)
+	}
+
+	state, msg, _ := index.State()
+	if state != datastore.ONLINE {
+		t.Fatalf("Expected online index, got %v %v", state, msg)
+	}
+
+	span := &datastore.Span{}
+	span.Range.Low = value.Values{value.NewValue(1027)}
+	span.Range.High = value.Values{value.NewValue(1030)}
+	span.Range.Inclusion = datastore.LOW
+
+	conn := datastore.NewIndexConnection(&testingContext{t})
+	go index.Scan("", span, false, 0, datastore.UNBOUNDED, nil, conn)
+
+	var keys []string
+	for entry := range conn.EntryChannel() {
+		keys = append(keys, fmt.Sprintf("%s=%v", entry.PrimaryKey, entry.EntryKey[0]))
+	}
+
+	if fmt.Sprint(keys) != "[k7=1027 k8=1028]" {
+		t.Errorf("Expected [k7=1027 k8=1028], got %v", keys)
+	}
+}
+
+func TestFileScopes(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	docs := map[string]string{
+		"docs/a.json":                     `{"v":"docs"}`,
+		"travel/_default/_default/a.json": `{"v":"default"}`,
+		"travel/inventory/hotel/a.json":   `{"v":"hotel"}`,
+		"travel/inventory/route/a.json":   `{"v":"route"}`,
+	}
+	for file, doc := range docs {
+		path := filepath.Join(dir, "default", filepath.FromSlash(file))
+		if er = os.MkdirAll(filepath.Dir(path), 0755); er != nil {
+			t.Fatal(er)
+		}
+		if er = ioutil.WriteFile(path, []byte(doc), 0644); er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	scoped, ok := namespace.(datastore.ScopedNamespace)
+	if !ok {
+		t.Fatalf("Expected a scoped namespace")
+	}
+
+	scopes, _ := scoped.ScopeNames("travel")
+	if fmt.Sprint(scopes) != "[_default inventory]" {
+		t.Errorf("Expected scopes [_default inventory], got %v", scopes)
+	}
+
+	names, _ := namespace.KeyspaceNames()
+	if fmt.Sprint(names) != "[docs travel._default._default travel.inventory.hotel travel.inventory.route]" {
+		t.Errorf("Unexpected keyspace names %v", names)
+	}
+
+	for name, v := range map[string]string{"docs": "docs", "travel": "default",
+		"travel.inventory.hotel": "hotel", "TRAVEL.Inventory.Route": "route"} {
+		keyspace, err := namespace.KeyspaceByName(name)
+		if err != nil {
+			t.Fatalf("failed to get keyspace %s: %v", name, err)
+		}
+
+		pairs, errs := keyspace.Fetch([]string{"a"})
+		if len(errs) > 0 || len(pairs) != 1 {
+			t.Fatalf("Expected a in %s, got %v %v", name, pairs, errs)
+		}
+
+		if actual, _ := pairs[0].Value.Field("v"); actual.Actual() != v {
+			t.Errorf("Expected %s in %s, got %v", v, name, actual)
+		}
+	}
+
+	// Indexes are kept per collection
+	keyspace, _ := namespace.KeyspaceByName("travel.inventory.hotel")
+	indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+	key, _ := parser.Parse("v")
+	_, err = indexer.CreateIndex("", "iv", nil, expression.Expressions{key}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	_, er = os.Stat(filepath.Join(dir, "default", "travel", "inventory", "hotel", INDEX_DIR, "iv"))
+	if er != nil {
+		t.Errorf("Expected index in the collection directory: %v", er)
+	}
+
+	store, err = NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to reload store: %v", err)
+	}
+
+	namespace, _ = store.NamespaceByName("default")
+	keyspace, _ = namespace.KeyspaceByName("travel.inventory.route")
+	indexer, _ = keyspace.Indexer(datastore.DEFAULT)
+	if _, err = indexer.IndexByName("iv"); err == nil {
+		t.Errorf("Expected no index iv on travel.inventory.route")
+	}
+}
+
+func TestFileEncryption(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	// Written before encryption was enabled
+	er = ioutil.WriteFile(filepath.Join(path, "k0.json"), []byte(`{"v":0}`), 0644)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	key1 := []byte("0123456789abcdef0123456789abcdef")
+	key2 := []byte("fedcba9876543210fedcba9876543210")
+	defer SetKeyProvider(EnvKey)
+
+	open := func(options string, key []byte) datastore.Keyspace {
+		SetKeyProvider(func() ([]byte, error) { return key, nil })
+		store, err := NewDatastore(dir + options)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+
+		namespace, _ := store.NamespaceByName("default")
+		keyspace, _ := namespace.KeyspaceByName("docs")
+		return keyspace
+	}
+
+	keyspace := open("?encrypt=true", key1)
+	pair := datastore.Pair{Key: "k1", Value: value.NewValue(map[string]interface{}{"v": 1})}
+	if _, err := keyspace.Insert([]datastore.Pair{pair}); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	doc, _ := ioutil.ReadFile(filepath.Join(path, "k1.json"))
+	if len(doc) == 0 || doc[0] != 0 {
+		t.Errorf("Expected an encrypted document, got %q", doc)
+	}
+
+	// The files cannot be read without the key
+	if _, errs := open("", nil).Fetch([]string{"k1"}); len(errs) == 0 {
+		t.Errorf("Expected an error fetching an encrypted document without the key")
+	}
+
+	indexer, _ := keyspace.Indexer(datastore.DEFAULT)
+	key, _ := parser.Parse("v")
+	_, err := indexer.CreateIndex("", "iv", nil, expression.Expressions{key}, nil, nil)
+	if err != nil {
+		t.Fatalf("failed to create index: %v", err)
+	}
+
+	n, err := Reencrypt(dir, key1, key2)
+	if err != nil || n != 4 {
+		t.Errorf("Expected 4 files rewritten, got %v %v", n, err)
+	}
+
+	// The files rewritten with the new key are left as they are
+	n, err = Reencrypt(dir, key1, key2)
+	if err != nil || n != 0 {
+		t.Errorf("Expected no files rewritten, got %v %v", n, err)
+	}
+
+	keyspace = open("?encrypt=true", key2)
+	pairs, errs := keyspace.Fetch([]string{"k0", "k1"})
+	if len(errs) > 0 || len(pairs) != 2 {
+		t.Fatalf("Expected 2 documents, got %v %v", pairs, errs)
+	}
+
+	indexer, _ = keyspace.Indexer(datastore.DEFAULT)
+	index, err := indexer.IndexByName("iv")
+	if err != nil {
+		t.Fatalf("failed to load index: %v", err)
+	}
+
+	conn := datastore.NewIndexConnection(&testingContext{t})
+	span := &datastore.Span{}
+	span.Range.Low = value.Values{value.NewValue(0)}
+	span.Range.Inclusion = datastore.LOW
+	go index.Scan("", span, false, 0, datastore.UNBOUNDED, nil, conn)
+
+	var keys []string
+	for entry := range conn.EntryChannel() {
+		keys = append(keys, entry.PrimaryKey)
+	}
+
+	if fmt.Sprint(keys) != "[k0 k1]" {
+		t.Errorf("Expected [k0 k1], got %v", keys)
+	}
+}
+
+func TestFileChecksums(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	store, err := NewDatastore(dir + "?checksums=true")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	keyspace, _ := namespace.KeyspaceByName("docs")
+
+	var pairs []datastore.Pair
+	for _, k := range []string{"k1", "k2"} {
+		pairs = append(pairs, datastore.Pair{Key: k, Value: value.NewValue(map[string]interface{}{"v": k})})
+	}
+	if _, err := keyspace.Insert(pairs); err != nil {
+		t.Fatalf("failed to insert: %v", err)
+	}
+
+	er = ioutil.WriteFile(filepath.Join(path, "k2.json"), []byte(`{"v":"garbage"}`), 0644)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	fetched, errs := keyspace.Fetch([]string{"k1", "k2"})
+	if len(fetched) != 1 || fetched[0].Key != "k1" {
+		t.Errorf("Expected only k1, got %v", fetched)
+	}
+	if len(errs) != 1 || errs[0].Code() != errors.FILE_CORRUPT_DOCUMENT || errs[0].Level() != errors.WARNING {
+		t.Errorf("Expected a corrupt document warning, got %v", errs)
+	}
+
+	if _, er = os.Stat(filepath.Join(path, QUARANTINE_DIR, "k2.json")); er != nil {
+		t.Errorf("Expected k2 to be quarantined: %v", er)
+	}
+
+	// The quarantined document is gone from the keyspace
+	if count, _ := keyspace.Count(); count != 1 {
+		t.Errorf("Expected 1 document, got %v", count)
+	}
+
+	fetched, errs = keyspace.Fetch([]string{"k2"})
+	if len(fetched) != 0 || len(errs) != 0 {
+		t.Errorf("Expected no document, got %v %v", fetched, errs)
+	}
+
+	// The keyspace is still found once reopened
+	store, err = NewDatastore(dir + "?checksums=true")
+	if err != nil {
+		t.Fatalf("failed to reopen store: %v", err)
+	}
+
+	namespace, _ = store.NamespaceByName("default")
+	if _, err = namespace.KeyspaceByName("docs"); err != nil {
+		t.Errorf("Expected keyspace docs, got %v", err)
+	}
+}
+
+func TestFileLocking(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("advisory locks are not taken on windows")
+	}
+
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	open := func(options string) datastore.Keyspace {
+		store, err := NewDatastore(dir + options)
+		if err != nil {
+			t.Fatalf("failed to create store: %v", err)
+		}
+
+		namespace, _ := store.NamespaceByName("default")
+		keyspace, _ := namespace.KeyspaceByName("docs")
+		return keyspace
+	}
+
+	keyspace := open("?lock_timeout=50ms")
+	readonly := open("?readonly=true")
+	pair := datastore.Pair{Key: "k1", Value: value.NewValue(map[string]interface{}{"v": 1})}
+
+	// Held by another process
+	file, er := flock(path, true, 0)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	if _, err := keyspace.Insert([]datastore.Pair{pair}); err == nil || err.Code() != 15013 {
+		t.Errorf("Expected a lock timeout, got %v", err)
+	}
+
+	if _, errs := keyspace.Fetch([]string{"k1"}); len(errs) != 1 || errs[0].Code() != 15013 {
+		t.Errorf("Expected a lock timeout, got %v", errs)
+	}
+
+	// Read-only stores take no locks
+	if _, errs := readonly.Fetch([]string{"k1"}); len(errs) != 0 {
+		t.Errorf("Expected no errors, got %v", errs)
+	}
+
+	funlock(file)
+
+	if _, err := keyspace.Insert([]datastore.Pair{pair}); err != nil {
+		t.Errorf("failed to insert: %v", err)
+	}
+
+	if _, err := readonly.Upsert([]datastore.Pair{pair}); err == nil || err.Code() != 15014 {
+		t.Errorf("Expected a read-only error, got %v", err)
+	}
+
+	pairs, errs := readonly.Fetch([]string{"k1"})
+	if len(errs) != 0 || len(pairs) != 1 {
+		t.Errorf("Expected k1, got %v %v", pairs, errs)
+	}
+}
+
+func TestFileCas(t *testing.T) {
+	dir, er := ioutil.TempDir("", "filestore")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "default", "docs")
+	if er = os.MkdirAll(path, 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	er = ioutil.WriteFile(filepath.Join(path, "a.json"), []byte(`{"v":1}`), 0644)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	store, err := NewDatastore(dir)
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	keyspace, _ := namespace.KeyspaceByName("docs")
+	casKeyspace := keyspace.(datastore.CasKeyspace)
+
+	fetch := func() datastore.Pair {
+		pairs, errs := keyspace.Fetch([]string{"a"})
+		if len(errs) > 0 || len(pairs) != 1 {
+			t.Fatalf("Expected a, got %v %v", pairs, errs)
+		}
+
+		return datastore.Pair{Key: "a", Value: pairs[0].Value}
+	}
+
+	stale := fetch()
+	if datastore.FetchedCas(stale.Value) == 0 {
+		t.Errorf("Expected the CAS of a")
+	}
+
+	time.Sleep(10 * time.Millisecond)
+	if _, err = keyspace.Update([]datastore.Pair{fetch()}); err != nil {
+		t.Fatal(err)
+	}
+
+	// Modified since fetched
+	updated, mismatched, err := casKeyspace.UpdateCas([]datastore.Pair{stale})
+	if err != nil || len(updated) != 0 || len(mismatched) != 1 || mismatched[0] != "a" {
+		t.Errorf("Expected a CAS mismatch, got %v %v %v", updated, mismatched, err)
+	}
+
+	updated, mismatched, err = casKeyspace.UpdateCas([]datastore.Pair{fetch()})
+	if err != nil || len(updated) != 1 || len(mismatched) != 0 {
+		t.Errorf("Expected an update, got %v %v %v", updated, mismatched, err)
+	}
+}
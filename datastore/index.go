@@ -24,6 +24,7 @@ const (
 	DEFAULT IndexType = "default" // default may vary per backend
 	VIEW    IndexType = "view"    // view index
 	GSI     IndexType = "gsi"     // global secondary index
+	VIRTUAL IndexType = "virtual" // hypothetical index, for planning only
 )
 
 type Indexer interface {
@@ -97,11 +98,32 @@ type PrimaryIndex interface {
 		vector timestamp.Vector, conn *IndexConnection) // Perform a scan of all the entries in this index
 }
 
+/*
+OffsetPrimaryIndex is implemented by primary indexes that can skip
+entries, so that OFFSET is applied by the index instead of streaming
+the skipped entries through the engine.
+*/
+type OffsetPrimaryIndex interface {
+	PrimaryIndex
+	ScanEntriesOffset(requestId string, offset, limit int64, cons ScanConsistency,
+		vector timestamp.Vector, conn *IndexConnection) // Perform a scan of the entries after offset
+}
+
 type SizedIndex interface {
 	Index
 	SizeFromStatistics(requestId string) (int64, errors.Error)
 }
 
+/*
+CompactableIndex is implemented by indexes whose storage can be
+compacted, such as the file-backed indexes. Compaction is run by
+the maintenance tasks of the server.
+*/
+type CompactableIndex interface {
+	Index
+	Compact(requestId string) errors.Error // Rewrite the storage of this index
+}
+
 type Range struct {
 	Low       value.Values
 	High      value.Values
@@ -121,8 +143,51 @@ const (
 )
 
 type Span struct {
-	Seek  value.Values
-	Range Range
+	Seek   value.Values
+	Range  Range
+	Ranges Ranges2 // Bounds of each key, if known
+}
+
+/*
+Range2 bounds a single key of an index. A span of a composite index
+holds one for each leading key it bounds, so that indexes can filter
+the entries on every key; Range compares the keys as an array, and
+so only bounds them up to the first key whose bounds differ. A nil
+bound is unbounded. Indexes that do not filter on them return the
+entries of Range, which include those of the Ranges.
+*/
+type Range2 struct {
+	Low       value.Value
+	High      value.Value
+	Inclusion Inclusion
+}
+
+type Ranges2 []*Range2
+
+// True if each key is within its range. Missing keys are MISSING.
+func (this Ranges2) Contains(keys value.Values) bool {
+	for i, r := range this {
+		key := value.MISSING_VALUE
+		if i < len(keys) && keys[i] != nil {
+			key = keys[i]
+		}
+
+		if r.Low != nil {
+			c := key.Collate(r.Low)
+			if c < 0 || (c == 0 && r.Inclusion&LOW == 0) {
+				return false
+			}
+		}
+
+		if r.High != nil {
+			c := key.Collate(r.High)
+			if c > 0 || (c == 0 && r.Inclusion&HIGH == 0) {
+				return false
+			}
+		}
+	}
+
+	return true
 }
 
 type Spans []*Span
@@ -181,11 +246,19 @@ func GetScanCap() int64 {
 	return atomic.LoadInt64(&scanCap)
 }
 
+// Contexts that implement this interface override the server scan cap.
+type ScanCapContext interface {
+	ScanCap() int64
+}
+
 func NewSizedIndexConnection(size int64, context Context) (*IndexConnection, errors.Error) {
 	if size <= 0 {
 		return nil, errors.NewIndexScanSizeError(size)
 	}
 	maxSize := GetScanCap()
+	if c, ok := context.(ScanCapContext); ok {
+		maxSize = c.ScanCap()
+	}
 	if (maxSize > 0) && (size > maxSize) {
 		size = maxSize
 	}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Keyspaces of hierarchical datastores are held in scopes within
+buckets. They are named by their keyspace path, bucket.scope.keyspace,
+and a bucket named alone resolves to the default keyspace of its
+default scope.
+*/
+const (
+	DEFAULT_SCOPE      = "_default"
+	DEFAULT_COLLECTION = "_default"
+)
+
+/*
+ScopedNamespace is implemented by namespaces that hold their
+keyspaces in scopes within buckets. KeyspaceNames() and
+KeyspaceByName() of such namespaces use keyspace paths, and
+KeyspaceByName() also accepts a bucket name.
+*/
+type ScopedNamespace interface {
+	Namespace
+	BucketNames() ([]string, errors.Error)             // Names of the buckets contained in this namespace
+	ScopeNames(bucket string) ([]string, errors.Error) // Names of the scopes contained in a bucket
+}
+
+// The keyspace path of a keyspace within a scope of a bucket.
+func KeyspacePath(bucket, scope, keyspace string) string {
+	return bucket + "." + scope + "." + keyspace
+}
+
+/*
+Returns the bucket, scope and keyspace of a keyspace path. A name
+that is not a path is returned as the keyspace, with no bucket or
+scope.
+*/
+func SplitKeyspacePath(name string) (bucket, scope, keyspace string) {
+	parts := strings.SplitN(name, ".", 3)
+	if len(parts) < 3 {
+		return "", "", name
+	}
+
+	return parts[0], parts[1], parts[2]
+}
+
+/*
+Whether the scope and keyspace following a bucket name the path of
+a keyspace in the namespace. This resolves FROM terms, where they
+otherwise read as a path within the documents of the bucket.
+*/
+func IsKeyspacePath(namespace Namespace, bucket, scope, keyspace string) bool {
+	if _, ok := namespace.(ScopedNamespace); !ok {
+		return false
+	}
+
+	ks, err := namespace.KeyspaceByName(KeyspacePath(bucket, scope, keyspace))
+	return err == nil && ks != nil
+}
@@ -0,0 +1,229 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+A KeyspaceResolver resolves the names of the keyspaces it holds, such
+as those of a mounted datastore, and returns nil and no error for the
+others, which are passed on to the next resolver. The store is the
+datastore of the request, or the global datastore when plans are
+unmarshaled.
+*/
+type KeyspaceResolver func(store Datastore, namespace, keyspace string) (Keyspace, errors.Error)
+
+type namedResolver struct {
+	name     string
+	resolver KeyspaceResolver
+}
+
+/*
+Names are resolved by the system namespace, then by the resolvers in
+the order they were added, and last by the store itself, which holds
+the temporary keyspaces of a request. The planner and plan
+unmarshaling both resolve names through the chain, so that they
+agree on the keyspace a name refers to.
+*/
+var _RESOLVERS struct {
+	sync.RWMutex
+	resolvers []namedResolver
+}
+
+// Replaces any resolver of the same name, in its place in the chain.
+func AddKeyspaceResolver(name string, resolver KeyspaceResolver) {
+	_RESOLVERS.Lock()
+	defer _RESOLVERS.Unlock()
+
+	for i, r := range _RESOLVERS.resolvers {
+		if r.name == name {
+			_RESOLVERS.resolvers[i].resolver = resolver
+			InvalidateKeyspaces()
+			return
+		}
+	}
+
+	_RESOLVERS.resolvers = append(_RESOLVERS.resolvers, namedResolver{name, resolver})
+	InvalidateKeyspaces()
+}
+
+func RemoveKeyspaceResolver(name string) {
+	_RESOLVERS.Lock()
+	defer _RESOLVERS.Unlock()
+
+	for i, r := range _RESOLVERS.resolvers {
+		if r.name == name {
+			_RESOLVERS.resolvers = append(_RESOLVERS.resolvers[:i:i], _RESOLVERS.resolvers[i+1:]...)
+			InvalidateKeyspaces()
+			return
+		}
+	}
+}
+
+func keyspaceResolvers() []namedResolver {
+	_RESOLVERS.RLock()
+	defer _RESOLVERS.RUnlock()
+	return _RESOLVERS.resolvers
+}
+
+// Globally accessible system datastore, which holds the system namespace
+var _SYSTEMSTORE Datastore
+
+func SetSystemstore(systemstore Datastore) {
+	_SYSTEMSTORE = systemstore
+	InvalidateKeyspaces()
+}
+
+func GetSystemstore() Datastore {
+	return _SYSTEMSTORE
+}
+
+func IsSystemNamespace(namespace string) bool {
+	return strings.ToLower(namespace) == "#system"
+}
+
+/*
+Resolves the keyspace through the chain of resolvers. Unqualified
+keyspaces are looked up along the search path of the store.
+*/
+func ResolveKeyspace(store Datastore, namespace, keyspace string) (Keyspace, errors.Error) {
+	if namespace == "" {
+		namespace = ResolveNamespace(store, "", keyspace)
+	}
+
+	if ks := _KEYSPACE_CACHE.get(store, namespace, keyspace); ks != nil {
+		return ks, nil
+	}
+
+	ks, err := resolveKeyspace(store, namespace, keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	// Temporary keyspaces belong to a request
+	if !IsTemporary(keyspace) {
+		_KEYSPACE_CACHE.put(store, namespace, keyspace, ks)
+	}
+
+	return ks, nil
+}
+
+func resolveKeyspace(store Datastore, namespace, keyspace string) (Keyspace, errors.Error) {
+	if IsSystemNamespace(namespace) && GetSystemstore() != nil {
+		store = GetSystemstore()
+	} else {
+		for _, r := range keyspaceResolvers() {
+			ks, err := r.resolver(store, namespace, keyspace)
+			if ks != nil || err != nil {
+				return ks, err
+			}
+		}
+	}
+
+	if store == nil {
+		return nil, errors.NewError(nil, "Datastore not set.")
+	}
+
+	ns, err := store.NamespaceByName(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	return ns.KeyspaceByName(keyspace)
+}
+
+/*
+Resolved keyspaces are cached for a time, so that names are not
+resolved again for each plan that is unmarshaled. Statements that
+create or drop keyspaces invalidate them; the time limit bounds how
+long changes made elsewhere go unnoticed.
+*/
+const _KEYSPACE_CACHE_TTL = 30 * time.Second
+
+type keyspaceEntry struct {
+	store    Datastore
+	keyspace Keyspace
+	expires  time.Time
+}
+
+type keyspaceCache struct {
+	sync.RWMutex
+	ttl     time.Duration
+	entries map[string]*keyspaceEntry
+}
+
+var _KEYSPACE_CACHE = &keyspaceCache{
+	ttl:     _KEYSPACE_CACHE_TTL,
+	entries: make(map[string]*keyspaceEntry),
+}
+
+// A TTL of zero disables the cache.
+func SetKeyspaceCacheTTL(ttl time.Duration) {
+	_KEYSPACE_CACHE.Lock()
+	defer _KEYSPACE_CACHE.Unlock()
+	_KEYSPACE_CACHE.ttl = ttl
+	_KEYSPACE_CACHE.entries = make(map[string]*keyspaceEntry)
+}
+
+func KeyspaceCacheTTL() time.Duration {
+	_KEYSPACE_CACHE.RLock()
+	defer _KEYSPACE_CACHE.RUnlock()
+	return _KEYSPACE_CACHE.ttl
+}
+
+func InvalidateKeyspace(namespace, keyspace string) {
+	_KEYSPACE_CACHE.Lock()
+	defer _KEYSPACE_CACHE.Unlock()
+	delete(_KEYSPACE_CACHE.entries, cacheKey(namespace, keyspace))
+}
+
+func InvalidateKeyspaces() {
+	_KEYSPACE_CACHE.Lock()
+	defer _KEYSPACE_CACHE.Unlock()
+	_KEYSPACE_CACHE.entries = make(map[string]*keyspaceEntry)
+}
+
+func cacheKey(namespace, keyspace string) string {
+	return strings.ToLower(namespace) + ":" + keyspace
+}
+
+// Entries only serve the store that resolved them.
+func (this *keyspaceCache) get(store Datastore, namespace, keyspace string) Keyspace {
+	this.RLock()
+	defer this.RUnlock()
+
+	entry, ok := this.entries[cacheKey(namespace, keyspace)]
+	if !ok || entry.store != store || time.Now().After(entry.expires) {
+		return nil
+	}
+
+	return entry.keyspace
+}
+
+func (this *keyspaceCache) put(store Datastore, namespace, keyspace string, ks Keyspace) {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.ttl <= 0 {
+		return
+	}
+
+	this.entries[cacheKey(namespace, keyspace)] = &keyspaceEntry{
+		store:    store,
+		keyspace: ks,
+		expires:  time.Now().Add(this.ttl),
+	}
+}
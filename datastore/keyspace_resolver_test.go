@@ -0,0 +1,93 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/errors"
+)
+
+func TestResolveKeyspace(t *testing.T) {
+	store, err := mock.NewDatastore("mock:namespaces=1,keyspaces=2,items=1")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	datastore.SetDatastore(store)
+	defer datastore.SetDatastore(nil)
+
+	ks, err := datastore.GetKeyspace("p0", "b0")
+	if err != nil || ks.Name() != "b0" {
+		t.Fatalf("Expected b0, got %v %v", ks, err)
+	}
+
+	// A mounted namespace, served by the keyspaces of p0
+	mounted, _ := store.NamespaceByName("p0")
+	calls := 0
+	datastore.AddKeyspaceResolver("mount", func(store datastore.Datastore, namespace, keyspace string) (
+		datastore.Keyspace, errors.Error) {
+		if namespace != "mnt" {
+			return nil, nil
+		}
+		calls++
+		return mounted.KeyspaceByName(keyspace)
+	})
+	defer datastore.RemoveKeyspaceResolver("mount")
+
+	for i := 0; i < 2; i++ {
+		ks, err = datastore.GetKeyspace("mnt", "b1")
+		if err != nil || ks.Name() != "b1" {
+			t.Fatalf("Expected b1, got %v %v", ks, err)
+		}
+	}
+
+	if calls != 1 {
+		t.Errorf("Expected the keyspace to be cached, resolved %d times", calls)
+	}
+
+	datastore.InvalidateKeyspace("mnt", "b1")
+	datastore.GetKeyspace("mnt", "b1")
+	if calls != 2 {
+		t.Errorf("Expected the keyspace to be resolved again, resolved %d times", calls)
+	}
+
+	// Replacing the resolver drops the keyspaces it resolved
+	datastore.AddKeyspaceResolver("mount", func(store datastore.Datastore, namespace, keyspace string) (
+		datastore.Keyspace, errors.Error) {
+		if namespace != "mnt" {
+			return nil, nil
+		}
+		calls++
+		return mounted.KeyspaceByName("b0")
+	})
+
+	ks, err = datastore.GetKeyspace("mnt", "b1")
+	if err != nil || ks.Name() != "b0" || calls != 3 {
+		t.Errorf("Expected b1 to be resolved by the new resolver, got %v %v", ks, err)
+	}
+
+	// The system namespace is resolved by the systemstore
+	sys, err := system.NewDatastore(store, nil)
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	datastore.SetSystemstore(sys)
+	defer datastore.SetSystemstore(nil)
+
+	ks, err = datastore.GetKeyspace("#system", "keyspaces")
+	if err != nil || ks.Name() != "keyspaces" {
+		t.Errorf("Expected system:keyspaces, got %v %v", ks, err)
+	}
+}
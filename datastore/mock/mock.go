@@ -21,6 +21,7 @@ import (
 	"fmt"
 	"strconv"
 	"strings"
+	"sync/atomic"
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
@@ -31,9 +32,10 @@ import (
 )
 
 const (
-	DEFAULT_NUM_NAMESPACES = 1
-	DEFAULT_NUM_KEYSPACES  = 1
-	DEFAULT_NUM_ITEMS      = 100000
+	DEFAULT_NUM_NAMESPACES  = 1
+	DEFAULT_NUM_KEYSPACES   = 1
+	DEFAULT_NUM_ITEMS       = 100000
+	DEFAULT_NUM_COLLECTIONS = 1
 )
 
 // store is the root for the mock-based Store.
@@ -42,6 +44,8 @@ type store struct {
 	namespaces     map[string]*namespace
 	namespaceNames []string
 	params         map[string]int
+	faults         *faults
+	seed           uint64
 }
 
 func (s *store) Id() string {
@@ -65,18 +69,26 @@ func (s *store) NamespaceById(id string) (p datastore.Namespace, e errors.Error)
 }
 
 func (s *store) NamespaceByName(name string) (p datastore.Namespace, e errors.Error) {
-	p, ok := s.namespaces[name]
+	ns, ok := s.namespaces[name]
 	if !ok {
-		p, e = nil, errors.NewOtherNamespaceNotFoundError(nil, name+" for Mock datastore")
+		return nil, errors.NewOtherNamespaceNotFoundError(nil, name+" for Mock datastore")
 	}
 
-	return
+	if ns.scopes != nil {
+		return &scopedNamespace{ns}, nil
+	}
+
+	return ns, nil
 }
 
 func (s *store) Authorize(datastore.Privileges, datastore.Credentials) errors.Error {
 	return nil
 }
 
+func (s *store) RoleCatalog() (datastore.RoleCatalog, errors.Error) {
+	return nil, errors.NewOtherNotImplementedError(nil, "role catalog")
+}
+
 func (s *store) SetLogLevel(level logging.Level) {
 	// No-op. Uses query engine logger.
 }
@@ -87,6 +99,8 @@ type namespace struct {
 	name          string
 	keyspaces     map[string]*keyspace
 	keyspaceNames []string
+	bucketNames   []string
+	scopes        map[string][]string // Scope names by bucket, if the keyspaces are in scopes
 }
 
 func (p *namespace) DatastoreId() string {
@@ -114,6 +128,10 @@ func (p *namespace) KeyspaceById(id string) (b datastore.Keyspace, e errors.Erro
 }
 
 func (p *namespace) KeyspaceByName(name string) (b datastore.Keyspace, e errors.Error) {
+	if _, ok := p.scopes[name]; ok {
+		name = datastore.KeyspacePath(name, datastore.DEFAULT_SCOPE, datastore.DEFAULT_COLLECTION)
+	}
+
 	b, ok := p.keyspaces[name]
 	if !ok {
 		b, e = nil, errors.NewOtherKeyspaceNotFoundError(nil, name+" for Mock datastore")
@@ -122,12 +140,33 @@ func (p *namespace) KeyspaceByName(name string) (b datastore.Keyspace, e errors.
 	return
 }
 
+// scopedNamespace is a mock-based namespace holding its keyspaces in scopes.
+type scopedNamespace struct {
+	*namespace
+}
+
+func (p *scopedNamespace) BucketNames() ([]string, errors.Error) {
+	return p.bucketNames, nil
+}
+
+func (p *scopedNamespace) ScopeNames(bucket string) ([]string, errors.Error) {
+	scopes, ok := p.scopes[bucket]
+	if !ok {
+		return nil, errors.NewOtherKeyspaceNotFoundError(nil, bucket+" for Mock datastore")
+	}
+
+	return scopes, nil
+}
+
 // keyspace is a mock-based keyspace.
 type keyspace struct {
 	namespace *namespace
 	name      string
 	nitems    int
+	template  Template
 	mi        datastore.Indexer
+	replicas  []*keyspace // Read replicas, generating the same items
+	fetches   int64       // Number of fetches, to observe replica reads
 }
 
 func (b *keyspace) NamespaceId() string {
@@ -146,6 +185,20 @@ func (b *keyspace) Count() (int64, errors.Error) {
 	return int64(b.nitems), nil
 }
 
+// The size of the items, encoded as JSON.
+func (b *keyspace) Size() (int64, errors.Error) {
+	var size int64
+	for i := 0; i < b.nitems; i++ {
+		item, err := genItem(i, b.nitems, b.namespace.store.seed, b.template)
+		if err != nil {
+			return 0, err
+		}
+
+		size += int64(encodedSize(item))
+	}
+	return size, nil
+}
+
 func (b *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
 	return b.mi, nil
 }
@@ -154,11 +207,33 @@ func (b *keyspace) Indexers() ([]datastore.Indexer, errors.Error) {
 	return []datastore.Indexer{b.mi}, nil
 }
 
+func (b *keyspace) Replicas() int {
+	return len(b.replicas)
+}
+
+func (b *keyspace) Replica(n int) (datastore.Keyspace, errors.Error) {
+	if n < 1 || n > len(b.replicas) {
+		return nil, errors.NewOtherDatastoreError(nil,
+			fmt.Sprintf("no mock replica: %v of %v", n, b.name))
+	}
+	return b.replicas[n-1], nil
+}
+
 func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	atomic.AddInt64(&b.fetches, 1)
+
+	faults := b.namespace.store.faults
+	if len(keys) > 0 {
+		faults.fetchDelay(keys[0])
+	}
+
 	var errs []errors.Error
 	rv := make([]datastore.AnnotatedPair, 0, len(keys))
 	for _, k := range keys {
 		item, e := b.fetchOne(k)
+		if e == nil {
+			e = faults.fetchError(k)
+		}
 		if e != nil {
 			if errs == nil {
 				errs = make([]errors.Error, 0, 1)
@@ -167,12 +242,6 @@ func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Err
 			continue
 		}
 
-		if item != nil {
-			item.SetAttachment("meta", map[string]interface{}{
-				"id": k,
-			})
-		}
-
 		rv = append(rv, datastore.AnnotatedPair{
 			Key:   k,
 			Value: item,
@@ -186,22 +255,30 @@ func (b *keyspace) fetchOne(key string) (value.AnnotatedValue, errors.Error) {
 	if e != nil {
 		return nil, errors.NewOtherKeyNotFoundError(e, fmt.Sprintf("no mock item: %v", key))
 	} else {
-		return genItem(i, b.nitems)
+		return genItem(i, b.nitems, b.namespace.store.seed, b.template)
 	}
 }
 
 // generate a mock document - used by fetchOne to mock a document in the keyspace
-func genItem(i int, nitems int) (value.AnnotatedValue, errors.Error) {
+func genItem(i int, nitems int, seed uint64, template Template) (value.AnnotatedValue, errors.Error) {
 	if i < 0 || i >= nitems {
 		return nil, errors.NewOtherDatastoreError(nil,
 			fmt.Sprintf("item out of mock range: %v [0,%v)", i, nitems))
 	}
 	id := strconv.Itoa(i)
-	doc := value.NewAnnotatedValue(map[string]interface{}{"id": id, "i": float64(i)})
-	doc.SetAttachment("meta", map[string]interface{}{"id": id})
+	doc := value.NewAnnotatedValue(template(i, nitems, newRandom(seed, i)))
+	doc.SetAttachment("meta", map[string]interface{}{
+		"id":   id,
+		"size": encodedSize(doc),
+	})
 	return doc, nil
 }
 
+func encodedSize(doc value.Value) int {
+	bytes, _ := doc.MarshalJSON()
+	return len(bytes)
+}
+
 func (b *keyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
 	// FIXME
 	return nil, errors.NewOtherNotImplementedError(nil, "for Mock datastore")
@@ -320,16 +397,33 @@ func (mi *mockIndexer) SetLogLevel(level logging.Level) {
 // keyspace with 50000 items.  By default, you get...
 // mock:namespaces=1,keyspaces=1,items=100000 Which is what you'd get
 // by specifying a path of just...  mock:
+//
+// The template param names the template of the generated documents,
+// e.g. mock:items=1000,template=orders. See RegisterTemplate. Faults
+// may also be injected, e.g. mock:fetcherrors=5,scantimeout=100. See
+// faults. The seed param seeds both. Documents are generated when
+// fetched, so keyspaces of billions of items use no memory, e.g.
+// mock:items=5000000000,seed=42. With replicas=N, each keyspace has
+// N read replicas, e.g. mock:items=1000,replicas=2.
 func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	if strings.HasPrefix(path, "mock:") {
 		path = path[5:]
 	}
 	params := map[string]int{}
+	template := Template(defaultTemplate)
 	for _, kv := range strings.Split(path, ",") {
 		if kv == "" {
 			continue
 		}
 		pair := strings.Split(kv, "=")
+		if pair[0] == "template" && len(pair) == 2 {
+			var err errors.Error
+			template, err = templateByName(pair[1])
+			if err != nil {
+				return nil, err
+			}
+			continue
+		}
 		v, e := strconv.Atoi(pair[1])
 		if e != nil {
 			return nil, errors.NewOtherDatastoreError(e,
@@ -341,16 +435,38 @@ func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	nnamespaces := paramVal(params, "namespaces", DEFAULT_NUM_NAMESPACES)
 	nkeyspaces := paramVal(params, "keyspaces", DEFAULT_NUM_KEYSPACES)
 	nitems := paramVal(params, "items", DEFAULT_NUM_ITEMS)
-	s := &store{path: path, params: params, namespaces: map[string]*namespace{}, namespaceNames: []string{}}
+	nscopes := paramVal(params, "scopes", 0)
+	ncollections := paramVal(params, "collections", DEFAULT_NUM_COLLECTIONS)
+	nreplicas := paramVal(params, "replicas", 0)
+	s := &store{path: path, params: params, faults: newFaults(params),
+		seed:       uint64(paramVal(params, "seed", 0)),
+		namespaces: map[string]*namespace{}, namespaceNames: []string{}}
 	for i := 0; i < nnamespaces; i++ {
 		p := &namespace{store: s, name: "p" + strconv.Itoa(i), keyspaces: map[string]*keyspace{}, keyspaceNames: []string{}}
+		if nscopes > 0 {
+			p.scopes = map[string][]string{}
+		}
 		for j := 0; j < nkeyspaces; j++ {
-			b := &keyspace{namespace: p, name: "b" + strconv.Itoa(j), nitems: nitems}
+			if nscopes == 0 {
+				p.addKeyspace("b"+strconv.Itoa(j), nitems, nreplicas, template)
+				continue
+			}
 
-			b.mi = newMockIndexer(b)
-			b.mi.CreatePrimaryIndex("", "#primary", nil)
-			p.keyspaces[b.name] = b
-			p.keyspaceNames = append(p.keyspaceNames, b.name)
+			// With scopes=N, each bucket holds the default scope and
+			// N more, each holding the default collection and more
+			bucket := "b" + strconv.Itoa(j)
+			scopes := []string{datastore.DEFAULT_SCOPE}
+			for k := 0; k < nscopes; k++ {
+				scopes = append(scopes, "s"+strconv.Itoa(k))
+			}
+			for _, scope := range scopes {
+				p.addKeyspace(datastore.KeyspacePath(bucket, scope, datastore.DEFAULT_COLLECTION), nitems, nreplicas, template)
+				for k := 0; k < ncollections; k++ {
+					p.addKeyspace(datastore.KeyspacePath(bucket, scope, "c"+strconv.Itoa(k)), nitems, nreplicas, template)
+				}
+			}
+			p.bucketNames = append(p.bucketNames, bucket)
+			p.scopes[bucket] = scopes
 		}
 		s.namespaces[p.name] = p
 		s.namespaceNames = append(s.namespaceNames, p.name)
@@ -358,6 +474,24 @@ func NewDatastore(path string) (datastore.Datastore, errors.Error) {
 	return s, nil
 }
 
+func (p *namespace) addKeyspace(name string, nitems, nreplicas int, template Template) {
+	b := p.newKeyspace(name, nitems, template)
+	for i := 0; i < nreplicas; i++ {
+		b.replicas = append(b.replicas, p.newKeyspace(name, nitems, template))
+	}
+
+	p.keyspaces[b.name] = b
+	p.keyspaceNames = append(p.keyspaceNames, b.name)
+}
+
+func (p *namespace) newKeyspace(name string, nitems int, template Template) *keyspace {
+	b := &keyspace{namespace: p, name: name, nitems: nitems, template: template}
+
+	b.mi = newMockIndexer(b)
+	b.mi.CreatePrimaryIndex("", "#primary", nil)
+	return b
+}
+
 func paramVal(params map[string]int, key string, defaultVal int) int {
 	v, ok := params[key]
 	if ok {
@@ -410,7 +544,11 @@ func (pi *primaryIndex) State() (state datastore.IndexState, msg string, err err
 
 func (pi *primaryIndex) Statistics(requestId string, span *datastore.Span) (
 	datastore.Statistics, errors.Error) {
-	return nil, nil
+	return newStatistics(span, pi.keyspace.nitems)
+}
+
+func (pi *primaryIndex) SizeFromStatistics(requestId string) (int64, errors.Error) {
+	return int64(pi.keyspace.nitems), nil
 }
 
 func (pi *primaryIndex) Drop(requestId string) errors.Error {
@@ -421,39 +559,19 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
-	// For primary indexes, bounds must always be strings, so we
-	// can just enforce that directly
-	low, high := "", ""
-
-	// Ensure that lower bound is a string, if any
-	if len(span.Range.Low) > 0 {
-		a := span.Range.Low[0].Actual()
-		switch a := a.(type) {
-		case string:
-			low = a
-		default:
-			conn.Error(errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid lower bound %v of type %T.", a, a)))
-			return
-		}
-	}
-
-	// Ensure that upper bound is a string, if any
-	if len(span.Range.High) > 0 {
-		a := span.Range.High[0].Actual()
-		switch a := a.(type) {
-		case string:
-			high = a
-		default:
-			conn.Error(errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid upper bound %v of type %T.", a, a)))
-			return
-		}
+	low, high, err := spanBounds(span)
+	if err != nil {
+		conn.Error(err)
+		return
 	}
 
 	if limit == 0 {
 		limit = int64(pi.keyspace.nitems)
 	}
 
-	for i := 0; i < pi.keyspace.nitems && int64(i) < limit; i++ {
+	faults := pi.keyspace.namespace.store.faults
+	sent := int64(0)
+	for i := seekKey(low, 0, pi.keyspace.nitems); i < pi.keyspace.nitems && sent < limit; i++ {
 		id := strconv.Itoa(i)
 
 		if low != "" &&
@@ -470,12 +588,23 @@ func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bo
 			break
 		}
 
-		entry := datastore.IndexEntry{PrimaryKey: id}
-		conn.EntryChannel() <- &entry
+		if faults.scanTimedOut(sent) {
+			conn.Error(errors.NewCbIndexScanTimeoutError(nil))
+			return
+		}
+
+		faults.scanDelay(id)
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, id)
+		sent++
 	}
 }
 
 func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	pi.ScanEntriesOffset(requestId, 0, limit, cons, vector, conn)
+}
+
+func (pi *primaryIndex) ScanEntriesOffset(requestId string, offset, limit int64, cons datastore.ScanConsistency,
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
@@ -483,8 +612,43 @@ func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastor
 		limit = int64(pi.keyspace.nitems)
 	}
 
-	for i := 0; i < pi.keyspace.nitems && int64(i) < limit; i++ {
-		entry := datastore.IndexEntry{PrimaryKey: strconv.Itoa(i)}
-		conn.EntryChannel() <- &entry
+	faults := pi.keyspace.namespace.store.faults
+	for i := offset; i < int64(pi.keyspace.nitems) && i-offset < limit; i++ {
+		if faults.scanTimedOut(i - offset) {
+			conn.Error(errors.NewCbIndexScanTimeoutError(nil))
+			return
+		}
+
+		id := strconv.FormatInt(i, 10)
+		faults.scanDelay(id)
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, id)
 	}
 }
+
+// For primary indexes, bounds must always be strings, so we can just
+// enforce that directly.
+func spanBounds(span *datastore.Span) (low, high string, err errors.Error) {
+	// Ensure that lower bound is a string, if any
+	if len(span.Range.Low) > 0 {
+		a := span.Range.Low[0].Actual()
+		switch a := a.(type) {
+		case string:
+			low = a
+		default:
+			return "", "", errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid lower bound %v of type %T.", a, a))
+		}
+	}
+
+	// Ensure that upper bound is a string, if any
+	if len(span.Range.High) > 0 {
+		a := span.Range.High[0].Actual()
+		switch a := a.(type) {
+		case string:
+			high = a
+		default:
+			return "", "", errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid upper bound %v of type %T.", a, a))
+		}
+	}
+
+	return low, high, nil
+}
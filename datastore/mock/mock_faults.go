@@ -0,0 +1,95 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mock
+
+import (
+	"fmt"
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/couchbase/query/errors"
+)
+
+/*
+faults are injected into mock operations, to exercise the retry,
+timeout and partial result paths of the engine. They are set by the
+params:
+
+	fetchlatency=<ms>   latency of each fetch
+	scanlatency=<ms>    latency of each scanned entry
+	jitter=<ms>         maximum latency added to the above
+	fetcherrors=<pct>   percentage of keys whose fetch fails
+	scantimeout=<n>     scans time out after n entries
+	seed=<n>            seed of the fault choices
+
+Choices are made by hashing the seed and the key, so that the same
+keys get the same faults on each run, whatever the scheduling.
+*/
+type faults struct {
+	fetchLatency time.Duration
+	scanLatency  time.Duration
+	jitter       time.Duration
+	fetchErrors  int
+	scanTimeout  int
+	seed         string
+}
+
+func newFaults(params map[string]int) *faults {
+	return &faults{
+		fetchLatency: time.Duration(paramVal(params, "fetchlatency", 0)) * time.Millisecond,
+		scanLatency:  time.Duration(paramVal(params, "scanlatency", 0)) * time.Millisecond,
+		jitter:       time.Duration(paramVal(params, "jitter", 0)) * time.Millisecond,
+		fetchErrors:  paramVal(params, "fetcherrors", 0),
+		scanTimeout:  paramVal(params, "scantimeout", 0),
+		seed:         strconv.Itoa(paramVal(params, "seed", 0)),
+	}
+}
+
+func (this *faults) choose(op, key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(this.seed))
+	h.Write([]byte(op))
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+func (this *faults) delay(op string, latency time.Duration, key string) {
+	if latency <= 0 {
+		return
+	}
+
+	if this.jitter > 0 {
+		latency += time.Duration(int64(this.choose(op, key)) % int64(this.jitter))
+	}
+
+	time.Sleep(latency)
+}
+
+func (this *faults) fetchDelay(key string) {
+	this.delay("fetch", this.fetchLatency, key)
+}
+
+func (this *faults) scanDelay(key string) {
+	this.delay("scan", this.scanLatency, key)
+}
+
+func (this *faults) fetchError(key string) errors.Error {
+	if this.fetchErrors <= 0 || int(this.choose("error", key)%100) >= this.fetchErrors {
+		return nil
+	}
+
+	return errors.NewOtherDatastoreError(nil, fmt.Sprintf("injected mock fetch error: %v", key))
+}
+
+// Returns true if a scan that has sent n entries times out.
+func (this *faults) scanTimedOut(n int64) bool {
+	return this.scanTimeout > 0 && n >= int64(this.scanTimeout)
+}
@@ -0,0 +1,225 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mock
+
+import (
+	"strconv"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+/*
+The keys of a mock keyspace are the decimal numbers below nitems, and
+are scanned in numeric order. Within each length, numeric order is
+key order, so seeks and statistics are computed per length instead of
+by iterating over the keys, which would not do for keyspaces of
+billions of items.
+*/
+
+// Returns the smallest k-digit decimal string >= low, if any.
+func ceilKey(k int, low string) (string, bool) {
+	valid := func(j int, c byte) bool {
+		return c >= '0' && c <= '9' && (c != '0' || j > 0 || k == 1)
+	}
+
+	n := len(low)
+	if n > k {
+		n = k
+	}
+
+	// Digits of low that can start a k-digit key
+	j := 0
+	for j < n && valid(j, low[j]) {
+		j++
+	}
+
+	if j == len(low) {
+		if j == 0 && k > 1 {
+			return "1" + zeros(k-1), true
+		}
+		return low + zeros(k-j), true
+	}
+
+	// Keep the longest prefix of low, and increase the next digit
+	for ; j >= 0; j-- {
+		if j == k {
+			continue
+		}
+
+		if low[j] >= '9' {
+			continue
+		}
+
+		c := byte('0')
+		if low[j] >= '0' {
+			c = low[j] + 1
+		}
+
+		for ; c <= '9'; c++ {
+			if valid(j, c) {
+				return low[:j] + string(c) + zeros(k-j-1), true
+			}
+		}
+	}
+
+	return "", false
+}
+
+func zeros(n int) string {
+	rv := make([]byte, n)
+	for i := range rv {
+		rv[i] = '0'
+	}
+	return string(rv)
+}
+
+// Returns the range of the k-digit keys.
+func keyRange(k int) (int, int) {
+	if k == 1 {
+		return 0, 10
+	}
+
+	start := 1
+	for i := 1; i < k; i++ {
+		start *= 10
+	}
+
+	return start, start * 10
+}
+
+/*
+Returns the first key from key from, in scan order, that is >= bound,
+or nitems if none. Scans start at this key instead of iterating from
+the first key.
+*/
+func seekKey(bound string, from, nitems int) int {
+	for k := 1; ; k++ {
+		start, end := keyRange(k)
+		if start >= nitems {
+			return nitems
+		}
+
+		if end <= from {
+			continue
+		}
+
+		if start < from {
+			start = from
+		}
+
+		key, ok := ceilKey(k, bound)
+		if !ok {
+			continue
+		}
+
+		i, _ := strconv.Atoi(key)
+		if i < start {
+			i = start
+		}
+
+		if i < end {
+			if i > nitems {
+				i = nitems
+			}
+			return i
+		}
+	}
+}
+
+// Returns the smallest and largest keys in [from, to).
+func keyBounds(from, to int) (min, max string) {
+	min, max = strconv.Itoa(from), strconv.Itoa(to-1)
+	for k := 1; ; k++ {
+		start, end := keyRange(k)
+		if start >= to {
+			return
+		}
+
+		if end <= from {
+			continue
+		}
+
+		if start > from {
+			if key := strconv.Itoa(start); key < min {
+				min = key
+			}
+		}
+
+		if end < to {
+			if key := strconv.Itoa(end - 1); key > max {
+				max = key
+			}
+		}
+	}
+}
+
+/*
+statistics describe the keys a scan of the span returns. They are
+exact, but computed without scanning the keys.
+*/
+type statistics struct {
+	count int64
+	min   value.Values
+	max   value.Values
+}
+
+func newStatistics(span *datastore.Span, nitems int) (*statistics, errors.Error) {
+	low, high, err := spanBounds(span)
+	if err != nil {
+		return nil, err
+	}
+
+	// The scan starts at the first key within low, and stops at the
+	// first key after it that is beyond high
+	if low != "" && span.Range.Inclusion&datastore.LOW == 0 {
+		low += "\x00"
+	}
+
+	from := seekKey(low, 0, nitems)
+	to := nitems
+	if high != "" {
+		if span.Range.Inclusion&datastore.HIGH != 0 {
+			high += "\x00"
+		}
+		to = seekKey(high, from, nitems)
+	}
+
+	rv := &statistics{}
+	if to > from {
+		min, max := keyBounds(from, to)
+		rv.count = int64(to - from)
+		rv.min = value.Values{value.NewValue(min)}
+		rv.max = value.Values{value.NewValue(max)}
+	}
+
+	return rv, nil
+}
+
+func (this *statistics) Count() (int64, errors.Error) {
+	return this.count, nil
+}
+
+func (this *statistics) Min() (value.Values, errors.Error) {
+	return this.min, nil
+}
+
+func (this *statistics) Max() (value.Values, errors.Error) {
+	return this.max, nil
+}
+
+func (this *statistics) DistinctCount() (int64, errors.Error) {
+	return this.count, nil
+}
+
+func (this *statistics) Bins() ([]datastore.Statistics, errors.Error) {
+	return nil, nil
+}
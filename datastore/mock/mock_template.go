@@ -0,0 +1,171 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package mock
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Template generates the body of mock document i of a keyspace with
+nitems documents. Templates must be deterministic, so that a document
+is the same each time it is fetched: any randomness must come from r,
+which is seeded by the seed param and i.
+*/
+type Template func(i, nitems int, r *Random) map[string]interface{}
+
+/*
+Random is a small, allocation-free generator (splitmix64), so that
+seeded documents of huge keyspaces are cheap to generate.
+*/
+type Random struct {
+	state uint64
+}
+
+func newRandom(seed uint64, i int) *Random {
+	return &Random{state: seed*0x9E3779B97F4A7C15 ^ uint64(i)}
+}
+
+func (this *Random) Uint64() uint64 {
+	this.state += 0x9E3779B97F4A7C15
+	z := this.state
+	z = (z ^ (z >> 30)) * 0xBF58476D1CE4E5B9
+	z = (z ^ (z >> 27)) * 0x94D049BB133111EB
+	return z ^ (z >> 31)
+}
+
+// Returns a number in [0, n).
+func (this *Random) Intn(n int) int {
+	return int(this.Uint64() % uint64(n))
+}
+
+// Returns a number in [0.0, 1.0).
+func (this *Random) Float64() float64 {
+	return float64(this.Uint64()>>11) / (1 << 53)
+}
+
+type templates struct {
+	sync.RWMutex
+	templates map[string]Template
+}
+
+var _TEMPLATES = &templates{
+	templates: map[string]Template{
+		"default": defaultTemplate,
+		"orders":  ordersTemplate,
+	},
+}
+
+// Register a template, for use by the template=<name> param.
+func RegisterTemplate(name string, template Template) {
+	_TEMPLATES.Lock()
+	defer _TEMPLATES.Unlock()
+	_TEMPLATES.templates[name] = template
+}
+
+func templateByName(name string) (Template, errors.Error) {
+	_TEMPLATES.RLock()
+	defer _TEMPLATES.RUnlock()
+	template, ok := _TEMPLATES.templates[name]
+	if !ok {
+		return nil, errors.NewOtherDatastoreError(nil,
+			fmt.Sprintf("unknown mock template: %s", name))
+	}
+	return template, nil
+}
+
+// Set the template of a mock keyspace. This should be done before the
+// keyspace is used.
+func SetTemplate(ks datastore.Keyspace, template Template) errors.Error {
+	b, ok := ks.(*keyspace)
+	if !ok {
+		return errors.NewOtherDatastoreError(nil,
+			fmt.Sprintf("not a mock keyspace: %s", ks.Name()))
+	}
+
+	if template == nil {
+		template = defaultTemplate
+	}
+
+	b.template = template
+	return nil
+}
+
+func defaultTemplate(i, nitems int, r *Random) map[string]interface{} {
+	return map[string]interface{}{"id": strconv.Itoa(i), "i": float64(i)}
+}
+
+var _CITIES = []string{"San Francisco", "New York", "London", "Bangalore", "Tokyo"}
+
+var _STATUSES = []string{"shipped", "pending", "cancelled"}
+
+/*
+Orders with a nested customer and an array of 1 to 5 line items. The
+data is skewed: a tenth of the customers place half of the orders,
+the first city is as frequent as all others together, and 80% of the
+orders are shipped, 15% pending and 5% cancelled. Quantities are
+random.
+*/
+func ordersTemplate(i, nitems int, r *Random) map[string]interface{} {
+	ncustomers := nitems/10 + 1
+	customer := i % ncustomers
+	if i%2 == 0 {
+		customer %= ncustomers/10 + 1
+	}
+
+	city := _CITIES[0]
+	if customer%2 == 1 {
+		city = _CITIES[1+(customer/2)%(len(_CITIES)-1)]
+	}
+
+	status := _STATUSES[0]
+	switch {
+	case i%20 == 19:
+		status = _STATUSES[2]
+	case i%20 >= 16:
+		status = _STATUSES[1]
+	}
+
+	nlines := 1 + (i*7)%5
+	lines := make([]interface{}, nlines)
+	total := 0.0
+	for j := 0; j < nlines; j++ {
+		product := (i*31 + j*17) % 1000
+		qty := 1 + r.Intn(4)
+		price := float64(1+product%100) + 0.99
+		total += float64(qty) * price
+		lines[j] = map[string]interface{}{
+			"product": "p" + strconv.Itoa(product),
+			"qty":     float64(qty),
+			"price":   price,
+		}
+	}
+
+	return map[string]interface{}{
+		"id":     strconv.Itoa(i),
+		"i":      float64(i),
+		"status": status,
+		"customer": map[string]interface{}{
+			"id":   "c" + strconv.Itoa(customer),
+			"name": "Customer " + strconv.Itoa(customer),
+			"address": map[string]interface{}{
+				"city": city,
+				"zip":  fmt.Sprintf("%05d", (customer*7919)%100000),
+			},
+		},
+		"lineitems": lines,
+		"total":     total,
+	}
+}
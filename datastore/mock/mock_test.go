@@ -193,6 +193,263 @@ func TestMockIndex(t *testing.T) {
 	items, err = doIndexScan(t, b, span)
 }
 
+func TestMockTemplate(t *testing.T) {
+	s, err := NewDatastore("mock:items=100,template=orders")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	p, _ := s.NamespaceByName("p0")
+	b, _ := p.KeyspaceByName("b0")
+
+	vs, errs := b.Fetch([]string{"42"})
+	if errs != nil || len(vs) != 1 {
+		t.Fatalf("expected item 42")
+	}
+
+	city, ok := vs[0].Value.Field("customer")
+	if ok {
+		city, ok = city.Field("address")
+	}
+	if ok {
+		city, ok = city.Field("city")
+	}
+	if !ok || city.Type() != value.STRING {
+		t.Fatalf("expected item.customer.address.city, got %v", vs[0].Value)
+	}
+
+	lines, ok := vs[0].Value.Field("lineitems")
+	if !ok || lines.Type() != value.ARRAY {
+		t.Fatalf("expected item.lineitems array, got %v", lines)
+	}
+
+	// The same seed generates the same documents
+	for _, seed := range []string{"0", "7"} {
+		s1, _ := NewDatastore("mock:items=100,template=orders,seed=" + seed)
+		s2, _ := NewDatastore("mock:items=100,template=orders,seed=" + seed)
+		vs1, _ := fetchItem(s1, "42")
+		vs2, _ := fetchItem(s2, "42")
+		if !vs1.Equals(vs2).Truth() {
+			t.Fatalf("expected the same item 42 for seed %s, got %v and %v", seed, vs1, vs2)
+		}
+	}
+
+	_, err = NewDatastore("mock:template=not-a-template")
+	if err == nil {
+		t.Fatalf("expected not-a-template")
+	}
+
+	err = SetTemplate(b, func(i, nitems int, r *Random) map[string]interface{} {
+		return map[string]interface{}{"n": float64(nitems - i)}
+	})
+	if err != nil {
+		t.Fatalf("failed to set template: %v", err)
+	}
+
+	vs, _ = b.Fetch([]string{"42"})
+	n, _ := vs[0].Value.Field("n")
+	if n.Actual() != 58.0 {
+		t.Fatalf("expected item.n 58, got %v", n)
+	}
+}
+
+func TestMockFaults(t *testing.T) {
+	s, err := NewDatastore("mock:items=1000,fetcherrors=50,scantimeout=3,seed=1")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	p, _ := s.NamespaceByName("p0")
+	b, _ := p.KeyspaceByName("b0")
+
+	keys := make([]string, 100)
+	for i := range keys {
+		keys[i] = strconv.Itoa(i)
+	}
+
+	vs, errs := b.Fetch(keys)
+	if len(errs) == 0 || len(vs) == 0 || len(vs)+len(errs) != len(keys) {
+		t.Fatalf("expected some fetch errors, got %d items and %d errors", len(vs), len(errs))
+	}
+
+	again, _ := b.Fetch(keys)
+	for i := range vs {
+		if vs[i].Key != again[i].Key {
+			t.Fatalf("expected the same fetch errors, got %v and %v", vs[i].Key, again[i].Key)
+		}
+	}
+
+	context := &faultContext{}
+	conn := datastore.NewIndexConnection(context)
+	conn.SetPrimary()
+	indexer, _ := b.Indexer(datastore.DEFAULT)
+	primaries, _ := indexer.PrimaryIndexes()
+	go primaries[0].ScanEntries("", 0, datastore.UNBOUNDED, nil, conn)
+
+	n := 0
+	for _ = range conn.EntryChannel() {
+		n++
+	}
+
+	if n != 3 || !conn.Timeout() {
+		t.Fatalf("expected a scan timeout after 3 entries, got %d entries", n)
+	}
+}
+
+type faultContext struct {
+	errors []errors.Error
+}
+
+func (this *faultContext) Error(err errors.Error) {
+	this.errors = append(this.errors, err)
+}
+
+func (this *faultContext) Warning(wrn errors.Error) {
+}
+
+func (this *faultContext) Fatal(fatal errors.Error) {
+	this.errors = append(this.errors, fatal)
+}
+
+func TestMockStatistics(t *testing.T) {
+	s, err := NewDatastore("mock:items=1234")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	p, _ := s.NamespaceByName("p0")
+	b, _ := p.KeyspaceByName("b0")
+	indexer, _ := b.Indexer(datastore.DEFAULT)
+	primaries, _ := indexer.PrimaryIndexes()
+
+	bounds := []string{"", "0", "00", "1", "10", "12", "123", "1234", "2", "20a", "5", "99",
+		"999", "a", "-1", "1\x00"}
+
+	// Statistics must describe the keys scanned
+	for _, low := range bounds {
+		for _, high := range bounds {
+			for _, inclusion := range []datastore.Inclusion{datastore.NEITHER, datastore.BOTH} {
+				span := &datastore.Span{Range: datastore.Range{Inclusion: inclusion}}
+				if low != "" {
+					span.Range.Low = value.Values{value.NewValue(low)}
+				}
+				if high != "" {
+					span.Range.High = value.Values{value.NewValue(high)}
+				}
+
+				items, _ := doIndexScan(t, b, span)
+				min, max := "", ""
+				for n, item := range items {
+					if n == 0 || item.PrimaryKey < min {
+						min = item.PrimaryKey
+					}
+					if n == 0 || item.PrimaryKey > max {
+						max = item.PrimaryKey
+					}
+				}
+
+				stats, err := primaries[0].Statistics("", span)
+				if err != nil {
+					t.Fatalf("unexpected error: %v", err)
+				}
+
+				c, _ := stats.Count()
+				if c != int64(len(items)) {
+					t.Errorf("expected count of %v to be %d, got %d", span.Range, len(items), c)
+				}
+
+				if len(items) > 0 {
+					mn, _ := stats.Min()
+					mx, _ := stats.Max()
+					if mn[0].Actual() != min || mx[0].Actual() != max {
+						t.Errorf("expected bounds of %v to be %s, %s, got %v, %v",
+							span.Range, min, max, mn, mx)
+					}
+				}
+			}
+		}
+	}
+
+	s, err = NewDatastore("mock:items=5000000000,seed=42")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	p, _ = s.NamespaceByName("p0")
+	b, _ = p.KeyspaceByName("b0")
+
+	vs, errs := b.Fetch([]string{"4999999999"})
+	if errs != nil || len(vs) != 1 {
+		t.Fatalf("expected item 4999999999")
+	}
+
+	// Statistics of all the keys, without scanning them
+	indexer, _ = b.Indexer(datastore.DEFAULT)
+	primaries, _ = indexer.PrimaryIndexes()
+	stats, _ := primaries[0].Statistics("", &datastore.Span{})
+	c, _ := stats.Count()
+	mn, _ := stats.Min()
+	mx, _ := stats.Max()
+	if c != 5000000000 || mn[0].Actual() != "0" || mx[0].Actual() != "999999999" {
+		t.Fatalf("expected 5000000000 keys from 0 to 999999999, got %d from %v to %v", c, mn, mx)
+	}
+}
+
+func TestMockReplicas(t *testing.T) {
+	s, err := NewDatastore("mock:items=10,replicas=2")
+	if err != nil {
+		t.Fatalf("failed to create store: %v", err)
+	}
+
+	p, _ := s.NamespaceById("p0")
+	ks, _ := p.KeyspaceById("b0")
+	b := ks.(*keyspace)
+	if b.Replicas() != 2 {
+		t.Fatalf("expected 2 replicas, got %v", b.Replicas())
+	}
+
+	defer datastore.SetReplicaPolicy(datastore.GetReplicaPolicy())
+
+	// Reads are routed to the keyspace, the first replica, and in turn
+	datastore.SetReplicaPolicy(datastore.PRIMARY_ONLY)
+	if datastore.ReadKeyspace(b) != b {
+		t.Fatalf("expected primary_only to read the keyspace")
+	}
+
+	datastore.SetReplicaPolicy(datastore.PREFER_REPLICA)
+	if datastore.ReadKeyspace(b) != b.replicas[0] {
+		t.Fatalf("expected prefer_replica to read the first replica")
+	}
+
+	datastore.SetReplicaPolicy(datastore.ROUND_ROBIN)
+	for i := 0; i < 6; i++ {
+		datastore.ReadKeyspace(b).Fetch([]string{"1"})
+	}
+	for _, r := range []*keyspace{b, b.replicas[0], b.replicas[1]} {
+		if r.fetches != 2 {
+			t.Fatalf("expected round_robin to fetch twice from each, got %v", r.fetches)
+		}
+	}
+
+	// Scans are routed to the index of the same name on the replica
+	datastore.SetReplicaPolicy(datastore.PREFER_REPLICA)
+	index, _ := b.mi.IndexByName("#primary")
+	replica := datastore.ReadIndex(b, index)
+	if replica == index || replica.(*primaryIndex).keyspace != b.replicas[0] {
+		t.Fatalf("expected prefer_replica to scan the first replica's #primary")
+	}
+}
+
+func fetchItem(s datastore.Datastore, key string) (value.Value, []errors.Error) {
+	p, _ := s.NamespaceByName("p0")
+	b, _ := p.KeyspaceByName("b0")
+	vs, errs := b.Fetch([]string{key})
+	if len(vs) == 0 {
+		return nil, errs
+	}
+	return vs[0].Value, errs
+}
+
 type testingContext struct {
 	t *testing.T
 }
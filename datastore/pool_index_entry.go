@@ -0,0 +1,67 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/value"
+)
+
+/*
+IndexEntryPool recycles the index entries sent by scans, which are
+otherwise allocated per scanned row. Entries are owned by the
+receiver of the entry channel, which may return them to the pool
+once it no longer refers to them.
+*/
+type IndexEntryPool struct {
+	pool *sync.Pool
+}
+
+func NewIndexEntryPool() *IndexEntryPool {
+	rv := &IndexEntryPool{
+		pool: &sync.Pool{
+			New: func() interface{} {
+				return &IndexEntry{}
+			},
+		},
+	}
+
+	return rv
+}
+
+func (this *IndexEntryPool) Get() *IndexEntry {
+	return this.pool.Get().(*IndexEntry)
+}
+
+func (this *IndexEntryPool) Put(entry *IndexEntry) {
+	if entry == nil {
+		return
+	}
+
+	entry.EntryKey = nil
+	entry.PrimaryKey = ""
+	this.pool.Put(entry)
+}
+
+var _INDEX_ENTRY_POOL = NewIndexEntryPool()
+
+// Allocate an index entry to be sent on an entry channel.
+func NewIndexEntry(entryKey value.Values, primaryKey string) *IndexEntry {
+	rv := _INDEX_ENTRY_POOL.Get()
+	rv.EntryKey = entryKey
+	rv.PrimaryKey = primaryKey
+	return rv
+}
+
+// Return a received index entry to the pool.
+func ReleaseIndexEntry(entry *IndexEntry) {
+	_INDEX_ENTRY_POOL.Put(entry)
+}
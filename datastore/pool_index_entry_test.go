@@ -0,0 +1,48 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestIndexEntryPool(t *testing.T) {
+	key := value.Values{value.NewValue(1)}
+	entry := NewIndexEntry(key, "k1")
+	if entry.PrimaryKey != "k1" || len(entry.EntryKey) != 1 {
+		t.Errorf("Unexpected entry %v", entry)
+	}
+
+	ReleaseIndexEntry(entry)
+	if entry.PrimaryKey != "" || entry.EntryKey != nil {
+		t.Errorf("Expected released entry to be cleared, got %v", entry)
+	}
+
+	ReleaseIndexEntry(nil)
+}
+
+func BenchmarkIndexEntryAlloc(b *testing.B) {
+	ch := make(EntryChannel, 1)
+	for i := 0; i < b.N; i++ {
+		entry := IndexEntry{PrimaryKey: "k1"}
+		ch <- &entry
+		<-ch
+	}
+}
+
+func BenchmarkIndexEntryPool(b *testing.B) {
+	ch := make(EntryChannel, 1)
+	for i := 0; i < b.N; i++ {
+		ch <- NewIndexEntry(nil, "k1")
+		ReleaseIndexEntry(<-ch)
+	}
+}
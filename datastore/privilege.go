@@ -17,8 +17,13 @@ const (
 	PRIV_READ  Privilege = 1
 	PRIV_WRITE Privilege = 2
 	PRIV_DDL   Privilege = 3
+	PRIV_ADMIN Privilege = 4
 )
 
+// Privileges on the cluster, rather than on a keyspace, are keyed by
+// the empty keyspace name.
+const CLUSTER = ""
+
 /*
 Type Privileges maps string of the form "namespace:keyspace" to
 privileges.
@@ -29,6 +34,11 @@ func NewPrivileges() Privileges {
 	return make(Privileges, 16)
 }
 
+// The privileges of an administrator of the cluster.
+func AdminPrivileges() Privileges {
+	return Privileges{CLUSTER: PRIV_ADMIN}
+}
+
 func (this Privileges) Add(other Privileges) {
 	for k, p := range other {
 		tp, ok := this[k]
@@ -38,6 +48,16 @@ func (this Privileges) Add(other Privileges) {
 	}
 }
 
+/*
+AdminKeyspace is implemented by keyspaces, such as those of the
+system namespace that act on the server, whose mutations require the
+admin privilege besides the privileges on the keyspace.
+*/
+type AdminKeyspace interface {
+	Keyspace
+	AdminMutations() bool
+}
+
 /*
 Type Credentials maps users to passwords.
 */
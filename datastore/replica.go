@@ -0,0 +1,125 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+	"sync"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+ReplicatedKeyspace is implemented by keyspaces that keep read
+replicas of their documents and indexes. Replicas may lag behind
+the keyspace, so only reads that need not observe the latest
+mutations are routed to them, according to the replica policy.
+*/
+type ReplicatedKeyspace interface {
+	Keyspace
+	Replicas() int                          // Number of read replicas
+	Replica(n int) (Keyspace, errors.Error) // Read replica n, from 1 to Replicas()
+}
+
+// Where reads of replicated keyspaces are routed.
+type ReplicaPolicy string
+
+const (
+	PRIMARY_ONLY   ReplicaPolicy = "primary_only"   // Read the keyspace itself
+	PREFER_REPLICA ReplicaPolicy = "prefer_replica" // Read the first replica that is available
+	ROUND_ROBIN    ReplicaPolicy = "round_robin"    // Read the keyspace and its replicas in turn
+)
+
+var _REPLICA_POLICY struct {
+	sync.RWMutex
+	policy ReplicaPolicy
+}
+
+var _REPLICA_TURN atomic.AlignedUint64
+
+func ParseReplicaPolicy(s string) (ReplicaPolicy, bool) {
+	switch policy := ReplicaPolicy(strings.ToLower(s)); policy {
+	case PRIMARY_ONLY, PREFER_REPLICA, ROUND_ROBIN:
+		return policy, true
+	default:
+		return "", false
+	}
+}
+
+func SetReplicaPolicy(policy ReplicaPolicy) {
+	_REPLICA_POLICY.Lock()
+	defer _REPLICA_POLICY.Unlock()
+	_REPLICA_POLICY.policy = policy
+}
+
+func GetReplicaPolicy() ReplicaPolicy {
+	_REPLICA_POLICY.RLock()
+	defer _REPLICA_POLICY.RUnlock()
+	if _REPLICA_POLICY.policy == "" {
+		return PRIMARY_ONLY
+	}
+
+	return _REPLICA_POLICY.policy
+}
+
+/*
+Returns the copy of the keyspace that a read is routed to under the
+replica policy: the keyspace itself, or one of its replicas. Replicas
+that cannot be had are passed over.
+*/
+func ReadKeyspace(keyspace Keyspace) Keyspace {
+	replicated, ok := keyspace.(ReplicatedKeyspace)
+	if !ok || replicated.Replicas() == 0 {
+		return keyspace
+	}
+
+	n := replicated.Replicas()
+	switch GetReplicaPolicy() {
+	case PREFER_REPLICA:
+		for i := 1; i <= n; i++ {
+			if replica, err := replicated.Replica(i); err == nil {
+				return replica
+			}
+		}
+	case ROUND_ROBIN:
+		turn := int(atomic.AddUint64(&_REPLICA_TURN, 1) % uint64(n+1))
+		if turn > 0 {
+			if replica, err := replicated.Replica(turn); err == nil {
+				return replica
+			}
+		}
+	}
+
+	return keyspace
+}
+
+/*
+Returns the index that a scan is routed to under the replica policy:
+the index itself, or the index of the same name on a replica.
+*/
+func ReadIndex(keyspace Keyspace, index Index) Index {
+	replica := ReadKeyspace(keyspace)
+	if replica == keyspace {
+		return index
+	}
+
+	indexer, err := replica.Indexer(index.Type())
+	if err != nil {
+		return index
+	}
+
+	rv, err := indexer.IndexByName(index.Name())
+	if err != nil {
+		return index
+	}
+
+	return rv
+}
@@ -0,0 +1,169 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+)
+
+// Roles that can be granted to users.
+const (
+	ROLE_ADMIN              = "admin"              // All privileges on all keyspaces
+	ROLE_QUERY_SELECT       = "query_select"       // PRIV_READ
+	ROLE_QUERY_UPDATE       = "query_update"       // PRIV_WRITE
+	ROLE_QUERY_MANAGE_INDEX = "query_manage_index" // PRIV_DDL
+)
+
+var _ROLE_PRIVILEGES = map[string]Privilege{
+	ROLE_ADMIN:              PRIV_ADMIN,
+	ROLE_QUERY_SELECT:       PRIV_READ,
+	ROLE_QUERY_UPDATE:       PRIV_WRITE,
+	ROLE_QUERY_MANAGE_INDEX: PRIV_DDL,
+}
+
+func IsRole(name string) bool {
+	_, ok := _ROLE_PRIVILEGES[name]
+	return ok
+}
+
+// Role names, sorted.
+func RoleNames() []string {
+	rv := make([]string, 0, len(_ROLE_PRIVILEGES))
+	for name, _ := range _ROLE_PRIVILEGES {
+		rv = append(rv, name)
+	}
+
+	sort.Strings(rv)
+	return rv
+}
+
+/*
+Role is a role granted on a keyspace, named namespace:keyspace, or
+on all keyspaces if the keyspace is empty. The admin role is only
+granted on all keyspaces.
+*/
+type Role struct {
+	Name     string `json:"role"`
+	Keyspace string `json:"keyspace,omitempty"`
+}
+
+// Returns true if the role grants the privilege on the keyspace.
+// Privileges are ordered, and each implies the lesser ones.
+func (this Role) Grants(keyspace string, privilege Privilege) bool {
+	if this.Keyspace != "" && !strings.EqualFold(this.Keyspace, keyspace) {
+		return false
+	}
+
+	return privilege <= _ROLE_PRIVILEGES[this.Name]
+}
+
+type UserInfo struct {
+	Id    string `json:"id"`
+	Roles []Role `json:"roles"`
+}
+
+/*
+RoleCatalog holds the roles granted to users. Granting a role that
+is already held, or revoking one that is not, is not an error.
+*/
+type RoleCatalog interface {
+	GrantRole(user string, role Role) errors.Error
+	RevokeRole(user string, role Role) errors.Error
+	Users() ([]UserInfo, errors.Error) // Users holding any role, sorted by id
+}
+
+/*
+Returns nil if any of the users is granted every privilege. If no
+roles have been granted at all, authorization on keyspaces is not
+enforced, but privileges on the cluster are never granted; the first
+admin must be added to the catalog by its owner.
+*/
+func AuthorizeRoles(catalog RoleCatalog, privileges Privileges, users []string) errors.Error {
+	infos, err := catalog.Users()
+	if err != nil {
+		return err
+	}
+
+	if len(infos) == 0 {
+		if _, ok := privileges[CLUSTER]; ok {
+			return errors.NewDatastoreAuthorizationError(nil, "for the cluster")
+		}
+
+		return nil
+	}
+
+	roles := make([]Role, 0, 8)
+	for _, info := range infos {
+		for _, user := range users {
+			if info.Id == user {
+				roles = append(roles, info.Roles...)
+			}
+		}
+	}
+
+	keyspace, ok := RolesGrant(roles, privileges)
+	if !ok {
+		if keyspace == CLUSTER {
+			return errors.NewDatastoreAuthorizationError(nil, "for the cluster")
+		}
+
+		return errors.NewDatastoreAuthorizationError(nil, "for keyspace "+keyspace)
+	}
+
+	return nil
+}
+
+/*
+Returns nil if the roles, as granted by an authenticator, or the
+credentials, as verified by the datastore, hold the admin privilege.
+*/
+func AuthorizeAdmin(store Datastore, roles []Role, credentials Credentials) errors.Error {
+	privs := AdminPrivileges()
+	if _, ok := RolesGrant(roles, privs); ok {
+		return nil
+	}
+
+	if store == nil {
+		return errors.NewDatastoreAuthorizationError(nil, "for the cluster")
+	}
+
+	return store.Authorize(privs, credentials)
+}
+
+/*
+Returns true if the roles grant every privilege; otherwise, also
+returns a keyspace lacking its privilege. Privileges on the system
+namespace and on temporary keyspaces are always granted.
+*/
+func RolesGrant(roles []Role, privileges Privileges) (string, bool) {
+	for keyspace, privilege := range privileges {
+		if strings.HasPrefix(strings.ToLower(keyspace), "#system:") ||
+			IsTemporary(keyspace[strings.IndexByte(keyspace, ':')+1:]) {
+			continue
+		}
+
+		granted := false
+		for _, role := range roles {
+			if role.Grants(keyspace, privilege) {
+				granted = true
+				break
+			}
+		}
+
+		if !granted {
+			return keyspace, false
+		}
+	}
+
+	return "", true
+}
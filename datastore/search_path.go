@@ -0,0 +1,80 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore
+
+import (
+	"strings"
+	"sync"
+)
+
+/*
+The search path lists the namespaces in which keyspaces referenced
+without a namespace are looked up, after the default namespace of
+the request. The first namespace containing the keyspace is used.
+*/
+var _SEARCH_PATH struct {
+	sync.RWMutex
+	path []string
+}
+
+// Empty names and the system namespace are dropped.
+func SetSearchPath(path []string) {
+	rv := make([]string, 0, len(path))
+	for _, ns := range path {
+		ns = strings.TrimSpace(ns)
+		if ns != "" && strings.ToLower(ns) != "#system" {
+			rv = append(rv, ns)
+		}
+	}
+
+	_SEARCH_PATH.Lock()
+	defer _SEARCH_PATH.Unlock()
+	_SEARCH_PATH.path = rv
+}
+
+func GetSearchPath() []string {
+	_SEARCH_PATH.RLock()
+	defer _SEARCH_PATH.RUnlock()
+	return _SEARCH_PATH.path
+}
+
+/*
+Returns the namespace of an unqualified keyspace: the first of the
+default namespace and the search path that contains the keyspace.
+If none does, the default namespace is returned, so that lookups
+report the keyspace missing from there.
+*/
+func ResolveNamespace(datastore Datastore, dflt, keyspace string) string {
+	if datastore == nil {
+		return dflt
+	}
+
+	if dflt != "" && hasKeyspace(datastore, dflt, keyspace) {
+		return dflt
+	}
+
+	for _, ns := range GetSearchPath() {
+		if ns != dflt && hasKeyspace(datastore, ns, keyspace) {
+			return ns
+		}
+	}
+
+	return dflt
+}
+
+func hasKeyspace(datastore Datastore, namespace, keyspace string) bool {
+	ns, err := datastore.NamespaceByName(namespace)
+	if err != nil {
+		return false
+	}
+
+	ks, err := ns.KeyspaceByName(keyspace)
+	return err == nil && ks != nil
+}
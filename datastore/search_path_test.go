@@ -0,0 +1,45 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package datastore_test
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+)
+
+func TestResolveNamespace(t *testing.T) {
+	store, err := mock.NewDatastore("mock:namespaces=2,keyspaces=2,items=1")
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	datastore.SetSearchPath([]string{"", "#system", "nowhere", "p1"})
+	defer datastore.SetSearchPath(nil)
+
+	path := datastore.GetSearchPath()
+	if len(path) != 2 || path[0] != "nowhere" || path[1] != "p1" {
+		t.Errorf("Unexpected search path %v", path)
+	}
+
+	// The default namespace comes first
+	if ns := datastore.ResolveNamespace(store, "p0", "b0"); ns != "p0" {
+		t.Errorf("Expected p0, got %s", ns)
+	}
+
+	if ns := datastore.ResolveNamespace(store, "default", "b1"); ns != "p1" {
+		t.Errorf("Expected p1, got %s", ns)
+	}
+
+	if ns := datastore.ResolveNamespace(store, "default", "b9"); ns != "default" {
+		t.Errorf("Expected default, got %s", ns)
+	}
+}
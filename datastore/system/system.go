@@ -10,6 +10,7 @@
 package system
 
 import (
+	"github.com/couchbase/query/accounting"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/logging"
@@ -22,9 +23,21 @@ const KEYSPACE_NAME_NAMESPACES = "namespaces"
 const KEYSPACE_NAME_KEYSPACES = "keyspaces"
 const KEYSPACE_NAME_INDEXES = "indexes"
 const KEYSPACE_NAME_DUAL = "dual"
+const KEYSPACE_NAME_METRICS = "metrics"
+const KEYSPACE_NAME_QUOTAS = "quotas"
+const KEYSPACE_NAME_POLICIES = "policies"
+const KEYSPACE_NAME_SESSIONS = "sessions"
+const KEYSPACE_NAME_USER_INFO = "user_info"
+const KEYSPACE_NAME_APPLICABLE_ROLES = "applicable_roles"
+const KEYSPACE_NAME_ACTIVE_REQUESTS = "active_requests"
+const KEYSPACE_NAME_FUNCTIONS = "functions"
+const KEYSPACE_NAME_TRANSACTIONS = "transactions"
+const KEYSPACE_NAME_TASKS = "tasks"
+const KEYSPACE_NAME_VIEWS = "views"
 
 type store struct {
 	actualStore              datastore.Datastore
+	acctStore                accounting.AccountingStore
 	systemDatastoreNamespace *namespace
 }
 
@@ -72,12 +85,17 @@ func (s *store) Authorize(datastore.Privileges, datastore.Credentials) errors.Er
 	return nil
 }
 
+func (s *store) RoleCatalog() (datastore.RoleCatalog, errors.Error) {
+	return s.actualStore.RoleCatalog()
+}
+
 func (s *store) SetLogLevel(level logging.Level) {
 	// No-op. Uses query engine logger.
 }
 
-func NewDatastore(actualStore datastore.Datastore) (datastore.Datastore, errors.Error) {
-	s := &store{actualStore: actualStore}
+func NewDatastore(actualStore datastore.Datastore,
+	acctStore accounting.AccountingStore) (datastore.Datastore, errors.Error) {
+	s := &store{actualStore: actualStore, acctStore: acctStore}
 
 	e := s.loadNamespace()
 	if e != nil {
@@ -0,0 +1,223 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/couchbase/query/active"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type activeRequestsKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *activeRequestsKeyspace) Release() {
+}
+
+func (b *activeRequestsKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *activeRequestsKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *activeRequestsKeyspace) Name() string {
+	return b.name
+}
+
+func (b *activeRequestsKeyspace) Count() (int64, errors.Error) {
+	return int64(active.Count()), nil
+}
+
+func (b *activeRequestsKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *activeRequestsKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *activeRequestsKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		r := active.Get(k)
+		if r == nil {
+			continue
+		}
+
+		users := make([]interface{}, len(r.Users()))
+		for i, user := range r.Users() {
+			users[i] = user
+		}
+
+		doc := map[string]interface{}{
+			"id":          r.Id(),
+			"statement":   r.Statement(),
+			"users":       users,
+			"requestTime": r.RequestTime().Format(time.RFC3339Nano),
+			"elapsedTime": time.Since(r.RequestTime()).String(),
+			"state":       r.State(),
+		}
+		if r.ClientId() != "" {
+			doc["clientContextID"] = r.ClientId()
+		}
+		if fingerprint := r.Fingerprint(); fingerprint != "" {
+			doc["fingerprint"] = fingerprint
+		}
+		if progress := r.Progress(); progress != nil {
+			doc["progress"] = progress
+		}
+
+		item := value.NewAnnotatedValue(doc)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *activeRequestsKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:active_requests.")
+}
+
+func (b *activeRequestsKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:active_requests.")
+}
+
+func (b *activeRequestsKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:active_requests.")
+}
+
+// Cancelling requests requires the admin privilege.
+func (b *activeRequestsKeyspace) AdminMutations() bool {
+	return true
+}
+
+// Deleting an active request cancels it.
+func (b *activeRequestsKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	rv := make([]string, 0, len(deletes))
+	for _, k := range deletes {
+		if active.Cancel(k) {
+			rv = append(rv, k)
+		}
+	}
+
+	return rv, nil
+}
+
+func newActiveRequestsKeyspace(p *namespace) (*activeRequestsKeyspace, errors.Error) {
+	b := new(activeRequestsKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_ACTIVE_REQUESTS
+
+	primary := &activeRequestsIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type activeRequestsIndex struct {
+	name     string
+	keyspace *activeRequestsKeyspace
+}
+
+func (pi *activeRequestsIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *activeRequestsIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *activeRequestsIndex) Name() string {
+	return pi.name
+}
+
+func (pi *activeRequestsIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *activeRequestsIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *activeRequestsIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *activeRequestsIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *activeRequestsIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *activeRequestsIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *activeRequestsIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *activeRequestsIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:active_requests")
+}
+
+func (pi *activeRequestsIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	if active.Get(val) != nil {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+	}
+}
+
+func (pi *activeRequestsIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, r := range active.Requests() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, r.Id())
+	}
+}
@@ -0,0 +1,213 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type applicableRolesKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *applicableRolesKeyspace) Release() {
+}
+
+func (b *applicableRolesKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *applicableRolesKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *applicableRolesKeyspace) Name() string {
+	return b.name
+}
+
+func (b *applicableRolesKeyspace) Count() (int64, errors.Error) {
+	keys, _ := applicableRoleDocs(b.namespace.store)
+	return int64(len(keys)), nil
+}
+
+func (b *applicableRolesKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *applicableRolesKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *applicableRolesKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	_, docs := applicableRoleDocs(b.namespace.store)
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		doc, ok := docs[k]
+		if !ok {
+			continue
+		}
+
+		item := value.NewAnnotatedValue(doc)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *applicableRolesKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:applicable_roles.")
+}
+
+func (b *applicableRolesKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:applicable_roles.")
+}
+
+func (b *applicableRolesKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:applicable_roles.")
+}
+
+func (b *applicableRolesKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:applicable_roles.")
+}
+
+func newApplicableRolesKeyspace(p *namespace) (*applicableRolesKeyspace, errors.Error) {
+	b := new(applicableRolesKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_APPLICABLE_ROLES
+
+	primary := &applicableRolesIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type applicableRolesIndex struct {
+	name     string
+	keyspace *applicableRolesKeyspace
+}
+
+func (pi *applicableRolesIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *applicableRolesIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *applicableRolesIndex) Name() string {
+	return pi.name
+}
+
+func (pi *applicableRolesIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *applicableRolesIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *applicableRolesIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *applicableRolesIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *applicableRolesIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *applicableRolesIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *applicableRolesIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *applicableRolesIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:applicable_roles")
+}
+
+func (pi *applicableRolesIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	_, docs := applicableRoleDocs(pi.keyspace.namespace.store)
+	if _, ok := docs[val]; ok {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+	}
+}
+
+func (pi *applicableRolesIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	keys, _ := applicableRoleDocs(pi.keyspace.namespace.store)
+	for i, k := range keys {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, k)
+	}
+}
+
+// One document for each role granted to each user.
+func applicableRoleDocs(s *store) ([]string, map[string]map[string]interface{}) {
+	keys := make([]string, 0, 16)
+	docs := make(map[string]map[string]interface{}, 16)
+	for _, u := range userInfo(s) {
+		for _, r := range u.Roles {
+			key := u.Id + "/" + r.Name + "/" + r.Keyspace
+			doc := map[string]interface{}{
+				"grantee": u.Id,
+				"role":    r.Name,
+			}
+			if r.Keyspace != "" {
+				doc["keyspace"] = r.Keyspace
+			}
+
+			keys = append(keys, key)
+			docs[key] = doc
+		}
+	}
+
+	return keys, docs
+}
@@ -0,0 +1,283 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Catalog provides the documents of a system keyspace for engine state
+that is kept outside the datastores, such as user-defined functions,
+transactions and background tasks. Entries have an id, a status, an
+owner, and timing fields formatted as RFC 3339.
+*/
+type Catalog interface {
+	Ids() []string                                  // Ids of the entries, sorted
+	Entry(id string) (map[string]interface{}, bool) // Entry with the given id
+}
+
+/*
+MutableCatalog is implemented by catalogs whose entries can be
+changed by updating their documents in the system keyspace.
+*/
+type MutableCatalog interface {
+	Catalog
+	Update(id string, entry value.Value) errors.Error // Apply the changed document of an entry
+}
+
+/*
+DeletableCatalog is implemented by catalogs whose entries can be
+removed by deleting their documents from the system keyspace.
+*/
+type DeletableCatalog interface {
+	Catalog
+	Delete(id string) errors.Error // Remove an entry
+}
+
+var _CATALOGS = struct {
+	sync.RWMutex
+	catalogs map[string]Catalog
+}{catalogs: make(map[string]Catalog)}
+
+// Set the catalog of the system keyspace with the given name. The
+// keyspace is empty until its catalog is set.
+func SetCatalog(name string, catalog Catalog) {
+	_CATALOGS.Lock()
+	defer _CATALOGS.Unlock()
+	_CATALOGS.catalogs[name] = catalog
+}
+
+func getCatalog(name string) Catalog {
+	_CATALOGS.RLock()
+	defer _CATALOGS.RUnlock()
+	return _CATALOGS.catalogs[name]
+}
+
+type catalogKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *catalogKeyspace) Release() {
+}
+
+func (b *catalogKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *catalogKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *catalogKeyspace) Name() string {
+	return b.name
+}
+
+func (b *catalogKeyspace) ids() []string {
+	catalog := getCatalog(b.name)
+	if catalog == nil {
+		return nil
+	}
+	return catalog.Ids()
+}
+
+func (b *catalogKeyspace) Count() (int64, errors.Error) {
+	return int64(len(b.ids())), nil
+}
+
+func (b *catalogKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *catalogKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *catalogKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	catalog := getCatalog(b.name)
+	if catalog == nil {
+		return nil, nil
+	}
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		entry, ok := catalog.Entry(k)
+		if !ok {
+			continue
+		}
+
+		item := value.NewAnnotatedValue(entry)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *catalogKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:"+b.name+".")
+}
+
+func (b *catalogKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.update(updates)
+}
+
+func (b *catalogKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.update(upserts)
+}
+
+// Existing entries of mutable catalogs can be updated. Entries are
+// never added by mutations.
+func (b *catalogKeyspace) update(pairs []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	catalog, ok := getCatalog(b.name).(MutableCatalog)
+	if !ok {
+		return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:"+b.name+".")
+	}
+
+	for i, pair := range pairs {
+		err := catalog.Update(pair.Key, pair.Value)
+		if err != nil {
+			return pairs[:i], err
+		}
+	}
+
+	return pairs, nil
+}
+
+// Entries of deletable catalogs can be deleted.
+func (b *catalogKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	catalog, ok := getCatalog(b.name).(DeletableCatalog)
+	if !ok {
+		return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:"+b.name+".")
+	}
+
+	for i, key := range deletes {
+		err := catalog.Delete(key)
+		if err != nil {
+			return deletes[:i], err
+		}
+	}
+
+	return deletes, nil
+}
+
+func newCatalogKeyspace(p *namespace, name string) (*catalogKeyspace, errors.Error) {
+	b := new(catalogKeyspace)
+	b.namespace = p
+	b.name = name
+
+	primary := &catalogIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type catalogIndex struct {
+	name     string
+	keyspace *catalogKeyspace
+}
+
+func (pi *catalogIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *catalogIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *catalogIndex) Name() string {
+	return pi.name
+}
+
+func (pi *catalogIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *catalogIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *catalogIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *catalogIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *catalogIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *catalogIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *catalogIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *catalogIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:"+pi.keyspace.name)
+}
+
+func (pi *catalogIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	catalog := getCatalog(pi.keyspace.name)
+	if catalog == nil {
+		return
+	}
+
+	if _, ok := catalog.Entry(val); ok {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+	}
+}
+
+func (pi *catalogIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, id := range pi.keyspace.ids() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, id)
+	}
+}
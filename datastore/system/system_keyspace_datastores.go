@@ -278,8 +278,7 @@ func (pi *storeIndex) Scan(requestId string, span *datastore.Span, distinct bool
 	}
 
 	if strings.EqualFold(val, pi.keyspace.namespace.store.actualStore.Id()) {
-		entry := datastore.IndexEntry{PrimaryKey: pi.keyspace.namespace.store.actualStore.Id()}
-		conn.EntryChannel() <- &entry
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, pi.keyspace.namespace.store.actualStore.Id())
 	}
 }
 
@@ -287,6 +286,5 @@ func (pi *storeIndex) ScanEntries(requestId string, limit int64, cons datastore.
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
-	entry := datastore.IndexEntry{PrimaryKey: pi.keyspace.namespace.store.actualStore.Id()}
-	conn.EntryChannel() <- &entry
+	conn.EntryChannel() <- datastore.NewIndexEntry(nil, pi.keyspace.namespace.store.actualStore.Id())
 }
@@ -178,8 +178,7 @@ func (pi *dualIndex) Scan(requestId string, span *datastore.Span, distinct bool,
 	}
 
 	if strings.EqualFold(val, KEYSPACE_NAME_DUAL) {
-		entry := datastore.IndexEntry{PrimaryKey: KEYSPACE_NAME_DUAL}
-		conn.EntryChannel() <- &entry
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, KEYSPACE_NAME_DUAL)
 	}
 }
 
@@ -187,6 +186,5 @@ func (pi *dualIndex) ScanEntries(requestId string, limit int64, cons datastore.S
 	vector timestamp.Vector, conn *datastore.IndexConnection) {
 	defer close(conn.EntryChannel())
 
-	entry := datastore.IndexEntry{PrimaryKey: KEYSPACE_NAME_DUAL}
-	conn.EntryChannel() <- &entry
+	conn.EntryChannel() <- datastore.NewIndexEntry(nil, KEYSPACE_NAME_DUAL)
 }
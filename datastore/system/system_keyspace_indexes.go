@@ -328,7 +328,6 @@ func (pi *indexIndex) ScanEntries(requestId string, limit int64, cons datastore.
 	}
 
 	for k, _ := range keys {
-		entry := datastore.IndexEntry{PrimaryKey: k}
-		conn.EntryChannel() <- &entry
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, k)
 	}
 }
@@ -113,6 +113,26 @@ func (b *keyspaceKeyspace) fetchOne(key string) (value.AnnotatedValue, errors.Er
 				"namespace_id": namespace.Id(),
 				"datastore_id": b.namespace.store.actualStore.Id(),
 			})
+
+			names := datastore.KeyspaceCapabilities(keyspace).Names()
+			capabilities := make([]interface{}, len(names))
+			for i, name := range names {
+				capabilities[i] = name
+			}
+			doc.SetField("capabilities", capabilities)
+
+			// Keyspaces in scopes show their bucket and scope
+			bucket, scope, _ := datastore.SplitKeyspacePath(keyspace.Name())
+			if bucket != "" {
+				doc.SetField("bucket", bucket)
+				doc.SetField("scope", scope)
+			}
+
+			if sized, ok := keyspace.(datastore.SizedKeyspace); ok {
+				if size, err := sized.Size(); err == nil {
+					doc.SetField("size", size)
+				}
+			}
 			return doc, nil
 		}
 		if err != nil {
@@ -230,8 +250,7 @@ func (pi *keyspaceIndex) Scan(requestId string, span *datastore.Span, distinct b
 
 	keyspace, _ := namespace.KeyspaceById(ids[1])
 	if keyspace != nil {
-		entry := datastore.IndexEntry{PrimaryKey: fmt.Sprintf("%s/%s", namespace.Id(), keyspace.Id())}
-		conn.EntryChannel() <- &entry
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, fmt.Sprintf("%s/%s", namespace.Id(), keyspace.Id()))
 	}
 }
 
@@ -250,8 +269,7 @@ func (pi *keyspaceIndex) ScanEntries(requestId string, limit int64, cons datasto
 						if limit > 0 && int64(i) > limit {
 							break
 						}
-						entry := datastore.IndexEntry{PrimaryKey: fmt.Sprintf("%s/%s", namespaceId, keyspaceId)}
-						conn.EntryChannel() <- &entry
+						conn.EntryChannel() <- datastore.NewIndexEntry(nil, fmt.Sprintf("%s/%s", namespaceId, keyspaceId))
 					}
 				}
 			}
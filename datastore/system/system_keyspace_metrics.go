@@ -0,0 +1,282 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+	"sort"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type metricsKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *metricsKeyspace) Release() {
+}
+
+func (b *metricsKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *metricsKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *metricsKeyspace) Name() string {
+	return b.name
+}
+
+func (b *metricsKeyspace) Count() (int64, errors.Error) {
+	return int64(len(b.metricNames())), nil
+}
+
+func (b *metricsKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *metricsKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *metricsKeyspace) registry() accounting.MetricRegistry {
+	acctstore := b.namespace.store.acctStore
+	if acctstore == nil {
+		return nil
+	}
+
+	return acctstore.MetricRegistry()
+}
+
+func (b *metricsKeyspace) metricNames() []string {
+	registry := b.registry()
+	if registry == nil {
+		return nil
+	}
+
+	rv := make([]string, 0, 32)
+	for name, _ := range registry.Counters() {
+		rv = append(rv, name)
+	}
+	for name, _ := range registry.Gauges() {
+		rv = append(rv, name)
+	}
+	for name, _ := range registry.Meters() {
+		rv = append(rv, name)
+	}
+	for name, _ := range registry.Timers() {
+		rv = append(rv, name)
+	}
+	for name, _ := range registry.Histograms() {
+		rv = append(rv, name)
+	}
+
+	sort.Strings(rv)
+	return rv
+}
+
+func (b *metricsKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	var errs []errors.Error
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		item, e := b.fetchOne(k)
+		if e != nil {
+			if errs == nil {
+				errs = make([]errors.Error, 0, 1)
+			}
+			errs = append(errs, e)
+			continue
+		}
+
+		if item != nil {
+			item.SetAttachment("meta", map[string]interface{}{
+				"id": k,
+			})
+
+			rv = append(rv, datastore.AnnotatedPair{
+				Key:   k,
+				Value: item,
+			})
+		}
+	}
+
+	return rv, errs
+}
+
+func (b *metricsKeyspace) fetchOne(key string) (value.AnnotatedValue, errors.Error) {
+	registry := b.registry()
+	if registry == nil {
+		return nil, nil
+	}
+
+	var doc map[string]interface{}
+
+	switch metric := registry.Get(key).(type) {
+	case accounting.Counter:
+		doc = map[string]interface{}{
+			"type":  "counter",
+			"count": metric.Count(),
+		}
+	case accounting.Gauge:
+		doc = map[string]interface{}{
+			"type":  "gauge",
+			"value": metric.Value(),
+		}
+	case accounting.Meter:
+		doc = map[string]interface{}{
+			"type":     "meter",
+			"count":    metric.Count(),
+			"1m.rate":  metric.Rate1(),
+			"5m.rate":  metric.Rate5(),
+			"15m.rate": metric.Rate15(),
+			"mean":     metric.RateMean(),
+		}
+	case accounting.Timer:
+		doc = map[string]interface{}{
+			"type":  "timer",
+			"count": metric.Count(),
+			"min":   metric.Min(),
+			"max":   metric.Max(),
+			"mean":  metric.Mean(),
+		}
+	case accounting.Histogram:
+		doc = map[string]interface{}{
+			"type":  "histogram",
+			"count": metric.Count(),
+			"min":   metric.Min(),
+			"max":   metric.Max(),
+			"mean":  metric.Mean(),
+		}
+	default:
+		return nil, nil
+	}
+
+	doc["name"] = key
+	return value.NewAnnotatedValue(doc), nil
+}
+
+func (b *metricsKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:metrics.")
+}
+
+func (b *metricsKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:metrics.")
+}
+
+func (b *metricsKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:metrics.")
+}
+
+func (b *metricsKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:metrics.")
+}
+
+func newMetricsKeyspace(p *namespace) (*metricsKeyspace, errors.Error) {
+	b := new(metricsKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_METRICS
+
+	primary := &metricsIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type metricsIndex struct {
+	name     string
+	keyspace *metricsKeyspace
+}
+
+func (pi *metricsIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *metricsIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *metricsIndex) Name() string {
+	return pi.name
+}
+
+func (pi *metricsIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *metricsIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *metricsIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *metricsIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *metricsIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *metricsIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *metricsIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *metricsIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:metrics")
+}
+
+func (pi *metricsIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	registry := pi.keyspace.registry()
+	if registry != nil && registry.Get(val) != nil {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+	}
+}
+
+func (pi *metricsIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, name := range pi.keyspace.metricNames() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, name)
+	}
+}
@@ -196,8 +196,7 @@ func (pi *namespaceIndex) Scan(requestId string, span *datastore.Span, distinct
 
 	namespace, _ := pi.keyspace.namespace.store.actualStore.NamespaceById(val)
 	if namespace != nil {
-		entry := datastore.IndexEntry{PrimaryKey: namespace.Id()}
-		conn.EntryChannel() <- &entry
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, namespace.Id())
 	}
 }
 
@@ -212,8 +211,7 @@ func (pi *namespaceIndex) ScanEntries(requestId string, limit int64, cons datast
 				break
 			}
 
-			entry := datastore.IndexEntry{PrimaryKey: namespaceId}
-			conn.EntryChannel() <- &entry
+			conn.EntryChannel() <- datastore.NewIndexEntry(nil, namespaceId)
 		}
 	}
 }
@@ -0,0 +1,211 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type policiesKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *policiesKeyspace) Release() {
+}
+
+func (b *policiesKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *policiesKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *policiesKeyspace) Name() string {
+	return b.name
+}
+
+func (b *policiesKeyspace) Count() (int64, errors.Error) {
+	return int64(policy.Count()), nil
+}
+
+func (b *policiesKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *policiesKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *policiesKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	policies := make(map[string]*policy.Policy, policy.Count())
+	for _, p := range policy.Policies() {
+		policies[policy.Key(p.Namespace(), p.Keyspace())] = p
+	}
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		p, ok := policies[k]
+		if !ok {
+			continue
+		}
+
+		masks := make([]interface{}, len(p.Masks()))
+		for i, m := range p.Masks() {
+			masks[i] = map[string]interface{}{
+				"path": m.Path().String(),
+				"type": m.Type(),
+			}
+		}
+
+		doc := map[string]interface{}{
+			"namespace": p.Namespace(),
+			"keyspace":  p.Keyspace(),
+			"masks":     masks,
+		}
+		if p.Predicate() != nil {
+			doc["predicate"] = p.Predicate().String()
+		}
+
+		item := value.NewAnnotatedValue(doc)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *policiesKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:policies.")
+}
+
+func (b *policiesKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:policies.")
+}
+
+func (b *policiesKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:policies.")
+}
+
+func (b *policiesKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:policies.")
+}
+
+func newPoliciesKeyspace(p *namespace) (*policiesKeyspace, errors.Error) {
+	b := new(policiesKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_POLICIES
+
+	primary := &policiesIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type policiesIndex struct {
+	name     string
+	keyspace *policiesKeyspace
+}
+
+func (pi *policiesIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *policiesIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *policiesIndex) Name() string {
+	return pi.name
+}
+
+func (pi *policiesIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *policiesIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *policiesIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *policiesIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *policiesIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *policiesIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *policiesIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *policiesIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:policies")
+}
+
+func (pi *policiesIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	for _, p := range policy.Policies() {
+		if policy.Key(p.Namespace(), p.Keyspace()) == val {
+			conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+			return
+		}
+	}
+}
+
+func (pi *policiesIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, p := range policy.Policies() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, policy.Key(p.Namespace(), p.Keyspace()))
+	}
+}
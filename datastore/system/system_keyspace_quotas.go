@@ -0,0 +1,211 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/quota"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type quotasKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *quotasKeyspace) Release() {
+}
+
+func (b *quotasKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *quotasKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *quotasKeyspace) Name() string {
+	return b.name
+}
+
+func (b *quotasKeyspace) Count() (int64, errors.Error) {
+	return int64(quota.Count()), nil
+}
+
+func (b *quotasKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *quotasKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *quotasKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	quotas := make(map[string]*quota.Quota, quota.Count())
+	for _, q := range quota.Quotas() {
+		quotas[quota.Key(q.Kind(), q.Name())] = q
+	}
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		q, ok := quotas[k]
+		if !ok {
+			continue
+		}
+
+		limits := q.Limits()
+		usage := q.Usage()
+		item := value.NewAnnotatedValue(map[string]interface{}{
+			"kind": string(q.Kind()),
+			"name": q.Name(),
+			"limits": map[string]interface{}{
+				"documents":   limits.Documents,
+				"mutations":   limits.Mutations,
+				"result_size": limits.ResultSize,
+			},
+			"usage": map[string]interface{}{
+				"requests":    usage.Requests,
+				"exceeded":    usage.Exceeded,
+				"documents":   usage.Documents,
+				"mutations":   usage.Mutations,
+				"result_size": usage.ResultSize,
+			},
+		})
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *quotasKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:quotas.")
+}
+
+func (b *quotasKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:quotas.")
+}
+
+func (b *quotasKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:quotas.")
+}
+
+func (b *quotasKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:quotas.")
+}
+
+func newQuotasKeyspace(p *namespace) (*quotasKeyspace, errors.Error) {
+	b := new(quotasKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_QUOTAS
+
+	primary := &quotasIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type quotasIndex struct {
+	name     string
+	keyspace *quotasKeyspace
+}
+
+func (pi *quotasIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *quotasIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *quotasIndex) Name() string {
+	return pi.name
+}
+
+func (pi *quotasIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *quotasIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *quotasIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *quotasIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *quotasIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *quotasIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *quotasIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *quotasIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:quotas")
+}
+
+func (pi *quotasIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	for _, q := range quota.Quotas() {
+		if quota.Key(q.Kind(), q.Name()) == val {
+			conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+			return
+		}
+	}
+}
+
+func (pi *quotasIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, q := range quota.Quotas() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, quota.Key(q.Kind(), q.Name()))
+	}
+}
@@ -0,0 +1,212 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/session"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type sessionsKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *sessionsKeyspace) Release() {
+}
+
+func (b *sessionsKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *sessionsKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *sessionsKeyspace) Name() string {
+	return b.name
+}
+
+func (b *sessionsKeyspace) Count() (int64, errors.Error) {
+	return int64(session.Count()), nil
+}
+
+func (b *sessionsKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *sessionsKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *sessionsKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	sessions := make(map[string]*session.Session, session.Count())
+	for _, s := range session.Sessions() {
+		sessions[s.Id()] = s
+	}
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		s, ok := sessions[k]
+		if !ok {
+			continue
+		}
+
+		users := make([]interface{}, len(s.Users()))
+		for i, user := range s.Users() {
+			users[i] = user
+		}
+
+		settings := make(map[string]interface{}, 4)
+		for name, setting := range s.Settings() {
+			settings[name] = setting
+		}
+
+		item := value.NewAnnotatedValue(map[string]interface{}{
+			"id":       s.Id(),
+			"users":    users,
+			"created":  s.Created().Format(time.RFC3339),
+			"lastUsed": s.LastUsed().Format(time.RFC3339),
+			"requests": s.Requests(),
+			"settings": settings,
+		})
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *sessionsKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:sessions.")
+}
+
+func (b *sessionsKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:sessions.")
+}
+
+func (b *sessionsKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:sessions.")
+}
+
+func (b *sessionsKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:sessions.")
+}
+
+func newSessionsKeyspace(p *namespace) (*sessionsKeyspace, errors.Error) {
+	b := new(sessionsKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_SESSIONS
+
+	primary := &sessionsIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type sessionsIndex struct {
+	name     string
+	keyspace *sessionsKeyspace
+}
+
+func (pi *sessionsIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *sessionsIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *sessionsIndex) Name() string {
+	return pi.name
+}
+
+func (pi *sessionsIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *sessionsIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *sessionsIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *sessionsIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *sessionsIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *sessionsIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *sessionsIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *sessionsIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:sessions")
+}
+
+func (pi *sessionsIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	for _, s := range session.Sessions() {
+		if s.Id() == val {
+			conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+			return
+		}
+	}
+}
+
+func (pi *sessionsIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	for i, s := range session.Sessions() {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, s.Id())
+	}
+}
@@ -0,0 +1,239 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package system
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+type userInfoKeyspace struct {
+	namespace *namespace
+	name      string
+	indexer   datastore.Indexer
+}
+
+func (b *userInfoKeyspace) Release() {
+}
+
+func (b *userInfoKeyspace) NamespaceId() string {
+	return b.namespace.Id()
+}
+
+func (b *userInfoKeyspace) Id() string {
+	return b.Name()
+}
+
+func (b *userInfoKeyspace) Name() string {
+	return b.name
+}
+
+func (b *userInfoKeyspace) Count() (int64, errors.Error) {
+	keys, _ := userInfoDocs(b.namespace.store)
+	return int64(len(keys)), nil
+}
+
+func (b *userInfoKeyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *userInfoKeyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *userInfoKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	_, docs := userInfoDocs(b.namespace.store)
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		doc, ok := docs[k]
+		if !ok {
+			continue
+		}
+
+		item := value.NewAnnotatedValue(doc)
+		item.SetAttachment("meta", map[string]interface{}{
+			"id": k,
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: item,
+		})
+	}
+
+	return rv, nil
+}
+
+func (b *userInfoKeyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:user_info.")
+}
+
+func (b *userInfoKeyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:user_info.")
+}
+
+func (b *userInfoKeyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:user_info.")
+}
+
+func (b *userInfoKeyspace) Delete(deletes []string) ([]string, errors.Error) {
+	return nil, errors.NewSystemDatastoreError(nil, "Mutations not allowed on system:user_info.")
+}
+
+func newUserInfoKeyspace(p *namespace) (*userInfoKeyspace, errors.Error) {
+	b := new(userInfoKeyspace)
+	b.namespace = p
+	b.name = KEYSPACE_NAME_USER_INFO
+
+	primary := &userInfoIndex{name: "#primary", keyspace: b}
+	b.indexer = &systemIndexer{keyspace: b, indexes: make(map[string]datastore.Index), primary: primary}
+
+	return b, nil
+}
+
+type userInfoIndex struct {
+	name     string
+	keyspace *userInfoKeyspace
+}
+
+func (pi *userInfoIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *userInfoIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *userInfoIndex) Name() string {
+	return pi.name
+}
+
+func (pi *userInfoIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *userInfoIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *userInfoIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *userInfoIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *userInfoIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *userInfoIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *userInfoIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *userInfoIndex) Drop(requestId string) errors.Error {
+	return errors.NewSystemIdxNoDropError(nil, "For system:user_info")
+}
+
+func (pi *userInfoIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	val := ""
+
+	a := span.Seek[0].Actual()
+	switch a := a.(type) {
+	case string:
+		val = a
+	default:
+		conn.Error(errors.NewSystemDatastoreError(nil, fmt.Sprintf("Invalid seek value %v of type %T.", a, a)))
+		return
+	}
+
+	_, docs := userInfoDocs(pi.keyspace.namespace.store)
+	if _, ok := docs[val]; ok {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, val)
+	}
+}
+
+func (pi *userInfoIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	keys, _ := userInfoDocs(pi.keyspace.namespace.store)
+	for i, k := range keys {
+		if limit > 0 && int64(i) >= limit {
+			break
+		}
+
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, k)
+	}
+}
+
+/*
+Returns the sorted keys and the documents of the users holding
+roles in the actual datastore. Datastores without a role catalog
+have no users.
+*/
+func userInfoDocs(s *store) ([]string, map[string]map[string]interface{}) {
+	users := userInfo(s)
+	keys := make([]string, len(users))
+	docs := make(map[string]map[string]interface{}, len(users))
+	for i, u := range users {
+		keys[i] = u.Id
+		docs[u.Id] = map[string]interface{}{
+			"id":    u.Id,
+			"roles": roleDocs(u.Roles),
+		}
+	}
+
+	return keys, docs
+}
+
+func userInfo(s *store) []datastore.UserInfo {
+	catalog, err := s.actualStore.RoleCatalog()
+	if err != nil || catalog == nil {
+		return nil
+	}
+
+	users, err := catalog.Users()
+	if err != nil {
+		return nil
+	}
+
+	return users
+}
+
+func roleDocs(roles []datastore.Role) []interface{} {
+	rv := make([]interface{}, len(roles))
+	for i, r := range roles {
+		doc := map[string]interface{}{
+			"role": r.Name,
+		}
+		if r.Keyspace != "" {
+			doc["keyspace"] = r.Keyspace
+		}
+		rv[i] = doc
+	}
+
+	return rv
+}
@@ -107,5 +107,56 @@ func (p *namespace) loadKeyspaces() (e errors.Error) {
 	}
 	p.keyspaces[ib.Name()] = ib
 
+	mb, e := newMetricsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[mb.Name()] = mb
+
+	qb, e := newQuotasKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[qb.Name()] = qb
+
+	lb, e := newPoliciesKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[lb.Name()] = lb
+
+	eb, e := newSessionsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[eb.Name()] = eb
+
+	ub, e := newUserInfoKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[ub.Name()] = ub
+
+	rb, e := newApplicableRolesKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[rb.Name()] = rb
+
+	ab, e := newActiveRequestsKeyspace(p)
+	if e != nil {
+		return e
+	}
+	p.keyspaces[ab.Name()] = ab
+
+	for _, name := range []string{KEYSPACE_NAME_FUNCTIONS, KEYSPACE_NAME_TRANSACTIONS, KEYSPACE_NAME_TASKS,
+		KEYSPACE_NAME_VIEWS} {
+		cb, e := newCatalogKeyspace(p, name)
+		if e != nil {
+			return e
+		}
+		p.keyspaces[cb.Name()] = cb
+	}
+
 	return nil
 }
@@ -10,6 +10,7 @@
 package system
 
 import (
+	"sort"
 	"testing"
 
 	"github.com/couchbase/query/datastore"
@@ -25,7 +26,7 @@ func TestSystem(t *testing.T) {
 	}
 
 	// Create systems store with mock m as the ActualStore
-	s, err := NewDatastore(m)
+	s, err := NewDatastore(m, nil)
 	if err != nil {
 		t.Fatalf("failed to create system store: %v", err)
 	}
@@ -178,3 +179,74 @@ func doPrimaryIndexScan(t *testing.T, b datastore.Keyspace) (m map[string]bool,
 		m[v.PrimaryKey] = true
 	}
 }
+
+type testCatalog map[string]map[string]interface{}
+
+func (this testCatalog) Ids() []string {
+	ids := make([]string, 0, len(this))
+	for id, _ := range this {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
+
+func (this testCatalog) Entry(id string) (map[string]interface{}, bool) {
+	entry, ok := this[id]
+	return entry, ok
+}
+
+func TestCatalog(t *testing.T) {
+	m, err := mock.NewDatastore("mock:")
+	if err != nil {
+		t.Fatalf("failed to create mock store: %v", err)
+	}
+
+	s, err := NewDatastore(m, nil)
+	if err != nil {
+		t.Fatalf("failed to create system store: %v", err)
+	}
+
+	p, err := s.NamespaceByName("#system")
+	if err != nil {
+		t.Fatalf("failed to get system namespace: %v", err)
+	}
+
+	tb, err := p.KeyspaceByName(KEYSPACE_NAME_TASKS)
+	if err != nil {
+		t.Fatalf("failed to get keyspace by name %v", err)
+	}
+
+	// Expect no tasks until a catalog is set
+	SetCatalog(KEYSPACE_NAME_TASKS, nil)
+	tb_c, err := tb.Count()
+	if err != nil || tb_c != 0 {
+		t.Fatalf("failed to get expected tasks keyspace count %v", err)
+	}
+
+	SetCatalog(KEYSPACE_NAME_TASKS, testCatalog{
+		"t1": {"id": "t1", "status": "running", "owner": "admin"},
+		"t2": {"id": "t2", "status": "scheduled", "owner": "admin"},
+	})
+	defer SetCatalog(KEYSPACE_NAME_TASKS, nil)
+
+	tb_c, err = tb.Count()
+	if err != nil || tb_c != 2 {
+		t.Fatalf("failed to get expected tasks keyspace count %v", err)
+	}
+
+	tb_e, err := doPrimaryIndexScan(t, tb)
+	if err != nil || !tb_e["t1"] || !tb_e["t2"] {
+		t.Fatalf("failed to get expected task ids from index scan: %v", tb_e)
+	}
+
+	pairs, errs := tb.Fetch([]string{"t2", "t3"})
+	if errs != nil || len(pairs) != 1 {
+		t.Fatalf("failed to fetch expected tasks: %v", errs)
+	}
+
+	status, _ := pairs[0].Value.Field("status")
+	if status.Actual() != "scheduled" {
+		t.Fatalf("unexpected task status %v", status)
+	}
+}
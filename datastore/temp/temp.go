@@ -0,0 +1,308 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package temp provides temporary keyspaces, which hold their documents
+in memory. They belong to a session, or else to a request, and are
+dropped when it ends, so that the statements of a workflow can stage
+intermediate results without writing them to real keyspaces.
+
+A temporary keyspace is named with a leading '#', and hides any
+keyspace of the same name in its namespace.
+*/
+package temp
+
+import (
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Keyspaces holds the temporary keyspaces of a session or a request.
+*/
+type Keyspaces struct {
+	sync.RWMutex
+	keyspaces map[string]*keyspace
+}
+
+func NewKeyspaces() *Keyspaces {
+	return &Keyspaces{
+		keyspaces: make(map[string]*keyspace),
+	}
+}
+
+func (this *Keyspaces) Create(namespace, name string) (datastore.Keyspace, errors.Error) {
+	if !datastore.IsTemporary(name) {
+		return nil, errors.NewOtherNotSupportedError(nil,
+			"- temporary keyspace names must start with #: "+name)
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	key := keyspaceKey(namespace, name)
+	if _, ok := this.keyspaces[key]; ok {
+		return nil, errors.NewOtherDuplicateKeyspaceError(nil, namespace+":"+name)
+	}
+
+	rv := newKeyspace(namespace, name)
+	this.keyspaces[key] = rv
+	return rv, nil
+}
+
+func (this *Keyspaces) Get(namespace, name string) (datastore.Keyspace, bool) {
+	this.RLock()
+	defer this.RUnlock()
+
+	rv, ok := this.keyspaces[keyspaceKey(namespace, name)]
+	if !ok {
+		return nil, false
+	}
+
+	return rv, true
+}
+
+func (this *Keyspaces) Drop(namespace, name string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+
+	key := keyspaceKey(namespace, name)
+	ks, ok := this.keyspaces[key]
+	if !ok {
+		return errors.NewOtherKeyspaceNotFoundError(nil, namespace+":"+name)
+	}
+
+	delete(this.keyspaces, key)
+	ks.drop()
+	return nil
+}
+
+// Drop all the keyspaces, when their session or request ends.
+func (this *Keyspaces) DropAll() {
+	this.Lock()
+	defer this.Unlock()
+
+	for key, ks := range this.keyspaces {
+		delete(this.keyspaces, key)
+		ks.drop()
+	}
+}
+
+func (this *Keyspaces) Count() int {
+	this.RLock()
+	defer this.RUnlock()
+	return len(this.keyspaces)
+}
+
+// Names of the keyspaces in the namespace, sorted.
+func (this *Keyspaces) Names(namespace string) []string {
+	this.RLock()
+	defer this.RUnlock()
+
+	var rv []string
+	for _, ks := range this.keyspaces {
+		if strings.EqualFold(ks.namespace, namespace) {
+			rv = append(rv, ks.name)
+		}
+	}
+
+	sort.Strings(rv)
+	return rv
+}
+
+// Namespace names are not case sensitive.
+func keyspaceKey(namespace, name string) string {
+	return strings.ToLower(namespace) + ":" + name
+}
+
+type keyspace struct {
+	sync.RWMutex
+	namespace string
+	name      string
+	docs      map[string][]byte
+	indexer   *indexer
+}
+
+func newKeyspace(namespace, name string) *keyspace {
+	rv := &keyspace{
+		namespace: namespace,
+		name:      name,
+		docs:      make(map[string][]byte),
+	}
+
+	rv.indexer = newIndexer(rv)
+	return rv
+}
+
+func (b *keyspace) NamespaceId() string {
+	return b.namespace
+}
+
+func (b *keyspace) Id() string {
+	return b.name
+}
+
+func (b *keyspace) Name() string {
+	return b.name
+}
+
+func (b *keyspace) Count() (int64, errors.Error) {
+	b.RLock()
+	defer b.RUnlock()
+	return int64(len(b.docs)), nil
+}
+
+// Temporary keyspaces have no secondary indexes.
+func (b *keyspace) Capabilities() datastore.Capabilities {
+	return datastore.CAPABILITY_DML | datastore.CAPABILITY_COUNT | datastore.CAPABILITY_PROJECTION
+}
+
+func (b *keyspace) Indexer(name datastore.IndexType) (datastore.Indexer, errors.Error) {
+	return b.indexer, nil
+}
+
+func (b *keyspace) Indexers() ([]datastore.Indexer, errors.Error) {
+	return []datastore.Indexer{b.indexer}, nil
+}
+
+func (b *keyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	b.RLock()
+	defer b.RUnlock()
+
+	rv := make([]datastore.AnnotatedPair, 0, len(keys))
+	for _, k := range keys {
+		bytes, ok := b.docs[k]
+		if !ok {
+			// Missing keys denote non-existent documents
+			continue
+		}
+
+		doc := value.NewAnnotatedValue(value.NewValue(bytes))
+		doc.SetAttachment("meta", map[string]interface{}{
+			"id":   k,
+			"size": len(bytes),
+		})
+
+		rv = append(rv, datastore.AnnotatedPair{
+			Key:   k,
+			Value: doc,
+		})
+	}
+
+	return rv, nil
+}
+
+const (
+	_INSERT = iota
+	_UPDATE
+	_UPSERT
+)
+
+func (b *keyspace) Insert(inserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(_INSERT, inserts)
+}
+
+func (b *keyspace) Update(updates []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(_UPDATE, updates)
+}
+
+func (b *keyspace) Upsert(upserts []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	return b.performOp(_UPSERT, upserts)
+}
+
+// Documents are held in their JSON encoding, so that later changes to
+// the values written do not change them.
+func (b *keyspace) performOp(op int, pairs []datastore.Pair) ([]datastore.Pair, errors.Error) {
+	b.Lock()
+	defer b.Unlock()
+
+	if b.docs == nil {
+		return nil, errors.NewOtherKeyspaceNotFoundError(nil, b.namespace+":"+b.name)
+	}
+
+	var returnErr errors.Error
+	rv := make([]datastore.Pair, 0, len(pairs))
+	for _, kv := range pairs {
+		_, exists := b.docs[kv.Key]
+		if op == _INSERT && exists {
+			returnErr = errors.NewOtherKeyExistsError(returnErr, kv.Key)
+			continue
+		}
+
+		if op == _UPDATE && !exists {
+			returnErr = errors.NewOtherKeyNotFoundError(returnErr, kv.Key)
+			continue
+		}
+
+		bytes, err := json.Marshal(kv.Value.Actual())
+		if err != nil {
+			returnErr = errors.NewOtherDatastoreError(err, "encoding "+kv.Key)
+			continue
+		}
+
+		b.docs[kv.Key] = bytes
+		rv = append(rv, kv)
+	}
+
+	return rv, returnErr
+}
+
+func (b *keyspace) Delete(deletes []string) ([]string, errors.Error) {
+	b.Lock()
+	defer b.Unlock()
+
+	rv := make([]string, 0, len(deletes))
+	for _, key := range deletes {
+		if _, ok := b.docs[key]; ok {
+			delete(b.docs, key)
+			rv = append(rv, key)
+		}
+	}
+
+	return rv, nil
+}
+
+func (b *keyspace) Release() {
+}
+
+// Plans that still hold a dropped keyspace find it empty, and cannot
+// write to it.
+func (b *keyspace) drop() {
+	b.Lock()
+	defer b.Unlock()
+	b.docs = nil
+}
+
+// The keys within the bounds, sorted.
+func (b *keyspace) keys(low, high string, inclusion datastore.Inclusion) []string {
+	b.RLock()
+	defer b.RUnlock()
+
+	rv := make([]string, 0, len(b.docs))
+	for key, _ := range b.docs {
+		if low != "" && (key < low || (key == low && inclusion&datastore.LOW == 0)) {
+			continue
+		}
+
+		if high != "" && (key > high || (key == high && inclusion&datastore.HIGH == 0)) {
+			continue
+		}
+
+		rv = append(rv, key)
+	}
+
+	sort.Strings(rv)
+	return rv
+}
@@ -0,0 +1,214 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package temp
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/timestamp"
+	"github.com/couchbase/query/value"
+)
+
+const PRIMARY_INDEX = "#primary"
+
+// Temporary keyspaces have only their primary index.
+type indexer struct {
+	keyspace *keyspace
+	primary  *primaryIndex
+}
+
+func newIndexer(keyspace *keyspace) *indexer {
+	return &indexer{
+		keyspace: keyspace,
+		primary: &primaryIndex{
+			name:     PRIMARY_INDEX,
+			keyspace: keyspace,
+		},
+	}
+}
+
+func (ti *indexer) KeyspaceId() string {
+	return ti.keyspace.Id()
+}
+
+func (ti *indexer) Name() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (ti *indexer) IndexIds() ([]string, errors.Error) {
+	return []string{ti.primary.Id()}, nil
+}
+
+func (ti *indexer) IndexNames() ([]string, errors.Error) {
+	return []string{ti.primary.Name()}, nil
+}
+
+func (ti *indexer) IndexById(id string) (datastore.Index, errors.Error) {
+	return ti.IndexByName(id)
+}
+
+func (ti *indexer) IndexByName(name string) (datastore.Index, errors.Error) {
+	if name != ti.primary.Name() {
+		return nil, errors.NewOtherIdxNotFoundError(nil, name+" for temporary keyspace")
+	}
+	return ti.primary, nil
+}
+
+func (ti *indexer) PrimaryIndexes() ([]datastore.PrimaryIndex, errors.Error) {
+	return []datastore.PrimaryIndex{ti.primary}, nil
+}
+
+func (ti *indexer) Indexes() ([]datastore.Index, errors.Error) {
+	return []datastore.Index{ti.primary}, nil
+}
+
+func (ti *indexer) CreatePrimaryIndex(requestId, name string, with value.Value) (datastore.PrimaryIndex, errors.Error) {
+	return ti.primary, nil
+}
+
+func (ti *indexer) CreateIndex(requestId, name string, equalKey, rangeKey expression.Expressions,
+	where expression.Expression, with value.Value) (datastore.Index, errors.Error) {
+	return nil, errors.NewOtherNotSupportedError(nil, "CREATE INDEX is not supported for temporary keyspaces.")
+}
+
+func (ti *indexer) BuildIndexes(requestId string, names ...string) errors.Error {
+	return errors.NewOtherNotSupportedError(nil, "BUILD INDEXES is not supported for temporary keyspaces.")
+}
+
+func (ti *indexer) Refresh() errors.Error {
+	return nil
+}
+
+func (ti *indexer) SetLogLevel(level logging.Level) {
+	// No-op, uses query engine logger
+}
+
+// primaryIndex scans the sorted keys of a temporary keyspace.
+type primaryIndex struct {
+	name     string
+	keyspace *keyspace
+}
+
+func (pi *primaryIndex) KeyspaceId() string {
+	return pi.keyspace.Id()
+}
+
+func (pi *primaryIndex) Id() string {
+	return pi.Name()
+}
+
+func (pi *primaryIndex) Name() string {
+	return pi.name
+}
+
+func (pi *primaryIndex) Type() datastore.IndexType {
+	return datastore.DEFAULT
+}
+
+func (pi *primaryIndex) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (pi *primaryIndex) RangeKey() expression.Expressions {
+	return nil
+}
+
+func (pi *primaryIndex) Condition() expression.Expression {
+	return nil
+}
+
+func (pi *primaryIndex) IsPrimary() bool {
+	return true
+}
+
+func (pi *primaryIndex) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (pi *primaryIndex) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, nil
+}
+
+func (pi *primaryIndex) Drop(requestId string) errors.Error {
+	return errors.NewOtherIdxNoDrop(nil, "The primary index of a temporary keyspace cannot be dropped.")
+}
+
+func (pi *primaryIndex) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	low, high, err := spanBounds(span)
+	if err != nil {
+		conn.Error(err)
+		return
+	}
+
+	pi.send(pi.keyspace.keys(low, high, span.Range.Inclusion), 0, limit, conn)
+}
+
+func (pi *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	pi.ScanEntriesOffset(requestId, 0, limit, cons, vector, conn)
+}
+
+func (pi *primaryIndex) ScanEntriesOffset(requestId string, offset, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+
+	pi.send(pi.keyspace.keys("", "", datastore.BOTH), offset, limit, conn)
+}
+
+func (pi *primaryIndex) send(keys []string, offset, limit int64, conn *datastore.IndexConnection) {
+	if offset >= int64(len(keys)) {
+		return
+	}
+
+	keys = keys[offset:]
+	if limit > 0 && limit < int64(len(keys)) {
+		keys = keys[:limit]
+	}
+
+	for _, key := range keys {
+		conn.EntryChannel() <- datastore.NewIndexEntry(nil, key)
+	}
+}
+
+// For primary indexes, bounds must always be strings, so we can just
+// enforce that directly.
+func spanBounds(span *datastore.Span) (low, high string, err errors.Error) {
+	// Ensure that lower bound is a string, if any
+	if len(span.Range.Low) > 0 {
+		a := span.Range.Low[0].Actual()
+		switch a := a.(type) {
+		case string:
+			low = a
+		default:
+			return "", "", errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid lower bound %v of type %T.", a, a))
+		}
+	}
+
+	// Ensure that upper bound is a string, if any
+	if len(span.Range.High) > 0 {
+		a := span.Range.High[0].Actual()
+		switch a := a.(type) {
+		case string:
+			high = a
+		default:
+			return "", "", errors.NewOtherDatastoreError(nil, fmt.Sprintf("Invalid upper bound %v of type %T.", a, a))
+		}
+	}
+
+	return low, high, nil
+}
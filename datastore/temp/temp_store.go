@@ -0,0 +1,153 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package temp
+
+import (
+	"sort"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Returns the datastore as seen by a session or a request: its
+namespaces also hold the temporary keyspaces, which hide the
+keyspaces of the same name, and can create and drop them.
+*/
+func NewDatastore(actual datastore.Datastore, keyspaces *Keyspaces) datastore.Datastore {
+	return &store{
+		Datastore: actual,
+		keyspaces: keyspaces,
+	}
+}
+
+type store struct {
+	datastore.Datastore
+	keyspaces *Keyspaces
+}
+
+// Temporary keyspaces are not written to the datastore, but they
+// cannot be created in a read-only one either.
+func (s *store) Readonly() bool {
+	return datastore.IsReadonly(s.Datastore)
+}
+
+func (s *store) NamespaceById(id string) (datastore.Namespace, errors.Error) {
+	ns, err := s.Datastore.NamespaceById(id)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.namespace(ns), nil
+}
+
+func (s *store) NamespaceByName(name string) (datastore.Namespace, errors.Error) {
+	ns, err := s.Datastore.NamespaceByName(name)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.namespace(ns), nil
+}
+
+func (s *store) namespace(actual datastore.Namespace) datastore.Namespace {
+	rv := &namespace{
+		Namespace: actual,
+		keyspaces: s.keyspaces,
+	}
+
+	if scoped, ok := actual.(datastore.ScopedNamespace); ok {
+		return &scopedNamespace{rv, scoped}
+	}
+
+	return rv
+}
+
+type namespace struct {
+	datastore.Namespace
+	keyspaces *Keyspaces
+}
+
+func (p *namespace) KeyspaceIds() ([]string, errors.Error) {
+	return p.KeyspaceNames()
+}
+
+func (p *namespace) KeyspaceNames() ([]string, errors.Error) {
+	rv, err := p.Namespace.KeyspaceNames()
+	if err != nil {
+		return nil, err
+	}
+
+	names := p.keyspaces.Names(p.Name())
+	if len(names) == 0 {
+		return rv, nil
+	}
+
+	rv = append(rv, names...)
+	sort.Strings(rv)
+	return rv, nil
+}
+
+func (p *namespace) KeyspaceById(id string) (datastore.Keyspace, errors.Error) {
+	if ks, ok := p.keyspaces.Get(p.Name(), id); ok {
+		return ks, nil
+	}
+
+	return p.Namespace.KeyspaceById(id)
+}
+
+func (p *namespace) KeyspaceByName(name string) (datastore.Keyspace, errors.Error) {
+	if ks, ok := p.keyspaces.Get(p.Name(), name); ok {
+		return ks, nil
+	}
+
+	return p.Namespace.KeyspaceByName(name)
+}
+
+// Temporary keyspaces are created and dropped here; others by the
+// namespace that holds them, if it can.
+func (p *namespace) CreateKeyspace(name string) (datastore.Keyspace, errors.Error) {
+	if datastore.IsTemporary(name) {
+		return p.keyspaces.Create(p.Name(), name)
+	}
+
+	manager, ok := p.Namespace.(datastore.KeyspaceManager)
+	if !ok {
+		return nil, errors.NewOtherNotSupportedError(nil, "- creating keyspaces in namespace "+p.Name())
+	}
+
+	return manager.CreateKeyspace(name)
+}
+
+func (p *namespace) DropKeyspace(name string) errors.Error {
+	if datastore.IsTemporary(name) {
+		return p.keyspaces.Drop(p.Name(), name)
+	}
+
+	manager, ok := p.Namespace.(datastore.KeyspaceManager)
+	if !ok {
+		return errors.NewOtherNotSupportedError(nil, "- dropping keyspaces in namespace "+p.Name())
+	}
+
+	return manager.DropKeyspace(name)
+}
+
+type scopedNamespace struct {
+	*namespace
+	scoped datastore.ScopedNamespace
+}
+
+func (p *scopedNamespace) BucketNames() ([]string, errors.Error) {
+	return p.scoped.BucketNames()
+}
+
+func (p *scopedNamespace) ScopeNames(bucket string) ([]string, errors.Error) {
+	return p.scoped.ScopeNames(bucket)
+}
@@ -0,0 +1,121 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package virtual provides hypothetical indexes, which exist only
+for planning. The planner considers them along with the indexes
+of their keyspaces, so that a proposed index can be checked for
+selection without building it. They cannot be scanned.
+*/
+package virtual
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/timestamp"
+)
+
+type index struct {
+	keyspace datastore.Keyspace
+	name     string
+	rangeKey expression.Expressions
+	where    expression.Expression
+}
+
+/*
+Returns a virtual secondary index on the keyspace. The keys and
+condition are unqualified, as in CREATE INDEX.
+*/
+func NewIndex(keyspace datastore.Keyspace, name string, rangeKey expression.Expressions,
+	where expression.Expression) datastore.Index {
+	return &index{
+		keyspace: keyspace,
+		name:     name,
+		rangeKey: rangeKey,
+		where:    where,
+	}
+}
+
+func (this *index) KeyspaceId() string {
+	return this.keyspace.Id()
+}
+
+func (this *index) Id() string {
+	return this.name
+}
+
+func (this *index) Name() string {
+	return this.name
+}
+
+func (this *index) Type() datastore.IndexType {
+	return datastore.VIRTUAL
+}
+
+func (this *index) SeekKey() expression.Expressions {
+	return nil
+}
+
+func (this *index) RangeKey() expression.Expressions {
+	return this.rangeKey
+}
+
+func (this *index) Condition() expression.Expression {
+	return this.where
+}
+
+func (this *index) IsPrimary() bool {
+	return false
+}
+
+func (this *index) State() (state datastore.IndexState, msg string, err errors.Error) {
+	return datastore.ONLINE, "", nil
+}
+
+func (this *index) Statistics(requestId string, span *datastore.Span) (
+	datastore.Statistics, errors.Error) {
+	return nil, errors.NewOtherNotSupportedError(nil, "for virtual index "+this.name)
+}
+
+func (this *index) Drop(requestId string) errors.Error {
+	return errors.NewOtherIdxNoDrop(nil, "Virtual index "+this.name)
+}
+
+func (this *index) Scan(requestId string, span *datastore.Span, distinct bool, limit int64,
+	cons datastore.ScanConsistency, vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+	conn.Error(errors.NewOtherVirtualIndexScanError(this.name))
+}
+
+type primaryIndex struct {
+	index
+}
+
+/*
+Returns a virtual primary index on the keyspace.
+*/
+func NewPrimaryIndex(keyspace datastore.Keyspace, name string) datastore.PrimaryIndex {
+	return &primaryIndex{
+		index: index{
+			keyspace: keyspace,
+			name:     name,
+		},
+	}
+}
+
+func (this *primaryIndex) IsPrimary() bool {
+	return true
+}
+
+func (this *primaryIndex) ScanEntries(requestId string, limit int64, cons datastore.ScanConsistency,
+	vector timestamp.Vector, conn *datastore.IndexConnection) {
+	defer close(conn.EntryChannel())
+	conn.Error(errors.NewOtherVirtualIndexScanError(this.name))
+}
@@ -0,0 +1,261 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"database/sql/driver"
+	"fmt"
+	"io"
+	"math"
+	"sync"
+
+	"github.com/couchbase/query/value"
+)
+
+/*
+The name of the database/sql driver. Its data source name is the URL
+of a datastore, as accepted by New:
+
+	db, err := sql.Open(engine.DRIVER_NAME, "dir:./data")
+	row := db.QueryRow("SELECT name FROM contacts USE KEYS $1", "c1")
+
+The columns of a SELECT are the terms of its projection. Results that
+are not objects of known fields, e.g. of SELECT RAW, have a single
+column named value. Strings, numbers and booleans are scanned as
+such, and objects and arrays as JSON.
+*/
+const DRIVER_NAME = "n1ql-engine"
+
+func init() {
+	sql.Register(DRIVER_NAME, &Driver{engines: make(map[string]*Engine)})
+}
+
+// Connections to the same data source share an engine.
+type Driver struct {
+	sync.Mutex
+	engines map[string]*Engine
+}
+
+func (this *Driver) Open(dsn string) (driver.Conn, error) {
+	this.Lock()
+	defer this.Unlock()
+
+	engine, ok := this.engines[dsn]
+	if !ok {
+		var err error
+		engine, err = New(dsn)
+		if err != nil {
+			return nil, err
+		}
+		this.engines[dsn] = engine
+	}
+
+	return &conn{engine: engine}, nil
+}
+
+/*
+Open a database over an engine, e.g. one whose settings differ from
+the defaults.
+*/
+func OpenDB(engine *Engine) *sql.DB {
+	return sql.OpenDB(&connector{engine: engine})
+}
+
+type connector struct {
+	engine *Engine
+}
+
+func (this *connector) Connect(context.Context) (driver.Conn, error) {
+	return &conn{engine: this.engine}, nil
+}
+
+func (this *connector) Driver() driver.Driver {
+	return &Driver{engines: make(map[string]*Engine)}
+}
+
+type conn struct {
+	engine *Engine
+}
+
+func (this *conn) Prepare(query string) (driver.Stmt, error) {
+	return &stmt{conn: this, query: query}, nil
+}
+
+func (this *conn) Close() error {
+	return nil
+}
+
+func (this *conn) Begin() (driver.Tx, error) {
+	return nil, fmt.Errorf("Transactions are not supported.")
+}
+
+/*
+Args may also be objects and arrays, which database/sql would
+otherwise reject.
+*/
+func (this *conn) CheckNamedValue(nv *driver.NamedValue) error {
+	switch nv.Value.(type) {
+	case map[string]interface{}, []interface{}:
+		return nil
+	}
+
+	v, err := driver.DefaultParameterConverter.ConvertValue(nv.Value)
+	if err != nil {
+		return err
+	}
+
+	nv.Value = v
+	return nil
+}
+
+func (this *conn) QueryContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Rows, error) {
+	r, err := this.engine.Query(ctx, query, namedValues(args)...)
+	if err != nil {
+		return nil, err
+	}
+
+	return &rows{rows: r}, nil
+}
+
+/*
+The rows affected are the mutation count of the statement.
+*/
+func (this *conn) ExecContext(ctx context.Context, query string, args []driver.NamedValue) (driver.Result, error) {
+	r, err := this.engine.Query(ctx, query, namedValues(args)...)
+	if err != nil {
+		return nil, err
+	}
+
+	defer r.Close()
+	for r.Next() {
+	}
+
+	err = r.Err()
+	if err != nil {
+		return nil, err
+	}
+
+	return driver.RowsAffected(r.MutationCount()), nil
+}
+
+func namedValues(args []driver.NamedValue) []interface{} {
+	rv := make([]interface{}, len(args))
+	for i, arg := range args {
+		if arg.Name != "" {
+			rv[i] = sql.Named(arg.Name, arg.Value)
+		} else {
+			rv[i] = arg.Value
+		}
+	}
+
+	return rv
+}
+
+// Statements are parsed and planned each time they are run.
+type stmt struct {
+	conn  *conn
+	query string
+}
+
+func (this *stmt) Close() error {
+	return nil
+}
+
+func (this *stmt) NumInput() int {
+	return -1
+}
+
+func (this *stmt) Exec(args []driver.Value) (driver.Result, error) {
+	return this.ExecContext(context.Background(), values(args))
+}
+
+func (this *stmt) Query(args []driver.Value) (driver.Rows, error) {
+	return this.QueryContext(context.Background(), values(args))
+}
+
+func (this *stmt) ExecContext(ctx context.Context, args []driver.NamedValue) (driver.Result, error) {
+	return this.conn.ExecContext(ctx, this.query, args)
+}
+
+func (this *stmt) QueryContext(ctx context.Context, args []driver.NamedValue) (driver.Rows, error) {
+	return this.conn.QueryContext(ctx, this.query, args)
+}
+
+func values(args []driver.Value) []driver.NamedValue {
+	rv := make([]driver.NamedValue, len(args))
+	for i, arg := range args {
+		rv[i] = driver.NamedValue{Ordinal: i + 1, Value: arg}
+	}
+
+	return rv
+}
+
+type rows struct {
+	rows *Rows
+}
+
+func (this *rows) Columns() []string {
+	columns := this.rows.Columns()
+	if columns == nil {
+		return []string{"value"}
+	}
+
+	return columns
+}
+
+func (this *rows) Close() error {
+	return this.rows.Close()
+}
+
+func (this *rows) Next(dest []driver.Value) error {
+	if !this.rows.Next() {
+		err := this.rows.Err()
+		if err != nil {
+			return err
+		}
+		return io.EOF
+	}
+
+	item := this.rows.Value()
+	if this.rows.Columns() == nil {
+		dest[0] = driverValue(item)
+		return nil
+	}
+
+	for i, name := range this.rows.Columns() {
+		field, _ := item.Field(name)
+		dest[i] = driverValue(field)
+	}
+
+	return nil
+}
+
+/*
+Integral numbers are int64, so that they scan into integers.
+*/
+func driverValue(val value.Value) driver.Value {
+	switch val.Type() {
+	case value.MISSING, value.NULL:
+		return nil
+	case value.BOOLEAN, value.STRING:
+		return val.Actual()
+	case value.NUMBER:
+		f := val.Actual().(float64)
+		if f == math.Trunc(f) && math.Abs(f) < math.MaxInt64 {
+			return int64(f)
+		}
+		return f
+	default:
+		bytes, _ := val.MarshalJSON()
+		return bytes
+	}
+}
@@ -0,0 +1,332 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package engine embeds the query engine in a Go application, without
+the HTTP service. An engine runs statements directly against a
+datastore, e.g. a directory of JSON files:
+
+	eng, err := engine.New("dir:./data")
+	rows, err := eng.Query(ctx, "SELECT name FROM contacts WHERE age > $1", 30)
+	defer rows.Close()
+	for rows.Next() {
+		fmt.Println(rows.Value())
+	}
+
+Package engine/scan stores the results in Go structs. The package
+also registers a database/sql driver; see driver.go.
+*/
+package engine
+
+import (
+	"context"
+	"database/sql"
+	"fmt"
+	"os"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/datastore/temp"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/guard"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/logging/logger_golog"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/value"
+)
+
+const DEFAULT_NAMESPACE = "default"
+
+/*
+The engine logs warnings to stderr, unless the application sets a
+logger of its own; see package logging.
+*/
+func init() {
+	logging.SetLogger(logger_golog.NewLogger(os.Stderr, logging.WARN, false))
+}
+
+type Engine struct {
+	datastore      datastore.Datastore
+	systemstore    datastore.Datastore
+	namespace      string
+	readonly       bool
+	maxParallelism int
+	outfileDir     string
+	importDir      string
+	durability     datastore.Durability
+	casRetries     int
+	allowFullScan  bool
+	requests       uint64
+}
+
+/*
+Create an engine over the datastore at the URL: a directory of JSON
+files, as a path or dir:path, or a mock datastore, as mock:options.
+Unlike cbq-engine, the engine does not connect to Couchbase servers,
+so that embedding it does not require their client libraries.
+*/
+func New(datastoreURL string) (*Engine, error) {
+	store, err := newDatastore(datastoreURL)
+	if err != nil {
+		return nil, err
+	}
+
+	sys, err := system.NewDatastore(store, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Temporary keyspaces last as long as the engine
+	return &Engine{
+		datastore:   temp.NewDatastore(store, temp.NewKeyspaces()),
+		systemstore: sys,
+		namespace:   DEFAULT_NAMESPACE,
+	}, nil
+}
+
+func newDatastore(uri string) (datastore.Datastore, errors.Error) {
+	switch {
+	case strings.HasPrefix(uri, "dir:"):
+		return file.NewDatastore(uri[4:])
+	case strings.HasPrefix(uri, "file:"):
+		return file.NewDatastore(uri[5:])
+	case strings.HasPrefix(uri, "mock:"):
+		return mock.NewDatastore(uri)
+	case strings.HasPrefix(uri, ".") || strings.HasPrefix(uri, "/"):
+		return file.NewDatastore(uri)
+	}
+
+	return nil, errors.NewError(nil, fmt.Sprintf("Invalid datastore uri: %s", uri))
+}
+
+func (this *Engine) Datastore() datastore.Datastore {
+	return this.datastore
+}
+
+func (this *Engine) Namespace() string {
+	return this.namespace
+}
+
+// The namespace of keyspaces that are not qualified by one.
+func (this *Engine) SetNamespace(namespace string) {
+	this.namespace = namespace
+}
+
+func (this *Engine) Readonly() bool {
+	return this.readonly
+}
+
+func (this *Engine) SetReadonly(readonly bool) {
+	this.readonly = readonly
+}
+
+func (this *Engine) MaxParallelism() int {
+	return this.maxParallelism
+}
+
+// The directory of INTO OUTFILE files; if empty, OUTFILE is disabled.
+func (this *Engine) OutfileDir() string {
+	return this.outfileDir
+}
+
+func (this *Engine) SetOutfileDir(dir string) {
+	this.outfileDir = dir
+}
+
+// The directory of IMPORT files; if empty, IMPORT is disabled.
+func (this *Engine) ImportDir() string {
+	return this.importDir
+}
+
+func (this *Engine) SetImportDir(dir string) {
+	this.importDir = dir
+}
+
+// How durable DML mutations are made; empty leaves it to the datastore.
+func (this *Engine) Durability() datastore.Durability {
+	return this.durability
+}
+
+func (this *Engine) SetDurability(durability datastore.Durability) {
+	this.durability = durability
+}
+
+// How many times UPDATE retries documents modified concurrently.
+func (this *Engine) CasRetries() int {
+	return this.casRetries
+}
+
+func (this *Engine) SetCasRetries(retries int) {
+	this.casRetries = retries
+}
+
+// Statements that scan large keyspaces in full fail unless allowed;
+// see guard.CheckFullScans.
+func (this *Engine) AllowFullScan() bool {
+	return this.allowFullScan
+}
+
+func (this *Engine) SetAllowFullScan(allow bool) {
+	this.allowFullScan = allow
+}
+
+// Zero or less means the number of CPUs.
+func (this *Engine) SetMaxParallelism(maxParallelism int) {
+	this.maxParallelism = maxParallelism
+}
+
+/*
+Subscribe to the mutations of a keyspace, e.g. by the DML that the
+engine runs, if the datastore has mutation feeds, as a directory
+opened with the journal option does: "dir:./data?journal=true".
+*/
+func (this *Engine) Subscribe(keyspace string) (datastore.MutationFeed, error) {
+	ns, err := this.datastore.NamespaceByName(this.namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, err := ns.KeyspaceByName(keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, ok := ks.(datastore.FeedKeyspace)
+	if !ok {
+		return nil, fmt.Errorf("Keyspace %s has no mutation feed.", keyspace)
+	}
+
+	feed, err := feeds.Subscribe()
+	if err != nil {
+		return nil, err
+	}
+
+	return feed, nil
+}
+
+/*
+Run a statement, and return its results as they are produced. Args
+of type sql.NamedArg bind named parameters, e.g. $name; the others
+bind positional parameters $1, $2, ... in order. The statement is
+stopped when ctx is done or the rows are closed.
+*/
+func (this *Engine) Query(ctx context.Context, statement string, args ...interface{}) (*Rows, error) {
+	stmt, err := n1ql.ParseStatement(statement)
+	if err != nil {
+		return nil, err
+	}
+
+	op, err := planner.BuildPrepared(stmt, this.datastore, this.systemstore, this.namespace,
+		false, false, this.readonly, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	// Engines have no users; only rules for all users apply
+	if err := guard.Check(nil, op.Operator); err != nil {
+		return nil, err
+	}
+
+	if !this.allowFullScan {
+		if err := guard.CheckFullScans(op.Operator); err != nil {
+			return nil, err
+		}
+	}
+
+	namedArgs, positionalArgs := arguments(args)
+	rows := newRows(columns(stmt))
+	id := fmt.Sprintf("engine-%d", atomic.AddUint64(&this.requests, 1))
+	execContext := execution.NewContext(id, this.datastore, this.systemstore, this.namespace,
+		this.readonly, this.maxParallelism, namedArgs, positionalArgs, nil,
+		datastore.UNBOUNDED, nil, rows)
+	execContext.SetOutfileDir(this.outfileDir)
+	execContext.SetImportDir(this.importDir)
+	execContext.SetDurability(this.durability)
+	execContext.SetCasRetries(this.casRetries)
+	execContext.SetAllowFullScan(this.allowFullScan)
+
+	exec, err := execution.Build(op, execContext)
+	if err != nil {
+		return nil, err
+	}
+
+	go rows.run(ctx, exec, execContext)
+	return rows, nil
+}
+
+func arguments(args []interface{}) (map[string]value.Value, value.Values) {
+	var named map[string]value.Value
+	var positional value.Values
+	for _, arg := range args {
+		switch arg := arg.(type) {
+		case sql.NamedArg:
+			if named == nil {
+				named = make(map[string]value.Value, len(args))
+			}
+			named[arg.Name] = argument(arg.Value)
+		default:
+			positional = append(positional, argument(arg))
+		}
+	}
+
+	return named, positional
+}
+
+/*
+Args are JSON values of Go types, as accepted by value.NewValue, or
+times, which are bound as strings in the format of the date
+functions. Byte slices are parsed as JSON.
+*/
+func argument(arg interface{}) value.Value {
+	if t, ok := arg.(time.Time); ok {
+		return value.NewValue(t.Format(time.RFC3339Nano))
+	}
+
+	return value.NewValue(arg)
+}
+
+/*
+The names of the columns of the results of a SELECT, in the order of
+its projection, or nil if the results are not objects of known
+fields, e.g. for SELECT RAW, SELECT * and INTO OUTFILE.
+*/
+func columns(stmt algebra.Statement) []string {
+	sel, ok := stmt.(*algebra.Select)
+	if !ok || sel.Outfile() != nil {
+		return nil
+	}
+
+	sub, ok := sel.Subresult().(*algebra.Subselect)
+	if !ok {
+		return nil
+	}
+
+	proj := sub.Projection()
+	if proj.Raw() {
+		return nil
+	}
+
+	names := make([]string, 0, len(proj.Terms()))
+	for _, term := range proj.Terms() {
+		if term.Star() {
+			return nil
+		}
+		names = append(names, term.Alias())
+	}
+
+	return names
+}
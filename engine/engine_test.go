@@ -0,0 +1,936 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package engine
+
+import (
+	"archive/zip"
+	"context"
+	"database/sql"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/guard"
+)
+
+func testDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "engine")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	contacts := filepath.Join(dir, "default", "contacts")
+	err = os.MkdirAll(contacts, 0755)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	docs := map[string]string{
+		"c1": `{"name": "dave", "age": 46, "children": ["aiden", "bill"]}`,
+		"c2": `{"name": "ian", "age": 56}`,
+	}
+
+	for key, doc := range docs {
+		err = ioutil.WriteFile(filepath.Join(contacts, key+".json"), []byte(doc), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	return dir
+}
+
+func TestEngine(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err := eng.Query(context.Background(),
+		"SELECT RAW name FROM contacts WHERE age > $1 ORDER BY name", 50)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for rows.Next() {
+		names = append(names, rows.Value().Actual().(string))
+	}
+
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+
+	if len(names) != 1 || names[0] != "ian" {
+		t.Errorf("Expected [ian], got %v", names)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	rows, err = eng.Query(ctx, "SELECT * FROM contacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cancel()
+	for rows.Next() {
+	}
+
+	if rows.Err() == nil {
+		t.Errorf("Expected an error for a cancelled statement")
+	}
+}
+
+func TestOutfile(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eng.SetOutfileDir(dir)
+	cases := []struct {
+		format   string
+		expected string
+	}{
+		{"csv", "name,age\ndave,46\nian,56\n"},
+		{"jsonl", `{"age":46,"name":"dave"}` + "\n" + `{"age":56,"name":"ian"}` + "\n"},
+		{"json", "[\n" + `{"age":46,"name":"dave"}` + ",\n" + `{"age":56,"name":"ian"}` + "\n]\n"},
+	}
+
+	for _, c := range cases {
+		rows, err := eng.Query(context.Background(), "SELECT name, age FROM contacts ORDER BY name "+
+			"INTO OUTFILE \"out."+c.format+"\" FORMAT "+c.format)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for rows.Next() {
+		}
+
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+
+		bytes, err := ioutil.ReadFile(filepath.Join(dir, "out."+c.format))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if string(bytes) != c.expected {
+			t.Errorf("Expected %s, got %s", c.expected, bytes)
+		}
+	}
+
+	rows, err := eng.Query(context.Background(), "SELECT name FROM contacts INTO OUTFILE \"../out.json\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+	}
+
+	if rows.Err() == nil {
+		t.Errorf("Expected an error for an OUTFILE outside the outfile directory")
+	}
+
+	outside, err := ioutil.TempDir("", "outside")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(outside)
+
+	err = os.Symlink(outside, filepath.Join(dir, "link"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	rows, err = eng.Query(context.Background(), "SELECT name FROM contacts INTO OUTFILE \"link/out.json\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+	}
+
+	if rows.Err() == nil {
+		t.Errorf("Expected an error for an OUTFILE through a link outside the outfile directory")
+	}
+
+	if _, err = os.Stat(filepath.Join(outside, "out.json")); err == nil {
+		t.Errorf("Expected no OUTFILE outside the outfile directory")
+	}
+}
+
+func TestImport(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	eng.SetImportDir(dir)
+	files := map[string]string{
+		"people.jsonl": `{"id": 3, "name": "al"}` + "\n\n" + `{"id": 4, "name": "bo"` + "\n" + `{"id": 5, "name": "cy"}` + "\n",
+		"people.csv":   "id,name,age\n6,di,30\n7,ed\n",
+	}
+
+	for name, content := range files {
+		err = ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	file, err := os.Create(filepath.Join(dir, "people.zip"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	archive := zip.NewWriter(file)
+	w, _ := archive.Create("docs/p8.json")
+	w.Write([]byte(`{"id": 8, "name": "fi"}`))
+	w, _ = archive.Create("README")
+	w.Write([]byte("not a document"))
+	archive.Close()
+	file.Close()
+
+	statements := []string{
+		`IMPORT INTO contacts (KEY "p" || TO_STRING(id)) FROM "people.jsonl" ERRORS "rejected.jsonl"`,
+		`IMPORT INTO contacts (KEY "p" || TO_STRING(id), VALUE {"name": name, "age": age}) FROM "people.csv" BATCH 1 ERRORS "rejected.csv.jsonl"`,
+		`IMPORT INTO contacts FROM "people.zip"`,
+	}
+
+	for _, statement := range statements {
+		rows, err := eng.Query(context.Background(), statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for rows.Next() {
+		}
+
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+	}
+
+	rows, err := eng.Query(context.Background(),
+		"SELECT RAW META(c).id || \":\" || name || \":\" || IFMISSING(TO_STRING(age), \"\") FROM contacts c WHERE META(c).id LIKE \"p%\" ORDER BY META(c).id")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var docs []string
+	for rows.Next() {
+		docs = append(docs, rows.Value().Actual().(string))
+	}
+
+	if rows.Err() != nil {
+		t.Fatal(rows.Err())
+	}
+
+	expected := `["p3:al:" "p5:cy:" "p6:di:30" "p8:fi:"]`
+	if fmt.Sprintf("%q", docs) != expected {
+		t.Errorf("Expected %s, got %q", expected, docs)
+	}
+
+	for _, name := range []string{"rejected.jsonl", "rejected.csv.jsonl"} {
+		bytes, err := ioutil.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if strings.Count(string(bytes), "\n") != 1 {
+			t.Errorf("Expected one rejected row in %s, got %s", name, bytes)
+		}
+	}
+
+	rows, err = eng.Query(context.Background(), `IMPORT INTO contacts FROM "people.jsonl"`)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for rows.Next() {
+	}
+
+	if rows.Err() == nil {
+		t.Errorf("Expected an error for a rejected row without an errors file")
+	}
+
+	err = os.Symlink(filepath.Join(dir, "people.jsonl"), filepath.Join(dir, "same.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, name := range []string{"people.jsonl", "./people.jsonl", "same.jsonl"} {
+		rows, err = eng.Query(context.Background(), `IMPORT INTO contacts FROM "people.jsonl" ERRORS "`+name+`"`)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for rows.Next() {
+		}
+
+		if rows.Err() == nil {
+			t.Errorf("Expected an error for the errors file %s of people.jsonl", name)
+		}
+	}
+
+	bytes, err := ioutil.ReadFile(filepath.Join(dir, "people.jsonl"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(bytes) != files["people.jsonl"] {
+		t.Errorf("Expected people.jsonl to be unchanged, got %s", bytes)
+	}
+}
+
+func TestSubscribe(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir + "?journal=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	feed, err := eng.Subscribe("contacts")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer feed.Close()
+
+	for _, statement := range []string{
+		`UPDATE contacts USE KEYS "c1" SET age = 47`,
+		`DELETE FROM contacts USE KEYS "c2"`,
+	} {
+		rows, err := eng.Query(context.Background(), statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		for rows.Next() {
+		}
+
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+	}
+
+	for _, expected := range []string{"update c1 1", "delete c2 2"} {
+		select {
+		case m := <-feed.Mutations():
+			actual := fmt.Sprintf("%s %s %d", m.Op, m.Key, m.Vector.Entries()[0].Value())
+			if actual != expected {
+				t.Errorf("Expected mutation %s, got %s", expected, actual)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatalf("Expected mutation %s", expected)
+		}
+	}
+}
+
+func TestDriver(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	db, err := sql.Open(DRIVER_NAME, "dir:"+dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer db.Close()
+
+	var name string
+	var age int
+	var children []byte
+	err = db.QueryRow("SELECT name, age, children FROM contacts USE KEYS $key",
+		sql.Named("key", "c1")).Scan(&name, &age, &children)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if name != "dave" || age != 46 || string(children) != `["aiden","bill"]` {
+		t.Errorf("Unexpected row %s %d %s", name, age, children)
+	}
+
+	result, err := db.Exec("UPDATE contacts SET age = age + 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	n, err := result.RowsAffected()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if n != 2 {
+		t.Errorf("Expected 2 rows affected, got %d", n)
+	}
+}
+
+func TestMaterializedView(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir + "?journal=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(statement string) []string {
+		rows, err := eng.Query(context.Background(), statement)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		var results []string
+		for rows.Next() {
+			bytes, _ := rows.Value().MarshalJSON()
+			results = append(results, string(bytes))
+		}
+
+		if rows.Err() != nil {
+			t.Fatal(rows.Err())
+		}
+
+		return results
+	}
+
+	run("CREATE MATERIALIZED VIEW elders AS SELECT name FROM contacts WHERE age > 50")
+	run("CREATE MATERIALIZED VIEW oldest AS SELECT MAX(age) AS age FROM contacts")
+
+	actual := fmt.Sprint(run("SELECT RAW META(e).id || \":\" || e.name FROM elders e"))
+	if actual != `["c2:ian"]` {
+		t.Errorf("Expected [\"c2:ian\"], got %s", actual)
+	}
+
+	// Key-preserving views are refreshed from the mutation feed
+	run(`UPDATE contacts USE KEYS "c1" SET age = 60`)
+	run(`UPDATE contacts USE KEYS "c2" SET age = 40`)
+
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		actual = fmt.Sprint(run("SELECT RAW name FROM elders"))
+		if actual == `["dave"]` || time.Now().After(deadline) {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	if actual != `["dave"]` {
+		t.Errorf("Expected [\"dave\"], got %s", actual)
+	}
+
+	// Other views are refreshed on demand
+	actual = fmt.Sprint(run("SELECT RAW age FROM oldest"))
+	if actual != "[56]" {
+		t.Errorf("Expected [56], got %s", actual)
+	}
+
+	run("REFRESH MATERIALIZED VIEW oldest")
+	actual = fmt.Sprint(run("SELECT RAW age FROM oldest"))
+	if actual != "[60]" {
+		t.Errorf("Expected [60], got %s", actual)
+	}
+
+	run("DROP MATERIALIZED VIEW elders")
+	run("DROP MATERIALIZED VIEW oldest")
+
+	_, err = eng.Query(context.Background(), "SELECT * FROM elders")
+	if err == nil {
+		t.Errorf("Expected an error for a dropped view")
+	}
+}
+
+func TestView(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(statement string) (string, error) {
+		rows, err := eng.Query(context.Background(), statement)
+		if err != nil {
+			return "", err
+		}
+
+		var results []string
+		for rows.Next() {
+			bytes, _ := rows.Value().MarshalJSON()
+			results = append(results, string(bytes))
+		}
+
+		return fmt.Sprint(results), rows.Err()
+	}
+
+	tests := []struct {
+		stmt     string
+		expected string
+	}{
+		{"CREATE VIEW elders AS SELECT name, age FROM contacts WHERE age > 50", "[]"},
+		{"CREATE VIEW names AS SELECT RAW e.name FROM elders e", "[]"},
+		{"SELECT RAW e.name FROM elders e WHERE e.age > 55", `["ian"]`},
+		{"SELECT COUNT(*) AS n FROM elders", `[{"n":1}]`},
+		{"SELECT * FROM names", `[{"names":"ian"}]`},
+		{"SELECT RAW id FROM system:views ORDER BY id", `["default:elders" "default:names"]`},
+		{`DELETE FROM system:views USE KEYS "default:names"`, "[]"},
+		{"DROP VIEW elders", "[]"},
+		{"SELECT RAW id FROM system:views", "[]"},
+	}
+
+	for _, test := range tests {
+		actual, err := run(test.stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.stmt, err)
+		}
+
+		if actual != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.stmt, actual)
+		}
+	}
+
+	for _, stmt := range []string{
+		"CREATE VIEW contacts AS SELECT * FROM contacts",
+		"SELECT * FROM elders",
+		"DROP MATERIALIZED VIEW elders",
+	} {
+		_, err := run(stmt)
+		if err == nil {
+			t.Errorf("Expected an error for %s", stmt)
+		}
+	}
+}
+
+func TestTempKeyspace(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(statement string) (string, error) {
+		rows, err := eng.Query(context.Background(), statement)
+		if err != nil {
+			return "", err
+		}
+
+		var results []string
+		for rows.Next() {
+			bytes, _ := rows.Value().MarshalJSON()
+			results = append(results, string(bytes))
+		}
+
+		return fmt.Sprint(results), rows.Err()
+	}
+
+	tests := []struct {
+		stmt     string
+		expected string
+	}{
+		{"CREATE TEMP KEYSPACE `#elders`", "[]"},
+		{"INSERT INTO `#elders` (KEY k, VALUE v) SELECT META(c).id AS k, c AS v FROM contacts c WHERE c.age > 50", "[]"},
+		{"SELECT RAW e.name FROM `#elders` e", `["ian"]`},
+		{"UPSERT INTO `#names` (KEY k, VALUE v) SELECT META(c).id AS k, c.name AS v FROM contacts c", "[]"},
+		{"SELECT RAW META(n).id FROM `#names` n WHERE n > 'e'", `["c2"]`},
+		{"SELECT RAW n FROM `#names` n USE KEYS 'c1'", `["dave"]`},
+		{"DROP KEYSPACE `#elders`", "[]"},
+	}
+
+	for _, test := range tests {
+		actual, err := run(test.stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.stmt, err)
+		}
+
+		if actual != test.expected {
+			t.Errorf("Expected %s for %s, got %s", test.expected, test.stmt, actual)
+		}
+	}
+
+	for _, stmt := range []string{
+		"SELECT * FROM `#elders`",
+		"CREATE TEMP KEYSPACE `#names`",
+		"CREATE TEMP KEYSPACE scratch",
+		"DROP KEYSPACE contacts",
+	} {
+		_, err := run(stmt)
+		if err == nil {
+			t.Errorf("Expected an error for %s", stmt)
+		}
+	}
+
+	// Temporary keyspaces are not written to the directory
+	if _, err := os.Stat(filepath.Join(dir, "default", "#names")); !os.IsNotExist(err) {
+		t.Errorf("Expected no directory for #names, got %v", err)
+	}
+}
+
+func TestCapabilities(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	explain := func(statement string) map[string]interface{} {
+		rows, err := eng.Query(context.Background(), "EXPLAIN "+statement)
+		if err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+
+		var plan map[string]interface{}
+		for rows.Next() {
+			plan, _ = rows.Value().Actual().(map[string]interface{})
+		}
+
+		if err = rows.Err(); err != nil {
+			t.Fatalf("%s: %v", statement, err)
+		}
+
+		return plan
+	}
+
+	rows, err := eng.Query(context.Background(), "UPSERT INTO `#scratch` (KEY, VALUE) VALUES ('s1', {'v': 1})")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for rows.Next() {
+	}
+
+	// Temporary keyspaces have no secondary indexes
+	if plan := explain("SELECT * FROM `#scratch` s WHERE s.v = 1"); plan["~notes"] == nil {
+		t.Errorf("Expected a note on the primary scan, got %v", plan)
+	}
+
+	if plan := explain("SELECT * FROM contacts c WHERE c.name = 'ian'"); plan["~notes"] != nil {
+		t.Errorf("Expected no notes, got %v", plan["~notes"])
+	}
+
+	rows, err = eng.Query(context.Background(), "SELECT RAW k.capabilities FROM system:keyspaces k WHERE k.name = 'contacts'")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var capabilities string
+	for rows.Next() {
+		bytes, _ := rows.Value().MarshalJSON()
+		capabilities = string(bytes)
+	}
+
+	if !strings.Contains(capabilities, `"cas"`) || !strings.Contains(capabilities, `"secondary_index"`) {
+		t.Errorf("Expected the capabilities of contacts, got %s", capabilities)
+	}
+
+	_, err = eng.Query(context.Background(), "CREATE INDEX iv ON `#scratch`(v)")
+	if err, ok := err.(errors.Error); !ok || err.Code() != errors.KEYSPACE_CAPABILITY {
+		t.Errorf("Expected a capability error, got %v", err)
+	}
+}
+
+func TestDurability(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		durability datastore.Durability
+		stmt       string
+		expected   datastore.Durability
+	}{
+		{"", "UPSERT INTO contacts (KEY, VALUE) VALUES ('c3', {'name': 'fred'})", ""},
+		{datastore.DURABILITY_MUTATION, "UPSERT INTO contacts (KEY, VALUE) VALUES ('c4', {'name': 'gina'})",
+			datastore.DURABILITY_MUTATION},
+		{datastore.DURABILITY_BATCH, "DELETE FROM contacts USE KEYS ['c3', 'c4']", datastore.DURABILITY_BATCH},
+		{datastore.DURABILITY_BATCH, "UPSERT INTO `#scratch` (KEY, VALUE) VALUES ('s1', 1)", datastore.DURABILITY_NONE},
+	}
+
+	for _, test := range tests {
+		eng.SetDurability(test.durability)
+		rows, err := eng.Query(context.Background(), test.stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", test.stmt, err)
+		}
+
+		for rows.Next() {
+		}
+
+		if err = rows.Err(); err != nil {
+			t.Fatalf("%s: %v", test.stmt, err)
+		}
+
+		if rows.MutationCount() == 0 {
+			t.Errorf("Expected mutations for %s", test.stmt)
+		}
+
+		if durability := rows.AchievedDurability(); durability != test.expected {
+			t.Errorf("Expected durability %q for %s, got %q", test.expected, test.stmt, durability)
+		}
+	}
+}
+
+func TestMutationBatch(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Batches are sent while the next are built
+	execution.SetMutationBatch(3)
+	defer execution.SetMutationBatch(0)
+
+	values := make([]string, 10)
+	for i := range values {
+		values[i] = fmt.Sprintf("('m%d', {'n': %d})", i, i)
+	}
+
+	for _, op := range []string{"INSERT", "UPSERT"} {
+		stmt := op + " INTO contacts (KEY, VALUE) VALUES " + strings.Join(values, ", ") + " RETURNING contacts.n"
+		rows, err := eng.Query(context.Background(), stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+
+		returned := 0
+		for rows.Next() {
+			returned++
+		}
+
+		if err = rows.Err(); err != nil {
+			t.Fatalf("%s: %v", op, err)
+		}
+
+		if returned != len(values) || rows.MutationCount() != uint64(len(values)) {
+			t.Errorf("Expected %d documents from %s, got %d returned and %d mutated",
+				len(values), op, returned, rows.MutationCount())
+		}
+	}
+}
+
+func TestReadonly(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	eng.SetReadonly(true)
+
+	mounted, err := New("dir:" + dir + "?readonly=true")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, e := range []*Engine{eng, mounted} {
+		for _, stmt := range []string{
+			"UPSERT INTO contacts (KEY, VALUE) VALUES ('c3', {'name': 'fred'})",
+			"CREATE INDEX iname ON contacts(name)",
+			"CREATE TEMP KEYSPACE `#scratch`",
+			"SELECT c.name FROM contacts c UNION ALL SELECT 1 FROM contacts INTO OUTFILE 'out.json'",
+		} {
+			_, err := e.Query(context.Background(), stmt)
+			if err, ok := err.(errors.Error); !ok || err.Code() != errors.READONLY_STATEMENT {
+				t.Errorf("Expected a read-only error for %s, got %v", stmt, err)
+			}
+		}
+
+		for _, stmt := range []string{
+			"SELECT RAW c.name FROM contacts c",
+			"EXPLAIN DELETE FROM contacts",
+		} {
+			rows, err := e.Query(context.Background(), stmt)
+			if err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+
+			for rows.Next() {
+			}
+
+			if err = rows.Err(); err != nil {
+				t.Errorf("%s: %v", stmt, err)
+			}
+		}
+	}
+}
+
+func TestStatementRules(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+	defer guard.Set(nil)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		rule    map[string]interface{}
+		denied  []string
+		allowed []string
+	}{
+		{
+			rule:    map[string]interface{}{"deny": "primary_scan", "keyspaces": []interface{}{"default:contacts"}},
+			denied:  []string{"SELECT name FROM contacts"},
+			allowed: []string{"SELECT name FROM contacts USE KEYS 'c1'"},
+		},
+		{
+			rule:    map[string]interface{}{"deny": "primary_scan", "users": []interface{}{"bob"}},
+			allowed: []string{"SELECT name FROM contacts"},
+		},
+		{
+			rule:    map[string]interface{}{"deny": "delete_without_where"},
+			denied:  []string{"DELETE FROM contacts"},
+			allowed: []string{"DELETE FROM contacts WHERE name = 'nobody'", "EXPLAIN DELETE FROM contacts"},
+		},
+		{
+			rule: map[string]interface{}{"deny": "select_star", "max_documents": 1.0},
+			denied: []string{
+				"SELECT * FROM contacts",
+				"SELECT (SELECT * FROM contacts AS c USE KEYS ['c1', 'c2']) AS x",
+				"SELECT name FROM contacts WHERE EXISTS (SELECT * FROM contacts AS c USE KEYS ['c1', 'c2'])",
+			},
+			allowed: []string{
+				"SELECT * FROM contacts LIMIT 1",
+				"SELECT name FROM contacts",
+				"SELECT (SELECT * FROM contacts AS c USE KEYS ['c1', 'c2'] LIMIT 1) AS x",
+			},
+		},
+	}
+
+	for _, test := range tests {
+		rules, err := guard.ParseRules([]interface{}{test.rule})
+		if err != nil {
+			t.Fatal(err)
+		}
+		guard.Set(rules)
+
+		for _, stmt := range test.denied {
+			// Subqueries are checked when they are evaluated
+			rows, err := eng.Query(context.Background(), stmt)
+			if err == nil {
+				for rows.Next() {
+				}
+				err = rows.Err()
+			}
+
+			// Denied subqueries are reported as the cause of the evaluation error
+			if e, ok := err.(errors.Error); ok && e.Code() != errors.STATEMENT_DENIED && e.Cause() != nil {
+				err = e.Cause()
+			}
+
+			if err, ok := err.(errors.Error); !ok || err.Code() != errors.STATEMENT_DENIED {
+				t.Errorf("Expected %s to be denied by %v, got %v", stmt, test.rule, err)
+			}
+		}
+
+		for _, stmt := range test.allowed {
+			rows, err := eng.Query(context.Background(), stmt)
+			if err != nil {
+				t.Fatalf("%s: %v", stmt, err)
+			}
+
+			for rows.Next() {
+			}
+
+			if err = rows.Err(); err != nil {
+				t.Errorf("%s: %v", stmt, err)
+			}
+		}
+	}
+
+	_, err = guard.ParseRules([]interface{}{map[string]interface{}{"deny": "full_scan"}})
+	if err == nil {
+		t.Errorf("Expected an error for an unknown statement class")
+	}
+}
+
+func TestFullScans(t *testing.T) {
+	dir := testDir(t)
+	defer os.RemoveAll(dir)
+	defer guard.SetFullScanThreshold(0)
+
+	eng, err := New("dir:" + dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	guard.SetFullScanThreshold(1)
+
+	for _, stmt := range []string{
+		"SELECT name FROM contacts",
+		"SELECT name FROM contacts ORDER BY name LIMIT 1",
+		"SELECT name FROM contacts WHERE age > 50",
+	} {
+		_, err := eng.Query(context.Background(), stmt)
+		if err, ok := err.(errors.Error); !ok || err.Code() != errors.FULL_SCAN {
+			t.Errorf("Expected a full scan error for %s, got %v", stmt, err)
+		}
+	}
+
+	_, err = eng.Query(context.Background(), "SELECT name FROM contacts WHERE age > 50")
+	if err == nil || !strings.Contains(err.Error(), "CREATE INDEX") {
+		t.Errorf("Expected an index suggestion, got %v", err)
+	}
+
+	run := func(stmt string) {
+		rows, err := eng.Query(context.Background(), stmt)
+		if err != nil {
+			t.Fatalf("%s: %v", stmt, err)
+		}
+
+		for rows.Next() {
+		}
+
+		if err = rows.Err(); err != nil {
+			t.Errorf("%s: %v", stmt, err)
+		}
+	}
+
+	run("SELECT name FROM contacts LIMIT 1")
+	run("SELECT name FROM contacts USE KEYS 'c1'")
+	run("CREATE INDEX iage ON contacts(age)")
+	run("SELECT name FROM contacts WHERE age > 50")
+
+	eng.SetAllowFullScan(true)
+	run("SELECT name FROM contacts")
+	eng.SetAllowFullScan(false)
+
+	guard.SetFullScanThreshold(2)
+	run("SELECT name FROM contacts")
+}
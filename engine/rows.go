@@ -0,0 +1,214 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package engine
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Rows are the results of a statement, read with Next and Value as the
+statement runs. Rows are the execution.Output of the statement.
+*/
+type Rows struct {
+	sync.Mutex
+	columns    []string
+	results    chan value.Value
+	stop       chan bool
+	stopOnce   sync.Once
+	current    value.Value
+	err        errors.Error
+	warnings   []errors.Error
+	mutations  uint64
+	durability datastore.Durability
+	sortCount  uint64
+}
+
+func newRows(columns []string) *Rows {
+	return &Rows{
+		columns: columns,
+		results: make(chan value.Value),
+		stop:    make(chan bool),
+	}
+}
+
+func (this *Rows) run(ctx context.Context, exec execution.Operator, execContext *execution.Context) {
+	defer close(this.results)
+	defer execContext.ReleaseSnapshots()
+
+	done := make(chan bool)
+	defer close(done)
+	go func() {
+		select {
+		case <-ctx.Done():
+			this.Error(errors.NewError(ctx.Err(), "Statement stopped."))
+			this.Close()
+		case <-this.stop:
+		case <-done:
+			return
+		}
+
+		select {
+		case exec.StopChannel() <- false:
+		default:
+		}
+	}()
+
+	exec.RunOnce(execContext, nil)
+	for _ = range exec.ItemChannel() {
+	}
+}
+
+/*
+The names of the columns of the results, if they are known; see
+columns().
+*/
+func (this *Rows) Columns() []string {
+	return this.columns
+}
+
+// Advance to the next result, and return false if there is none.
+func (this *Rows) Next() bool {
+	this.current = nil
+	select {
+	case <-this.stop:
+		return false
+	default:
+	}
+
+	select {
+	case item, ok := <-this.results:
+		if ok {
+			this.current = item
+		}
+	case <-this.stop:
+	}
+
+	return this.current != nil
+}
+
+// The current result.
+func (this *Rows) Value() value.Value {
+	return this.current
+}
+
+/*
+The first error of the statement, if any. It is final once Next has
+returned false.
+*/
+func (this *Rows) Err() error {
+	this.Lock()
+	defer this.Unlock()
+	if this.err == nil {
+		return nil
+	}
+
+	return this.err
+}
+
+func (this *Rows) Warnings() []errors.Error {
+	this.Lock()
+	defer this.Unlock()
+	return this.warnings
+}
+
+// Stop the statement, and discard its remaining results.
+func (this *Rows) Close() error {
+	this.stopOnce.Do(func() { close(this.stop) })
+	return nil
+}
+
+func (this *Rows) Result(item value.Value) bool {
+	select {
+	case this.results <- item:
+		return true
+	case <-this.stop:
+		return false
+	}
+}
+
+func (this *Rows) CloseResults() {
+}
+
+func (this *Rows) Fatal(err errors.Error) {
+	this.Error(err)
+}
+
+func (this *Rows) Error(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *Rows) Warning(wrn errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	this.warnings = append(this.warnings, wrn)
+}
+
+func (this *Rows) AddMutationCount(i uint64) {
+	this.Lock()
+	defer this.Unlock()
+	this.mutations += i
+}
+
+/*
+The number of documents changed by a DML statement. It is final once
+Next has returned false.
+*/
+func (this *Rows) MutationCount() uint64 {
+	this.Lock()
+	defer this.Unlock()
+	return this.mutations
+}
+
+func (this *Rows) AchieveDurability(durability datastore.Durability) {
+	this.Lock()
+	defer this.Unlock()
+	this.durability = datastore.WeakerDurability(this.durability, durability)
+}
+
+/*
+The weakest durability achieved by the mutations of a DML statement,
+if the connection asked for one.
+*/
+func (this *Rows) AchievedDurability() datastore.Durability {
+	this.Lock()
+	defer this.Unlock()
+	return this.durability
+}
+
+func (this *Rows) SetSortCount(i uint64) {
+	this.Lock()
+	defer this.Unlock()
+	this.sortCount = i
+}
+
+func (this *Rows) SortCount() uint64 {
+	this.Lock()
+	defer this.Unlock()
+	return this.sortCount
+}
+
+func (this *Rows) AddPhaseTime(phase string, duration time.Duration) {
+}
+
+func (this *Rows) PhaseTimes() map[string]time.Duration {
+	return nil
+}
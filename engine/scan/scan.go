@@ -0,0 +1,471 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package scan stores query results in Go values, e.g. structs:
+
+	type Contact struct {
+		Name     string   `n1ql:"name"`
+		Age      int      `n1ql:"age"`
+		Children []string `n1ql:"children"`
+	}
+
+	scanner := scan.NewScanner(rows)
+	defer scanner.Close()
+	for scanner.Next() {
+		var c Contact
+		err := scanner.Scan(&c)
+		...
+	}
+
+Struct fields are matched to object fields by their n1ql tag, or else
+their json tag, or else their name; names without a tag also match
+case-insensitively, as in encoding/json. Fields tagged "-" are
+skipped, and the fields of untagged embedded structs are promoted.
+
+MISSING and NULL store the zero value. Numbers are stored in integer
+fields only if they are integral and in range. Strings are stored in
+time.Time fields if they are in the format of the date functions.
+Byte slices receive the JSON encoding of a value, and value.Value
+fields receive the value itself.
+*/
+package scan
+
+import (
+	"encoding/json"
+	"fmt"
+	"math"
+	"reflect"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+/*
+The results of a statement, e.g. *engine.Rows.
+*/
+type Rows interface {
+	Next() bool
+	Value() value.Value
+	Err() error
+	Close() error
+}
+
+/*
+Scanner stores results one at a time, as they are read from the
+rows.
+*/
+type Scanner struct {
+	rows Rows
+}
+
+func NewScanner(rows Rows) *Scanner {
+	return &Scanner{rows: rows}
+}
+
+// Advance to the next result, and return false if there is none.
+func (this *Scanner) Next() bool {
+	return this.rows.Next()
+}
+
+// Store the current result in the value that dest points to.
+func (this *Scanner) Scan(dest interface{}) error {
+	return Unmarshal(this.rows.Value(), dest)
+}
+
+func (this *Scanner) Err() error {
+	return this.rows.Err()
+}
+
+func (this *Scanner) Close() error {
+	return this.rows.Close()
+}
+
+/*
+Append all the results to the slice that dest points to, and close
+the rows.
+*/
+func All(rows Rows, dest interface{}) error {
+	defer rows.Close()
+
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.Elem().Kind() != reflect.Slice {
+		return fmt.Errorf("Cannot scan results into %T: not a pointer to a slice.", dest)
+	}
+
+	slice := rv.Elem()
+	for rows.Next() {
+		elem := reflect.New(slice.Type().Elem()).Elem()
+		err := unmarshal(rows.Value(), elem, "")
+		if err != nil {
+			return err
+		}
+
+		slice.Set(reflect.Append(slice, elem))
+	}
+
+	return rows.Err()
+}
+
+/*
+Store the value in the Go value that dest points to.
+*/
+func Unmarshal(val value.Value, dest interface{}) error {
+	rv := reflect.ValueOf(dest)
+	if rv.Kind() != reflect.Ptr || rv.IsNil() {
+		return fmt.Errorf("Cannot scan result into %T: not a pointer.", dest)
+	}
+
+	return unmarshal(val, rv.Elem(), "")
+}
+
+var _VALUE_TYPE = reflect.TypeOf((*value.Value)(nil)).Elem()
+var _TIME_TYPE = reflect.TypeOf(time.Time{})
+var _BYTES_TYPE = reflect.TypeOf([]byte(nil))
+
+func unmarshal(val value.Value, rv reflect.Value, path string) error {
+	if val == nil {
+		val = value.MISSING_VALUE
+	}
+
+	if rv.Type() == _VALUE_TYPE {
+		rv.Set(reflect.ValueOf(val))
+		return nil
+	}
+
+	if val.Type() == value.MISSING || val.Type() == value.NULL {
+		rv.Set(reflect.Zero(rv.Type()))
+		return nil
+	}
+
+	switch rv.Type() {
+	case _TIME_TYPE:
+		if val.Type() != value.STRING {
+			return mismatch(val, rv, path)
+		}
+
+		t, err := time.Parse(time.RFC3339Nano, val.Actual().(string))
+		if err != nil {
+			return fmt.Errorf("Cannot scan %s into time.Time%s: %v", val.Type(), at(path), err)
+		}
+
+		rv.Set(reflect.ValueOf(t))
+		return nil
+	case _BYTES_TYPE:
+		bytes, err := val.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		rv.SetBytes(bytes)
+		return nil
+	}
+
+	switch rv.Kind() {
+	case reflect.Ptr:
+		if rv.IsNil() {
+			rv.Set(reflect.New(rv.Type().Elem()))
+		}
+		return unmarshal(val, rv.Elem(), path)
+	case reflect.Interface:
+		if rv.Type().NumMethod() > 0 {
+			return mismatch(val, rv, path)
+		}
+
+		bytes, err := val.MarshalJSON()
+		if err != nil {
+			return err
+		}
+
+		var actual interface{}
+		err = json.Unmarshal(bytes, &actual)
+		if err != nil {
+			return err
+		}
+
+		rv.Set(reflect.ValueOf(actual))
+		return nil
+	case reflect.Bool:
+		if val.Type() != value.BOOLEAN {
+			return mismatch(val, rv, path)
+		}
+
+		rv.SetBool(val.Actual().(bool))
+		return nil
+	case reflect.String:
+		if val.Type() != value.STRING {
+			return mismatch(val, rv, path)
+		}
+
+		rv.SetString(val.Actual().(string))
+		return nil
+	case reflect.Int, reflect.Int8, reflect.Int16, reflect.Int32, reflect.Int64:
+		if val.Type() != value.NUMBER {
+			return mismatch(val, rv, path)
+		}
+
+		f := val.Actual().(float64)
+		if f != math.Trunc(f) || f < math.MinInt64 || f >= math.MaxInt64 || rv.OverflowInt(int64(f)) {
+			return outOfRange(f, rv, path)
+		}
+
+		rv.SetInt(int64(f))
+		return nil
+	case reflect.Uint, reflect.Uint8, reflect.Uint16, reflect.Uint32, reflect.Uint64, reflect.Uintptr:
+		if val.Type() != value.NUMBER {
+			return mismatch(val, rv, path)
+		}
+
+		f := val.Actual().(float64)
+		if f != math.Trunc(f) || f < 0 || f >= math.MaxUint64 || rv.OverflowUint(uint64(f)) {
+			return outOfRange(f, rv, path)
+		}
+
+		rv.SetUint(uint64(f))
+		return nil
+	case reflect.Float32, reflect.Float64:
+		if val.Type() != value.NUMBER {
+			return mismatch(val, rv, path)
+		}
+
+		f := val.Actual().(float64)
+		if rv.OverflowFloat(f) {
+			return outOfRange(f, rv, path)
+		}
+
+		rv.SetFloat(f)
+		return nil
+	case reflect.Slice:
+		if val.Type() != value.ARRAY {
+			return mismatch(val, rv, path)
+		}
+
+		n := len(val.Actual().([]interface{}))
+		slice := reflect.MakeSlice(rv.Type(), n, n)
+		for i := 0; i < n; i++ {
+			elem, _ := val.Index(i)
+			err := unmarshal(elem, slice.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+
+		rv.Set(slice)
+		return nil
+	case reflect.Array:
+		if val.Type() != value.ARRAY {
+			return mismatch(val, rv, path)
+		}
+
+		for i := 0; i < rv.Len(); i++ {
+			elem, _ := val.Index(i)
+			err := unmarshal(elem, rv.Index(i), fmt.Sprintf("%s[%d]", path, i))
+			if err != nil {
+				return err
+			}
+		}
+
+		return nil
+	case reflect.Map:
+		if val.Type() != value.OBJECT || rv.Type().Key().Kind() != reflect.String {
+			return mismatch(val, rv, path)
+		}
+
+		fields := val.Fields()
+		m := reflect.MakeMapWithSize(rv.Type(), len(fields))
+		for name, _ := range fields {
+			field, _ := val.Field(name)
+			elem := reflect.New(rv.Type().Elem()).Elem()
+			err := unmarshal(field, elem, join(path, name))
+			if err != nil {
+				return err
+			}
+
+			m.SetMapIndex(reflect.ValueOf(name).Convert(rv.Type().Key()), elem)
+		}
+
+		rv.Set(m)
+		return nil
+	case reflect.Struct:
+		if val.Type() != value.OBJECT {
+			return mismatch(val, rv, path)
+		}
+
+		return unmarshalStruct(val, rv, path)
+	}
+
+	return mismatch(val, rv, path)
+}
+
+func unmarshalStruct(val value.Value, rv reflect.Value, path string) error {
+	var fields map[string]interface{}
+	for _, f := range structFields(rv.Type()) {
+		field, ok := val.Field(f.name)
+		if !ok && !f.tagged {
+			if fields == nil {
+				fields = val.Fields()
+			}
+
+			for name, _ := range fields {
+				if strings.EqualFold(name, f.name) {
+					field, ok = val.Field(name)
+					break
+				}
+			}
+		}
+
+		if !ok {
+			continue
+		}
+
+		fv, err := fieldByIndex(rv, f.index)
+		if err != nil {
+			return err
+		}
+
+		err = unmarshal(field, fv, join(path, f.name))
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+/*
+The field of an embedded struct, allocating embedded pointers as
+needed.
+*/
+func fieldByIndex(rv reflect.Value, index []int) (reflect.Value, error) {
+	for i, x := range index {
+		if i > 0 && rv.Kind() == reflect.Ptr {
+			if rv.IsNil() {
+				if !rv.CanSet() {
+					return rv, fmt.Errorf("Cannot scan into unexported embedded %s.", rv.Type())
+				}
+				rv.Set(reflect.New(rv.Type().Elem()))
+			}
+			rv = rv.Elem()
+		}
+		rv = rv.Field(x)
+	}
+
+	return rv, nil
+}
+
+// A struct field, and the object field that it is stored from.
+type structField struct {
+	name   string
+	tagged bool
+	index  []int
+}
+
+var fieldCache = struct {
+	sync.RWMutex
+	fields map[reflect.Type][]*structField
+}{
+	fields: make(map[reflect.Type][]*structField),
+}
+
+func structFields(t reflect.Type) []*structField {
+	fieldCache.RLock()
+	fields, ok := fieldCache.fields[t]
+	fieldCache.RUnlock()
+	if ok {
+		return fields
+	}
+
+	fields = appendFields(nil, t, nil)
+	fieldCache.Lock()
+	fieldCache.fields[t] = fields
+	fieldCache.Unlock()
+	return fields
+}
+
+func appendFields(fields []*structField, t reflect.Type, index []int) []*structField {
+	for i := 0; i < t.NumField(); i++ {
+		sf := t.Field(i)
+		name, tagged := tagName(sf)
+		if name == "-" {
+			continue
+		}
+
+		fieldIndex := make([]int, len(index)+1)
+		copy(fieldIndex, index)
+		fieldIndex[len(index)] = i
+
+		ft := sf.Type
+		if ft.Kind() == reflect.Ptr {
+			ft = ft.Elem()
+		}
+
+		if sf.Anonymous && !tagged && ft.Kind() == reflect.Struct {
+			fields = appendFields(fields, ft, fieldIndex)
+			continue
+		}
+
+		if sf.PkgPath != "" {
+			continue
+		}
+
+		if name == "" {
+			name = sf.Name
+		}
+
+		fields = append(fields, &structField{
+			name:   name,
+			tagged: tagged,
+			index:  fieldIndex,
+		})
+	}
+
+	return fields
+}
+
+func tagName(sf reflect.StructField) (string, bool) {
+	for _, key := range []string{"n1ql", "json"} {
+		tag, ok := sf.Tag.Lookup(key)
+		if !ok {
+			continue
+		}
+
+		name := strings.Split(tag, ",")[0]
+		if name != "" {
+			return name, true
+		}
+	}
+
+	return "", false
+}
+
+func mismatch(val value.Value, rv reflect.Value, path string) error {
+	return fmt.Errorf("Cannot scan %s into %s%s.", val.Type(), rv.Type(), at(path))
+}
+
+func outOfRange(f float64, rv reflect.Value, path string) error {
+	return fmt.Errorf("Cannot scan number %v into %s%s.", f, rv.Type(), at(path))
+}
+
+func at(path string) string {
+	if path == "" {
+		return ""
+	}
+
+	return " at " + path
+}
+
+func join(path, name string) string {
+	if path == "" {
+		return name
+	}
+
+	return path + "." + name
+}
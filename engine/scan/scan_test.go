@@ -0,0 +1,115 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package scan
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+type address struct {
+	City string `json:"city"`
+}
+
+type contact struct {
+	address
+	Name     string            `n1ql:"name"`
+	Age      uint8             `n1ql:"age"`
+	Children []string          `n1ql:"children"`
+	Born     time.Time         `n1ql:"born"`
+	Spouse   *contact          `n1ql:"spouse"`
+	Tags     map[string]bool   `n1ql:"tags"`
+	Extra    interface{}       `n1ql:"extra"`
+	Raw      []byte            `n1ql:"raw"`
+	Val      value.Value       `n1ql:"val"`
+	Nick     string            // Matched by name, case-insensitively
+	Skip     string            `n1ql:"-"`
+	Unset    map[string]string `n1ql:"unset"`
+}
+
+type rows struct {
+	values []value.Value
+	next   int
+}
+
+func (this *rows) Next() bool {
+	this.next++
+	return this.next <= len(this.values)
+}
+
+func (this *rows) Value() value.Value { return this.values[this.next-1] }
+func (this *rows) Err() error         { return nil }
+func (this *rows) Close() error       { return nil }
+
+func TestUnmarshal(t *testing.T) {
+	val := value.NewValue([]byte(`{"name": "dave", "age": 46, "city": "sf",
+		"children": ["aiden", "bill"], "born": "1970-01-02T03:04:05Z",
+		"spouse": {"name": "jane"}, "tags": {"a": true}, "extra": {"x": [1]},
+		"raw": {"y": 2}, "val": "v", "NICK": "d", "Skip": "s", "unset": null}`))
+
+	var c contact
+	err := Unmarshal(val, &c)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	expected := contact{
+		address:  address{City: "sf"},
+		Name:     "dave",
+		Age:      46,
+		Children: []string{"aiden", "bill"},
+		Born:     time.Date(1970, 1, 2, 3, 4, 5, 0, time.UTC),
+		Spouse:   &contact{Name: "jane"},
+		Tags:     map[string]bool{"a": true},
+		Extra:    map[string]interface{}{"x": []interface{}{1.0}},
+		Raw:      []byte(`{"y":2}`),
+		Val:      value.NewValue("v"),
+		Nick:     "d",
+	}
+
+	if !reflect.DeepEqual(c, expected) {
+		t.Errorf("Expected %#v, got %#v", expected, c)
+	}
+
+	errs := []string{
+		`{"age": 300}`,
+		`{"age": 1.5}`,
+		`{"name": 1}`,
+		`{"children": ["a", 1]}`,
+	}
+
+	for _, e := range errs {
+		var c contact
+		err := Unmarshal(value.NewValue([]byte(e)), &c)
+		if err == nil {
+			t.Errorf("Expected an error for %s", e)
+		}
+	}
+}
+
+func TestAll(t *testing.T) {
+	r := &rows{values: []value.Value{
+		value.NewValue([]byte(`{"name": "dave"}`)),
+		value.NewValue([]byte(`{"name": "ian"}`)),
+	}}
+
+	var contacts []contact
+	err := All(r, &contacts)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(contacts) != 2 || contacts[0].Name != "dave" || contacts[1].Name != "ian" {
+		t.Errorf("Unexpected results %v", contacts)
+	}
+}
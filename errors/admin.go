@@ -105,3 +105,43 @@ func NewAdminNotSSLEnabledError() Error {
 	return &err{level: EXCEPTION, ICode: ADMIN_SSL_NOT_ENABLED, IKey: "admin.service.ssl_cert",
 		InternalMsg: "server is not ssl enabled", InternalCaller: CallerN(1)}
 }
+
+func NewAdminQuotaKindError(kind string) Error {
+	return &err{level: EXCEPTION, ICode: 2150, IKey: "admin.quota.kind",
+		InternalMsg: fmt.Sprintf("Unknown quota kind: %s", kind), InternalCaller: CallerN(1)}
+}
+
+func NewAdminTaskNotFoundError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 2160, IKey: "admin.task.not_found",
+		InternalMsg: fmt.Sprintf("Unknown task: %s", name), InternalCaller: CallerN(1)}
+}
+
+func NewAdminTaskRunningError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 2170, IKey: "admin.task.running",
+		InternalMsg: fmt.Sprintf("Task %s is already running", name), InternalCaller: CallerN(1)}
+}
+
+func NewAdminTaskIntervalError(name string, interval interface{}) Error {
+	return &err{level: EXCEPTION, ICode: 2180, IKey: "admin.task.interval",
+		InternalMsg: fmt.Sprintf("Invalid interval %v for task %s", interval, name), InternalCaller: CallerN(1)}
+}
+
+func NewAdminTaskExistsError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 2190, IKey: "admin.task.exists",
+		InternalMsg: fmt.Sprintf("Task %s already exists", name), InternalCaller: CallerN(1)}
+}
+
+func NewAdminTaskDeleteError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 2200, IKey: "admin.task.delete",
+		InternalMsg: fmt.Sprintf("Task %s cannot be deleted", name), InternalCaller: CallerN(1)}
+}
+
+func NewAdminContinuousQueryError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 2210, IKey: "admin.continuous_query", ICause: e,
+		InternalMsg: "Invalid continuous query: " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewAdminPlanError(msg string) Error {
+	return &err{level: EXCEPTION, ICode: 2220, IKey: "admin.plan",
+		InternalMsg: msg, InternalCaller: CallerN(1)}
+}
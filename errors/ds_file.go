@@ -9,7 +9,9 @@
 
 package errors
 
-import ()
+import (
+	"fmt"
+)
 
 // Datastore File based error codes
 
@@ -72,3 +74,26 @@ func NewFilePrimaryIdxNoDropError(e error, msg string) Error {
 	return &err{level: EXCEPTION, ICode: 15011, IKey: "datastore.file.primary_idx_no_drop", ICause: e,
 		InternalMsg: "Primary Index cannot be dropped " + msg, InternalCaller: CallerN(1)}
 }
+
+const FILE_CORRUPT_DOCUMENT = 15012
+
+// An empty quarantine path means the document was left in place.
+func NewFileCorruptDocumentError(key, keyspace, quarantine string) Error {
+	msg := fmt.Sprintf("Document %s of keyspace %s failed its checksum", key, keyspace)
+	if quarantine != "" {
+		msg += " and was moved to " + quarantine
+	}
+
+	return &err{level: WARNING, ICode: FILE_CORRUPT_DOCUMENT, IKey: "datastore.file.corrupt_document",
+		InternalMsg: msg + ".", InternalCaller: CallerN(1)}
+}
+
+func NewFileLockTimeoutError(e error, keyspace string) Error {
+	return &err{level: EXCEPTION, ICode: 15013, IKey: "datastore.file.lock_timeout", ICause: e,
+		InternalMsg: "Timed out waiting for the lock of keyspace " + keyspace, InternalCaller: CallerN(1)}
+}
+
+func NewFileReadonlyError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 15014, IKey: "datastore.file.readonly", ICause: e,
+		InternalMsg: "The datastore is open read-only " + msg, InternalCaller: CallerN(1)}
+}
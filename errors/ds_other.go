@@ -59,3 +59,19 @@ func NewOtherKeyNotFoundError(e error, msg string) Error {
 	return &err{level: EXCEPTION, ICode: 16007, IKey: "datastore.other.key_not_found", ICause: e,
 		InternalMsg: "Key not found " + msg, InternalCaller: CallerN(1)}
 }
+
+func NewOtherVirtualIndexScanError(name string) Error {
+	return &err{level: EXCEPTION, ICode: 16008, IKey: "datastore.other.virtual_index_scan",
+		InternalMsg:    fmt.Sprintf("Virtual index %s exists only for planning, and cannot be scanned.", name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewOtherDuplicateKeyspaceError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 16009, IKey: "datastore.other.duplicate_keyspace", ICause: e,
+		InternalMsg: "Duplicate Keyspace " + msg, InternalCaller: CallerN(1)}
+}
+
+func NewOtherKeyExistsError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 16010, IKey: "datastore.other.key_exists", ICause: e,
+		InternalMsg: "Key Exists " + msg, InternalCaller: CallerN(1)}
+}
@@ -36,6 +36,19 @@ const (
 
 type Errors []Error
 
+/*
+The errors of one input, such as the syntax errors of a statement,
+reported together. The message joins their messages.
+*/
+func (this Errors) Error() string {
+	msgs := make([]string, len(this))
+	for i, e := range this {
+		msgs[i] = e.Error()
+	}
+
+	return strings.Join(msgs, " \n ")
+}
+
 // Error will eventually include code, message key, and internal error
 // object (cause) and message
 type Error interface {
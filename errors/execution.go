@@ -10,6 +10,7 @@
 package errors
 
 import (
+	"encoding/json"
 	"fmt"
 
 	"github.com/couchbase/query/value"
@@ -22,6 +23,70 @@ func NewEvaluationError(e error, termType string) Error {
 		InternalMsg: fmt.Sprintf("Error evaluating %s.", termType), InternalCaller: CallerN(1)}
 }
 
+/*
+EvaluationContextError is an error evaluating an expression, with the
+text of the expression and the key of the document it was evaluated
+on. Either is empty if unknown or not reported.
+*/
+type EvaluationContextError interface {
+	Error
+	Expression() string
+	Key() string
+}
+
+type evalErr struct {
+	err
+	expr string
+	key  string
+}
+
+func NewEvaluationContextError(e error, termType, expr, key string) EvaluationContextError {
+	msg := fmt.Sprintf("Error evaluating %s.", termType)
+	if expr != "" {
+		msg = fmt.Sprintf("Error evaluating %s %s.", termType, expr)
+	}
+
+	if key != "" {
+		msg = fmt.Sprintf("%s Document key: %s.", msg, key)
+	}
+
+	return &evalErr{
+		err: err{level: EXCEPTION, ICode: 5010, IKey: "execution.evaluation_error", ICause: e,
+			InternalMsg: msg, InternalCaller: CallerN(1)},
+		expr: expr,
+		key:  key,
+	}
+}
+
+func (e *evalErr) Expression() string {
+	return e.expr
+}
+
+func (e *evalErr) Key() string {
+	return e.key
+}
+
+func (e *evalErr) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"code":    e.ICode,
+		"key":     e.IKey,
+		"message": e.InternalMsg,
+	}
+	if e.ICause != nil {
+		m["cause"] = e.ICause.Error()
+	}
+	if e.expr != "" {
+		m["expression"] = e.expr
+	}
+	if e.key != "" {
+		m["document_key"] = e.key
+	}
+	if e.InternalCaller != "" {
+		m["caller"] = e.InternalCaller
+	}
+	return json.Marshal(m)
+}
+
 func NewGroupUpdateError(e error, msg string) Error {
 	return &err{level: EXCEPTION, ICode: 5020, IKey: "execution.group_update_error", ICause: e,
 		InternalMsg: msg, InternalCaller: CallerN(1)}
@@ -102,3 +167,132 @@ func NewUnnestInvalidPosition(pos interface{}) Error {
 	return &err{level: EXCEPTION, ICode: 5180, IKey: "execution.unnest_invalid_position",
 		InternalMsg: fmt.Sprintf("Invalid UNNEST position of type %T.", pos), InternalCaller: CallerN(1)}
 }
+
+const INDEX_NOT_ONLINE = 5190
+
+func NewIndexNotOnlineError(index string, state string) Error {
+	return &err{level: EXCEPTION, ICode: INDEX_NOT_ONLINE, IKey: "execution.index_not_online",
+		InternalMsg: fmt.Sprintf("Index %s is not online (state: %s).", index, state), InternalCaller: CallerN(1)}
+}
+
+func NewQuotaDocumentsError(kind, name string, limit int64) Error {
+	return &err{level: EXCEPTION, ICode: 5200, IKey: "execution.quota_documents_exceeded",
+		InternalMsg:    fmt.Sprintf("Request exceeded the quota of %d documents for %s %s.", limit, kind, name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewQuotaMutationsError(kind, name string, limit int64) Error {
+	return &err{level: EXCEPTION, ICode: 5210, IKey: "execution.quota_mutations_exceeded",
+		InternalMsg:    fmt.Sprintf("Request exceeded the quota of %d mutations for %s %s.", limit, kind, name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewQuotaResultSizeError(kind, name string, limit int64) Error {
+	return &err{level: EXCEPTION, ICode: 5220, IKey: "execution.quota_result_size_exceeded",
+		InternalMsg:    fmt.Sprintf("Request exceeded the quota of %d result bytes for %s %s.", limit, kind, name),
+		InternalCaller: CallerN(1)}
+}
+
+func NewPolicyExistsError(keyspace string) Error {
+	return &err{level: EXCEPTION, ICode: 5230, IKey: "execution.policy_exists",
+		InternalMsg: fmt.Sprintf("Keyspace %s already has a policy.", keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewPolicyNotFoundError(keyspace string) Error {
+	return &err{level: EXCEPTION, ICode: 5240, IKey: "execution.policy_not_found",
+		InternalMsg: fmt.Sprintf("Keyspace %s has no policy.", keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewPolicyViolationError(keyspace, key string) Error {
+	return &err{level: EXCEPTION, ICode: 5250, IKey: "execution.policy_violation",
+		InternalMsg:    fmt.Sprintf("Document %s does not satisfy the policy on keyspace %s.", key, keyspace),
+		InternalCaller: CallerN(1)}
+}
+
+func NewMaskExistsError(keyspace, path string) Error {
+	return &err{level: EXCEPTION, ICode: 5260, IKey: "execution.mask_exists",
+		InternalMsg: fmt.Sprintf("Path %s of keyspace %s is already masked.", path, keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewMaskNotFoundError(keyspace, path string) Error {
+	return &err{level: EXCEPTION, ICode: 5270, IKey: "execution.mask_not_found",
+		InternalMsg: fmt.Sprintf("Path %s of keyspace %s is not masked.", path, keyspace), InternalCaller: CallerN(1)}
+}
+
+func NewSessionSettingError(e error) Error {
+	return &err{level: EXCEPTION, ICode: 5280, IKey: "execution.session_setting", ICause: e,
+		InternalMsg: "Error in session setting", InternalCaller: CallerN(1)}
+}
+
+func NewApproximateAggregateWarning(aggregate string, stderr float64) Error {
+	return &err{level: WARNING, ICode: 5290, IKey: "execution.approximate_aggregate",
+		InternalMsg: fmt.Sprintf("Results of %s are estimates, with a relative standard error of %.2f%%.",
+			aggregate, stderr*100),
+		InternalCaller: CallerN(1)}
+}
+
+func NewMissingComparisonWarning(expr string) Error {
+	return &err{level: WARNING, ICode: 5300, IKey: "execution.missing_comparison",
+		InternalMsg:    fmt.Sprintf("Comparison %s involves MISSING, so its result is MISSING.", expr),
+		InternalCaller: CallerN(1)}
+}
+
+func NewInvalidCastError(val, target string) Error {
+	return &err{level: EXCEPTION, ICode: 5310, IKey: "execution.invalid_cast",
+		InternalMsg:    fmt.Sprintf("Cannot cast %s to %s.", val, target),
+		InternalCaller: CallerN(1)}
+}
+
+func NewOutfileError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 5320, IKey: "execution.outfile", ICause: e,
+		InternalMsg: msg, InternalCaller: CallerN(1)}
+}
+
+func NewImportError(e error, msg string) Error {
+	return &err{level: EXCEPTION, ICode: 5330, IKey: "execution.import", ICause: e,
+		InternalMsg: msg, InternalCaller: CallerN(1)}
+}
+
+func NewImportRejectedWarning(rows int64, path, errors string) Error {
+	return &err{level: WARNING, ICode: 5331, IKey: "execution.import_rejected",
+		InternalMsg:    fmt.Sprintf("%d rows of IMPORT %s were rejected; see %s.", rows, path, errors),
+		InternalCaller: CallerN(1)}
+}
+
+func NewViewExistsError(view string) Error {
+	return &err{level: EXCEPTION, ICode: 5340, IKey: "execution.view_exists",
+		InternalMsg: fmt.Sprintf("View or keyspace %s already exists.", view), InternalCaller: CallerN(1)}
+}
+
+func NewViewNotFoundError(view string) Error {
+	return &err{level: EXCEPTION, ICode: 5341, IKey: "execution.view_not_found",
+		InternalMsg: fmt.Sprintf("View %s not found.", view), InternalCaller: CallerN(1)}
+}
+
+func NewViewRefreshError(e error, view string) Error {
+	return &err{level: EXCEPTION, ICode: 5342, IKey: "execution.view_refresh", ICause: e,
+		InternalMsg: fmt.Sprintf("Error refreshing materialized view %s.", view), InternalCaller: CallerN(1)}
+}
+
+func NewViewTypeError(view string, materialized bool) Error {
+	msg := "View %s is not materialized."
+	if materialized {
+		msg = "View %s is materialized."
+	}
+
+	return &err{level: EXCEPTION, ICode: 5343, IKey: "execution.view_type",
+		InternalMsg: fmt.Sprintf(msg, view), InternalCaller: CallerN(1)}
+}
+
+func NewCorruptDocumentsWarning(count int, keyspace string) Error {
+	return &err{level: WARNING, ICode: 5350, IKey: "execution.corrupt_documents",
+		InternalMsg:    fmt.Sprintf("%d corrupt documents of keyspace %s were skipped and quarantined.", count, keyspace),
+		InternalCaller: CallerN(1)}
+}
+
+func NewUpdateCasMismatchError(keys []string, retries int) Error {
+	return &err{level: EXCEPTION, ICode: 5360, IKey: "execution.update_cas_mismatch",
+		InternalMsg: fmt.Sprintf("UPDATE of %d documents failed after %d retries, because of concurrent modifications: %v.",
+			len(keys), retries, keys),
+		InternalCaller: CallerN(1)}
+}
@@ -9,7 +9,9 @@
 
 package errors
 
-import ()
+import (
+	"encoding/json"
+)
 
 // Parse errors - errors that are created in the parse package
 func NewParseSyntaxError(e error, msg string) Error {
@@ -21,3 +23,59 @@ func NewParseSyntaxError(e error, msg string) Error {
 			InternalMsg: msg, InternalCaller: CallerN(1)}
 	}
 }
+
+/*
+ParseError is a syntax error at a position of the statement text,
+with the token found there. Line and column start at 1; the token
+is empty at the end of the input.
+*/
+type ParseError interface {
+	Error
+	Line() int
+	Column() int
+	Token() string
+}
+
+type parseErr struct {
+	err
+	line   int
+	column int
+	token  string
+}
+
+func NewParseErrorAt(msg string, line, column int, token string) ParseError {
+	return &parseErr{
+		err: err{level: EXCEPTION, ICode: 4100, IKey: "parse_error",
+			InternalMsg: msg, InternalCaller: CallerN(1)},
+		line:   line,
+		column: column,
+		token:  token,
+	}
+}
+
+func (e *parseErr) Line() int {
+	return e.line
+}
+
+func (e *parseErr) Column() int {
+	return e.column
+}
+
+func (e *parseErr) Token() string {
+	return e.token
+}
+
+func (e *parseErr) MarshalJSON() ([]byte, error) {
+	m := map[string]interface{}{
+		"code":    e.ICode,
+		"key":     e.IKey,
+		"message": e.InternalMsg,
+		"line":    e.line,
+		"column":  e.column,
+		"token":   e.token,
+	}
+	if e.InternalCaller != "" {
+		m["caller"] = e.InternalCaller
+	}
+	return json.Marshal(m)
+}
@@ -71,3 +71,19 @@ func NewPreparedEncodingMismatchError(name string) Error {
 	return &err{level: EXCEPTION, ICode: 4080, IKey: "plan.build_prepared.name_encoded_plan_mismatch",
 		InternalMsg: fmt.Sprintf("Encoded plan parameter does not match encoded plan of %s", name), InternalCaller: CallerN(1)}
 }
+
+const READONLY_STATEMENT = 4090
+
+func NewReadonlyStatementError(what string) Error {
+	return &err{level: EXCEPTION, ICode: READONLY_STATEMENT, IKey: "plan.build.readonly",
+		InternalMsg:    fmt.Sprintf("The %s is read-only and cannot accept this write statement.", what),
+		InternalCaller: CallerN(1)}
+}
+
+const KEYSPACE_CAPABILITY = 4091
+
+func NewKeyspaceCapabilityError(keyspace, capability string) Error {
+	return &err{level: EXCEPTION, ICode: KEYSPACE_CAPABILITY, IKey: "plan.build.keyspace_capability",
+		InternalMsg:    fmt.Sprintf("Keyspace %s does not support %s.", keyspace, capability),
+		InternalCaller: CallerN(1)}
+}
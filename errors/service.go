@@ -59,6 +59,12 @@ func NewServiceErrorTypeMismatch(feature string, expected string) Error {
 		InternalMsg: fmt.Sprintf("%s has to be of type %s", feature, expected), InternalCaller: CallerN(1)}
 }
 
+func NewServiceErrorCapExceeded(feature string, value, max int64) Error {
+	return &err{level: EXCEPTION, ICode: 1075, IKey: "service.io.request.cap_exceeded",
+		InternalMsg:    fmt.Sprintf("%s value %d exceeds the server maximum of %d", feature, value, max),
+		InternalCaller: CallerN(1)}
+}
+
 func NewServiceErrorInvalidJSON(e error) Error {
 	return &err{level: EXCEPTION, ICode: 1100, IKey: "service.io.response.invalid_json", ICause: e,
 		InternalMsg: "Invalid JSON in results", InternalCaller: CallerN(1)}
@@ -73,3 +79,66 @@ func NewServiceErrorMediaType(mediaType string) Error {
 	return &err{level: EXCEPTION, ICode: 1120, IKey: "service.io.request.media_type",
 		InternalMsg: fmt.Sprintf("Unsupported media type: %s", mediaType), InternalCaller: CallerN(1)}
 }
+
+// For applications rejecting statements from a server rewriter.
+func NewServiceErrorStatementRejected(reason string) Error {
+	return &err{level: EXCEPTION, ICode: 1130, IKey: "service.rewrite.rejected",
+		InternalMsg: fmt.Sprintf("Statement rejected: %s", reason), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorSession(id string) Error {
+	return &err{level: EXCEPTION, ICode: 1140, IKey: "service.io.request.session",
+		InternalMsg: fmt.Sprintf("Unknown or expired session: %s", id), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorCancelled(id string) Error {
+	return &err{level: EXCEPTION, ICode: 1160, IKey: "service.request.cancelled",
+		InternalMsg: fmt.Sprintf("Request %s cancelled", id), InternalCaller: CallerN(1)}
+}
+
+func NewServiceWarningTruncated(limit string, value, count int) Error {
+	return &err{level: WARNING, ICode: 1170, IKey: "service.io.response.truncated",
+		InternalMsg:    fmt.Sprintf("Results truncated after %d results: %s %d exceeded", count, limit, value),
+		InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorAuthentication(scheme, reason string) Error {
+	return &err{level: EXCEPTION, ICode: 1150, IKey: "service.io.request.authentication",
+		InternalMsg: fmt.Sprintf("Authentication failed (%s): %s", scheme, reason), InternalCaller: CallerN(1)}
+}
+
+const STATEMENT_FAILED = 1180
+
+func NewServiceErrorStatementFailed(index, count int) Error {
+	return &err{level: EXCEPTION, ICode: STATEMENT_FAILED, IKey: "service.request.statement_failed",
+		InternalMsg:    fmt.Sprintf("Statement %d of %d failed; the remaining statements were not executed.", index, count),
+		InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorContinuation(token string) Error {
+	return &err{level: EXCEPTION, ICode: 1190, IKey: "service.request.continuation",
+		InternalMsg: fmt.Sprintf("Unknown or expired continuation: %s", token), InternalCaller: CallerN(1)}
+}
+
+func NewServiceErrorPaging(msg string) Error {
+	return &err{level: EXCEPTION, ICode: 1200, IKey: "service.request.paging",
+		InternalMsg: msg, InternalCaller: CallerN(1)}
+}
+
+const STATEMENT_DENIED = 1210
+
+func NewServiceErrorStatementDenied(rule, keyspace, reason string) Error {
+	return &err{level: EXCEPTION, ICode: STATEMENT_DENIED, IKey: "service.guard.denied",
+		InternalMsg:    fmt.Sprintf("Statement denied by the %s rule on keyspace %s: %s", rule, keyspace, reason),
+		InternalCaller: CallerN(1)}
+}
+
+const FULL_SCAN = 1220
+
+func NewServiceErrorFullScan(keyspace string, count, threshold int64, advice string) Error {
+	return &err{level: EXCEPTION, ICode: FULL_SCAN, IKey: "service.guard.full_scan",
+		InternalMsg: fmt.Sprintf("The statement scans all %d documents of keyspace %s, more than the"+
+			" full-scan-threshold of %d. %s Set allow_full_scan=true to run it anyway.",
+			count, keyspace, threshold, advice),
+		InternalCaller: CallerN(1)}
+}
@@ -0,0 +1,47 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"github.com/couchbase/query/value"
+)
+
+type Advise struct {
+	base
+	advice value.Value
+}
+
+func NewAdvise(advice value.Value, context *Context) *Advise {
+	rv := &Advise{
+		base:   newBase(context),
+		advice: advice,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *Advise) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitAdvise(this)
+}
+
+func (this *Advise) Copy() Operator {
+	return &Advise{this.base.copy(), this.advice}
+}
+
+func (this *Advise) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		this.sendItem(value.NewAnnotatedValue(this.advice))
+	})
+}
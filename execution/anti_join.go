@@ -0,0 +1,135 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Anti-join against the results of an uncorrelated subquery. The
+subquery is evaluated once, by the first of the parallel copies to
+start, into a hash set that all the copies probe.
+*/
+type AntiJoin struct {
+	base
+	plan  *plan.AntiJoin
+	build *antiJoinBuild
+}
+
+type antiJoinBuild struct {
+	once sync.Once
+	set  *value.Set
+	err  error
+}
+
+func NewAntiJoin(plan *plan.AntiJoin, context *Context) *AntiJoin {
+	rv := &AntiJoin{
+		base:  newBase(context),
+		plan:  plan,
+		build: &antiJoinBuild{},
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *AntiJoin) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitAntiJoin(this)
+}
+
+func (this *AntiJoin) Copy() Operator {
+	return &AntiJoin{this.base.copy(), this.plan, this.build}
+}
+
+func (this *AntiJoin) RunOnce(context *Context, parent value.Value) {
+	this.runConsumer(this, context, parent)
+}
+
+func (this *AntiJoin) beforeItems(context *Context, parent value.Value) bool {
+	build := this.build
+	build.once.Do(func() {
+		results, err := context.EvaluateSubquery(this.plan.Query(), parent)
+		if err == nil {
+			build.set = value.NewSet(_DISTINCT_CAP)
+			err = build.add(results)
+		}
+
+		if err != nil {
+			build.err = err
+			context.Error(errors.NewEvaluationError(err, "anti-join subquery"))
+		}
+	})
+
+	return build.err == nil
+}
+
+func (this *antiJoinBuild) add(results value.Value) error {
+	if stream, ok := results.(*value.StreamValue); ok {
+		it := stream.Iterator()
+		for v, ok := it.Next(); ok; v, ok = it.Next() {
+			this.set.Add(v)
+		}
+
+		return it.Err()
+	}
+
+	if a, ok := results.Actual().([]interface{}); ok {
+		for _, v := range a {
+			this.set.Add(value.NewValue(v))
+		}
+	}
+
+	return nil
+}
+
+func (this *AntiJoin) processItem(item value.AnnotatedValue, context *Context) bool {
+	term := this.plan.Term()
+	if term == nil {
+		return this.build.set.Len() > 0 || this.sendItem(item)
+	}
+
+	v, err := term.Evaluate(item, context)
+	if err != nil {
+		context.Error(errors.NewEvaluationError(err, "anti-join term"))
+		return false
+	}
+
+	// MISSING NOT IN (...) is MISSING
+	if v.Type() == value.MISSING && !this.plan.Exists() {
+		return true
+	}
+
+	if this.matches(v) {
+		return true
+	}
+
+	return this.sendItem(item)
+}
+
+/*
+Matches as IN and = do: NULL and MISSING match nothing, and arrays
+and objects match only if they contain no NULL or MISSING, for which
+Equals is not TRUE.
+*/
+func (this *AntiJoin) matches(v value.Value) bool {
+	switch v.Type() {
+	case value.MISSING, value.NULL:
+		return false
+	case value.ARRAY, value.OBJECT:
+		return this.build.set.Has(v) && v.Equals(v).Truth()
+	default:
+		return this.build.set.Has(v)
+	}
+}
@@ -24,9 +24,9 @@ type Authorize struct {
 	childChannel StopChannel
 }
 
-func NewAuthorize(plan *plan.Authorize, child Operator) *Authorize {
+func NewAuthorize(plan *plan.Authorize, child Operator, context *Context) *Authorize {
 	rv := &Authorize{
-		base:         newBase(),
+		base:         newBase(context),
 		plan:         plan,
 		child:        child,
 		childChannel: make(StopChannel, 1),
@@ -57,8 +57,10 @@ func (this *Authorize) RunOnce(context *Context, parent value.Value) {
 
 		timer := time.Now()
 
+		// Roles granted by the authenticator take precedence
+		_, granted := datastore.RolesGrant(context.Roles(), this.plan.Privileges())
 		ds := datastore.GetDatastore()
-		if ds != nil {
+		if ds != nil && !granted {
 			err := ds.Authorize(this.plan.Privileges(), context.Credentials())
 			if err != nil {
 				context.Fatal(err)
@@ -51,9 +51,9 @@ func GetPipelineCap() int64 {
 	return atomic.LoadInt64(&pipelineCap)
 }
 
-func newBase() base {
+func newBase(context *Context) base {
 	return base{
-		itemChannel: make(value.AnnotatedChannel, GetPipelineCap()),
+		itemChannel: make(value.AnnotatedChannel, context.PipelineCap()),
 		stopChannel: make(StopChannel, 1),
 	}
 }
@@ -107,9 +107,10 @@ func (this *base) SetParent(parent Parent) {
 	this.parent = parent
 }
 
+// Copies keep the pipeline capacity of the request.
 func (this *base) copy() base {
 	return base{
-		itemChannel: make(value.AnnotatedChannel, GetPipelineCap()),
+		itemChannel: make(value.AnnotatedChannel, cap(this.itemChannel)),
 		stopChannel: make(StopChannel, 1),
 		input:       this.input,
 		output:      this.output,
@@ -225,7 +226,7 @@ func (this *base) notifyStop() {
 }
 
 type batcher interface {
-	allocateBatch()
+	allocateBatch(context *Context)
 	enbatch(item value.AnnotatedValue, b batcher, context *Context) bool
 	flushBatch(context *Context) bool
 	releaseBatch()
@@ -252,8 +253,18 @@ func getBatchPool() *value.AnnotatedPool {
 	return _BATCH_POOL.Load().(*value.AnnotatedPool)
 }
 
-func (this *base) allocateBatch() {
-	this.batch = getBatchPool().Get()
+// Batches of the server batch size are pooled.
+func (this *base) allocateBatch(context *Context) {
+	this.allocateBatchSize(context.PipelineBatch())
+}
+
+func (this *base) allocateBatchSize(size int) {
+	pool := getBatchPool()
+	if size == pool.Size() {
+		this.batch = pool.Get()
+	} else {
+		this.batch = make(value.AnnotatedValues, 0, size)
+	}
 }
 
 func (this *base) releaseBatch() {
@@ -262,15 +273,20 @@ func (this *base) releaseBatch() {
 }
 
 func (this *base) enbatch(item value.AnnotatedValue, b batcher, context *Context) bool {
+	return this.enbatchSize(item, b, context.PipelineBatch(), context)
+}
+
+// Batch items in batches of the given size.
+func (this *base) enbatchSize(item value.AnnotatedValue, b batcher, size int, context *Context) bool {
 	if this.batch == nil {
-		this.allocateBatch()
+		this.allocateBatchSize(size)
 	} else if len(this.batch) == cap(this.batch) {
 		if !b.flushBatch(context) {
 			return false
 		}
 
 		if len(this.batch) == cap(this.batch) {
-			this.allocateBatch()
+			this.allocateBatchSize(size)
 		}
 	}
 
@@ -10,6 +10,7 @@
 package execution
 
 import (
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/util"
 )
@@ -33,31 +34,40 @@ type builder struct {
 
 // Scan
 func (this *builder) VisitPrimaryScan(plan *plan.PrimaryScan) (interface{}, error) {
-	return NewPrimaryScan(plan), nil
+	return NewPrimaryScan(plan, this.context), nil
 }
 
 func (this *builder) VisitParentScan(plan *plan.ParentScan) (interface{}, error) {
-	return NewParentScan(), nil
+	return NewParentScan(this.context), nil
 }
 
 func (this *builder) VisitIndexScan(plan *plan.IndexScan) (interface{}, error) {
-	return NewIndexScan(plan), nil
+	return NewIndexScan(plan, this.context), nil
 }
 
 func (this *builder) VisitKeyScan(plan *plan.KeyScan) (interface{}, error) {
-	return NewKeyScan(plan), nil
+	return NewKeyScan(plan, this.context), nil
 }
 
 func (this *builder) VisitValueScan(plan *plan.ValueScan) (interface{}, error) {
-	return NewValueScan(plan), nil
+	return NewValueScan(plan, this.context), nil
+}
+
+// The batch of IMPORT is that of the upserts of the request.
+func (this *builder) VisitImportScan(plan *plan.ImportScan) (interface{}, error) {
+	if plan.Batch() > 0 {
+		this.context.SetPipelineBatch(plan.Batch())
+	}
+
+	return NewImportScan(plan, this.context), nil
 }
 
 func (this *builder) VisitDummyScan(plan *plan.DummyScan) (interface{}, error) {
-	return NewDummyScan(), nil
+	return NewDummyScan(this.context), nil
 }
 
 func (this *builder) VisitCountScan(plan *plan.CountScan) (interface{}, error) {
-	return NewCountScan(plan), nil
+	return NewCountScan(plan, this.context), nil
 }
 
 func (this *builder) VisitIntersectScan(plan *plan.IntersectScan) (interface{}, error) {
@@ -72,7 +82,7 @@ func (this *builder) VisitIntersectScan(plan *plan.IntersectScan) (interface{},
 		scans = append(scans, s.(Operator))
 	}
 
-	return NewIntersectScan(scans), nil
+	return NewIntersectScan(scans, this.context), nil
 }
 
 func (this *builder) VisitUnionScan(plan *plan.UnionScan) (interface{}, error) {
@@ -87,62 +97,66 @@ func (this *builder) VisitUnionScan(plan *plan.UnionScan) (interface{}, error) {
 		scans = append(scans, s.(Operator))
 	}
 
-	return NewUnionScan(scans), nil
+	return NewUnionScan(scans, this.context), nil
 }
 
 // Fetch
 func (this *builder) VisitFetch(plan *plan.Fetch) (interface{}, error) {
-	return NewFetch(plan), nil
+	return NewFetch(plan, this.context), nil
 }
 
 // Join
 func (this *builder) VisitJoin(plan *plan.Join) (interface{}, error) {
-	return NewJoin(plan), nil
+	return NewJoin(plan, this.context), nil
 }
 
 func (this *builder) VisitNest(plan *plan.Nest) (interface{}, error) {
-	return NewNest(plan), nil
+	return NewNest(plan, this.context), nil
 }
 
 func (this *builder) VisitUnnest(plan *plan.Unnest) (interface{}, error) {
-	return NewUnnest(plan), nil
+	return NewUnnest(plan, this.context), nil
+}
+
+func (this *builder) VisitAntiJoin(plan *plan.AntiJoin) (interface{}, error) {
+	return NewAntiJoin(plan, this.context), nil
 }
 
 // Let + Letting
 func (this *builder) VisitLet(plan *plan.Let) (interface{}, error) {
-	return NewLet(plan), nil
+	return NewLet(plan, this.context), nil
 }
 
 // Filter
 func (this *builder) VisitFilter(plan *plan.Filter) (interface{}, error) {
-	return NewFilter(plan), nil
+	return NewFilter(plan, this.context), nil
 }
 
 // Group
 func (this *builder) VisitInitialGroup(plan *plan.InitialGroup) (interface{}, error) {
-	return NewInitialGroup(plan), nil
+	return NewInitialGroup(plan, this.context), nil
 }
 
 func (this *builder) VisitIntermediateGroup(plan *plan.IntermediateGroup) (interface{}, error) {
-	return NewIntermediateGroup(plan), nil
+	return NewIntermediateGroup(plan, this.context), nil
 }
 
 func (this *builder) VisitFinalGroup(plan *plan.FinalGroup) (interface{}, error) {
-	return NewFinalGroup(plan), nil
+	return NewFinalGroup(plan, this.context), nil
 }
 
 // Project
 func (this *builder) VisitInitialProject(plan *plan.InitialProject) (interface{}, error) {
-	return NewInitialProject(plan), nil
+	return NewInitialProject(plan, this.context), nil
 }
 
 func (this *builder) VisitFinalProject(plan *plan.FinalProject) (interface{}, error) {
-	return NewFinalProject(), nil
+	return NewFinalProject(this.context), nil
 }
 
 // Distinct
 func (this *builder) VisitDistinct(plan *plan.Distinct) (interface{}, error) {
-	return NewDistinct(false), nil
+	return NewDistinct(false, this.context), nil
 }
 
 // Set operators
@@ -158,7 +172,7 @@ func (this *builder) VisitUnionAll(plan *plan.UnionAll) (interface{}, error) {
 		children = append(children, c.(Operator))
 	}
 
-	return NewUnionAll(children...), nil
+	return NewUnionAll(this.context, children...), nil
 }
 
 func (this *builder) VisitIntersectAll(plan *plan.IntersectAll) (interface{}, error) {
@@ -172,7 +186,7 @@ func (this *builder) VisitIntersectAll(plan *plan.IntersectAll) (interface{}, er
 		return nil, e
 	}
 
-	return NewIntersectAll(first.(Operator), second.(Operator)), nil
+	return NewIntersectAll(first.(Operator), second.(Operator), this.context), nil
 }
 
 func (this *builder) VisitExceptAll(plan *plan.ExceptAll) (interface{}, error) {
@@ -186,53 +200,53 @@ func (this *builder) VisitExceptAll(plan *plan.ExceptAll) (interface{}, error) {
 		return nil, e
 	}
 
-	return NewExceptAll(first.(Operator), second.(Operator)), nil
+	return NewExceptAll(first.(Operator), second.(Operator), this.context), nil
 }
 
 // Order
 func (this *builder) VisitOrder(plan *plan.Order) (interface{}, error) {
-	return NewOrder(plan), nil
+	return NewOrder(plan, this.context), nil
 }
 
 // Offset
 func (this *builder) VisitOffset(plan *plan.Offset) (interface{}, error) {
-	return NewOffset(plan), nil
+	return NewOffset(plan, this.context), nil
 }
 
 func (this *builder) VisitLimit(plan *plan.Limit) (interface{}, error) {
-	return NewLimit(plan), nil
+	return NewLimit(plan, this.context), nil
 }
 
 // Insert
 func (this *builder) VisitSendInsert(plan *plan.SendInsert) (interface{}, error) {
-	return NewSendInsert(plan), nil
+	return NewSendInsert(plan, this.context), nil
 }
 
 // Upsert
 func (this *builder) VisitSendUpsert(plan *plan.SendUpsert) (interface{}, error) {
-	return NewSendUpsert(plan), nil
+	return NewSendUpsert(plan, this.context), nil
 }
 
 // Delete
 func (this *builder) VisitSendDelete(plan *plan.SendDelete) (interface{}, error) {
-	return NewSendDelete(plan), nil
+	return NewSendDelete(plan, this.context), nil
 }
 
 // Update
 func (this *builder) VisitClone(plan *plan.Clone) (interface{}, error) {
-	return NewClone(plan), nil
+	return NewClone(plan, this.context), nil
 }
 
 func (this *builder) VisitSet(plan *plan.Set) (interface{}, error) {
-	return NewSet(plan), nil
+	return NewSet(plan, this.context), nil
 }
 
 func (this *builder) VisitUnset(plan *plan.Unset) (interface{}, error) {
-	return NewUnset(plan), nil
+	return NewUnset(plan, this.context), nil
 }
 
 func (this *builder) VisitSendUpdate(plan *plan.SendUpdate) (interface{}, error) {
-	return NewSendUpdate(plan), nil
+	return NewSendUpdate(plan, this.context), nil
 }
 
 // Merge
@@ -263,12 +277,12 @@ func (this *builder) VisitMerge(plan *plan.Merge) (interface{}, error) {
 		insert = op.(Operator)
 	}
 
-	return NewMerge(plan, update, delete, insert), nil
+	return NewMerge(plan, update, delete, insert, this.context), nil
 }
 
 // Alias
 func (this *builder) VisitAlias(plan *plan.Alias) (interface{}, error) {
-	return NewAlias(plan), nil
+	return NewAlias(plan, this.context), nil
 }
 
 // Authorize
@@ -278,7 +292,7 @@ func (this *builder) VisitAuthorize(plan *plan.Authorize) (interface{}, error) {
 		return nil, err
 	}
 
-	return NewAuthorize(plan, child.(Operator)), nil
+	return NewAuthorize(plan, child.(Operator), this.context), nil
 }
 
 // Parallel
@@ -293,7 +307,7 @@ func (this *builder) VisitParallel(plan *plan.Parallel) (interface{}, error) {
 	if maxParallelism == 1 {
 		return child, nil
 	} else {
-		return NewParallel(plan, child.(Operator)), nil
+		return NewParallel(plan, child.(Operator), this.context), nil
 	}
 }
 
@@ -310,12 +324,12 @@ func (this *builder) VisitSequence(plan *plan.Sequence) (interface{}, error) {
 		children = append(children, child.(Operator))
 	}
 
-	return NewSequence(children...), nil
+	return NewSequence(this.context, children...), nil
 }
 
 // Discard
 func (this *builder) VisitDiscard(plan *plan.Discard) (interface{}, error) {
-	return NewDiscard(), nil
+	return NewDiscard(this.context), nil
 }
 
 // Stream
@@ -323,47 +337,132 @@ func (this *builder) VisitStream(plan *plan.Stream) (interface{}, error) {
 	return NewStream(), nil
 }
 
+// Outfile
+func (this *builder) VisitOutfile(plan *plan.Outfile) (interface{}, error) {
+	return NewOutfile(plan, this.context), nil
+}
+
 // Collect
 func (this *builder) VisitCollect(plan *plan.Collect) (interface{}, error) {
-	return NewCollect(), nil
+	return NewCollect(this.context), nil
 }
 
 // Channel
 func (this *builder) VisitChannel(plan *plan.Channel) (interface{}, error) {
-	return NewChannel(), nil
+	return NewChannel(this.context), nil
 }
 
 // CreateIndex
 func (this *builder) VisitCreatePrimaryIndex(plan *plan.CreatePrimaryIndex) (interface{}, error) {
-	return NewCreatePrimaryIndex(plan), nil
+	return NewCreatePrimaryIndex(plan, this.context), nil
 }
 
 // CreateIndex
 func (this *builder) VisitCreateIndex(plan *plan.CreateIndex) (interface{}, error) {
-	return NewCreateIndex(plan), nil
+	return NewCreateIndex(plan, this.context), nil
 }
 
 // DropIndex
 func (this *builder) VisitDropIndex(plan *plan.DropIndex) (interface{}, error) {
-	return NewDropIndex(plan), nil
+	return NewDropIndex(plan, this.context), nil
 }
 
 // AlterIndex
 func (this *builder) VisitAlterIndex(plan *plan.AlterIndex) (interface{}, error) {
-	return NewAlterIndex(plan), nil
+	return NewAlterIndex(plan, this.context), nil
 }
 
 // BuildIndexes
 func (this *builder) VisitBuildIndexes(plan *plan.BuildIndexes) (interface{}, error) {
-	return NewBuildIndexes(plan), nil
+	return NewBuildIndexes(plan, this.context), nil
+}
+
+// CreatePolicy
+func (this *builder) VisitCreatePolicy(plan *plan.CreatePolicy) (interface{}, error) {
+	return NewCreatePolicy(plan, this.context), nil
+}
+
+// DropPolicy
+func (this *builder) VisitDropPolicy(plan *plan.DropPolicy) (interface{}, error) {
+	return NewDropPolicy(plan, this.context), nil
+}
+
+// CreateMask
+func (this *builder) VisitCreateMask(plan *plan.CreateMask) (interface{}, error) {
+	return NewCreateMask(plan, this.context), nil
+}
+
+// DropMask
+func (this *builder) VisitDropMask(plan *plan.DropMask) (interface{}, error) {
+	return NewDropMask(plan, this.context), nil
+}
+
+// CreateView
+func (this *builder) VisitCreateView(plan *plan.CreateView) (interface{}, error) {
+	return NewCreateView(plan, this.context), nil
+}
+
+// DropView
+func (this *builder) VisitDropView(plan *plan.DropView) (interface{}, error) {
+	return NewDropView(plan, this.context), nil
+}
+
+// RefreshView
+func (this *builder) VisitRefreshView(plan *plan.RefreshView) (interface{}, error) {
+	return NewRefreshView(plan, this.context), nil
+}
+
+// CreateKeyspace
+func (this *builder) VisitCreateKeyspace(plan *plan.CreateKeyspace) (interface{}, error) {
+	return NewCreateKeyspace(plan, this.context), nil
+}
+
+// DropKeyspace
+func (this *builder) VisitDropKeyspace(plan *plan.DropKeyspace) (interface{}, error) {
+	return NewDropKeyspace(plan, this.context), nil
+}
+
+// GrantRole
+func (this *builder) VisitGrantRole(plan *plan.GrantRole) (interface{}, error) {
+	return NewGrantRole(plan, this.context), nil
+}
+
+// RevokeRole
+func (this *builder) VisitRevokeRole(plan *plan.RevokeRole) (interface{}, error) {
+	return NewRevokeRole(plan, this.context), nil
+}
+
+// SessionSet
+func (this *builder) VisitSessionSet(plan *plan.SessionSet) (interface{}, error) {
+	return NewSessionSet(plan, this.context), nil
+}
+
+// SetVariable
+func (this *builder) VisitSetVariable(plan *plan.SetVariable) (interface{}, error) {
+	return NewSetVariable(plan, this.context), nil
 }
 
 // Prepare
 func (this *builder) VisitPrepare(plan *plan.Prepare) (interface{}, error) {
-	return NewPrepare(plan.Prepared()), nil
+	return NewPrepare(plan.Prepared(), this.context), nil
 }
 
 // Explain
 func (this *builder) VisitExplain(plan *plan.Explain) (interface{}, error) {
-	return NewExplain(plan.Operator()), nil
+	return NewExplain(plan.Operator(), plan.Format(), plan.Features(), plan.Notes(), this.context), nil
+}
+
+// Advise
+func (this *builder) VisitAdvise(plan *plan.Advise) (interface{}, error) {
+	return NewAdvise(plan.Advice(), this.context), nil
+}
+
+// Extensions
+func (this *builder) VisitExtension(plan plan.Extension) (interface{}, error) {
+	build, ok := getBuilder(plan.Name())
+	if !ok {
+		return nil, errors.NewPlanError(nil, "No execution builder for operator "+plan.Name())
+	}
+
+	return build(plan, this.context)
 }
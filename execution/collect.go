@@ -10,24 +10,24 @@
 package execution
 
 import (
-	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/value"
 )
 
 // Collect subquery results
 type Collect struct {
 	base
-	values []interface{}
+	values *value.StreamValue
 }
 
-const _COLLECT_CAP = 64
+// Results beyond this are spilled to disk, so that subqueries with
+// many results do not exhaust memory.
+const _COLLECT_SPILL = 1 << 16
 
-var _COLLECT_POOL = util.NewInterfacePool(_COLLECT_CAP)
-
-func NewCollect() *Collect {
+func NewCollect(context *Context) *Collect {
 	rv := &Collect{
-		base:   newBase(),
-		values: _COLLECT_POOL.Get(),
+		base:   newBase(context),
+		values: value.NewStreamValue(_COLLECT_SPILL),
 	}
 
 	rv.output = rv
@@ -41,7 +41,7 @@ func (this *Collect) Accept(visitor Visitor) (interface{}, error) {
 func (this *Collect) Copy() Operator {
 	return &Collect{
 		base:   this.base.copy(),
-		values: _COLLECT_POOL.Get(),
+		values: value.NewStreamValue(_COLLECT_SPILL),
 	}
 }
 
@@ -50,23 +50,24 @@ func (this *Collect) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *Collect) processItem(item value.AnnotatedValue, context *Context) bool {
-	if len(this.values) == cap(this.values) {
-		values := make([]interface{}, len(this.values), len(this.values)<<1)
-		copy(values, this.values)
-		this.releaseValues()
-		this.values = values
+	err := this.values.Add(item.Actual())
+	if err != nil {
+		context.Error(errors.NewError(err, "Error collecting subquery results."))
+		return false
 	}
 
-	this.values = append(this.values, item.Actual())
 	return true
 }
 
-func (this *Collect) ValuesOnce() value.Value {
-	defer this.releaseValues()
-	return value.NewValue(this.values)
-}
+/*
+The results are a stream value, so that consumers such as UNNEST can
+iterate over them without materializing them.
+*/
+func (this *Collect) ValuesOnce() (value.Value, error) {
+	err := this.values.Close()
+	if err != nil {
+		return nil, err
+	}
 
-func (this *Collect) releaseValues() {
-	_COLLECT_POOL.Put(this.values)
-	this.values = nil
+	return this.values, nil
 }
@@ -11,17 +11,25 @@ package execution
 
 import (
 	"fmt"
+	"math/rand"
 	"os"
 	"runtime"
 	"sync"
+	"sync/atomic"
 	"time"
 
 	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/guard"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/quota"
+	"github.com/couchbase/query/session"
 	"github.com/couchbase/query/timestamp"
 	"github.com/couchbase/query/value"
 )
@@ -34,6 +42,8 @@ type Output interface {
 	Warning(wrn errors.Error)
 	AddMutationCount(uint64)
 	MutationCount() uint64
+	AchieveDurability(datastore.Durability)
+	AchievedDurability() datastore.Durability
 	SetSortCount(uint64)
 	SortCount() uint64
 	AddPhaseTime(phase string, duration time.Duration)
@@ -56,6 +66,28 @@ type Context struct {
 	output         Output
 	subplans       *subqueryMap
 	subresults     *subqueryMap
+	keyspaces      map[string]uint64
+	quotas         *quota.Tracker
+	progress       *Progress
+	session        *session.Session
+	roles          []datastore.Role
+	scanCap        int64
+	pipelineCap    int64
+	pipelineBatch  int
+	mutationBatch  int
+	snapshots      map[datastore.SnapshotKeyspace]bool
+	replicaReads   bool
+	durability     datastore.Durability
+	casRetries     int
+	features       feature.Flags
+	missingOrder   value.MissingOrder
+	strictCast     bool
+	allowFullScan  bool
+	outfileDir     string
+	importDir      string
+	errorCount     int64
+	missingWarned  map[string]bool // Comparisons warned of, if warning
+	random         *rand.Rand      // Generator of seeded requests
 	mutex          sync.RWMutex
 }
 
@@ -112,10 +144,45 @@ func (this *Context) MaxParallelism() int {
 	return this.maxParallelism
 }
 
+/*
+The clock of the statement, which NOW_MILLIS() and NOW_STR() read. It
+is captured once, so that all their evaluations return the same
+instant.
+*/
 func (this *Context) Now() time.Time {
 	return this.now
 }
 
+/*
+Sets the clock of the statement, to the start of its request, so that
+all the statements of the request observe the same instant.
+*/
+func (this *Context) SetNow(now time.Time) {
+	this.now = now
+}
+
+/*
+Seeds the request for deterministic evaluation, so that its results
+are reproducible: the clock is frozen at the Unix epoch, RANDOM() and
+UUID() draw from a generator with the seed, and items are processed
+serially so that they draw in a stable order.
+*/
+func (this *Context) SetSeed(seed int64) {
+	this.now = time.Unix(0, 0).UTC()
+	this.random = rand.New(rand.NewSource(seed))
+	this.maxParallelism = 1
+}
+
+func (this *Context) Seeded() bool {
+	return this.random != nil
+}
+
+func (this *Context) RandomUint64() uint64 {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+	return this.random.Uint64()
+}
+
 func (this *Context) NamedArg(name string) (value.Value, bool) {
 	val, ok := this.namedArgs[name]
 	return val, ok
@@ -144,6 +211,243 @@ func (this *Context) ScanVector() timestamp.Vector {
 	return this.vector
 }
 
+/*
+The scan consistency and vector of the index scans of a keyspace
+term: those of its USE CONSISTENCY clause, or else the request's.
+Errors evaluating the vector are reported, and false returned.
+*/
+func (this *Context) TermScanConsistency(term *algebra.KeyspaceTerm) (
+	datastore.ScanConsistency, timestamp.Vector, bool) {
+	cons := term.Consistency()
+	if cons == nil {
+		return this.consistency, this.vector, true
+	}
+
+	if cons.Vector() == nil {
+		return cons.Level(), nil, true
+	}
+
+	val, err := cons.Vector().Evaluate(nil, this)
+	if err != nil {
+		this.Error(errors.NewEvaluationError(err, "scan vector"))
+		return "", nil, false
+	}
+
+	vector, err := timestamp.ParseVector(val.Actual())
+	if err != nil {
+		this.Error(errors.NewEvaluationError(err, "scan vector"))
+		return "", nil, false
+	}
+
+	return cons.Level(), vector, true
+}
+
+// Per-request overrides of the server scan cap, pipeline cap and
+// pipeline batch size; zero means use the server setting. Overrides
+// may lower the server setting but never raise it.
+
+func (this *Context) ScanCap() int64 {
+	scanCap := datastore.GetScanCap()
+	if this.scanCap > 0 && (scanCap <= 0 || this.scanCap < scanCap) {
+		return this.scanCap
+	}
+
+	return scanCap
+}
+
+func (this *Context) SetScanCap(scanCap int64) {
+	this.scanCap = scanCap
+}
+
+func (this *Context) PipelineCap() int64 {
+	pipelineCap := GetPipelineCap()
+	if this.pipelineCap > 0 && this.pipelineCap < pipelineCap {
+		return this.pipelineCap
+	}
+
+	return pipelineCap
+}
+
+func (this *Context) SetPipelineCap(pipelineCap int64) {
+	this.pipelineCap = pipelineCap
+}
+
+func (this *Context) PipelineBatch() int {
+	pipelineBatch := PipelineBatchSize()
+	if this.pipelineBatch > 0 && this.pipelineBatch < pipelineBatch {
+		return this.pipelineBatch
+	}
+
+	return pipelineBatch
+}
+
+func (this *Context) SetPipelineBatch(pipelineBatch int) {
+	this.pipelineBatch = pipelineBatch
+}
+
+func (this *Context) MutationBatch() int {
+	mutationBatch := MutationBatchSize()
+	if this.mutationBatch > 0 && this.mutationBatch < mutationBatch {
+		return this.mutationBatch
+	}
+
+	return mutationBatch
+}
+
+func (this *Context) SetMutationBatch(mutationBatch int) {
+	this.mutationBatch = mutationBatch
+}
+
+// Whether reads may be routed to replicas, under the replica policy.
+// Only read-only statements at unbounded consistency are routed, as
+// replicas may lag behind.
+
+func (this *Context) ReplicaReads() bool {
+	return this.replicaReads
+}
+
+func (this *Context) SetReplicaReads(replicaReads bool) {
+	this.replicaReads = replicaReads
+}
+
+// How durable the mutations of the request are to be made; empty if
+// the request does not ask.
+
+func (this *Context) Durability() datastore.Durability {
+	return this.durability
+}
+
+func (this *Context) SetDurability(durability datastore.Durability) {
+	this.durability = durability
+}
+
+// How many times UPDATE re-applies its SET and UNSET to documents
+// modified concurrently, in keyspaces that report them.
+func (this *Context) CasRetries() int {
+	return this.casRetries
+}
+
+func (this *Context) SetCasRetries(retries int) {
+	this.casRetries = retries
+}
+
+// Per-request overrides of the server feature flags.
+
+func (this *Context) Features() feature.Flags {
+	return this.features
+}
+
+func (this *Context) SetFeatures(features feature.Flags) {
+	this.features = features
+}
+
+// Whether an invalid CAST is an error rather than NULL.
+func (this *Context) StrictCast() bool {
+	return this.strictCast
+}
+
+func (this *Context) SetStrictCast(strict bool) {
+	this.strictCast = strict
+}
+
+// Whether subqueries may scan keyspaces above the full scan threshold.
+func (this *Context) AllowFullScan() bool {
+	return this.allowFullScan
+}
+
+func (this *Context) SetAllowFullScan(allow bool) {
+	this.allowFullScan = allow
+}
+
+// The directory of INTO OUTFILE files; if empty, OUTFILE is disabled.
+func (this *Context) OutfileDir() string {
+	return this.outfileDir
+}
+
+func (this *Context) SetOutfileDir(dir string) {
+	this.outfileDir = dir
+}
+
+// The directory of IMPORT files; if empty, IMPORT is disabled.
+func (this *Context) ImportDir() string {
+	return this.importDir
+}
+
+func (this *Context) SetImportDir(dir string) {
+	this.importDir = dir
+}
+
+func (this *Context) MissingOrder() value.MissingOrder {
+	return this.missingOrder
+}
+
+func (this *Context) SetMissingOrder(order value.MissingOrder) {
+	this.missingOrder = order
+}
+
+/*
+Warn of comparisons involving MISSING, once per comparison of the
+statement.
+*/
+func (this *Context) SetMissingWarnings(warnings bool) {
+	if warnings {
+		this.missingWarned = make(map[string]bool)
+	} else {
+		this.missingWarned = nil
+	}
+}
+
+func (this *Context) WarnMissing(expr expression.Expression) {
+	if this.missingWarned == nil {
+		return
+	}
+
+	s := expr.String()
+	this.mutex.Lock()
+	warned := this.missingWarned[s]
+	this.missingWarned[s] = true
+	this.mutex.Unlock()
+
+	if !warned {
+		this.Warning(errors.NewMissingComparisonWarning(s))
+	}
+}
+
+func (this *Context) QuotaTracker() *quota.Tracker {
+	return this.quotas
+}
+
+func (this *Context) SetQuotaTracker(quotas *quota.Tracker) {
+	this.quotas = quotas
+}
+
+// The progress of the mutations of the request, or nil.
+func (this *Context) Progress() *Progress {
+	return this.progress
+}
+
+func (this *Context) SetProgress(progress *Progress) {
+	this.progress = progress
+}
+
+// Roles granted to the request by its authenticator.
+func (this *Context) Roles() []datastore.Role {
+	return this.roles
+}
+
+func (this *Context) SetRoles(roles []datastore.Role) {
+	this.roles = roles
+}
+
+// The session of the request, or nil.
+func (this *Context) Session() *session.Session {
+	return this.session
+}
+
+func (this *Context) SetSession(session *session.Session) {
+	this.session = session
+}
+
 func (this *Context) AddMutationCount(i uint64) {
 	this.output.AddMutationCount(i)
 }
@@ -156,6 +460,26 @@ func (this *Context) SetSortCount(i uint64) {
 	this.output.SetSortCount(i)
 }
 
+/*
+The keyspace that a DML statement mutates, made durable as the request
+asks. The durability achieved is reported to the output: none for
+keyspaces that cannot make their mutations durable.
+*/
+func (this *Context) durableKeyspace(keyspace datastore.Keyspace) datastore.Keyspace {
+	if this.durability == "" {
+		return keyspace
+	}
+
+	achieved := datastore.DURABILITY_NONE
+	if durable, ok := keyspace.(datastore.DurableKeyspace); ok {
+		keyspace = durable.Durable(this.durability)
+		achieved = this.durability
+	}
+
+	this.output.AchieveDurability(achieved)
+	return keyspace
+}
+
 func (this *Context) SortCount() uint64 {
 	return this.output.SortCount()
 }
@@ -165,6 +489,20 @@ func (this *Context) AddPhaseTime(phase string, duration time.Duration) {
 }
 
 func (this *Context) Result(item value.Value) bool {
+	if this.quotas.TracksResultSize() {
+		bytes, err := item.MarshalJSON()
+		if err != nil {
+			this.Error(errors.NewError(err, "Error marshaling result."))
+			return false
+		}
+
+		er := this.quotas.AddResultSize(int64(len(bytes)))
+		if er != nil {
+			this.Error(er)
+			return false
+		}
+	}
+
 	return this.output.Result(item)
 }
 
@@ -173,17 +511,194 @@ func (this *Context) CloseResults() {
 }
 
 func (this *Context) Error(err errors.Error) {
+	atomic.AddInt64(&this.errorCount, 1)
 	this.output.Error(err)
 }
 
 func (this *Context) Fatal(err errors.Error) {
+	atomic.AddInt64(&this.errorCount, 1)
 	this.output.Fatal(err)
 }
 
+// The number of errors of the request so far.
+func (this *Context) ErrorCount() int64 {
+	return atomic.LoadInt64(&this.errorCount)
+}
+
 func (this *Context) Warning(wrn errors.Error) {
 	this.output.Warning(wrn)
 }
 
+// Record a keyspace read by this request, along with its mutation
+// count when it was first read.
+func (this *Context) addKeyspace(namespace, keyspace string) {
+	key := keyspaceKey(namespace, keyspace)
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.keyspaces == nil {
+		this.keyspaces = make(map[string]uint64, 4)
+	}
+
+	if _, ok := this.keyspaces[key]; !ok {
+		this.keyspaces[key] = _KEYSPACE_MUTATIONS.get(key)
+		this.quotas.AddKeyspace(key)
+	}
+}
+
+// Keyspaces read by this request, mapped to their mutation counts
+// when first read.
+func (this *Context) Keyspaces() map[string]uint64 {
+	this.mutex.RLock()
+	defer this.mutex.RUnlock()
+
+	rv := make(map[string]uint64, len(this.keyspaces))
+	for k, v := range this.keyspaces {
+		rv[k] = v
+	}
+
+	return rv
+}
+
+// Charge documents read against the request quotas: those fetched,
+// and those whose index entries stand in for them in covering and
+// count scans.
+func (this *Context) chargeDocuments(namespace, keyspace string, count int) bool {
+	if this.quotas == nil {
+		return true
+	}
+
+	err := this.quotas.AddDocuments(keyspaceKey(namespace, keyspace), int64(count))
+	if err != nil {
+		this.Error(err)
+		return false
+	}
+
+	return true
+}
+
+// Charge mutations against the request quotas before they are applied.
+func (this *Context) chargeMutations(keyspace datastore.Keyspace, count int) bool {
+	err := this.quotas.AddMutations(keyspaceKey(keyspace.NamespaceId(), keyspace.Name()), int64(count))
+	if err != nil {
+		this.Error(err)
+		return false
+	}
+
+	return true
+}
+
+// Open a snapshot of the keyspace for SCAN_PLUS and AT_PLUS requests,
+// so that they observe a consistent view of it. Snapshots are opened
+// once per request, and kept until ReleaseSnapshots().
+func (this *Context) openSnapshot(keyspace datastore.Keyspace) errors.Error {
+	if this.consistency != datastore.SCAN_PLUS && this.consistency != datastore.AT_PLUS {
+		return nil
+	}
+
+	snapshotKeyspace, ok := keyspace.(datastore.SnapshotKeyspace)
+	if !ok {
+		return nil
+	}
+
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.snapshots[snapshotKeyspace] {
+		return nil
+	}
+
+	err := snapshotKeyspace.OpenSnapshot(this.requestId)
+	if err != nil {
+		return err
+	}
+
+	if this.snapshots == nil {
+		this.snapshots = make(map[datastore.SnapshotKeyspace]bool, 4)
+	}
+
+	this.snapshots[snapshotKeyspace] = true
+	return nil
+}
+
+// Fetch from the snapshot of the keyspace, if the request has one,
+// or else from the replica that the replica policy routes to.
+func (this *Context) fetch(keyspace datastore.Keyspace, keys []string) (
+	[]datastore.AnnotatedPair, []errors.Error) {
+	snapshotKeyspace, ok := keyspace.(datastore.SnapshotKeyspace)
+	if ok {
+		this.mutex.RLock()
+		ok = this.snapshots[snapshotKeyspace]
+		this.mutex.RUnlock()
+	}
+
+	if ok {
+		return snapshotKeyspace.FetchSnapshot(this.requestId, keys)
+	}
+
+	if this.replicaReads && this.consistency == datastore.UNBOUNDED {
+		keyspace = datastore.ReadKeyspace(keyspace)
+	}
+
+	return keyspace.Fetch(keys)
+}
+
+// The index that a scan at the given consistency reads: the index of
+// the same name on a replica, if the replica policy routes there.
+func (this *Context) scanIndex(term *algebra.KeyspaceTerm, index datastore.Index,
+	consistency datastore.ScanConsistency) datastore.Index {
+	if !this.replicaReads || consistency != datastore.UNBOUNDED ||
+		datastore.GetReplicaPolicy() == datastore.PRIMARY_ONLY {
+		return index
+	}
+
+	namespace, err := this.datastore.NamespaceByName(term.Namespace())
+	if err != nil {
+		return index
+	}
+
+	keyspace, err := namespace.KeyspaceByName(term.Keyspace())
+	if err != nil {
+		return index
+	}
+
+	return datastore.ReadIndex(keyspace, index)
+}
+
+// Release the snapshots opened by the request, once it has completed.
+func (this *Context) ReleaseSnapshots() {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	for snapshotKeyspace, _ := range this.snapshots {
+		snapshotKeyspace.ReleaseSnapshot(this.requestId)
+	}
+
+	this.snapshots = nil
+}
+
+// Check the plan of a subquery against the statement rules and the
+// full scan threshold.
+func (this *Context) guard(op plan.Operator) errors.Error {
+	if guard.Count() > 0 {
+		users := make([]string, 0, len(this.credentials))
+		for user, _ := range this.credentials {
+			users = append(users, user)
+		}
+
+		if err := guard.Check(users, op); err != nil {
+			return err
+		}
+	}
+
+	if !this.allowFullScan {
+		return guard.CheckFullScans(op)
+	}
+
+	return nil
+}
+
 func (this *Context) EvaluateSubquery(query *algebra.Select, parent value.Value) (value.Value, error) {
 	subresults := this.getSubresults()
 	subresult, ok := subresults.get(query)
@@ -196,11 +711,19 @@ func (this *Context) EvaluateSubquery(query *algebra.Select, parent value.Value)
 
 	if !planFound {
 		var err error
-		subplan, err = planner.Build(query, this.datastore, this.systemstore, this.namespace, true)
+		subplan, err = planner.BuildFeatures(query, this.datastore, this.systemstore,
+			this.namespace, true, policy.Applies(this.datastore, this.roles, this.credentials), this.features)
 		if err != nil {
 			return nil, err
 		}
 
+		// Subqueries are planned as they are evaluated, so the rules
+		// and the full scan threshold are checked here rather than
+		// before execution
+		if err := this.guard(subplan.(plan.Operator)); err != nil {
+			return nil, err
+		}
+
 		// Cache plan
 		subplans.set(query, subplan)
 	}
@@ -211,8 +734,8 @@ func (this *Context) EvaluateSubquery(query *algebra.Select, parent value.Value)
 	}
 
 	// Collect subquery results
-	collect := NewCollect()
-	sequence := NewSequence(pipeline, collect)
+	collect := NewCollect(this)
+	sequence := NewSequence(this, pipeline, collect)
 	sequence.RunOnce(this, parent)
 
 	// Await completion
@@ -221,7 +744,10 @@ func (this *Context) EvaluateSubquery(query *algebra.Select, parent value.Value)
 		_, ok = <-collect.Output().ItemChannel()
 	}
 
-	results := collect.ValuesOnce()
+	results, err := collect.ValuesOnce()
+	if err != nil {
+		return nil, err
+	}
 
 	// Cache results
 	if !planFound && !query.Subresult().IsCorrelated() {
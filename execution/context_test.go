@@ -0,0 +1,48 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+)
+
+func TestContextCaps(t *testing.T) {
+	context := NewContext("test", nil, nil, "default", false, 1, nil, nil, nil,
+		datastore.UNBOUNDED, nil, &viewOutput{})
+
+	context.SetPipelineCap(2000000000)
+	if cap := context.PipelineCap(); cap != GetPipelineCap() {
+		t.Errorf("Expected the pipeline cap to be bounded by %d, got %d", GetPipelineCap(), cap)
+	}
+
+	context.SetPipelineCap(8)
+	if cap := context.PipelineCap(); cap != 8 {
+		t.Errorf("Expected a pipeline cap of 8, got %d", cap)
+	}
+
+	context.SetPipelineBatch(2000000000)
+	if batch := context.PipelineBatch(); batch != PipelineBatchSize() {
+		t.Errorf("Expected the pipeline batch to be bounded by %d, got %d", PipelineBatchSize(), batch)
+	}
+
+	context.SetMutationBatch(2000000000)
+	if batch := context.MutationBatch(); batch != MutationBatchSize() {
+		t.Errorf("Expected the mutation batch to be bounded by %d, got %d", MutationBatchSize(), batch)
+	}
+
+	datastore.SetScanCap(16)
+	defer datastore.SetScanCap(0)
+	context.SetScanCap(2000000000)
+	if cap := context.ScanCap(); cap != 16 {
+		t.Errorf("Expected the scan cap to be bounded by 16, got %d", cap)
+	}
+}
@@ -24,9 +24,9 @@ type SendDelete struct {
 	limit int64
 }
 
-func NewSendDelete(plan *plan.SendDelete) *SendDelete {
+func NewSendDelete(plan *plan.SendDelete, context *Context) *SendDelete {
 	rv := &SendDelete{
-		base:  newBase(),
+		base:  newBase(context),
 		plan:  plan,
 		limit: -1,
 	}
@@ -90,7 +90,7 @@ func (this *SendDelete) flushBatch(context *Context) bool {
 		return true
 	}
 
-	keys := _STRING_POOL.Get()
+	keys := _STRING_POOL.Get(len(this.batch))
 	defer _STRING_POOL.Put(keys)
 
 	for _, item := range this.batch {
@@ -114,14 +114,20 @@ func (this *SendDelete) flushBatch(context *Context) bool {
 		keys = append(keys, key)
 	}
 
+	if !context.chargeMutations(this.plan.Keyspace(), len(keys)) {
+		return false
+	}
+
 	timer := time.Now()
 
-	deleted_keys, e := this.plan.Keyspace().Delete(keys)
+	deleted_keys, e := context.durableKeyspace(this.plan.Keyspace()).Delete(keys)
 
 	context.AddPhaseTime("delete", time.Since(timer))
 
 	// Update mutation count with number of deleted docs:
 	context.AddMutationCount(uint64(len(deleted_keys)))
+	addKeyspaceMutations(this.plan.Keyspace(), uint64(len(deleted_keys)))
+	context.progress.Add(len(keys), len(deleted_keys))
 
 	if e != nil {
 		context.Error(e)
@@ -17,9 +17,9 @@ type Discard struct {
 	base
 }
 
-func NewDiscard() *Discard {
+func NewDiscard(context *Context) *Discard {
 	rv := &Discard{
-		base: newBase(),
+		base: newBase(context),
 	}
 
 	rv.output = rv
@@ -10,25 +10,42 @@
 package execution
 
 import (
+	"bufio"
+	"encoding/json"
+	"hash/fnv"
+	"io/ioutil"
+	"os"
 	"time"
 
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/value"
 )
 
-// Distincting of input data.
+/*
+Distincting of input data. Unless collecting, e.g. for INTERSECT and
+EXCEPT, items are streamed: each is sent as soon as its projection is
+first seen. Beyond _DISTINCT_LIMIT distinct projections, unseen items
+are spilled to temporary files, partitioned by hash, and each
+partition is distincted and sent after the input is exhausted.
+*/
 type Distinct struct {
 	base
 	set     *value.Set
 	collect bool
+	limit   int
+	spill   *distinctSpill
 }
 
 const _DISTINCT_CAP = 1024
+const _DISTINCT_LIMIT = 1 << 16
+const _DISTINCT_PARTITIONS = 16
 
-func NewDistinct(collect bool) *Distinct {
+func NewDistinct(collect bool, context *Context) *Distinct {
 	rv := &Distinct{
-		base:    newBase(),
+		base:    newBase(context),
 		set:     value.NewSet(_DISTINCT_CAP),
 		collect: collect,
+		limit:   _DISTINCT_LIMIT,
 	}
 
 	rv.output = rv
@@ -41,8 +58,10 @@ func (this *Distinct) Accept(visitor Visitor) (interface{}, error) {
 
 func (this *Distinct) Copy() Operator {
 	return &Distinct{
-		base: this.base.copy(),
-		set:  value.NewSet(_DISTINCT_CAP),
+		base:    this.base.copy(),
+		set:     value.NewSet(_DISTINCT_CAP),
+		collect: this.collect,
+		limit:   this.limit,
 	}
 }
 
@@ -51,12 +70,32 @@ func (this *Distinct) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *Distinct) processItem(item value.AnnotatedValue, context *Context) bool {
-	p := item.GetAttachment("projection")
-	if p == nil {
-		p = item
+	p := projection(item)
+
+	if this.collect {
+		this.set.Put(p, item)
+		return true
+	}
+
+	if this.set.Has(p) {
+		return true
+	}
+
+	if this.set.Len() < this.limit || !spillable(p) {
+		this.set.Put(p, item)
+		return this.sendItem(item)
+	}
+
+	if this.spill == nil {
+		this.spill = &distinctSpill{}
+	}
+
+	err := this.spill.write(p, item)
+	if err != nil {
+		context.Error(errors.NewError(err, "Error spilling DISTINCT values."))
+		return false
 	}
 
-	this.set.Put(p.(value.Value), item)
 	return true
 }
 
@@ -65,21 +104,147 @@ func (this *Distinct) afterItems(context *Context) {
 		return
 	}
 
+	this.set = nil
+
+	if this.spill == nil {
+		return
+	}
+
+	defer this.spill.release()
+
 	timer := time.Now()
+	defer func() { context.AddPhaseTime("distinct", time.Since(timer)) }()
 
-	values := this.set.Values()
+	err := this.spill.read(this.sendItem)
+	if err != nil {
+		context.Error(errors.NewError(err, "Error reading spilled DISTINCT values."))
+	}
+}
 
-	context.AddPhaseTime("distinct", time.Since(timer))
+func (this *Distinct) Set() *value.Set {
+	return this.set
+}
 
-	for _, av := range values {
-		if !this.sendItem(value.NewAnnotatedValue(av)) {
-			return
+func projection(item value.AnnotatedValue) value.Value {
+	p := item.GetAttachment("projection")
+	if p == nil {
+		return item
+	}
+
+	return p.(value.Value)
+}
+
+// Only values that survive a round trip through JSON are spilled;
+// the others have few distinct values, and are kept in memory.
+func spillable(p value.Value) bool {
+	switch p.Type() {
+	case value.NUMBER, value.STRING, value.ARRAY, value.OBJECT:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+distinctSpill holds the spilled items, one per line: the projection,
+and the fields of the item if the item is not the projection itself.
+Spilled items keep no attachment other than their projection.
+*/
+type distinctSpill struct {
+	files   [_DISTINCT_PARTITIONS]*os.File
+	writers [_DISTINCT_PARTITIONS]*bufio.Writer
+}
+
+func (this *distinctSpill) write(p value.Value, item value.AnnotatedValue) error {
+	pb, err := p.MarshalJSON()
+	if err != nil {
+		return err
+	}
+
+	record := []interface{}{json.RawMessage(pb)}
+	if item.GetAttachment("projection") != nil {
+		record = append(record, item.Fields())
+	}
+
+	data, err := json.Marshal(record)
+	if err != nil {
+		return err
+	}
+
+	h := fnv.New32a()
+	h.Write(pb)
+	i := h.Sum32() % _DISTINCT_PARTITIONS
+
+	if this.files[i] == nil {
+		file, err := ioutil.TempFile("", "query-distinct")
+		if err != nil {
+			return err
 		}
+
+		this.files[i] = file
+		this.writers[i] = bufio.NewWriter(file)
 	}
 
-	this.set = nil
+	_, err = this.writers[i].Write(append(data, '\n'))
+	return err
 }
 
-func (this *Distinct) Set() *value.Set {
-	return this.set
+// Distinct each partition in turn, and send its items.
+func (this *distinctSpill) read(send func(value.AnnotatedValue) bool) error {
+	for i, file := range this.files {
+		if file == nil {
+			continue
+		}
+
+		err := this.writers[i].Flush()
+		if err != nil {
+			return err
+		}
+
+		_, err = file.Seek(0, 0)
+		if err != nil {
+			return err
+		}
+
+		set := value.NewSet(_DISTINCT_CAP)
+		scanner := bufio.NewScanner(file)
+		scanner.Buffer(make([]byte, 64*1024), 1<<30)
+		for scanner.Scan() {
+			record := value.NewValue(append([]byte(nil), scanner.Bytes()...))
+			p, _ := record.Index(0)
+			if set.Has(p) {
+				continue
+			}
+
+			set.Add(p)
+
+			var item value.AnnotatedValue
+			if fields, ok := record.Index(1); ok {
+				item = value.NewAnnotatedValue(value.NewScopeValue(fields.Actual(), nil))
+				item.SetAttachment("projection", p)
+			} else {
+				item = value.NewAnnotatedValue(p)
+			}
+
+			if !send(item) {
+				return nil
+			}
+		}
+
+		err = scanner.Err()
+		if err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (this *distinctSpill) release() {
+	for _, file := range this.files {
+		if file != nil {
+			file.Close()
+			os.Remove(file.Name())
+		}
+	}
 }
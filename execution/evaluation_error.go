@@ -0,0 +1,93 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+// How much context the errors of evaluating expressions carry.
+type ErrorVerbosity string
+
+const (
+	ERRORS_TERSE      ErrorVerbosity = "terse"      // The kind of term only
+	ERRORS_EXPRESSION ErrorVerbosity = "expression" // Also the text of the expression
+	ERRORS_DOCUMENT   ErrorVerbosity = "document"   // Also the key of the document
+)
+
+var _ERROR_VERBOSITY struct {
+	sync.RWMutex
+	verbosity ErrorVerbosity
+}
+
+func ParseErrorVerbosity(s string) (ErrorVerbosity, bool) {
+	switch verbosity := ErrorVerbosity(s); verbosity {
+	case ERRORS_TERSE, ERRORS_EXPRESSION, ERRORS_DOCUMENT:
+		return verbosity, true
+	default:
+		return "", false
+	}
+}
+
+func SetErrorVerbosity(verbosity ErrorVerbosity) {
+	_ERROR_VERBOSITY.Lock()
+	defer _ERROR_VERBOSITY.Unlock()
+	_ERROR_VERBOSITY.verbosity = verbosity
+}
+
+func GetErrorVerbosity() ErrorVerbosity {
+	_ERROR_VERBOSITY.RLock()
+	defer _ERROR_VERBOSITY.RUnlock()
+	if _ERROR_VERBOSITY.verbosity == "" {
+		return ERRORS_EXPRESSION
+	}
+
+	return _ERROR_VERBOSITY.verbosity
+}
+
+/*
+The error of evaluating an expression of the given kind of term on
+an item. Depending on the error verbosity, it carries the text of
+the expression and the key of the document of the item.
+*/
+func evaluationError(e error, termType string, expr expression.Expression,
+	item value.Value) errors.Error {
+	verbosity := GetErrorVerbosity()
+	if verbosity == ERRORS_TERSE || expr == nil {
+		return errors.NewEvaluationError(e, termType)
+	}
+
+	key := ""
+	if verbosity == ERRORS_DOCUMENT {
+		key = documentKey(item)
+	}
+
+	return errors.NewEvaluationContextError(e, termType, expr.String(), key)
+}
+
+// The key of the document of an item, if it has one.
+func documentKey(item value.Value) string {
+	av, ok := item.(value.AnnotatedValue)
+	if !ok {
+		return ""
+	}
+
+	meta, ok := av.GetAttachment("meta").(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	key, _ := meta["id"].(string)
+	return key
+}
@@ -21,9 +21,9 @@ type ExceptAll struct {
 	set          *value.Set
 }
 
-func NewExceptAll(first, second Operator) *ExceptAll {
+func NewExceptAll(first, second Operator, context *Context) *ExceptAll {
 	rv := &ExceptAll{
-		base:         newBase(),
+		base:         newBase(context),
 		first:        first,
 		second:       second,
 		childChannel: make(StopChannel, 2),
@@ -53,8 +53,8 @@ func (this *ExceptAll) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *ExceptAll) beforeItems(context *Context, parent value.Value) bool {
-	distinct := NewDistinct(true)
-	sequence := NewSequence(this.second, distinct)
+	distinct := NewDistinct(true, context)
+	sequence := NewSequence(context, this.second, distinct)
 	sequence.SetParent(this)
 	go sequence.RunOnce(context, parent)
 
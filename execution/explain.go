@@ -12,6 +12,7 @@ package execution
 import (
 	"encoding/json"
 
+	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
@@ -19,13 +20,20 @@ import (
 
 type Explain struct {
 	base
-	plan plan.Operator
+	plan     plan.Operator
+	format   string
+	features map[string]bool
+	notes    []string
 }
 
-func NewExplain(plan plan.Operator) *Explain {
+func NewExplain(plan plan.Operator, format string, features map[string]bool, notes []string,
+	context *Context) *Explain {
 	rv := &Explain{
-		base: newBase(),
-		plan: plan,
+		base:     newBase(context),
+		plan:     plan,
+		format:   format,
+		features: features,
+		notes:    notes,
 	}
 
 	rv.output = rv
@@ -37,7 +45,7 @@ func (this *Explain) Accept(visitor Visitor) (interface{}, error) {
 }
 
 func (this *Explain) Copy() Operator {
-	return &Explain{this.base.copy(), this.plan}
+	return &Explain{this.base.copy(), this.plan, this.format, this.features, this.notes}
 }
 
 func (this *Explain) RunOnce(context *Context, parent value.Value) {
@@ -46,14 +54,48 @@ func (this *Explain) RunOnce(context *Context, parent value.Value) {
 		defer close(this.itemChannel) // Broadcast that I have stopped
 		defer this.notify()           // Notify that I have stopped
 
-		bytes, err := json.Marshal(this.plan)
-		if err != nil {
-			context.Fatal(errors.NewError(err, "Failed to marshal JSON."))
-			return
-		}
+		switch this.format {
+		case algebra.EXPLAIN_DOT, algebra.EXPLAIN_FLAME:
+			graph, err := plan.NewGraph(this.plan)
+			if err != nil {
+				context.Fatal(errors.NewError(err, "Failed to graph plan."))
+				return
+			}
+
+			if this.format == algebra.EXPLAIN_DOT {
+				this.sendItem(value.NewAnnotatedValue(graph.DOT()))
+			} else {
+				this.sendItem(value.NewAnnotatedValue(graph.Flame()))
+			}
+		default:
+			bytes, err := json.Marshal(this.plan)
+			if err != nil {
+				context.Fatal(errors.NewError(err, "Failed to marshal JSON."))
+				return
+			}
+
+			// The feature flags the plan was built with
+			value := value.NewAnnotatedValue(bytes)
+			if len(this.features) > 0 {
+				features := make(map[string]interface{}, len(this.features))
+				for name, enabled := range this.features {
+					features[name] = enabled
+				}
 
-		value := value.NewAnnotatedValue(bytes)
-		this.sendItem(value)
+				value.SetField("~features", features)
+			}
 
+			// The plan choices limited by keyspace capabilities
+			if len(this.notes) > 0 {
+				notes := make([]interface{}, len(this.notes))
+				for i, note := range this.notes {
+					notes[i] = note
+				}
+
+				value.SetField("~notes", notes)
+			}
+
+			this.sendItem(value)
+		}
 	})
 }
@@ -0,0 +1,123 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// OperatorBuilder builds the execution operator of an extension's plan operator.
+type OperatorBuilder func(plan plan.Extension, context *Context) (Operator, error)
+
+var _BUILDERS = struct {
+	sync.RWMutex
+	builders map[string]OperatorBuilder
+}{
+	builders: make(map[string]OperatorBuilder),
+}
+
+/*
+RegisterBuilder adds the execution builder of the plan operators
+registered with plan.RegisterOperator under the same name, so that
+plans holding them can be executed.
+*/
+func RegisterBuilder(name string, builder OperatorBuilder) error {
+	if name == "" || builder == nil {
+		return fmt.Errorf("Operator name and builder required")
+	}
+
+	_BUILDERS.Lock()
+	defer _BUILDERS.Unlock()
+
+	if _, ok := _BUILDERS.builders[name]; ok {
+		return fmt.Errorf("Builder of operator %s already registered", name)
+	}
+
+	_BUILDERS.builders[name] = builder
+	return nil
+}
+
+func UnregisterBuilder(name string) {
+	_BUILDERS.Lock()
+	defer _BUILDERS.Unlock()
+	delete(_BUILDERS.builders, name)
+}
+
+func getBuilder(name string) (OperatorBuilder, bool) {
+	_BUILDERS.RLock()
+	defer _BUILDERS.RUnlock()
+	rv, ok := _BUILDERS.builders[name]
+	return rv, ok
+}
+
+/*
+ItemProcessor returns the items to send for an item of the input of
+an operator of an extension.
+*/
+type ItemProcessor func(item value.AnnotatedValue, context *Context) (value.AnnotatedValues, errors.Error)
+
+/*
+Extension is an operator that runs the processor of an extension on
+each item of its input, so that extensions need not implement the
+channels and notifications of operators themselves.
+*/
+type Extension struct {
+	base
+	plan      plan.Extension
+	processor ItemProcessor
+}
+
+func NewExtension(plan plan.Extension, processor ItemProcessor, context *Context) *Extension {
+	rv := &Extension{
+		base:      newBase(context),
+		plan:      plan,
+		processor: processor,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *Extension) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitExtension(this)
+}
+
+func (this *Extension) Copy() Operator {
+	return &Extension{this.base.copy(), this.plan, this.processor}
+}
+
+func (this *Extension) Plan() plan.Extension {
+	return this.plan
+}
+
+func (this *Extension) RunOnce(context *Context, parent value.Value) {
+	this.runConsumer(this, context, parent)
+}
+
+func (this *Extension) processItem(item value.AnnotatedValue, context *Context) bool {
+	items, err := this.processor(item, context)
+	if err != nil {
+		context.Error(err)
+		return false
+	}
+
+	for _, item := range items {
+		if !this.sendItem(item) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -20,12 +20,13 @@ import (
 
 type Fetch struct {
 	base
-	plan *plan.Fetch
+	plan    *plan.Fetch
+	corrupt int
 }
 
-func NewFetch(plan *plan.Fetch) *Fetch {
+func NewFetch(plan *plan.Fetch, context *Context) *Fetch {
 	rv := &Fetch{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -38,10 +39,20 @@ func (this *Fetch) Accept(visitor Visitor) (interface{}, error) {
 }
 
 func (this *Fetch) Copy() Operator {
-	return &Fetch{this.base.copy(), this.plan}
+	return &Fetch{
+		base: this.base.copy(),
+		plan: this.plan,
+	}
 }
 
 func (this *Fetch) RunOnce(context *Context, parent value.Value) {
+	context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
+
+	err := context.openSnapshot(this.plan.Keyspace())
+	if err != nil {
+		context.Error(err)
+	}
+
 	this.runConsumer(this, context, parent)
 }
 
@@ -52,6 +63,10 @@ func (this *Fetch) processItem(item value.AnnotatedValue, context *Context) bool
 func (this *Fetch) afterItems(context *Context) {
 	this.flushBatch(context)
 	context.SetSortCount(0)
+
+	if this.corrupt > 0 {
+		context.Warning(errors.NewCorruptDocumentsWarning(this.corrupt, this.plan.Keyspace().Name()))
+	}
 }
 
 func (this *Fetch) flushBatch(context *Context) bool {
@@ -61,7 +76,7 @@ func (this *Fetch) flushBatch(context *Context) bool {
 		return true
 	}
 
-	keys := _STRING_POOL.Get()
+	keys := _STRING_POOL.Get(len(this.batch))
 	defer _STRING_POOL.Put(keys)
 
 	for _, av := range this.batch {
@@ -87,15 +102,26 @@ func (this *Fetch) flushBatch(context *Context) bool {
 		}
 	}
 
+	keyspace := this.plan.Keyspace()
+	if !context.chargeDocuments(keyspace.NamespaceId(), keyspace.Name(), len(keys)) {
+		return false
+	}
+
 	timer := time.Now()
 
 	// Fetch
-	pairs, errs := this.plan.Keyspace().Fetch(keys)
+	pairs, errs := context.fetch(this.plan.Keyspace(), keys)
 
 	context.AddPhaseTime("fetch", time.Since(timer))
 
 	fetchOk := true
 	for _, err := range errs {
+		// Corrupt documents are skipped, and counted in a single warning
+		if err.Code() == errors.FILE_CORRUPT_DOCUMENT {
+			this.corrupt++
+			continue
+		}
+
 		context.Error(err)
 		if err.IsFatal() {
 			fetchOk = false
@@ -118,7 +144,7 @@ func (this *Fetch) flushBatch(context *Context) bool {
 		if projection != nil {
 			projectedItem, e := projection.Evaluate(pv, context)
 			if e != nil {
-				context.Error(errors.NewEvaluationError(e, "fetch path"))
+				context.Error(evaluationError(e, "fetch path", projection, pv))
 				return false
 			}
 
@@ -10,7 +10,6 @@
 package execution
 
 import (
-	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -20,9 +19,9 @@ type Filter struct {
 	plan *plan.Filter
 }
 
-func NewFilter(plan *plan.Filter) *Filter {
+func NewFilter(plan *plan.Filter, context *Context) *Filter {
 	rv := &Filter{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -45,7 +44,7 @@ func (this *Filter) RunOnce(context *Context, parent value.Value) {
 func (this *Filter) processItem(item value.AnnotatedValue, context *Context) bool {
 	val, e := this.plan.Condition().Evaluate(item, context)
 	if e != nil {
-		context.Error(errors.NewEvaluationError(e, "filter"))
+		context.Error(evaluationError(e, "filter", this.plan.Condition(), item))
 		return false
 	}
 
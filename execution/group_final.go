@@ -12,6 +12,7 @@ package execution
 import (
 	"fmt"
 
+	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
@@ -21,11 +22,12 @@ type FinalGroup struct {
 	base
 	plan   *plan.FinalGroup
 	groups map[string]value.AnnotatedValue
+	limit  int
 }
 
-func NewFinalGroup(plan *plan.FinalGroup) *FinalGroup {
+func NewFinalGroup(plan *plan.FinalGroup, context *Context) *FinalGroup {
 	rv := &FinalGroup{
-		base:   newBase(),
+		base:   newBase(context),
 		plan:   plan,
 		groups: make(map[string]value.AnnotatedValue),
 	}
@@ -50,6 +52,21 @@ func (this *FinalGroup) RunOnce(context *Context, parent value.Value) {
 	this.runConsumer(this, context, parent)
 }
 
+func (this *FinalGroup) beforeItems(context *Context, parent value.Value) bool {
+	var ok bool
+	this.limit, ok = groupLimit(this.plan.Limit(), parent, context)
+	if !ok {
+		context.Error(errors.NewInvalidValueError(
+			fmt.Sprintf("Invalid EACH LIMIT value %v.", this.plan.Limit())))
+
+		// Do not send the default values either
+		this.groups = nil
+		return false
+	}
+
+	return true
+}
+
 func (this *FinalGroup) processItem(item value.AnnotatedValue, context *Context) bool {
 	// Generate the group key
 	var gk string
@@ -96,8 +113,27 @@ func (this *FinalGroup) processItem(item value.AnnotatedValue, context *Context)
 }
 
 func (this *FinalGroup) afterItems(context *Context) {
+	if this.groups == nil {
+		return
+	}
+
+	// Note the error of estimated aggregates
+	if len(this.groups) > 0 {
+		for _, agg := range this.plan.Aggregates() {
+			approx, ok := agg.(algebra.ApproximateAggregate)
+			if ok {
+				context.Warning(errors.NewApproximateAggregateWarning(
+					agg.String(), approx.StandardError()))
+			}
+		}
+	}
+
 	for _, av := range this.groups {
-		if !this.sendItem(av) {
+		if this.limit >= 0 {
+			if !this.sendGroupItems(av) {
+				return
+			}
+		} else if !this.sendItem(av) {
 			return
 		}
 	}
@@ -114,3 +150,17 @@ func (this *FinalGroup) afterItems(context *Context) {
 		this.sendItem(av)
 	}
 }
+
+// Send the items kept under EACH LIMIT, with the aggregates of their
+// group.
+func (this *FinalGroup) sendGroupItems(gv value.AnnotatedValue) bool {
+	aggregates := gv.GetAttachment("aggregates")
+	for _, item := range groupItems(gv) {
+		item.SetAttachment("aggregates", aggregates)
+		if !this.sendItem(item) {
+			return false
+		}
+	}
+
+	return true
+}
@@ -22,11 +22,12 @@ type InitialGroup struct {
 	base
 	plan   *plan.InitialGroup
 	groups map[string]value.AnnotatedValue
+	limit  int
 }
 
-func NewInitialGroup(plan *plan.InitialGroup) *InitialGroup {
+func NewInitialGroup(plan *plan.InitialGroup, context *Context) *InitialGroup {
 	rv := &InitialGroup{
-		base:   newBase(),
+		base:   newBase(context),
 		plan:   plan,
 		groups: make(map[string]value.AnnotatedValue),
 	}
@@ -51,6 +52,13 @@ func (this *InitialGroup) RunOnce(context *Context, parent value.Value) {
 	this.runConsumer(this, context, parent)
 }
 
+// An invalid EACH LIMIT is reported by FinalGroup, so all the items
+// are then kept.
+func (this *InitialGroup) beforeItems(context *Context, parent value.Value) bool {
+	this.limit, _ = groupLimit(this.plan.Limit(), parent, context)
+	return true
+}
+
 func (this *InitialGroup) processItem(item value.AnnotatedValue, context *Context) bool {
 	// Generate the group key
 	var gk string
@@ -76,6 +84,11 @@ func (this *InitialGroup) processItem(item value.AnnotatedValue, context *Contex
 		}
 	}
 
+	// Keep the item under EACH LIMIT
+	if this.limit >= 0 {
+		addGroupItems(gv, []value.AnnotatedValue{item}, this.limit)
+	}
+
 	// Cumulate aggregates
 	aggregates, ok := gv.GetAttachment("aggregates").(map[string]value.Value)
 	if !ok {
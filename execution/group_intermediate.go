@@ -22,11 +22,12 @@ type IntermediateGroup struct {
 	base
 	plan   *plan.IntermediateGroup
 	groups map[string]value.AnnotatedValue
+	limit  int
 }
 
-func NewIntermediateGroup(plan *plan.IntermediateGroup) *IntermediateGroup {
+func NewIntermediateGroup(plan *plan.IntermediateGroup, context *Context) *IntermediateGroup {
 	rv := &IntermediateGroup{
-		base:   newBase(),
+		base:   newBase(context),
 		plan:   plan,
 		groups: make(map[string]value.AnnotatedValue),
 	}
@@ -51,6 +52,12 @@ func (this *IntermediateGroup) RunOnce(context *Context, parent value.Value) {
 	this.runConsumer(this, context, parent)
 }
 
+// An invalid EACH LIMIT is reported by FinalGroup.
+func (this *IntermediateGroup) beforeItems(context *Context, parent value.Value) bool {
+	this.limit, _ = groupLimit(this.plan.Limit(), parent, context)
+	return true
+}
+
 func (this *IntermediateGroup) processItem(item value.AnnotatedValue, context *Context) bool {
 	// Generate the group key
 	var gk string
@@ -98,6 +105,11 @@ func (this *IntermediateGroup) processItem(item value.AnnotatedValue, context *C
 		cumulative[a] = v
 	}
 
+	// Keep the items of both under EACH LIMIT
+	if this.limit >= 0 {
+		addGroupItems(gv, groupItems(item), this.limit)
+	}
+
 	return true
 }
 
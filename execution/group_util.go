@@ -10,6 +10,8 @@
 package execution
 
 import (
+	"math"
+
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/value"
 )
@@ -30,3 +32,48 @@ func groupKey(item value.Value, keys expression.Expressions, context *Context) (
 	bytes, _ := value.NewValue(kvs).MarshalJSON()
 	return string(bytes), nil
 }
+
+/*
+Evaluate the EACH LIMIT of a grouping. Returns -1 if there is none,
+and false if it is not an integer.
+*/
+func groupLimit(limit expression.Expression, parent value.Value, context *Context) (int, bool) {
+	if limit == nil {
+		return -1, true
+	}
+
+	n, ok := evalCount(limit, parent, context)
+	if !ok {
+		return -1, false
+	}
+
+	if n < 0 {
+		return 0, true
+	} else if n > math.MaxInt32 {
+		return math.MaxInt32, true
+	}
+
+	return int(n), true
+}
+
+/*
+Keep the items of a group under EACH LIMIT, up to limit items.
+The group value is the first of its items.
+*/
+func addGroupItems(gv value.AnnotatedValue, items []value.AnnotatedValue, limit int) {
+	kept, _ := gv.GetAttachment("group_items").([]value.AnnotatedValue)
+	for _, item := range items {
+		if len(kept) >= limit {
+			break
+		}
+
+		kept = append(kept, item)
+	}
+
+	gv.SetAttachment("group_items", kept)
+}
+
+func groupItems(gv value.AnnotatedValue) []value.AnnotatedValue {
+	items, _ := gv.GetAttachment("group_items").([]value.AnnotatedValue)
+	return items
+}
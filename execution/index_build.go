@@ -19,9 +19,9 @@ type BuildIndexes struct {
 	plan *plan.BuildIndexes
 }
 
-func NewBuildIndexes(plan *plan.BuildIndexes) *BuildIndexes {
+func NewBuildIndexes(plan *plan.BuildIndexes, context *Context) *BuildIndexes {
 	rv := &BuildIndexes{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
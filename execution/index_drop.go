@@ -19,9 +19,9 @@ type DropIndex struct {
 	plan *plan.DropIndex
 }
 
-func NewDropIndex(plan *plan.DropIndex) *DropIndex {
+func NewDropIndex(plan *plan.DropIndex, context *Context) *DropIndex {
 	rv := &DropIndex{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -21,13 +21,14 @@ import (
 
 type SendInsert struct {
 	base
-	plan  *plan.SendInsert
-	limit int64
+	plan     *plan.SendInsert
+	limit    int64
+	pipeline sendPipeline
 }
 
-func NewSendInsert(plan *plan.SendInsert) *SendInsert {
+func NewSendInsert(plan *plan.SendInsert, context *Context) *SendInsert {
 	rv := &SendInsert{
-		base:  newBase(),
+		base:  newBase(context),
 		plan:  plan,
 		limit: -1,
 	}
@@ -41,7 +42,7 @@ func (this *SendInsert) Accept(visitor Visitor) (interface{}, error) {
 }
 
 func (this *SendInsert) Copy() Operator {
-	return &SendInsert{this.base.copy(), this.plan, this.limit}
+	return &SendInsert{base: this.base.copy(), plan: this.plan, limit: this.limit}
 }
 
 func (this *SendInsert) RunOnce(context *Context, parent value.Value) {
@@ -49,7 +50,7 @@ func (this *SendInsert) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *SendInsert) processItem(item value.AnnotatedValue, context *Context) bool {
-	rv := this.limit != 0 && this.enbatch(item, this, context)
+	rv := this.limit != 0 && this.enbatchSize(item, this, context.MutationBatch(), context)
 
 	if this.limit > 0 {
 		this.limit--
@@ -82,6 +83,7 @@ func (this *SendInsert) beforeItems(context *Context, parent value.Value) bool {
 
 func (this *SendInsert) afterItems(context *Context) {
 	this.flushBatch(context)
+	this.pipeline.wait()
 }
 
 func (this *SendInsert) flushBatch(context *Context) bool {
@@ -91,8 +93,8 @@ func (this *SendInsert) flushBatch(context *Context) bool {
 		return true
 	}
 
-	dpairs := _INSERT_POOL.Get()
-	defer _INSERT_POOL.Put(dpairs)
+	// Held until the batch is acknowledged
+	dpairs := make([]datastore.Pair, 0, len(this.batch))
 
 	keyExpr := this.plan.Key()
 	valExpr := this.plan.Value()
@@ -145,21 +147,36 @@ func (this *SendInsert) flushBatch(context *Context) bool {
 			continue
 		}
 
+		if !checkPolicy(this.plan.Policy(), this.plan.Keyspace(), dpair.Key, val, context) {
+			continue
+		}
+
 		dpair.Value = val
 		i++
 	}
 
 	dpairs = dpairs[0:i]
 
+	if !context.chargeMutations(this.plan.Keyspace(), len(dpairs)) {
+		return false
+	}
+
+	return this.pipeline.send(func() bool { return this.send(dpairs, context) }, context)
+}
+
+// Send a batch to the keyspace, and the mutated documents on.
+func (this *SendInsert) send(dpairs []datastore.Pair, context *Context) bool {
 	timer := time.Now()
 
 	// Perform the actual INSERT
-	keys, e := this.plan.Keyspace().Insert(dpairs)
+	keys, e := context.durableKeyspace(this.plan.Keyspace()).Insert(dpairs)
 
 	context.AddPhaseTime("insert", time.Since(timer))
 
 	// Update mutation count with number of inserted docs
 	context.AddMutationCount(uint64(len(keys)))
+	addKeyspaceMutations(this.plan.Keyspace(), uint64(len(keys)))
+	context.progress.Add(len(dpairs), len(keys))
 
 	if e != nil {
 		context.Error(e)
@@ -181,5 +198,3 @@ func (this *SendInsert) flushBatch(context *Context) bool {
 func (this *SendInsert) readonly() bool {
 	return false
 }
-
-var _INSERT_POOL = datastore.NewPairPool(_BATCH_SIZE)
@@ -21,9 +21,9 @@ type IntersectAll struct {
 	set          *value.Set
 }
 
-func NewIntersectAll(first, second Operator) *IntersectAll {
+func NewIntersectAll(first, second Operator, context *Context) *IntersectAll {
 	rv := &IntersectAll{
-		base:         newBase(),
+		base:         newBase(context),
 		first:        first,
 		second:       second,
 		childChannel: make(StopChannel, 2),
@@ -53,8 +53,8 @@ func (this *IntersectAll) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *IntersectAll) beforeItems(context *Context, parent value.Value) bool {
-	distinct := NewDistinct(true)
-	sequence := NewSequence(this.second, distinct)
+	distinct := NewDistinct(true, context)
+	sequence := NewSequence(context, this.second, distinct)
 	sequence.SetParent(this)
 	go sequence.RunOnce(context, parent)
 
@@ -12,7 +12,6 @@ package execution
 import (
 	"time"
 
-	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -23,9 +22,9 @@ type Join struct {
 	duration time.Duration
 }
 
-func NewJoin(plan *plan.Join) *Join {
+func NewJoin(plan *plan.Join, context *Context) *Join {
 	rv := &Join{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -46,13 +45,20 @@ func (this *Join) Copy() Operator {
 
 func (this *Join) RunOnce(context *Context, parent value.Value) {
 	defer context.AddPhaseTime("join", this.duration)
+	context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
+
+	err := context.openSnapshot(this.plan.Keyspace())
+	if err != nil {
+		context.Error(err)
+	}
+
 	this.runConsumer(this, context, parent)
 }
 
 func (this *Join) processItem(item value.AnnotatedValue, context *Context) bool {
 	kv, e := this.plan.Term().Keys().Evaluate(item, context)
 	if e != nil {
-		context.Error(errors.NewEvaluationError(e, "JOIN keys"))
+		context.Error(evaluationError(e, "JOIN keys", this.plan.Term().Keys(), item))
 		return false
 	}
 
@@ -72,7 +78,9 @@ func (this *Join) processItem(item value.AnnotatedValue, context *Context) bool
 	}
 
 	// Build list of keys
-	keys := make([]string, 0, len(acts))
+	keys := _STRING_POOL.Get(len(acts))
+	defer _STRING_POOL.Put(keys)
+
 	for _, key := range acts {
 		k := value.NewValue(key).Actual()
 		switch k := k.(type) {
@@ -84,7 +92,7 @@ func (this *Join) processItem(item value.AnnotatedValue, context *Context) bool
 	timer := time.Now()
 
 	// Fetch
-	pairs, errs := this.plan.Keyspace().Fetch(keys)
+	pairs, errs := context.fetch(this.plan.Keyspace(), keys)
 
 	this.duration += time.Since(timer)
 
@@ -96,6 +104,12 @@ func (this *Join) processItem(item value.AnnotatedValue, context *Context) bool
 		}
 	}
 
+	// Documents hidden by the keyspace policy are not joined
+	pairs, ok := filterPolicy(this.plan.Policy(), pairs, context)
+	if !ok {
+		return false
+	}
+
 	found := len(pairs) > 0
 
 	// Attach and send
@@ -108,7 +122,7 @@ func (this *Join) processItem(item value.AnnotatedValue, context *Context) bool
 		if projection != nil {
 			projectedItem, e := projection.Evaluate(joinItem, context)
 			if e != nil {
-				context.Error(errors.NewEvaluationError(e, "join path"))
+				context.Error(evaluationError(e, "join path", projection, joinItem))
 				return false
 			}
 
@@ -0,0 +1,128 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+type CreateKeyspace struct {
+	base
+	plan *plan.CreateKeyspace
+}
+
+func NewCreateKeyspace(plan *plan.CreateKeyspace, context *Context) *CreateKeyspace {
+	rv := &CreateKeyspace{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *CreateKeyspace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateKeyspace(this)
+}
+
+func (this *CreateKeyspace) Copy() Operator {
+	return &CreateKeyspace{this.base.copy(), this.plan}
+}
+
+func (this *CreateKeyspace) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		manager, err := keyspaceManager(context, this.plan.Namespace())
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		_, err = manager.CreateKeyspace(this.plan.Name())
+		if err != nil {
+			context.Error(err)
+		}
+
+		datastore.InvalidateKeyspace(this.plan.Namespace(), this.plan.Name())
+	})
+}
+
+type DropKeyspace struct {
+	base
+	plan *plan.DropKeyspace
+}
+
+func NewDropKeyspace(plan *plan.DropKeyspace, context *Context) *DropKeyspace {
+	rv := &DropKeyspace{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *DropKeyspace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropKeyspace(this)
+}
+
+func (this *DropKeyspace) Copy() Operator {
+	return &DropKeyspace{this.base.copy(), this.plan}
+}
+
+func (this *DropKeyspace) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		manager, err := keyspaceManager(context, this.plan.Namespace())
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		err = manager.DropKeyspace(this.plan.Name())
+		if err != nil {
+			context.Error(err)
+		}
+
+		datastore.InvalidateKeyspace(this.plan.Namespace(), this.plan.Name())
+	})
+}
+
+// The datastore of a request holds its temporary keyspaces.
+func keyspaceManager(context *Context, ns string) (datastore.KeyspaceManager, errors.Error) {
+	namespace, err := context.Datastore().NamespaceByName(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	manager, ok := namespace.(datastore.KeyspaceManager)
+	if !ok {
+		return nil, errors.NewOtherNotSupportedError(nil, "Temporary keyspaces are not supported by namespace "+ns)
+	}
+
+	return manager, nil
+}
@@ -0,0 +1,91 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/couchbase/query/datastore"
+)
+
+/*
+Per-keyspace mutation counters. Every mutation performed by the
+execution layer bumps the counter of the keyspace it was applied
+to, so that consumers can detect whether a keyspace has changed
+between two points in time.
+*/
+type keyspaceMutations struct {
+	sync.RWMutex
+	counters map[string]*uint64
+}
+
+var _KEYSPACE_MUTATIONS = &keyspaceMutations{
+	counters: make(map[string]*uint64, 64),
+}
+
+func keyspaceKey(namespace, keyspace string) string {
+	return namespace + ":" + keyspace
+}
+
+// Returns the number of mutations applied to the keyspace so far.
+func KeyspaceMutations(namespace, keyspace string) uint64 {
+	return _KEYSPACE_MUTATIONS.get(keyspaceKey(namespace, keyspace))
+}
+
+// Returns true if any of the keyspaces has been mutated since the
+// counts were taken (see Context.Keyspaces()).
+func KeyspacesChanged(counts map[string]uint64) bool {
+	for key, count := range counts {
+		if _KEYSPACE_MUTATIONS.get(key) != count {
+			return true
+		}
+	}
+
+	return false
+}
+
+func addKeyspaceMutations(keyspace datastore.Keyspace, count uint64) {
+	if count == 0 {
+		return
+	}
+
+	_KEYSPACE_MUTATIONS.add(keyspaceKey(keyspace.NamespaceId(), keyspace.Name()), count)
+}
+
+func (this *keyspaceMutations) get(key string) uint64 {
+	this.RLock()
+	counter, ok := this.counters[key]
+	this.RUnlock()
+
+	if !ok {
+		return 0
+	}
+
+	return atomic.LoadUint64(counter)
+}
+
+func (this *keyspaceMutations) add(key string, count uint64) {
+	this.RLock()
+	counter, ok := this.counters[key]
+	this.RUnlock()
+
+	if !ok {
+		this.Lock()
+		counter, ok = this.counters[key]
+		if !ok {
+			counter = new(uint64)
+			this.counters[key] = counter
+		}
+		this.Unlock()
+	}
+
+	atomic.AddUint64(counter, count)
+}
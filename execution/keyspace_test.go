@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/plan"
+)
+
+func TestKeyspaceInvalidation(t *testing.T) {
+	dir, er := ioutil.TempDir("", "keyspace")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	if er = os.Mkdir(dir+"/default", 0755); er != nil {
+		t.Fatal(er)
+	}
+
+	store, err := file.NewDatastore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	run := func(op func(*Context) Operator) {
+		output := &viewOutput{}
+		context := NewContext("test", store, nil, "default", false, 1, nil, nil, nil,
+			datastore.UNBOUNDED, nil, output)
+		op(context).RunOnce(context, nil)
+		if output.err != nil {
+			t.Fatal(output.err)
+		}
+	}
+
+	create := func(context *Context) Operator {
+		return NewCreateKeyspace(plan.NewCreateKeyspace("default", "orders"), context)
+	}
+
+	drop := func(context *Context) Operator {
+		return NewDropKeyspace(plan.NewDropKeyspace("default", "orders"), context)
+	}
+
+	// A failed lookup is not cached, a resolved keyspace is
+	if ks, _ := datastore.ResolveKeyspace(store, "default", "orders"); ks != nil {
+		t.Fatalf("Unexpected keyspace %v", ks)
+	}
+
+	run(create)
+	if ks, err := datastore.ResolveKeyspace(store, "default", "orders"); err != nil || ks == nil {
+		t.Fatalf("Expected keyspace orders, got %v", err)
+	}
+
+	run(drop)
+	if ks, _ := datastore.ResolveKeyspace(store, "default", "orders"); ks != nil {
+		t.Errorf("Expected the dropped keyspace not to be resolved, got %v", ks)
+	}
+
+	// A keyspace created again is not the one dropped
+	run(create)
+	ks, err := datastore.ResolveKeyspace(store, "default", "orders")
+	if err != nil || ks == nil {
+		t.Fatalf("Expected keyspace orders, got %v", err)
+	}
+
+	namespace, _ := store.NamespaceByName("default")
+	if created, _ := namespace.KeyspaceByName("orders"); created != ks {
+		t.Errorf("Expected the keyspace created again to be resolved")
+	}
+}
@@ -10,7 +10,6 @@
 package execution
 
 import (
-	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -20,9 +19,9 @@ type Let struct {
 	plan *plan.Let
 }
 
-func NewLet(plan *plan.Let) *Let {
+func NewLet(plan *plan.Let, context *Context) *Let {
 	rv := &Let{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -51,7 +50,7 @@ func (this *Let) processItem(item value.AnnotatedValue, context *Context) bool {
 	for _, b := range this.plan.Bindings() {
 		v, e := b.Expression().Evaluate(item, context)
 		if e != nil {
-			context.Error(errors.NewEvaluationError(e, "LET"))
+			context.Error(evaluationError(e, "LET", b.Expression(), item))
 			return false
 		}
 
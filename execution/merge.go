@@ -28,9 +28,9 @@ type Merge struct {
 	duration     time.Duration
 }
 
-func NewMerge(plan *plan.Merge, update, delete, insert Operator) *Merge {
+func NewMerge(plan *plan.Merge, update, delete, insert Operator, context *Context) *Merge {
 	rv := &Merge{
-		base:         newBase(),
+		base:         newBase(context),
 		plan:         plan,
 		update:       update,
 		delete:       delete,
@@ -71,9 +71,9 @@ func (this *Merge) RunOnce(context *Context, parent value.Value) {
 
 		go this.input.RunOnce(context, parent)
 
-		update := this.wrapChild(this.update)
-		delete := this.wrapChild(this.delete)
-		insert := this.wrapChild(this.insert)
+		update := this.wrapChild(this.update, context)
+		delete := this.wrapChild(this.delete, context)
+		insert := this.wrapChild(this.insert, context)
 
 		children := make([]Operator, 0, 3)
 
@@ -147,7 +147,7 @@ func (this *Merge) processMatch(item value.AnnotatedValue,
 	context *Context, update, delete, insert Operator) bool {
 	kv, e := this.plan.Key().Evaluate(item, context)
 	if e != nil {
-		context.Error(errors.NewEvaluationError(e, "MERGE key"))
+		context.Error(evaluationError(e, "MERGE key", this.plan.Key(), item))
 		return false
 	}
 
@@ -195,13 +195,13 @@ func (this *Merge) processMatch(item value.AnnotatedValue,
 	return fetchOk
 }
 
-func (this *Merge) wrapChild(op Operator) Operator {
+func (this *Merge) wrapChild(op Operator, context *Context) Operator {
 	if op == nil {
 		return nil
 	}
 
-	ch := NewChannel()
-	seq := NewSequence(ch, op)
+	ch := NewChannel(context)
+	seq := NewSequence(context, ch, op)
 	seq.SetInput(ch)
 	seq.SetParent(this)
 	seq.SetOutput(this.output)
@@ -12,7 +12,6 @@ package execution
 import (
 	"time"
 
-	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
 )
@@ -23,9 +22,9 @@ type Nest struct {
 	duration time.Duration
 }
 
-func NewNest(plan *plan.Nest) *Nest {
+func NewNest(plan *plan.Nest, context *Context) *Nest {
 	rv := &Nest{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -46,13 +45,20 @@ func (this *Nest) Copy() Operator {
 
 func (this *Nest) RunOnce(context *Context, parent value.Value) {
 	defer context.AddPhaseTime("nest", this.duration)
+	context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
+
+	err := context.openSnapshot(this.plan.Keyspace())
+	if err != nil {
+		context.Error(err)
+	}
+
 	this.runConsumer(this, context, parent)
 }
 
 func (this *Nest) processItem(item value.AnnotatedValue, context *Context) bool {
 	kv, e := this.plan.Term().Keys().Evaluate(item, context)
 	if e != nil {
-		context.Error(errors.NewEvaluationError(e, "NEST keys"))
+		context.Error(evaluationError(e, "NEST keys", this.plan.Term().Keys(), item))
 		return false
 	}
 
@@ -72,7 +78,9 @@ func (this *Nest) processItem(item value.AnnotatedValue, context *Context) bool
 	}
 
 	// Build list of keys
-	keys := make([]string, 0, len(acts))
+	keys := _STRING_POOL.Get(len(acts))
+	defer _STRING_POOL.Put(keys)
+
 	for _, key := range acts {
 		k := value.NewValue(key).Actual()
 		switch k := k.(type) {
@@ -84,7 +92,7 @@ func (this *Nest) processItem(item value.AnnotatedValue, context *Context) bool
 	timer := time.Now()
 
 	// Fetch
-	pairs, errs := this.plan.Keyspace().Fetch(keys)
+	pairs, errs := context.fetch(this.plan.Keyspace(), keys)
 
 	this.duration += time.Since(timer)
 
@@ -96,6 +104,12 @@ func (this *Nest) processItem(item value.AnnotatedValue, context *Context) bool
 		}
 	}
 
+	// Documents hidden by the keyspace policy are not nested
+	pairs, ok := filterPolicy(this.plan.Policy(), pairs, context)
+	if !ok {
+		return false
+	}
+
 	found := len(pairs) > 0
 
 	if !found && !this.plan.Outer() {
@@ -112,7 +126,7 @@ func (this *Nest) processItem(item value.AnnotatedValue, context *Context) bool
 		if projection != nil {
 			projectedItem, e := projection.Evaluate(nestItem, context)
 			if e != nil {
-				context.Error(errors.NewEvaluationError(e, "nest path"))
+				context.Error(evaluationError(e, "nest path", projection, nestItem))
 				return false
 			}
 
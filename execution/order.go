@@ -10,9 +10,12 @@
 package execution
 
 import (
+	"container/heap"
+	"math"
 	"time"
 
-	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/sort"
 	"github.com/couchbase/query/value"
@@ -24,15 +27,18 @@ type Order struct {
 	values  value.AnnotatedValues
 	context *Context
 	terms   []string
+	limit   int    // Number of items kept, if bounded
+	heaped  bool   // The kept items are a heap
+	count   uint64 // Number of items sorted
 }
 
 const _ORDER_CAP = 1024
 
 var _ORDER_POOL = value.NewAnnotatedPool(_ORDER_CAP)
 
-func NewOrder(plan *plan.Order) *Order {
+func NewOrder(plan *plan.Order, context *Context) *Order {
 	rv := &Order{
-		base:   newBase(),
+		base:   newBase(context),
 		plan:   plan,
 		values: _ORDER_POOL.Get(),
 	}
@@ -58,7 +64,72 @@ func (this *Order) RunOnce(context *Context, parent value.Value) {
 	this.runConsumer(this, context, parent)
 }
 
+func (this *Order) beforeItems(context *Context, parent value.Value) bool {
+	this.context = context
+	this.terms = make([]string, len(this.plan.Terms()))
+	for i, term := range this.plan.Terms() {
+		this.terms[i] = term.Expression().String()
+	}
+
+	this.limit = 0
+	this.heaped = false
+	this.count = 0
+
+	// An invalid OFFSET or LIMIT is reported by the Offset or Limit
+	// operator, so the sort is then unbounded
+	if this.plan.Limit() != nil {
+		limit, ok := evalCount(this.plan.Limit(), parent, context)
+		if !ok || limit <= 0 {
+			return true
+		}
+
+		if this.plan.Offset() != nil {
+			offset, ok := evalCount(this.plan.Offset(), parent, context)
+			if !ok || offset < 0 {
+				return true
+			}
+
+			limit += offset
+		}
+
+		if limit < math.MaxInt32 {
+			this.limit = int(limit)
+		}
+	}
+
+	return true
+}
+
+// Evaluate an OFFSET or LIMIT to an integer.
+func evalCount(expr expression.Expression, parent value.Value, context *Context) (int64, bool) {
+	val, e := expr.Evaluate(parent, context)
+	if e != nil {
+		return 0, false
+	}
+
+	actual, ok := val.Actual().(float64)
+	if !ok || math.Trunc(actual) != actual {
+		return 0, false
+	}
+
+	return int64(actual), true
+}
+
 func (this *Order) processItem(item value.AnnotatedValue, context *Context) bool {
+	// Items with a MISSING sort key are excluded, if so requested
+	if context.MissingOrder() == value.MISSING_EXCLUDED {
+		for i, term := range this.plan.Terms() {
+			ev, ok := this.sortKey(i, term, item)
+			if !ok {
+				return false
+			}
+
+			if ev.Type() == value.MISSING {
+				return true
+			}
+		}
+	}
+
 	if len(this.values) == cap(this.values) {
 		values := make(value.AnnotatedValues, len(this.values), len(this.values)<<1)
 		copy(values, this.values)
@@ -67,9 +138,37 @@ func (this *Order) processItem(item value.AnnotatedValue, context *Context) bool
 	}
 
 	this.values = append(this.values, item)
+	this.count++
+
+	if this.limit > 0 && len(this.values) > this.limit {
+		this.keepTop()
+	}
+
 	return true
 }
 
+/*
+Keep the first limit items in a heap, with the last of them on top.
+The new item, after the heap, replaces the top if it sorts before it,
+and is dropped otherwise, so that memory is bounded by the limit.
+*/
+func (this *Order) keepTop() {
+	h := &orderHeap{this}
+	if !this.heaped {
+		heap.Init(h)
+		this.heaped = true
+	}
+
+	n := this.limit
+	if this.Less(n, 0) {
+		this.Swap(n, 0)
+		heap.Fix(h, 0)
+	}
+
+	this.values[n] = nil
+	this.values = this.values[:n]
+}
+
 func (this *Order) afterItems(context *Context) {
 	defer this.releaseValues()
 	defer func() {
@@ -77,17 +176,11 @@ func (this *Order) afterItems(context *Context) {
 		this.terms = nil
 	}()
 
-	this.context = context
-	this.terms = make([]string, len(this.plan.Terms()))
-	for i, term := range this.plan.Terms() {
-		this.terms[i] = term.Expression().String()
-	}
-
 	timer := time.Now()
 	sort.Sort(this)
 	context.AddPhaseTime("sort", time.Since(timer))
 
-	context.SetSortCount(uint64(this.Len()))
+	context.SetSortCount(this.count)
 
 	for _, av := range this.values {
 		if !this.sendItem(av) {
@@ -108,43 +201,20 @@ func (this *Order) Len() int {
 func (this *Order) Less(i, j int) bool {
 	v1 := this.values[i]
 	v2 := this.values[j]
-
-	var ev1, ev2 value.Value
-	var c int
-	var e error
+	missing := this.context.MissingOrder()
 
 	for i, term := range this.plan.Terms() {
-		s := this.terms[i]
-
-		sv1 := v1.GetAttachment(s)
-		switch sv1 := sv1.(type) {
-		case value.Value:
-			ev1 = sv1
-		default:
-			ev1, e = term.Expression().Evaluate(v1, this.context)
-			if e != nil {
-				this.context.Error(errors.NewEvaluationError(e, "ORDER BY"))
-				return false
-			}
-
-			v1.SetAttachment(s, ev1)
+		ev1, ok := this.sortKey(i, term, v1)
+		if !ok {
+			return false
 		}
 
-		sv2 := v2.GetAttachment(s)
-		switch sv2 := sv2.(type) {
-		case value.Value:
-			ev2 = sv2
-		default:
-			ev2, e = term.Expression().Evaluate(v2, this.context)
-			if e != nil {
-				this.context.Error(errors.NewEvaluationError(e, "ORDER BY"))
-				return false
-			}
-
-			v2.SetAttachment(s, ev2)
+		ev2, ok := this.sortKey(i, term, v2)
+		if !ok {
+			return false
 		}
 
-		c = ev1.Collate(ev2)
+		c := value.CollateMissing(ev1, ev2, missing)
 
 		if c == 0 {
 			continue
@@ -158,6 +228,55 @@ func (this *Order) Less(i, j int) bool {
 	return false
 }
 
+/*
+The value of the i-th sort term for an item. It is evaluated once
+and attached to the item.
+*/
+func (this *Order) sortKey(i int, term *algebra.SortTerm, item value.AnnotatedValue) (value.Value, bool) {
+	s := this.terms[i]
+	if sv, ok := item.GetAttachment(s).(value.Value); ok {
+		return sv, true
+	}
+
+	ev, e := term.Expression().Evaluate(item, this.context)
+	if e != nil {
+		this.context.Error(evaluationError(e, "ORDER BY", term.Expression(), item))
+		return nil, false
+	}
+
+	item.SetAttachment(s, ev)
+	return ev, true
+}
+
 func (this *Order) Swap(i, j int) {
 	this.values[i], this.values[j] = this.values[j], this.values[i]
 }
+
+/*
+Max-heap over the first limit items of an Order.
+*/
+type orderHeap struct {
+	order *Order
+}
+
+func (this *orderHeap) Len() int {
+	return this.order.limit
+}
+
+func (this *orderHeap) Less(i, j int) bool {
+	return this.order.Less(j, i)
+}
+
+func (this *orderHeap) Swap(i, j int) {
+	this.order.Swap(i, j)
+}
+
+// Items are only replaced at the top, so the heap never grows or
+// shrinks.
+func (this *orderHeap) Push(x interface{}) {
+	panic("Unexpected push onto ORDER BY heap.")
+}
+
+func (this *orderHeap) Pop() interface{} {
+	panic("Unexpected pop from ORDER BY heap.")
+}
@@ -0,0 +1,350 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"bufio"
+	"encoding/csv"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// Progress is logged every _OUTFILE_PROGRESS rows.
+const _OUTFILE_PROGRESS = 100000
+
+/*
+Outfile writes its input to a temporary file beside the OUTFILE, and
+renames it to the OUTFILE once all the results have been written
+without errors, so that the OUTFILE is never incomplete. Its only
+output is a summary of the file.
+*/
+type Outfile struct {
+	base
+	plan    *plan.Outfile
+	path    string
+	file    *os.File
+	writer  *countingWriter
+	buffer  *bufio.Writer
+	csv     *csv.Writer
+	columns []string
+	rows    int64
+	failed  bool
+}
+
+func NewOutfile(plan *plan.Outfile, context *Context) *Outfile {
+	rv := &Outfile{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *Outfile) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitOutfile(this)
+}
+
+func (this *Outfile) Copy() Operator {
+	return &Outfile{
+		base: this.base.copy(),
+		plan: this.plan,
+	}
+}
+
+func (this *Outfile) RunOnce(context *Context, parent value.Value) {
+	this.runConsumer(this, context, parent)
+}
+
+func (this *Outfile) readonly() bool {
+	return false
+}
+
+func (this *Outfile) beforeItems(context *Context, parent value.Value) bool {
+	path, err := outfilePath(context.OutfileDir(), this.plan.Path())
+	if err != nil {
+		context.Error(err)
+		return false
+	}
+
+	file, e := ioutil.TempFile(filepath.Dir(path), "."+filepath.Base(path)+".")
+	if e != nil {
+		context.Error(errors.NewOutfileError(e, "Error creating OUTFILE "+this.plan.Path()))
+		return false
+	}
+
+	this.path = path
+	this.file = file
+	this.writer = &countingWriter{writer: file}
+	this.buffer = bufio.NewWriter(this.writer)
+	this.columns = this.plan.Columns()
+
+	switch this.plan.Format() {
+	case "csv":
+		this.csv = csv.NewWriter(this.buffer)
+	case "json":
+		_, e = this.buffer.WriteString("[")
+	}
+
+	return this.check(e, context)
+}
+
+func (this *Outfile) processItem(item value.AnnotatedValue, context *Context) bool {
+	var e error
+	switch this.plan.Format() {
+	case "csv":
+		e = this.writeCSV(item)
+	case "json":
+		if this.rows == 0 {
+			e = this.writeJSON("\n", item, "")
+		} else {
+			e = this.writeJSON(",\n", item, "")
+		}
+	default:
+		e = this.writeJSON("", item, "\n")
+	}
+
+	if !this.check(e, context) {
+		return false
+	}
+
+	this.rows++
+	if this.rows%_OUTFILE_PROGRESS == 0 {
+		logging.Infop("OUTFILE progress", logging.Pair{"request", context.RequestId()},
+			logging.Pair{"path", this.plan.Path()}, logging.Pair{"rows", this.rows})
+	}
+
+	return true
+}
+
+func (this *Outfile) afterItems(context *Context) {
+	if this.file == nil {
+		return
+	}
+
+	// Results are complete if the input was closed after its last item
+	complete := false
+	select {
+	case _, ok := <-this.input.ItemChannel():
+		complete = !ok
+	default:
+	}
+
+	var e error
+	if complete && !this.failed && context.ErrorCount() == 0 {
+		switch this.plan.Format() {
+		case "csv":
+			if this.rows == 0 {
+				e = this.writeHeader(nil)
+			}
+			if e == nil {
+				this.csv.Flush()
+				e = this.csv.Error()
+			}
+		case "json":
+			_, e = this.buffer.WriteString("\n]\n")
+		}
+
+		if e == nil {
+			e = this.buffer.Flush()
+		}
+	} else {
+		complete = false
+	}
+
+	er := this.file.Close()
+	if e == nil {
+		e = er
+	}
+
+	if complete && this.check(e, context) {
+		e = os.Rename(this.file.Name(), this.path)
+		if this.check(e, context) {
+			logging.Infop("OUTFILE written", logging.Pair{"request", context.RequestId()},
+				logging.Pair{"path", this.plan.Path()}, logging.Pair{"rows", this.rows})
+			this.sendItem(value.NewAnnotatedValue(map[string]interface{}{
+				"path":   this.plan.Path(),
+				"format": this.plan.Format(),
+				"rows":   float64(this.rows),
+				"bytes":  float64(this.writer.count),
+			}))
+			return
+		}
+	}
+
+	os.Remove(this.file.Name())
+}
+
+func (this *Outfile) check(e error, context *Context) bool {
+	if e == nil {
+		return true
+	}
+
+	if !this.failed {
+		this.failed = true
+		context.Error(errors.NewOutfileError(e, "Error writing OUTFILE "+this.plan.Path()))
+	}
+
+	return false
+}
+
+func (this *Outfile) writeJSON(prefix string, item value.Value, suffix string) error {
+	bytes, e := item.MarshalJSON()
+	if e == nil {
+		_, e = this.buffer.WriteString(prefix)
+	}
+
+	if e == nil {
+		_, e = this.buffer.Write(bytes)
+	}
+
+	if e == nil {
+		_, e = this.buffer.WriteString(suffix)
+	}
+
+	return e
+}
+
+/*
+Each result is a row of the columns of the projection, or else of the
+fields of the first result, in order of their names. Strings are
+written as is, MISSING and NULL as empty, and other values as JSON.
+*/
+func (this *Outfile) writeCSV(item value.Value) error {
+	if this.rows == 0 {
+		e := this.writeHeader(item)
+		if e != nil {
+			return e
+		}
+	}
+
+	record := make([]string, len(this.columns))
+	for i, column := range this.columns {
+		val := item
+		if column != "" {
+			val, _ = item.Field(column)
+		}
+
+		switch val.Type() {
+		case value.MISSING, value.NULL:
+		case value.STRING:
+			record[i] = val.Actual().(string)
+		default:
+			bytes, e := val.MarshalJSON()
+			if e != nil {
+				return e
+			}
+			record[i] = string(bytes)
+		}
+	}
+
+	return this.csv.Write(record)
+}
+
+func (this *Outfile) writeHeader(item value.Value) error {
+	if this.columns == nil && item != nil {
+		if item.Type() == value.OBJECT {
+			fields := item.Fields()
+			this.columns = make([]string, 0, len(fields))
+			for name, _ := range fields {
+				this.columns = append(this.columns, name)
+			}
+			sort.Strings(this.columns)
+		} else {
+			// The result itself is the only column
+			this.columns = []string{""}
+			return nil
+		}
+	}
+
+	if len(this.columns) == 0 {
+		return nil
+	}
+
+	return this.csv.Write(this.columns)
+}
+
+/*
+The OUTFILE path is relative to the outfile directory of the server,
+and may not be outside it.
+*/
+func outfilePath(dir, path string) (string, errors.Error) {
+	if dir == "" {
+		return "", errors.NewOutfileError(nil,
+			"INTO OUTFILE is disabled; the server has no outfile directory.")
+	}
+
+	if path == "" || filepath.IsAbs(path) {
+		return "", errors.NewOutfileError(nil,
+			"OUTFILE "+path+" must be relative to the outfile directory.")
+	}
+
+	rv, ok := insidePath(dir, path)
+	if !ok {
+		return "", errors.NewOutfileError(nil,
+			"OUTFILE "+path+" is outside the outfile directory.")
+	}
+
+	return rv, nil
+}
+
+/*
+The relative path joined to dir, unless it is outside dir. Symbolic
+links are resolved first, so that neither a link to a directory nor a
+link to a file leads outside dir; the file itself need not exist, but
+its directory must.
+*/
+func insidePath(dir, path string) (string, bool) {
+	base, e := filepath.EvalSymlinks(dir)
+	if e != nil {
+		return "", false
+	}
+
+	rv := filepath.Join(dir, path)
+	if _, e = os.Lstat(rv); e == nil {
+		rv, e = filepath.EvalSymlinks(rv)
+	} else if os.IsNotExist(e) {
+		var parent string
+		parent, e = filepath.EvalSymlinks(filepath.Dir(rv))
+		rv = filepath.Join(parent, filepath.Base(rv))
+	}
+
+	if e != nil {
+		return "", false
+	}
+
+	rel, e := filepath.Rel(base, rv)
+	if e != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return "", false
+	}
+
+	return rv, true
+}
+
+// Counts the bytes written to a file.
+type countingWriter struct {
+	writer io.Writer
+	count  int64
+}
+
+func (this *countingWriter) Write(p []byte) (int, error) {
+	n, e := this.writer.Write(p)
+	this.count += int64(n)
+	return n, e
+}
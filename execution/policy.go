@@ -0,0 +1,301 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+)
+
+type CreatePolicy struct {
+	base
+	plan *plan.CreatePolicy
+}
+
+func NewCreatePolicy(plan *plan.CreatePolicy, context *Context) *CreatePolicy {
+	rv := &CreatePolicy{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+func (this *CreatePolicy) Copy() Operator {
+	return &CreatePolicy{this.base.copy(), this.plan}
+}
+
+func (this *CreatePolicy) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		keyspace := this.plan.Keyspace()
+		_, ok := policy.Add(keyspace.NamespaceId(), keyspace.Name(), this.plan.Predicate())
+		if !ok {
+			context.Error(errors.NewPolicyExistsError(
+				policy.Key(keyspace.NamespaceId(), keyspace.Name())))
+		}
+	})
+}
+
+type DropPolicy struct {
+	base
+	plan *plan.DropPolicy
+}
+
+func NewDropPolicy(plan *plan.DropPolicy, context *Context) *DropPolicy {
+	rv := &DropPolicy{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *DropPolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropPolicy(this)
+}
+
+func (this *DropPolicy) Copy() Operator {
+	return &DropPolicy{this.base.copy(), this.plan}
+}
+
+func (this *DropPolicy) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		keyspace := this.plan.Keyspace()
+		if !policy.Remove(keyspace.NamespaceId(), keyspace.Name()) {
+			context.Error(errors.NewPolicyNotFoundError(
+				policy.Key(keyspace.NamespaceId(), keyspace.Name())))
+		}
+	})
+}
+
+type CreateMask struct {
+	base
+	plan *plan.CreateMask
+}
+
+func NewCreateMask(plan *plan.CreateMask, context *Context) *CreateMask {
+	rv := &CreateMask{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *CreateMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateMask(this)
+}
+
+func (this *CreateMask) Copy() Operator {
+	return &CreateMask{this.base.copy(), this.plan}
+}
+
+func (this *CreateMask) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		keyspace := this.plan.Keyspace()
+		_, ok := policy.AddMask(keyspace.NamespaceId(), keyspace.Name(),
+			this.plan.Path(), this.plan.MaskType())
+		if !ok {
+			context.Error(errors.NewMaskExistsError(
+				policy.Key(keyspace.NamespaceId(), keyspace.Name()), this.plan.Path().String()))
+		}
+	})
+}
+
+type DropMask struct {
+	base
+	plan *plan.DropMask
+}
+
+func NewDropMask(plan *plan.DropMask, context *Context) *DropMask {
+	rv := &DropMask{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *DropMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropMask(this)
+}
+
+func (this *DropMask) Copy() Operator {
+	return &DropMask{this.base.copy(), this.plan}
+}
+
+func (this *DropMask) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		keyspace := this.plan.Keyspace()
+		if !policy.RemoveMask(keyspace.NamespaceId(), keyspace.Name(), this.plan.Path()) {
+			context.Error(errors.NewMaskNotFoundError(
+				policy.Key(keyspace.NamespaceId(), keyspace.Name()), this.plan.Path().String()))
+		}
+	})
+}
+
+/*
+Policy predicates in plans are unqualified, and are evaluated
+against the documents themselves.
+*/
+
+// Drops the fetched documents that do not satisfy the predicate.
+func filterPolicy(predicate expression.Expression, pairs []datastore.AnnotatedPair,
+	context *Context) ([]datastore.AnnotatedPair, bool) {
+	if predicate == nil {
+		return pairs, true
+	}
+
+	rv := pairs[:0]
+	for _, pair := range pairs {
+		val, e := predicate.Evaluate(pair.Value, context)
+		if e != nil {
+			context.Error(errors.NewEvaluationError(e, "policy"))
+			return nil, false
+		}
+
+		if val.Truth() {
+			rv = append(rv, pair)
+		}
+	}
+
+	return rv, true
+}
+
+// Returns false, after reporting it, if a new document does not
+// satisfy the predicate.
+func checkPolicy(predicate expression.Expression, keyspace datastore.Keyspace,
+	key string, val value.Value, context *Context) bool {
+	if predicate == nil {
+		return true
+	}
+
+	if av, ok := val.(value.AnnotatedValue); ok {
+		val = av.GetValue()
+	}
+
+	doc := value.NewAnnotatedValue(val)
+	doc.SetAttachment("meta", map[string]interface{}{"id": key})
+
+	result, e := predicate.Evaluate(doc, context)
+	if e != nil {
+		context.Error(errors.NewEvaluationError(e, "policy"))
+		return false
+	}
+
+	if !result.Truth() {
+		context.Error(errors.NewPolicyViolationError(
+			policy.Key(keyspace.NamespaceId(), keyspace.Name()), key))
+		return false
+	}
+
+	return true
+}
+
+// Drops, after reporting them, the pairs that would overwrite
+// existing documents that do not satisfy the predicate.
+func checkPolicyOverwrites(predicate expression.Expression, keyspace datastore.Keyspace,
+	pairs []datastore.Pair, context *Context) ([]datastore.Pair, bool) {
+	if predicate == nil || len(pairs) == 0 {
+		return pairs, true
+	}
+
+	keys := _STRING_POOL.Get(len(pairs))
+	defer _STRING_POOL.Put(keys)
+
+	for _, pair := range pairs {
+		keys = append(keys, pair.Key)
+	}
+
+	existing, errs := keyspace.Fetch(keys)
+	for _, err := range errs {
+		context.Error(err)
+		if err.IsFatal() {
+			return nil, false
+		}
+	}
+
+	var hidden map[string]bool
+	for _, pair := range existing {
+		val, e := predicate.Evaluate(pair.Value, context)
+		if e != nil {
+			context.Error(errors.NewEvaluationError(e, "policy"))
+			return nil, false
+		}
+
+		if !val.Truth() {
+			if hidden == nil {
+				hidden = make(map[string]bool, len(existing))
+			}
+			hidden[pair.Key] = true
+		}
+	}
+
+	if len(hidden) == 0 {
+		return pairs, true
+	}
+
+	rv := pairs[:0]
+	for _, pair := range pairs {
+		if hidden[pair.Key] {
+			context.Error(errors.NewPolicyViolationError(
+				policy.Key(keyspace.NamespaceId(), keyspace.Name()), pair.Key))
+			continue
+		}
+
+		rv = append(rv, pair)
+	}
+
+	return rv, true
+}
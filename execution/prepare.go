@@ -16,9 +16,9 @@ type Prepare struct {
 	plan value.Value
 }
 
-func NewPrepare(plan value.Value) *Prepare {
+func NewPrepare(plan value.Value, context *Context) *Prepare {
 	rv := &Prepare{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -0,0 +1,85 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"sync/atomic"
+)
+
+/*
+Progress counts the documents that the mutation operators of a
+request have examined and mutated so far, the documents they failed
+to mutate, and the updates they retried after concurrent
+modifications, so that the progress of long-running UPDATE and
+DELETE statements can be reported while they run. A nil Progress
+counts nothing.
+*/
+type Progress struct {
+	examined int64
+	mutated  int64
+	failed   int64
+	retried  int64
+}
+
+func NewProgress() *Progress {
+	return &Progress{}
+}
+
+// Record a batch of mutations.
+func (this *Progress) Add(examined, mutated int) {
+	if this == nil {
+		return
+	}
+
+	atomic.AddInt64(&this.examined, int64(examined))
+	atomic.AddInt64(&this.mutated, int64(mutated))
+	if examined > mutated {
+		atomic.AddInt64(&this.failed, int64(examined-mutated))
+	}
+}
+
+// Record updates retried after concurrent modifications.
+func (this *Progress) Retry(retried int) {
+	if this == nil {
+		return
+	}
+
+	atomic.AddInt64(&this.retried, int64(retried))
+}
+
+func (this *Progress) Examined() int64 {
+	return atomic.LoadInt64(&this.examined)
+}
+
+func (this *Progress) Mutated() int64 {
+	return atomic.LoadInt64(&this.mutated)
+}
+
+func (this *Progress) Failed() int64 {
+	return atomic.LoadInt64(&this.failed)
+}
+
+func (this *Progress) Retried() int64 {
+	return atomic.LoadInt64(&this.retried)
+}
+
+// The counts, or nil if no documents have been examined.
+func (this *Progress) Values() map[string]interface{} {
+	if this == nil || this.Examined() == 0 {
+		return nil
+	}
+
+	return map[string]interface{}{
+		"examined": this.Examined(),
+		"mutated":  this.Mutated(),
+		"failed":   this.Failed(),
+		"retried":  this.Retried(),
+	}
+}
@@ -17,9 +17,9 @@ type FinalProject struct {
 	base
 }
 
-func NewFinalProject() *FinalProject {
+func NewFinalProject(context *Context) *FinalProject {
 	rv := &FinalProject{
-		base: newBase(),
+		base: newBase(context),
 	}
 
 	rv.output = rv
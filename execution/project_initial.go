@@ -10,6 +10,8 @@
 package execution
 
 import (
+	"fmt"
+
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
@@ -20,9 +22,9 @@ type InitialProject struct {
 	plan *plan.InitialProject
 }
 
-func NewInitialProject(plan *plan.InitialProject) *InitialProject {
+func NewInitialProject(plan *plan.InitialProject, context *Context) *InitialProject {
 	rv := &InitialProject{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -45,6 +47,14 @@ func (this *InitialProject) RunOnce(context *Context, parent value.Value) {
 var _EMPTY_ANNOTATED_VALUE = value.NewAnnotatedValue(map[string]interface{}{})
 
 func (this *InitialProject) processItem(item value.AnnotatedValue, context *Context) bool {
+	if len(this.plan.Masks()) > 0 {
+		var ok bool
+		item, ok = this.mask(item, context)
+		if !ok {
+			return false
+		}
+	}
+
 	terms := this.plan.Terms()
 	n := len(terms)
 
@@ -72,11 +82,11 @@ func (this *InitialProject) processItem(item value.AnnotatedValue, context *Cont
 		// Raw projection of an expression
 		v, err := expr.Evaluate(item, context)
 		if err != nil {
-			context.Error(errors.NewEvaluationError(err, "projection"))
+			context.Error(evaluationError(err, "projection", expr, item))
 			return false
 		}
 
-		if result.As() == "" {
+		if result.As() == "" || this.plan.Direct() {
 			return this.sendItem(value.NewAnnotatedValue(v))
 		}
 
@@ -104,7 +114,7 @@ func (this *InitialProject) processTerms(item value.AnnotatedValue, context *Con
 		if term.Result().Alias() != "" {
 			v, err := term.Result().Expression().Evaluate(item, context)
 			if err != nil {
-				context.Error(errors.NewEvaluationError(err, "projection"))
+				context.Error(evaluationError(err, "projection", term.Result().Expression(), item))
 				return false
 			}
 
@@ -121,7 +131,7 @@ func (this *InitialProject) processTerms(item value.AnnotatedValue, context *Con
 				var err error
 				starval, err = term.Result().Expression().Evaluate(item, context)
 				if err != nil {
-					context.Error(errors.NewEvaluationError(err, "projection"))
+					context.Error(evaluationError(err, "projection", term.Result().Expression(), item))
 					return false
 				}
 			}
@@ -138,3 +148,30 @@ func (this *InitialProject) processTerms(item value.AnnotatedValue, context *Con
 
 	return this.sendItem(pv)
 }
+
+/*
+Masks a copy of the item, so that masked values never reach the
+projection. Masks are evaluated against the original item.
+*/
+func (this *InitialProject) mask(item value.AnnotatedValue, context *Context) (value.AnnotatedValue, bool) {
+	masked, ok := item.CopyForUpdate().(value.AnnotatedValue)
+	if !ok {
+		context.Error(errors.NewInvalidValueError(
+			fmt.Sprintf("Invalid masked value of type %T.", masked)))
+		return nil, false
+	}
+
+	for _, m := range this.plan.Masks() {
+		v, err := m.Value().Evaluate(item, context)
+		if err != nil {
+			context.Error(evaluationError(err, "mask", m.Value(), item))
+			return nil, false
+		}
+
+		if v.Type() != value.MISSING {
+			m.Path().Set(masked, v, context)
+		}
+	}
+
+	return masked, true
+}
@@ -0,0 +1,117 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+type GrantRole struct {
+	base
+	plan *plan.GrantRole
+}
+
+func NewGrantRole(plan *plan.GrantRole, context *Context) *GrantRole {
+	rv := &GrantRole{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *GrantRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitGrantRole(this)
+}
+
+func (this *GrantRole) Copy() Operator {
+	return &GrantRole{this.base.copy(), this.plan}
+}
+
+func (this *GrantRole) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		catalog, err := context.Datastore().RoleCatalog()
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		for _, user := range this.plan.Users() {
+			for _, role := range this.plan.Roles() {
+				err = catalog.GrantRole(user, role)
+				if err != nil {
+					context.Error(err)
+					return
+				}
+			}
+		}
+	})
+}
+
+type RevokeRole struct {
+	base
+	plan *plan.RevokeRole
+}
+
+func NewRevokeRole(plan *plan.RevokeRole, context *Context) *RevokeRole {
+	rv := &RevokeRole{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *RevokeRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRevokeRole(this)
+}
+
+func (this *RevokeRole) Copy() Operator {
+	return &RevokeRole{this.base.copy(), this.plan}
+}
+
+func (this *RevokeRole) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		catalog, err := context.Datastore().RoleCatalog()
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		for _, user := range this.plan.Users() {
+			for _, role := range this.plan.Roles() {
+				err = catalog.RevokeRole(user, role)
+				if err != nil {
+					context.Error(err)
+					return
+				}
+			}
+		}
+	})
+}
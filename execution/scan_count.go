@@ -21,9 +21,9 @@ type CountScan struct {
 	plan *plan.CountScan
 }
 
-func NewCountScan(plan *plan.CountScan) *CountScan {
+func NewCountScan(plan *plan.CountScan, context *Context) *CountScan {
 	rv := &CountScan{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -45,6 +45,7 @@ func (this *CountScan) RunOnce(context *Context, parent value.Value) {
 		defer close(this.itemChannel) // Broadcast that I have stopped
 		defer this.notify()           // Notify that I have stopped
 
+		context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
 		timer := time.Now()
 
 		count, e := this.plan.Keyspace().Count()
@@ -56,6 +57,11 @@ func (this *CountScan) RunOnce(context *Context, parent value.Value) {
 			return
 		}
 
+		// The count stands in for the documents counted
+		if !context.chargeDocuments(this.plan.Term().Namespace(), this.plan.Term().Keyspace(), int(count)) {
+			return
+		}
+
 		cv := value.NewScopeValue(nil, parent)
 		av := value.NewAnnotatedValue(cv)
 		av.SetAttachment("count", value.NewValue(count))
@@ -19,9 +19,9 @@ type DummyScan struct {
 	base
 }
 
-func NewDummyScan() *DummyScan {
+func NewDummyScan(context *Context) *DummyScan {
 	rv := &DummyScan{
-		base: newBase(),
+		base: newBase(context),
 	}
 
 	rv.output = rv
@@ -0,0 +1,441 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"archive/zip"
+	"bufio"
+	"bytes"
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// Lines of jsonl files may be up to _IMPORT_MAX_LINE bytes.
+const _IMPORT_MAX_LINE = 20 * 1024 * 1024
+
+/*
+ImportScan reads the rows of an IMPORT file, and sends each with its
+key and value attached, as ValueScan does for UPSERT ... VALUES.
+Rejected rows fail the statement, unless there is an errors file, in
+which case they are written to it as JSON lines of the row, the error
+and the text of the row.
+*/
+type ImportScan struct {
+	base
+	plan     *plan.ImportScan
+	uuid     expression.Expression
+	errors   *os.File
+	buffer   *bufio.Writer
+	rows     int64
+	rejected int64
+}
+
+func NewImportScan(plan *plan.ImportScan, context *Context) *ImportScan {
+	rv := &ImportScan{
+		base: newBase(context),
+		plan: plan,
+		uuid: expression.NewUuid(),
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *ImportScan) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitImportScan(this)
+}
+
+func (this *ImportScan) Copy() Operator {
+	return &ImportScan{
+		base: this.base.copy(),
+		plan: this.plan,
+		uuid: this.uuid,
+	}
+}
+
+func (this *ImportScan) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+		defer this.closeErrors(context)
+
+		path, err := importPath(context.ImportDir(), this.plan.Path())
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		// Rejected rows would overwrite the file being imported
+		if this.plan.Errors() != "" {
+			errorsPath, err := importPath(context.ImportDir(), this.plan.Errors())
+			if err != nil {
+				context.Error(err)
+				return
+			}
+
+			if sameFile(path, errorsPath) {
+				context.Error(errors.NewImportError(nil,
+					"IMPORT errors file "+this.plan.Errors()+" may not be the file imported."))
+				return
+			}
+		}
+
+		var e error
+		switch this.plan.Format() {
+		case "zip":
+			e = this.scanZip(path, context)
+		default:
+			var file *os.File
+			file, e = os.Open(path)
+			if e != nil {
+				break
+			}
+			defer file.Close()
+
+			switch this.plan.Format() {
+			case "json":
+				e = this.scanJSON(file, context)
+			case "csv":
+				e = this.scanCSV(file, context)
+			default:
+				e = this.scanJSONL(file, context)
+			}
+		}
+
+		if e != nil {
+			context.Error(errors.NewImportError(e, "Error reading IMPORT file "+this.plan.Path()))
+			return
+		}
+
+		logging.Infop("IMPORT read", logging.Pair{"request", context.RequestId()},
+			logging.Pair{"path", this.plan.Path()}, logging.Pair{"rows", this.rows},
+			logging.Pair{"rejected", this.rejected})
+	})
+}
+
+func (this *ImportScan) readonly() bool {
+	return false
+}
+
+func (this *ImportScan) scanJSONL(file io.Reader, context *Context) error {
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), _IMPORT_MAX_LINE)
+	row := 0
+	for scanner.Scan() {
+		row++
+		line := scanner.Bytes()
+		if len(bytes.TrimSpace(line)) == 0 {
+			continue
+		}
+
+		if !this.importJSON(row, "", line, context) {
+			return nil
+		}
+	}
+
+	return scanner.Err()
+}
+
+// A JSON array of documents, which is decoded one document at a time.
+func (this *ImportScan) scanJSON(file io.Reader, context *Context) error {
+	decoder := json.NewDecoder(bufio.NewReader(file))
+	tok, e := decoder.Token()
+	if e != nil {
+		return e
+	}
+
+	if tok != json.Delim('[') {
+		return fmt.Errorf("The file is not a JSON array.")
+	}
+
+	row := 0
+	for decoder.More() {
+		var raw json.RawMessage
+		e = decoder.Decode(&raw)
+		if e != nil {
+			return e
+		}
+
+		row++
+		if !this.importJSON(row, "", raw, context) {
+			return nil
+		}
+	}
+
+	_, e = decoder.Token()
+	return e
+}
+
+/*
+The header names the fields. Empty fields are MISSING, fields that
+are JSON numbers, booleans, null, objects or arrays are parsed, and
+other fields are strings, as written by INTO OUTFILE.
+*/
+func (this *ImportScan) scanCSV(file io.Reader, context *Context) error {
+	reader := csv.NewReader(bufio.NewReader(file))
+	header, e := reader.Read()
+	if e == io.EOF {
+		return nil
+	} else if e != nil {
+		return e
+	}
+
+	row := 0
+	for {
+		record, e := reader.Read()
+		if e == io.EOF {
+			return nil
+		}
+
+		row++
+		if e != nil {
+			if _, ok := e.(*csv.ParseError); !ok {
+				return e
+			}
+
+			if !this.reject(row, "", strings.Join(record, ","), e.Error(), context) {
+				return nil
+			}
+			continue
+		}
+
+		doc := make(map[string]interface{}, len(header))
+		for i, name := range header {
+			field := record[i]
+			if field == "" {
+				continue
+			}
+
+			var parsed interface{}
+			if field[0] != '"' && json.Unmarshal([]byte(field), &parsed) == nil {
+				doc[name] = parsed
+			} else {
+				doc[name] = field
+			}
+		}
+
+		if !this.importRow(row, "", value.NewValue(doc), strings.Join(record, ","), context) {
+			return nil
+		}
+	}
+}
+
+/*
+Each JSON file in the archive is a document, whose default key is the
+name of the file without its extension. Other files are skipped.
+*/
+func (this *ImportScan) scanZip(path string, context *Context) error {
+	archive, e := zip.OpenReader(path)
+	if e != nil {
+		return e
+	}
+	defer archive.Close()
+
+	for _, f := range archive.File {
+		if f.FileInfo().IsDir() || !strings.EqualFold(filepath.Ext(f.Name), ".json") {
+			continue
+		}
+
+		r, e := f.Open()
+		if e != nil {
+			return e
+		}
+
+		data, e := ioutil.ReadAll(r)
+		r.Close()
+		if e != nil {
+			return e
+		}
+
+		if !this.importJSON(0, f.Name, data, context) {
+			return nil
+		}
+	}
+
+	return nil
+}
+
+func (this *ImportScan) importJSON(row int, entry string, data []byte, context *Context) bool {
+	if !json.Valid(data) {
+		return this.reject(row, entry, string(data), "Invalid JSON.", context)
+	}
+
+	// The scanner reuses its buffer
+	doc := make([]byte, len(data))
+	copy(doc, data)
+	return this.importRow(row, entry, value.NewValue(doc), string(data), context)
+}
+
+/*
+Key the row and send it; the row is either a row number or the name
+of a file in a zip.
+*/
+func (this *ImportScan) importRow(row int, entry string, doc value.Value, text string, context *Context) bool {
+	item := value.NewAnnotatedValue(doc)
+
+	var key value.Value
+	var err error
+	if this.plan.Key() != nil {
+		key, err = this.plan.Key().Evaluate(item, context)
+	} else if entry != "" {
+		base := filepath.Base(entry)
+		key = value.NewValue(strings.TrimSuffix(base, filepath.Ext(base)))
+	} else {
+		key, err = this.uuid.Evaluate(item, context)
+	}
+
+	if err != nil {
+		return this.reject(row, entry, text, "Error evaluating key: "+err.Error(), context)
+	}
+
+	if key.Type() != value.STRING {
+		return this.reject(row, entry, text, fmt.Sprintf("Key %v is not a string.", key), context)
+	}
+
+	val := doc
+	if this.plan.Value() != nil {
+		val, err = this.plan.Value().Evaluate(item, context)
+		if err != nil {
+			return this.reject(row, entry, text, "Error evaluating value: "+err.Error(), context)
+		}
+
+		if val.Type() == value.MISSING {
+			return this.reject(row, entry, text, "Value is MISSING.", context)
+		}
+	}
+
+	this.rows++
+	av := value.NewAnnotatedValue(nil)
+	av.SetAttachment("key", key)
+	av.SetAttachment("value", val)
+	return this.sendItem(av)
+}
+
+func (this *ImportScan) reject(row int, entry string, text, msg string, context *Context) bool {
+	this.rejected++
+	where := entry
+	if where == "" {
+		where = fmt.Sprintf("row %d", row)
+	}
+
+	if this.plan.Errors() == "" {
+		context.Error(errors.NewImportError(nil,
+			fmt.Sprintf("IMPORT %s %s rejected: %s", this.plan.Path(), where, msg)))
+		return false
+	}
+
+	if this.errors == nil {
+		path, err := importPath(context.ImportDir(), this.plan.Errors())
+		if err != nil {
+			context.Error(err)
+			return false
+		}
+
+		file, e := os.Create(path)
+		if e != nil {
+			context.Error(errors.NewImportError(e, "Error creating IMPORT errors file "+this.plan.Errors()))
+			return false
+		}
+
+		this.errors = file
+
+		this.buffer = bufio.NewWriter(this.errors)
+	}
+
+	rejection := map[string]interface{}{
+		"error": msg,
+		"text":  text,
+	}
+
+	if entry != "" {
+		rejection["entry"] = entry
+	} else {
+		rejection["row"] = row
+	}
+
+	data, e := json.Marshal(rejection)
+	if e == nil {
+		_, e = this.buffer.Write(append(data, '\n'))
+	}
+
+	if e != nil {
+		context.Error(errors.NewImportError(e, "Error writing IMPORT errors file "+this.plan.Errors()))
+		return false
+	}
+
+	return true
+}
+
+func (this *ImportScan) closeErrors(context *Context) {
+	if this.errors == nil {
+		return
+	}
+
+	e := this.buffer.Flush()
+	er := this.errors.Close()
+	if e == nil {
+		e = er
+	}
+
+	if e != nil {
+		context.Error(errors.NewImportError(e, "Error writing IMPORT errors file "+this.plan.Errors()))
+		return
+	}
+
+	context.Warning(errors.NewImportRejectedWarning(this.rejected, this.plan.Path(), this.plan.Errors()))
+}
+
+// Whether the paths, with links resolved, name the same file.
+func sameFile(path1, path2 string) bool {
+	if path1 == path2 {
+		return true
+	}
+
+	info1, e1 := os.Stat(path1)
+	info2, e2 := os.Stat(path2)
+	return e1 == nil && e2 == nil && os.SameFile(info1, info2)
+}
+
+/*
+IMPORT files and errors files are relative to the import directory of
+the server, and may not be outside it.
+*/
+func importPath(dir, path string) (string, errors.Error) {
+	if dir == "" {
+		return "", errors.NewImportError(nil,
+			"IMPORT is disabled; the server has no import directory.")
+	}
+
+	if path == "" || filepath.IsAbs(path) {
+		return "", errors.NewImportError(nil,
+			"IMPORT file "+path+" must be relative to the import directory.")
+	}
+
+	rv, ok := insidePath(dir, path)
+	if !ok {
+		return "", errors.NewImportError(nil,
+			"IMPORT file "+path+" is outside the import directory.")
+	}
+
+	return rv, nil
+}
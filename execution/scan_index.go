@@ -27,9 +27,9 @@ type IndexScan struct {
 	childChannel StopChannel
 }
 
-func NewIndexScan(plan *plan.IndexScan) *IndexScan {
+func NewIndexScan(plan *plan.IndexScan, context *Context) *IndexScan {
 	rv := &IndexScan{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -54,6 +54,22 @@ func (this *IndexScan) RunOnce(context *Context, parent value.Value) {
 		defer close(this.itemChannel) // Broadcast that I have stopped
 		defer this.notify()           // Notify that I have stopped
 
+		context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
+
+		// The index may have been dropped or taken offline since
+		// the plan was built; report it so the request can be re-planned
+		index := this.plan.Index()
+		state, _, er := index.State()
+		if er != nil {
+			context.Error(er)
+			return
+		}
+
+		if state != datastore.ONLINE {
+			context.Error(errors.NewIndexNotOnlineError(index.Name(), state.String()))
+			return
+		}
+
 		spans := this.plan.Spans()
 		n := len(spans)
 		this.childChannel = make(StopChannel, n)
@@ -130,6 +146,9 @@ func (this *spanScan) RunOnce(context *Context, parent value.Value) {
 
 		go this.scan(context, conn)
 
+		term := this.plan.Term()
+		covering := this.plan.Covering()
+
 		var entry *datastore.IndexEntry
 		ok := true
 		for ok {
@@ -143,17 +162,32 @@ func (this *spanScan) RunOnce(context *Context, parent value.Value) {
 			case entry, ok = <-conn.EntryChannel():
 				t := time.Now()
 
+				// Covering scans read no documents; their entries
+				// are charged instead
+				if ok && covering {
+					ok = context.chargeDocuments(term.Namespace(), term.Keyspace(), 1)
+					if !ok {
+						datastore.ReleaseIndexEntry(entry)
+					}
+				}
+
 				if ok {
 					cv := value.NewScopeValue(make(map[string]interface{}), parent)
 					av := value.NewAnnotatedValue(cv)
 					meta := map[string]interface{}{"id": entry.PrimaryKey}
 					av.SetAttachment("meta", meta)
 
+					// The cover after the index keys is META().id
 					covers := this.plan.Covers()
 					for i, c := range covers {
-						av.SetCover(c.Text(), entry.EntryKey[i])
+						if i < len(entry.EntryKey) {
+							av.SetCover(c.Text(), entry.EntryKey[i])
+						} else {
+							av.SetCover(c.Text(), value.NewValue(entry.PrimaryKey))
+						}
 					}
 
+					datastore.ReleaseIndexEntry(entry)
 					ok = this.sendItem(av)
 				}
 
@@ -175,6 +209,18 @@ func (this *spanScan) scan(context *Context, conn *datastore.IndexConnection) {
 		return
 	}
 
+	// The bound parameters leave nothing to scan
+	if dspan == nil {
+		close(conn.EntryChannel())
+		return
+	}
+
+	cons, vector, ok := context.TermScanConsistency(this.plan.Term())
+	if !ok {
+		close(conn.EntryChannel())
+		return
+	}
+
 	limit := int64(math.MaxInt64)
 	if this.plan.Limit() != nil {
 		lv, err := this.plan.Limit().Evaluate(nil, context)
@@ -183,39 +229,81 @@ func (this *spanScan) scan(context *Context, conn *datastore.IndexConnection) {
 		}
 	}
 
-	this.plan.Index().Scan(context.RequestId(), dspan, this.plan.Distinct(), limit,
-		context.ScanConsistency(), context.ScanVector(), conn)
+	index := context.scanIndex(this.plan.Term(), this.plan.Index(), cons)
+	index.Scan(context.RequestId(), dspan, this.plan.Distinct(), limit, cons, vector, conn)
 }
 
+/*
+Evaluate the span bounds against the bound parameters of the
+request. Returns nil if the span is empty: a bound over parameters
+that evaluates to NULL or MISSING comes from a comparison that is
+never true.
+*/
 func evalSpan(ps *plan.Span, context *Context) (*datastore.Span, error) {
 	var err error
+	var empty, e bool
 	ds := &datastore.Span{}
 
-	ds.Seek, err = evalExprs(ps.Seek, context)
+	ds.Seek, empty, err = evalExprs(ps.Seek, context)
+	if err != nil {
+		return nil, err
+	}
+
+	ds.Range.Low, e, err = evalExprs(ps.Range.Low, context)
 	if err != nil {
 		return nil, err
 	}
+	empty = empty || e
 
-	ds.Range.Low, err = evalExprs(ps.Range.Low, context)
+	ds.Range.High, e, err = evalExprs(ps.Range.High, context)
 	if err != nil {
 		return nil, err
 	}
+	empty = empty || e
 
-	ds.Range.High, err = evalExprs(ps.Range.High, context)
+	ds.Ranges, e, err = evalRanges(ps.Ranges, context)
 	if err != nil {
 		return nil, err
 	}
+	empty = empty || e
+
+	if empty {
+		return nil, nil
+	}
 
 	ds.Range.Inclusion = ps.Range.Inclusion
 	return ds, nil
 }
 
-func evalExprs(exprs expression.Expressions, context *Context) (value.Values, error) {
+func evalRanges(ranges plan.Ranges2, context *Context) (datastore.Ranges2, bool, error) {
+	if ranges == nil {
+		return nil, false, nil
+	}
+
+	rv := make(datastore.Ranges2, len(ranges))
+	for i, r := range ranges {
+		bounds, empty, err := evalExprs(expression.Expressions{r.Low, r.High}, context)
+		if err != nil || empty {
+			return nil, empty, err
+		}
+
+		rv[i] = &datastore.Range2{
+			Low:       bounds[0],
+			High:      bounds[1],
+			Inclusion: r.Inclusion,
+		}
+	}
+
+	return rv, false, nil
+}
+
+func evalExprs(exprs expression.Expressions, context *Context) (value.Values, bool, error) {
 	if exprs == nil {
-		return nil, nil
+		return nil, false, nil
 	}
 
 	values := make(value.Values, len(exprs))
+	empty := false
 
 	var err error
 	for i, expr := range exprs {
@@ -225,9 +313,13 @@ func evalExprs(exprs expression.Expressions, context *Context) (value.Values, er
 
 		values[i], err = expr.Evaluate(nil, context)
 		if err != nil {
-			return nil, err
+			return nil, false, err
+		}
+
+		if _, ok := expr.(*expression.Constant); !ok && values[i].Type() <= value.NULL {
+			empty = true
 		}
 	}
 
-	return values, nil
+	return values, empty, nil
 }
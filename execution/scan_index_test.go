@@ -0,0 +1,56 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+func TestEvalSpan(t *testing.T) {
+	x := algebra.NewNamedParameter("x")
+	spans := []*plan.Span{
+		{Seek: expression.Expressions{x}},
+		{Range: plan.Range{Low: expression.Expressions{x}, Inclusion: datastore.LOW}},
+		{Range: plan.Range{High: expression.Expressions{x}, Inclusion: datastore.HIGH}},
+		{Ranges: plan.Ranges2{&plan.Range2{Low: x, High: x, Inclusion: datastore.BOTH}}},
+	}
+
+	eval := func(arg value.Value, ps *plan.Span) (*datastore.Span, error) {
+		context := NewContext("test", nil, nil, "default", false, 1,
+			map[string]value.Value{"x": arg}, nil, nil, datastore.UNBOUNDED, nil, &viewOutput{})
+		return evalSpan(ps, context)
+	}
+
+	for i, ps := range spans {
+		if span, err := eval(value.NewValue(1), ps); err != nil || span == nil {
+			t.Errorf("Expected span %d to be evaluated, got %v and %v", i, span, err)
+		}
+
+		// A bound of NULL or MISSING selects no entries
+		for _, arg := range []value.Value{value.NULL_VALUE, value.MISSING_VALUE} {
+			if span, err := eval(arg, ps); err != nil || span != nil {
+				t.Errorf("Expected span %d to be skipped for %v, got %v and %v", i, arg, span, err)
+			}
+		}
+	}
+
+	// Constant bounds are kept as planned
+	ps := &plan.Span{Range: plan.Range{Low: expression.Expressions{expression.NULL_EXPR},
+		Inclusion: datastore.NEITHER}}
+	if span, err := eval(value.NULL_VALUE, ps); err != nil || span == nil {
+		t.Errorf("Expected the constant span to be evaluated, got %v and %v", span, err)
+	}
+}
@@ -24,9 +24,9 @@ type IntersectScan struct {
 	childChannel StopChannel
 }
 
-func NewIntersectScan(scans []Operator) *IntersectScan {
+func NewIntersectScan(scans []Operator, context *Context) *IntersectScan {
 	rv := &IntersectScan{
-		base:         newBase(),
+		base:         newBase(context),
 		scans:        scans,
 		childChannel: make(StopChannel, len(scans)),
 	}
@@ -70,7 +70,7 @@ func (this *IntersectScan) RunOnce(context *Context, parent value.Value) {
 		this.counts = _COUNT_POOL.Get()
 		this.values = _VALUE_POOL.Get()
 
-		channel := NewChannel()
+		channel := NewChannel(context)
 
 		for _, scan := range this.scans {
 			scan.SetParent(this)
@@ -17,9 +17,9 @@ type ParentScan struct {
 	base
 }
 
-func NewParentScan() *ParentScan {
+func NewParentScan(context *Context) *ParentScan {
 	rv := &ParentScan{
-		base: newBase(),
+		base: newBase(context),
 	}
 
 	rv.output = rv
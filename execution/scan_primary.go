@@ -10,11 +10,13 @@
 package execution
 
 import (
+	"fmt"
 	"math"
 	"time"
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/value"
@@ -25,9 +27,9 @@ type PrimaryScan struct {
 	plan *plan.PrimaryScan
 }
 
-func NewPrimaryScan(plan *plan.PrimaryScan) *PrimaryScan {
+func NewPrimaryScan(plan *plan.PrimaryScan, context *Context) *PrimaryScan {
 	rv := &PrimaryScan{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -49,6 +51,14 @@ func (this *PrimaryScan) RunOnce(context *Context, parent value.Value) {
 		defer close(this.itemChannel) // Broadcast that I have stopped
 		defer this.notify()           // Notify that I have stopped
 
+		context.addKeyspace(this.plan.Term().Namespace(), this.plan.Term().Keyspace())
+
+		err := context.openSnapshot(this.plan.Keyspace())
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
 		this.scanPrimary(context, parent)
 	})
 }
@@ -80,10 +90,11 @@ func (this *PrimaryScan) scanPrimary(context *Context, parent value.Value) {
 			t := time.Now()
 
 			if ok {
-				cv := value.NewScopeValue(make(map[string]interface{}), parent)
-				av := value.NewAnnotatedValue(cv)
-				av.SetAttachment("meta", map[string]interface{}{"id": entry.PrimaryKey})
-				ok = this.sendItem(av)
+				ok = this.chargeEntry(context) && this.sendItem(this.entryValue(entry, parent))
+
+				// Only the last entry is kept, as the starting
+				// point of chunked scans
+				datastore.ReleaseIndexEntry(lastEntry)
 				lastEntry = entry
 				nitems++
 			}
@@ -95,7 +106,9 @@ func (this *PrimaryScan) scanPrimary(context *Context, parent value.Value) {
 
 	}
 
-	if conn.Timeout() {
+	if conn.Timeout() && !context.Features().Enabled(feature.CHUNKED_SCAN) {
+		context.Error(errors.NewCbIndexScanTimeoutError(nil))
+	} else if conn.Timeout() {
 		logging.Errorp("Primary index scan timeout - resorting to chunked scan",
 			logging.Pair{"chunkSize", nitems},
 			logging.Pair{"startingEntry", lastEntry})
@@ -138,10 +151,11 @@ func (this *PrimaryScan) scanPrimaryChunk(context *Context, parent value.Value,
 			t := time.Now()
 
 			if ok {
-				cv := value.NewScopeValue(make(map[string]interface{}), parent)
-				av := value.NewAnnotatedValue(cv)
-				av.SetAttachment("meta", map[string]interface{}{"id": entry.PrimaryKey})
-				ok = this.sendItem(av)
+				ok = this.chargeEntry(context) && this.sendItem(this.entryValue(entry, parent))
+
+				// Only the last entry is kept, as the starting
+				// point of chunked scans
+				datastore.ReleaseIndexEntry(lastEntry)
 				lastEntry = entry
 				nitems++
 			}
@@ -156,9 +170,38 @@ func (this *PrimaryScan) scanPrimaryChunk(context *Context, parent value.Value,
 	return lastEntry
 }
 
+// Covering scans read no documents; their entries are charged instead.
+func (this *PrimaryScan) chargeEntry(context *Context) bool {
+	if len(this.plan.Covers()) == 0 {
+		return true
+	}
+
+	term := this.plan.Term()
+	return context.chargeDocuments(term.Namespace(), term.Keyspace(), 1)
+}
+
+// The cover, if any, is META().id.
+func (this *PrimaryScan) entryValue(entry *datastore.IndexEntry, parent value.Value) value.AnnotatedValue {
+	cv := value.NewScopeValue(make(map[string]interface{}), parent)
+	av := value.NewAnnotatedValue(cv)
+	av.SetAttachment("meta", map[string]interface{}{"id": entry.PrimaryKey})
+
+	for _, c := range this.plan.Covers() {
+		av.SetCover(c.Text(), value.NewValue(entry.PrimaryKey))
+	}
+
+	return av
+}
+
 func (this *PrimaryScan) scanEntries(context *Context, conn *datastore.IndexConnection) {
 	defer context.Recover() // Recover from any panic
 
+	cons, vector, ok := context.TermScanConsistency(this.plan.Term())
+	if !ok {
+		close(conn.EntryChannel())
+		return
+	}
+
 	limit := int64(math.MaxInt64)
 	if this.plan.Limit() != nil {
 		lv, err := this.plan.Limit().Evaluate(nil, context)
@@ -167,20 +210,71 @@ func (this *PrimaryScan) scanEntries(context *Context, conn *datastore.IndexConn
 		}
 	}
 
-	this.plan.Index().ScanEntries(context.RequestId(), limit,
-		context.ScanConsistency(), context.ScanVector(), conn)
+	if this.plan.Offset() != nil {
+		offset, ok := this.evalOffset(context)
+		if !ok {
+			close(conn.EntryChannel())
+			return
+		}
+
+		index, ok := this.primaryIndex(context, cons).(datastore.OffsetPrimaryIndex)
+		if !ok {
+			index = this.plan.Index().(datastore.OffsetPrimaryIndex)
+		}
+
+		index.ScanEntriesOffset(context.RequestId(), offset, limit, cons, vector, conn)
+		return
+	}
+
+	this.primaryIndex(context, cons).ScanEntries(context.RequestId(), limit, cons, vector, conn)
+}
+
+// The primary index to scan, on a replica if the replica policy
+// routes there.
+func (this *PrimaryScan) primaryIndex(context *Context,
+	cons datastore.ScanConsistency) datastore.PrimaryIndex {
+	index := this.plan.Index()
+	if primary, ok := context.scanIndex(this.plan.Term(), index, cons).(datastore.PrimaryIndex); ok {
+		return primary
+	}
+
+	return index
+}
+
+// OFFSET is validated as by the Offset operator, which it replaces.
+func (this *PrimaryScan) evalOffset(context *Context) (int64, bool) {
+	val, err := this.plan.Offset().Evaluate(nil, context)
+	if err != nil {
+		context.Error(errors.NewEvaluationError(err, "OFFSET"))
+		return 0, false
+	}
+
+	actual, ok := val.Actual().(float64)
+	if !ok || actual < 0 || math.Trunc(actual) != actual {
+		context.Error(errors.NewInvalidValueError(
+			fmt.Sprintf("Invalid OFFSET value %v.", val.Actual())))
+		return 0, false
+	}
+
+	return int64(actual), true
 }
 
 func (this *PrimaryScan) scanChunk(context *Context, conn *datastore.IndexConnection, chunkSize int, indexEntry *datastore.IndexEntry) {
 	defer context.Recover() // Recover from any panic
+
+	cons, vector, ok := context.TermScanConsistency(this.plan.Term())
+	if !ok {
+		close(conn.EntryChannel())
+		return
+	}
 	ds := &datastore.Span{}
 	// do the scan starting from, but not including, the given index entry:
 	ds.Range = datastore.Range{
 		Inclusion: datastore.NEITHER,
 		Low:       []value.Value{value.NewValue(indexEntry.PrimaryKey)},
 	}
-	this.plan.Index().Scan(context.RequestId(), ds, true, int64(chunkSize),
-		context.ScanConsistency(), context.ScanVector(), conn)
+	this.primaryIndex(context, cons).Scan(context.RequestId(), ds, true, int64(chunkSize),
+		cons, vector, conn)
 }
 
 func (this *PrimaryScan) newIndexConnection(context *Context) *datastore.IndexConnection {
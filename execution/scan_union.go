@@ -23,9 +23,9 @@ type UnionScan struct {
 	childChannel StopChannel
 }
 
-func NewUnionScan(scans []Operator) *UnionScan {
+func NewUnionScan(scans []Operator, context *Context) *UnionScan {
 	rv := &UnionScan{
-		base:         newBase(),
+		base:         newBase(context),
 		scans:        scans,
 		childChannel: make(StopChannel, len(scans)),
 	}
@@ -68,7 +68,7 @@ func (this *UnionScan) RunOnce(context *Context, parent value.Value) {
 			this.values = nil
 		}()
 
-		channel := NewChannel()
+		channel := NewChannel(context)
 
 		for _, scan := range this.scans {
 			scan.SetParent(this)
@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"sync/atomic"
+)
+
+/*
+The number of documents that INSERT and UPSERT send to the keyspace at
+a time; zero or less means the pipeline batch size.
+*/
+var _MUTATION_BATCH int64
+
+func SetMutationBatch(size int) {
+	atomic.StoreInt64(&_MUTATION_BATCH, int64(size))
+}
+
+func MutationBatchSize() int {
+	size := int(atomic.LoadInt64(&_MUTATION_BATCH))
+	if size <= 0 {
+		return PipelineBatchSize()
+	}
+
+	return size
+}
+
+/*
+sendPipeline streams the batches of a mutation operator to the
+keyspace: each batch is sent in the background while the operator
+builds the next, and is acknowledged before the next is sent. At most
+one batch is in flight, so that INSERT and UPSERT of any number of
+documents hold no more than two batches at a time.
+*/
+type sendPipeline struct {
+	done chan bool // Receives the outcome of the batch in flight
+}
+
+// Send a batch, once the batch in flight is acknowledged. Returns
+// false if the batch in flight failed.
+func (this *sendPipeline) send(send func() bool, context *Context) bool {
+	if !this.wait() {
+		return false
+	}
+
+	done := make(chan bool, 1)
+	this.done = done
+
+	go func() {
+		ok := false
+		defer func() { done <- ok }()
+		defer context.Recover()
+
+		ok = send()
+	}()
+
+	return true
+}
+
+// Wait for the acknowledgment of the batch in flight, if any.
+func (this *sendPipeline) wait() bool {
+	if this.done == nil {
+		return true
+	}
+
+	ok := <-this.done
+	this.done = nil
+	return ok
+}
@@ -19,9 +19,9 @@ type Sequence struct {
 	childChannel StopChannel
 }
 
-func NewSequence(children ...Operator) *Sequence {
+func NewSequence(context *Context, children ...Operator) *Sequence {
 	rv := &Sequence{
-		base:         newBase(),
+		base:         newBase(context),
 		children:     children,
 		childChannel: make(StopChannel, 1),
 	}
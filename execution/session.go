@@ -0,0 +1,136 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/session"
+	"github.com/couchbase/query/value"
+)
+
+// Makes a session setting, starting a session if the request has
+// none. The result holds the session id, which the client passes
+// on to subsequent requests, and the settings of the session.
+type SessionSet struct {
+	base
+	plan *plan.SessionSet
+}
+
+func NewSessionSet(plan *plan.SessionSet, context *Context) *SessionSet {
+	rv := &SessionSet{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *SessionSet) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSessionSet(this)
+}
+
+func (this *SessionSet) Copy() Operator {
+	return &SessionSet{this.base.copy(), this.plan}
+}
+
+func (this *SessionSet) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		s := context.Session()
+		started := s == nil
+		if started {
+			users := make([]string, 0, len(context.Credentials()))
+			for user, _ := range context.Credentials() {
+				users = append(users, user)
+			}
+
+			s = session.New(users)
+			context.SetSession(s)
+		}
+
+		err := s.Set(this.plan.Name(), this.plan.Value())
+		if err != nil {
+			if started {
+				session.Remove(s.Id())
+				context.SetSession(nil)
+			}
+			context.Error(errors.NewSessionSettingError(err))
+			return
+		}
+
+		settings := make(map[string]interface{}, 4)
+		for name, setting := range s.Settings() {
+			settings[name] = setting
+		}
+
+		item := value.NewAnnotatedValue(map[string]interface{}{
+			"session":  s.Id(),
+			"settings": settings,
+		})
+		this.sendItem(item)
+	})
+}
+
+// Sets a script variable. The server binds the value of the variable
+// to a named parameter for the later statements of the request; it
+// is also kept in the session, if the request has one.
+type SetVariable struct {
+	base
+	plan *plan.SetVariable
+}
+
+func NewSetVariable(plan *plan.SetVariable, context *Context) *SetVariable {
+	rv := &SetVariable{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+func (this *SetVariable) Copy() Operator {
+	return &SetVariable{this.base.copy(), this.plan}
+}
+
+func (this *SetVariable) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		item := value.NewScopeValue(nil, parent)
+		val, err := this.plan.Expression().Evaluate(item, context)
+		if err != nil {
+			context.Error(errors.NewEvaluationError(err, "script variable"))
+			return
+		}
+
+		if s := context.Session(); s != nil {
+			s.SetVariable(this.plan.Name(), val)
+		}
+
+		rv := map[string]interface{}{"name": this.plan.Name()}
+		if val.Type() != value.MISSING {
+			rv["value"] = val
+		}
+
+		this.sendItem(value.NewAnnotatedValue(rv))
+	})
+}
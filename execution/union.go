@@ -19,9 +19,9 @@ type UnionAll struct {
 	childChannel StopChannel
 }
 
-func NewUnionAll(children ...Operator) *UnionAll {
+func NewUnionAll(context *Context, children ...Operator) *UnionAll {
 	rv := &UnionAll{
-		base:         newBase(),
+		base:         newBase(context),
 		children:     children,
 		childChannel: make(StopChannel, len(children)),
 	}
@@ -20,9 +20,9 @@ type Unnest struct {
 	plan *plan.Unnest
 }
 
-func NewUnnest(plan *plan.Unnest) *Unnest {
+func NewUnnest(plan *plan.Unnest, context *Context) *Unnest {
 	rv := &Unnest{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -45,10 +45,15 @@ func (this *Unnest) RunOnce(context *Context, parent value.Value) {
 func (this *Unnest) processItem(item value.AnnotatedValue, context *Context) bool {
 	ev, err := this.plan.Term().Expression().Evaluate(item, context)
 	if err != nil {
-		context.Error(errors.NewEvaluationError(err, "UNNEST path"))
+		context.Error(evaluationError(err, "UNNEST path", this.plan.Term().Expression(), item))
 		return false
 	}
 
+	// Stream values, e.g. subquery results, are not materialized
+	if stream, ok := ev.(*value.StreamValue); ok {
+		return this.unnestStream(item, stream, context)
+	}
+
 	actuals := ev.Actual()
 	switch actuals.(type) {
 	case []interface{}:
@@ -84,3 +89,38 @@ func (this *Unnest) processItem(item value.AnnotatedValue, context *Context) boo
 
 	return true
 }
+
+func (this *Unnest) unnestStream(item value.AnnotatedValue, stream *value.StreamValue, context *Context) bool {
+	n := stream.Len()
+	if n == 0 {
+		// Outer unnest
+		return !this.plan.Term().Outer() || this.sendItem(item)
+	}
+
+	// Attach and send
+	it := stream.Iterator()
+	for i := 0; i < n; i++ {
+		act, ok := it.Next()
+		if !ok {
+			context.Error(errors.NewError(it.Err(), "Error reading UNNEST path"))
+			return false
+		}
+
+		var av value.AnnotatedValue
+		if i < n-1 {
+			av = value.NewAnnotatedValue(item.Copy())
+		} else {
+			av = item
+		}
+
+		actv := value.NewAnnotatedValue(act)
+		actv.SetAttachment("unnest_position", i)
+		av.SetField(this.plan.Alias(), actv)
+
+		if !this.sendItem(av) {
+			return false
+		}
+	}
+
+	return true
+}
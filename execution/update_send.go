@@ -13,6 +13,7 @@ import (
 	"fmt"
 	"time"
 
+	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/plan"
@@ -26,9 +27,9 @@ type SendUpdate struct {
 	limit int64
 }
 
-func NewSendUpdate(plan *plan.SendUpdate) *SendUpdate {
+func NewSendUpdate(plan *plan.SendUpdate, context *Context) *SendUpdate {
 	rv := &SendUpdate{
-		base:  newBase(),
+		base:  newBase(context),
 		plan:  plan,
 		limit: -1,
 	}
@@ -134,14 +135,21 @@ func (this *SendUpdate) flushBatch(context *Context) bool {
 		}
 	}
 
+	if !context.chargeMutations(this.plan.Keyspace(), len(pairs)) {
+		return false
+	}
+
+	examined := len(pairs)
 	timer := time.Now()
 
-	pairs, e := this.plan.Keyspace().Update(pairs)
+	pairs, e := this.update(pairs, context)
 
 	context.AddPhaseTime("update", time.Since(timer))
 
 	// Update mutation count with number of updated docs
 	context.AddMutationCount(uint64(len(pairs)))
+	addKeyspaceMutations(this.plan.Keyspace(), uint64(len(pairs)))
+	context.progress.Add(examined, len(pairs))
 
 	if e != nil {
 		context.Error(e)
@@ -156,6 +164,111 @@ func (this *SendUpdate) flushBatch(context *Context) bool {
 	return true
 }
 
+/*
+Update the documents. If the request retries updates and the keyspace
+reports concurrent modifications, the documents modified since they
+were fetched are fetched again and, if they still satisfy the WHERE
+clause, the SET and UNSET re-applied to them, as many times as the
+request allows.
+*/
+func (this *SendUpdate) update(pairs []datastore.Pair, context *Context) ([]datastore.Pair, errors.Error) {
+	keyspace := context.durableKeyspace(this.plan.Keyspace())
+	casKeyspace, ok := keyspace.(datastore.CasKeyspace)
+	if !ok || context.casRetries <= 0 {
+		return keyspace.Update(pairs)
+	}
+
+	updated, mismatched, e := casKeyspace.UpdateCas(pairs)
+	if len(mismatched) == 0 {
+		return updated, e
+	}
+
+	items := make(map[string]value.AnnotatedValue, len(pairs))
+	for i, pair := range pairs {
+		items[pair.Key] = this.batch[i]
+	}
+
+	for retry := 0; retry < context.casRetries && len(mismatched) > 0 && e == nil; retry++ {
+		context.progress.Retry(len(mismatched))
+
+		var retries, rv []datastore.Pair
+		retries, e = this.reapply(mismatched, items, context)
+		if len(retries) == 0 {
+			// Documents deleted or no longer qualifying are not updated
+			mismatched = nil
+			break
+		}
+
+		rv, mismatched, e = casKeyspace.UpdateCas(retries)
+		updated = append(updated, rv...)
+	}
+
+	if e == nil && len(mismatched) > 0 {
+		e = errors.NewUpdateCasMismatchError(mismatched, context.casRetries)
+	}
+
+	return updated, e
+}
+
+// Fetch the documents again, and re-apply the SET and UNSET of their
+// items to those that still satisfy the WHERE clause.
+func (this *SendUpdate) reapply(keys []string, items map[string]value.AnnotatedValue,
+	context *Context) ([]datastore.Pair, errors.Error) {
+	fetched, errs := this.plan.Keyspace().Fetch(keys)
+	if len(errs) > 0 {
+		return nil, errs[0]
+	}
+
+	alias := this.plan.Alias()
+	pairs := make([]datastore.Pair, 0, len(fetched))
+	for _, pair := range fetched {
+		item := items[pair.Key]
+		item.SetField(alias, pair.Value)
+
+		if where := this.plan.Where(); where != nil {
+			val, e := where.Evaluate(item, context)
+			if e != nil {
+				return nil, evaluationError(e, "filter", where, item)
+			}
+
+			if !val.Truth() {
+				continue
+			}
+		}
+
+		clone, ok := item.CopyForUpdate().(value.AnnotatedValue)
+		if !ok {
+			return nil, errors.NewUpdateMissingClone()
+		}
+
+		if set, ok := item.GetAttachment("set").(*algebra.Set); ok {
+			for _, t := range set.Terms() {
+				var e error
+				clone, e = setPath(t, clone, item, context)
+				if e != nil {
+					return nil, evaluationError(e, "SET clause", t.Value(), item)
+				}
+			}
+		}
+
+		if unset, ok := item.GetAttachment("unset").(*algebra.Unset); ok {
+			for _, t := range unset.Terms() {
+				unsetPath(t, clone, context)
+			}
+		}
+
+		cv, ok := clone.Field(alias)
+		if !ok {
+			return nil, errors.NewUpdateAliasMissingError(alias)
+		}
+
+		pairs = append(pairs, datastore.Pair{Key: pair.Key, Value: cv})
+		item.SetField(alias, cv)
+	}
+
+	return pairs, nil
+}
+
 func (this *SendUpdate) readonly() bool {
 	return false
 }
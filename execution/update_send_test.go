@@ -0,0 +1,110 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// casKeyspace reports the first update of each document as modified
+// concurrently, by the writer that makes it the fetched document.
+type casKeyspace struct {
+	datastore.Keyspace
+	fetched map[string]interface{}
+	updated []datastore.Pair
+}
+
+func (this *casKeyspace) Name() string {
+	return "orders"
+}
+
+func (this *casKeyspace) Fetch(keys []string) ([]datastore.AnnotatedPair, []errors.Error) {
+	rv := make([]datastore.AnnotatedPair, len(keys))
+	for i, key := range keys {
+		rv[i] = datastore.AnnotatedPair{Key: key, Value: value.NewAnnotatedValue(this.fetched[key])}
+	}
+	return rv, nil
+}
+
+func (this *casKeyspace) UpdateCas(updates []datastore.Pair) ([]datastore.Pair, []string, errors.Error) {
+	if this.updated == nil {
+		this.updated = []datastore.Pair{}
+		keys := make([]string, len(updates))
+		for i, pair := range updates {
+			keys[i] = pair.Key
+		}
+		return nil, keys, nil
+	}
+
+	this.updated = append(this.updated, updates...)
+	return updates, nil, nil
+}
+
+func TestUpdateCasWhere(t *testing.T) {
+	stmt, err := n1ql.ParseStatement(
+		"UPDATE orders o SET o.status = \"shipped\" WHERE o.status = \"pending\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	update := stmt.(*algebra.Update)
+	for _, concurrent := range []string{"pending", "cancelled"} {
+		keyspace := &casKeyspace{
+			fetched: map[string]interface{}{
+				"o1": map[string]interface{}{"status": concurrent, "n": 2},
+			},
+		}
+
+		context := NewContext("test", nil, nil, "default", false, 1, nil, nil, nil,
+			datastore.UNBOUNDED, nil, &viewOutput{})
+		context.SetCasRetries(1)
+
+		item := value.NewAnnotatedValue(map[string]interface{}{
+			"o": map[string]interface{}{"status": "pending", "n": 1},
+		})
+		item.SetAttachment("set", update.Set())
+
+		send := NewSendUpdate(plan.NewSendUpdate(keyspace, "o", nil, update.Where()), context)
+		send.batch = value.AnnotatedValues{item}
+
+		updated, e := send.update([]datastore.Pair{{Key: "o1", Value: value.NewValue(map[string]interface{}{
+			"status": "shipped", "n": 1,
+		})}}, context)
+		if e != nil {
+			t.Fatal(e)
+		}
+
+		if concurrent == "cancelled" {
+			if len(updated) != 0 {
+				t.Errorf("Expected no update of a document no longer pending, got %v", updated)
+			}
+			continue
+		}
+
+		if len(updated) != 1 {
+			t.Fatalf("Expected the pending document to be updated, got %v", updated)
+		}
+
+		if v, _ := updated[0].Value.Field("status"); v.Actual() != "shipped" {
+			t.Errorf("Expected the status to be shipped, got %v", v)
+		}
+
+		if v, _ := updated[0].Value.Field("n"); v.Actual() != 2.0 {
+			t.Errorf("Expected the concurrent update to be kept, got %v", v)
+		}
+	}
+}
@@ -25,9 +25,9 @@ type Set struct {
 	plan *plan.Set
 }
 
-func NewSet(plan *plan.Set) *Set {
+func NewSet(plan *plan.Set, context *Context) *Set {
 	rv := &Set{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -65,12 +65,18 @@ func (this *Set) processItem(item value.AnnotatedValue, context *Context) bool {
 	for _, t := range this.plan.Node().Terms() {
 		clone, e = setPath(t, clone, item, context)
 		if e != nil {
-			context.Error(errors.NewEvaluationError(e, "SET clause"))
+			context.Error(evaluationError(e, "SET clause", t.Value(), item))
 			return false
 		}
 	}
 
 	item.SetAttachment("clone", clone)
+
+	// Kept to re-apply to documents modified concurrently
+	if context.casRetries > 0 {
+		item.SetAttachment("set", this.plan.Node())
+	}
+
 	return this.sendItem(item)
 }
 
@@ -24,9 +24,9 @@ type Unset struct {
 	plan *plan.Unset
 }
 
-func NewUnset(plan *plan.Unset) *Unset {
+func NewUnset(plan *plan.Unset, context *Context) *Unset {
 	rv := &Unset{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -58,6 +58,11 @@ func (this *Unset) processItem(item value.AnnotatedValue, context *Context) bool
 		unsetPath(t, clone, context)
 	}
 
+	// Kept to re-apply to documents modified concurrently
+	if context.casRetries > 0 {
+		item.SetAttachment("unset", this.plan.Node())
+	}
+
 	return this.sendItem(item)
 }
 
@@ -21,12 +21,13 @@ import (
 
 type SendUpsert struct {
 	base
-	plan *plan.SendUpsert
+	plan     *plan.SendUpsert
+	pipeline sendPipeline
 }
 
-func NewSendUpsert(plan *plan.SendUpsert) *SendUpsert {
+func NewSendUpsert(plan *plan.SendUpsert, context *Context) *SendUpsert {
 	rv := &SendUpsert{
-		base: newBase(),
+		base: newBase(context),
 		plan: plan,
 	}
 
@@ -39,7 +40,7 @@ func (this *SendUpsert) Accept(visitor Visitor) (interface{}, error) {
 }
 
 func (this *SendUpsert) Copy() Operator {
-	return &SendUpsert{this.base.copy(), this.plan}
+	return &SendUpsert{base: this.base.copy(), plan: this.plan}
 }
 
 func (this *SendUpsert) RunOnce(context *Context, parent value.Value) {
@@ -47,11 +48,12 @@ func (this *SendUpsert) RunOnce(context *Context, parent value.Value) {
 }
 
 func (this *SendUpsert) processItem(item value.AnnotatedValue, context *Context) bool {
-	return this.enbatch(item, this, context)
+	return this.enbatchSize(item, this, context.MutationBatch(), context)
 }
 
 func (this *SendUpsert) afterItems(context *Context) {
 	this.flushBatch(context)
+	this.pipeline.wait()
 }
 
 func (this *SendUpsert) flushBatch(context *Context) bool {
@@ -61,8 +63,8 @@ func (this *SendUpsert) flushBatch(context *Context) bool {
 		return true
 	}
 
-	dpairs := _UPSERT_POOL.Get()
-	defer _UPSERT_POOL.Put(dpairs)
+	// Held until the batch is acknowledged
+	dpairs := make([]datastore.Pair, 0, len(this.batch))
 
 	keyExpr := this.plan.Key()
 	valExpr := this.plan.Value()
@@ -115,21 +117,42 @@ func (this *SendUpsert) flushBatch(context *Context) bool {
 			continue
 		}
 
+		if !checkPolicy(this.plan.Policy(), this.plan.Keyspace(), dpair.Key, val, context) {
+			continue
+		}
+
 		dpair.Value = val
 		i++
 	}
 
 	dpairs = dpairs[0:i]
 
+	// Documents hidden by the keyspace policy cannot be replaced
+	dpairs, ok = checkPolicyOverwrites(this.plan.Policy(), this.plan.Keyspace(), dpairs, context)
+	if !ok {
+		return false
+	}
+
+	if !context.chargeMutations(this.plan.Keyspace(), len(dpairs)) {
+		return false
+	}
+
+	return this.pipeline.send(func() bool { return this.send(dpairs, context) }, context)
+}
+
+// Send a batch to the keyspace, and the mutated documents on.
+func (this *SendUpsert) send(dpairs []datastore.Pair, context *Context) bool {
 	timer := time.Now()
 
 	// Perform the actual UPSERT
-	keys, e := this.plan.Keyspace().Upsert(dpairs)
+	keys, e := context.durableKeyspace(this.plan.Keyspace()).Upsert(dpairs)
 
 	context.AddPhaseTime("upsert", time.Since(timer))
 
 	// Update mutation count with number of upserted docs
 	context.AddMutationCount(uint64(len(keys)))
+	addKeyspaceMutations(this.plan.Keyspace(), uint64(len(keys)))
+	context.progress.Add(len(dpairs), len(keys))
 
 	if e != nil {
 		context.Error(e)
@@ -151,5 +174,3 @@ func (this *SendUpsert) flushBatch(context *Context) bool {
 func (this *SendUpsert) readonly() bool {
 	return false
 }
-
-var _UPSERT_POOL = datastore.NewPairPool(_BATCH_SIZE)
@@ -32,4 +32,5 @@ func copyOperator(op Operator) Operator {
 	}
 }
 
-var _STRING_POOL = util.NewStringPool(_BATCH_SIZE)
+// Key batches follow the per-request pipeline batch size
+var _STRING_POOL = util.NewStringClassPool(16, 16384)
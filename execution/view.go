@@ -0,0 +1,645 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package execution
+
+import (
+	"fmt"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+	"github.com/couchbase/query/view"
+)
+
+type CreateView struct {
+	base
+	plan *plan.CreateView
+}
+
+func NewCreateView(plan *plan.CreateView, context *Context) *CreateView {
+	rv := &CreateView{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *CreateView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateView(this)
+}
+
+func (this *CreateView) Copy() Operator {
+	return &CreateView{this.base.copy(), this.plan}
+}
+
+func (this *CreateView) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		err := createView(this.plan, context)
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+/*
+Register a view that is not materialized. Create the keyspace of a
+materialized view and fill it. A key-preserving materialized view is
+subscribed to the mutation feed of its source keyspace before it is
+filled, so that no mutation is missed, and is then refreshed from the
+feed until it is dropped.
+*/
+func createView(op *plan.CreateView, context *Context) errors.Error {
+	ns, name := op.Namespace(), op.Name()
+	if view.Get(ns, name) != nil {
+		return errors.NewViewExistsError(view.Key(ns, name))
+	}
+
+	namespace, err := context.Datastore().NamespaceByName(ns)
+	if err != nil {
+		return err
+	}
+
+	// Queries would read the view in place of the keyspace
+	if !op.Materialized() {
+		if _, err := namespace.KeyspaceByName(name); err == nil {
+			return errors.NewViewExistsError(view.Key(ns, name))
+		}
+
+		if !view.Add(view.NewVirtual(ns, name, op.Definition())) {
+			return errors.NewViewExistsError(view.Key(ns, name))
+		}
+
+		return nil
+	}
+
+	manager, ok := namespace.(datastore.KeyspaceManager)
+	if !ok {
+		return errors.NewOtherNotSupportedError(nil, "Materialized views are not supported by namespace "+ns)
+	}
+
+	keyspace, err := manager.CreateKeyspace(name)
+	if err != nil {
+		return err
+	}
+	datastore.InvalidateKeyspace(ns, name)
+
+	var feed datastore.MutationFeed
+	if op.KeyPreserving() {
+		feed, err = subscribeView(context.Datastore(), op.SourceNamespace(), op.SourceKeyspace())
+	}
+
+	if err == nil {
+		err = refreshView(context, keyspace, op.Definition())
+	}
+
+	if err != nil {
+		if feed != nil {
+			feed.Close()
+		}
+		manager.DropKeyspace(name)
+		return err
+	}
+
+	var stop func()
+	if feed != nil {
+		stop = feed.Close
+	}
+
+	v := view.New(ns, name, op.Definition(), op.Key(), feed != nil, stop)
+	if !view.Add(v) {
+		if feed != nil {
+			feed.Close()
+		}
+		manager.DropKeyspace(name)
+		return errors.NewViewExistsError(view.Key(ns, name))
+	}
+
+	if feed != nil {
+		go maintainView(v, keyspace, feed, context.Datastore(), context.Systemstore(),
+			context.Credentials())
+	}
+
+	return nil
+}
+
+/*
+Returns the mutation feed of the source keyspace, or nil if it has
+none, in which case the view is only refreshed on demand.
+*/
+func subscribeView(store datastore.Datastore, ns, ks string) (datastore.MutationFeed, errors.Error) {
+	namespace, err := store.NamespaceByName(ns)
+	if err != nil {
+		return nil, err
+	}
+
+	keyspace, err := namespace.KeyspaceByName(ks)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, ok := keyspace.(datastore.FeedKeyspace)
+	if !ok {
+		return nil, nil
+	}
+
+	return feeds.Subscribe()
+}
+
+type DropView struct {
+	base
+	plan *plan.DropView
+}
+
+func NewDropView(plan *plan.DropView, context *Context) *DropView {
+	rv := &DropView{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *DropView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropView(this)
+}
+
+func (this *DropView) Copy() Operator {
+	return &DropView{this.base.copy(), this.plan}
+}
+
+func (this *DropView) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		ns, name := this.plan.Namespace(), this.plan.Name()
+		v := view.Get(ns, name)
+		if v == nil {
+			context.Error(errors.NewViewNotFoundError(view.Key(ns, name)))
+			return
+		}
+
+		if v.Materialized() != this.plan.Materialized() {
+			context.Error(errors.NewViewTypeError(view.Key(ns, name), v.Materialized()))
+			return
+		}
+
+		view.Remove(ns, name)
+		if !v.Materialized() {
+			return
+		}
+
+		// Wait for any refresh in progress
+		_VIEW_WRITES.Lock()
+		defer _VIEW_WRITES.Unlock()
+
+		namespace, err := context.Datastore().NamespaceByName(ns)
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		if manager, ok := namespace.(datastore.KeyspaceManager); ok {
+			err = manager.DropKeyspace(name)
+			datastore.InvalidateKeyspace(ns, name)
+			if err != nil {
+				context.Error(err)
+			}
+		}
+	})
+}
+
+type RefreshView struct {
+	base
+	plan *plan.RefreshView
+}
+
+func NewRefreshView(plan *plan.RefreshView, context *Context) *RefreshView {
+	rv := &RefreshView{
+		base: newBase(context),
+		plan: plan,
+	}
+
+	rv.output = rv
+	return rv
+}
+
+func (this *RefreshView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRefreshView(this)
+}
+
+func (this *RefreshView) Copy() Operator {
+	return &RefreshView{this.base.copy(), this.plan}
+}
+
+func (this *RefreshView) RunOnce(context *Context, parent value.Value) {
+	this.once.Do(func() {
+		defer context.Recover()       // Recover from any panic
+		defer close(this.itemChannel) // Broadcast that I have stopped
+		defer this.notify()           // Notify that I have stopped
+
+		if context.Readonly() {
+			return
+		}
+
+		ns, name := this.plan.Namespace(), this.plan.Name()
+		v := view.Get(ns, name)
+		if v == nil {
+			context.Error(errors.NewViewNotFoundError(view.Key(ns, name)))
+			return
+		}
+
+		if !v.Materialized() {
+			context.Error(errors.NewViewTypeError(view.Key(ns, name), false))
+			return
+		}
+
+		namespace, err := context.Datastore().NamespaceByName(ns)
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		keyspace, err := namespace.KeyspaceByName(name)
+		if err != nil {
+			context.Error(err)
+			return
+		}
+
+		err = refreshView(context, keyspace, v.Definition())
+		if err != nil {
+			context.Error(err)
+		}
+	})
+}
+
+// Serializes the writes of refreshes, and drops of views.
+var _VIEW_WRITES sync.Mutex
+
+// The fields of the results of key-preserving definitions that hold
+// the keys of the documents, and the values of RAW projections.
+const (
+	_VIEW_KEY   = "#key"
+	_VIEW_VALUE = "#value"
+)
+
+/*
+The statements that compute the results of a view: the definition
+itself, or for a key-preserving definition, the definition projecting
+the key of each document as well, and its keyed variant, which only
+computes the results of the documents whose keys are $1.
+*/
+func viewStatements(definition string) (full, keyed algebra.Statement, err error) {
+	stmt, err := n1ql.ParseStatement(definition)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	sel, ok := stmt.(*algebra.Select)
+	if !ok {
+		return nil, nil, fmt.Errorf("Definition is not a SELECT.")
+	}
+
+	sub, term, er := view.KeyPreserving(sel)
+	if er != nil {
+		return stmt, nil, nil
+	}
+
+	key := algebra.NewResultTerm(expression.NewField(
+		expression.NewMeta(expression.NewIdentifier(term.Alias())),
+		expression.NewFieldName("id", false)), false, _VIEW_KEY)
+
+	proj := sub.Projection()
+	terms := make(algebra.ResultTerms, 0, len(proj.Terms())+1)
+	if proj.Raw() {
+		terms = append(terms, algebra.NewResultTerm(proj.Terms()[0].Expression(), false, _VIEW_VALUE))
+	} else {
+		terms = append(terms, proj.Terms()...)
+	}
+
+	projection := algebra.NewProjection(false, append(terms, key))
+	full, err = n1ql.ParseStatement(algebra.NewSubselect(term, sub.Let(), sub.Where(),
+		nil, projection).String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	keyedTerm := algebra.NewKeyspaceTerm(term.Namespace(), term.Keyspace(), term.Projection(),
+		term.As(), algebra.NewPositionalParameter(1), nil)
+	keyed, err = n1ql.ParseStatement(algebra.NewSubselect(keyedTerm, sub.Let(), sub.Where(),
+		nil, projection).String())
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return full, keyed, nil
+}
+
+/*
+Recompute the results of the view, with the credentials of the
+request, and replace the documents of its keyspace with them. The
+results of key-preserving definitions are stored under the keys of
+their documents, and the others under their ordinals.
+*/
+func refreshView(context *Context, keyspace datastore.Keyspace, definition string) errors.Error {
+	name := view.Key(keyspace.NamespaceId(), keyspace.Name())
+	full, _, err := viewStatements(definition)
+	if err != nil {
+		return errors.NewViewRefreshError(err, name)
+	}
+
+	results, e := evaluateView(full, context.Datastore(), context.Systemstore(),
+		keyspace.NamespaceId(), context.Credentials(), nil)
+	if e != nil {
+		return errors.NewViewRefreshError(e, name)
+	}
+
+	pairs := make([]datastore.Pair, 0, len(results))
+	keys := make(map[string]bool, len(results))
+	for i, result := range results {
+		key, doc, ok := viewDocument(result, strconv.Itoa(i+1))
+		if ok {
+			pairs = append(pairs, datastore.Pair{Key: key, Value: doc})
+			keys[key] = true
+		}
+	}
+
+	_VIEW_WRITES.Lock()
+	defer _VIEW_WRITES.Unlock()
+
+	// List the current documents, to delete those not in the results
+	existing, e := evaluateView(viewKeys(keyspace), context.Datastore(), context.Systemstore(),
+		keyspace.NamespaceId(), context.Credentials(), nil)
+	if e != nil {
+		return errors.NewViewRefreshError(e, name)
+	}
+
+	stale := make([]string, 0, len(existing))
+	for _, key := range existing {
+		if k, ok := key.Actual().(string); ok && !keys[k] {
+			stale = append(stale, k)
+		}
+	}
+
+	return writeView(keyspace, pairs, stale)
+}
+
+func viewKeys(keyspace datastore.Keyspace) algebra.Statement {
+	term := algebra.NewKeyspaceTerm(keyspace.NamespaceId(), keyspace.Name(), nil, "v", nil, nil)
+	sub := algebra.NewSubselect(term, nil, nil, nil, algebra.NewRawProjection(false,
+		expression.NewField(expression.NewMeta(expression.NewIdentifier("v")),
+			expression.NewFieldName("id", false)), ""))
+	stmt := algebra.NewSelect(sub, nil, nil, nil)
+	stmt.Formalize()
+	return stmt
+}
+
+/*
+Returns the key and the document of a result: the key projected by a
+key-preserving definition, or else the ordinal of the result.
+*/
+func viewDocument(result value.Value, ordinal string) (string, value.Value, bool) {
+	k, ok := result.Field(_VIEW_KEY)
+	if !ok {
+		return ordinal, result, true
+	}
+
+	key, ok := k.Actual().(string)
+	if !ok {
+		return "", nil, false
+	}
+
+	if v, ok := result.Field(_VIEW_VALUE); ok {
+		return key, v, v.Type() != value.MISSING
+	}
+
+	doc := result.CopyForUpdate()
+	doc.UnsetField(_VIEW_KEY)
+	return key, doc, true
+}
+
+func writeView(keyspace datastore.Keyspace, pairs []datastore.Pair, deletes []string) errors.Error {
+	if len(pairs) > 0 {
+		_, err := keyspace.Upsert(pairs)
+		if err != nil {
+			return err
+		}
+	}
+
+	for _, key := range deletes {
+		// Keys may already have been deleted
+		keyspace.Delete([]string{key})
+	}
+
+	return nil
+}
+
+// The number of mutated keys re-evaluated at once.
+const _VIEW_BATCH = 1024
+
+/*
+Refresh a key-preserving view from the mutation feed of its source
+keyspace, until the feed is closed by dropping the view. The mutated
+documents are re-evaluated in batches, with the credentials of the
+creator of the view.
+*/
+func maintainView(v *view.View, keyspace datastore.Keyspace, feed datastore.MutationFeed,
+	store, systemstore datastore.Datastore, credentials datastore.Credentials) {
+	name := view.Key(v.Namespace(), v.Name())
+	_, keyed, err := viewStatements(v.Definition())
+	if err != nil {
+		logging.Errorf("Materialized view %s: %v", name, err)
+		feed.Close()
+		return
+	}
+
+	mutations := feed.Mutations()
+	for m := range mutations {
+		keys := map[string]bool{m.Key: true}
+
+		// Batch the mutations already delivered
+	batch:
+		for len(keys) < _VIEW_BATCH {
+			select {
+			case m, ok := <-mutations:
+				if !ok {
+					break batch
+				}
+				keys[m.Key] = true
+			default:
+				break batch
+			}
+		}
+
+		keyArgs := make([]interface{}, 0, len(keys))
+		for key, _ := range keys {
+			keyArgs = append(keyArgs, key)
+		}
+
+		results, e := evaluateView(keyed, store, systemstore, v.Namespace(), credentials,
+			value.Values{value.NewValue(keyArgs)})
+		if e != nil {
+			logging.Errorf("Materialized view %s: %v", name, e)
+			continue
+		}
+
+		pairs := make([]datastore.Pair, 0, len(results))
+		for _, result := range results {
+			key, doc, ok := viewDocument(result, "")
+			if ok {
+				pairs = append(pairs, datastore.Pair{Key: key, Value: doc})
+				delete(keys, key)
+			}
+		}
+
+		// The documents without results no longer match
+		deletes := make([]string, 0, len(keys))
+		for key, _ := range keys {
+			deletes = append(deletes, key)
+		}
+
+		_VIEW_WRITES.Lock()
+		if view.Get(v.Namespace(), v.Name()) == v {
+			e = writeView(keyspace, pairs, deletes)
+		}
+		_VIEW_WRITES.Unlock()
+
+		if e != nil {
+			logging.Errorf("Materialized view %s: %v", name, e)
+		}
+	}
+
+	if e := feed.Err(); e != nil {
+		logging.Errorf("Materialized view %s: %v", name, e)
+	}
+}
+
+/*
+Compute the results of a view statement in a context of its own, as
+the refresh of a view may outlive the request that started it. Views
+are not read in place of their sources.
+*/
+func evaluateView(stmt algebra.Statement, store, systemstore datastore.Datastore, namespace string,
+	credentials datastore.Credentials, args value.Values) (value.Values, error) {
+	features := feature.Flags{feature.VIEW_REWRITE: false}
+	op, err := planner.BuildFeatures(stmt, store, systemstore, namespace, false,
+		policy.Applies(store, nil, credentials), features)
+	if err != nil {
+		return nil, err
+	}
+
+	out := &viewOutput{}
+	context := NewContext("view", store, systemstore, namespace, true, 1, nil, args,
+		credentials, datastore.UNBOUNDED, nil, out)
+	context.SetFeatures(features)
+	defer context.ReleaseSnapshots()
+
+	operator, err := Build(op, context)
+	if err != nil {
+		return nil, err
+	}
+
+	operator.RunOnce(context, nil)
+	if out.err != nil {
+		return nil, out.err
+	}
+
+	return out.results, nil
+}
+
+/*
+viewOutput collects the results and the first error of a view
+statement.
+*/
+type viewOutput struct {
+	sync.Mutex
+	results value.Values
+	err     errors.Error
+}
+
+func (this *viewOutput) Result(item value.Value) bool {
+	this.Lock()
+	defer this.Unlock()
+	this.results = append(this.results, item)
+	return true
+}
+
+func (this *viewOutput) CloseResults() {
+}
+
+func (this *viewOutput) Fatal(err errors.Error) {
+	this.Error(err)
+}
+
+func (this *viewOutput) Error(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *viewOutput) Warning(wrn errors.Error) {
+}
+
+func (this *viewOutput) AddMutationCount(i uint64) {
+}
+
+func (this *viewOutput) MutationCount() uint64 {
+	return 0
+}
+
+func (this *viewOutput) AchieveDurability(durability datastore.Durability) {
+}
+
+func (this *viewOutput) AchievedDurability() datastore.Durability {
+	return ""
+}
+
+func (this *viewOutput) SetSortCount(i uint64) {
+}
+
+func (this *viewOutput) SortCount() uint64 {
+	return 0
+}
+
+func (this *viewOutput) AddPhaseTime(phase string, duration time.Duration) {
+}
+
+func (this *viewOutput) PhaseTimes() map[string]time.Duration {
+	return nil
+}
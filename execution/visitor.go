@@ -16,6 +16,7 @@ type Visitor interface {
 	VisitIndexScan(op *IndexScan) (interface{}, error)
 	VisitKeyScan(op *KeyScan) (interface{}, error)
 	VisitValueScan(op *ValueScan) (interface{}, error)
+	VisitImportScan(op *ImportScan) (interface{}, error)
 	VisitDummyScan(op *DummyScan) (interface{}, error)
 	VisitCountScan(op *CountScan) (interface{}, error)
 	VisitIntersectScan(op *IntersectScan) (interface{}, error)
@@ -28,6 +29,7 @@ type Visitor interface {
 	VisitJoin(op *Join) (interface{}, error)
 	VisitNest(op *Nest) (interface{}, error)
 	VisitUnnest(op *Unnest) (interface{}, error)
+	VisitAntiJoin(op *AntiJoin) (interface{}, error)
 
 	// Let + Letting
 	VisitLet(op *Let) (interface{}, error)
@@ -84,6 +86,7 @@ type Visitor interface {
 	VisitSequence(op *Sequence) (interface{}, error)
 	VisitDiscard(op *Discard) (interface{}, error)
 	VisitStream(op *Stream) (interface{}, error)
+	VisitOutfile(op *Outfile) (interface{}, error)
 	VisitCollect(op *Collect) (interface{}, error)
 	VisitChannel(op *Channel) (interface{}, error)
 
@@ -94,9 +97,38 @@ type Visitor interface {
 	VisitAlterIndex(op *AlterIndex) (interface{}, error)
 	VisitBuildIndexes(op *BuildIndexes) (interface{}, error)
 
+	// Policy DDL
+	VisitCreatePolicy(op *CreatePolicy) (interface{}, error)
+	VisitDropPolicy(op *DropPolicy) (interface{}, error)
+	VisitCreateMask(op *CreateMask) (interface{}, error)
+	VisitDropMask(op *DropMask) (interface{}, error)
+
+	// Materialized views
+	VisitCreateView(op *CreateView) (interface{}, error)
+	VisitDropView(op *DropView) (interface{}, error)
+	VisitRefreshView(op *RefreshView) (interface{}, error)
+
+	// Temporary keyspaces
+	VisitCreateKeyspace(op *CreateKeyspace) (interface{}, error)
+	VisitDropKeyspace(op *DropKeyspace) (interface{}, error)
+
+	// Roles
+	VisitGrantRole(op *GrantRole) (interface{}, error)
+	VisitRevokeRole(op *RevokeRole) (interface{}, error)
+
+	// Session
+	VisitSessionSet(op *SessionSet) (interface{}, error)
+	VisitSetVariable(op *SetVariable) (interface{}, error)
+
 	// Explain
 	VisitExplain(op *Explain) (interface{}, error)
 
+	// Advise
+	VisitAdvise(op *Advise) (interface{}, error)
+
 	// Prepare
 	VisitPrepare(op *Prepare) (interface{}, error)
+
+	// Extensions
+	VisitExtension(op *Extension) (interface{}, error)
 }
@@ -109,7 +109,9 @@ func (this *ExpressionBase) Value() value.Value {
 }
 
 /*
-Returns a Constant or nil.
+Returns a Constant, or this expression if it only depends on
+constants and query parameters, or nil. An expression over parameters
+is evaluated when the query is executed, using the bound values.
 */
 func (this *ExpressionBase) Static() Expression {
 	v := this.expr.Value()
@@ -117,7 +119,18 @@ func (this *ExpressionBase) Static() Expression {
 		return NewConstant(v)
 	}
 
-	return nil
+	children := this.expr.Children()
+	if this.volatile || len(children) == 0 {
+		return nil
+	}
+
+	for _, child := range children {
+		if child.Static() == nil {
+			return nil
+		}
+	}
+
+	return this.expr
 }
 
 /*
@@ -68,14 +68,92 @@ with respect to the collection until it is true and return that
 element (after evaluation) as an array.
 */
 func (this *Array) Evaluate(item value.Value, context Context) (value.Value, error) {
+	barr, n, bv, err := this.bind(item, context)
+	if barr == nil {
+		return bv, err
+	}
+
+	rv := make([]interface{}, 0, n)
+	for i := 0; i < n; i++ {
+		cv, ok, e := this.element(item, context, barr, i)
+		if e != nil {
+			return nil, e
+		}
+
+		if !ok {
+			continue
+		}
+
+		mv, e := this.mapping.Evaluate(cv, context)
+		if e != nil {
+			return nil, e
+		}
+
+		if mv.Type() != value.MISSING {
+			rv = append(rv, mv)
+		}
+	}
+
+	return value.NewValue(rv), nil
+}
+
+/*
+Evaluate the Array range transform as an array index key, which
+indexes each distinct element of the array. An array mapping is
+itself an array index key, so that nested arrays are flattened, e.g.
+ARRAY (ARRAY w FOR w IN v.b END) FOR v IN a END indexes every w of
+every v. A MISSING or NULL collection is indexed as such.
+*/
+func (this *Array) EvaluateForIndex(item value.Value, context Context) (
+	value.Value, value.Values, error) {
+	barr, n, bv, err := this.bind(item, context)
+	if barr == nil {
+		return bv, nil, err
+	}
+
+	set := value.NewSet(n)
+	for i := 0; i < n; i++ {
+		cv, ok, e := this.element(item, context, barr, i)
+		if e != nil {
+			return nil, nil, e
+		}
+
+		if !ok {
+			continue
+		}
+
+		mv, mvs, e := this.mapping.EvaluateForIndex(cv, context)
+		if e != nil {
+			return nil, nil, e
+		}
+
+		if mvs == nil {
+			mvs = value.Values{mv}
+		}
+
+		for _, v := range mvs {
+			if v.Type() != value.MISSING {
+				set.Add(v)
+			}
+		}
+	}
+
+	return nil, set.Values(), nil
+}
+
+/*
+Evaluate the bindings. Returns nil and MISSING or NULL if any binding
+is MISSING or not an array, else the binding arrays and the number
+of elements to range over, which is the length of the shortest.
+*/
+func (this *Array) bind(item value.Value, context Context) ([][]interface{}, int, value.Value, error) {
 	missing := false
 	null := false
-
 	barr := make([][]interface{}, len(this.bindings))
 	for i, b := range this.bindings {
 		bv, err := b.Expression().Evaluate(item, context)
 		if err != nil {
-			return nil, err
+			return nil, 0, nil, err
 		}
 
 		if b.Descend() {
@@ -94,11 +172,11 @@ func (this *Array) Evaluate(item value.Value, context Context) (value.Value, err
 	}
 
 	if missing {
-		return value.MISSING_VALUE, nil
+		return nil, 0, value.MISSING_VALUE, nil
 	}
 
 	if null {
-		return value.NULL_VALUE, nil
+		return nil, 0, value.NULL_VALUE, nil
 	}
 
 	n := -1
@@ -108,35 +186,29 @@ func (this *Array) Evaluate(item value.Value, context Context) (value.Value, err
 		}
 	}
 
-	rv := make([]interface{}, 0, n)
-	for i := 0; i < n; i++ {
-		cv := value.NewScopeValue(make(map[string]interface{}, len(this.bindings)), item)
-		for j, b := range this.bindings {
-			cv.SetField(b.Variable(), barr[j][i])
-		}
-
-		if this.when != nil {
-			wv, e := this.when.Evaluate(cv, context)
-			if e != nil {
-				return nil, e
-			}
+	return barr, n, nil, nil
+}
 
-			if !wv.Truth() {
-				continue
-			}
-		}
+// Bind the i-th element, and report whether it satisfies the WHEN condition.
+func (this *Array) element(item value.Value, context Context, barr [][]interface{}, i int) (
+	value.Value, bool, error) {
+	cv := value.NewScopeValue(make(map[string]interface{}, len(this.bindings)), item)
+	for j, b := range this.bindings {
+		cv.SetField(b.Variable(), barr[j][i])
+	}
 
-		mv, e := this.mapping.Evaluate(cv, context)
+	if this.when != nil {
+		wv, e := this.when.Evaluate(cv, context)
 		if e != nil {
-			return nil, e
+			return nil, false, e
 		}
 
-		if mv.Type() != value.MISSING {
-			rv = append(rv, mv)
+		if !wv.Truth() {
+			return cv, false, nil
 		}
 	}
 
-	return value.NewValue(rv), nil
+	return cv, true, nil
 }
 
 func (this *Array) Copy() Expression {
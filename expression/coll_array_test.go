@@ -0,0 +1,64 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"sort"
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestArrayEvaluateForIndex(t *testing.T) {
+	// ARRAY (ARRAY y.c FOR y IN x.b END) FOR x IN a END
+	inner := NewArray(NewField(NewIdentifier("y"), NewFieldName("c", false)),
+		Bindings{NewBinding("y", NewField(NewIdentifier("x"), NewFieldName("b", false)))}, nil)
+	key := NewArray(inner, Bindings{NewBinding("x", NewIdentifier("a"))}, nil)
+
+	item := value.NewValue(map[string]interface{}{
+		"a": []interface{}{
+			map[string]interface{}{"b": []interface{}{
+				map[string]interface{}{"c": 1.0},
+				map[string]interface{}{"c": 2.0},
+			}},
+			map[string]interface{}{"b": []interface{}{
+				map[string]interface{}{"c": 2.0},
+				map[string]interface{}{"d": 4.0},
+				map[string]interface{}{"c": 3.0},
+			}},
+		},
+	})
+
+	v, vals, err := key.EvaluateForIndex(item, nil)
+	if err != nil || v != nil {
+		t.Fatalf("Expected index values, got %v (%v)", v, err)
+	}
+
+	actual := make([]float64, len(vals))
+	for i, val := range vals {
+		actual[i] = val.Actual().(float64)
+	}
+
+	sort.Float64s(actual)
+	if len(actual) != 3 || actual[0] != 1 || actual[1] != 2 || actual[2] != 3 {
+		t.Errorf("Expected distinct elements [1 2 3], got %v", actual)
+	}
+
+	v, vals, err = key.EvaluateForIndex(value.NewValue(map[string]interface{}{}), nil)
+	if err != nil || vals != nil || v.Type() != value.MISSING {
+		t.Errorf("Expected MISSING for a missing collection, got %v %v (%v)", v, vals, err)
+	}
+
+	// Evaluate is unchanged
+	v, err = key.Evaluate(item, nil)
+	if err != nil || len(v.Actual().([]interface{})) != 2 {
+		t.Errorf("Expected nested arrays, got %v (%v)", v, err)
+	}
+}
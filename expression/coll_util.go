@@ -75,6 +75,20 @@ func (this *collMap) Bindings() Bindings {
 	return this.bindings
 }
 
+/*
+Return receiver mapping.
+*/
+func (this *collMap) Mapping() Expression {
+	return this.mapping
+}
+
+/*
+Return receiver when condition, or nil.
+*/
+func (this *collMap) When() Expression {
+	return this.when
+}
+
 /*
 Type collPred represents a struct that implements ExpressionBase.
 It refers to the fields or attributes of a collection or map
@@ -123,3 +137,10 @@ Return receiver bindings.
 func (this *collPred) Bindings() Bindings {
 	return this.bindings
 }
+
+/*
+Return receiver satisfies condition.
+*/
+func (this *collPred) Satisfies() Expression {
+	return this.satisfies
+}
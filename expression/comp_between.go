@@ -59,6 +59,8 @@ func (this *Between) Evaluate(item value.Value, context Context) (value.Value, e
 }
 
 func (this *Between) Apply(context Context, item, low, high value.Value) (value.Value, error) {
+	warnMissing(this, context, item, low, high)
+
 	lowCmp := item.Compare(low)
 	if lowCmp.Type() == value.MISSING {
 		return lowCmp, nil
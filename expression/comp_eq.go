@@ -60,6 +60,8 @@ func (this *Eq) Evaluate(item value.Value, context Context) (value.Value, error)
 }
 
 func (this *Eq) Apply(context Context, first, second value.Value) (value.Value, error) {
+	warnMissing(this, context, first, second)
+
 	return first.Equals(second), nil
 }
 
@@ -76,6 +76,8 @@ value representing if first value is LIKE the second. If either of
 the input operands is MISSING or NULL, return MISSING or NULL.
 */
 func (this *Like) Apply(context Context, first, second value.Value) (value.Value, error) {
+	warnMissing(this, context, first, second)
+
 	if first.Type() == value.MISSING || second.Type() == value.MISSING {
 		return value.MISSING_VALUE, nil
 	} else if first.Type() != value.STRING || second.Type() != value.STRING {
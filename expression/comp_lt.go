@@ -60,6 +60,8 @@ func (this *LT) Evaluate(item value.Value, context Context) (value.Value, error)
 }
 
 func (this *LT) Apply(context Context, first, second value.Value) (value.Value, error) {
+	warnMissing(this, context, first, second)
+
 	cmp := first.Compare(second)
 	switch actual := cmp.Actual().(type) {
 	case float64:
@@ -11,14 +11,77 @@ package expression
 
 import (
 	"time"
+
+	"github.com/couchbase/query/value"
 )
 
 /*
-It imports the time package that provides the functionality
-to measure and display the time. The type Context is an
-interface that has a method Now that returns the Time that
-returns the instant it time with a nanosecond precision.
+Context is the context of expression evaluation. Now() is the
+instant of the statement being evaluated, captured when it starts:
+NOW_MILLIS() and NOW_STR() return it for all their evaluations,
+whereas the CLOCK_* functions read the clock.
 */
 type Context interface {
 	Now() time.Time
 }
+
+/*
+SeededContext is implemented by contexts that evaluate expressions
+deterministically, so that results are reproducible: the CLOCK_*
+functions are frozen at Now(), and RANDOM() and UUID() draw from a
+generator seeded per request.
+*/
+type SeededContext interface {
+	Context
+	Seeded() bool
+	RandomUint64() uint64
+}
+
+// The current time, frozen at Now() if the context is seeded.
+func clockNow(context Context) time.Time {
+	if sc, ok := context.(SeededContext); ok && sc.Seeded() {
+		return sc.Now()
+	}
+
+	return time.Now()
+}
+
+// The next random bits of a seeded context.
+func seededRandom(context Context) (uint64, bool) {
+	if sc, ok := context.(SeededContext); ok && sc.Seeded() {
+		return sc.RandomUint64(), true
+	}
+
+	return 0, false
+}
+
+/*
+MissingContext is implemented by contexts that warn of comparisons
+involving MISSING, whose results are MISSING rather than false.
+*/
+type MissingContext interface {
+	Context
+	WarnMissing(expr Expression)
+}
+
+// Warn of a comparison if any of its operands is MISSING.
+func warnMissing(expr Expression, context Context, operands ...value.Value) {
+	for _, op := range operands {
+		if op.Type() == value.MISSING {
+			if mc, ok := context.(MissingContext); ok {
+				mc.WarnMissing(expr)
+			}
+
+			return
+		}
+	}
+}
+
+/*
+CastContext is implemented by contexts that make CAST strict, so
+that an invalid conversion is an error rather than NULL.
+*/
+type CastContext interface {
+	Context
+	StrictCast() bool
+}
@@ -70,9 +70,10 @@ type Expression interface {
 
 	/*
 	   Static() returns the static / constant equivalent of this
-	   Expression, or nil. Expressions that depend on data,
-	   clocks, or random numbers must return nil. Used in index
-	   selection.
+	   Expression, or nil. Expressions over query parameters are
+	   static, and are evaluated at execution. Expressions that
+	   depend on data, clocks, or random numbers must return nil.
+	   Used in index selection.
 	*/
 	Static() Expression
 
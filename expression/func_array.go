@@ -189,6 +189,94 @@ func (this *ArrayAvg) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// ArrayBinarySearch
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the array function ARRAY_BINARY_SEARCH(expr, value).
+It returns the position of value within the array, which must be
+sorted in N1QL collation order, or -1. The position is 0-based.
+Type ArrayBinarySearch is a struct that implements
+BinaryFunctionBase.
+*/
+type ArrayBinarySearch struct {
+	BinaryFunctionBase
+}
+
+/*
+The function NewArrayBinarySearch calls NewBinaryFunctionBase
+to create a function named ARRAY_BINARY_SEARCH with the two
+expressions as input.
+*/
+func NewArrayBinarySearch(first, second Expression) Function {
+	rv := &ArrayBinarySearch{
+		*NewBinaryFunctionBase("array_binary_search", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ArrayBinarySearch) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a Number value.
+*/
+func (this *ArrayBinarySearch) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the Eval method for binary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ArrayBinarySearch) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+/*
+This method searches the sorted array (first value) for the
+second value, in logarithmic time. If the input values are of
+type missing return a missing value, and for all non array
+values return null. If not found then return -1. If the array
+is not sorted, the result is undefined.
+*/
+func (this *ArrayBinarySearch) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.ARRAY {
+		return value.NULL_VALUE, nil
+	}
+
+	fa := first.Actual().([]interface{})
+	i := sort.Search(len(fa), func(i int) bool {
+		return value.NewValue(fa[i]).Collate(second) >= 0
+	})
+
+	if i < len(fa) && value.NewValue(fa[i]).Collate(second) == 0 {
+		return value.NewValue(float64(i)), nil
+	}
+
+	return value.NewValue(float64(-1)), nil
+}
+
+/*
+The constructor returns a NewArrayBinarySearch with the operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ArrayBinarySearch) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewArrayBinarySearch(operands[0], operands[1])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // ArrayConcat
@@ -525,6 +613,131 @@ func (this *ArrayDistinct) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// ArrayFlatten
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the array function ARRAY_FLATTEN(expr, depth).
+It returns a new array with the elements of nested arrays
+inlined, down to depth levels of nesting. If depth is negative,
+all the levels are flattened. Type ArrayFlatten is a struct
+that implements BinaryFunctionBase.
+*/
+type ArrayFlatten struct {
+	BinaryFunctionBase
+}
+
+/*
+The function NewArrayFlatten calls NewBinaryFunctionBase to
+create a function named ARRAY_FLATTEN with the two
+expressions as input.
+*/
+func NewArrayFlatten(first, second Expression) Function {
+	rv := &ArrayFlatten{
+		*NewBinaryFunctionBase("array_flatten", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ArrayFlatten) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns an Array value.
+*/
+func (this *ArrayFlatten) Type() value.Type { return value.ARRAY }
+
+/*
+Calls the Eval method for binary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ArrayFlatten) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+/*
+This method flattens the first array value. If either of the
+input values are missing, return a missing value. If the first
+value is not an array, or the depth is not an integer, return
+a null value. An array without nested arrays is returned as is.
+*/
+func (this *ArrayFlatten) Apply(context Context, first, second value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.ARRAY || second.Type() != value.NUMBER {
+		return value.NULL_VALUE, nil
+	}
+
+	depth := second.Actual().(float64)
+	if depth != math.Trunc(depth) {
+		return value.NULL_VALUE, nil
+	}
+
+	fa := first.Actual().([]interface{})
+	ra, ok := flattenArray(fa, int(depth))
+	if !ok {
+		return first, nil
+	}
+
+	return value.NewValue(ra), nil
+}
+
+/*
+The constructor returns a NewArrayFlatten with the two operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ArrayFlatten) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewArrayFlatten(operands[0], operands[1])
+	}
+}
+
+/*
+Returns the flattened array, and false if there was nothing to
+flatten. The array is only copied once a nested array is found.
+*/
+func flattenArray(fa []interface{}, depth int) ([]interface{}, bool) {
+	if depth == 0 {
+		return fa, false
+	}
+
+	var ra []interface{}
+	for i, f := range fa {
+		fv := value.NewValue(f)
+		if fv.Type() != value.ARRAY {
+			if ra != nil {
+				ra = append(ra, f)
+			}
+			continue
+		}
+
+		if ra == nil {
+			ra = make([]interface{}, i, len(fa)<<1)
+			copy(ra, fa[:i])
+		}
+
+		nested := fv.Actual().([]interface{})
+		flat, _ := flattenArray(nested, depth-1)
+		ra = append(ra, flat...)
+	}
+
+	if ra == nil {
+		return fa, false
+	}
+
+	return ra, true
+}
+
 ///////////////////////////////////////////////////
 //
 // ArrayIfNull
@@ -863,6 +1076,136 @@ func (this *ArrayMin) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// ArrayMove
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the array function ARRAY_MOVE(expr, from, to).
+It returns a new array with the element at position from moved
+to position to, shifting the elements in between. Positions are
+0-based, and negative positions count from the end of the array.
+Type ArrayMove is a struct that implements TernaryFunctionBase.
+*/
+type ArrayMove struct {
+	TernaryFunctionBase
+}
+
+/*
+The function NewArrayMove calls NewTernaryFunctionBase to
+create a function named ARRAY_MOVE with the three
+expressions as input.
+*/
+func NewArrayMove(first, second, third Expression) Function {
+	rv := &ArrayMove{
+		*NewTernaryFunctionBase("array_move", first, second, third),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ArrayMove) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns an Array value.
+*/
+func (this *ArrayMove) Type() value.Type { return value.ARRAY }
+
+/*
+Calls the Eval method for ternary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ArrayMove) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.TernaryEval(this, item, context)
+}
+
+/*
+This method moves an element of the first array value. If any
+of the input values are missing, return a missing value. If
+the first value is not an array, or either position is not an
+integer within the array, return a null value. If the positions
+are the same, the array is returned as is.
+*/
+func (this *ArrayMove) Apply(context Context, first, second, third value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING ||
+		third.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.ARRAY {
+		return value.NULL_VALUE, nil
+	}
+
+	fa := first.Actual().([]interface{})
+	from, ok := arrayPosition(second, len(fa))
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	to, ok := arrayPosition(third, len(fa))
+	if !ok {
+		return value.NULL_VALUE, nil
+	}
+
+	if from == to {
+		return first, nil
+	}
+
+	ra := make([]interface{}, len(fa))
+	copy(ra, fa)
+	if from < to {
+		copy(ra[from:to], fa[from+1:to+1])
+	} else {
+		copy(ra[to+1:from+1], fa[to:from])
+	}
+
+	ra[to] = fa[from]
+	return value.NewValue(ra), nil
+}
+
+/*
+The constructor returns a NewArrayMove with the three operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ArrayMove) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewArrayMove(operands[0], operands[1], operands[2])
+	}
+}
+
+/*
+Returns the position given by an integer value in an array of
+length n, counting from the end if negative.
+*/
+func arrayPosition(pos value.Value, n int) (int, bool) {
+	if pos.Type() != value.NUMBER {
+		return 0, false
+	}
+
+	pf := pos.Actual().(float64)
+	if pf != math.Trunc(pf) {
+		return 0, false
+	}
+
+	p := int(pf)
+	if p < 0 {
+		p += n
+	}
+
+	if p < 0 || p >= n {
+		return 0, false
+	}
+
+	return p, true
+}
+
 ///////////////////////////////////////////////////
 //
 // ArrayPosition
@@ -0,0 +1,250 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+///////////////////////////////////////////////////
+//
+// Cast
+//
+///////////////////////////////////////////////////
+
+/*
+The target type of CAST(expr AS type). Type names follow ISO SQL,
+and several names denote the same type.
+*/
+type CastType string
+
+const (
+	CAST_STRING   CastType = "string"
+	CAST_NUMBER   CastType = "number"
+	CAST_INTEGER  CastType = "integer"
+	CAST_BOOLEAN  CastType = "boolean"
+	CAST_DATETIME CastType = "datetime"
+)
+
+var _CAST_TYPES = map[string]CastType{
+	"string":    CAST_STRING,
+	"varchar":   CAST_STRING,
+	"char":      CAST_STRING,
+	"text":      CAST_STRING,
+	"number":    CAST_NUMBER,
+	"numeric":   CAST_NUMBER,
+	"decimal":   CAST_NUMBER,
+	"double":    CAST_NUMBER,
+	"float":     CAST_NUMBER,
+	"real":      CAST_NUMBER,
+	"integer":   CAST_INTEGER,
+	"int":       CAST_INTEGER,
+	"bigint":    CAST_INTEGER,
+	"smallint":  CAST_INTEGER,
+	"boolean":   CAST_BOOLEAN,
+	"bool":      CAST_BOOLEAN,
+	"datetime":  CAST_DATETIME,
+	"timestamp": CAST_DATETIME,
+}
+
+/*
+This represents CAST(expr AS type), which converts a value to the
+target type according to the following matrix. Datetimes are
+strings in one of the date formats, and are cast to the default
+format.
+
+	from \ to  STRING      NUMBER        INTEGER       BOOLEAN        DATETIME
+	string     itself      parsed (1)    parsed (1,2)  "true"/"false" reformatted
+	number     formatted   itself        truncated     non-zero       from millis
+	boolean    formatted   1 or 0        1 or 0        itself         invalid
+	datetime   itself      millis        millis        invalid        itself
+	other      invalid     invalid       invalid       invalid        invalid
+
+(1) A string that is not a number, but is a datetime, is cast to its
+milliseconds since the epoch. (2) Fractions are truncated. Boolean
+strings are matched regardless of case. MISSING and NULL are cast to
+themselves.
+
+Invalid conversions return NULL, unless the context makes casts
+strict, in which case they are errors.
+*/
+type Cast struct {
+	UnaryFunctionBase
+	target CastType
+}
+
+/*
+The function NewCast returns a CAST of the operand to the named
+type, or an error if the type is not valid.
+*/
+func NewCast(operand Expression, typeName string) (*Cast, error) {
+	target, ok := _CAST_TYPES[strings.ToLower(typeName)]
+	if !ok {
+		return nil, fmt.Errorf("Invalid CAST type %s.", typeName)
+	}
+
+	return newCast(operand, target), nil
+}
+
+func newCast(operand Expression, target CastType) *Cast {
+	rv := &Cast{
+		*NewUnaryFunctionBase("cast", operand),
+		target,
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Cast) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+Returns the type of the target. Datetimes are strings.
+*/
+func (this *Cast) Type() value.Type {
+	switch this.target {
+	case CAST_NUMBER, CAST_INTEGER:
+		return value.NUMBER
+	case CAST_BOOLEAN:
+		return value.BOOLEAN
+	default:
+		return value.STRING
+	}
+}
+
+/*
+Returns the target type.
+*/
+func (this *Cast) Target() CastType {
+	return this.target
+}
+
+/*
+Casts to different types are not equivalent.
+*/
+func (this *Cast) EquivalentTo(other Expression) bool {
+	oc, ok := other.(*Cast)
+	return ok && oc.target == this.target && this.UnaryFunctionBase.EquivalentTo(other)
+}
+
+/*
+Calls the Eval method for unary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *Cast) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+func (this *Cast) Apply(context Context, arg value.Value) (value.Value, error) {
+	switch arg.Type() {
+	case value.MISSING, value.NULL:
+		return arg, nil
+	}
+
+	rv, ok := this.convert(arg)
+	if ok {
+		return value.NewValue(rv), nil
+	}
+
+	if cc, isCast := context.(CastContext); isCast && cc.StrictCast() {
+		bytes, _ := arg.MarshalJSON()
+		return nil, errors.NewInvalidCastError(string(bytes), string(this.target))
+	}
+
+	return value.NULL_VALUE, nil
+}
+
+func (this *Cast) convert(arg value.Value) (interface{}, bool) {
+	switch this.target {
+	case CAST_STRING:
+		switch arg.Type() {
+		case value.STRING:
+			return arg.Actual(), true
+		case value.NUMBER, value.BOOLEAN:
+			return fmt.Sprint(arg.Actual()), true
+		}
+	case CAST_NUMBER, CAST_INTEGER:
+		num, ok := castNumber(arg)
+		if ok && this.target == CAST_INTEGER {
+			num = math.Trunc(num)
+		}
+
+		return num, ok
+	case CAST_BOOLEAN:
+		switch a := arg.Actual().(type) {
+		case bool:
+			return a, true
+		case float64:
+			return a != 0, true
+		case string:
+			b, ok := map[string]bool{"true": true, "false": false}[strings.ToLower(a)]
+			return b, ok
+		}
+	case CAST_DATETIME:
+		switch a := arg.Actual().(type) {
+		case float64:
+			return timeToStr(millisToTime(a), _DEFAULT_FORMAT), true
+		case string:
+			t, err := strToTime(a)
+			if err == nil {
+				return timeToStr(t, _DEFAULT_FORMAT), true
+			}
+		}
+	}
+
+	return nil, false
+}
+
+func castNumber(arg value.Value) (float64, bool) {
+	switch a := arg.Actual().(type) {
+	case float64:
+		return a, true
+	case bool:
+		if a {
+			return 1, true
+		}
+
+		return 0, true
+	case string:
+		s := strings.TrimSpace(a)
+		f, err := strconv.ParseFloat(s, 64)
+		if err == nil && !math.IsNaN(f) && !math.IsInf(f, 0) {
+			return f, true
+		}
+
+		t, err := strToTime(s)
+		if err == nil {
+			return timeToMillis(t), true
+		}
+	}
+
+	return 0, false
+}
+
+/*
+The constructor returns a CAST to the same type.
+*/
+func (this *Cast) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return newCast(operands[0], this.target)
+	}
+}
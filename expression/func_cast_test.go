@@ -0,0 +1,80 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+type strictCastContext struct{}
+
+func (this *strictCastContext) Now() time.Time   { return time.Now() }
+func (this *strictCastContext) StrictCast() bool { return true }
+
+func TestCast(t *testing.T) {
+	tests := []struct {
+		arg      interface{}
+		typ      string
+		expected interface{}
+	}{
+		{"12.5", "number", 12.5},
+		{" 7 ", "DOUBLE", 7.0},
+		{"12.5", "int", 12.0},
+		{-3.9, "bigint", -3.0},
+		{true, "numeric", 1.0},
+		{"abc", "number", nil},
+		{12.5, "varchar", "12.5"},
+		{false, "string", "false"},
+		{[]interface{}{1.0}, "string", nil},
+		{"TRUE", "boolean", true},
+		{0.0, "bool", false},
+		{"yes", "boolean", nil},
+		{"2015-03-04T05:06:07Z", "timestamp", "2015-03-04T05:06:07Z"},
+		{"2015-03-04T05:06:07Z", "number", 1425445567000.0},
+		{true, "datetime", nil},
+	}
+
+	for _, test := range tests {
+		cast, err := NewCast(NewConstant(test.arg), test.typ)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		v, err := cast.Evaluate(nil, nil)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %v as %s", err, test.arg, test.typ)
+			continue
+		}
+
+		if !v.Equals(value.NewValue(test.expected)).Truth() && v.Actual() != test.expected {
+			t.Errorf("Expected %v for %v as %s, got %v", test.expected, test.arg, test.typ, v)
+		}
+
+		if test.expected == nil {
+			_, err = cast.Evaluate(nil, &strictCastContext{})
+			if err == nil {
+				t.Errorf("Expected error for %v as %s in strict mode", test.arg, test.typ)
+			}
+		}
+	}
+
+	_, err := NewCast(NewConstant(1.0), "blob")
+	if err == nil {
+		t.Errorf("Expected error for CAST to an invalid type")
+	}
+
+	cast, _ := NewCast(NewIdentifier("a"), "Int")
+	if s := cast.String(); s != "cast(`a` as integer)" {
+		t.Errorf("Unexpected text %s", s)
+	}
+}
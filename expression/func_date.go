@@ -12,6 +12,7 @@ package expression
 import (
 	"fmt"
 	"math"
+	"strconv"
 	"strings"
 	"time"
 
@@ -69,7 +70,7 @@ order to convert it to milliseconds, divide it by
 10^6.
 */
 func (this *ClockMillis) Evaluate(item value.Value, context Context) (value.Value, error) {
-	nanos := time.Now().UnixNano()
+	nanos := clockNow(context).UnixNano()
 	return value.NewValue(float64(nanos) / (1000000.0)), nil
 }
 
@@ -165,7 +166,7 @@ func (this *ClockStr) Apply(context Context, args ...value.Value) (value.Value,
 		fmt = fv.Actual().(string)
 	}
 
-	return value.NewValue(timeToStr(time.Now(), fmt)), nil
+	return value.NewValue(timeToStr(clockNow(context), fmt)), nil
 }
 
 /*
@@ -185,6 +186,101 @@ Returns receiver as FunctionConstructor.
 */
 func (this *ClockStr) Constructor() FunctionConstructor { return NewClockStr }
 
+///////////////////////////////////////////////////
+//
+// DateAddInterval
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function DATE_ADD_INTERVAL(date, millis).
+It adds an interval in milliseconds to a date, which is either an
+ISO 8601 timestamp string or a UNIX timestamp in milliseconds. It
+is also the expression for date + INTERVAL literals. Type
+DateAddInterval is a struct that implements BinaryFunctionBase.
+*/
+type DateAddInterval struct {
+	BinaryFunctionBase
+}
+
+/*
+The function NewDateAddInterval calls NewBinaryFunctionBase to
+create a function named DATE_ADD_INTERVAL with the two
+expressions as input.
+*/
+func NewDateAddInterval(first, second Expression) Function {
+	rv := &DateAddInterval{
+		*NewBinaryFunctionBase("date_add_interval", first, second),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *DateAddInterval) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value type JSON, since the result is a string or a
+number, depending on the date.
+*/
+func (this *DateAddInterval) Type() value.Type { return value.JSON }
+
+/*
+Calls the Eval method for binary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *DateAddInterval) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.BinaryEval(this, item, context)
+}
+
+/*
+This method takes a date and an interval in milliseconds. If either
+is missing, return missing; if the interval is not a number, return
+null. A number date is added to the interval. A string date is
+parsed, and the result is formatted in the format of the date. Any
+other date returns null.
+*/
+func (this *DateAddInterval) Apply(context Context, date, millis value.Value) (value.Value, error) {
+	if date.Type() == value.MISSING || millis.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if millis.Type() != value.NUMBER {
+		return value.NULL_VALUE, nil
+	}
+
+	ms := millis.Actual().(float64)
+
+	switch date.Type() {
+	case value.NUMBER:
+		return value.NewValue(date.Actual().(float64) + ms), nil
+	case value.STRING:
+		t, fmt, err := strToTimeFormat(date.Actual().(string))
+		if err != nil {
+			return value.NULL_VALUE, nil
+		}
+
+		t = t.Add(time.Duration(ms * 1000000.0))
+		return value.NewValue(timeToStr(t, fmt)), nil
+	default:
+		return value.NULL_VALUE, nil
+	}
+}
+
+/*
+The constructor returns a NewDateAddInterval with the two operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *DateAddInterval) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewDateAddInterval(operands[0], operands[1])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // DateAddMillis
@@ -926,6 +1022,87 @@ func (this *DateTruncStr) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// IntervalToMillis
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the Date function INTERVAL_TO_MILLIS(expr). It
+converts an interval string such as 1h30m into milliseconds. It is
+also the expression for INTERVAL literals. Type IntervalToMillis is
+a struct that implements UnaryFunctionBase.
+*/
+type IntervalToMillis struct {
+	UnaryFunctionBase
+}
+
+/*
+The function NewIntervalToMillis calls NewUnaryFunctionBase to
+create a function named INTERVAL_TO_MILLIS with the an
+expression as input.
+*/
+func NewIntervalToMillis(operand Expression) Function {
+	rv := &IntervalToMillis{
+		*NewUnaryFunctionBase("interval_to_millis", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *IntervalToMillis) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns value type number.
+*/
+func (this *IntervalToMillis) Type() value.Type { return value.NUMBER }
+
+/*
+Calls the Eval method for unary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *IntervalToMillis) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+/*
+This method takes in an interval string and returns its length in
+milliseconds. If the input is missing, return missing; if it is
+not a valid interval string, return null.
+*/
+func (this *IntervalToMillis) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	millis, err := ParseInterval(arg.Actual().(string))
+	if err != nil {
+		return value.NULL_VALUE, nil
+	}
+
+	return value.NewValue(millis), nil
+}
+
+/*
+The constructor returns a NewIntervalToMillis with an operand cast to a
+Function as the FunctionConstructor.
+*/
+func (this *IntervalToMillis) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewIntervalToMillis(operands[0])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // MillisToStr
@@ -1718,6 +1895,64 @@ func timeToMillis(t time.Time) float64 {
 	return float64(t.UnixNano() / 1000000)
 }
 
+/*
+Parse an interval string, such as 1h30m or 2d, and return its length
+in milliseconds. An interval is a sequence of numbers, each followed
+by a unit: w, d, h, m, s or ms. It may be preceded by a minus sign.
+Days and weeks are fixed multiples of 24 hours.
+*/
+func ParseInterval(s string) (float64, error) {
+	str := strings.ToLower(strings.TrimSpace(s))
+	sign := 1.0
+	if strings.HasPrefix(str, "-") {
+		sign, str = -1.0, str[1:]
+	}
+
+	if str == "" {
+		return 0, fmt.Errorf("Invalid interval %s.", s)
+	}
+
+	millis := 0.0
+	for str != "" {
+		i := 0
+		for i < len(str) && (str[i] == '.' || (str[i] >= '0' && str[i] <= '9')) {
+			i++
+		}
+
+		j := i
+		for j < len(str) && str[j] >= 'a' && str[j] <= 'z' {
+			j++
+		}
+
+		n, err := strconv.ParseFloat(str[:i], 64)
+		if err != nil {
+			return 0, fmt.Errorf("Invalid interval %s.", s)
+		}
+
+		unit, ok := _INTERVAL_UNITS[str[i:j]]
+		if !ok {
+			return 0, fmt.Errorf("Invalid interval unit in %s.", s)
+		}
+
+		millis += n * unit
+		str = str[j:]
+	}
+
+	return sign * millis, nil
+}
+
+/*
+Milliseconds per interval unit.
+*/
+var _INTERVAL_UNITS = map[string]float64{
+	"ms": 1,
+	"s":  1000,
+	"m":  60 * 1000,
+	"h":  60 * 60 * 1000,
+	"d":  24 * 60 * 60 * 1000,
+	"w":  7 * 24 * 60 * 60 * 1000,
+}
+
 /*
 Variable that represents different date formats.
 */
@@ -0,0 +1,41 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"testing"
+)
+
+func TestParseInterval(t *testing.T) {
+	valid := map[string]float64{
+		"500ms":  500,
+		"1h30m":  5400000,
+		"1d":     86400000,
+		"-2w":    -1209600000,
+		"1.5s":   1500,
+		" 10M  ": 600000,
+	}
+
+	for s, expected := range valid {
+		millis, err := ParseInterval(s)
+		if err != nil {
+			t.Errorf("Unexpected error parsing %s: %v", s, err)
+		} else if millis != expected {
+			t.Errorf("Expected %v for %s, got %v", expected, s, millis)
+		}
+	}
+
+	for _, s := range []string{"", "-", "1", "h", "1x", "1h-30m"} {
+		_, err := ParseInterval(s)
+		if err == nil {
+			t.Errorf("Expected error parsing %s", s)
+		}
+	}
+}
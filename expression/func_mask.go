@@ -0,0 +1,158 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"math"
+	"strings"
+
+	"github.com/couchbase/query/value"
+)
+
+///////////////////////////////////////////////////
+//
+// Mask
+//
+///////////////////////////////////////////////////
+
+// Mask types
+const (
+	MASK_FULL    = "full"    // Every character is replaced by X
+	MASK_PARTIAL = "partial" // All but the last n characters, 4 by default
+	MASK_EMAIL   = "email"   // All of the local part but its first character
+	MASK_NULL    = "null"    // The value is replaced by NULL
+)
+
+const _MASK_CHAR = 'X'
+const _MASK_PARTIAL_KEEP = 4
+
+func IsMaskType(maskType string) bool {
+	switch maskType {
+	case MASK_FULL, MASK_PARTIAL, MASK_EMAIL, MASK_NULL:
+		return true
+	default:
+		return false
+	}
+}
+
+/*
+This represents the function MASK(expr, type [, n]). It hides
+all or part of a string, according to the mask type. Values
+other than strings are masked as NULL. Type Mask is a struct
+that implements FunctionBase.
+*/
+type Mask struct {
+	FunctionBase
+}
+
+/*
+The function NewMask calls NewFunctionBase to create a
+function named MASK with input arguments as the operands
+from the input expression.
+*/
+func NewMask(operands ...Expression) Function {
+	rv := &Mask{
+		*NewFunctionBase("mask", operands...),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *Mask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+func (this *Mask) Type() value.Type { return value.STRING }
+
+func (this *Mask) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.Eval(this, item, context)
+}
+
+/*
+Returns MISSING if the value is missing, and NULL if the value is
+not a string, or if the type or the number of characters kept is
+invalid, so that an invalid mask never reveals the value.
+*/
+func (this *Mask) Apply(context Context, args ...value.Value) (value.Value, error) {
+	if args[0].Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if args[0].Type() != value.STRING || args[1].Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	keep := _MASK_PARTIAL_KEEP
+	if len(args) > 2 {
+		if args[2].Type() != value.NUMBER {
+			return value.NULL_VALUE, nil
+		}
+
+		kf := args[2].Actual().(float64)
+		if kf < 0 || kf != math.Trunc(kf) {
+			return value.NULL_VALUE, nil
+		}
+
+		keep = int(kf)
+	}
+
+	str := args[0].Actual().(string)
+
+	switch args[1].Actual().(string) {
+	case MASK_FULL:
+		return value.NewValue(maskRunes(str, 0)), nil
+	case MASK_PARTIAL:
+		return value.NewValue(maskRunes(str, keep)), nil
+	case MASK_EMAIL:
+		at := strings.LastIndex(str, "@")
+		if at < 0 {
+			return value.NewValue(maskRunes(str, 0)), nil
+		}
+
+		local := []rune(str[:at])
+		for i := 1; i < len(local); i++ {
+			local[i] = _MASK_CHAR
+		}
+
+		return value.NewValue(string(local) + str[at:]), nil
+	default:
+		return value.NULL_VALUE, nil
+	}
+}
+
+/*
+Minimum input arguments required for the MASK function
+is 2.
+*/
+func (this *Mask) MinArgs() int { return 2 }
+
+/*
+Maximum input arguments required for the MASK function
+is 3.
+*/
+func (this *Mask) MaxArgs() int { return 3 }
+
+/*
+Return NewMask as FunctionConstructor.
+*/
+func (this *Mask) Constructor() FunctionConstructor { return NewMask }
+
+// Replaces every character but the last keep.
+func maskRunes(str string, keep int) string {
+	runes := []rune(str)
+	for i := 0; i < len(runes)-keep; i++ {
+		runes[i] = _MASK_CHAR
+	}
+
+	return string(runes)
+}
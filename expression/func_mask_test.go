@@ -0,0 +1,56 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package expression
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestMask(t *testing.T) {
+	tests := []struct {
+		args     []interface{}
+		expected interface{}
+	}{
+		{[]interface{}{"4111222233334444", MASK_PARTIAL}, "XXXXXXXXXXXX4444"},
+		{[]interface{}{"4111222233334444", MASK_PARTIAL, 2.0}, "XXXXXXXXXXXXXX44"},
+		{[]interface{}{"abc", MASK_PARTIAL}, "abc"},
+		{[]interface{}{"süß", MASK_FULL}, "XXX"},
+		{[]interface{}{"joe@example.com", MASK_EMAIL}, "jXX@example.com"},
+		{[]interface{}{"joe", MASK_EMAIL}, "XXX"},
+		{[]interface{}{"joe", MASK_NULL}, nil},
+		{[]interface{}{"joe", "bogus"}, nil},
+		{[]interface{}{1234.0, MASK_FULL}, nil},
+		{[]interface{}{"joe", MASK_PARTIAL, 1.5}, nil},
+	}
+
+	for _, test := range tests {
+		operands := make(Expressions, len(test.args))
+		for i, arg := range test.args {
+			operands[i] = NewConstant(arg)
+		}
+
+		v, err := NewMask(operands...).Evaluate(nil, nil)
+		if err != nil {
+			t.Errorf("Unexpected error %v for %v", err, test.args)
+			continue
+		}
+
+		if !v.Equals(value.NewValue(test.expected)).Truth() && v.Actual() != test.expected {
+			t.Errorf("Expected %v for %v, got %v", test.expected, test.args, v)
+		}
+	}
+
+	v, _ := NewMask(NewConstant(value.MISSING_VALUE), NewConstant(MASK_FULL)).Evaluate(nil, nil)
+	if v.Type() != value.MISSING {
+		t.Errorf("Expected MISSING, got %v", v)
+	}
+}
@@ -226,9 +226,15 @@ func (this *Uuid) Type() value.Type { return value.STRING }
 /*
 Generate a Version 4 UUID as specified in RFC 4122, wrap it in a value
 and return it. The UUID() function may return an error, if so return
-a nil value UUID with the error.
+a nil value UUID with the error. Seeded contexts give reproducible
+UUIDs; see SeededContext.
 */
 func (this *Uuid) Evaluate(item value.Value, context Context) (value.Value, error) {
+	if hi, ok := seededRandom(context); ok {
+		lo, _ := seededRandom(context)
+		return value.NewValue(util.UUIDFromBits(hi, lo)), nil
+	}
+
 	u, err := util.UUID()
 	if err != nil {
 		return nil, err
@@ -2,8 +2,10 @@ package expression
 
 import (
 	"fmt"
+	"math/rand"
 	"regexp"
 	"testing"
+	"time"
 
 	"github.com/couchbase/query/util"
 )
@@ -35,3 +37,49 @@ func TestNewV4_eval(t *testing.T) {
 	fmt.Printf("\t UUID:  %v \n", u.Actual())
 
 }
+
+type seededContext struct {
+	random *rand.Rand
+}
+
+func (this *seededContext) Now() time.Time       { return time.Unix(0, 0).UTC() }
+func (this *seededContext) Seeded() bool         { return true }
+func (this *seededContext) RandomUint64() uint64 { return this.random.Uint64() }
+
+func TestSeededContext(t *testing.T) {
+	exprs := Expressions{NewUuid(), NewRandom(), NewClockMillis(), NewClockStr()}
+
+	eval := func(seed int64) []interface{} {
+		context := &seededContext{rand.New(rand.NewSource(seed))}
+		rv := make([]interface{}, len(exprs))
+		for i, expr := range exprs {
+			val, err := expr.Evaluate(nil, context)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			rv[i] = val.Actual()
+		}
+
+		return rv
+	}
+
+	first, second, other := eval(42), eval(42), eval(43)
+	for i, expr := range exprs {
+		if first[i] != second[i] {
+			t.Errorf("Expected %v to be reproducible, got %v and %v", expr, first[i], second[i])
+		}
+	}
+
+	if !parseUUIDRegex.MatchString(first[0].(string)) {
+		t.Errorf("Expected string representation to be valid, given: %s", first[0])
+	}
+
+	if first[0] == other[0] || first[1] == other[1] {
+		t.Errorf("Expected other seeds to give other values, got %v and %v", first, other)
+	}
+
+	if first[2] != 0.0 || first[3] != "1970-01-01T00:00:00Z" {
+		t.Errorf("Expected the clock frozen at the epoch, got %v", first[2:])
+	}
+}
@@ -1535,7 +1535,8 @@ func (this *Random) Evaluate(item value.Value, context Context) (value.Value, er
 /*
 This method evaluates the Random function. If the seed exists, then
 return it as a value. If there are no input arguments, return
-a random number. If the input argument type is Missing, return a
+a random number, drawn from the generator of seeded contexts; see
+SeededContext. If the input argument type is Missing, return a
 missing value, and if it is not a number then return a null value.
 For numbers, check if it is an integer value and if not return
 null. Generate a new random number using this integer value as a
@@ -1547,6 +1548,10 @@ func (this *Random) Apply(context Context, args ...value.Value) (value.Value, er
 	}
 
 	if len(args) == 0 {
+		if bits, ok := seededRandom(context); ok {
+			return value.NewValue(float64(bits>>11) / (1 << 53)), nil
+		}
+
 		return value.NewValue(rand.Float64()), nil
 	}
 
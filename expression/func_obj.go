@@ -11,6 +11,8 @@ package expression
 
 import (
 	"sort"
+	"strconv"
+	"strings"
 
 	"github.com/couchbase/query/value"
 )
@@ -280,6 +282,347 @@ func (this *ObjectPairs) Constructor() FunctionConstructor {
 	}
 }
 
+///////////////////////////////////////////////////
+//
+// ObjectPaths
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the object function OBJECT_PATHS(expr).
+It returns an array containing the paths of the leaf
+values of the object, in N1QL collation order of the
+names at each level. Nested attributes are separated by
+dots and array elements are subscripted, as in a.b[0].c;
+names that are not identifiers are escaped with backticks.
+Empty objects and arrays are leaves. Type ObjectPaths is a
+struct that implements UnaryFunctionBase.
+*/
+type ObjectPaths struct {
+	UnaryFunctionBase
+}
+
+/*
+The function NewObjectPaths calls NewUnaryFunctionBase to
+create a function named OBJECT_PATHS with an expression as
+input.
+*/
+func NewObjectPaths(operand Expression) Function {
+	rv := &ObjectPaths{
+		*NewUnaryFunctionBase("object_paths", operand),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ObjectPaths) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value type ARRAY.
+*/
+func (this *ObjectPaths) Type() value.Type { return value.ARRAY }
+
+/*
+Calls the Eval method for unary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ObjectPaths) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.UnaryEval(this, item, context)
+}
+
+/*
+This method returns the leaf paths of the object. If the type
+of input is missing then return a missing value, and if not an
+object return a null value.
+*/
+func (this *ObjectPaths) Apply(context Context, arg value.Value) (value.Value, error) {
+	if arg.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if arg.Type() != value.OBJECT {
+		return value.NULL_VALUE, nil
+	}
+
+	ra := objectPaths("", arg.Actual(), make([]interface{}, 0, 16))
+	return value.NewValue(ra), nil
+}
+
+/*
+The constructor returns a NewObjectPaths with the an operand
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ObjectPaths) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewObjectPaths(operands[0])
+	}
+}
+
+func objectPaths(prefix string, val interface{}, paths []interface{}) []interface{} {
+	switch val := value.NewValue(val).Actual().(type) {
+	case map[string]interface{}:
+		if len(val) > 0 {
+			keys := make(sort.StringSlice, 0, len(val))
+			for key, _ := range val {
+				keys = append(keys, key)
+			}
+
+			sort.Sort(keys)
+			for _, key := range keys {
+				name := pathName(key)
+				if prefix != "" {
+					name = prefix + "." + name
+				}
+
+				paths = objectPaths(name, val[key], paths)
+			}
+
+			return paths
+		}
+	case []interface{}:
+		if len(val) > 0 {
+			for i, v := range val {
+				paths = objectPaths(prefix+"["+strconv.Itoa(i)+"]", v, paths)
+			}
+
+			return paths
+		}
+	}
+
+	if prefix == "" {
+		return paths
+	}
+
+	return append(paths, prefix)
+}
+
+/*
+Escape names that cannot be written as identifiers.
+*/
+func pathName(name string) string {
+	for i, c := range name {
+		if c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') ||
+			(i > 0 && c >= '0' && c <= '9') {
+			continue
+		}
+
+		return "`" + strings.Replace(name, "`", "``", -1) + "`"
+	}
+
+	if name == "" {
+		return "``"
+	}
+
+	return name
+}
+
+///////////////////////////////////////////////////
+//
+// ObjectRename
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the object function OBJECT_RENAME(expr,
+old_name, new_name). It returns a new object with the
+attribute old_name renamed to new_name, replacing any
+attribute named new_name. Type ObjectRename is a struct
+that implements TernaryFunctionBase.
+*/
+type ObjectRename struct {
+	TernaryFunctionBase
+}
+
+/*
+The function NewObjectRename calls NewTernaryFunctionBase to
+create a function named OBJECT_RENAME with the three
+expressions as input.
+*/
+func NewObjectRename(first, second, third Expression) Function {
+	rv := &ObjectRename{
+		*NewTernaryFunctionBase("object_rename", first, second, third),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ObjectRename) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value type OBJECT.
+*/
+func (this *ObjectRename) Type() value.Type { return value.OBJECT }
+
+/*
+Calls the Eval method for ternary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ObjectRename) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.TernaryEval(this, item, context)
+}
+
+/*
+This method returns a copy of the object with the attribute
+renamed. If any input is missing then return a missing value,
+and if the first input is not an object, or the names are not
+strings, return a null value. If the object has no attribute
+old_name, it is returned unchanged.
+*/
+func (this *ObjectRename) Apply(context Context, first, second, third value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING || second.Type() == value.MISSING ||
+		third.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.OBJECT || second.Type() != value.STRING ||
+		third.Type() != value.STRING {
+		return value.NULL_VALUE, nil
+	}
+
+	oldName := second.Actual().(string)
+	newName := third.Actual().(string)
+
+	oa := first.Actual().(map[string]interface{})
+	val, ok := oa[oldName]
+	if !ok || oldName == newName {
+		return first, nil
+	}
+
+	ra := make(map[string]interface{}, len(oa))
+	for k, v := range oa {
+		if k != oldName {
+			ra[k] = v
+		}
+	}
+
+	ra[newName] = val
+	return value.NewValue(ra), nil
+}
+
+/*
+The constructor returns a NewObjectRename with the three operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ObjectRename) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewObjectRename(operands[0], operands[1], operands[2])
+	}
+}
+
+///////////////////////////////////////////////////
+//
+// ObjectReplace
+//
+///////////////////////////////////////////////////
+
+/*
+This represents the object function OBJECT_REPLACE(expr,
+old_value, new_value). It returns a new object with all
+attribute values equal to old_value replaced with new_value.
+Type ObjectReplace is a struct that implements
+TernaryFunctionBase.
+*/
+type ObjectReplace struct {
+	TernaryFunctionBase
+}
+
+/*
+The function NewObjectReplace calls NewTernaryFunctionBase to
+create a function named OBJECT_REPLACE with the three
+expressions as input.
+*/
+func NewObjectReplace(first, second, third Expression) Function {
+	rv := &ObjectReplace{
+		*NewTernaryFunctionBase("object_replace", first, second, third),
+	}
+
+	rv.expr = rv
+	return rv
+}
+
+/*
+It calls the VisitFunction method by passing in the receiver to
+and returns the interface. It is a visitor pattern.
+*/
+func (this *ObjectReplace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitFunction(this)
+}
+
+/*
+It returns a value type OBJECT.
+*/
+func (this *ObjectReplace) Type() value.Type { return value.OBJECT }
+
+/*
+Calls the Eval method for ternary functions and passes in the
+receiver, current item and current context.
+*/
+func (this *ObjectReplace) Evaluate(item value.Value, context Context) (value.Value, error) {
+	return this.TernaryEval(this, item, context)
+}
+
+/*
+A missing or null old_value matches no attribute, and the
+object is returned unchanged.
+*/
+func (this *ObjectReplace) PropagatesMissing() bool {
+	return false
+}
+
+func (this *ObjectReplace) PropagatesNull() bool {
+	return false
+}
+
+/*
+This method returns a copy of the object with the matching
+attribute values replaced. If the type of the first input is
+missing then return a missing value, and if not an object
+return a null value. Attributes are compared as in
+ARRAY_REPLACE. If new_value is missing, the matching
+attributes are removed.
+*/
+func (this *ObjectReplace) Apply(context Context, first, second, third value.Value) (value.Value, error) {
+	if first.Type() == value.MISSING {
+		return value.MISSING_VALUE, nil
+	} else if first.Type() != value.OBJECT {
+		return value.NULL_VALUE, nil
+	} else if second.Type() <= value.NULL {
+		return first, nil
+	}
+
+	oa := first.Actual().(map[string]interface{})
+	ra := make(map[string]interface{}, len(oa))
+	for k, v := range oa {
+		if !second.Equals(value.NewValue(v)).Truth() {
+			ra[k] = v
+		} else if third.Type() != value.MISSING {
+			ra[k] = third
+		}
+	}
+
+	return value.NewValue(ra), nil
+}
+
+/*
+The constructor returns a NewObjectReplace with the three operands
+cast to a Function as the FunctionConstructor.
+*/
+func (this *ObjectReplace) Constructor() FunctionConstructor {
+	return func(operands ...Expression) Function {
+		return NewObjectReplace(operands[0], operands[1], operands[2])
+	}
+}
+
 ///////////////////////////////////////////////////
 //
 // ObjectValues
@@ -87,6 +87,7 @@ var _FUNCTIONS = map[string]Function{
 	// Date
 	"clock_millis":        &ClockMillis{},
 	"clock_str":           &ClockStr{},
+	"date_add_interval":   &DateAddInterval{},
 	"date_add_millis":     &DateAddMillis{},
 	"date_add_str":        &DateAddStr{},
 	"date_diff_millis":    &DateDiffMillis{},
@@ -95,6 +96,7 @@ var _FUNCTIONS = map[string]Function{
 	"date_part_str":       &DatePartStr{},
 	"date_trunc_millis":   &DateTruncMillis{},
 	"date_trunc_str":      &DateTruncStr{},
+	"interval_to_millis":  &IntervalToMillis{},
 	"millis":              &StrToMillis{},
 	"millis_to_str":       &MillisToStr{},
 	"millis_to_utc":       &MillisToUTC{},
@@ -132,6 +134,9 @@ var _FUNCTIONS = map[string]Function{
 	"trim":            &Trim{},
 	"upper":           &Upper{},
 
+	// Masking
+	"mask": &Mask{},
+
 	// Numeric
 	"abs":     &Abs{},
 	"acos":    &Acos{},
@@ -164,34 +169,40 @@ var _FUNCTIONS = map[string]Function{
 	"trunc":   &Trunc{},
 
 	// Array
-	"array_append":   &ArrayAppend{},
-	"array_avg":      &ArrayAvg{},
-	"array_concat":   &ArrayConcat{},
-	"array_contains": &ArrayContains{},
-	"array_count":    &ArrayCount{},
-	"array_distinct": &ArrayDistinct{},
-	"array_ifnull":   &ArrayIfNull{},
-	"array_length":   &ArrayLength{},
-	"array_max":      &ArrayMax{},
-	"array_min":      &ArrayMin{},
-	"array_position": &ArrayPosition{},
-	"array_pos":      &ArrayPosition{},
-	"array_prepend":  &ArrayPrepend{},
-	"array_put":      &ArrayPut{},
-	"array_range":    &ArrayRange{},
-	"array_remove":   &ArrayRemove{},
-	"array_repeat":   &ArrayRepeat{},
-	"array_replace":  &ArrayReplace{},
-	"array_reverse":  &ArrayReverse{},
-	"array_sort":     &ArraySort{},
-	"array_star":     &ArrayStar{},
-	"array_sum":      &ArraySum{},
+	"array_append":        &ArrayAppend{},
+	"array_avg":           &ArrayAvg{},
+	"array_binary_search": &ArrayBinarySearch{},
+	"array_concat":        &ArrayConcat{},
+	"array_contains":      &ArrayContains{},
+	"array_count":         &ArrayCount{},
+	"array_distinct":      &ArrayDistinct{},
+	"array_flatten":       &ArrayFlatten{},
+	"array_ifnull":        &ArrayIfNull{},
+	"array_length":        &ArrayLength{},
+	"array_max":           &ArrayMax{},
+	"array_min":           &ArrayMin{},
+	"array_move":          &ArrayMove{},
+	"array_position":      &ArrayPosition{},
+	"array_pos":           &ArrayPosition{},
+	"array_prepend":       &ArrayPrepend{},
+	"array_put":           &ArrayPut{},
+	"array_range":         &ArrayRange{},
+	"array_remove":        &ArrayRemove{},
+	"array_repeat":        &ArrayRepeat{},
+	"array_replace":       &ArrayReplace{},
+	"array_reverse":       &ArrayReverse{},
+	"array_sort":          &ArraySort{},
+	"array_star":          &ArrayStar{},
+	"array_sum":           &ArraySum{},
 
 	// Object
-	"object_length": &ObjectLength{},
-	"object_names":  &ObjectNames{},
-	"object_pairs":  &ObjectPairs{},
-	"object_values": &ObjectValues{},
+	"object_length":  &ObjectLength{},
+	"object_names":   &ObjectNames{},
+	"object_pairs":   &ObjectPairs{},
+	"object_paths":   &ObjectPaths{},
+	"object_rename":  &ObjectRename{},
+	"object_replace": &ObjectReplace{},
+	"object_values":  &ObjectValues{},
 
 	// JSON
 	"decode_json":  &DecodeJSON{},
@@ -91,6 +91,46 @@ type UnaryFunction interface {
 	Operand() Expression
 }
 
+/*
+A function whose arguments are followed by WITH options, as in
+MEDIAN(expr WITH {"approximate": true}). The options are a
+static object, or nil if none were given.
+*/
+type OptionsFunction interface {
+	/*
+	   Inherits from Function.
+	*/
+	Function
+
+	/*
+	   Returns the options.
+	*/
+	Options() value.Value
+}
+
+/*
+A function whose arguments are followed by an ORDER BY clause, as
+in FIRST(expr ORDER BY key DESC). The sort keys are the trailing
+operands, so that they are formalized and mapped like the
+arguments.
+*/
+type OrderedFunction interface {
+	/*
+	   Inherits from Function.
+	*/
+	Function
+
+	/*
+	   Returns the number of leading operands that are arguments.
+	*/
+	Arguments() int
+
+	/*
+	   Returns the direction of each sort key.
+	*/
+	Descending() []bool
+}
+
 /*
 A binary function is one that has two operands. It inherits
 from Function and contains two additional methods to return
@@ -623,3 +663,41 @@ Define Apply methods to evaluate Ternary functions.
 type TernaryApplied interface {
 	Apply(context Context, first, second, third value.Value) (value.Value, error)
 }
+
+/*
+Returns true if any of the expressions, including those of their
+subqueries, calls a volatile function, such as NOW_STR(), RANDOM()
+or UUID(), whose value differs between evaluations.
+*/
+func HasVolatile(exprs Expressions) bool {
+	finder := &volatileFinder{}
+	finder.traverser = finder
+
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+
+		finder.Traverse(expr)
+		if finder.volatile {
+			return true
+		}
+	}
+
+	return false
+}
+
+type volatileFinder struct {
+	TraverserBase
+
+	volatile bool
+}
+
+func (this *volatileFinder) VisitFunction(expr Function) (interface{}, error) {
+	if expr.Volatile() {
+		this.volatile = true
+		return nil, nil
+	}
+
+	return nil, this.TraverseList(expr.Children())
+}
@@ -0,0 +1,94 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build gofuzz
+
+package expression
+
+import (
+	"math"
+	"sort"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+/*
+Fuzz is the go-fuzz entry point for expression evaluation. The first
+byte selects a function from the registry, and the second is a mask
+of operands to replace with MISSING. The rest of the input is JSON,
+whose array elements (or the whole value, if it is not an array)
+supply the operands. Evaluation must never panic, whatever the
+operands.
+*/
+func Fuzz(data []byte) int {
+	if len(data) < 2 {
+		return 0
+	}
+
+	name := _FUZZ_NAMES[int(data[0])%len(_FUZZ_NAMES)]
+	function := _FUNCTIONS[name]
+
+	val := value.NewValue(data[2:])
+	if val.Type() == value.BINARY {
+		return 0
+	}
+
+	var args []interface{}
+	if actual, ok := val.Actual().([]interface{}); ok {
+		args = actual
+	} else {
+		args = []interface{}{val.Actual()}
+	}
+
+	n := len(args)
+	if n < function.MinArgs() {
+		n = function.MinArgs()
+	}
+	if function.MaxArgs() != math.MaxInt16 && n > function.MaxArgs() {
+		n = function.MaxArgs()
+	}
+
+	operands := make(Expressions, n)
+	for i := range operands {
+		switch {
+		case i < 8 && data[1]&(1<<uint(i)) != 0:
+			operands[i] = MISSING_EXPR
+		case i < len(args):
+			operands[i] = NewConstant(args[i])
+		default:
+			operands[i] = NULL_EXPR
+		}
+	}
+
+	item := value.NewAnnotatedValue(val)
+	item.SetAttachment("meta", map[string]interface{}{"id": name})
+
+	expr := function.Constructor()(operands...)
+	_, err := expr.Evaluate(item, _FUZZ_CONTEXT)
+	if err != nil {
+		return 0
+	}
+
+	return 1
+}
+
+var _FUZZ_NAMES = func() []string {
+	rv := make([]string, 0, len(_FUNCTIONS))
+	for name, _ := range _FUNCTIONS {
+		rv = append(rv, name)
+	}
+	sort.Strings(rv)
+	return rv
+}()
+
+// A fixed time, so that crashers are reproducible.
+var _FUZZ_CONTEXT = &IndexContext{
+	now: time.Date(2015, time.January, 1, 0, 0, 0, 0, time.UTC),
+}
@@ -530,6 +530,10 @@ func (this *Stringer) VisitSlice(expr *Slice) (interface{}, error) {
 
 // Function
 func (this *Stringer) VisitFunction(expr Function) (interface{}, error) {
+	if cast, ok := expr.(*Cast); ok {
+		return "cast(" + this.Visit(cast.Operand()) + " as " + string(cast.Target()) + ")", nil
+	}
+
 	var buf bytes.Buffer
 	buf.WriteString(expr.Name())
 	buf.WriteString("(")
@@ -538,7 +542,13 @@ func (this *Stringer) VisitFunction(expr Function) (interface{}, error) {
 		buf.WriteString("distinct ")
 	}
 
-	for i, op := range expr.Operands() {
+	ops := expr.Operands()
+	of, ordered := expr.(OrderedFunction)
+	if ordered {
+		ops = ops[0:of.Arguments()]
+	}
+
+	for i, op := range ops {
 		if i > 0 {
 			buf.WriteString(", ")
 		}
@@ -550,6 +560,26 @@ func (this *Stringer) VisitFunction(expr Function) (interface{}, error) {
 		}
 	}
 
+	if ordered {
+		buf.WriteString(" order by ")
+		descending := of.Descending()
+		for i, key := range expr.Operands()[of.Arguments():] {
+			if i > 0 {
+				buf.WriteString(", ")
+			}
+
+			buf.WriteString(this.Visit(key))
+			if descending[i] {
+				buf.WriteString(" desc")
+			}
+		}
+	}
+
+	if of, ok := expr.(OptionsFunction); ok && of.Options() != nil {
+		buf.WriteString(" with ")
+		buf.WriteString(this.Visit(NewConstant(of.Options())))
+	}
+
 	buf.WriteString(")")
 	return buf.String(), nil
 }
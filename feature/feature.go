@@ -0,0 +1,150 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package feature provides the feature flags that gate planner and
+execution behaviors, so that optimizer changes can be rolled out
+incrementally and turned off if they misbehave. Each flag has a
+server setting, which the flags of a request may override.
+*/
+package feature
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+)
+
+const (
+	ANTI_JOIN       = "anti_join"       // Evaluate NOT IN and NOT EXISTS subqueries as hash anti-joins
+	CHUNKED_SCAN    = "chunked_scan"    // Resume primary scans that time out as chunked scans
+	COUNT_SCAN      = "count_scan"      // Answer COUNT(*) from the count of the keyspace
+	COVERING_SCAN   = "covering_scan"   // Cover queries with index keys, without fetching
+	INTERSECT_SCAN  = "intersect_scan"  // Intersect the scans of several indexes
+	LIMIT_PUSHDOWN  = "limit_pushdown"  // Apply LIMIT in index scans
+	OFFSET_PUSHDOWN = "offset_pushdown" // Apply OFFSET in primary scans
+	VIEW_REWRITE    = "view_rewrite"    // Read the results of matching queries from materialized views
+)
+
+// The flags and their defaults
+var _DEFAULTS = map[string]bool{
+	ANTI_JOIN:       true,
+	CHUNKED_SCAN:    true,
+	COUNT_SCAN:      true,
+	COVERING_SCAN:   true,
+	INTERSECT_SCAN:  true,
+	LIMIT_PUSHDOWN:  true,
+	OFFSET_PUSHDOWN: true,
+	VIEW_REWRITE:    false, // Views may be stale until refreshed
+}
+
+// The server settings that differ from the defaults
+var _SETTINGS struct {
+	sync.RWMutex
+	flags map[string]bool
+}
+
+func Names() []string {
+	names := make([]string, 0, len(_DEFAULTS))
+	for name, _ := range _DEFAULTS {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	return names
+}
+
+func IsFeature(name string) bool {
+	_, ok := _DEFAULTS[name]
+	return ok
+}
+
+// The server setting of the flag.
+func Enabled(name string) bool {
+	_SETTINGS.RLock()
+	defer _SETTINGS.RUnlock()
+
+	if enabled, ok := _SETTINGS.flags[name]; ok {
+		return enabled
+	}
+
+	return _DEFAULTS[name]
+}
+
+func Set(name string, enabled bool) error {
+	if !IsFeature(name) {
+		return fmt.Errorf("Unknown feature %s.", name)
+	}
+
+	_SETTINGS.Lock()
+	defer _SETTINGS.Unlock()
+
+	if _SETTINGS.flags == nil {
+		_SETTINGS.flags = make(map[string]bool, len(_DEFAULTS))
+	}
+
+	_SETTINGS.flags[name] = enabled
+	return nil
+}
+
+// The server settings of all the flags.
+func Settings() map[string]bool {
+	return Flags(nil).Values()
+}
+
+/*
+Flags are the overrides of the server settings for a request. A nil
+Flags uses the server settings.
+*/
+type Flags map[string]bool
+
+/*
+Parses the flags of a request, an object of booleans by feature
+name.
+*/
+func ParseFlags(val interface{}) (Flags, error) {
+	fields, ok := val.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Features must be an object, not %v.", val)
+	}
+
+	rv := make(Flags, len(fields))
+	for name, field := range fields {
+		if !IsFeature(name) {
+			return nil, fmt.Errorf("Unknown feature %s.", name)
+		}
+
+		enabled, ok := field.(bool)
+		if !ok {
+			return nil, fmt.Errorf("Feature %s must be true or false, not %v.", name, field)
+		}
+
+		rv[name] = enabled
+	}
+
+	return rv, nil
+}
+
+func (this Flags) Enabled(name string) bool {
+	if enabled, ok := this[name]; ok {
+		return enabled
+	}
+
+	return Enabled(name)
+}
+
+// The values of all the flags, as reported by EXPLAIN.
+func (this Flags) Values() map[string]bool {
+	rv := make(map[string]bool, len(_DEFAULTS))
+	for name, _ := range _DEFAULTS {
+		rv[name] = this.Enabled(name)
+	}
+
+	return rv
+}
@@ -0,0 +1,82 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package guard
+
+import (
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+)
+
+/*
+The number of documents above which keyspaces may not be scanned in
+full; zero allows full scans of any keyspace.
+*/
+func FullScanThreshold() int64 {
+	_SETTINGS.RLock()
+	defer _SETTINGS.RUnlock()
+	return _SETTINGS.fullScanThreshold
+}
+
+func SetFullScanThreshold(threshold int64) {
+	_SETTINGS.Lock()
+	defer _SETTINGS.Unlock()
+	_SETTINGS.fullScanThreshold = threshold
+}
+
+/*
+Check that the plan of a statement does not scan in full a keyspace
+with more documents than the full scan threshold. A scan is in full
+if it is a primary scan, meaning that no index sargs the predicate
+of the statement, and the statement has no LIMIT that bounds the
+scan. The error suggests an index for the predicate, if it has
+sargable terms. Requests that intend full scans skip this check.
+The plans of subqueries are checked when they are evaluated.
+*/
+func CheckFullScans(op plan.Operator) errors.Error {
+	threshold := FullScanThreshold()
+	if threshold <= 0 || op == nil {
+		return nil
+	}
+
+	summary := summarize(op)
+
+	var pred expression.Expression
+	switch len(summary.conditions) {
+	case 0:
+	case 1:
+		pred = summary.conditions[0]
+	default:
+		pred = expression.NewAnd(summary.conditions...)
+	}
+
+	for _, scan := range summary.scans {
+		if scan.Limit() != nil || (summary.limited && !summary.ordered) {
+			continue
+		}
+
+		keyspace := scan.Keyspace()
+		count, err := keyspace.Count()
+		if err != nil || count <= threshold {
+			continue
+		}
+
+		advice := "Add a WHERE clause on indexed fields, USE KEYS or a LIMIT."
+		if index, ok := planner.AdviseIndex(keyspace, scan.Term(), pred); ok {
+			advice = "Consider creating an index: " + index + "."
+		}
+
+		return errors.NewServiceErrorFullScan(name(keyspace.NamespaceId(), keyspace.Name()),
+			count, threshold, advice)
+	}
+
+	return nil
+}
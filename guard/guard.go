@@ -0,0 +1,380 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package guard provides statement rules, which deny classes of
+statements to some users or on some keyspaces, for instance primary
+scans in production, or DELETE statements without a WHERE clause.
+Rules are a server setting, and are checked against the plan of each
+statement before it is executed.
+
+The package also blocks unconstrained full scans of large keyspaces;
+see CheckFullScans.
+
+Subqueries in expressions are planned when they are evaluated, and
+their plans are checked then; a statement is denied when it evaluates
+a subquery that breaks a rule.
+*/
+package guard
+
+import (
+	"fmt"
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+type Class string
+
+const (
+	PRIMARY_SCAN         Class = "primary_scan"         // Scans of primary indexes
+	DELETE_WITHOUT_WHERE Class = "delete_without_where" // Deletes of every document of a keyspace
+	UPDATE_WITHOUT_WHERE Class = "update_without_where" // Updates of every document of a keyspace
+	SELECT_STAR          Class = "select_star"          // SELECT * over more than max_documents
+)
+
+func ParseClass(class string) (Class, bool) {
+	switch Class(class) {
+	case PRIMARY_SCAN, DELETE_WITHOUT_WHERE, UPDATE_WITHOUT_WHERE, SELECT_STAR:
+		return Class(class), true
+	default:
+		return "", false
+	}
+}
+
+/*
+Rule denies a class of statements. A rule without users applies to
+all users, and a rule without keyspaces to all keyspaces; keyspaces
+are named namespace:keyspace.
+*/
+type Rule struct {
+	Deny         Class    `json:"deny"`
+	Users        []string `json:"users,omitempty"`
+	Keyspaces    []string `json:"keyspaces,omitempty"`
+	MaxDocuments int64    `json:"max_documents,omitempty"` // SELECT_STAR only
+	Message      string   `json:"message,omitempty"`       // Explains the rule to the user
+}
+
+type Rules []*Rule
+
+// The server settings
+var _SETTINGS struct {
+	sync.RWMutex
+	rules             Rules
+	fullScanThreshold int64
+}
+
+/*
+Parses the rules setting, an array of objects with the fields of
+Rule.
+*/
+func ParseRules(val interface{}) (Rules, error) {
+	items, ok := val.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Statement rules must be an array, not %v.", val)
+	}
+
+	rv := make(Rules, 0, len(items))
+	for _, item := range items {
+		fields, ok := item.(map[string]interface{})
+		if !ok {
+			return nil, fmt.Errorf("Statement rule must be an object, not %v.", item)
+		}
+
+		rule := &Rule{}
+		for name, field := range fields {
+			var err error
+			switch name {
+			case "deny":
+				s, _ := field.(string)
+				class, ok := ParseClass(s)
+				if !ok {
+					return nil, fmt.Errorf("Unknown statement class %v.", field)
+				}
+				rule.Deny = class
+			case "users":
+				rule.Users, err = parseStrings(name, field)
+			case "keyspaces":
+				rule.Keyspaces, err = parseStrings(name, field)
+			case "max_documents":
+				n, ok := field.(float64)
+				if !ok || n < 0 {
+					err = fmt.Errorf("Statement rule max_documents must be a positive number, not %v.", field)
+				}
+				rule.MaxDocuments = int64(n)
+			case "message":
+				rule.Message, ok = field.(string)
+				if !ok {
+					err = fmt.Errorf("Statement rule message must be a string, not %v.", field)
+				}
+			default:
+				err = fmt.Errorf("Unknown statement rule field %s.", name)
+			}
+
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		if rule.Deny == "" {
+			return nil, fmt.Errorf("Statement rule %v has no class to deny.", item)
+		}
+
+		rv = append(rv, rule)
+	}
+
+	return rv, nil
+}
+
+func parseStrings(name string, field interface{}) ([]string, error) {
+	items, ok := field.([]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Statement rule %s must be an array of strings, not %v.", name, field)
+	}
+
+	rv := make([]string, len(items))
+	for i, item := range items {
+		rv[i], ok = item.(string)
+		if !ok {
+			return nil, fmt.Errorf("Statement rule %s must be an array of strings, not %v.", name, field)
+		}
+	}
+
+	return rv, nil
+}
+
+// Rules are immutable; Set replaces them.
+func Set(rules Rules) {
+	_SETTINGS.Lock()
+	defer _SETTINGS.Unlock()
+	_SETTINGS.rules = rules
+}
+
+func Get() Rules {
+	_SETTINGS.RLock()
+	defer _SETTINGS.RUnlock()
+	return _SETTINGS.rules
+}
+
+func Count() int {
+	return len(Get())
+}
+
+/*
+Check the plan of a statement of the given users against the rules
+of the server, returning an error that explains the first rule the
+statement breaks.
+*/
+func Check(users []string, op plan.Operator) errors.Error {
+	return Get().Check(users, op)
+}
+
+func (this Rules) Check(users []string, op plan.Operator) errors.Error {
+	if len(this) == 0 || op == nil {
+		return nil
+	}
+
+	var summary *summary
+	for _, rule := range this {
+		if !rule.appliesTo(users) {
+			continue
+		}
+
+		if summary == nil {
+			summary = summarize(op)
+		}
+
+		if err := rule.check(summary); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func (this *Rule) appliesTo(users []string) bool {
+	if len(this.Users) == 0 {
+		return true
+	}
+
+	for _, user := range users {
+		for _, u := range this.Users {
+			if u == user {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+func (this *Rule) appliesOn(keyspace string) bool {
+	if len(this.Keyspaces) == 0 {
+		return true
+	}
+
+	for _, k := range this.Keyspaces {
+		if k == keyspace {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (this *Rule) check(summary *summary) errors.Error {
+	switch this.Deny {
+	case PRIMARY_SCAN:
+		for _, keyspace := range summary.primaryScans {
+			if this.appliesOn(keyspace) {
+				return this.deny(keyspace, "primary scans are not allowed; create an index for the query")
+			}
+		}
+	case DELETE_WITHOUT_WHERE:
+		if !summary.filtered {
+			for _, keyspace := range summary.deletes {
+				if this.appliesOn(keyspace) {
+					return this.deny(keyspace, "DELETE requires a WHERE clause or USE KEYS")
+				}
+			}
+		}
+	case UPDATE_WITHOUT_WHERE:
+		if !summary.filtered {
+			for _, keyspace := range summary.updates {
+				if this.appliesOn(keyspace) {
+					return this.deny(keyspace, "UPDATE requires a WHERE clause or USE KEYS")
+				}
+			}
+		}
+	case SELECT_STAR:
+		if !summary.star || (summary.limit >= 0 && summary.limit <= this.MaxDocuments) {
+			return nil
+		}
+
+		for keyspace, ks := range summary.fetches {
+			if !this.appliesOn(keyspace) {
+				continue
+			}
+
+			count, err := ks.Count()
+			if err != nil || count > this.MaxDocuments {
+				return this.deny(keyspace, fmt.Sprintf("SELECT * is not allowed over more than %d documents;"+
+					" project the fields needed or add a LIMIT", this.MaxDocuments))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (this *Rule) deny(keyspace, reason string) errors.Error {
+	if this.Message != "" {
+		reason = this.Message
+	}
+
+	return errors.NewServiceErrorStatementDenied(string(this.Deny), keyspace, reason)
+}
+
+type counter interface {
+	Count() (int64, errors.Error)
+}
+
+// What the rules look for in a plan.
+type summary struct {
+	primaryScans []string
+	scans        []*plan.PrimaryScan
+	deletes      []string
+	updates      []string
+	fetches      map[string]counter
+	conditions   expression.Expressions // Of the filters
+	filtered     bool                   // The plan has a filter or USE KEYS
+	star         bool                   // The projection has a star term
+	limited      bool                   // The plan has a LIMIT
+	ordered      bool                   // The plan sorts or groups its input
+	limit        int64                  // Constant LIMIT, or -1
+}
+
+func summarize(op plan.Operator) *summary {
+	rv := &summary{
+		fetches: make(map[string]counter),
+		limit:   -1,
+	}
+
+	rv.walk(op)
+	return rv
+}
+
+func (this *summary) walk(op plan.Operator) {
+	switch op := op.(type) {
+	case *plan.Sequence:
+		this.walkAll(op.Children())
+	case *plan.Parallel:
+		this.walk(op.Child())
+	case *plan.Authorize:
+		this.walk(op.Child())
+	case *plan.UnionAll:
+		this.walkAll(op.Children())
+	case *plan.IntersectAll:
+		this.walk(op.First())
+		this.walk(op.Second())
+	case *plan.ExceptAll:
+		this.walk(op.First())
+		this.walk(op.Second())
+	case *plan.IntersectScan:
+		this.walkAll(op.Scans())
+	case *plan.UnionScan:
+		this.walkAll(op.Scans())
+	case *plan.Merge:
+		this.walk(op.Update())
+		this.walk(op.Delete())
+		this.walk(op.Insert())
+	case *plan.PrimaryScan:
+		this.primaryScans = append(this.primaryScans, name(op.Keyspace().NamespaceId(), op.Keyspace().Name()))
+		this.scans = append(this.scans, op)
+	case *plan.KeyScan:
+		this.filtered = true
+	case *plan.Filter:
+		this.filtered = true
+		this.conditions = append(this.conditions, op.Condition())
+	case *plan.Order, *plan.InitialGroup:
+		this.ordered = true
+	case *plan.SendDelete:
+		this.deletes = append(this.deletes, name(op.Keyspace().NamespaceId(), op.Keyspace().Name()))
+	case *plan.SendUpdate:
+		this.updates = append(this.updates, name(op.Keyspace().NamespaceId(), op.Keyspace().Name()))
+	case *plan.Fetch:
+		this.fetches[name(op.Keyspace().NamespaceId(), op.Keyspace().Name())] = op.Keyspace()
+	case *plan.InitialProject:
+		for _, term := range op.Terms() {
+			if term.Result().Star() {
+				this.star = true
+			}
+		}
+	case *plan.Limit:
+		this.limited = true
+		if expr := op.Expression(); expr != nil {
+			if val := expr.Value(); val != nil && val.Type() == value.NUMBER {
+				this.limit = int64(val.Actual().(float64))
+			}
+		}
+	}
+}
+
+func (this *summary) walkAll(ops []plan.Operator) {
+	for _, op := range ops {
+		this.walk(op)
+	}
+}
+
+func name(namespace, keyspace string) string {
+	return namespace + ":" + keyspace
+}
@@ -0,0 +1,298 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package n1ql
+
+import (
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	COMPLETE_KEYWORD  = "keyword"
+	COMPLETE_KEYSPACE = "keyspace"
+	COMPLETE_INDEX    = "index"
+)
+
+/*
+Completion is a candidate for the word being typed in a statement:
+a keyword, or the name of a keyspace or index.
+*/
+type Completion struct {
+	Text string `json:"text"`
+	Kind string `json:"kind"`
+}
+
+/*
+Complete returns the candidates for the word that ends at the cursor,
+an offset in the input, and the offset where that word starts.
+Keywords are candidates if the parser accepts them after the text
+before the word. Keyspace names are looked up in the datastore after
+FROM, JOIN, INTO, UPDATE and the like, and index names after USE
+INDEX or when naming an index of a keyspace. The store may be nil,
+in which case only keywords are returned.
+*/
+func Complete(input string, cursor int, store datastore.Datastore, namespace string) (
+	int, []*Completion) {
+	if cursor < 0 || cursor > len(input) {
+		cursor = len(input)
+	}
+
+	start := cursor
+	for start > 0 && isWordByte(input[start-1]) {
+		start--
+	}
+
+	before := input[:start]
+	word := strings.ToLower(input[start:cursor])
+	tokens := lexTokens(before)
+	rv := make([]*Completion, 0, 16)
+
+	for _, keyword := range keywords() {
+		lower := strings.ToLower(keyword)
+		if !strings.HasPrefix(lower, word) || !accepts(before, lower) {
+			continue
+		}
+
+		// ADVISE is otherwise accepted as an identifier
+		if keyword == "ADVISE" && len(tokens) > 0 && tokens[len(tokens)-1].id != SEMI {
+			continue
+		}
+
+		rv = append(rv, &Completion{Text: keyword, Kind: COMPLETE_KEYWORD})
+	}
+
+	if store == nil || !accepts(before, "x") {
+		return start, rv
+	}
+
+	if ns, ok := keyspaceContext(tokens); ok {
+		if ns == "" {
+			ns = namespace
+		}
+
+		rv = appendNames(rv, word, COMPLETE_KEYSPACE, keyspaceNames(store, ns))
+	} else if ns, ks, ok := indexContext(tokens); ok {
+		if ns == "" {
+			ns = namespace
+		}
+
+		rv = appendNames(rv, word, COMPLETE_INDEX, indexNames(store, ns, ks))
+	}
+
+	return start, rv
+}
+
+func appendNames(rv []*Completion, word, kind string, names []string) []*Completion {
+	sort.Strings(names)
+	for _, name := range names {
+		if strings.HasPrefix(strings.ToLower(name), word) {
+			rv = append(rv, &Completion{Text: name, Kind: kind})
+		}
+	}
+
+	return rv
+}
+
+/*
+Whether the parser accepts the word after the text, that is, it
+reports no syntax error before the end of the input.
+*/
+func accepts(text, word string) bool {
+	text = strings.TrimSpace(text + " " + word)
+	lex := newLexer(NewLexer(strings.NewReader(text)))
+	lex.parsingStmt = true
+	lex.text = text
+	doParse(lex)
+
+	if len(lex.errs) == 0 {
+		return true
+	}
+
+	perr, ok := lex.errs[0].(errors.ParseError)
+	return ok && perr.Token() == ""
+}
+
+type token struct {
+	id   int
+	text string
+}
+
+func lexTokens(text string) []token {
+	nex := NewLexer(strings.NewReader(text))
+	rv := make([]token, 0, 16)
+	for {
+		var lval yySymType
+		id := nex.Lex(&lval)
+		if id == 0 {
+			return rv
+		}
+
+		rv = append(rv, token{id: id, text: lval.s})
+	}
+}
+
+/*
+A keyspace is named after FROM, JOIN, NEST, INTO, UPDATE and ON, or
+after the namespace and colon. Return the namespace if given.
+*/
+func keyspaceContext(tokens []token) (string, bool) {
+	n := len(tokens)
+	if n == 0 {
+		return "", false
+	}
+
+	switch tokens[n-1].id {
+	case FROM, JOIN, NEST, INTO, UPDATE, ON:
+		return "", true
+	case COLON:
+		if n > 1 && tokens[n-2].id == IDENTIFIER {
+			return tokens[n-2].text, true
+		} else if n > 1 && tokens[n-2].id == SYSTEM {
+			return "#system", true
+		}
+	}
+
+	return "", false
+}
+
+/*
+An index is named in USE INDEX (...) after the keyspace it is used
+on, in BUILD INDEX ON keyspace(...), and as keyspace.index in DROP
+INDEX and ALTER INDEX. Return the namespace and keyspace.
+*/
+func indexContext(tokens []token) (string, string, bool) {
+	n := len(tokens)
+	if n >= 3 && tokens[n-1].id == DOT && tokens[n-2].id == IDENTIFIER &&
+		tokens[n-3].id == INDEX {
+		return "", tokens[n-2].text, true
+	}
+
+	i := n - 1
+	for i >= 0 && (tokens[i].id == IDENTIFIER || tokens[i].id == COMMA) {
+		i--
+	}
+
+	if i < 2 || tokens[i].id != LPAREN {
+		return "", "", false
+	}
+
+	if tokens[i-1].id == INDEX && tokens[i-2].id == USE {
+		ns, ks := lastKeyspace(tokens[:i-2])
+		return ns, ks, ks != ""
+	}
+
+	if i >= 3 && tokens[i-1].id == IDENTIFIER && tokens[i-2].id == ON && tokens[i-3].id == INDEX {
+		return "", tokens[i-1].text, true
+	}
+
+	return "", "", false
+}
+
+// The namespace and keyspace named last before the tokens end.
+func lastKeyspace(tokens []token) (string, string) {
+	for i := len(tokens) - 1; i > 0; i-- {
+		if tokens[i].id != IDENTIFIER {
+			continue
+		}
+
+		switch tokens[i-1].id {
+		case FROM, JOIN, NEST, INTO, UPDATE:
+			return "", tokens[i].text
+		case COLON:
+			if i > 2 {
+				switch tokens[i-3].id {
+				case FROM, JOIN, NEST, INTO, UPDATE:
+					return tokens[i-2].text, tokens[i].text
+				}
+			}
+		}
+	}
+
+	return "", ""
+}
+
+func keyspaceNames(store datastore.Datastore, namespace string) []string {
+	ns, err := store.NamespaceByName(namespace)
+	if err != nil {
+		return nil
+	}
+
+	names, err := ns.KeyspaceNames()
+	if err != nil {
+		return nil
+	}
+
+	return names
+}
+
+func indexNames(store datastore.Datastore, namespace, keyspace string) []string {
+	ns, err := store.NamespaceByName(namespace)
+	if err != nil {
+		return nil
+	}
+
+	ks, err := ns.KeyspaceByName(keyspace)
+	if err != nil {
+		return nil
+	}
+
+	indexers, err := ks.Indexers()
+	if err != nil {
+		return nil
+	}
+
+	rv := make([]string, 0, 8)
+	for _, indexer := range indexers {
+		names, err := indexer.IndexNames()
+		if err == nil {
+			rv = append(rv, names...)
+		}
+	}
+
+	return rv
+}
+
+func isWordByte(c byte) bool {
+	return c == '_' || (c >= 'a' && c <= 'z') || (c >= 'A' && c <= 'Z') || (c >= '0' && c <= '9')
+}
+
+var _KEYWORDS []string
+var _KEYWORDS_ONCE sync.Once
+
+/*
+The keywords are the token names that the lexer returns as those
+tokens, plus ADVISE, which is a keyword only at the start of a
+statement.
+*/
+func keywords() []string {
+	_KEYWORDS_ONCE.Do(func() {
+		_KEYWORDS = []string{"ADVISE"}
+		for _, name := range yyToknames {
+			if name == "" || strings.IndexFunc(name, func(r rune) bool {
+				return r < 'A' || r > 'Z'
+			}) >= 0 {
+				continue
+			}
+
+			tokens := lexTokens(strings.ToLower(name))
+			if len(tokens) == 1 && tokens[0].id != IDENTIFIER {
+				_KEYWORDS = append(_KEYWORDS, name)
+			}
+		}
+
+		sort.Strings(_KEYWORDS)
+	})
+
+	return _KEYWORDS
+}
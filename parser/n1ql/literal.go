@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package n1ql
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Typed literals are written as a type name followed by a string:
+DATE '2015-06-30', TIMESTAMP '2015-06-30T12:00:00Z' or
+INTERVAL '1h30m'. Dates and timestamps are strict ISO 8601, and
+remain strings; intervals are milliseconds.
+*/
+func newTypedLiteral(typ, str string) (expression.Expression, error) {
+	switch strings.ToLower(typ) {
+	case "date":
+		_, err := time.Parse("2006-01-02", str)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid DATE literal: %s", str)
+		}
+		return expression.NewConstant(value.NewValue(str)), nil
+	case "timestamp":
+		_, err := time.Parse(time.RFC3339Nano, str)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid TIMESTAMP literal: %s", str)
+		}
+		return expression.NewConstant(value.NewValue(str)), nil
+	case "interval":
+		_, err := expression.ParseInterval(str)
+		if err != nil {
+			return nil, fmt.Errorf("Invalid INTERVAL literal: %s", str)
+		}
+		return expression.NewIntervalToMillis(expression.NewConstant(value.NewValue(str))), nil
+	default:
+		return nil, fmt.Errorf("Unknown literal type: %s", typ)
+	}
+}
+
+// Adding or subtracting an interval is date arithmetic.
+func newAdd(first, second expression.Expression) expression.Expression {
+	if isInterval(second) {
+		return expression.NewDateAddInterval(first, second)
+	}
+
+	if isInterval(first) {
+		return expression.NewDateAddInterval(second, first)
+	}
+
+	return expression.NewAdd(first, second)
+}
+
+func newSub(first, second expression.Expression) expression.Expression {
+	if isInterval(second) {
+		return expression.NewDateAddInterval(first, expression.NewNeg(second))
+	}
+
+	return expression.NewSub(first, second)
+}
+
+func isInterval(expr expression.Expression) bool {
+	_, ok := expr.(*expression.IntervalToMillis)
+	return ok
+}
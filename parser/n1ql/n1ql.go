@@ -13,13 +13,65 @@ import (
 	"fmt"
 	"runtime"
 	"strings"
+	"unicode/utf8"
 
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/value"
 )
 
 func ParseStatement(input string) (algebra.Statement, error) {
+	stmts, err := ParseStatements(input)
+	if err != nil {
+		return nil, err
+	} else if len(stmts) > 1 {
+		return nil, fmt.Errorf("Input was more than one statement.")
+	} else {
+		return stmts[0], nil
+	}
+}
+
+/*
+Parses a statement and reprints it as formatted by the formatter.
+*/
+func FormatStatement(input string, formatter *algebra.Formatter) (string, error) {
+	stmt, err := ParseStatement(input)
+	if err != nil {
+		return "", err
+	}
+
+	return formatter.Format(stmt)
+}
+
+/*
+Parses the statements of the input and returns their fingerprints,
+separated by semicolons. See algebra.Fingerprint.
+*/
+func Fingerprint(input string) (string, error) {
+	stmts, err := ParseStatements(input)
+	if err != nil {
+		return "", err
+	}
+
+	fingerprints := make([]string, len(stmts))
+	for i, stmt := range stmts {
+		fingerprints[i], err = algebra.Fingerprint(stmt)
+		if err != nil {
+			return "", err
+		}
+	}
+
+	return strings.Join(fingerprints, "; "), nil
+}
+
+/*
+Parses a list of semicolon-separated statements. PREPARE is
+allowed only on its own, as the text of a prepared statement is
+the whole input.
+*/
+func ParseStatements(input string) ([]algebra.Statement, error) {
 	input = strings.TrimSpace(input)
 	reader := strings.NewReader(input)
 	lex := newLexer(NewLexer(reader))
@@ -28,27 +80,109 @@ func ParseStatement(input string) (algebra.Statement, error) {
 	doParse(lex)
 
 	if len(lex.errs) > 0 {
-		return nil, fmt.Errorf(strings.Join(lex.errs, " \n "))
-	} else if lex.stmt == nil {
+		return nil, lex.errors()
+	} else if len(lex.stmts) == 0 {
 		return nil, fmt.Errorf("Input was not a statement.")
-	} else {
-		err := lex.stmt.Formalize()
+	}
+
+	for _, stmt := range lex.stmts {
+		if _, ok := stmt.(*algebra.Prepare); ok && len(lex.stmts) > 1 {
+			return nil, fmt.Errorf("PREPARE is not allowed with other statements.")
+		}
+
+		err := stmt.Formalize()
 		if err != nil {
 			return nil, err
-		} else {
-			return lex.stmt, nil
 		}
 	}
+
+	return lex.stmts, nil
+}
+
+/*
+Parses the WITH option of EXPLAIN, which declares virtual indexes
+by CREATE INDEX statements, as in
+
+	EXPLAIN WITH {"indexes": ["CREATE INDEX ix ON k(x)"]} SELECT ...
+*/
+func parseExplainOptions(with value.Value) (indexes []algebra.Statement, format string, err error) {
+	format = algebra.EXPLAIN_JSON
+	if with == nil {
+		return
+	}
+
+	for name, option := range with.Fields() {
+		switch name {
+		case "indexes":
+			indexes, err = parseVirtualIndexes(value.NewValue(option))
+		case "format":
+			format, err = parseExplainFormat(value.NewValue(option))
+		default:
+			err = fmt.Errorf("Unknown EXPLAIN WITH option %s.", name)
+		}
+
+		if err != nil {
+			return
+		}
+	}
+
+	return
+}
+
+func parseExplainFormat(format value.Value) (string, error) {
+	if s, ok := format.Actual().(string); ok {
+		switch s = strings.ToLower(s); s {
+		case algebra.EXPLAIN_JSON, algebra.EXPLAIN_DOT, algebra.EXPLAIN_FLAME:
+			return s, nil
+		}
+	}
+
+	return "", fmt.Errorf("EXPLAIN WITH format must be one of %s, %s or %s.",
+		algebra.EXPLAIN_JSON, algebra.EXPLAIN_DOT, algebra.EXPLAIN_FLAME)
+}
+
+func parseVirtualIndexes(indexes value.Value) ([]algebra.Statement, error) {
+	if indexes.Type() != value.ARRAY {
+		return nil, fmt.Errorf("EXPLAIN WITH indexes must be an array of CREATE INDEX statements.")
+	}
+
+	rv := make([]algebra.Statement, 0, 4)
+	for i := 0; ; i++ {
+		index, ok := indexes.Index(i)
+		if !ok {
+			break
+		}
+
+		if index.Type() != value.STRING {
+			return nil, fmt.Errorf("Invalid virtual index %v.", index)
+		}
+
+		text := index.Actual().(string)
+		stmt, err := ParseStatement(text)
+		if err != nil {
+			return nil, err
+		}
+
+		switch stmt.(type) {
+		case *algebra.CreateIndex, *algebra.CreatePrimaryIndex:
+			rv = append(rv, stmt)
+		default:
+			return nil, fmt.Errorf("Virtual index must be declared by CREATE INDEX: %s", text)
+		}
+	}
+
+	return rv, nil
 }
 
 func ParseExpression(input string) (expression.Expression, error) {
 	input = strings.TrimSpace(input)
 	reader := strings.NewReader(input)
 	lex := newLexer(NewLexer(reader))
+	lex.text = input
 	doParse(lex)
 
 	if len(lex.errs) > 0 {
-		return nil, fmt.Errorf(strings.Join(lex.errs, " \n "))
+		return nil, lex.errors()
 	} else if lex.expr == nil {
 		return nil, fmt.Errorf("Input was not an expression.")
 	} else {
@@ -75,36 +209,120 @@ func doParse(lex *lexer) {
 type lexer struct {
 	nex         *Lexer
 	posParam    int
-	errs        []string
-	stmt        algebra.Statement
+	errs        []errors.Error
+	stmts       []algebra.Statement
 	expr        expression.Expression
 	parsingStmt bool
 	text        string
+	started     bool
+	start       int // Offset of the token read last
+	end         int // Offset after the token read last
 }
 
 func newLexer(nex *Lexer) *lexer {
 	return &lexer{
 		nex:  nex,
-		errs: make([]string, 0, 16),
+		errs: make([]errors.Error, 0, 16),
 	}
 }
 
 func (this *lexer) Lex(lval *yySymType) int {
-	return this.nex.Lex(lval)
+	rv := this.nex.Lex(lval)
+	this.locate(rv)
+
+	// ADVISE, IMPORT and REFRESH are keywords only at the start
+	// of a statement, so that they can still be used as identifiers
+	if rv == IDENTIFIER && !this.started && this.parsingStmt {
+		if strings.EqualFold(this.nex.Text(), "advise") {
+			rv = ADVISE
+		} else if strings.EqualFold(this.nex.Text(), "import") {
+			rv = IMPORT
+		} else if strings.EqualFold(this.nex.Text(), "refresh") {
+			rv = REFRESH
+		}
+	}
+
+	// A statement starts after a semicolon
+	this.started = rv != SEMI
+	return rv
 }
 
+/*
+Errors are reported at the token read last, which is the one the
+parser could not accept for syntax errors. The parser recovers from
+syntax errors at the closing parenthesis, bracket or brace around
+them, or else at the next statement, so that several errors can be
+reported for one input.
+*/
 func (this *lexer) Error(s string) {
-	if len(this.nex.stack) > 0 {
-		s = s + " - at " + this.nex.Text()
+	token := ""
+	if len(this.nex.stack) > 0 && this.start < len(this.text) {
+		token = this.nex.Text()
+		s = s + " - at " + token
 	} else {
 		s = s + " - at end of input"
 	}
 
-	this.errs = append(this.errs, s)
+	before := this.text[:this.start]
+	line := 1 + strings.Count(before, "\n")
+	column := 1 + utf8.RuneCountInString(before[strings.LastIndex(before, "\n")+1:])
+	s = fmt.Sprintf("%s (line %d, column %d)", s, line, column)
+	this.errs = append(this.errs, errors.NewParseErrorAt(s, line, column, token))
+}
+
+// A single error, or all of them.
+func (this *lexer) errors() error {
+	if len(this.errs) == 1 {
+		return this.errs[0]
+	}
+
+	return errors.Errors(this.errs)
+}
+
+/*
+Find the token read last in the text, skipping the whitespace and
+comments before it, which the lexer does not return.
+*/
+func (this *lexer) locate(token int) {
+	i := this.end
+	for i < len(this.text) {
+		if strings.IndexByte(" \t\n\r\f", this.text[i]) >= 0 {
+			i++
+		} else if strings.HasPrefix(this.text[i:], "/*") {
+			n := strings.Index(this.text[i+2:], "*/")
+			if n < 0 {
+				i = len(this.text)
+			} else {
+				i += n + 4
+			}
+		} else if strings.HasPrefix(this.text[i:], "--") {
+			n := strings.IndexAny(this.text[i:], "\n\r")
+			if n < 0 {
+				i = len(this.text)
+			} else {
+				i += n
+			}
+		} else {
+			break
+		}
+	}
+
+	this.start, this.end = i, i
+	if token == 0 || len(this.nex.stack) == 0 {
+		return
+	}
+
+	text := this.nex.Text()
+	if strings.HasPrefix(this.text[i:], text) {
+		this.end = i + len(text)
+	} else if n := strings.Index(this.text[i:], text); n >= 0 {
+		this.start = i + n
+		this.end = this.start + len(text)
+	}
 }
 
-func (this *lexer) setStatement(stmt algebra.Statement) {
-	this.stmt = stmt
+func (this *lexer) setStatements(stmts []algebra.Statement) {
+	this.stmts = stmts
 }
 
 func (this *lexer) setExpression(expr expression.Expression) {
@@ -119,3 +337,29 @@ func (this *lexer) nextParam() int {
 	this.posParam++
 	return this.posParam
 }
+
+/*
+USE CONSISTENCY names a level, and AT_PLUS alone takes a vector.
+*/
+func newConsistency(yylex yyLexer, keyword, name string, vector expression.Expression) *algebra.Consistency {
+	if !strings.EqualFold(keyword, "consistency") {
+		yylex.Error(fmt.Sprintf("Unexpected %s after USE.", keyword))
+		return nil
+	}
+
+	level, hasVector, ok := algebra.ConsistencyLevel(name)
+	if !ok {
+		yylex.Error(fmt.Sprintf("Invalid scan consistency %s.", name))
+		return nil
+	}
+
+	if hasVector && vector == nil {
+		yylex.Error(fmt.Sprintf("Scan consistency %s requires a scan vector.", name))
+		return nil
+	} else if !hasVector && vector != nil {
+		yylex.Error(fmt.Sprintf("Scan consistency %s takes no scan vector.", name))
+		return nil
+	}
+
+	return algebra.NewConsistency(level, vector)
+}
@@ -31,6 +31,7 @@ bindings         expression.Bindings
 
 node             algebra.Node
 statement        algebra.Statement
+statements       []algebra.Statement
 
 fullselect       *algebra.Select
 subresult        algebra.Subresult
@@ -39,6 +40,8 @@ subselect        *algebra.Subselect
 fromTerm         algebra.FromTerm
 keyspaceTerm     *algebra.KeyspaceTerm
 use              *algebra.Use
+keyRange         *algebra.KeyRange
+consistency      *algebra.Consistency
 indexRefs        algebra.IndexRefs
 indexRef         *algebra.IndexRef
 subqueryTerm     *algebra.SubqueryTerm
@@ -52,6 +55,7 @@ sortTerm         *algebra.SortTerm
 sortTerms        algebra.SortTerms
 
 keyspaceRef      *algebra.KeyspaceRef
+keyspaceRefs     []*algebra.KeyspaceRef
 
 pairs            algebra.Pairs
 set              *algebra.Set
@@ -73,6 +77,7 @@ indexType        datastore.IndexType
 val              value.Value
 }
 
+%token ADVISE
 %token ALL
 %token ALTER
 %token ANALYZE
@@ -138,6 +143,7 @@ val              value.Value
 %token IF
 %token IGNORE
 %token ILIKE
+%token IMPORT
 %token IN
 %token INCLUDE
 %token INCREMENT
@@ -193,6 +199,7 @@ val              value.Value
 %token RAW
 %token REALM
 %token REDUCE
+%token REFRESH
 %token RENAME
 %token RETURN
 %token RETURNING
@@ -302,7 +309,8 @@ val              value.Value
 %type <expr>             opt_when
 
 %type <expr>             function_expr
-%type <s>                function_name
+%type <s>                function_name cast_type
+%type <s>                opt_outfile_format
 
 %type <expr>             paren_expr
 %type <subquery>         subquery_expr
@@ -317,14 +325,17 @@ val              value.Value
 %type <subqueryTerm>     subquery_term
 %type <b>                opt_join_type
 %type <path>             path opt_subpath
-%type <s>                namespace_name keyspace_name
-%type <use>              opt_use
+%type <s>                namespace_name keyspace_name keyspace_path
+%type <use>              opt_use opt_mutate_use
+%type <consistency>      use_consistency
+%type <keyRange>         use_key_range
 %type <expr>             use_keys on_keys
 %type <indexRefs>        use_index index_refs
 %type <indexRef>         index_ref
 %type <bindings>         opt_let let
 %type <expr>             opt_where where
 %type <group>            opt_group group
+%type <expr>             opt_group_limit
 %type <bindings>         opt_letting letting
 %type <expr>             opt_having having
 %type <resultTerm>       project
@@ -337,9 +348,19 @@ val              value.Value
 %type <expr>             offset opt_offset
 %type <b>                dir opt_dir
 
-%type <statement>        stmt explain prepare execute select_stmt dml_stmt ddl_stmt
-%type <statement>        insert upsert delete update merge
+%type <statements>       stmts
+%type <statement>        stmt explain advise prepare execute select_stmt dml_stmt ddl_stmt
+%type <statement>        insert upsert delete update merge import_stmt
 %type <statement>        index_stmt create_index drop_index alter_index build_index
+%type <statement>        policy_stmt create_policy drop_policy create_mask drop_mask
+%type <statement>        view_stmt create_view drop_view refresh_view
+%type <statement>        keyspace_stmt create_keyspace drop_keyspace
+%type <statement>        session_set
+%type <statement>        role_stmt grant_role revoke_role
+%type <ss>               role_list user_list
+%type <s>                role_name user
+%type <keyspaceRefs>     keyspace_list
+%type <s>                setting_name
 
 %type <keyspaceRef>      keyspace_ref
 %type <pairs>            values values_list next_values
@@ -363,11 +384,13 @@ val              value.Value
 %type <mergeInsert>      merge_insert opt_merge_insert
 
 %type <s>                index_name opt_primary_name
-%type <ss>               index_names
+%type <ss>               index_names keyspace_index_path
 %type <keyspaceRef>      named_keyspace_ref
+%type <expr>             policy_expr mask_path
 %type <expr>             index_partition
 %type <indexType>        index_using opt_index_using
 %type <val>              index_with opt_index_with
+%type <val>              opt_import_options import_options import_option
 %type <s>                rename
 %type <expr>             index_expr index_where
 %type <exprs>            index_exprs
@@ -377,9 +400,14 @@ val              value.Value
 %%
 
 input:
-stmt opt_trailer
+stmts
 {
-    yylex.(*lexer).setStatement($1)
+    yylex.(*lexer).setStatements($1)
+}
+|
+stmts semis
+{
+    yylex.(*lexer).setStatements($1)
 }
 |
 expr
@@ -388,17 +416,29 @@ expr
 }
 ;
 
-opt_trailer:
+stmts:
+stmt
 {
-  /* nothing */
+    $$ = []algebra.Statement{$1}
 }
 |
-opt_trailer SEMI
+stmts semis stmt
+{
+    $$ = append($1, $3)
+}
+;
+
+semis:
+SEMI
+|
+semis SEMI
 ;
 
 stmt:
 explain
 |
+advise
+|
 prepare
 |
 execute
@@ -408,12 +448,45 @@ select_stmt
 dml_stmt
 |
 ddl_stmt
+|
+session_set
+|
+role_stmt
+|
+error
+{
+    /* Skip to the next statement after a syntax error */
+    $$ = nil
+}
 ;
 
 explain:
 EXPLAIN stmt
 {
-    $$ = algebra.NewExplain($2)
+    $$ = algebra.NewExplain($2, nil, algebra.EXPLAIN_JSON)
+}
+|
+EXPLAIN WITH object stmt
+{
+    with := $3.Value()
+    if with == nil {
+        yylex.Error("WITH value must be static.")
+    }
+
+    indexes, format, err := parseExplainOptions(with)
+    if err != nil {
+        yylex.Error(err.Error())
+    }
+
+    $$ = algebra.NewExplain($4, indexes, format)
+}
+;
+
+/* ADVISE is returned by the lexer only at the start of a statement. */
+advise:
+ADVISE stmt
+{
+    $$ = algebra.NewAdvise($2)
 }
 ;
 
@@ -459,6 +532,38 @@ fullselect
 {
     $$ = $1
 }
+|
+fullselect INTO IDENTIFIER STR opt_outfile_format
+{
+    /* OUTFILE and FORMAT are not reserved words */
+    if strings.ToLower($3) != "outfile" {
+        yylex.Error("Expected OUTFILE after INTO.")
+    }
+
+    outfile, err := algebra.NewOutfile($4, $5)
+    if err != nil {
+        yylex.Error(err.Error())
+    }
+
+    $1.SetOutfile(outfile)
+    $$ = $1
+}
+;
+
+opt_outfile_format:
+/* empty */
+{
+    $$ = ""
+}
+|
+IDENTIFIER IDENTIFIER
+{
+    if strings.ToLower($1) != "format" {
+        yylex.Error("Expected FORMAT after OUTFILE.")
+    }
+
+    $$ = $2
+}
 ;
 
 dml_stmt:
@@ -471,10 +576,18 @@ delete
 update
 |
 merge
+|
+import_stmt
 ;
 
 ddl_stmt:
 index_stmt
+|
+policy_stmt
+|
+view_stmt
+|
+keyspace_stmt
 ;
 
 index_stmt:
@@ -487,6 +600,30 @@ alter_index
 build_index
 ;
 
+policy_stmt:
+create_policy
+|
+drop_policy
+|
+create_mask
+|
+drop_mask
+;
+
+view_stmt:
+create_view
+|
+drop_view
+|
+refresh_view
+;
+
+keyspace_stmt:
+create_keyspace
+|
+drop_keyspace
+;
+
 fullselect:
 select_terms opt_order_by
 {
@@ -770,16 +907,19 @@ keyspace_term:
 keyspace_name opt_subpath opt_as_alias opt_use
 {
     $$ = algebra.NewKeyspaceTerm("", $1, $2, $3, $4.Keys(), $4.Indexes())
+    $$.SetConsistency($4.Consistency())
 }
 |
 namespace_name COLON keyspace_name opt_subpath opt_as_alias opt_use
 {
     $$ = algebra.NewKeyspaceTerm($1, $3, $4, $5, $6.Keys(), $6.Indexes())
+    $$.SetConsistency($6.Consistency())
 }
 |
 SYSTEM COLON keyspace_name opt_subpath opt_as_alias opt_use
 {
     $$ = algebra.NewKeyspaceTerm("#system", $3, $4, $5, $6.Keys(), $6.Indexes())
+    $$.SetConsistency($6.Consistency())
 }
 ;
 
@@ -846,6 +986,16 @@ use_index
 {
     $$ = algebra.NewUse(nil, $1)
 }
+|
+use_consistency
+{
+    $$ = algebra.NewUseConsistency(algebra.EMPTY_USE, $1)
+}
+|
+use_index use_consistency
+{
+    $$ = algebra.NewUseConsistency(algebra.NewUse(nil, $1), $2)
+}
 ;
 
 use_keys:
@@ -855,6 +1005,29 @@ USE opt_primary KEYS expr
 }
 ;
 
+opt_mutate_use:
+opt_use
+{
+    if $1.Consistency() != nil {
+        yylex.Error("USE CONSISTENCY is only supported in the FROM clause.")
+    }
+
+    $$ = $1
+}
+|
+use_key_range
+{
+    $$ = algebra.NewUseKeyRange($1)
+}
+;
+
+use_key_range:
+USE opt_primary KEYS BETWEEN b_expr AND b_expr
+{
+    $$ = algebra.NewKeyRange($5, $7)
+}
+;
+
 opt_primary:
 /* empty */
 {
@@ -870,6 +1043,26 @@ USE INDEX LPAREN index_refs RPAREN
 }
 ;
 
+/*************************************************
+ *
+ * USE CONSISTENCY names the scan consistency of a
+ * FROM term as in the request parameter. CONSISTENCY
+ * and the levels are not reserved words.
+ *
+ *************************************************/
+
+use_consistency:
+USE IDENTIFIER IDENTIFIER
+{
+    $$ = newConsistency(yylex, $2, $3, nil)
+}
+|
+USE IDENTIFIER IDENTIFIER LPAREN expr RPAREN
+{
+    $$ = newConsistency(yylex, $2, $3, $5)
+}
+;
+
 index_refs:
 index_ref
 {
@@ -1000,14 +1193,26 @@ group
 ;
 
 group:
-GROUP BY exprs opt_letting opt_having
+GROUP BY exprs opt_group_limit opt_letting opt_having
 {
-    $$ = algebra.NewGroup($3, $4, $5)
+    $$ = algebra.NewGroup($3, $4, $5, $6)
 }
 |
 letting
 {
-    $$ = algebra.NewGroup(nil, $1, nil)
+    $$ = algebra.NewGroup(nil, nil, $1, nil)
+}
+;
+
+opt_group_limit:
+/* empty */
+{
+    $$ = nil
+}
+|
+EACH LIMIT expr
+{
+    $$ = $3
 }
 ;
 
@@ -1184,17 +1389,31 @@ INSERT INTO keyspace_ref LPAREN key_expr opt_value_expr RPAREN fullselect opt_re
 ;
 
 keyspace_ref:
-namespace_name COLON keyspace_name opt_as_alias
+namespace_name COLON keyspace_path opt_as_alias
 {
     $$ = algebra.NewKeyspaceRef($1, $3, $4)
 }
 |
-keyspace_name opt_as_alias
+SYSTEM COLON keyspace_name opt_as_alias
+{
+    $$ = algebra.NewKeyspaceRef("#system", $3, $4)
+}
+|
+keyspace_path opt_as_alias
 {
     $$ = algebra.NewKeyspaceRef("", $1, $2)
 }
 ;
 
+keyspace_path:
+keyspace_name
+|
+keyspace_name DOT keyspace_name DOT keyspace_name
+{
+    $$ = datastore.KeyspacePath($1, $3, $5)
+}
+;
+
 opt_values_header:
 /* empty */
 |
@@ -1301,6 +1520,74 @@ UPSERT INTO keyspace_ref LPAREN key_expr opt_value_expr RPAREN fullselect opt_re
 ;
 
 
+/*************************************************
+ *
+ * IMPORT
+ *
+ *************************************************/
+
+/* IMPORT is returned by the lexer only at the start of a statement. */
+import_stmt:
+IMPORT INTO keyspace_ref FROM STR opt_import_options
+{
+    imp, err := algebra.NewImport($3, nil, nil, $5, $6)
+    if err != nil {
+        yylex.Error(err.Error())
+    }
+    $$ = imp
+}
+|
+IMPORT INTO keyspace_ref LPAREN key_expr opt_value_expr RPAREN FROM STR opt_import_options
+{
+    imp, err := algebra.NewImport($3, $5, $6, $9, $10)
+    if err != nil {
+        yylex.Error(err.Error())
+    }
+    $$ = imp
+}
+;
+
+/* FORMAT, BATCH and ERRORS are not reserved words */
+opt_import_options:
+/* empty */
+{
+    $$ = nil
+}
+|
+import_options
+;
+
+import_options:
+IDENTIFIER import_option
+{
+    $$ = value.NewValue(map[string]interface{}{strings.ToLower($1): $2})
+}
+|
+import_options IDENTIFIER import_option
+{
+    $1.SetField(strings.ToLower($2), $3)
+    $$ = $1
+}
+;
+
+import_option:
+IDENTIFIER
+{
+    $$ = value.NewValue($1)
+}
+|
+STR
+{
+    $$ = value.NewValue($1)
+}
+|
+INT
+{
+    $$ = value.NewValue($1)
+}
+;
+
+
 /*************************************************
  *
  * DELETE
@@ -1308,9 +1595,9 @@ UPSERT INTO keyspace_ref LPAREN key_expr opt_value_expr RPAREN fullselect opt_re
  *************************************************/
 
 delete:
-DELETE FROM keyspace_ref opt_use opt_where opt_limit opt_returning
+DELETE FROM keyspace_ref opt_mutate_use opt_where opt_limit opt_returning
 {
-    $$ = algebra.NewDelete($3, $4.Keys(), $4.Indexes(), $5, $6, $7)
+    $$ = algebra.NewDelete($3, $4.Keys(), $4.KeyRange(), $4.Indexes(), $5, $6, $7)
 }
 ;
 
@@ -1322,19 +1609,19 @@ DELETE FROM keyspace_ref opt_use opt_where opt_limit opt_returning
  *************************************************/
 
 update:
-UPDATE keyspace_ref opt_use set unset opt_where opt_limit opt_returning
+UPDATE keyspace_ref opt_mutate_use set unset opt_where opt_limit opt_returning
 {
-    $$ = algebra.NewUpdate($2, $3.Keys(), $3.Indexes(), $4, $5, $6, $7, $8)
+    $$ = algebra.NewUpdate($2, $3.Keys(), $3.KeyRange(), $3.Indexes(), $4, $5, $6, $7, $8)
 }
 |
-UPDATE keyspace_ref opt_use set opt_where opt_limit opt_returning
+UPDATE keyspace_ref opt_mutate_use set opt_where opt_limit opt_returning
 {
-    $$ = algebra.NewUpdate($2, $3.Keys(), $3.Indexes(), $4, nil, $5, $6, $7)
+    $$ = algebra.NewUpdate($2, $3.Keys(), $3.KeyRange(), $3.Indexes(), $4, nil, $5, $6, $7)
 }
 |
-UPDATE keyspace_ref opt_use unset opt_where opt_limit opt_returning
+UPDATE keyspace_ref opt_mutate_use unset opt_where opt_limit opt_returning
 {
-    $$ = algebra.NewUpdate($2, $3.Keys(), $3.Indexes(), nil, $4, $5, $6, $7)
+    $$ = algebra.NewUpdate($2, $3.Keys(), $3.KeyRange(), $3.Indexes(), nil, $4, $5, $6, $7)
 }
 ;
 
@@ -1589,17 +1876,40 @@ IDENTIFIER
 ;
 
 named_keyspace_ref:
-keyspace_name
+keyspace_path
 {
     $$ = algebra.NewKeyspaceRef("", $1, "")
 }
 |
-namespace_name COLON keyspace_name
+namespace_name COLON keyspace_path
 {
     $$ = algebra.NewKeyspaceRef($1, $3, "")
 }
 ;
 
+/* The namespace, keyspace and name of an index */
+keyspace_index_path:
+keyspace_name DOT index_name
+{
+    $$ = []string{"", $1, $3}
+}
+|
+namespace_name COLON keyspace_name DOT index_name
+{
+    $$ = []string{$1, $3, $5}
+}
+|
+keyspace_name DOT keyspace_name DOT keyspace_name DOT index_name
+{
+    $$ = []string{"", datastore.KeyspacePath($1, $3, $5), $7}
+}
+|
+namespace_name COLON keyspace_name DOT keyspace_name DOT keyspace_name DOT index_name
+{
+    $$ = []string{$1, datastore.KeyspacePath($3, $5, $7), $9}
+}
+;
+
 index_partition:
 /* empty */
 {
@@ -1700,9 +2010,9 @@ DROP PRIMARY INDEX ON named_keyspace_ref opt_index_using
     $$ = algebra.NewDropIndex($5, "#primary", $6) 
 }
 |
-DROP INDEX named_keyspace_ref DOT index_name opt_index_using
+DROP INDEX keyspace_index_path opt_index_using
 {
-    $$ = algebra.NewDropIndex($3, $5, $6)
+    $$ = algebra.NewDropIndex(algebra.NewKeyspaceRef($3[0], $3[1], ""), $3[2], $4)
 }
 ;
 
@@ -1713,9 +2023,9 @@ DROP INDEX named_keyspace_ref DOT index_name opt_index_using
  *************************************************/
 
 alter_index:
-ALTER INDEX named_keyspace_ref DOT index_name opt_index_using rename
+ALTER INDEX keyspace_index_path opt_index_using rename
 {
-    $$ = algebra.NewAlterIndex($3, $5, $6, $7)
+    $$ = algebra.NewAlterIndex(algebra.NewKeyspaceRef($3[0], $3[1], ""), $3[2], $4, $5)
 }
 
 rename:
@@ -1756,6 +2066,332 @@ index_names COMMA index_name
 ;
 
 
+/*************************************************
+ *
+ * CREATE POLICY
+ *
+ * POLICY is not a reserved word, so that it can
+ * still be used as an identifier.
+ *
+ *************************************************/
+
+create_policy:
+CREATE IDENTIFIER ON named_keyspace_ref USING policy_expr
+{
+    if !strings.EqualFold($2, "policy") {
+        yylex.Error(fmt.Sprintf("Unexpected %s after CREATE.", $2))
+    }
+
+    $$ = algebra.NewCreatePolicy($4, $6)
+}
+;
+
+policy_expr:
+expr
+{
+    exp := $1
+    if !exp.Indexable() {
+        yylex.Error(fmt.Sprintf("Expression not allowed in policy: %s", exp.String()))
+    }
+
+    $$ = exp
+}
+;
+
+
+/*************************************************
+ *
+ * DROP POLICY
+ *
+ *************************************************/
+
+drop_policy:
+DROP IDENTIFIER ON named_keyspace_ref
+{
+    if !strings.EqualFold($2, "policy") {
+        yylex.Error(fmt.Sprintf("Unexpected %s after DROP.", $2))
+    }
+
+    $$ = algebra.NewDropPolicy($4)
+}
+;
+
+
+/*************************************************
+ *
+ * CREATE MASK
+ *
+ * MASK is not a reserved word either.
+ *
+ *************************************************/
+
+create_mask:
+CREATE IDENTIFIER ON named_keyspace_ref LPAREN mask_path RPAREN USING STR
+{
+    if !strings.EqualFold($2, "mask") {
+        yylex.Error(fmt.Sprintf("Unexpected %s after CREATE.", $2))
+    }
+
+    if !expression.IsMaskType($9) {
+        yylex.Error(fmt.Sprintf("Invalid mask type: %s", $9))
+    }
+
+    $$ = algebra.NewCreateMask($4, $6.(expression.Path), $9)
+}
+;
+
+mask_path:
+expr
+{
+    exp := $1
+    if _, ok := exp.(expression.Path); !ok {
+        yylex.Error(fmt.Sprintf("Mask path must be a path: %s", exp.String()))
+        exp = expression.NewIdentifier("")
+    }
+
+    $$ = exp
+}
+;
+
+
+/*************************************************
+ *
+ * DROP MASK
+ *
+ *************************************************/
+
+drop_mask:
+DROP IDENTIFIER ON named_keyspace_ref LPAREN mask_path RPAREN
+{
+    if !strings.EqualFold($2, "mask") {
+        yylex.Error(fmt.Sprintf("Unexpected %s after DROP.", $2))
+    }
+
+    $$ = algebra.NewDropMask($4, $6.(expression.Path))
+}
+;
+
+
+/*************************************************
+ *
+ * CREATE VIEW and CREATE MATERIALIZED VIEW
+ *
+ *************************************************/
+
+create_view:
+CREATE VIEW named_keyspace_ref AS fullselect
+{
+    $$ = algebra.NewCreateView($3, $5, false)
+}
+|
+CREATE MATERIALIZED VIEW named_keyspace_ref AS fullselect
+{
+    $$ = algebra.NewCreateView($4, $6, true)
+}
+;
+
+
+/*************************************************
+ *
+ * DROP VIEW and DROP MATERIALIZED VIEW
+ *
+ *************************************************/
+
+drop_view:
+DROP VIEW named_keyspace_ref
+{
+    $$ = algebra.NewDropView($3, false)
+}
+|
+DROP MATERIALIZED VIEW named_keyspace_ref
+{
+    $$ = algebra.NewDropView($4, true)
+}
+;
+
+
+/*************************************************
+ *
+ * REFRESH MATERIALIZED VIEW
+ *
+ *************************************************/
+
+/* REFRESH is returned by the lexer only at the start of a statement. */
+refresh_view:
+REFRESH MATERIALIZED VIEW named_keyspace_ref
+{
+    $$ = algebra.NewRefreshView($4)
+}
+;
+
+
+/*************************************************
+ *
+ * CREATE TEMP KEYSPACE
+ *
+ * TEMP and TEMPORARY are not reserved words, so
+ * that they can still be used as identifiers.
+ *
+ *************************************************/
+
+create_keyspace:
+CREATE IDENTIFIER KEYSPACE named_keyspace_ref
+{
+    if !strings.EqualFold($2, "temp") && !strings.EqualFold($2, "temporary") {
+        yylex.Error(fmt.Sprintf("Unexpected %s after CREATE.", $2))
+    }
+
+    $$ = algebra.NewCreateKeyspace($4)
+}
+;
+
+
+/*************************************************
+ *
+ * DROP KEYSPACE
+ *
+ *************************************************/
+
+drop_keyspace:
+DROP KEYSPACE named_keyspace_ref
+{
+    $$ = algebra.NewDropKeyspace($3)
+}
+;
+
+
+/*************************************************
+ *
+ * GRANT ROLE
+ *
+ *************************************************/
+
+role_stmt:
+grant_role
+|
+revoke_role
+;
+
+grant_role:
+GRANT ROLE role_list TO user_list
+{
+    $$ = algebra.NewGrantRole($3, nil, $5)
+}
+|
+GRANT ROLE role_list ON keyspace_list TO user_list
+{
+    $$ = algebra.NewGrantRole($3, $5, $7)
+}
+;
+
+role_list:
+role_name
+{
+    $$ = []string{$1}
+}
+|
+role_list COMMA role_name
+{
+    $$ = append($1, $3)
+}
+;
+
+role_name:
+IDENTIFIER
+{
+    if !datastore.IsRole($1) {
+        yylex.Error(fmt.Sprintf("Unknown role: %s", $1))
+    }
+
+    $$ = $1
+}
+;
+
+keyspace_list:
+named_keyspace_ref
+{
+    $$ = []*algebra.KeyspaceRef{$1}
+}
+|
+keyspace_list COMMA named_keyspace_ref
+{
+    $$ = append($1, $3)
+}
+;
+
+user_list:
+user
+{
+    $$ = []string{$1}
+}
+|
+user_list COMMA user
+{
+    $$ = append($1, $3)
+}
+;
+
+user:
+IDENTIFIER
+|
+STR
+;
+
+
+/*************************************************
+ *
+ * REVOKE ROLE
+ *
+ *************************************************/
+
+revoke_role:
+REVOKE ROLE role_list FROM user_list
+{
+    $$ = algebra.NewRevokeRole($3, nil, $5)
+}
+|
+REVOKE ROLE role_list ON keyspace_list FROM user_list
+{
+    $$ = algebra.NewRevokeRole($3, $5, $7)
+}
+;
+
+
+/*************************************************
+ *
+ * SET
+ *
+ * Session settings; the value must be a constant.
+ * Script variables; the value may be any expression.
+ *
+ *************************************************/
+
+session_set:
+SET setting_name EQ expr
+{
+    if $4.Value() == nil {
+        yylex.Error(fmt.Sprintf("Session setting %s must be a constant.", $2))
+    }
+
+    $$ = algebra.NewSessionSet($2, $4)
+}
+|
+SET NAMED_PARAM EQ expr
+{
+    $$ = algebra.NewSetVariable($2, $4)
+}
+;
+
+setting_name:
+IDENTIFIER
+|
+NAMESPACE
+{
+    $$ = "namespace"
+}
+;
+
+
 /*************************************************
  *
  * Path
@@ -1844,12 +2480,12 @@ expr LBRACKET STAR RBRACKET
 /* Arithmetic */
 expr PLUS expr
 {
-    $$ = expression.NewAdd($1, $3)
+    $$ = newAdd($1, $3)
 }
 |
 expr MINUS expr
 {
-    $$ = expression.NewSub($1, $3)
+    $$ = newSub($1, $3)
 }
 |
 expr STAR expr
@@ -2179,6 +2815,16 @@ STR
 {
     $$ = expression.NewConstant(value.NewValue($1))
 }
+|
+IDENTIFIER STR
+{
+    lit, err := newTypedLiteral($1, $2)
+    if err != nil {
+        yylex.Error(err.Error())
+        lit = expression.NULL_EXPR
+    }
+    $$ = lit
+}
 ;
 
 
@@ -2199,6 +2845,11 @@ LBRACE opt_members RBRACE
 {
     $$ = expression.NewObjectConstruct($2)
 }
+|
+LBRACE error RBRACE
+{
+    $$ = expression.NULL_EXPR
+}
 ;
 
 opt_members:
@@ -2234,6 +2885,11 @@ LBRACKET opt_exprs RBRACKET
 {
     $$ = expression.NewArrayConstruct($2...)
 }
+|
+LBRACKET error RBRACKET
+{
+    $$ = expression.NULL_EXPR
+}
 ;
 
 opt_exprs:
@@ -2337,6 +2993,22 @@ ELSE expr
  *************************************************/
 
 function_expr:
+function_name LPAREN error RPAREN
+{
+    $$ = expression.NULL_EXPR
+}
+|
+CAST LPAREN expr AS cast_type RPAREN
+{
+    $$ = nil;
+    cast, err := expression.NewCast($3, $5);
+    if err != nil {
+        yylex.Error(err.Error());
+    } else {
+        $$ = cast;
+    }
+}
+|
 function_name LPAREN opt_exprs RPAREN
 {
     $$ = nil;
@@ -2356,6 +3028,53 @@ function_name LPAREN opt_exprs RPAREN
     }
 }
 |
+function_name LPAREN exprs WITH expr RPAREN
+{
+    $$ = nil;
+    if !yylex.(*lexer).parsingStatement() {
+        yylex.Error("Cannot use aggregate as an inline expression.");
+    } else {
+        agg, ok := algebra.GetAggregate($1, false);
+        if !ok {
+            yylex.Error(fmt.Sprintf("Invalid aggregate function %s.", $1));
+        } else if _, ok = agg.(algebra.OptionsAggregate); !ok {
+            yylex.Error(fmt.Sprintf("Aggregate function %s does not accept WITH options.", $1));
+        } else if len($3) < agg.MinArgs() || len($3) > agg.MaxArgs() {
+            yylex.Error(fmt.Sprintf("Wrong number of arguments to function %s.", $1));
+        } else if options := $5.Value(); options == nil {
+            yylex.Error("WITH value must be static.");
+        } else {
+            f := agg.Constructor()($3...).(algebra.OptionsAggregate);
+            err := f.SetOptions(options);
+            if err != nil {
+                yylex.Error(err.Error());
+            } else {
+                $$ = f;
+            }
+        }
+    }
+}
+|
+FIRST LPAREN expr ORDER BY sort_terms RPAREN
+{
+    $$ = nil;
+    if !yylex.(*lexer).parsingStatement() {
+        yylex.Error("Cannot use aggregate as an inline expression.");
+    } else {
+        $$ = algebra.NewFirst($3, $6.Expressions(), $6.Descending());
+    }
+}
+|
+LAST LPAREN expr ORDER BY sort_terms RPAREN
+{
+    $$ = nil;
+    if !yylex.(*lexer).parsingStatement() {
+        yylex.Error("Cannot use aggregate as an inline expression.");
+    } else {
+        $$ = algebra.NewLast($3, $6.Expressions(), $6.Descending());
+    }
+}
+|
 function_name LPAREN DISTINCT expr RPAREN
 {
     $$ = nil;
@@ -2395,6 +3114,25 @@ function_name:
 IDENTIFIER
 ;
 
+cast_type:
+IDENTIFIER
+|
+STRING
+{
+    $$ = "string"
+}
+|
+NUMBER
+{
+    $$ = "number"
+}
+|
+BOOLEAN
+{
+    $$ = "boolean"
+}
+;
+
 
 /*************************************************
  *
@@ -2481,6 +3219,12 @@ LPAREN expr RPAREN
     $$ = $2
 }
 |
+LPAREN error RPAREN
+{
+    /* Resume after the parentheses of a syntax error */
+    $$ = expression.NULL_EXPR
+}
+|
 subquery_expr
 {
     $$ = $1
@@ -0,0 +1,62 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/value"
+)
+
+// The index recommendations are made when the statement is
+// planned, so that ADVISE can be prepared like EXPLAIN.
+type Advise struct {
+	readonly
+	advice value.Value
+}
+
+func NewAdvise(advice value.Value) *Advise {
+	return &Advise{
+		advice: advice,
+	}
+}
+
+func (this *Advise) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitAdvise(this)
+}
+
+func (this *Advise) New() Operator {
+	return &Advise{}
+}
+
+func (this *Advise) Advice() value.Value {
+	return this.advice
+}
+
+func (this *Advise) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "Advise"}
+	r["advice"] = this.advice
+	return json.Marshal(r)
+}
+
+func (this *Advise) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_      string          `json:"#operator"`
+		Advice json.RawMessage `json:"advice"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.advice = value.NewValue([]byte(_unmarshalled.Advice))
+	return nil
+}
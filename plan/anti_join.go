@@ -0,0 +1,119 @@
+//  Copyright (c) 2014 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+/*
+AntiJoin passes the items whose term has no match among the results
+of an uncorrelated subquery, the build side, which is evaluated once
+into a hash set. It implements term NOT IN (subquery), and, with the
+exists flag, NOT EXISTS (subquery) decorrelated on an equality. With
+NOT EXISTS semantics, items whose term is MISSING are passed; without
+a term, all items are passed if the subquery has no results.
+*/
+type AntiJoin struct {
+	readonly
+	term   expression.Expression
+	query  *algebra.Select
+	exists bool
+}
+
+func NewAntiJoin(term expression.Expression, query *algebra.Select, exists bool) *AntiJoin {
+	return &AntiJoin{
+		term:   term,
+		query:  query,
+		exists: exists,
+	}
+}
+
+func (this *AntiJoin) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitAntiJoin(this)
+}
+
+func (this *AntiJoin) New() Operator {
+	return &AntiJoin{}
+}
+
+func (this *AntiJoin) Term() expression.Expression {
+	return this.term
+}
+
+func (this *AntiJoin) Query() *algebra.Select {
+	return this.query
+}
+
+func (this *AntiJoin) Exists() bool {
+	return this.exists
+}
+
+func (this *AntiJoin) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "AntiJoin"}
+
+	if this.term != nil {
+		r["term"] = expression.NewStringer().Visit(this.term)
+	}
+
+	r["subquery"] = this.query.String()
+
+	if this.exists {
+		r["exists"] = this.exists
+	}
+
+	return json.Marshal(r)
+}
+
+func (this *AntiJoin) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_        string `json:"#operator"`
+		Term     string `json:"term"`
+		Subquery string `json:"subquery"`
+		Exists   bool   `json:"exists"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	if _unmarshalled.Term != "" {
+		this.term, err = parser.Parse(_unmarshalled.Term)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _unmarshalled.Subquery == "" {
+		return fmt.Errorf("AntiJoin.UnmarshalJSON: missing subquery")
+	}
+
+	// The subquery is uncorrelated, so it parses as a statement
+	stmt, err := n1ql.ParseStatement(_unmarshalled.Subquery)
+	if err != nil {
+		return err
+	}
+
+	query, ok := stmt.(*algebra.Select)
+	if !ok {
+		return fmt.Errorf("AntiJoin.UnmarshalJSON: subquery is not a SELECT")
+	}
+
+	this.query = query
+	this.exists = _unmarshalled.Exists
+	return nil
+}
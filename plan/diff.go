@@ -0,0 +1,151 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"strconv"
+	"strings"
+)
+
+const (
+	DIFF_ADDED    = "added"    // The operator is only in the second plan
+	DIFF_REMOVED  = "removed"  // The operator is only in the first plan
+	DIFF_REPLACED = "replaced" // The operator is replaced by another
+	DIFF_CHANGED  = "changed"  // The details of the operator differ
+)
+
+/*
+Difference is a structural difference between two plans. The path
+names the operators from the root of the first plan, or of the second
+plan for added operators; operators are described by their name and
+the details of their graph.
+*/
+type Difference struct {
+	Path   string `json:"path"`
+	Change string `json:"change"`
+	Before string `json:"before,omitempty"`
+	After  string `json:"after,omitempty"`
+}
+
+/*
+Diff two plans, for instance the plans of a statement before and
+after an index change, operator by operator. The children of each
+pair of matching operators are aligned by operator name; the
+unaligned children of a pair are replaced, added or removed, and the
+descendants of replaced operators are not compared.
+*/
+func Diff(before, after Operator) ([]*Difference, error) {
+	b, err := NewGraph(before)
+	if err != nil {
+		return nil, err
+	}
+
+	a, err := NewGraph(after)
+	if err != nil {
+		return nil, err
+	}
+
+	rv := make([]*Difference, 0, 8)
+	if b.Operator != a.Operator {
+		return append(rv, &Difference{"/" + b.Operator, DIFF_REPLACED, b.describe(), a.describe()}), nil
+	}
+
+	return diffNodes(rv, "/"+b.Operator, b, a), nil
+}
+
+func diffNodes(rv []*Difference, path string, before, after *GraphNode) []*Difference {
+	if before.Detail != after.Detail {
+		rv = append(rv, &Difference{path, DIFF_CHANGED, flatten(before.Detail), flatten(after.Detail)})
+	}
+
+	bc, ac := before.Children, after.Children
+	i, j := 0, 0
+	for _, m := range append(alignNodes(bc, ac), [2]int{len(bc), len(ac)}) {
+		for ; i < m[0] && j < m[1]; i, j = i+1, j+1 {
+			rv = append(rv, &Difference{childPath(path, bc, i), DIFF_REPLACED,
+				bc[i].describe(), ac[j].describe()})
+		}
+
+		for ; i < m[0]; i++ {
+			rv = append(rv, &Difference{childPath(path, bc, i), DIFF_REMOVED, bc[i].describe(), ""})
+		}
+
+		for ; j < m[1]; j++ {
+			rv = append(rv, &Difference{childPath(path, ac, j), DIFF_ADDED, "", ac[j].describe()})
+		}
+
+		if i < len(bc) {
+			rv = diffNodes(rv, childPath(path, bc, i), bc[i], ac[j])
+			i, j = i+1, j+1
+		}
+	}
+
+	return rv
+}
+
+// The longest common subsequence of the operator names of the
+// children, as pairs of indexes.
+func alignNodes(before, after []*GraphNode) [][2]int {
+	lengths := make([][]int, len(before)+1)
+	for i := range lengths {
+		lengths[i] = make([]int, len(after)+1)
+	}
+
+	for i := len(before) - 1; i >= 0; i-- {
+		for j := len(after) - 1; j >= 0; j-- {
+			if before[i].Operator == after[j].Operator {
+				lengths[i][j] = lengths[i+1][j+1] + 1
+			} else if lengths[i+1][j] >= lengths[i][j+1] {
+				lengths[i][j] = lengths[i+1][j]
+			} else {
+				lengths[i][j] = lengths[i][j+1]
+			}
+		}
+	}
+
+	rv := make([][2]int, 0, lengths[0][0])
+	for i, j := 0, 0; i < len(before) && j < len(after); {
+		if before[i].Operator == after[j].Operator {
+			rv = append(rv, [2]int{i, j})
+			i, j = i+1, j+1
+		} else if lengths[i+1][j] >= lengths[i][j+1] {
+			i++
+		} else {
+			j++
+		}
+	}
+
+	return rv
+}
+
+// Children are numbered when their parent has several children of
+// the same operator.
+func childPath(path string, children []*GraphNode, i int) string {
+	name := children[i].Operator
+	for j, child := range children {
+		if j != i && child.Operator == name {
+			return path + "/" + name + "[" + strconv.Itoa(i) + "]"
+		}
+	}
+
+	return path + "/" + name
+}
+
+func (this *GraphNode) describe() string {
+	if this.Detail == "" {
+		return this.Operator
+	}
+
+	return this.Operator + " (" + flatten(this.Detail) + ")"
+}
+
+func flatten(detail string) string {
+	return strings.Replace(detail, "\n", "; ", -1)
+}
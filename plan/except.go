@@ -14,7 +14,6 @@ import (
 )
 
 type ExceptAll struct {
-	readonly
 	first  Operator
 	second Operator
 }
@@ -30,6 +29,10 @@ func (this *ExceptAll) Accept(visitor Visitor) (interface{}, error) {
 	return visitor.VisitExceptAll(this)
 }
 
+func (this *ExceptAll) Readonly() bool {
+	return this.first.Readonly() && this.second.Readonly()
+}
+
 func (this *ExceptAll) New() Operator {
 	return &ExceptAll{}
 }
@@ -11,12 +11,18 @@ package plan
 
 type Explain struct {
 	readonly
-	op Operator
+	op       Operator
+	format   string
+	features map[string]bool
+	notes    []string
 }
 
-func NewExplain(op Operator) *Explain {
+func NewExplain(op Operator, format string, features map[string]bool, notes []string) *Explain {
 	return &Explain{
-		op: op,
+		op:       op,
+		format:   format,
+		features: features,
+		notes:    notes,
 	}
 }
 
@@ -31,3 +37,17 @@ func (this *Explain) New() Operator {
 func (this *Explain) Operator() Operator {
 	return this.op
 }
+
+func (this *Explain) Format() string {
+	return this.format
+}
+
+// The feature flags the operator was planned with.
+func (this *Explain) Features() map[string]bool {
+	return this.features
+}
+
+// The plan choices that the capabilities of keyspaces limited.
+func (this *Explain) Notes() []string {
+	return this.notes
+}
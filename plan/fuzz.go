@@ -0,0 +1,90 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+// +build gofuzz
+
+package plan
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Fuzz is the go-fuzz entry point for plan unmarshaling. The input is
+the JSON of a single operator, as found in EXPLAIN output. Operators
+that unmarshal must marshal back to JSON that unmarshals to the same
+plan.
+
+Keyspaces are resolved against the datastore named by
+N1QL_FUZZ_DATASTORE, either a mock: URL or a file datastore
+directory; the default mock datastore matches the mock plan
+snapshots in test/planstability.
+*/
+func Fuzz(data []byte) int {
+	var op struct {
+		Operator string `json:"#operator"`
+	}
+
+	if json.Unmarshal(data, &op) != nil {
+		return 0
+	}
+
+	rv, err := MakeOperator(op.Operator, data)
+	if err != nil {
+		return 0
+	}
+
+	bytes1, err := json.Marshal(rv)
+	if err != nil {
+		return 0
+	}
+
+	rv, err = MakeOperator(op.Operator, bytes1)
+	if err != nil {
+		panic("plan does not unmarshal after marshaling: " + err.Error())
+	}
+
+	bytes2, err := json.Marshal(rv)
+	if err != nil || !bytes.Equal(bytes1, bytes2) {
+		panic("plan does not survive marshaling: " + string(bytes1))
+	}
+
+	return 1
+}
+
+const _FUZZ_DATASTORE = "mock:namespaces=1,keyspaces=2,items=100"
+
+func init() {
+	path := os.Getenv("N1QL_FUZZ_DATASTORE")
+	if path == "" {
+		path = _FUZZ_DATASTORE
+	}
+
+	var store datastore.Datastore
+	var err errors.Error
+	if strings.HasPrefix(path, "mock:") {
+		store, err = mock.NewDatastore(path)
+	} else {
+		store, err = file.NewDatastore(path)
+	}
+
+	if err != nil {
+		panic(err.Error())
+	}
+
+	datastore.SetDatastore(store)
+}
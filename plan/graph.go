@@ -0,0 +1,533 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/query/expression"
+)
+
+/*
+GraphNode is an operator of a plan, with a short description and
+the operators it runs. Graphs are used to export plans for
+visualization.
+*/
+type GraphNode struct {
+	Operator string
+	Detail   string
+	Children []*GraphNode
+}
+
+// Build the graph of a plan.
+func NewGraph(op Operator) (*GraphNode, error) {
+	node, err := op.Accept(&grapher{})
+	if err != nil {
+		return nil, err
+	}
+
+	return node.(*GraphNode), nil
+}
+
+// Number of operators in the graph.
+func (this *GraphNode) Size() int {
+	n := 1
+	for _, child := range this.Children {
+		n += child.Size()
+	}
+	return n
+}
+
+// The graph in Graphviz DOT format. Edges go from each operator to
+// the operators it runs.
+func (this *GraphNode) DOT() string {
+	var buf bytes.Buffer
+	buf.WriteString("digraph plan {\n")
+	buf.WriteString("    node [shape=box];\n")
+	this.writeDOT(&buf, 0)
+	buf.WriteString("}\n")
+	return buf.String()
+}
+
+// Write the node and its descendants, numbered from id. Returns the
+// next id.
+func (this *GraphNode) writeDOT(buf *bytes.Buffer, id int) int {
+	label := this.Operator
+	if this.Detail != "" {
+		label += "\n" + this.Detail
+	}
+
+	fmt.Fprintf(buf, "    n%d [label=%s];\n", id, dotQuote(label))
+
+	next := id + 1
+	for _, child := range this.Children {
+		fmt.Fprintf(buf, "    n%d -> n%d;\n", id, next)
+		next = child.writeDOT(buf, next)
+	}
+
+	return next
+}
+
+func dotQuote(s string) string {
+	s = strings.Replace(s, "\\", "\\\\", -1)
+	s = strings.Replace(s, "\"", "\\\"", -1)
+	s = strings.Replace(s, "\n", "\\n", -1)
+	return "\"" + s + "\""
+}
+
+// The graph as nested JSON for flame graphs. The value of each node
+// is the number of operators under it, itself included.
+func (this *GraphNode) Flame() map[string]interface{} {
+	rv := map[string]interface{}{
+		"name":  this.Operator,
+		"value": this.Size(),
+	}
+
+	if this.Detail != "" {
+		rv["detail"] = this.Detail
+	}
+
+	if len(this.Children) > 0 {
+		children := make([]interface{}, len(this.Children))
+		for i, child := range this.Children {
+			children[i] = child.Flame()
+		}
+		rv["children"] = children
+	}
+
+	return rv
+}
+
+// Visitor that builds the graph of a plan.
+type grapher struct {
+}
+
+func (this *grapher) node(operator, detail string, children ...Operator) (interface{}, error) {
+	rv := &GraphNode{
+		Operator: operator,
+		Detail:   detail,
+		Children: make([]*GraphNode, 0, len(children)),
+	}
+
+	for _, child := range children {
+		if child == nil {
+			continue
+		}
+
+		node, err := child.Accept(this)
+		if err != nil {
+			return nil, err
+		}
+
+		rv.Children = append(rv.Children, node.(*GraphNode))
+	}
+
+	return rv, nil
+}
+
+func exprDetail(name string, expr expression.Expression) string {
+	if expr == nil {
+		return ""
+	}
+
+	return name + ": " + expr.String()
+}
+
+func exprsDetail(name string, exprs expression.Expressions) string {
+	if len(exprs) == 0 {
+		return ""
+	}
+
+	s := make([]string, len(exprs))
+	for i, expr := range exprs {
+		s[i] = expr.String()
+	}
+
+	return name + ": " + strings.Join(s, ", ")
+}
+
+func details(d ...string) string {
+	rv := make([]string, 0, len(d))
+	for _, s := range d {
+		if s != "" {
+			rv = append(rv, s)
+		}
+	}
+
+	return strings.Join(rv, "\n")
+}
+
+// Scan
+
+func (this *grapher) VisitPrimaryScan(op *PrimaryScan) (interface{}, error) {
+	return this.node("PrimaryScan", details("index: "+op.Index().Name(),
+		"keyspace: "+op.Keyspace().Name(), exprDetail("limit", op.Limit())))
+}
+
+func (this *grapher) VisitParentScan(op *ParentScan) (interface{}, error) {
+	return this.node("ParentScan", "")
+}
+
+func (this *grapher) VisitIndexScan(op *IndexScan) (interface{}, error) {
+	covering := ""
+	if op.Covering() {
+		covering = "covering"
+	}
+
+	return this.node("IndexScan", details("index: "+op.Index().Name(),
+		"keyspace: "+op.Term().Keyspace(), covering, exprDetail("limit", op.Limit())))
+}
+
+func (this *grapher) VisitKeyScan(op *KeyScan) (interface{}, error) {
+	return this.node("KeyScan", exprDetail("keys", op.Keys()))
+}
+
+func (this *grapher) VisitValueScan(op *ValueScan) (interface{}, error) {
+	return this.node("ValueScan", fmt.Sprintf("values: %d", len(op.Values())))
+}
+
+func (this *grapher) VisitImportScan(op *ImportScan) (interface{}, error) {
+	return this.node("ImportScan", op.Format()+" "+op.Path())
+}
+
+func (this *grapher) VisitDummyScan(op *DummyScan) (interface{}, error) {
+	return this.node("DummyScan", "")
+}
+
+func (this *grapher) VisitCountScan(op *CountScan) (interface{}, error) {
+	return this.node("CountScan", "keyspace: "+op.Keyspace().Name())
+}
+
+func (this *grapher) VisitIntersectScan(op *IntersectScan) (interface{}, error) {
+	return this.node("IntersectScan", "", op.Scans()...)
+}
+
+func (this *grapher) VisitUnionScan(op *UnionScan) (interface{}, error) {
+	return this.node("UnionScan", "", op.Scans()...)
+}
+
+// Fetch
+
+func (this *grapher) VisitFetch(op *Fetch) (interface{}, error) {
+	return this.node("Fetch", details("keyspace: "+op.Keyspace().Name(), "as: "+op.Term().Alias()))
+}
+
+// Join
+
+func (this *grapher) VisitJoin(op *Join) (interface{}, error) {
+	return this.node("Join", details("keyspace: "+op.Keyspace().Name(),
+		"as: "+op.Term().Alias(), outerDetail(op.Outer())))
+}
+
+func (this *grapher) VisitNest(op *Nest) (interface{}, error) {
+	return this.node("Nest", details("keyspace: "+op.Keyspace().Name(),
+		"as: "+op.Term().Alias(), outerDetail(op.Outer())))
+}
+
+func (this *grapher) VisitUnnest(op *Unnest) (interface{}, error) {
+	return this.node("Unnest", details(exprDetail("expr", op.Term().Expression()),
+		"as: "+op.Alias(), outerDetail(op.Term().Outer())))
+}
+
+func (this *grapher) VisitAntiJoin(op *AntiJoin) (interface{}, error) {
+	name := "not exists"
+	if op.Exists() {
+		name = "exists"
+	}
+
+	return this.node("AntiJoin", exprDetail(name, op.Term()))
+}
+
+func outerDetail(outer bool) string {
+	if outer {
+		return "outer"
+	}
+	return ""
+}
+
+// Let + Letting
+
+func (this *grapher) VisitLet(op *Let) (interface{}, error) {
+	vars := make([]string, len(op.Bindings()))
+	for i, b := range op.Bindings() {
+		vars[i] = b.Variable()
+	}
+
+	return this.node("Let", "variables: "+strings.Join(vars, ", "))
+}
+
+// Filter
+
+func (this *grapher) VisitFilter(op *Filter) (interface{}, error) {
+	return this.node("Filter", exprDetail("condition", op.Condition()))
+}
+
+// Group
+
+func (this *grapher) VisitInitialGroup(op *InitialGroup) (interface{}, error) {
+	return this.node("InitialGroup", exprsDetail("keys", op.Keys()))
+}
+
+func (this *grapher) VisitIntermediateGroup(op *IntermediateGroup) (interface{}, error) {
+	return this.node("IntermediateGroup", exprsDetail("keys", op.Keys()))
+}
+
+func (this *grapher) VisitFinalGroup(op *FinalGroup) (interface{}, error) {
+	return this.node("FinalGroup", exprsDetail("keys", op.Keys()))
+}
+
+// Project
+
+func (this *grapher) VisitInitialProject(op *InitialProject) (interface{}, error) {
+	return this.node("InitialProject", fmt.Sprintf("terms: %d", len(op.Terms())))
+}
+
+func (this *grapher) VisitFinalProject(op *FinalProject) (interface{}, error) {
+	return this.node("FinalProject", "")
+}
+
+// Distinct
+
+func (this *grapher) VisitDistinct(op *Distinct) (interface{}, error) {
+	return this.node("Distinct", "")
+}
+
+// Set operators
+
+func (this *grapher) VisitUnionAll(op *UnionAll) (interface{}, error) {
+	return this.node("UnionAll", "", op.Children()...)
+}
+
+func (this *grapher) VisitIntersectAll(op *IntersectAll) (interface{}, error) {
+	return this.node("IntersectAll", "", op.First(), op.Second())
+}
+
+func (this *grapher) VisitExceptAll(op *ExceptAll) (interface{}, error) {
+	return this.node("ExceptAll", "", op.First(), op.Second())
+}
+
+// Order
+
+func (this *grapher) VisitOrder(op *Order) (interface{}, error) {
+	terms := make([]string, len(op.Terms()))
+	for i, term := range op.Terms() {
+		terms[i] = term.String()
+	}
+
+	return this.node("Order", details("by: "+strings.Join(terms, ", "),
+		exprDetail("offset", op.Offset()), exprDetail("limit", op.Limit())))
+}
+
+// Offset
+
+func (this *grapher) VisitOffset(op *Offset) (interface{}, error) {
+	return this.node("Offset", exprDetail("expr", op.Expression()))
+}
+
+func (this *grapher) VisitLimit(op *Limit) (interface{}, error) {
+	return this.node("Limit", exprDetail("expr", op.Expression()))
+}
+
+// Insert
+
+func (this *grapher) VisitSendInsert(op *SendInsert) (interface{}, error) {
+	return this.node("SendInsert", "keyspace: "+op.Keyspace().Name())
+}
+
+// Upsert
+
+func (this *grapher) VisitSendUpsert(op *SendUpsert) (interface{}, error) {
+	return this.node("SendUpsert", "keyspace: "+op.Keyspace().Name())
+}
+
+// Delete
+
+func (this *grapher) VisitSendDelete(op *SendDelete) (interface{}, error) {
+	return this.node("SendDelete", "keyspace: "+op.Keyspace().Name())
+}
+
+// Update
+
+func (this *grapher) VisitClone(op *Clone) (interface{}, error) {
+	return this.node("Clone", "")
+}
+
+func (this *grapher) VisitSet(op *Set) (interface{}, error) {
+	return this.node("Set", "")
+}
+
+func (this *grapher) VisitUnset(op *Unset) (interface{}, error) {
+	return this.node("Unset", "")
+}
+
+func (this *grapher) VisitSendUpdate(op *SendUpdate) (interface{}, error) {
+	return this.node("SendUpdate", "keyspace: "+op.Keyspace().Name())
+}
+
+// Merge
+
+func (this *grapher) VisitMerge(op *Merge) (interface{}, error) {
+	return this.node("Merge", "keyspace: "+op.Keyspace().Name(),
+		op.Update(), op.Delete(), op.Insert())
+}
+
+// Framework
+
+func (this *grapher) VisitAlias(op *Alias) (interface{}, error) {
+	return this.node("Alias", "as: "+op.Alias())
+}
+
+func (this *grapher) VisitAuthorize(op *Authorize) (interface{}, error) {
+	return this.node("Authorize", "", op.Child())
+}
+
+func (this *grapher) VisitParallel(op *Parallel) (interface{}, error) {
+	detail := ""
+	if op.MaxParallelism() > 0 {
+		detail = fmt.Sprintf("max parallelism: %d", op.MaxParallelism())
+	}
+
+	return this.node("Parallel", detail, op.Child())
+}
+
+func (this *grapher) VisitSequence(op *Sequence) (interface{}, error) {
+	return this.node("Sequence", "", op.Children()...)
+}
+
+func (this *grapher) VisitDiscard(op *Discard) (interface{}, error) {
+	return this.node("Discard", "")
+}
+
+func (this *grapher) VisitStream(op *Stream) (interface{}, error) {
+	return this.node("Stream", "")
+}
+
+func (this *grapher) VisitOutfile(op *Outfile) (interface{}, error) {
+	return this.node("Outfile", op.Format()+" "+op.Path())
+}
+
+func (this *grapher) VisitCollect(op *Collect) (interface{}, error) {
+	return this.node("Collect", "")
+}
+
+func (this *grapher) VisitChannel(op *Channel) (interface{}, error) {
+	return this.node("Channel", "")
+}
+
+// Index DDL
+
+func (this *grapher) VisitCreatePrimaryIndex(op *CreatePrimaryIndex) (interface{}, error) {
+	return this.node("CreatePrimaryIndex", "keyspace: "+op.Keyspace().Name())
+}
+
+func (this *grapher) VisitCreateIndex(op *CreateIndex) (interface{}, error) {
+	return this.node("CreateIndex", details("index: "+op.Node().Name(),
+		"keyspace: "+op.Keyspace().Name()))
+}
+
+func (this *grapher) VisitDropIndex(op *DropIndex) (interface{}, error) {
+	return this.node("DropIndex", "index: "+op.Index().Name())
+}
+
+func (this *grapher) VisitAlterIndex(op *AlterIndex) (interface{}, error) {
+	return this.node("AlterIndex", "index: "+op.Index().Name())
+}
+
+func (this *grapher) VisitBuildIndexes(op *BuildIndexes) (interface{}, error) {
+	return this.node("BuildIndexes", "keyspace: "+op.Keyspace().Name())
+}
+
+// Policy DDL
+
+func (this *grapher) VisitCreatePolicy(op *CreatePolicy) (interface{}, error) {
+	return this.node("CreatePolicy", "keyspace: "+op.Keyspace().Name())
+}
+
+func (this *grapher) VisitDropPolicy(op *DropPolicy) (interface{}, error) {
+	return this.node("DropPolicy", "keyspace: "+op.Keyspace().Name())
+}
+
+func (this *grapher) VisitCreateMask(op *CreateMask) (interface{}, error) {
+	return this.node("CreateMask", details("keyspace: "+op.Keyspace().Name(),
+		exprDetail("path", op.Path())))
+}
+
+func (this *grapher) VisitDropMask(op *DropMask) (interface{}, error) {
+	return this.node("DropMask", details("keyspace: "+op.Keyspace().Name(),
+		exprDetail("path", op.Path())))
+}
+
+func (this *grapher) VisitCreateView(op *CreateView) (interface{}, error) {
+	return this.node("CreateView", "view: "+op.Name())
+}
+
+func (this *grapher) VisitDropView(op *DropView) (interface{}, error) {
+	return this.node("DropView", "view: "+op.Name())
+}
+
+func (this *grapher) VisitRefreshView(op *RefreshView) (interface{}, error) {
+	return this.node("RefreshView", "view: "+op.Name())
+}
+
+// Temporary keyspaces
+
+func (this *grapher) VisitCreateKeyspace(op *CreateKeyspace) (interface{}, error) {
+	return this.node("CreateKeyspace", "keyspace: "+op.Name())
+}
+
+func (this *grapher) VisitDropKeyspace(op *DropKeyspace) (interface{}, error) {
+	return this.node("DropKeyspace", "keyspace: "+op.Name())
+}
+
+// Roles
+
+func (this *grapher) VisitGrantRole(op *GrantRole) (interface{}, error) {
+	return this.node("GrantRole", "users: "+strings.Join(op.Users(), ", "))
+}
+
+func (this *grapher) VisitRevokeRole(op *RevokeRole) (interface{}, error) {
+	return this.node("RevokeRole", "users: "+strings.Join(op.Users(), ", "))
+}
+
+// Session
+
+func (this *grapher) VisitSessionSet(op *SessionSet) (interface{}, error) {
+	return this.node("SessionSet", "name: "+op.Name())
+}
+
+func (this *grapher) VisitSetVariable(op *SetVariable) (interface{}, error) {
+	return this.node("SetVariable", "name: $"+op.Name())
+}
+
+// Explain
+
+func (this *grapher) VisitExplain(op *Explain) (interface{}, error) {
+	return this.node("Explain", "", op.Operator())
+}
+
+// Advise
+
+func (this *grapher) VisitAdvise(op *Advise) (interface{}, error) {
+	return this.node("Advise", "")
+}
+
+// Prepare
+
+func (this *grapher) VisitPrepare(op *Prepare) (interface{}, error) {
+	return this.node("Prepare", "")
+}
+
+// Extensions
+
+func (this *grapher) VisitExtension(op Extension) (interface{}, error) {
+	return this.node(op.Name(), "")
+}
@@ -22,12 +22,15 @@ type InitialGroup struct {
 	readonly
 	keys       expression.Expressions
 	aggregates algebra.Aggregates
+	limit      expression.Expression
 }
 
-func NewInitialGroup(keys expression.Expressions, aggregates algebra.Aggregates) *InitialGroup {
+func NewInitialGroup(keys expression.Expressions, aggregates algebra.Aggregates,
+	limit expression.Expression) *InitialGroup {
 	return &InitialGroup{
 		keys:       keys,
 		aggregates: aggregates,
+		limit:      limit,
 	}
 }
 
@@ -47,6 +50,11 @@ func (this *InitialGroup) Aggregates() algebra.Aggregates {
 	return this.aggregates
 }
 
+// The EACH LIMIT of the items of each group, or nil.
+func (this *InitialGroup) Limit() expression.Expression {
+	return this.limit
+}
+
 func (this *InitialGroup) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "InitialGroup"}
 	keylist := make([]string, 0, len(this.keys))
@@ -59,14 +67,18 @@ func (this *InitialGroup) MarshalJSON() ([]byte, error) {
 		s = append(s, expression.NewStringer().Visit(agg))
 	}
 	r["aggregates"] = s
+	if this.limit != nil {
+		r["limit"] = expression.NewStringer().Visit(this.limit)
+	}
 	return json.Marshal(r)
 }
 
 func (this *InitialGroup) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_    string   "#operator"
-		Keys []string "group_keys"
-		Aggs []string "aggregates"
+		_     string   "#operator"
+		Keys  []string "group_keys"
+		Aggs  []string "aggregates"
+		Limit string   `json:"limit"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -92,6 +104,13 @@ func (this *InitialGroup) UnmarshalJSON(body []byte) error {
 		this.aggregates[i], _ = agg_expr.(algebra.Aggregate)
 	}
 
+	if _unmarshalled.Limit != "" {
+		this.limit, err = parser.Parse(_unmarshalled.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -100,12 +119,15 @@ type IntermediateGroup struct {
 	readonly
 	keys       expression.Expressions
 	aggregates algebra.Aggregates
+	limit      expression.Expression
 }
 
-func NewIntermediateGroup(keys expression.Expressions, aggregates algebra.Aggregates) *IntermediateGroup {
+func NewIntermediateGroup(keys expression.Expressions, aggregates algebra.Aggregates,
+	limit expression.Expression) *IntermediateGroup {
 	return &IntermediateGroup{
 		keys:       keys,
 		aggregates: aggregates,
+		limit:      limit,
 	}
 }
 
@@ -125,6 +147,11 @@ func (this *IntermediateGroup) Aggregates() algebra.Aggregates {
 	return this.aggregates
 }
 
+// The EACH LIMIT of the items of each group, or nil.
+func (this *IntermediateGroup) Limit() expression.Expression {
+	return this.limit
+}
+
 func (this *IntermediateGroup) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "IntermediateGroup"}
 	keylist := make([]string, 0, len(this.keys))
@@ -137,14 +164,18 @@ func (this *IntermediateGroup) MarshalJSON() ([]byte, error) {
 		s = append(s, expression.NewStringer().Visit(agg))
 	}
 	r["aggregates"] = s
+	if this.limit != nil {
+		r["limit"] = expression.NewStringer().Visit(this.limit)
+	}
 	return json.Marshal(r)
 }
 
 func (this *IntermediateGroup) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_    string   "#operator"
-		Keys []string "group_keys"
-		Aggs []string "aggregates"
+		_     string   "#operator"
+		Keys  []string "group_keys"
+		Aggs  []string "aggregates"
+		Limit string   `json:"limit"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -170,6 +201,13 @@ func (this *IntermediateGroup) UnmarshalJSON(body []byte) error {
 		this.aggregates[i], _ = agg_expr.(algebra.Aggregate)
 	}
 
+	if _unmarshalled.Limit != "" {
+		this.limit, err = parser.Parse(_unmarshalled.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
 
@@ -178,12 +216,15 @@ type FinalGroup struct {
 	readonly
 	keys       expression.Expressions
 	aggregates algebra.Aggregates
+	limit      expression.Expression
 }
 
-func NewFinalGroup(keys expression.Expressions, aggregates algebra.Aggregates) *FinalGroup {
+func NewFinalGroup(keys expression.Expressions, aggregates algebra.Aggregates,
+	limit expression.Expression) *FinalGroup {
 	return &FinalGroup{
 		keys:       keys,
 		aggregates: aggregates,
+		limit:      limit,
 	}
 }
 
@@ -203,6 +244,11 @@ func (this *FinalGroup) Aggregates() algebra.Aggregates {
 	return this.aggregates
 }
 
+// The EACH LIMIT of the items of each group, or nil.
+func (this *FinalGroup) Limit() expression.Expression {
+	return this.limit
+}
+
 func (this *FinalGroup) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "FinalGroup"}
 	keylist := make([]string, 0, len(this.keys))
@@ -215,14 +261,18 @@ func (this *FinalGroup) MarshalJSON() ([]byte, error) {
 		s = append(s, expression.NewStringer().Visit(agg))
 	}
 	r["aggregates"] = s
+	if this.limit != nil {
+		r["limit"] = expression.NewStringer().Visit(this.limit)
+	}
 	return json.Marshal(r)
 }
 
 func (this *FinalGroup) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_    string   "#operator"
-		Keys []string "group_keys"
-		Aggs []string "aggregates"
+		_     string   "#operator"
+		Keys  []string "group_keys"
+		Aggs  []string "aggregates"
+		Limit string   `json:"limit"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -248,5 +298,12 @@ func (this *FinalGroup) UnmarshalJSON(body []byte) error {
 		this.aggregates[i], _ = agg_expr.(algebra.Aggregate)
 	}
 
+	if _unmarshalled.Limit != "" {
+		this.limit, err = parser.Parse(_unmarshalled.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
@@ -0,0 +1,136 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+/*
+ImportScan reads the rows of a file on the query server, for IMPORT,
+and produces them keyed for SendUpsert. Rows that cannot be read or
+keyed are written to the errors file, if any.
+*/
+type ImportScan struct {
+	readwrite
+	path   string
+	format string
+	key    expression.Expression
+	value  expression.Expression
+	batch  int
+	errors string
+}
+
+func NewImportScan(path, format string, key, value expression.Expression, batch int, errors string) *ImportScan {
+	return &ImportScan{
+		path:   path,
+		format: format,
+		key:    key,
+		value:  value,
+		batch:  batch,
+		errors: errors,
+	}
+}
+
+func (this *ImportScan) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitImportScan(this)
+}
+
+func (this *ImportScan) New() Operator {
+	return &ImportScan{}
+}
+
+func (this *ImportScan) Path() string {
+	return this.path
+}
+
+func (this *ImportScan) Format() string {
+	return this.format
+}
+
+func (this *ImportScan) Key() expression.Expression {
+	return this.key
+}
+
+func (this *ImportScan) Value() expression.Expression {
+	return this.value
+}
+
+func (this *ImportScan) Batch() int {
+	return this.batch
+}
+
+func (this *ImportScan) Errors() string {
+	return this.errors
+}
+
+func (this *ImportScan) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "ImportScan"}
+	r["path"] = this.path
+	r["format"] = this.format
+
+	if this.key != nil {
+		r["key"] = this.key.String()
+	}
+
+	if this.value != nil {
+		r["value"] = this.value.String()
+	}
+
+	if this.batch > 0 {
+		r["batch"] = this.batch
+	}
+
+	if this.errors != "" {
+		r["errors"] = this.errors
+	}
+
+	return json.Marshal(r)
+}
+
+func (this *ImportScan) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Path      string `json:"path"`
+		Format    string `json:"format"`
+		KeyExpr   string `json:"key"`
+		ValueExpr string `json:"value"`
+		Batch     int    `json:"batch"`
+		Errors    string `json:"errors"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	if _unmarshalled.KeyExpr != "" {
+		this.key, err = parser.Parse(_unmarshalled.KeyExpr)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _unmarshalled.ValueExpr != "" {
+		this.value, err = parser.Parse(_unmarshalled.ValueExpr)
+		if err != nil {
+			return err
+		}
+	}
+
+	this.path = _unmarshalled.Path
+	this.format = _unmarshalled.Format
+	this.batch = _unmarshalled.Batch
+	this.errors = _unmarshalled.Errors
+	return nil
+}
@@ -24,16 +24,20 @@ type SendInsert struct {
 	key      expression.Expression
 	value    expression.Expression
 	limit    expression.Expression
+	policy   expression.Expression
 }
 
+// The policy, if any, is the unqualified predicate of the
+// row-level security policy on the keyspace.
 func NewSendInsert(keyspace datastore.Keyspace, alias string,
-	key, value, limit expression.Expression) *SendInsert {
+	key, value, limit, policy expression.Expression) *SendInsert {
 	return &SendInsert{
 		keyspace: keyspace,
 		alias:    alias,
 		key:      key,
 		value:    value,
 		limit:    limit,
+		policy:   policy,
 	}
 }
 
@@ -65,6 +69,10 @@ func (this *SendInsert) Limit() expression.Expression {
 	return this.limit
 }
 
+func (this *SendInsert) Policy() expression.Expression {
+	return this.policy
+}
+
 func (this *SendInsert) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "SendInsert"}
 	r["keyspace"] = this.keyspace.Name()
@@ -80,6 +88,10 @@ func (this *SendInsert) MarshalJSON() ([]byte, error) {
 		r["value"] = this.value.String()
 	}
 
+	if this.policy != nil {
+		r["policy"] = this.policy.String()
+	}
+
 	return json.Marshal(r)
 }
 
@@ -92,6 +104,7 @@ func (this *SendInsert) UnmarshalJSON(body []byte) error {
 		Names     string `json:"namespace"`
 		Alias     string `json:"alias"`
 		Limit     string `json:"limit"`
+		Policy    string `json:"policy"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -122,6 +135,13 @@ func (this *SendInsert) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Policy != "" {
+		this.policy, err = parser.Parse(_unmarshalled.Policy)
+		if err != nil {
+			return err
+		}
+	}
+
 	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
 	return err
 }
@@ -14,7 +14,6 @@ import (
 )
 
 type IntersectAll struct {
-	readonly
 	first  Operator
 	second Operator
 }
@@ -30,6 +29,10 @@ func (this *IntersectAll) Accept(visitor Visitor) (interface{}, error) {
 	return visitor.VisitIntersectAll(this)
 }
 
+func (this *IntersectAll) Readonly() bool {
+	return this.first.Readonly() && this.second.Readonly()
+}
+
 func (this *IntersectAll) New() Operator {
 	return &IntersectAll{}
 }
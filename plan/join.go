@@ -23,13 +23,17 @@ type Join struct {
 	keyspace datastore.Keyspace
 	term     *algebra.KeyspaceTerm
 	outer    bool
+	policy   expression.Expression
 }
 
-func NewJoin(keyspace datastore.Keyspace, join *algebra.Join) *Join {
+// The policy, if any, is the unqualified predicate of the
+// row-level security policy on the keyspace.
+func NewJoin(keyspace datastore.Keyspace, join *algebra.Join, policy expression.Expression) *Join {
 	return &Join{
 		keyspace: keyspace,
 		term:     join.Right(),
 		outer:    join.Outer(),
+		policy:   policy,
 	}
 }
 
@@ -53,6 +57,10 @@ func (this *Join) Outer() bool {
 	return this.outer
 }
 
+func (this *Join) Policy() expression.Expression {
+	return this.policy
+}
+
 func (this *Join) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "Join"}
 	r["namespace"] = this.term.Namespace()
@@ -66,17 +74,22 @@ func (this *Join) MarshalJSON() ([]byte, error) {
 	if this.term.As() != "" {
 		r["as"] = this.term.As()
 	}
+
+	if this.policy != nil {
+		r["policy"] = expression.NewStringer().Visit(this.policy)
+	}
 	return json.Marshal(r)
 }
 
 func (this *Join) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_     string `json:"#operator"`
-		Names string `json:"namespace"`
-		Keys  string `json:"keyspace"`
-		On    string `json:"on_keys"`
-		Outer bool   `json:"outer"`
-		As    string `json:"as"`
+		_      string `json:"#operator"`
+		Names  string `json:"namespace"`
+		Keys   string `json:"keyspace"`
+		On     string `json:"on_keys"`
+		Outer  bool   `json:"outer"`
+		As     string `json:"as"`
+		Policy string `json:"policy"`
 	}
 	var keys_expr expression.Expression
 
@@ -92,6 +105,13 @@ func (this *Join) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Policy != "" {
+		this.policy, err = parser.Parse(_unmarshalled.Policy)
+		if err != nil {
+			return err
+		}
+	}
+
 	this.outer = _unmarshalled.Outer
 	this.term = algebra.NewKeyspaceTerm(_unmarshalled.Names, _unmarshalled.Keys,
 		nil, _unmarshalled.As, keys_expr, nil)
@@ -104,13 +124,17 @@ type Nest struct {
 	keyspace datastore.Keyspace
 	term     *algebra.KeyspaceTerm
 	outer    bool
+	policy   expression.Expression
 }
 
-func NewNest(keyspace datastore.Keyspace, nest *algebra.Nest) *Nest {
+// The policy, if any, is the unqualified predicate of the
+// row-level security policy on the keyspace.
+func NewNest(keyspace datastore.Keyspace, nest *algebra.Nest, policy expression.Expression) *Nest {
 	return &Nest{
 		keyspace: keyspace,
 		term:     nest.Right(),
 		outer:    nest.Outer(),
+		policy:   policy,
 	}
 }
 
@@ -134,6 +158,10 @@ func (this *Nest) Outer() bool {
 	return this.outer
 }
 
+func (this *Nest) Policy() expression.Expression {
+	return this.policy
+}
+
 func (this *Nest) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "Nest"}
 	r["namespace"] = this.term.Namespace()
@@ -147,17 +175,22 @@ func (this *Nest) MarshalJSON() ([]byte, error) {
 	if this.term.As() != "" {
 		r["as"] = this.term.As()
 	}
+
+	if this.policy != nil {
+		r["policy"] = expression.NewStringer().Visit(this.policy)
+	}
 	return json.Marshal(r)
 }
 
 func (this *Nest) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_     string `json:"#operator"`
-		Names string `json:"namespace"`
-		Keys  string `json:"keyspace"`
-		On    string `json:"on_keys"`
-		Outer bool   `json:"outer"`
-		As    string `json:"as"`
+		_      string `json:"#operator"`
+		Names  string `json:"namespace"`
+		Keys   string `json:"keyspace"`
+		On     string `json:"on_keys"`
+		Outer  bool   `json:"outer"`
+		As     string `json:"as"`
+		Policy string `json:"policy"`
 	}
 	var keys_expr expression.Expression
 
@@ -173,6 +206,13 @@ func (this *Nest) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Policy != "" {
+		this.policy, err = parser.Parse(_unmarshalled.Policy)
+		if err != nil {
+			return err
+		}
+	}
+
 	this.outer = _unmarshalled.Outer
 	this.term = algebra.NewKeyspaceTerm(_unmarshalled.Names, _unmarshalled.Keys,
 		nil, _unmarshalled.As, keys_expr, nil)
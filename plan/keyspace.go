@@ -0,0 +1,122 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+)
+
+// Create temporary keyspace
+type CreateKeyspace struct {
+	readwrite
+	namespace string
+	name      string
+}
+
+func NewCreateKeyspace(namespace, name string) *CreateKeyspace {
+	return &CreateKeyspace{
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (this *CreateKeyspace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateKeyspace(this)
+}
+
+func (this *CreateKeyspace) New() Operator {
+	return &CreateKeyspace{}
+}
+
+func (this *CreateKeyspace) Namespace() string {
+	return this.namespace
+}
+
+func (this *CreateKeyspace) Name() string {
+	return this.name
+}
+
+func (this *CreateKeyspace) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "CreateKeyspace"}
+	r["namespace"] = this.namespace
+	r["name"] = this.name
+	return json.Marshal(r)
+}
+
+func (this *CreateKeyspace) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.namespace = _unmarshalled.Namespace
+	this.name = _unmarshalled.Name
+	return nil
+}
+
+// Drop temporary keyspace
+type DropKeyspace struct {
+	readwrite
+	namespace string
+	name      string
+}
+
+func NewDropKeyspace(namespace, name string) *DropKeyspace {
+	return &DropKeyspace{
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (this *DropKeyspace) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropKeyspace(this)
+}
+
+func (this *DropKeyspace) New() Operator {
+	return &DropKeyspace{}
+}
+
+func (this *DropKeyspace) Namespace() string {
+	return this.namespace
+}
+
+func (this *DropKeyspace) Name() string {
+	return this.name
+}
+
+func (this *DropKeyspace) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "DropKeyspace"}
+	r["namespace"] = this.namespace
+	r["name"] = this.name
+	return json.Marshal(r)
+}
+
+func (this *DropKeyspace) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.namespace = _unmarshalled.Namespace
+	this.name = _unmarshalled.Name
+	return nil
+}
@@ -11,6 +11,7 @@ package plan
 
 import (
 	"fmt"
+	"sync"
 )
 
 // Helper function to create a specific operator given its name
@@ -31,7 +32,79 @@ func MakeOperator(name string, body []byte) (Operator, error) {
 // GetOperator exposes the operators map to other packages
 func GetOperator(name string) (Operator, bool) {
 	rv, ok := _OPERATORS[name]
-	return rv, ok
+	if ok {
+		return rv, ok
+	}
+
+	_EXTENSIONS.RLock()
+	factory, ok := _EXTENSIONS.factories[name]
+	_EXTENSIONS.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	return factory(), true
+}
+
+/*
+Extension is implemented by the operators registered with
+RegisterOperator. Their Accept calls VisitExtension, so that visitors
+handle operators from outside the core without a method for each.
+*/
+type Extension interface {
+	Operator
+	Name() string // The name the operator is registered under
+}
+
+// OperatorFactory returns a new, empty operator, to be unmarshaled.
+type OperatorFactory func() Operator
+
+/*
+_EXTENSIONS holds the operators registered by packages outside the
+core, such as search scans, external tables and UDF operators, so
+that plans holding them can be marshaled and unmarshaled like any
+other.
+*/
+var _EXTENSIONS = struct {
+	sync.RWMutex
+	factories map[string]OperatorFactory
+}{
+	factories: make(map[string]OperatorFactory),
+}
+
+/*
+RegisterOperator adds an operator type, under the name its MarshalJSON
+writes as "#operator". The operators must implement Extension. The
+names of the core operators, and those already registered, cannot be
+replaced.
+*/
+func RegisterOperator(name string, factory OperatorFactory) error {
+	if name == "" || factory == nil {
+		return fmt.Errorf("Operator name and factory required")
+	}
+
+	if _, ok := factory().(Extension); !ok {
+		return fmt.Errorf("Operator %s does not implement Extension", name)
+	}
+
+	_EXTENSIONS.Lock()
+	defer _EXTENSIONS.Unlock()
+
+	_, core := _OPERATORS[name]
+	_, registered := _EXTENSIONS.factories[name]
+	if core || registered {
+		return fmt.Errorf("Operator %s already registered", name)
+	}
+
+	_EXTENSIONS.factories[name] = factory
+	return nil
+}
+
+// UnregisterOperator removes an operator type added by RegisterOperator.
+func UnregisterOperator(name string) {
+	_EXTENSIONS.Lock()
+	defer _EXTENSIONS.Unlock()
+	delete(_EXTENSIONS.factories, name)
 }
 
 // _OPERATORS is a global map of all plan.Operator implementations
@@ -39,7 +112,9 @@ func GetOperator(name string) (Operator, bool) {
 // correct implementation given the name of an implementation via
 // the "#operator" key in a marshalled object.
 var _OPERATORS = map[string]Operator{
+	"Advise":             &Advise{},
 	"Alias":              &Alias{},
+	"AntiJoin":           &AntiJoin{},
 	"Authorize":          &Authorize{},
 	"Channel":            &Channel{},
 	"Collect":            &Collect{},
@@ -57,6 +132,19 @@ var _OPERATORS = map[string]Operator{
 	"CreateIndex":        &CreateIndex{},
 	"DropIndex":          &DropIndex{},
 	"AlterIndex":         &AlterIndex{},
+	"CreatePolicy":       &CreatePolicy{},
+	"DropPolicy":         &DropPolicy{},
+	"CreateMask":         &CreateMask{},
+	"DropMask":           &DropMask{},
+	"CreateView":         &CreateView{},
+	"DropView":           &DropView{},
+	"RefreshView":        &RefreshView{},
+	"CreateKeyspace":     &CreateKeyspace{},
+	"DropKeyspace":       &DropKeyspace{},
+	"GrantRole":          &GrantRole{},
+	"RevokeRole":         &RevokeRole{},
+	"SessionSet":         &SessionSet{},
+	"SetVariable":        &SetVariable{},
 	"Insert":             &SendInsert{},
 	"IntersectAll":       &IntersectAll{},
 	"Join":               &Join{},
@@ -76,11 +164,13 @@ var _OPERATORS = map[string]Operator{
 	"KeyScan":            &KeyScan{},
 	"ParentScan":         &ParentScan{},
 	"ValueScan":          &ValueScan{},
+	"ImportScan":         &ImportScan{},
 	"CountScan":          &CountScan{},
 	"DummyScan":          &DummyScan{},
 	"IntersectScan":      &IntersectScan{},
 	"Sequence":           &Sequence{},
 	"Stream":             &Stream{},
+	"Outfile":            &Outfile{},
 	"UnionAll":           &UnionAll{},
 	"Clone":              &Clone{},
 	"Set":                &Set{},
@@ -17,14 +17,19 @@ import (
 	"github.com/couchbase/query/expression/parser"
 )
 
+// With a LIMIT, the Order keeps only the first OFFSET + LIMIT items.
 type Order struct {
 	readonly
-	terms algebra.SortTerms
+	terms  algebra.SortTerms
+	offset expression.Expression
+	limit  expression.Expression
 }
 
-func NewOrder(order *algebra.Order) *Order {
+func NewOrder(order *algebra.Order, offset, limit expression.Expression) *Order {
 	return &Order{
-		terms: order.Terms(),
+		terms:  order.Terms(),
+		offset: offset,
+		limit:  limit,
 	}
 }
 
@@ -40,6 +45,14 @@ func (this *Order) Terms() algebra.SortTerms {
 	return this.terms
 }
 
+func (this *Order) Offset() expression.Expression {
+	return this.offset
+}
+
+func (this *Order) Limit() expression.Expression {
+	return this.limit
+}
+
 func (this *Order) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "Order"}
 
@@ -56,6 +69,15 @@ func (this *Order) MarshalJSON() ([]byte, error) {
 		s = append(s, q)
 	}
 	r["sort_terms"] = s
+
+	if this.offset != nil {
+		r["offset"] = expression.NewStringer().Visit(this.offset)
+	}
+
+	if this.limit != nil {
+		r["limit"] = expression.NewStringer().Visit(this.limit)
+	}
+
 	return json.Marshal(r)
 }
 
@@ -66,6 +88,8 @@ func (this *Order) UnmarshalJSON(body []byte) error {
 			Expr string `json:"expr"`
 			Desc bool   `json:"desc"`
 		} `json:"sort_terms"`
+		Offset string `json:"offset"`
+		Limit  string `json:"limit"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -81,5 +105,20 @@ func (this *Order) UnmarshalJSON(body []byte) error {
 		}
 		this.terms[i] = algebra.NewSortTerm(expr, term.Desc)
 	}
+
+	if _unmarshalled.Offset != "" {
+		this.offset, err = parser.Parse(_unmarshalled.Offset)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _unmarshalled.Limit != "" {
+		this.limit, err = parser.Parse(_unmarshalled.Limit)
+		if err != nil {
+			return err
+		}
+	}
+
 	return nil
 }
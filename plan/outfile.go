@@ -0,0 +1,85 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+)
+
+/*
+Outfile writes the results of a SELECT to a file on the query server,
+for INTO OUTFILE. The columns of csv files are the aliases of the
+projection, if the results are objects of known fields.
+*/
+type Outfile struct {
+	readwrite
+	path    string
+	format  string
+	columns []string
+}
+
+func NewOutfile(path, format string, columns []string) *Outfile {
+	return &Outfile{
+		path:    path,
+		format:  format,
+		columns: columns,
+	}
+}
+
+func (this *Outfile) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitOutfile(this)
+}
+
+func (this *Outfile) New() Operator {
+	return &Outfile{}
+}
+
+func (this *Outfile) Path() string {
+	return this.path
+}
+
+func (this *Outfile) Format() string {
+	return this.format
+}
+
+func (this *Outfile) Columns() []string {
+	return this.columns
+}
+
+func (this *Outfile) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "Outfile"}
+	r["path"] = this.path
+	r["format"] = this.format
+
+	if this.columns != nil {
+		r["columns"] = this.columns
+	}
+
+	return json.Marshal(r)
+}
+
+func (this *Outfile) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_       string   `json:"#operator"`
+		Path    string   `json:"path"`
+		Format  string   `json:"format"`
+		Columns []string `json:"columns"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.path = _unmarshalled.Path
+	this.format = _unmarshalled.Format
+	this.columns = _unmarshalled.Columns
+	return nil
+}
@@ -0,0 +1,273 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+// Create policy
+type CreatePolicy struct {
+	readwrite
+	keyspace  datastore.Keyspace
+	predicate expression.Expression
+}
+
+func NewCreatePolicy(keyspace datastore.Keyspace, predicate expression.Expression) *CreatePolicy {
+	return &CreatePolicy{
+		keyspace:  keyspace,
+		predicate: predicate,
+	}
+}
+
+func (this *CreatePolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreatePolicy(this)
+}
+
+func (this *CreatePolicy) New() Operator {
+	return &CreatePolicy{}
+}
+
+func (this *CreatePolicy) Keyspace() datastore.Keyspace {
+	return this.keyspace
+}
+
+func (this *CreatePolicy) Predicate() expression.Expression {
+	return this.predicate
+}
+
+func (this *CreatePolicy) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "CreatePolicy"}
+	r["keyspace"] = this.keyspace.Name()
+	r["namespace"] = this.keyspace.NamespaceId()
+	if this.predicate != nil {
+		r["predicate"] = expression.NewStringer().Visit(this.predicate)
+	}
+	return json.Marshal(r)
+}
+
+func (this *CreatePolicy) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Keys      string `json:"keyspace"`
+		Names     string `json:"namespace"`
+		Predicate string `json:"predicate"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	if _unmarshalled.Predicate != "" {
+		this.predicate, err = parser.Parse(_unmarshalled.Predicate)
+		if err != nil {
+			return err
+		}
+	}
+
+	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
+	return err
+}
+
+// Drop policy
+type DropPolicy struct {
+	readwrite
+	keyspace datastore.Keyspace
+}
+
+func NewDropPolicy(keyspace datastore.Keyspace) *DropPolicy {
+	return &DropPolicy{
+		keyspace: keyspace,
+	}
+}
+
+func (this *DropPolicy) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropPolicy(this)
+}
+
+func (this *DropPolicy) New() Operator {
+	return &DropPolicy{}
+}
+
+func (this *DropPolicy) Keyspace() datastore.Keyspace {
+	return this.keyspace
+}
+
+func (this *DropPolicy) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "DropPolicy"}
+	r["keyspace"] = this.keyspace.Name()
+	r["namespace"] = this.keyspace.NamespaceId()
+	return json.Marshal(r)
+}
+
+func (this *DropPolicy) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string `json:"#operator"`
+		Keys  string `json:"keyspace"`
+		Names string `json:"namespace"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
+	return err
+}
+
+// Create mask
+type CreateMask struct {
+	readwrite
+	keyspace datastore.Keyspace
+	path     expression.Path
+	maskType string
+}
+
+func NewCreateMask(keyspace datastore.Keyspace, path expression.Path, maskType string) *CreateMask {
+	return &CreateMask{
+		keyspace: keyspace,
+		path:     path,
+		maskType: maskType,
+	}
+}
+
+func (this *CreateMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateMask(this)
+}
+
+func (this *CreateMask) New() Operator {
+	return &CreateMask{}
+}
+
+func (this *CreateMask) Keyspace() datastore.Keyspace {
+	return this.keyspace
+}
+
+func (this *CreateMask) Path() expression.Path {
+	return this.path
+}
+
+func (this *CreateMask) MaskType() string {
+	return this.maskType
+}
+
+func (this *CreateMask) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "CreateMask"}
+	r["keyspace"] = this.keyspace.Name()
+	r["namespace"] = this.keyspace.NamespaceId()
+	r["path"] = expression.NewStringer().Visit(this.path)
+	r["type"] = this.maskType
+	return json.Marshal(r)
+}
+
+func (this *CreateMask) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string `json:"#operator"`
+		Keys  string `json:"keyspace"`
+		Names string `json:"namespace"`
+		Path  string `json:"path"`
+		Type  string `json:"type"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.path, err = unmarshalMaskPath(_unmarshalled.Path)
+	if err != nil {
+		return err
+	}
+
+	this.maskType = _unmarshalled.Type
+	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
+	return err
+}
+
+// Drop mask
+type DropMask struct {
+	readwrite
+	keyspace datastore.Keyspace
+	path     expression.Path
+}
+
+func NewDropMask(keyspace datastore.Keyspace, path expression.Path) *DropMask {
+	return &DropMask{
+		keyspace: keyspace,
+		path:     path,
+	}
+}
+
+func (this *DropMask) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropMask(this)
+}
+
+func (this *DropMask) New() Operator {
+	return &DropMask{}
+}
+
+func (this *DropMask) Keyspace() datastore.Keyspace {
+	return this.keyspace
+}
+
+func (this *DropMask) Path() expression.Path {
+	return this.path
+}
+
+func (this *DropMask) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "DropMask"}
+	r["keyspace"] = this.keyspace.Name()
+	r["namespace"] = this.keyspace.NamespaceId()
+	r["path"] = expression.NewStringer().Visit(this.path)
+	return json.Marshal(r)
+}
+
+func (this *DropMask) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string `json:"#operator"`
+		Keys  string `json:"keyspace"`
+		Names string `json:"namespace"`
+		Path  string `json:"path"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.path, err = unmarshalMaskPath(_unmarshalled.Path)
+	if err != nil {
+		return err
+	}
+
+	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
+	return err
+}
+
+func unmarshalMaskPath(s string) (expression.Path, error) {
+	expr, err := parser.Parse(s)
+	if err != nil {
+		return nil, err
+	}
+
+	path, ok := expr.(expression.Path)
+	if !ok {
+		return nil, fmt.Errorf("Mask.UnmarshalJSON: cannot resolve path expression from %s", s)
+	}
+
+	return path, nil
+}
@@ -0,0 +1,54 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"sync"
+)
+
+/*
+SpansPool recycles the span slices used as scratch space while
+sarging. Slices that have grown beyond the pool size are dropped
+on Put, so that the pool only holds slices of a single capacity.
+*/
+type SpansPool struct {
+	pool *sync.Pool
+	size int
+}
+
+func NewSpansPool(size int) *SpansPool {
+	rv := &SpansPool{
+		pool: &sync.Pool{
+			New: func() interface{} {
+				return make(Spans, 0, size)
+			},
+		},
+		size: size,
+	}
+
+	return rv
+}
+
+func (this *SpansPool) Get() Spans {
+	return this.pool.Get().(Spans)
+}
+
+func (this *SpansPool) Put(s Spans) {
+	if cap(s) != this.size {
+		return
+	}
+
+	// Do not hold on to the spans themselves
+	for i := range s {
+		s[i] = nil
+	}
+
+	this.pool.Put(s[0:0])
+}
@@ -160,6 +160,13 @@ func AddPrepared(prepared *Prepared) errors.Error {
 	return nil
 }
 
+// Remove all prepared statements, whose plans may be stale.
+func ClearPrepareds() {
+	cache.Lock()
+	cache.prepareds = make(map[string]*Prepared, _CACHE_SIZE)
+	cache.Unlock()
+}
+
 var errBadFormat = fmt.Errorf("unable to convert to prepared statment.")
 
 func GetPrepared(prepared_stmt value.Value) (*Prepared, errors.Error) {
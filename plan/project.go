@@ -11,6 +11,7 @@ package plan
 
 import (
 	"encoding/json"
+	"fmt"
 
 	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/expression"
@@ -21,9 +22,11 @@ type InitialProject struct {
 	readonly
 	projection *algebra.Projection
 	terms      ProjectTerms
+	masks      algebra.SetTerms // Masking policies, applied before projecting
+	direct     bool             // RAW values are sent without the item
 }
 
-func NewInitialProject(projection *algebra.Projection) *InitialProject {
+func NewInitialProject(projection *algebra.Projection, masks algebra.SetTerms) *InitialProject {
 	results := projection.Terms()
 	terms := make(ProjectTerms, len(results))
 
@@ -36,6 +39,7 @@ func NewInitialProject(projection *algebra.Projection) *InitialProject {
 	return &InitialProject{
 		projection: projection,
 		terms:      terms,
+		masks:      masks,
 	}
 }
 
@@ -55,6 +59,23 @@ func (this *InitialProject) Terms() ProjectTerms {
 	return this.terms
 }
 
+func (this *InitialProject) Masks() algebra.SetTerms {
+	return this.masks
+}
+
+/*
+A direct RAW projection sends the projected value as the item, instead
+of wrapping it with the item it was projected from. Only valid when
+nothing after the projection, such as ORDER BY, refers to the item.
+*/
+func (this *InitialProject) Direct() bool {
+	return this.direct
+}
+
+func (this *InitialProject) SetDirect() {
+	this.direct = this.projection.Raw()
+}
+
 func (this *InitialProject) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "InitialProject"}
 
@@ -66,6 +87,10 @@ func (this *InitialProject) MarshalJSON() ([]byte, error) {
 		r["raw"] = this.projection.Raw()
 	}
 
+	if this.direct {
+		r["direct"] = this.direct
+	}
+
 	s := make([]interface{}, 0, len(this.terms))
 	for _, term := range this.terms {
 		t := make(map[string]interface{})
@@ -86,6 +111,18 @@ func (this *InitialProject) MarshalJSON() ([]byte, error) {
 		s = append(s, t)
 	}
 	r["result_terms"] = s
+
+	if len(this.masks) > 0 {
+		m := make([]interface{}, 0, len(this.masks))
+		for _, mask := range this.masks {
+			t := make(map[string]interface{})
+			t["path"] = expression.NewStringer().Visit(mask.Path())
+			t["expr"] = expression.NewStringer().Visit(mask.Value())
+			m = append(m, t)
+		}
+		r["masks"] = m
+	}
+
 	return json.Marshal(r)
 }
 
@@ -99,6 +136,11 @@ func (this *InitialProject) UnmarshalJSON(body []byte) error {
 		} `json:"result_terms"`
 		Distinct bool `json:"distinct"`
 		Raw      bool `json:"raw"`
+		Direct   bool `json:"direct"`
+		Masks    []struct {
+			Path string `json:"path"`
+			Expr string `json:"expr"`
+		} `json:"masks"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -114,10 +156,19 @@ func (this *InitialProject) UnmarshalJSON(body []byte) error {
 			if err != nil {
 				return err
 			}
+		} else if !term_data.Star {
+			return fmt.Errorf("InitialProject.UnmarshalJSON: missing expression in result term %d", i)
 		}
 		terms[i] = algebra.NewResultTerm(expr, term_data.Star, term_data.As)
 	}
-	projection := algebra.NewProjection(_unmarshalled.Distinct, terms)
+	var projection *algebra.Projection
+	if _unmarshalled.Raw && len(terms) == 1 {
+		projection = algebra.NewRawProjection(_unmarshalled.Distinct,
+			terms[0].Expression(), terms[0].As())
+	} else {
+		projection = algebra.NewProjection(_unmarshalled.Distinct, terms)
+	}
+
 	results := projection.Terms()
 	project_terms := make(ProjectTerms, len(results))
 
@@ -129,6 +180,29 @@ func (this *InitialProject) UnmarshalJSON(body []byte) error {
 
 	this.projection = projection
 	this.terms = project_terms
+	this.direct = _unmarshalled.Direct && projection.Raw()
+
+	if len(_unmarshalled.Masks) > 0 {
+		this.masks = make(algebra.SetTerms, len(_unmarshalled.Masks))
+		for i, mask := range _unmarshalled.Masks {
+			path_expr, err := parser.Parse(mask.Path)
+			if err != nil {
+				return err
+			}
+
+			path, is_path := path_expr.(expression.Path)
+			if !is_path {
+				return fmt.Errorf("InitialProject.UnmarshalJSON: cannot resolve path expression from %s", mask.Path)
+			}
+
+			expr, err := parser.Parse(mask.Expr)
+			if err != nil {
+				return err
+			}
+
+			this.masks[i] = algebra.NewSetTerm(path, expr, nil)
+		}
+	}
 
 	return nil
 }
@@ -0,0 +1,124 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+)
+
+// Grant role
+type GrantRole struct {
+	readwrite
+	roles []datastore.Role
+	users []string
+}
+
+func NewGrantRole(roles []datastore.Role, users []string) *GrantRole {
+	return &GrantRole{
+		roles: roles,
+		users: users,
+	}
+}
+
+func (this *GrantRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitGrantRole(this)
+}
+
+func (this *GrantRole) New() Operator {
+	return &GrantRole{}
+}
+
+func (this *GrantRole) Roles() []datastore.Role {
+	return this.roles
+}
+
+func (this *GrantRole) Users() []string {
+	return this.users
+}
+
+func (this *GrantRole) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "GrantRole"}
+	r["roles"] = this.roles
+	r["users"] = this.users
+	return json.Marshal(r)
+}
+
+func (this *GrantRole) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string           `json:"#operator"`
+		Roles []datastore.Role `json:"roles"`
+		Users []string         `json:"users"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.roles = _unmarshalled.Roles
+	this.users = _unmarshalled.Users
+	return nil
+}
+
+// Revoke role
+type RevokeRole struct {
+	readwrite
+	roles []datastore.Role
+	users []string
+}
+
+func NewRevokeRole(roles []datastore.Role, users []string) *RevokeRole {
+	return &RevokeRole{
+		roles: roles,
+		users: users,
+	}
+}
+
+func (this *RevokeRole) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRevokeRole(this)
+}
+
+func (this *RevokeRole) New() Operator {
+	return &RevokeRole{}
+}
+
+func (this *RevokeRole) Roles() []datastore.Role {
+	return this.roles
+}
+
+func (this *RevokeRole) Users() []string {
+	return this.users
+}
+
+func (this *RevokeRole) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "RevokeRole"}
+	r["roles"] = this.roles
+	r["users"] = this.users
+	return json.Marshal(r)
+}
+
+func (this *RevokeRole) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string           `json:"#operator"`
+		Roles []datastore.Role `json:"roles"`
+		Users []string         `json:"users"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.roles = _unmarshalled.Roles
+	this.users = _unmarshalled.Users
+	return nil
+}
@@ -19,21 +19,41 @@ import (
 	"github.com/couchbase/query/expression/parser"
 )
 
+/*
+A scan whose entries cover the query, so that the documents are not
+fetched.
+*/
+type CoveringScan interface {
+	Operator
+	Covers() []*expression.Cover
+}
+
 type PrimaryScan struct {
 	readonly
 	index    datastore.PrimaryIndex
 	keyspace datastore.Keyspace
 	term     *algebra.KeyspaceTerm
+	offset   expression.Expression
 	limit    expression.Expression
+	covers   []*expression.Cover
 }
 
+/*
+The offset, if any, is applied by the index, which must be a
+datastore.OffsetPrimaryIndex. The limit then counts the entries after
+the offset. The scan covers the query if covers is META().id, the
+primary key of each entry.
+*/
 func NewPrimaryScan(index datastore.PrimaryIndex, keyspace datastore.Keyspace,
-	term *algebra.KeyspaceTerm, limit expression.Expression) *PrimaryScan {
+	term *algebra.KeyspaceTerm, offset, limit expression.Expression,
+	covers []*expression.Cover) *PrimaryScan {
 	return &PrimaryScan{
 		index:    index,
 		keyspace: keyspace,
 		term:     term,
+		offset:   offset,
 		limit:    limit,
+		covers:   covers,
 	}
 }
 
@@ -57,10 +77,18 @@ func (this *PrimaryScan) Term() *algebra.KeyspaceTerm {
 	return this.term
 }
 
+func (this *PrimaryScan) Offset() expression.Expression {
+	return this.offset
+}
+
 func (this *PrimaryScan) Limit() expression.Expression {
 	return this.limit
 }
 
+func (this *PrimaryScan) Covers() []*expression.Cover {
+	return this.covers
+}
+
 func (this *PrimaryScan) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "PrimaryScan"}
 	r["index"] = this.index.Name()
@@ -68,21 +96,34 @@ func (this *PrimaryScan) MarshalJSON() ([]byte, error) {
 	r["keyspace"] = this.term.Keyspace()
 	r["using"] = this.index.Type()
 
+	if this.offset != nil {
+		r["offset"] = expression.NewStringer().Visit(this.offset)
+	}
+
 	if this.limit != nil {
 		r["limit"] = expression.NewStringer().Visit(this.limit)
 	}
 
+	if this.covers != nil {
+		r["covers"] = this.covers
+	}
+
+	marshalConsistency(r, this.term)
 	return json.Marshal(r)
 }
 
 func (this *PrimaryScan) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		_     string              `json:"#operator"`
-		Index string              `json:"index"`
-		Names string              `json:"namespace"`
-		Keys  string              `json:"keyspace"`
-		Using datastore.IndexType `json:"using"`
-		Limit string              `json:"limit"`
+		_      string              `json:"#operator"`
+		Index  string              `json:"index"`
+		Names  string              `json:"namespace"`
+		Keys   string              `json:"keyspace"`
+		Using  datastore.IndexType `json:"using"`
+		Offset string              `json:"offset"`
+		Limit  string              `json:"limit"`
+		Covers []string            `json:"covers"`
+		Cons   string              `json:"scan_consistency"`
+		Vector string              `json:"scan_vector"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -90,6 +131,13 @@ func (this *PrimaryScan) UnmarshalJSON(body []byte) error {
 		return err
 	}
 
+	if _unmarshalled.Offset != "" {
+		this.offset, err = parser.Parse(_unmarshalled.Offset)
+		if err != nil {
+			return err
+		}
+	}
+
 	if _unmarshalled.Limit != "" {
 		this.limit, err = parser.Parse(_unmarshalled.Limit)
 		if err != nil {
@@ -97,6 +145,18 @@ func (this *PrimaryScan) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Covers != nil {
+		this.covers = make([]*expression.Cover, len(_unmarshalled.Covers))
+		for i, c := range _unmarshalled.Covers {
+			expr, err := parser.Parse(c)
+			if err != nil {
+				return err
+			}
+
+			this.covers[i] = expression.NewCover(expr)
+		}
+	}
+
 	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
 	if err != nil {
 		return err
@@ -106,6 +166,11 @@ func (this *PrimaryScan) UnmarshalJSON(body []byte) error {
 		_unmarshalled.Names, _unmarshalled.Keys,
 		nil, "", nil, nil)
 
+	err = unmarshalConsistency(this.term, _unmarshalled.Cons, _unmarshalled.Vector)
+	if err != nil {
+		return err
+	}
+
 	indexer, err := this.keyspace.Indexer(_unmarshalled.Using)
 	if err != nil {
 		return err
@@ -203,6 +268,7 @@ func (this *IndexScan) MarshalJSON() ([]byte, error) {
 		r["covers"] = this.covers
 	}
 
+	marshalConsistency(r, this.term)
 	return json.Marshal(r)
 }
 
@@ -217,6 +283,8 @@ func (this *IndexScan) UnmarshalJSON(body []byte) error {
 		Distinct  bool                `json:"distinct"`
 		Limit     string              `json:"limit"`
 		Covers    []string            `json:"covers"`
+		Cons      string              `json:"scan_consistency"`
+		Vector    string              `json:"scan_vector"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -233,6 +301,11 @@ func (this *IndexScan) UnmarshalJSON(body []byte) error {
 		_unmarshalled.Namespace, _unmarshalled.Keyspace,
 		nil, "", nil, nil)
 
+	err = unmarshalConsistency(this.term, _unmarshalled.Cons, _unmarshalled.Vector)
+	if err != nil {
+		return err
+	}
+
 	this.spans = _unmarshalled.Spans
 	this.distinct = _unmarshalled.Distinct
 
@@ -264,6 +337,37 @@ func (this *IndexScan) UnmarshalJSON(body []byte) error {
 	return err
 }
 
+// The scan consistency of USE CONSISTENCY, if the term has it.
+func marshalConsistency(r map[string]interface{}, term *algebra.KeyspaceTerm) {
+	cons := term.Consistency()
+	if cons == nil {
+		return
+	}
+
+	r["scan_consistency"] = cons.Level()
+	if cons.Vector() != nil {
+		r["scan_vector"] = expression.NewStringer().Visit(cons.Vector())
+	}
+}
+
+func unmarshalConsistency(term *algebra.KeyspaceTerm, level, vector string) error {
+	if level == "" {
+		return nil
+	}
+
+	var expr expression.Expression
+	if vector != "" {
+		var err error
+		expr, err = parser.Parse(vector)
+		if err != nil {
+			return err
+		}
+	}
+
+	term.SetConsistency(algebra.NewConsistency(datastore.ScanConsistency(level), expr))
+	return nil
+}
+
 // KeyScan is used for USE KEYS clauses.
 type KeyScan struct {
 	readonly
@@ -0,0 +1,126 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/value"
+)
+
+// Session setting. It does not write any data.
+type SessionSet struct {
+	readonly
+	name  string
+	value value.Value
+}
+
+func NewSessionSet(name string, value value.Value) *SessionSet {
+	return &SessionSet{
+		name:  name,
+		value: value,
+	}
+}
+
+func (this *SessionSet) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSessionSet(this)
+}
+
+func (this *SessionSet) New() Operator {
+	return &SessionSet{}
+}
+
+func (this *SessionSet) Name() string {
+	return this.name
+}
+
+func (this *SessionSet) Value() value.Value {
+	return this.value
+}
+
+func (this *SessionSet) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "SessionSet"}
+	r["name"] = this.name
+	r["value"] = this.value
+	return json.Marshal(r)
+}
+
+func (this *SessionSet) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_     string          `json:"#operator"`
+		Name  string          `json:"name"`
+		Value json.RawMessage `json:"value"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.name = _unmarshalled.Name
+	this.value = value.NewValue([]byte(_unmarshalled.Value))
+	return nil
+}
+
+// Script variable. It does not write any data.
+type SetVariable struct {
+	readonly
+	name string
+	expr expression.Expression
+}
+
+func NewSetVariable(name string, expr expression.Expression) *SetVariable {
+	return &SetVariable{
+		name: name,
+		expr: expr,
+	}
+}
+
+func (this *SetVariable) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitSetVariable(this)
+}
+
+func (this *SetVariable) New() Operator {
+	return &SetVariable{}
+}
+
+func (this *SetVariable) Name() string {
+	return this.name
+}
+
+func (this *SetVariable) Expression() expression.Expression {
+	return this.expr
+}
+
+func (this *SetVariable) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "SetVariable"}
+	r["name"] = this.name
+	r["expr"] = expression.NewStringer().Visit(this.expr)
+	return json.Marshal(r)
+}
+
+func (this *SetVariable) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_    string `json:"#operator"`
+		Name string `json:"name"`
+		Expr string `json:"expr"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.name = _unmarshalled.Name
+	this.expr, err = parser.Parse(_unmarshalled.Expr)
+	return err
+}
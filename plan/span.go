@@ -85,17 +85,113 @@ func (this *Range) UnmarshalJSON(body []byte) error {
 	return nil
 }
 
+/*
+Ranges2 bound each key of a composite index on its own; see
+datastore.Range2. Plans without them, or servers that do not read
+them, scan the composite Range.
+*/
+type Ranges2 []*Range2
+
+type Range2 struct {
+	Low       expression.Expression
+	High      expression.Expression
+	Inclusion datastore.Inclusion
+}
+
+func (this *Range2) Copy() *Range2 {
+	rv := &Range2{
+		Inclusion: this.Inclusion,
+	}
+
+	if this.Low != nil {
+		rv.Low = this.Low.Copy()
+	}
+
+	if this.High != nil {
+		rv.High = this.High.Copy()
+	}
+
+	return rv
+}
+
+func (this Ranges2) Copy() Ranges2 {
+	if this == nil {
+		return nil
+	}
+
+	rv := make(Ranges2, len(this))
+	for i, r := range this {
+		rv[i] = r.Copy()
+	}
+
+	return rv
+}
+
+func (this *Range2) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{
+		"Inclusion": this.Inclusion,
+	}
+
+	if this.Low != nil {
+		r["Low"] = this.Low
+	}
+
+	if this.High != nil {
+		r["High"] = this.High
+	}
+
+	return json.Marshal(r)
+}
+
+func (this *Range2) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		Low       string
+		High      string
+		Inclusion datastore.Inclusion
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	if _unmarshalled.Low != "" {
+		this.Low, err = parser.Parse(_unmarshalled.Low)
+		if err != nil {
+			return err
+		}
+	}
+
+	if _unmarshalled.High != "" {
+		this.High, err = parser.Parse(_unmarshalled.High)
+		if err != nil {
+			return err
+		}
+	}
+
+	this.Inclusion = _unmarshalled.Inclusion
+	return nil
+}
+
 type Spans []*Span
 
 type Span struct {
-	Seek  expression.Expressions
-	Range Range
+	Seek   expression.Expressions
+	Range  Range
+	Ranges Ranges2 // Bounds of each key, if known
+	Exact  bool    // The entries of the span are exactly those that satisfy the predicate
 }
 
 func (this *Span) Copy() *Span {
 	return &Span{
-		Seek:  expression.CopyExpressions(this.Seek),
-		Range: *(this.Range.Copy()),
+		Seek: expression.CopyExpressions(this.Seek),
+		Range: Range{
+			Low:       expression.CopyExpressions(this.Range.Low),
+			High:      expression.CopyExpressions(this.Range.High),
+			Inclusion: this.Range.Inclusion,
+		},
+		Ranges: this.Ranges.Copy(),
+		Exact:  this.Exact,
 	}
 }
 
@@ -108,13 +204,23 @@ func (this *Span) MarshalJSON() ([]byte, error) {
 		r["Seek"] = this.Seek
 	}
 
+	if this.Ranges != nil {
+		r["Ranges"] = this.Ranges
+	}
+
+	if this.Exact {
+		r["Exact"] = this.Exact
+	}
+
 	return json.Marshal(r)
 }
 
 func (this *Span) UnmarshalJSON(body []byte) error {
 	var _unmarshalled struct {
-		Seek  []string
-		Range *Range
+		Seek   []string
+		Range  *Range
+		Ranges Ranges2
+		Exact  bool
 	}
 
 	_unmarshalled.Range = &this.Range
@@ -134,6 +240,8 @@ func (this *Span) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	this.Ranges = _unmarshalled.Ranges
+	this.Exact = _unmarshalled.Exact
 	return nil
 }
 
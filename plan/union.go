@@ -14,7 +14,6 @@ import (
 )
 
 type UnionAll struct {
-	readonly
 	children []Operator
 }
 
@@ -28,6 +27,16 @@ func (this *UnionAll) Accept(visitor Visitor) (interface{}, error) {
 	return visitor.VisitUnionAll(this)
 }
 
+func (this *UnionAll) Readonly() bool {
+	for _, child := range this.children {
+		if !child.Readonly() {
+			return false
+		}
+	}
+
+	return true
+}
+
 func (this *UnionAll) New() Operator {
 	return &UnionAll{}
 }
@@ -43,6 +43,7 @@ type SendUpdate struct {
 	keyspace datastore.Keyspace
 	alias    string
 	limit    expression.Expression
+	where    expression.Expression // Re-checked on documents modified concurrently
 }
 
 func NewClone(alias string) *Clone {
@@ -213,11 +214,12 @@ func (this *Unset) UnmarshalJSON(body []byte) error {
 	return nil
 }
 
-func NewSendUpdate(keyspace datastore.Keyspace, alias string, limit expression.Expression) *SendUpdate {
+func NewSendUpdate(keyspace datastore.Keyspace, alias string, limit, where expression.Expression) *SendUpdate {
 	return &SendUpdate{
 		keyspace: keyspace,
 		alias:    alias,
 		limit:    limit,
+		where:    where,
 	}
 }
 
@@ -241,12 +243,20 @@ func (this *SendUpdate) Limit() expression.Expression {
 	return this.limit
 }
 
+// The condition of the documents to update, or nil.
+func (this *SendUpdate) Where() expression.Expression {
+	return this.where
+}
+
 func (this *SendUpdate) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "SendUpdate"}
 	r["keyspace"] = this.keyspace.Name()
 	r["namespace"] = this.keyspace.NamespaceId()
 	r["alias"] = this.alias
 	r["limit"] = this.limit
+	if this.where != nil {
+		r["where"] = expression.NewStringer().Visit(this.where)
+	}
 	return json.Marshal(r)
 }
 
@@ -257,6 +267,7 @@ func (this *SendUpdate) UnmarshalJSON(body []byte) error {
 		Names string `json:"namespace"`
 		Alias string `json:"alias"`
 		Limit string `json:"limit"`
+		Where string `json:"where"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -273,6 +284,13 @@ func (this *SendUpdate) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Where != "" {
+		this.where, err = parser.Parse(_unmarshalled.Where)
+		if err != nil {
+			return err
+		}
+	}
+
 	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
 	return err
 }
@@ -23,14 +23,18 @@ type SendUpsert struct {
 	alias    string
 	key      expression.Expression
 	value    expression.Expression
+	policy   expression.Expression
 }
 
-func NewSendUpsert(keyspace datastore.Keyspace, alias string, key, value expression.Expression) *SendUpsert {
+// The policy, if any, is the unqualified predicate of the
+// row-level security policy on the keyspace.
+func NewSendUpsert(keyspace datastore.Keyspace, alias string, key, value, policy expression.Expression) *SendUpsert {
 	return &SendUpsert{
 		keyspace: keyspace,
 		alias:    alias,
 		key:      key,
 		value:    value,
+		policy:   policy,
 	}
 }
 
@@ -58,6 +62,10 @@ func (this *SendUpsert) Value() expression.Expression {
 	return this.value
 }
 
+func (this *SendUpsert) Policy() expression.Expression {
+	return this.policy
+}
+
 func (this *SendUpsert) MarshalJSON() ([]byte, error) {
 	r := map[string]interface{}{"#operator": "SendUpsert"}
 	r["keyspace"] = this.keyspace.Name()
@@ -72,6 +80,10 @@ func (this *SendUpsert) MarshalJSON() ([]byte, error) {
 		r["value"] = this.value.String()
 	}
 
+	if this.policy != nil {
+		r["policy"] = this.policy.String()
+	}
+
 	return json.Marshal(r)
 }
 
@@ -83,6 +95,7 @@ func (this *SendUpsert) UnmarshalJSON(body []byte) error {
 		Keys      string `json:"keyspace"`
 		Names     string `json:"namespace"`
 		Alias     string `json:"alias"`
+		Policy    string `json:"policy"`
 	}
 
 	err := json.Unmarshal(body, &_unmarshalled)
@@ -104,7 +117,14 @@ func (this *SendUpsert) UnmarshalJSON(body []byte) error {
 		}
 	}
 
+	if _unmarshalled.Policy != "" {
+		this.policy, err = parser.Parse(_unmarshalled.Policy)
+		if err != nil {
+			return err
+		}
+	}
+
 	this.alias = _unmarshalled.Alias
 	this.keyspace, err = datastore.GetKeyspace(_unmarshalled.Names, _unmarshalled.Keys)
-	return nil
+	return err
 }
@@ -0,0 +1,260 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package plan
+
+import (
+	"encoding/json"
+)
+
+/*
+Create view. The definition is the text of the query of the view. The
+key of a materialized view is the text of its subselect, which
+matching queries have. A key-preserving materialized view is
+refreshed incrementally from the mutation feed of its source keyspace.
+*/
+type CreateView struct {
+	readwrite
+	namespace       string
+	name            string
+	definition      string
+	materialized    bool
+	key             string
+	keyPreserving   bool
+	sourceNamespace string
+	sourceKeyspace  string
+}
+
+func NewCreateView(namespace, name, definition string) *CreateView {
+	return &CreateView{
+		namespace:  namespace,
+		name:       name,
+		definition: definition,
+	}
+}
+
+func NewCreateMaterializedView(namespace, name, definition, key string, keyPreserving bool,
+	sourceNamespace, sourceKeyspace string) *CreateView {
+	return &CreateView{
+		namespace:       namespace,
+		name:            name,
+		definition:      definition,
+		materialized:    true,
+		key:             key,
+		keyPreserving:   keyPreserving,
+		sourceNamespace: sourceNamespace,
+		sourceKeyspace:  sourceKeyspace,
+	}
+}
+
+func (this *CreateView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitCreateView(this)
+}
+
+func (this *CreateView) New() Operator {
+	return &CreateView{}
+}
+
+func (this *CreateView) Namespace() string {
+	return this.namespace
+}
+
+func (this *CreateView) Name() string {
+	return this.name
+}
+
+func (this *CreateView) Definition() string {
+	return this.definition
+}
+
+func (this *CreateView) Materialized() bool {
+	return this.materialized
+}
+
+func (this *CreateView) Key() string {
+	return this.key
+}
+
+func (this *CreateView) KeyPreserving() bool {
+	return this.keyPreserving
+}
+
+// The namespace and keyspace of a key-preserving view.
+func (this *CreateView) SourceNamespace() string {
+	return this.sourceNamespace
+}
+
+func (this *CreateView) SourceKeyspace() string {
+	return this.sourceKeyspace
+}
+
+func (this *CreateView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "CreateView"}
+	r["namespace"] = this.namespace
+	r["name"] = this.name
+	r["definition"] = this.definition
+	if this.materialized {
+		r["materialized"] = this.materialized
+		r["key"] = this.key
+	}
+	if this.keyPreserving {
+		r["key_preserving"] = this.keyPreserving
+		r["source_namespace"] = this.sourceNamespace
+		r["source_keyspace"] = this.sourceKeyspace
+	}
+	return json.Marshal(r)
+}
+
+func (this *CreateView) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_               string `json:"#operator"`
+		Namespace       string `json:"namespace"`
+		Name            string `json:"name"`
+		Definition      string `json:"definition"`
+		Materialized    bool   `json:"materialized"`
+		Key             string `json:"key"`
+		KeyPreserving   bool   `json:"key_preserving"`
+		SourceNamespace string `json:"source_namespace"`
+		SourceKeyspace  string `json:"source_keyspace"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.namespace = _unmarshalled.Namespace
+	this.name = _unmarshalled.Name
+	this.definition = _unmarshalled.Definition
+	this.materialized = _unmarshalled.Materialized
+	this.key = _unmarshalled.Key
+	this.keyPreserving = _unmarshalled.KeyPreserving
+	this.sourceNamespace = _unmarshalled.SourceNamespace
+	this.sourceKeyspace = _unmarshalled.SourceKeyspace
+	return nil
+}
+
+// Drop view
+type DropView struct {
+	readwrite
+	namespace    string
+	name         string
+	materialized bool
+}
+
+func NewDropView(namespace, name string, materialized bool) *DropView {
+	return &DropView{
+		namespace:    namespace,
+		name:         name,
+		materialized: materialized,
+	}
+}
+
+func (this *DropView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitDropView(this)
+}
+
+func (this *DropView) New() Operator {
+	return &DropView{}
+}
+
+func (this *DropView) Namespace() string {
+	return this.namespace
+}
+
+func (this *DropView) Name() string {
+	return this.name
+}
+
+func (this *DropView) Materialized() bool {
+	return this.materialized
+}
+
+func (this *DropView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "DropView"}
+	r["namespace"] = this.namespace
+	r["name"] = this.name
+	if this.materialized {
+		r["materialized"] = this.materialized
+	}
+	return json.Marshal(r)
+}
+
+func (this *DropView) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_            string `json:"#operator"`
+		Namespace    string `json:"namespace"`
+		Name         string `json:"name"`
+		Materialized bool   `json:"materialized"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.namespace = _unmarshalled.Namespace
+	this.name = _unmarshalled.Name
+	this.materialized = _unmarshalled.Materialized
+	return nil
+}
+
+// Refresh materialized view
+type RefreshView struct {
+	readwrite
+	namespace string
+	name      string
+}
+
+func NewRefreshView(namespace, name string) *RefreshView {
+	return &RefreshView{
+		namespace: namespace,
+		name:      name,
+	}
+}
+
+func (this *RefreshView) Accept(visitor Visitor) (interface{}, error) {
+	return visitor.VisitRefreshView(this)
+}
+
+func (this *RefreshView) New() Operator {
+	return &RefreshView{}
+}
+
+func (this *RefreshView) Namespace() string {
+	return this.namespace
+}
+
+func (this *RefreshView) Name() string {
+	return this.name
+}
+
+func (this *RefreshView) MarshalJSON() ([]byte, error) {
+	r := map[string]interface{}{"#operator": "RefreshView"}
+	r["namespace"] = this.namespace
+	r["name"] = this.name
+	return json.Marshal(r)
+}
+
+func (this *RefreshView) UnmarshalJSON(body []byte) error {
+	var _unmarshalled struct {
+		_         string `json:"#operator"`
+		Namespace string `json:"namespace"`
+		Name      string `json:"name"`
+	}
+
+	err := json.Unmarshal(body, &_unmarshalled)
+	if err != nil {
+		return err
+	}
+
+	this.namespace = _unmarshalled.Namespace
+	this.name = _unmarshalled.Name
+	return nil
+}
@@ -10,17 +10,47 @@
 package planner
 
 import (
-	"strings"
-
 	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/plan"
 )
 
+/*
+Row-level security policies are applied if restricted is true; see
+package policy.
+*/
 func Build(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
-	namespace string, subquery bool) (plan.Operator, error) {
-	builder := newBuilder(datastore, systemstore, namespace, subquery)
+	namespace string, subquery, restricted bool) (plan.Operator, error) {
+	builder := newBuilder(datastore, systemstore, namespace, subquery, restricted)
+	return build(stmt, builder, subquery)
+}
+
+/*
+Like Build, but the feature flags of a request override the server
+settings; see package feature.
+*/
+func BuildFeatures(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
+	namespace string, subquery, restricted bool, features feature.Flags) (plan.Operator, error) {
+	builder := newBuilder(datastore, systemstore, namespace, subquery, restricted)
+	builder.features = features
+	return build(stmt, builder, subquery)
+}
+
+/*
+Like Build, but the virtual indexes are considered along with the
+indexes of their keyspaces; see package datastore/virtual.
+*/
+func BuildVirtual(stmt algebra.Statement, store, systemstore datastore.Datastore,
+	namespace string, subquery, restricted bool, indexes []datastore.Index) (plan.Operator, error) {
+	builder := newBuilder(store, systemstore, namespace, subquery, restricted)
+	builder.virtualIndexes = indexes
+	return build(stmt, builder, subquery)
+}
+
+func build(stmt algebra.Statement, builder *builder, subquery bool) (plan.Operator, error) {
 	o, err := stmt.Accept(builder)
 
 	if err != nil {
@@ -28,6 +58,19 @@ func Build(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
 	}
 
 	op := o.(plan.Operator)
+	if !op.Readonly() {
+		if builder.readonly {
+			return nil, errors.NewReadonlyStatementError("request")
+		} else if datastore.IsReadonly(builder.datastore) {
+			return nil, errors.NewReadonlyStatementError("datastore")
+		} else if builder.mutated != nil {
+			err = requireCapability(builder.mutated, datastore.CAPABILITY_DML)
+			if err != nil {
+				return nil, err
+			}
+		}
+	}
+
 	_, is_prepared := o.(*plan.Prepared)
 
 	if !subquery && !is_prepared {
@@ -36,6 +79,13 @@ func Build(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
 			return nil, er
 		}
 
+		if admin, ok := builder.mutated.(datastore.AdminKeyspace); ok && admin.AdminMutations() {
+			if privs == nil {
+				privs = datastore.NewPrivileges()
+			}
+			privs.Add(datastore.AdminPrivileges())
+		}
+
 		if len(privs) > 0 {
 			op = plan.NewAuthorize(privs, op)
 		}
@@ -51,46 +101,78 @@ type builder struct {
 	systemstore     datastore.Datastore
 	namespace       string
 	subquery        bool
+	restricted      bool // Apply row-level security policies
+	readonly        bool // Reject statements that are not read-only
 	maxParallelism  int
 	delayProjection bool                  // Used to allow ORDER BY non-projected expressions
 	where           expression.Expression // Used for index selection
 	order           *algebra.Order        // Used to collect aggregates from ORDER BY
 	limit           expression.Expression // Used for LIMIT pushdown
+	offset          expression.Expression // Used for OFFSET pushdown
+	offsetPushed    bool                  // OFFSET is applied by the scan
 	distinct        bool
 	children        []plan.Operator
 	subChildren     []plan.Operator
 	cover           algebra.Statement
-	coveringScan    *plan.IndexScan
+	coveringScan    plan.CoveringScan
+	policy          expression.Expression            // Policy predicate, which a covering index must cover
+	masks           algebra.SetTerms                 // Masking policies, which prevent covering scans
+	advice          []interface{}                    // Index recommendations of ADVISE
+	virtualIndexes  []datastore.Index                // Hypothetical indexes, for planning only
+	features        feature.Flags                    // Overrides of the server feature flags
+	viewTerms       map[*algebra.SubqueryTerm]string // Subqueries that expand views
+	expanding       map[string]bool                  // Views being expanded
+	notes           []string                         // Plan choices limited by keyspace capabilities
+	mutated         datastore.Keyspace               // Target of a DML statement
 }
 
-func newBuilder(datastore, systemstore datastore.Datastore, namespace string, subquery bool) *builder {
+func newBuilder(datastore, systemstore datastore.Datastore, namespace string, subquery, restricted bool) *builder {
 	return &builder{
 		datastore:       datastore,
 		systemstore:     systemstore,
 		namespace:       namespace,
 		subquery:        subquery,
+		restricted:      restricted,
 		delayProjection: false,
 	}
 }
 
+// Namespace of an unqualified keyspace, resolved along the search path.
+func (this *builder) defaultNamespace(keyspace string) string {
+	return datastore.ResolveNamespace(this.datastore, this.namespace, keyspace)
+}
+
 func (this *builder) getTermKeyspace(node *algebra.KeyspaceTerm) (datastore.Keyspace, error) {
-	node.SetDefaultNamespace(this.namespace)
+	node.SetDefaultNamespace(this.defaultNamespace(node.Keyspace()))
 	ns := node.Namespace()
 
-	datastore := this.datastore
-	if strings.ToLower(ns) == "#system" {
-		datastore = this.systemstore
+	store := this.datastore
+	if datastore.IsSystemNamespace(ns) {
+		store = this.systemstore
 	}
 
-	namespace, err := datastore.NamespaceByName(ns)
+	namespace, err := store.NamespaceByName(ns)
 	if err != nil {
 		return nil, err
 	}
 
-	keyspace, err := namespace.KeyspaceByName(node.Keyspace())
+	resolveKeyspacePath(node, namespace)
+	keyspace, err := datastore.ResolveKeyspace(store, ns, node.Keyspace())
 	if err != nil {
 		return nil, err
 	}
 
 	return keyspace, nil
 }
+
+/*
+The keyspace of a FROM term followed by two field names is a bucket,
+and the names its scope and keyspace, if the namespace holds that
+keyspace path. Otherwise they are a path within the documents.
+*/
+func resolveKeyspacePath(node *algebra.KeyspaceTerm, namespace datastore.Namespace) {
+	scope, keyspace, ok := node.KeyspacePath()
+	if ok && datastore.IsKeyspacePath(namespace, node.Keyspace(), scope, keyspace) {
+		node.SetKeyspacePath(scope, keyspace)
+	}
+}
@@ -0,0 +1,377 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"math"
+	"regexp"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// Default selectivities of an index key sarged by an equality or a
+// range predicate, in the absence of statistics.
+const (
+	_EQ_SELECTIVITY    = 0.1
+	_RANGE_SELECTIVITY = 1.0 / 3.0
+)
+
+/*
+The statement is planned as usual. Each index selection also
+considers hypothetical indexes on the sargable terms of the
+predicate, and recommends those that would reduce the documents
+fetched. See adviseIndexes.
+*/
+func (this *builder) VisitAdvise(stmt *algebra.Advise) (interface{}, error) {
+	this.advice = make([]interface{}, 0, 4)
+	defer func() { this.advice = nil }()
+
+	_, err := stmt.Statement().Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	advice := value.NewValue(map[string]interface{}{
+		"recommendations": this.advice,
+	})
+
+	return plan.NewAdvise(advice), nil
+}
+
+/*
+Recommend indexes for the scan of a keyspace term, given the
+predicate and the minimal existing sargable indexes.
+
+The keys of the hypothetical index are the terms sarged by
+equality, then those sarged by ranges. It is recommended unless
+an existing index is narrower or equivalent. An index that also
+includes the other fields of the keyspace that the statement
+references is recommended if it would cover the statement.
+
+The estimated benefit is the fraction of the documents of the
+keyspace that would no longer be fetched.
+*/
+func (this *builder) adviseIndexes(keyspace datastore.Keyspace, node *algebra.KeyspaceTerm,
+	pred expression.Expression, minimals map[datastore.Index]*indexEntry) error {
+	id := metaId(node)
+	keys, eqs := adviseKeys(pred, node.Alias(), id)
+	if len(keys) == 0 {
+		return nil
+	}
+
+	covering := this.cover != nil && len(this.masks) == 0
+
+	// Fraction fetched by the current plan
+	current := 1.0
+	for index, entry := range minimals {
+		if covering && !index.IsPrimary() && !hasArrayKey(entry.keys) &&
+			this.coveredBy(append(entry.keys[0:len(entry.keys):len(entry.keys)], id)) {
+			return nil
+		}
+
+		current = math.Min(current, selectivity(entry.sargKeys, eqs))
+	}
+
+	n := SargableFor(pred, keys)
+	if n == 0 {
+		return nil
+	}
+
+	entry := &indexEntry{keys, keys[0:n], nil, nil}
+	for _, me := range minimals {
+		if narrowerOrEquivalent(me, entry) {
+			entry = nil
+			break
+		}
+	}
+
+	if entry != nil {
+		spans, err := SargFor(pred, entry.sargKeys, len(entry.keys))
+		if err != nil || len(spans) == 0 {
+			return err
+		}
+	}
+
+	// Extend the keys with the referenced fields, ORDER BY first
+	var ckeys expression.Expressions
+	if covering {
+		exprs := this.cover.Expressions()
+		if this.order != nil {
+			exprs = append(this.order.Expressions(), exprs...)
+		}
+
+		ckeys = keys[0:len(keys):len(keys)]
+		for _, expr := range exprs {
+			ckeys = appendPaths(ckeys, expr, node.Alias())
+		}
+
+		if !this.coveredBy(append(ckeys[0:len(ckeys):len(ckeys)], id)) {
+			ckeys = nil
+		}
+	}
+
+	if entry != nil && len(ckeys) != len(keys) {
+		advised := selectivity(entry.sargKeys, eqs)
+		this.recommend(keyspace, node, keys, n, false, current-advised)
+	}
+
+	if ckeys != nil {
+		this.recommend(keyspace, node, ckeys, n, true, current)
+	}
+
+	return nil
+}
+
+func (this *builder) recommend(keyspace datastore.Keyspace, node *algebra.KeyspaceTerm,
+	keys expression.Expressions, sargKeys int, covering bool, benefit float64) {
+	text, ok := createIndex(keyspace, node, keys)
+	if !ok {
+		return
+	}
+
+	this.advice = append(this.advice, map[string]interface{}{
+		"keyspace":          keyspace.Name(),
+		"alias":             node.Alias(),
+		"index":             text,
+		"sargable_keys":     sargKeys,
+		"covering":          covering,
+		"estimated_benefit": math.Floor(benefit*1000+0.5) / 1000,
+	})
+}
+
+// The CREATE INDEX statement of an index on the keys.
+func createIndex(keyspace datastore.Keyspace, node *algebra.KeyspaceTerm,
+	keys expression.Expressions) (string, bool) {
+	exprs := make([]string, len(keys))
+	for i, key := range keys {
+		key, err := unqualify(key, node.Alias())
+		if err != nil {
+			return "", false
+		}
+
+		exprs[i] = key.String()
+	}
+
+	name := "adv_" + keyspace.Name() + "_" + strings.Join(exprs, "_")
+	name = strings.Trim(_NON_ALNUM.ReplaceAllString(name, "_"), "_")
+
+	return fmt.Sprintf("CREATE INDEX %s ON %s:%s(%s)",
+		expression.NewIdentifier(name), expression.NewIdentifier(node.Namespace()),
+		expression.NewIdentifier(keyspace.Name()), strings.Join(exprs, ", ")), true
+}
+
+var _NON_ALNUM = regexp.MustCompile("[^A-Za-z0-9]+")
+
+/*
+Returns the CREATE INDEX statement of an index that the predicate of
+a statement would sarg when scanning the keyspace term, or false if
+no term of the predicate is sargable. Unlike ADVISE, existing indexes
+are not considered; it is meant for statements whose plan scans the
+primary index of the keyspace.
+*/
+func AdviseIndex(keyspace datastore.Keyspace, node *algebra.KeyspaceTerm,
+	pred expression.Expression) (string, bool) {
+	if pred == nil {
+		return "", false
+	}
+
+	pred, err := NewDNF().Map(pred.Copy())
+	if err != nil {
+		return "", false
+	}
+
+	keys, _ := adviseKeys(pred, node.Alias(), metaId(node))
+	if len(keys) == 0 {
+		return "", false
+	}
+
+	n := SargableFor(pred, keys)
+	if n == 0 {
+		return "", false
+	}
+
+	return createIndex(keyspace, node, keys)
+}
+
+/*
+Returns the candidate index keys of the predicate, equality terms
+first, and which of them are sarged by equality. A candidate is an
+operand of a conjunct that references no keyspace other than alias
+and that the conjunct sargs.
+*/
+func adviseKeys(pred expression.Expression, alias string, id expression.Expression) (
+	expression.Expressions, expression.Expressions) {
+	// The keys of a disjunction must sarg every disjunct
+	if or, ok := pred.(*expression.Or); ok {
+		keys, eqs := adviseKeys(or.Operands()[0], alias, id)
+		rv := make(expression.Expressions, 0, len(keys))
+		for _, key := range keys {
+			if SargableFor(pred, expression.Expressions{key}) > 0 {
+				rv = append(rv, key)
+			}
+		}
+
+		return rv, eqs
+	}
+
+	conjuncts := expression.Expressions{pred}
+	if and, ok := pred.(*expression.And); ok {
+		conjuncts = and.Operands()
+	}
+
+	var eqs, ranges expression.Expressions
+	for _, conjunct := range conjuncts {
+		if !localTo(conjunct, alias) {
+			continue
+		}
+
+		for _, child := range conjunct.Children() {
+			if child.Value() != nil || !child.Indexable() ||
+				child.EquivalentTo(id) || !localTo(child, alias) ||
+				SargableFor(conjunct, expression.Expressions{child}) == 0 {
+				continue
+			}
+
+			switch conjunct.(type) {
+			case *expression.Eq, *expression.In, *expression.IsNull:
+				eqs = appendKey(eqs, child)
+			default:
+				ranges = appendKey(ranges, child)
+			}
+		}
+	}
+
+	keys := eqs[0:len(eqs):len(eqs)]
+	for _, key := range ranges {
+		keys = appendKey(keys, key)
+	}
+
+	return keys, eqs
+}
+
+// Returns true if expr references alias, and no other keyspace.
+func localTo(expr expression.Expression, alias string) bool {
+	lister := &identifierLister{
+		identifiers: make(map[string]bool, 4),
+		bound:       make(map[string]bool, 4),
+	}
+	lister.SetTraverser(lister)
+
+	err := lister.Traverse(expr)
+	if err != nil || lister.subquery || !lister.identifiers[alias] {
+		return false
+	}
+
+	for id := range lister.identifiers {
+		if id != alias && !lister.bound[id] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func appendKey(keys expression.Expressions, key expression.Expression) expression.Expressions {
+	for _, k := range keys {
+		if k.EquivalentTo(key) {
+			return keys
+		}
+	}
+
+	return append(keys, key)
+}
+
+// Appends the field paths of alias that expr references.
+func appendPaths(keys expression.Expressions, expr expression.Expression, alias string) expression.Expressions {
+	if isPath(expr, alias) {
+		return appendKey(keys, expr)
+	}
+
+	for _, child := range expr.Children() {
+		keys = appendPaths(keys, child, alias)
+	}
+
+	return keys
+}
+
+func isPath(expr expression.Expression, alias string) bool {
+	field, ok := expr.(*expression.Field)
+	if !ok {
+		return false
+	}
+
+	if _, ok := field.Second().(*expression.FieldName); !ok {
+		return false
+	}
+
+	if id, ok := field.First().(*expression.Identifier); ok {
+		return id.Identifier() == alias
+	}
+
+	return isPath(field.First(), alias)
+}
+
+/*
+Estimated fraction of the documents that an index scan fetches.
+The keys following the first range key do not narrow the scan.
+*/
+func selectivity(sargKeys, eqs expression.Expressions) float64 {
+	rv := 1.0
+	for _, key := range sargKeys {
+		eq := false
+		for _, e := range eqs {
+			if e.EquivalentTo(key) {
+				eq = true
+				break
+			}
+		}
+
+		if !eq {
+			return rv * _RANGE_SELECTIVITY
+		}
+
+		rv *= _EQ_SELECTIVITY
+	}
+
+	return rv
+}
+
+// Returns a copy of the key with the fields of alias unqualified,
+// as in CREATE INDEX.
+func unqualify(key expression.Expression, alias string) (expression.Expression, error) {
+	unqualifier := &unqualifier{
+		alias: alias,
+	}
+	unqualifier.SetMapper(unqualifier)
+
+	return unqualifier.Map(key.Copy())
+}
+
+type unqualifier struct {
+	expression.MapperBase
+
+	alias string
+}
+
+func (this *unqualifier) VisitField(expr *expression.Field) (interface{}, error) {
+	if id, ok := expr.First().(*expression.Identifier); ok && id.Identifier() == this.alias {
+		if name, ok := expr.Second().(*expression.FieldName); ok {
+			return expression.NewIdentifier(name.Alias()), nil
+		}
+	}
+
+	return expr, expr.MapChildren(this)
+}
@@ -0,0 +1,73 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+)
+
+func TestAdvise(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stmt    string
+		indexes []string
+	}{
+		{"ADVISE SELECT * FROM b0 WHERE i > 5",
+			[]string{"CREATE INDEX `adv_b0_i` ON `p0`:`b0`(`i`)"}},
+		{"ADVISE SELECT * FROM b0 WHERE 5 < i AND j = 1",
+			[]string{"CREATE INDEX `adv_b0_j_i` ON `p0`:`b0`(`j`, `i`)"}},
+		{"ADVISE SELECT b.x FROM b0 AS b WHERE b.i IN [1, 2] ORDER BY b.y",
+			[]string{"CREATE INDEX `adv_b0_i` ON `p0`:`b0`(`i`)",
+				"CREATE INDEX `adv_b0_i_y_x` ON `p0`:`b0`(`i`, `y`, `x`)"}},
+		{"ADVISE SELECT a.i FROM b0 AS a JOIN b0 AS c ON KEYS a.k WHERE a.i = c.i AND a.j = 2",
+			[]string{"CREATE INDEX `adv_b0_j` ON `p0`:`b0`(`j`)"}},
+		{"ADVISE SELECT * FROM b0", nil},
+		{"ADVISE SELECT * FROM b0 WHERE META().id > \"5\"", nil},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := stmt.Accept(newBuilder(store, nil, "p0", false, false))
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		recs, _ := op.(*plan.Advise).Advice().Field("recommendations")
+		indexes := recs.Actual().([]interface{})
+		if len(indexes) != len(test.indexes) {
+			t.Errorf("Expected %d recommendations for %s, got %v", len(test.indexes), test.stmt, recs)
+			continue
+		}
+
+		for i, index := range indexes {
+			text := index.(map[string]interface{})["index"].(string)
+			if text != test.indexes[i] {
+				t.Errorf("Expected %s for %s, got %s", test.indexes[i], test.stmt, text)
+			}
+
+			_, er = n1ql.ParseStatement(text)
+			if er != nil {
+				t.Errorf("Recommendation %s does not parse: %v", text, er)
+			}
+		}
+	}
+}
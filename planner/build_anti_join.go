@@ -0,0 +1,303 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/plan"
+)
+
+type antiJoinTerm struct {
+	term   expression.Expression
+	query  *algebra.Select
+	exists bool
+}
+
+/*
+Removes from the WHERE clause the conjuncts NOT IN (subquery) and NOT
+EXISTS (subquery) that can be evaluated as anti-joins, which build a
+hash set of the subquery results once, instead of evaluating the
+subquery for every item. The subquery must be uncorrelated, or for
+NOT EXISTS, correlated only on an equality that can be pulled out of
+it.
+*/
+func (this *builder) antiJoins(where expression.Expression) (expression.Expression, []*antiJoinTerm) {
+	if where == nil || !this.features.Enabled(feature.ANTI_JOIN) {
+		return nil, nil
+	}
+
+	conjuncts := expression.Expressions{where}
+	if and, ok := where.(*expression.And); ok {
+		conjuncts = and.Operands()
+	}
+
+	var terms []*antiJoinTerm
+	remainder := make(expression.Expressions, 0, len(conjuncts))
+	for _, cond := range conjuncts {
+		term := this.antiJoin(cond)
+		if term != nil {
+			terms = append(terms, term)
+		} else {
+			remainder = append(remainder, cond)
+		}
+	}
+
+	switch {
+	case len(terms) == 0:
+		return where, nil
+	case len(remainder) == 0:
+		return nil, terms
+	case len(remainder) == 1:
+		return remainder[0], terms
+	default:
+		return expression.NewAnd(remainder...), terms
+	}
+}
+
+func (this *builder) antiJoin(cond expression.Expression) *antiJoinTerm {
+	not, ok := cond.(*expression.Not)
+	if !ok {
+		return nil
+	}
+
+	switch op := not.Operand().(type) {
+	case *expression.In:
+		subquery, ok := op.Second().(*algebra.Subquery)
+		if !ok || !simpleSubquery(subquery.Select()) || correlated(subquery.Select()) {
+			return nil
+		}
+
+		return &antiJoinTerm{
+			term:  op.First(),
+			query: subquery.Select(),
+		}
+	case *expression.Exists:
+		subquery, ok := op.Operand().(*algebra.Subquery)
+		if !ok || !simpleSubquery(subquery.Select()) {
+			return nil
+		}
+
+		if !correlated(subquery.Select()) {
+			return &antiJoinTerm{
+				query:  subquery.Select(),
+				exists: true,
+			}
+		}
+
+		// The projection of the decorrelated subquery would be masked
+		if this.restricted {
+			return nil
+		}
+
+		return decorrelate(subquery.Select())
+	default:
+		return nil
+	}
+}
+
+// A single keyspace, filtered, without any other clause.
+func simpleSubquery(query *algebra.Select) bool {
+	sub, ok := query.Subresult().(*algebra.Subselect)
+	if !ok || query.Order() != nil || query.Offset() != nil || query.Limit() != nil ||
+		sub.Let() != nil || sub.Group() != nil {
+		return false
+	}
+
+	if sub.From() == nil {
+		return true
+	}
+
+	term, ok := sub.From().(*algebra.KeyspaceTerm)
+	return ok && term.Projection() == nil
+}
+
+// Must be a simpleSubquery.
+func correlated(query *algebra.Select) bool {
+	sub := query.Subresult().(*algebra.Subselect)
+	for _, expr := range sub.Expressions() {
+		if scopeOf(expr, subqueryAlias(sub)) != _INNER {
+			return true
+		}
+	}
+
+	return false
+}
+
+/*
+Rewrites NOT EXISTS (SELECT ... FROM k WHERE k.a = o.b AND ...) to
+o.b NOT IN (SELECT RAW k.a FROM k WHERE ...), with NOT EXISTS
+semantics for a MISSING o.b. The inner side of the equality refers
+only to the subquery, and the outer side not at all.
+*/
+func decorrelate(query *algebra.Select) *antiJoinTerm {
+	sub := query.Subresult().(*algebra.Subselect)
+	alias := subqueryAlias(sub)
+	if alias == "" || sub.Where() == nil {
+		return nil
+	}
+
+	for _, expr := range sub.From().Expressions() {
+		if scopeOf(expr, alias) != _INNER {
+			return nil
+		}
+	}
+
+	conjuncts := expression.Expressions{sub.Where()}
+	if and, ok := sub.Where().(*expression.And); ok {
+		conjuncts = and.Operands()
+	}
+
+	var inner, outer expression.Expression
+	remainder := make(expression.Expressions, 0, len(conjuncts))
+	for _, cond := range conjuncts {
+		if scopeOf(cond, alias) == _INNER {
+			remainder = append(remainder, cond)
+			continue
+		}
+
+		eq, ok := cond.(*expression.Eq)
+		if !ok || inner != nil {
+			return nil
+		}
+
+		first, second := scopeOf(eq.First(), alias), scopeOf(eq.Second(), alias)
+		switch {
+		case first == _INNER && second == _OUTER:
+			inner, outer = eq.First(), eq.Second()
+		case first == _OUTER && second == _INNER:
+			inner, outer = eq.Second(), eq.First()
+		default:
+			return nil
+		}
+	}
+
+	var where expression.Expression
+	switch len(remainder) {
+	case 0:
+	case 1:
+		where = remainder[0]
+	default:
+		where = expression.NewAnd(remainder...)
+	}
+
+	projection := algebra.NewRawProjection(false, inner, "")
+	decorrelated := algebra.NewSelect(
+		algebra.NewSubselect(sub.From(), nil, where, nil, projection), nil, nil, nil)
+
+	return &antiJoinTerm{
+		term:   outer,
+		query:  decorrelated,
+		exists: true,
+	}
+}
+
+func subqueryAlias(sub *algebra.Subselect) string {
+	if sub.From() == nil {
+		return ""
+	}
+
+	return sub.From().(*algebra.KeyspaceTerm).Alias()
+}
+
+func (this *builder) buildAntiJoins(terms []*antiJoinTerm) {
+	for _, term := range terms {
+		this.subChildren = append(this.subChildren,
+			plan.NewAntiJoin(term.term, term.query, term.exists))
+	}
+}
+
+const (
+	_INNER = iota // Refers only to the subquery alias, or to nothing
+	_OUTER        // Refers only to identifiers outside the subquery
+	_MIXED        // Refers to both, or contains a subquery
+)
+
+/*
+Formalized identifiers are keyspace aliases or bound variables, and
+the aliases of a subquery cannot shadow those in scope. So an
+identifier that is neither the subquery alias nor bound within the
+expression is outside the subquery.
+*/
+func scopeOf(expr expression.Expression, alias string) int {
+	lister := &identifierLister{
+		identifiers: make(map[string]bool, 4),
+		bound:       make(map[string]bool, 4),
+	}
+	lister.SetTraverser(lister)
+
+	err := lister.Traverse(expr)
+	if err != nil || lister.subquery {
+		return _MIXED
+	}
+
+	inner, outer := false, false
+	for identifier := range lister.identifiers {
+		switch {
+		case identifier == alias:
+			inner = true
+		case !lister.bound[identifier]:
+			outer = true
+		}
+	}
+
+	switch {
+	case inner && outer:
+		return _MIXED
+	case outer:
+		return _OUTER
+	default:
+		return _INNER
+	}
+}
+
+type identifierLister struct {
+	expression.TraverserBase
+
+	identifiers map[string]bool
+	bound       map[string]bool
+	subquery    bool
+}
+
+func (this *identifierLister) VisitIdentifier(expr *expression.Identifier) (interface{}, error) {
+	this.identifiers[expr.Identifier()] = true
+	return nil, nil
+}
+
+func (this *identifierLister) VisitSubquery(expr expression.Subquery) (interface{}, error) {
+	this.subquery = true
+	return nil, nil
+}
+
+func (this *identifierLister) VisitAny(expr *expression.Any) (interface{}, error) {
+	return this.visitBindings(expr.Bindings(), expr)
+}
+
+func (this *identifierLister) VisitEvery(expr *expression.Every) (interface{}, error) {
+	return this.visitBindings(expr.Bindings(), expr)
+}
+
+func (this *identifierLister) VisitArray(expr *expression.Array) (interface{}, error) {
+	return this.visitBindings(expr.Bindings(), expr)
+}
+
+func (this *identifierLister) VisitFirst(expr *expression.First) (interface{}, error) {
+	return this.visitBindings(expr.Bindings(), expr)
+}
+
+func (this *identifierLister) visitBindings(bindings expression.Bindings, expr expression.Expression) (
+	interface{}, error) {
+	for _, b := range bindings {
+		this.bound[b.Variable()] = true
+	}
+
+	return nil, this.TraverseList(expr.Children())
+}
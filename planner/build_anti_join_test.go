@@ -0,0 +1,62 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+func TestAntiJoin(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stmt     string
+		antiJoin bool
+	}{
+		{"SELECT * FROM b0 AS b WHERE b.i NOT IN (SELECT RAW c.i FROM b1 AS c)", true},
+		{"SELECT * FROM b0 AS b WHERE b.i > 1 AND NOT EXISTS (SELECT 1 FROM b1 AS c WHERE c.i = b.i AND c.j > 1)", true},
+		{"SELECT * FROM b0 AS b WHERE NOT EXISTS (SELECT 1 FROM b1 AS c)", true},
+		{"SELECT * FROM b0 AS b WHERE b.i NOT IN (SELECT RAW c.i FROM b1 AS c WHERE c.j = b.j)", false},
+		{"SELECT * FROM b0 AS b WHERE NOT EXISTS (SELECT 1 FROM b1 AS c WHERE c.i < b.i)", false},
+		{"SELECT * FROM b0 AS b WHERE NOT EXISTS (SELECT 1 FROM b1 AS c WHERE c.i = b.i AND c.j = b.j)", false},
+		{"SELECT * FROM b0 AS b WHERE b.i NOT IN (SELECT RAW c.i FROM b1 AS c LIMIT 1)", false},
+		{"SELECT * FROM b0 AS b WHERE b.i NOT IN [1, 2]", false},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, nil, "p0", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		antiJoin := strings.Contains(string(bytes), `"#operator":"AntiJoin"`)
+		if antiJoin != test.antiJoin {
+			t.Errorf("Expected anti-join %v for %s, got plan %s", test.antiJoin, test.stmt, bytes)
+		}
+	}
+}
@@ -0,0 +1,47 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Statements that require a capability a keyspace lacks are rejected
+when they are planned, rather than failing when they run. Keyspaces
+that do not accept DML are read-only.
+*/
+func requireCapability(keyspace datastore.Keyspace, capability datastore.Capabilities) error {
+	if datastore.KeyspaceCapabilities(keyspace).Has(capability) {
+		return nil
+	}
+
+	if capability == datastore.CAPABILITY_DML {
+		return errors.NewReadonlyStatementError("keyspace " + keyspace.Name())
+	}
+
+	return errors.NewKeyspaceCapabilityError(keyspace.Name(), capability.String())
+}
+
+// Whether the keyspace has the capability. If not, the plan choice it
+// limits is noted, for EXPLAIN.
+func (this *builder) hasCapability(keyspace datastore.Keyspace, capability datastore.Capabilities,
+	choice string) bool {
+	if datastore.KeyspaceCapabilities(keyspace).Has(capability) {
+		return true
+	}
+
+	this.notes = append(this.notes, fmt.Sprintf("Keyspace %s does not support %s: %s.",
+		keyspace.Name(), capability, choice))
+	return false
+}
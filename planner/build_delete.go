@@ -10,21 +10,45 @@
 package planner
 
 import (
+	"strings"
+
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/plan"
 )
 
 func (this *builder) VisitDelete(stmt *algebra.Delete) (interface{}, error) {
 	this.cover = stmt
-	this.where = stmt.Where()
+	if projectsStar(stmt.Returning()) {
+		this.cover = nil
+	}
 
 	ksref := stmt.KeyspaceRef()
-	keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	keyspace, err := this.getMutationKeyspace(ksref)
+	if err != nil {
+		return nil, err
+	}
+
+	this.mutated = keyspace
+
+	this.policy, err = this.termPolicy(keyspace, ksref.Alias())
+	if err != nil {
+		return nil, err
+	}
+
+	this.where = andPolicy(stmt.Where(), this.policy)
+
+	this.masks, err = this.termMasks(keyspace, ksref.Alias())
 	if err != nil {
 		return nil, err
 	}
 
-	err = this.beginMutate(keyspace, ksref, stmt.Keys(), stmt.Indexes(), stmt.Limit())
+	err = checkMasks(this.masks, stmt.Where())
+	if err != nil {
+		return nil, err
+	}
+
+	err = this.beginMutate(keyspace, ksref, stmt.Keys(), stmt.KeyRange(), stmt.Indexes(), stmt.Limit())
 	if err != nil {
 		return nil, err
 	}
@@ -33,7 +57,7 @@ func (this *builder) VisitDelete(stmt *algebra.Delete) (interface{}, error) {
 	subChildren = append(subChildren, plan.NewSendDelete(keyspace, ksref.Alias(), stmt.Limit()))
 
 	if stmt.Returning() != nil {
-		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning()), plan.NewFinalProject())
+		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning(), this.masks), plan.NewFinalProject())
 	}
 
 	parallel := plan.NewParallel(plan.NewSequence(subChildren...), this.maxParallelism)
@@ -49,3 +73,19 @@ func (this *builder) VisitDelete(stmt *algebra.Delete) (interface{}, error) {
 
 	return plan.NewSequence(this.children...), nil
 }
+
+// System keyspaces may support deletes and updates, e.g.
+// system:active_requests, where deleting a request cancels it, and
+// system:tasks, where updating a task changes its schedule.
+func (this *builder) getMutationKeyspace(ksref *algebra.KeyspaceRef) (datastore.Keyspace, error) {
+	if strings.ToLower(ksref.Namespace()) != "#system" {
+		return this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	}
+
+	namespace, err := this.systemstore.NamespaceByName(ksref.Namespace())
+	if err != nil {
+		return nil, err
+	}
+
+	return namespace.KeyspaceByName(ksref.Keyspace())
+}
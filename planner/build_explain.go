@@ -10,15 +10,61 @@
 package planner
 
 import (
+	"fmt"
+
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/virtual"
 	"github.com/couchbase/query/plan"
 )
 
 func (this *builder) VisitExplain(stmt *algebra.Explain) (interface{}, error) {
+	if len(stmt.Indexes()) > 0 {
+		prev := this.virtualIndexes
+		defer func() { this.virtualIndexes = prev }()
+
+		indexes, err := this.buildVirtualIndexes(stmt.Indexes())
+		if err != nil {
+			return nil, err
+		}
+
+		this.virtualIndexes = append(prev[0:len(prev):len(prev)], indexes...)
+	}
+
 	op, err := stmt.Statement().Accept(this)
 	if err != nil {
 		return nil, err
 	}
 
-	return plan.NewExplain(op.(plan.Operator)), nil
+	return plan.NewExplain(op.(plan.Operator), stmt.Format(), this.features.Values(), this.notes), nil
+}
+
+// Returns the virtual indexes declared by CREATE INDEX statements.
+func (this *builder) buildVirtualIndexes(stmts []algebra.Statement) ([]datastore.Index, error) {
+	indexes := make([]datastore.Index, 0, len(stmts))
+	for _, stmt := range stmts {
+		switch stmt := stmt.(type) {
+		case *algebra.CreateIndex:
+			ksref := stmt.Keyspace()
+			keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+			if err != nil {
+				return nil, err
+			}
+
+			indexes = append(indexes, virtual.NewIndex(keyspace, stmt.Name(),
+				stmt.Expressions(), stmt.Where()))
+		case *algebra.CreatePrimaryIndex:
+			ksref := stmt.Keyspace()
+			keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+			if err != nil {
+				return nil, err
+			}
+
+			indexes = append(indexes, virtual.NewPrimaryIndex(keyspace, stmt.Name()))
+		default:
+			return nil, fmt.Errorf("Virtual index must be declared by CREATE INDEX.")
+		}
+	}
+
+	return indexes, nil
 }
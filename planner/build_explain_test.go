@@ -0,0 +1,189 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/virtual"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+)
+
+func TestVirtualIndexes(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	scan := func(stmt algebra.Statement, indexes []datastore.Index) string {
+		op, er := BuildVirtual(stmt, store, nil, "p0", false, false, indexes)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		return string(bytes)
+	}
+
+	// The recommendation of ADVISE is selected as a virtual index
+	stmt, er := n1ql.ParseStatement("ADVISE SELECT * FROM b0 WHERE i > 5")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	advice := scan(stmt, nil)
+	if !strings.Contains(advice, "CREATE INDEX `adv_b0_i` ON `p0`:`b0`(`i`)") {
+		t.Fatalf("Unexpected advice %s", advice)
+	}
+
+	stmt, er = n1ql.ParseStatement(`EXPLAIN WITH {"indexes": ["CREATE INDEX adv_b0_i ON p0:b0(i)"]} ` +
+		`SELECT * FROM b0 WHERE i > 5`)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	op, er := stmt.Accept(newBuilder(store, nil, "p0", false, false))
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	bytes, _ := json.Marshal(op.(*plan.Explain).Operator())
+	explain := string(bytes)
+	if !strings.Contains(explain, `"index":"adv_b0_i"`) || !strings.Contains(explain, `"using":"virtual"`) {
+		t.Errorf("Expected scan of virtual index, got plan %s", explain)
+	}
+
+	// Virtual indexes of the API
+	stmt, er = n1ql.ParseStatement("SELECT * FROM b0 WHERE j = 1")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	namespace, _ := store.NamespaceByName("p0")
+	keyspace, _ := namespace.KeyspaceByName("b0")
+	keys := expression.Expressions{expression.NewIdentifier("j")}
+	index := virtual.NewIndex(keyspace, "vj", keys, nil)
+
+	text := scan(stmt, []datastore.Index{index})
+	if !strings.Contains(text, `"index":"vj"`) {
+		t.Errorf("Expected scan of virtual index, got plan %s", text)
+	}
+
+	text = scan(stmt, nil)
+	if strings.Contains(text, `"using":"virtual"`) {
+		t.Errorf("Unexpected scan of virtual index, got plan %s", text)
+	}
+}
+
+func TestExplainGraph(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, er := n1ql.ParseStatement(`EXPLAIN WITH {"format": "dot"} SELECT * FROM b0 WHERE i > 5`)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	op, er := stmt.Accept(newBuilder(store, nil, "p0", false, false))
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	explain := op.(*plan.Explain)
+	if explain.Format() != algebra.EXPLAIN_DOT {
+		t.Errorf("Expected format %s, got %s", algebra.EXPLAIN_DOT, explain.Format())
+	}
+
+	graph, er := plan.NewGraph(explain.Operator())
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	dot := graph.DOT()
+	if !strings.HasPrefix(dot, "digraph plan {") || !strings.Contains(dot, `label="PrimaryScan\nindex: #primary`) ||
+		!strings.Contains(dot, `label="Filter\ncondition: `) || !strings.Contains(dot, "n0 -> n1;") {
+		t.Errorf("Unexpected graph %s", dot)
+	}
+
+	flame := graph.Flame()
+	if flame["name"] != "Sequence" || flame["value"] != graph.Size() {
+		t.Errorf("Unexpected flame graph %v", flame)
+	}
+
+	_, er = n1ql.ParseStatement(`EXPLAIN WITH {"format": "svg"} SELECT * FROM b0`)
+	if er == nil {
+		t.Errorf("Expected error for unknown format")
+	}
+}
+
+func TestPlanDiff(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, er := n1ql.ParseStatement("SELECT * FROM b0 WHERE i > 5")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	before, er := BuildVirtual(stmt, store, nil, "p0", false, false, nil)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	namespace, _ := store.NamespaceByName("p0")
+	keyspace, _ := namespace.KeyspaceByName("b0")
+	keys := expression.Expressions{expression.NewIdentifier("i")}
+	index := virtual.NewIndex(keyspace, "vi", keys, nil)
+
+	after, er := BuildVirtual(stmt, store, nil, "p0", false, false, []datastore.Index{index})
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	diffs, er := plan.Diff(before, before)
+	if er != nil || len(diffs) != 0 {
+		t.Errorf("Expected no differences, got %v %v", diffs, er)
+	}
+
+	// The primary scan is replaced by a scan of the index
+	diffs, er = plan.Diff(before, after)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	replaced := false
+	for _, diff := range diffs {
+		if diff.Change == plan.DIFF_REPLACED && strings.HasPrefix(diff.Before, "PrimaryScan") &&
+			strings.HasPrefix(diff.After, "IndexScan (index: vi") {
+			replaced = true
+		} else if diff.Change != plan.DIFF_CHANGED {
+			t.Errorf("Unexpected difference %v", diff)
+		}
+	}
+
+	if !replaced {
+		t.Errorf("Expected the primary scan to be replaced, got %v", diffs)
+	}
+}
@@ -0,0 +1,36 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/plan"
+)
+
+/*
+IMPORT is planned as UPSERT ... SELECT over the rows of the file,
+which are keyed by the scan so that rejected rows can be written to
+the errors file.
+*/
+func (this *builder) VisitImport(stmt *algebra.Import) (interface{}, error) {
+	ksref := stmt.KeyspaceRef()
+	ksref.SetDefaultNamespace(this.defaultNamespace(ksref.Keyspace()))
+
+	keyspace, err := this.getTargetKeyspace(ksref.Namespace(), ksref.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	scan := plan.NewImportScan(stmt.Path(), stmt.Format(), stmt.Key(), stmt.Value(),
+		stmt.Batch(), stmt.Errors())
+	send := plan.NewSendUpsert(keyspace, ksref.Alias(), nil, nil, this.keyspacePolicy(keyspace))
+	parallel := plan.NewParallel(plan.NewSequence(send, plan.NewDiscard()), this.maxParallelism)
+	return plan.NewSequence(scan, parallel), nil
+}
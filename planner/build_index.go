@@ -31,6 +31,10 @@ func (this *builder) VisitCreatePrimaryIndex(stmt *algebra.CreatePrimaryIndex) (
 func (this *builder) VisitCreateIndex(stmt *algebra.CreateIndex) (interface{}, error) {
 	ksref := stmt.Keyspace()
 	keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	if err == nil {
+		err = requireCapability(keyspace, datastore.CAPABILITY_SECONDARY_INDEX)
+	}
+
 	if err != nil {
 		return nil, err
 	}
@@ -97,20 +101,14 @@ func (this *builder) VisitBuildIndexes(stmt *algebra.BuildIndexes) (interface{},
 
 func (this *builder) getNameKeyspace(ns, ks string) (datastore.Keyspace, error) {
 	if ns == "" {
-		ns = this.namespace
+		ns = this.defaultNamespace(ks)
 	}
 
 	if strings.ToLower(ns) == "#system" {
 		return nil, fmt.Errorf("Index operations not allowed on system namespace.")
 	}
 
-	datastore := this.datastore
-	namespace, err := datastore.NamespaceByName(ns)
-	if err != nil {
-		return nil, err
-	}
-
-	keyspace, err := namespace.KeyspaceByName(ks)
+	keyspace, err := datastore.ResolveKeyspace(this.datastore, ns, ks)
 	if err != nil {
 		return nil, err
 	}
@@ -0,0 +1,83 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/plan"
+)
+
+func (this *builder) VisitCreateKeyspace(stmt *algebra.CreateKeyspace) (interface{}, error) {
+	ns, name, err := this.getTempKeyspace(stmt.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreateKeyspace(ns, name), nil
+}
+
+func (this *builder) VisitDropKeyspace(stmt *algebra.DropKeyspace) (interface{}, error) {
+	ns, name, err := this.getTempKeyspace(stmt.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewDropKeyspace(ns, name), nil
+}
+
+func (this *builder) getTempKeyspace(ksref *algebra.KeyspaceRef) (string, string, error) {
+	ns := ksref.Namespace()
+	if ns == "" {
+		ns = this.defaultNamespace(ksref.Keyspace())
+	}
+
+	if strings.ToLower(ns) == "#system" {
+		return "", "", fmt.Errorf("Temporary keyspaces not allowed on system namespace.")
+	}
+
+	name := ksref.Keyspace()
+	if !datastore.IsTemporary(name) {
+		return "", "", fmt.Errorf("Temporary keyspace names must start with #: %s", name)
+	}
+
+	return ns, name, nil
+}
+
+/*
+INSERT, UPSERT and IMPORT into a temporary keyspace that does not
+exist create it when they are planned, so that the results of a
+statement can be staged without declaring the keyspace first.
+*/
+func (this *builder) getTargetKeyspace(ns, ks string) (datastore.Keyspace, error) {
+	keyspace, err := this.getNameKeyspace(ns, ks)
+	if err == nil {
+		this.mutated = keyspace
+	}
+
+	if err == nil || !datastore.IsTemporary(ks) {
+		return keyspace, err
+	}
+
+	namespace, er := this.datastore.NamespaceByName(ns)
+	if er != nil {
+		return nil, err
+	}
+
+	manager, ok := namespace.(datastore.KeyspaceManager)
+	if !ok {
+		return nil, err
+	}
+
+	return manager.CreateKeyspace(ks)
+}
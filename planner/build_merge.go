@@ -42,6 +42,15 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 		children = append(children, this.children...)
 		subChildren = append(subChildren, this.subChildren...)
 
+		// Row-level security policy of the source keyspace
+		policy, err := this.fromPolicy(source.From())
+		if err != nil {
+			return nil, err
+		}
+
+		if policy != nil {
+			subChildren = append(subChildren, plan.NewFilter(policy))
+		}
 	}
 
 	if source.As() != "" {
@@ -49,22 +58,48 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 	}
 
 	ksref := stmt.KeyspaceRef()
-	ksref.SetDefaultNamespace(this.namespace)
+	ksref.SetDefaultNamespace(this.defaultNamespace(ksref.Keyspace()))
 
 	keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
 	if err != nil {
 		return nil, err
 	}
 
+	this.mutated = keyspace
+
+	// Matched documents hidden by the policy are neither updated
+	// nor deleted
+	policy, err := this.termPolicy(keyspace, ksref.Alias())
+	if err != nil {
+		return nil, err
+	}
+
+	masks, err := this.termMasks(keyspace, ksref.Alias())
+	if err != nil {
+		return nil, err
+	}
+
 	actions := stmt.Actions()
+	if actions.Update() != nil {
+		err = checkMasks(masks, actions.Update().Where())
+	}
+
+	if err == nil && actions.Delete() != nil {
+		err = checkMasks(masks, actions.Delete().Where())
+	}
+
+	if err != nil {
+		return nil, err
+	}
 	var update, delete, insert plan.Operator
 
 	if actions.Update() != nil {
 		act := actions.Update()
 		ops := make([]plan.Operator, 0, 5)
 
-		if act.Where() != nil {
-			ops = append(ops, plan.NewFilter(act.Where()))
+		where := andPolicy(act.Where(), policy)
+		if where != nil {
+			ops = append(ops, plan.NewFilter(where))
 		}
 
 		ops = append(ops, plan.NewClone(ksref.Alias()))
@@ -77,7 +112,7 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 			ops = append(ops, plan.NewUnset(act.Unset()))
 		}
 
-		ops = append(ops, plan.NewSendUpdate(keyspace, ksref.Alias(), stmt.Limit()))
+		ops = append(ops, plan.NewSendUpdate(keyspace, ksref.Alias(), stmt.Limit(), where))
 		update = plan.NewSequence(ops...)
 	}
 
@@ -85,8 +120,8 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 		act := actions.Delete()
 		ops := make([]plan.Operator, 0, 4)
 
-		if act.Where() != nil {
-			ops = append(ops, plan.NewFilter(act.Where()))
+		if where := andPolicy(act.Where(), policy); where != nil {
+			ops = append(ops, plan.NewFilter(where))
 		}
 
 		ops = append(ops, plan.NewSendDelete(keyspace, ksref.Alias(), stmt.Limit()))
@@ -101,7 +136,8 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 			ops = append(ops, plan.NewFilter(act.Where()))
 		}
 
-		ops = append(ops, plan.NewSendInsert(keyspace, ksref.Alias(), stmt.Key(), act.Value(), stmt.Limit()))
+		ops = append(ops, plan.NewSendInsert(keyspace, ksref.Alias(),
+			stmt.Key(), act.Value(), stmt.Limit(), this.keyspacePolicy(keyspace)))
 		insert = plan.NewSequence(ops...)
 	}
 
@@ -109,7 +145,7 @@ func (this *builder) VisitMerge(stmt *algebra.Merge) (interface{}, error) {
 	subChildren = append(subChildren, merge)
 
 	if stmt.Returning() != nil {
-		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning()), plan.NewFinalProject())
+		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning(), masks), plan.NewFinalProject())
 	}
 
 	parallel := plan.NewParallel(plan.NewSequence(subChildren...), this.maxParallelism)
@@ -17,8 +17,9 @@ import (
 )
 
 func (this *builder) beginMutate(keyspace datastore.Keyspace, ksref *algebra.KeyspaceRef,
-	keys expression.Expression, indexes algebra.IndexRefs, limit expression.Expression) error {
-	ksref.SetDefaultNamespace(this.namespace)
+	keys expression.Expression, keyRange *algebra.KeyRange, indexes algebra.IndexRefs,
+	limit expression.Expression) error {
+	ksref.SetDefaultNamespace(this.defaultNamespace(ksref.Keyspace()))
 	term := algebra.NewKeyspaceTerm(ksref.Namespace(), ksref.Keyspace(), nil, ksref.As(), keys, indexes)
 
 	this.children = make([]plan.Operator, 0, 8)
@@ -28,7 +29,14 @@ func (this *builder) beginMutate(keyspace datastore.Keyspace, ksref *algebra.Key
 		limit = nil
 	}
 
-	scan, err := this.selectScan(keyspace, term, limit)
+	var scan plan.Operator
+	var err error
+	if keyRange != nil {
+		scan, err = this.selectKeyRangeScan(keyspace, term, keyRange, limit)
+	} else {
+		scan, err = this.selectScan(keyspace, term, limit)
+	}
+
 	if err != nil {
 		return err
 	}
@@ -59,3 +67,37 @@ func (this *builder) beginMutate(keyspace datastore.Keyspace, ksref *algebra.Key
 
 	return nil
 }
+
+/*
+Scan the document keys between the bounds of USE KEYS BETWEEN,
+inclusive, using the primary index.
+*/
+func (this *builder) selectKeyRangeScan(keyspace datastore.Keyspace, term *algebra.KeyspaceTerm,
+	keyRange *algebra.KeyRange, limit expression.Expression) (plan.Operator, error) {
+	var hintIndexes []datastore.Index
+	var err error
+	if term.Indexes() != nil {
+		hintIndexes, err = this.allHints(keyspace, term.Indexes())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	primary, err := buildPrimaryIndex(keyspace, hintIndexes, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	this.maxParallelism = 0 // Use default parallelism for index scans
+	this.coveringScan = nil
+
+	span := &plan.Span{
+		Range: plan.Range{
+			Low:       expression.Expressions{keyRange.Low()},
+			High:      expression.Expressions{keyRange.High()},
+			Inclusion: datastore.BOTH,
+		},
+	}
+
+	return plan.NewIndexScan(primary, term, plan.Spans{span}, false, limit, nil), nil
+}
@@ -0,0 +1,327 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+)
+
+func (this *builder) VisitCreatePolicy(stmt *algebra.CreatePolicy) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	keyspace, err := this.getPolicyKeyspace(ksref)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreatePolicy(keyspace, stmt.Predicate()), nil
+}
+
+func (this *builder) VisitDropPolicy(stmt *algebra.DropPolicy) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	keyspace, err := this.getPolicyKeyspace(ksref)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewDropPolicy(keyspace), nil
+}
+
+func (this *builder) VisitCreateMask(stmt *algebra.CreateMask) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	keyspace, err := this.getPolicyKeyspace(ksref)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreateMask(keyspace, stmt.Path(), stmt.MaskType()), nil
+}
+
+func (this *builder) VisitDropMask(stmt *algebra.DropMask) (interface{}, error) {
+	ksref := stmt.Keyspace()
+	keyspace, err := this.getPolicyKeyspace(ksref)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewDropMask(keyspace, stmt.Path()), nil
+}
+
+func (this *builder) getPolicyKeyspace(ksref *algebra.KeyspaceRef) (datastore.Keyspace, error) {
+	if strings.ToLower(ksref.Namespace()) == "#system" {
+		return nil, fmt.Errorf("Policies not allowed on system namespace.")
+	}
+
+	return this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+}
+
+/*
+Returns the unqualified predicate of the policy on the keyspace,
+to be evaluated against its documents, or nil if the statement is
+not restricted.
+*/
+func (this *builder) keyspacePolicy(keyspace datastore.Keyspace) expression.Expression {
+	if !this.restricted {
+		return nil
+	}
+
+	p := policy.Get(keyspace.NamespaceId(), keyspace.Name())
+	if p == nil {
+		return nil
+	}
+
+	return p.Predicate()
+}
+
+/*
+Returns the predicate of the policy on the keyspace, qualified with
+the alias of the keyspace in the statement, or nil if the statement
+is not restricted.
+*/
+func (this *builder) termPolicy(keyspace datastore.Keyspace, alias string) (expression.Expression, error) {
+	pred := this.keyspacePolicy(keyspace)
+	if pred == nil {
+		return nil, nil
+	}
+
+	formalizer := expression.NewFormalizer()
+	formalizer.Keyspace = alias
+	return formalizer.Map(pred.Copy())
+}
+
+func andPolicy(cond, policy expression.Expression) expression.Expression {
+	switch {
+	case policy == nil:
+		return cond
+	case cond == nil:
+		return policy
+	default:
+		return expression.NewAnd(cond, policy)
+	}
+}
+
+// Returns the qualified policy predicate of the primary term.
+func (this *builder) fromPolicy(from algebra.FromTerm) (expression.Expression, error) {
+	if !this.restricted || from == nil {
+		return nil, nil
+	}
+
+	term, ok := from.PrimaryTerm().(*algebra.KeyspaceTerm)
+	if !ok {
+		return nil, nil
+	}
+
+	keyspace, err := this.getTermKeyspace(term)
+	if err != nil {
+		return nil, err
+	}
+
+	pred, err := this.termPolicy(keyspace, term.Alias())
+	if err != nil || pred == nil {
+		return nil, err
+	}
+
+	// The alias of a keyspace path names the projected value,
+	// not the document that the policy applies to
+	if term.Projection() != nil {
+		return nil, fmt.Errorf("Keyspace path not allowed on %s, which has a policy.", term.Keyspace())
+	}
+
+	return pred, nil
+}
+
+/*
+Returns the masks of the keyspace, qualified with the alias of the
+keyspace in the statement, as terms setting each masked path to its
+masked value; or nil if the statement is not restricted.
+*/
+func (this *builder) termMasks(keyspace datastore.Keyspace, alias string) (algebra.SetTerms, error) {
+	if !this.restricted {
+		return nil, nil
+	}
+
+	p := policy.Get(keyspace.NamespaceId(), keyspace.Name())
+	if p == nil || len(p.Masks()) == 0 {
+		return nil, nil
+	}
+
+	formalizer := expression.NewFormalizer()
+	formalizer.Keyspace = alias
+
+	masks := make(algebra.SetTerms, 0, len(p.Masks()))
+	for _, m := range p.Masks() {
+		expr, err := formalizer.Map(m.Path().Copy())
+		if err != nil {
+			return nil, err
+		}
+
+		path, ok := expr.(expression.Path)
+		if !ok {
+			return nil, fmt.Errorf("Invalid masked path %s.", m.Path())
+		}
+
+		mask := expression.NewMask(path.Copy(), expression.NewConstant(m.Type()))
+		masks = append(masks, algebra.NewSetTerm(path, mask, nil))
+	}
+
+	return masks, nil
+}
+
+/*
+Returns the qualified masks of all the keyspaces of the FROM clause,
+along with the UNNEST expressions and join keys, which are evaluated
+on unmasked values.
+*/
+func (this *builder) fromMasks(from algebra.FromTerm) (algebra.SetTerms, expression.Expressions, error) {
+	if !this.restricted || from == nil {
+		return nil, nil, nil
+	}
+
+	switch from := from.(type) {
+	case *algebra.KeyspaceTerm:
+		masks, err := this.keyspaceTermMasks(from)
+		return masks, nil, err
+	case *algebra.Join:
+		masks, unnests, err := this.fromMasks(from.Left())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		right, err := this.keyspaceTermMasks(from.Right())
+		return append(masks, right...), append(unnests, from.Right().Keys()), err
+	case *algebra.Nest:
+		masks, unnests, err := this.fromMasks(from.Left())
+		if err != nil {
+			return nil, nil, err
+		}
+
+		// Nested documents are arrays, which masks do not apply to
+		right, err := this.keyspaceTermMasks(from.Right())
+		if err == nil && len(right) > 0 {
+			err = fmt.Errorf("NEST not allowed on %s, which has masks.", from.Right().Keyspace())
+		}
+
+		return masks, append(unnests, from.Right().Keys()), err
+	case *algebra.Unnest:
+		masks, unnests, err := this.fromMasks(from.Left())
+		return masks, append(unnests, from.Expression()), err
+	default:
+		// Subqueries mask their own projections
+		return nil, nil, nil
+	}
+}
+
+func (this *builder) keyspaceTermMasks(term *algebra.KeyspaceTerm) (algebra.SetTerms, error) {
+	keyspace, err := this.getTermKeyspace(term)
+	if err != nil {
+		return nil, err
+	}
+
+	masks, err := this.termMasks(keyspace, term.Alias())
+	if err != nil || len(masks) == 0 {
+		return nil, err
+	}
+
+	if term.Projection() != nil {
+		return nil, fmt.Errorf("Keyspace path not allowed on %s, which has masks.", term.Keyspace())
+	}
+
+	return masks, nil
+}
+
+func (this *builder) selectMasks(node *algebra.Subselect,
+	aggs map[string]algebra.Aggregate) (algebra.SetTerms, error) {
+	masks, unnests, err := this.fromMasks(node.From())
+	if err != nil || len(masks) == 0 {
+		return nil, err
+	}
+
+	exprs := make(expression.Expressions, 0, 16)
+	exprs = append(exprs, unnests...)
+	for _, binding := range node.Let() {
+		exprs = append(exprs, binding.Expression())
+	}
+
+	exprs = append(exprs, node.Where())
+
+	if group := node.Group(); group != nil {
+		exprs = append(exprs, group.By()...)
+		for _, binding := range group.Letting() {
+			exprs = append(exprs, binding.Expression())
+		}
+		exprs = append(exprs, group.Having())
+	}
+
+	if this.order != nil {
+		exprs = append(exprs, this.order.Expressions()...)
+	}
+
+	for _, agg := range aggs {
+		exprs = append(exprs, agg)
+	}
+
+	err = checkMasks(masks, exprs...)
+	if err != nil {
+		return nil, err
+	}
+
+	return masks, nil
+}
+
+/*
+Masks are applied by the projection, so values derived from masked
+paths before the projection, such as LET bindings, UNNESTs, GROUP BY
+keys and aggregates, would not be masked. Nor would the values that
+WHERE, HAVING, join keys and ORDER BY filter and sort on, which the
+results would disclose. They are not allowed.
+*/
+func checkMasks(masks algebra.SetTerms, exprs ...expression.Expression) error {
+	for _, expr := range exprs {
+		if expr == nil {
+			continue
+		}
+
+		for _, mask := range masks {
+			if refersTo(expr, mask.Path()) {
+				return fmt.Errorf("%s not allowed, as it refers to masked path %s.", expr, mask.Path())
+			}
+		}
+	}
+
+	return nil
+}
+
+// Returns true if the value of expr may contain the value at path,
+// including through correlated references in subqueries.
+func refersTo(expr expression.Expression, path expression.Path) bool {
+	var children expression.Expressions
+	switch expr := expr.(type) {
+	case expression.Path:
+		return path.DependsOn(expr) || expr.DependsOn(path)
+	case *algebra.Subquery:
+		children = expr.Select().Expressions()
+	default:
+		children = expr.Children()
+	}
+
+	for _, child := range children {
+		if refersTo(child, path) {
+			return true
+		}
+	}
+
+	return false
+}
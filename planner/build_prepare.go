@@ -22,7 +22,10 @@ import (
 )
 
 func (this *builder) VisitPrepare(stmt *algebra.Prepare) (interface{}, error) {
-	pl, err := BuildPrepared(stmt.Statement(), this.datastore, this.systemstore, this.namespace, false)
+	// Prepared statements are restricted whoever prepares them,
+	// since anyone can execute them
+	pl, err := BuildPrepared(stmt.Statement(), this.datastore, this.systemstore, this.namespace,
+		false, true, false, this.features)
 	if err != nil {
 		return nil, err
 	}
@@ -12,12 +12,16 @@ package planner
 import (
 	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/plan"
 )
 
 func BuildPrepared(stmt algebra.Statement, datastore, systemstore datastore.Datastore,
-	namespace string, subquery bool) (*plan.Prepared, error) {
-	operator, err := Build(stmt, datastore, systemstore, namespace, subquery)
+	namespace string, subquery, restricted, readonly bool, features feature.Flags) (*plan.Prepared, error) {
+	builder := newBuilder(datastore, systemstore, namespace, subquery, restricted)
+	builder.features = features
+	builder.readonly = readonly
+	operator, err := build(stmt, builder, subquery)
 	if err != nil {
 		return nil, err
 	}
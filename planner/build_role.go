@@ -0,0 +1,76 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/plan"
+)
+
+func (this *builder) VisitGrantRole(stmt *algebra.GrantRole) (interface{}, error) {
+	roles, err := this.getRoles(stmt.Roles(), stmt.Keyspaces())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewGrantRole(roles, stmt.Users()), nil
+}
+
+func (this *builder) VisitRevokeRole(stmt *algebra.RevokeRole) (interface{}, error) {
+	roles, err := this.getRoles(stmt.Roles(), stmt.Keyspaces())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewRevokeRole(roles, stmt.Users()), nil
+}
+
+// Each role applies to each of the keyspaces, or to all keyspaces.
+func (this *builder) getRoles(names []string, ksrefs []*algebra.KeyspaceRef) ([]datastore.Role, error) {
+	if len(ksrefs) == 0 {
+		roles := make([]datastore.Role, len(names))
+		for i, name := range names {
+			roles[i] = datastore.Role{Name: name}
+		}
+		return roles, nil
+	}
+
+	keyspaces := make([]string, len(ksrefs))
+	for i, ksref := range ksrefs {
+		ksref.SetDefaultNamespace(this.defaultNamespace(ksref.Keyspace()))
+		if strings.ToLower(ksref.Namespace()) == "#system" {
+			return nil, fmt.Errorf("Roles not allowed on system namespace.")
+		}
+
+		keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+		if err != nil {
+			return nil, err
+		}
+
+		keyspaces[i] = keyspace.NamespaceId() + ":" + keyspace.Name()
+	}
+
+	roles := make([]datastore.Role, 0, len(names)*len(keyspaces))
+	for _, name := range names {
+		if name == datastore.ROLE_ADMIN {
+			return nil, fmt.Errorf("Role %s cannot be granted on keyspaces.", name)
+		}
+
+		for _, keyspace := range keyspaces {
+			roles = append(roles, datastore.Role{Name: name, Keyspace: keyspace})
+		}
+	}
+
+	return roles, nil
+}
@@ -16,6 +16,7 @@ import (
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/util"
@@ -56,10 +57,10 @@ func (this *builder) buildScan(keyspace datastore.Keyspace, node *algebra.Keyspa
 	var indexes, hintIndexes, otherIndexes []datastore.Index
 	hints := node.Indexes()
 	if hints != nil {
-		indexes, err = allHints(keyspace, hints)
+		indexes, err = this.allHints(keyspace, hints)
 		hintIndexes = indexes
 	} else {
-		indexes, err = allIndexes(keyspace)
+		indexes, err = this.allIndexes(keyspace)
 		otherIndexes = indexes
 	}
 
@@ -94,20 +95,36 @@ func (this *builder) buildScan(keyspace datastore.Keyspace, node *algebra.Keyspa
 			return nil, nil, er
 		}
 
+		if this.advice != nil && datastore.KeyspaceCapabilities(keyspace).Has(datastore.CAPABILITY_SECONDARY_INDEX) {
+			err = this.adviseIndexes(keyspace, node, pred, minimals)
+			if err != nil {
+				return
+			}
+		}
+
 		if len(minimals) > 0 {
 			secondary, err = this.buildSecondaryScan(minimals, node, limit)
 			return secondary, nil, err
 		}
 	}
 
+	if pred != nil {
+		this.hasCapability(keyspace, datastore.CAPABILITY_SECONDARY_INDEX, "the WHERE clause filters a primary scan")
+	}
+
 	primary, err = this.buildPrimaryScan(keyspace, node, limit, hintIndexes, otherIndexes)
 	return nil, primary, err
 }
 
-func allHints(keyspace datastore.Keyspace, hints algebra.IndexRefs) ([]datastore.Index, error) {
+func (this *builder) allHints(keyspace datastore.Keyspace, hints algebra.IndexRefs) ([]datastore.Index, error) {
 	indexes := make([]datastore.Index, 0, len(hints))
 
 	for _, hint := range hints {
+		if index := this.virtualIndex(keyspace, hint.Name()); index != nil {
+			indexes = append(indexes, index)
+			continue
+		}
+
 		indexer, err := keyspace.Indexer(hint.Using())
 		if err != nil {
 			return nil, err
@@ -133,7 +150,7 @@ func allHints(keyspace datastore.Keyspace, hints algebra.IndexRefs) ([]datastore
 	return indexes, nil
 }
 
-func allIndexes(keyspace datastore.Keyspace) ([]datastore.Index, error) {
+func (this *builder) allIndexes(keyspace datastore.Keyspace) ([]datastore.Index, error) {
 	indexers, err := keyspace.Indexers()
 	if err != nil {
 		return nil, err
@@ -161,9 +178,27 @@ func allIndexes(keyspace datastore.Keyspace) ([]datastore.Index, error) {
 		}
 	}
 
+	// Virtual indexes follow, so that existing primary indexes are preferred
+	for _, index := range this.virtualIndexes {
+		if index.KeyspaceId() == keyspace.Id() {
+			indexes = append(indexes, index)
+		}
+	}
+
 	return indexes, nil
 }
 
+// Returns the virtual index of the keyspace with the name, if any.
+func (this *builder) virtualIndex(keyspace datastore.Keyspace, name string) datastore.Index {
+	for _, index := range this.virtualIndexes {
+		if index.KeyspaceId() == keyspace.Id() && index.Name() == name {
+			return index
+		}
+	}
+
+	return nil
+}
+
 type indexEntry struct {
 	keys     expression.Expressions
 	sargKeys expression.Expressions
@@ -288,17 +323,23 @@ outer:
 
 func (this *builder) buildSecondaryScan(secondaries map[datastore.Index]*indexEntry,
 	node *algebra.KeyspaceTerm, limit expression.Expression) (plan.Operator, error) {
-	if this.cover != nil {
+	if this.cover != nil && this.features.Enabled(feature.COVERING_SCAN) {
 		scan, err := this.buildCoveringScan(secondaries, node, limit)
 		if scan != nil || err != nil {
 			return scan, err
 		}
 	}
 
+	if len(secondaries) > 1 && !this.features.Enabled(feature.INTERSECT_SCAN) {
+		secondaries = narrowestIndex(secondaries)
+	}
+
 	scans := make([]plan.Operator, 0, len(secondaries))
 	var op plan.Operator
 	for index, entry := range secondaries {
-		op = plan.NewIndexScan(index, node, entry.spans, false, limit, nil)
+		// An array index key has an entry per element
+		distinct := hasArrayKey(entry.keys)
+		op = plan.NewIndexScan(index, node, entry.spans, distinct, limit, nil)
 		if len(entry.spans) > 1 {
 			// Use UnionScan to de-dup multiple spans
 			op = plan.NewUnionScan(op)
@@ -314,6 +355,23 @@ func (this *builder) buildSecondaryScan(secondaries map[datastore.Index]*indexEn
 	}
 }
 
+/*
+Without intersect scans, only the index with the most sargable keys
+is scanned; ties go to the first index by name.
+*/
+func narrowestIndex(secondaries map[datastore.Index]*indexEntry) map[datastore.Index]*indexEntry {
+	var index datastore.Index
+	var entry *indexEntry
+	for i, e := range secondaries {
+		if index == nil || len(e.sargKeys) > len(entry.sargKeys) ||
+			(len(e.sargKeys) == len(entry.sargKeys) && i.Name() < index.Name()) {
+			index, entry = i, e
+		}
+	}
+
+	return map[datastore.Index]*indexEntry{index: entry}
+}
+
 func (this *builder) buildPrimaryScan(keyspace datastore.Keyspace, node *algebra.KeyspaceTerm,
 	limit expression.Expression, hintIndexes, otherIndexes []datastore.Index) (scan *plan.PrimaryScan, err error) {
 	primary, err := buildPrimaryIndex(keyspace, hintIndexes, otherIndexes)
@@ -321,7 +379,35 @@ func (this *builder) buildPrimaryScan(keyspace datastore.Keyspace, node *algebra
 		return nil, err
 	}
 
-	return plan.NewPrimaryScan(primary, keyspace, node, limit), nil
+	// The primary key of each entry covers META().id
+	var covers []*expression.Cover
+	if this.cover != nil && len(this.masks) == 0 && this.features.Enabled(feature.COVERING_SCAN) {
+		id := metaId(node)
+		if this.coveredBy(expression.Expressions{id}) {
+			covers = []*expression.Cover{expression.NewCover(id)}
+		}
+	}
+
+	if this.offset == nil {
+		scan = plan.NewPrimaryScan(primary, keyspace, node, nil, limit, covers)
+	} else if _, ok := primary.(datastore.OffsetPrimaryIndex); ok &&
+		this.features.Enabled(feature.OFFSET_PUSHDOWN) {
+		this.offsetPushed = true
+		scan = plan.NewPrimaryScan(primary, keyspace, node, this.offset, limit, covers)
+	} else {
+		// The skipped entries count towards the scan limit
+		if limit != nil {
+			limit = expression.NewAdd(this.offset, limit)
+		}
+
+		scan = plan.NewPrimaryScan(primary, keyspace, node, nil, limit, covers)
+	}
+
+	if covers != nil {
+		this.coveringScan = scan
+	}
+
+	return scan, nil
 }
 
 func buildPrimaryIndex(keyspace datastore.Keyspace, hintIndexes, otherIndexes []datastore.Index) (
@@ -393,22 +479,31 @@ func buildPrimaryIndex(keyspace datastore.Keyspace, hintIndexes, otherIndexes []
 
 func (this *builder) buildCoveringScan(secondaries map[datastore.Index]*indexEntry,
 	node *algebra.KeyspaceTerm, limit expression.Expression) (*plan.IndexScan, error) {
-	if this.cover == nil {
+	if this.cover == nil || len(this.masks) > 0 {
 		return nil, nil
 	}
 
-	exprs := this.cover.Expressions()
+	id := metaId(node)
 
-outer:
 	for index, entry := range secondaries {
-		for _, expr := range exprs {
-			if !expr.CoveredBy(entry.keys) {
-				continue outer
-			}
+		// The entries of an array index key are elements, not the key
+		if hasArrayKey(entry.keys) {
+			continue
+		}
+
+		// Every entry also covers META().id with its primary key
+		keys := entry.keys
+		if index.IsPrimary() {
+			keys = nil
+		}
+
+		keys = append(keys[0:len(keys):len(keys)], id)
+		if !this.coveredBy(keys) {
+			continue
 		}
 
-		covered := make([]*expression.Cover, len(entry.keys))
-		for i, key := range entry.keys {
+		covered := make([]*expression.Cover, len(keys))
+		for i, key := range keys {
 			covered[i] = expression.NewCover(key)
 		}
 
@@ -419,3 +514,43 @@ outer:
 
 	return nil, nil
 }
+
+/*
+Returns true if the scan returns exactly the entries that satisfy the
+WHERE clause, which then need not be filtered again.
+*/
+func exactScan(scan plan.CoveringScan) bool {
+	index, ok := scan.(*plan.IndexScan)
+	return ok && exactSpans(index.Spans())
+}
+
+// Returns true if the keys cover the query and the policy, if any.
+func (this *builder) coveredBy(keys expression.Expressions) bool {
+	for _, expr := range this.cover.Expressions() {
+		if !expr.CoveredBy(keys) {
+			return false
+		}
+	}
+
+	return this.policy == nil || this.policy.CoveredBy(keys)
+}
+
+// A projection of * returns whole documents, which no index covers.
+func projectsStar(projection *algebra.Projection) bool {
+	if projection != nil {
+		for _, term := range projection.Terms() {
+			if term.Star() && term.Expression() == nil {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// Returns META(alias).id of the keyspace term.
+func metaId(node *algebra.KeyspaceTerm) expression.Expression {
+	return expression.NewField(
+		expression.NewMeta(expression.NewIdentifier(node.Alias())),
+		expression.NewFieldName("id", false))
+}
@@ -0,0 +1,146 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/parser/n1ql"
+)
+
+func TestPrimaryCovering(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stmt     string
+		covering bool
+	}{
+		{"SELECT META(b).id FROM b0 AS b WHERE META(b).id > $x", true},
+		{"SELECT META().id AS id, 1 AS one FROM b0", true},
+		{"SELECT COUNT(1) FROM b0 WHERE META().id LIKE \"1%\"", true},
+		{"SELECT t.id FROM (SELECT META(b).id FROM b0 AS b) AS t", true},
+		{"DELETE FROM b0 WHERE META().id > \"5\"", true},
+		{"SELECT * FROM b0 WHERE META().id > $x", false},
+		{"SELECT META(b).id, b.i FROM b0 AS b", false},
+		{"SELECT META(b).cas FROM b0 AS b", false},
+		{"SELECT META(b) FROM b0 AS b", false},
+		{"DELETE FROM b0 WHERE META().id > \"5\" RETURNING *", false},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, nil, "p0", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		covering := !strings.Contains(string(bytes), `"#operator":"Fetch"`)
+		if covering != test.covering {
+			t.Errorf("Expected covering %v for %s, got plan %s", test.covering, test.stmt, bytes)
+		}
+	}
+}
+
+func TestKeyRangeScan(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmts := []string{
+		"DELETE FROM b0 USE KEYS BETWEEN \"1\" AND \"3\"",
+		"UPDATE b0 USE PRIMARY KEYS BETWEEN \"1\" AND \"3\" SET x = 1",
+	}
+
+	for _, s := range stmts {
+		stmt, er := n1ql.ParseStatement(s)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, nil, "p0", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		span := `"spans":[{"Range":{"High":["\"3\""],"Inclusion":3,"Low":["\"1\""]}}]`
+		if !strings.Contains(string(bytes), span) {
+			t.Errorf("Expected key range span for %s, got plan %s", s, bytes)
+		}
+	}
+}
+
+func TestUseConsistency(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stmt string
+		scan string
+	}{
+		{"SELECT META().id FROM b0 USE CONSISTENCY AT_PLUS($v)",
+			`"scan_consistency":"at_plus","scan_vector":"$v"`},
+		{"SELECT META(b).id FROM b0 b USE INDEX (`#primary`) USE CONSISTENCY request_plus",
+			`"scan_consistency":"scan_plus"`},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, nil, "p0", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		if !strings.Contains(string(bytes), test.scan) {
+			t.Errorf("Expected %s for %s, got plan %s", test.scan, test.stmt, bytes)
+		}
+	}
+
+	for _, s := range []string{
+		"SELECT * FROM b0 USE CONSISTENCY AT_PLUS",
+		"SELECT * FROM b0 USE CONSISTENCY NOT_BOUNDED($v)",
+		"DELETE FROM b0 USE CONSISTENCY REQUEST_PLUS",
+	} {
+		if _, er := n1ql.ParseStatement(s); er == nil {
+			t.Errorf("Expected an error parsing %s", s)
+		}
+	}
+}
@@ -11,6 +11,7 @@ package planner
 
 import (
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/plan"
 )
 
@@ -22,11 +23,15 @@ func (this *builder) VisitSelect(stmt *algebra.Select) (interface{}, error) {
 	prevCover := this.cover
 	prevOrder := this.order
 	prevLimit := this.limit
+	prevOffset := this.offset
+	prevOffsetPushed := this.offsetPushed
 	prevProjection := this.delayProjection
 	defer func() {
 		this.cover = prevCover
 		this.order = prevOrder
 		this.limit = prevLimit
+		this.offset = prevOffset
+		this.offsetPushed = prevOffsetPushed
 		this.delayProjection = prevProjection
 	}()
 
@@ -41,29 +46,55 @@ func (this *builder) VisitSelect(stmt *algebra.Select) (interface{}, error) {
 		this.delayProjection = true
 	}
 
-	if order != nil || offset != nil {
+	this.offset = nil
+	this.offsetPushed = false
+	if order != nil {
 		this.limit = nil
+	} else if offset != nil {
+		// The scan may apply OFFSET, and then LIMIT after it
+		this.offset = offset
+		this.limit = limit
 	} else if limit != nil {
 		this.limit = limit
 	}
 
-	sub, err := stmt.Subresult().Accept(this)
+	if !this.features.Enabled(feature.LIMIT_PUSHDOWN) {
+		this.limit = nil
+	}
+
+	subresult := stmt.Subresult()
+	rewrite, err := this.viewRewrite(stmt)
 	if err != nil {
 		return nil, err
 	}
 
-	if order == nil && offset == nil && limit == nil {
+	if rewrite != nil {
+		subresult = rewrite
+	}
+
+	sub, err := subresult.Accept(this)
+	if err != nil {
+		return nil, err
+	}
+
+	outfile := stmt.Outfile()
+	if order == nil && offset == nil && limit == nil && outfile == nil {
 		return sub, nil
 	}
 
-	children := make([]plan.Operator, 0, 5)
+	children := make([]plan.Operator, 0, 6)
 	children = append(children, sub.(plan.Operator))
 
 	if order != nil {
-		children = append(children, plan.NewOrder(order))
+		// With a LIMIT, the sort only keeps the first OFFSET + LIMIT items
+		if limit != nil {
+			children = append(children, plan.NewOrder(order, offset, limit))
+		} else {
+			children = append(children, plan.NewOrder(order, nil, nil))
+		}
 	}
 
-	if offset != nil {
+	if offset != nil && !this.offsetPushed {
 		children = append(children, plan.NewOffset(offset))
 	}
 
@@ -76,5 +107,32 @@ func (this *builder) VisitSelect(stmt *algebra.Select) (interface{}, error) {
 		children = append(children, plan.NewFinalProject())
 	}
 
+	if outfile != nil {
+		children = append(children, plan.NewOutfile(outfile.Path(), outfile.Format(),
+			projectionColumns(stmt)))
+	}
+
 	return plan.NewSequence(children...), nil
 }
+
+/*
+The aliases of the projection of a SELECT, or nil if its results are
+not objects of known fields.
+*/
+func projectionColumns(stmt *algebra.Select) []string {
+	sub, ok := stmt.Subresult().(*algebra.Subselect)
+	if !ok || sub.Projection().Raw() {
+		return nil
+	}
+
+	terms := sub.Projection().Terms()
+	columns := make([]string, 0, len(terms))
+	for _, term := range terms {
+		if term.Star() {
+			return nil
+		}
+		columns = append(columns, term.Alias())
+	}
+
+	return columns
+}
@@ -14,27 +14,56 @@ import (
 	"sort"
 
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/plan"
 )
 
 func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error) {
+	node, err := this.expandView(node)
+	if err != nil {
+		return nil, err
+	}
+
 	aggs, err := allAggregates(node, this.order)
 	if err != nil {
 		return nil, err
 	}
 
-	this.where = node.Where()
+	// Row-level security policy of the primary keyspace
+	policy, err := this.fromPolicy(node.From())
+	if err != nil {
+		return nil, err
+	}
+
+	// Masking policies of all the keyspaces
+	masks, err := this.selectMasks(node, aggs)
+	if err != nil {
+		return nil, err
+	}
+
+	// NOT IN and NOT EXISTS subqueries evaluated as anti-joins
+	where, antiJoins := this.antiJoins(node.Where())
+
+	this.policy = policy
+	this.masks = masks
+	this.where = andPolicy(where, policy)
 
 	group := node.Group()
 	if group == nil && len(aggs) > 0 {
-		group = algebra.NewGroup(nil, nil, nil)
+		group = algebra.NewGroup(nil, nil, nil, nil)
 		this.where = constrainAggregate(this.where, aggs)
 	}
 
 	this.children = make([]plan.Operator, 0, 16)    // top-level children, executed sequentially
 	this.subChildren = make([]plan.Operator, 0, 16) // sub-children, executed across data-parallel streams
+	this.coveringScan = nil                         // Not that of a preceding set operand
+
+	if projectsStar(node.Projection()) {
+		this.cover = nil
+	}
 
 	count, err := this.fastCount(node)
 	if err != nil {
@@ -44,8 +73,13 @@ func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error
 	if count {
 		this.maxParallelism = 1
 	} else if node.From() != nil {
-		if this.where != nil || group != nil {
+		if this.where != nil || group != nil || len(antiJoins) > 0 {
 			this.limit = nil
+			this.offset = nil
+		}
+
+		if node.Projection().Distinct() || this.distinct {
+			this.offset = nil
 		}
 
 		_, err := node.From().Accept(this)
@@ -72,22 +106,45 @@ func (this *builder) VisitSubselect(node *algebra.Subselect) (interface{}, error
 				return nil, err
 			}
 		}
+
+		if policy != nil {
+			policy, err = coverer.Map(policy)
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		// Split the covered WHERE clause again
+		where, antiJoins = this.antiJoins(node.Where())
+
+		// The spans of the scan filter the documents exactly
+		if exactScan(this.coveringScan) {
+			where = nil
+		}
 	}
 
 	if node.Let() != nil {
 		this.subChildren = append(this.subChildren, plan.NewLet(node.Let()))
 	}
 
-	if node.Where() != nil {
-		this.subChildren = append(this.subChildren, plan.NewFilter(node.Where()))
+	if where := andPolicy(where, policy); where != nil {
+		this.subChildren = append(this.subChildren, plan.NewFilter(where))
 	}
 
+	this.buildAntiJoins(antiJoins)
+
 	if group != nil {
 		this.visitGroup(group, aggs)
 	}
 
 	projection := node.Projection()
-	this.subChildren = append(this.subChildren, plan.NewInitialProject(projection))
+	initialProject := plan.NewInitialProject(projection, masks)
+	if projection.Raw() && !this.delayProjection {
+		// Nothing after the projection refers to the item
+		initialProject.SetDirect()
+	}
+
+	this.subChildren = append(this.subChildren, initialProject)
 
 	// Initial DISTINCT (parallel)
 	if projection.Distinct() || this.distinct {
@@ -123,10 +180,10 @@ func (this *builder) visitGroup(group *algebra.Group, aggs map[string]algebra.Ag
 		aggv[i] = aggs[n]
 	}
 
-	this.subChildren = append(this.subChildren, plan.NewInitialGroup(group.By(), aggv))
+	this.subChildren = append(this.subChildren, plan.NewInitialGroup(group.By(), aggv, group.Limit()))
 	this.children = append(this.children, plan.NewParallel(plan.NewSequence(this.subChildren...), this.maxParallelism))
-	this.children = append(this.children, plan.NewIntermediateGroup(group.By(), aggv))
-	this.children = append(this.children, plan.NewFinalGroup(group.By(), aggv))
+	this.children = append(this.children, plan.NewIntermediateGroup(group.By(), aggv, group.Limit()))
+	this.children = append(this.children, plan.NewFinalGroup(group.By(), aggv, group.Limit()))
 	this.subChildren = make([]plan.Operator, 0, 8)
 
 	letting := group.Letting()
@@ -141,7 +198,7 @@ func (this *builder) visitGroup(group *algebra.Group, aggs map[string]algebra.Ag
 }
 
 func (this *builder) VisitKeyspaceTerm(node *algebra.KeyspaceTerm) (interface{}, error) {
-	node.SetDefaultNamespace(this.namespace)
+	node.SetDefaultNamespace(this.defaultNamespace(node.Keyspace()))
 	keyspace, err := this.getTermKeyspace(node)
 	if err != nil {
 		return nil, err
@@ -167,6 +224,14 @@ func (this *builder) VisitKeyspaceTerm(node *algebra.KeyspaceTerm) (interface{},
 }
 
 func (this *builder) VisitSubqueryTerm(node *algebra.SubqueryTerm) (interface{}, error) {
+	if key, ok := this.viewTerms[node]; ok {
+		if this.expanding == nil {
+			this.expanding = make(map[string]bool)
+		}
+		this.expanding[key] = true
+		defer delete(this.expanding, key)
+	}
+
 	sel, err := node.Subquery().Accept(this)
 	if err != nil {
 		return nil, err
@@ -174,12 +239,14 @@ func (this *builder) VisitSubqueryTerm(node *algebra.SubqueryTerm) (interface{},
 
 	this.children = make([]plan.Operator, 0, 16)    // top-level children, executed sequentially
 	this.subChildren = make([]plan.Operator, 0, 16) // sub-children, executed across data-parallel streams
+	this.coveringScan = nil                         // That of the subquery, not of this term
 	this.children = append(this.children, sel.(plan.Operator), plan.NewAlias(node.Alias()))
 	return nil, nil
 }
 
 func (this *builder) VisitJoin(node *algebra.Join) (interface{}, error) {
 	this.limit = nil
+	this.offset = nil
 
 	_, err := node.Left().Accept(this)
 	if err != nil {
@@ -187,47 +254,52 @@ func (this *builder) VisitJoin(node *algebra.Join) (interface{}, error) {
 	}
 
 	right := node.Right()
-	right.SetDefaultNamespace(this.namespace)
+	right.SetDefaultNamespace(this.defaultNamespace(right.Keyspace()))
 	namespace, err := this.datastore.NamespaceByName(right.Namespace())
 	if err != nil {
 		return nil, err
 	}
 
+	resolveKeyspacePath(right, namespace)
 	keyspace, err := namespace.KeyspaceByName(right.Keyspace())
 	if err != nil {
 		return nil, err
 	}
 
-	join := plan.NewJoin(keyspace, node)
+	join := plan.NewJoin(keyspace, node, this.keyspacePolicy(keyspace))
 	this.subChildren = append(this.subChildren, join)
 	return nil, nil
 }
 
 func (this *builder) VisitNest(node *algebra.Nest) (interface{}, error) {
+	this.offset = nil
+
 	_, err := node.Left().Accept(this)
 	if err != nil {
 		return nil, err
 	}
 
 	right := node.Right()
-	right.SetDefaultNamespace(this.namespace)
+	right.SetDefaultNamespace(this.defaultNamespace(right.Keyspace()))
 	namespace, err := this.datastore.NamespaceByName(right.Namespace())
 	if err != nil {
 		return nil, err
 	}
 
+	resolveKeyspacePath(right, namespace)
 	keyspace, err := namespace.KeyspaceByName(right.Keyspace())
 	if err != nil {
 		return nil, err
 	}
 
-	nest := plan.NewNest(keyspace, node)
+	nest := plan.NewNest(keyspace, node, this.keyspacePolicy(keyspace))
 	this.subChildren = append(this.subChildren, nest)
 	return nil, nil
 }
 
 func (this *builder) VisitUnnest(node *algebra.Unnest) (interface{}, error) {
 	this.limit = nil
+	this.offset = nil
 
 	_, err := node.Left().Accept(this)
 	if err != nil {
@@ -318,7 +390,8 @@ func collectAggregates(aggs map[string]algebra.Aggregate, exprs ...expression.Ex
 }
 
 func (this *builder) fastCount(node *algebra.Subselect) (bool, error) {
-	if node.From() == nil ||
+	if !this.features.Enabled(feature.COUNT_SCAN) ||
+		node.From() == nil ||
 		node.Where() != nil ||
 		node.Group() != nil {
 		return false, nil
@@ -329,12 +402,16 @@ func (this *builder) fastCount(node *algebra.Subselect) (bool, error) {
 		return false, nil
 	}
 
-	from.SetDefaultNamespace(this.namespace)
+	from.SetDefaultNamespace(this.defaultNamespace(from.Keyspace()))
 	keyspace, err := this.getTermKeyspace(from)
 	if err != nil {
 		return false, err
 	}
 
+	if this.keyspacePolicy(keyspace) != nil {
+		return false, nil
+	}
+
 	for _, term := range node.Projection().Terms() {
 		count, ok := term.Expression().(*algebra.Count)
 		if !ok || count.Operand() != nil {
@@ -342,6 +419,10 @@ func (this *builder) fastCount(node *algebra.Subselect) (bool, error) {
 		}
 	}
 
+	if !this.hasCapability(keyspace, datastore.CAPABILITY_COUNT, "COUNT(*) scans the keyspace") {
+		return false, nil
+	}
+
 	scan := plan.NewCountScan(keyspace, from)
 	this.children = append(this.children, scan)
 	return true, nil
@@ -0,0 +1,23 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/plan"
+)
+
+func (this *builder) VisitSessionSet(stmt *algebra.SessionSet) (interface{}, error) {
+	return plan.NewSessionSet(stmt.Name(), stmt.Value()), nil
+}
+
+func (this *builder) VisitSetVariable(stmt *algebra.SetVariable) (interface{}, error) {
+	return plan.NewSetVariable(stmt.Name(), stmt.Expression()), nil
+}
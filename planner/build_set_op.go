@@ -23,6 +23,7 @@ func (this *builder) VisitUnion(node *algebra.Union) (interface{}, error) {
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
@@ -43,6 +44,7 @@ func (this *builder) VisitUnionAll(node *algebra.UnionAll) (interface{}, error)
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
@@ -67,6 +69,7 @@ func (this *builder) VisitIntersect(node *algebra.Intersect) (interface{}, error
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
@@ -86,6 +89,7 @@ func (this *builder) VisitIntersectAll(node *algebra.IntersectAll) (interface{},
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
@@ -115,6 +119,7 @@ func (this *builder) VisitExcept(node *algebra.Except) (interface{}, error) {
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
@@ -134,6 +139,7 @@ func (this *builder) VisitExceptAll(node *algebra.ExceptAll) (interface{}, error
 	this.order = nil             // Disable aggregates from ORDER BY
 	this.delayProjection = false // Disable ORDER BY non-projected expressions
 	this.limit = nil
+	this.offset = nil
 
 	first, err := node.First().Accept(this)
 	if err != nil {
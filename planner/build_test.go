@@ -0,0 +1,335 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+)
+
+func TestKeyspacePath(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1,scopes=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		stmt      string
+		keyspaces []string
+	}{
+		{"SELECT * FROM p0:b0.s0.c0", []string{`"keyspace":"b0.s0.c0"`}},
+		{"SELECT * FROM b0", []string{`"keyspace":"b0"`}},
+		{"SELECT * FROM b0.s0.c9", []string{`"keyspace":"b0"`, `"projection":"(`}},
+		{"SELECT * FROM b0._default.c0 JOIN b0.s0.c0 d ON KEYS c0.k",
+			[]string{`"keyspace":"b0._default.c0"`, `"keyspace":"b0.s0.c0"`}},
+		{"DELETE FROM p0:b0.s0.c0", []string{`"keyspace":"b0.s0.c0"`}},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, nil, "p0", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		for _, keyspace := range test.keyspaces {
+			if !strings.Contains(string(bytes), keyspace) {
+				t.Errorf("Expected %s for %s, got plan %s", keyspace, test.stmt, bytes)
+			}
+		}
+	}
+}
+
+func TestFeatures(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	build := func(text string, features feature.Flags) string {
+		stmt, er := n1ql.ParseStatement(text)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := BuildFeatures(stmt, store, nil, "p0", false, false, features)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		return string(bytes)
+	}
+
+	text := "SELECT COUNT(*) FROM b0"
+	if p := build(text, nil); !strings.Contains(p, "CountScan") {
+		t.Errorf("Expected CountScan, got plan %s", p)
+	}
+
+	// The flags of a request override the server settings
+	if p := build(text, feature.Flags{feature.COUNT_SCAN: false}); strings.Contains(p, "CountScan") {
+		t.Errorf("Unexpected CountScan, got plan %s", p)
+	}
+
+	defer feature.Set(feature.COUNT_SCAN, feature.Enabled(feature.COUNT_SCAN))
+	feature.Set(feature.COUNT_SCAN, false)
+	if p := build(text, nil); strings.Contains(p, "CountScan") {
+		t.Errorf("Unexpected CountScan, got plan %s", p)
+	}
+
+	if p := build(text, feature.Flags{feature.COUNT_SCAN: true}); !strings.Contains(p, "CountScan") {
+		t.Errorf("Expected CountScan, got plan %s", p)
+	}
+
+	// EXPLAIN reports the flags the plan was built with
+	stmt, er := n1ql.ParseStatement("EXPLAIN " + text)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	builder := newBuilder(store, nil, "p0", false, false)
+	builder.features = feature.Flags{feature.ANTI_JOIN: false}
+	op, er := stmt.Accept(builder)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	features := op.(*plan.Explain).Features()
+	if features[feature.ANTI_JOIN] || features[feature.COUNT_SCAN] || !features[feature.COVERING_SCAN] {
+		t.Errorf("Unexpected features %v", features)
+	}
+
+	if _, er := feature.ParseFlags(map[string]interface{}{"hash_join": true}); er == nil {
+		t.Errorf("Expected error for unknown feature")
+	}
+}
+
+func TestTypeCheck(t *testing.T) {
+	tests := []struct {
+		stmt  string
+		valid bool
+	}{
+		{"SELECT a + 1 FROM b0 ORDER BY a", true},
+		{"SELECT \"a\" || b FROM b0 WHERE c LIKE \"x%\" LIMIT 5", true},
+		{"SELECT \"a\" + 1 FROM b0", false},
+		{"SELECT -[1] FROM b0", false},
+		{"SELECT a FROM b0 WHERE 5 LIKE \"x%\"", false},
+		{"SELECT a FROM b0 ORDER BY {\"a\": a}", false},
+		{"SELECT a FROM b0 LIMIT \"5\"", false},
+		{"SELECT a FROM b0 WHERE a IN (SELECT RAW 1 * true FROM b0 AS s USE KEYS \"k\")", false},
+		{"EXPLAIN DELETE FROM b0 WHERE a = 1 / \"2\"", false},
+	}
+
+	for _, test := range tests {
+		stmt, err := n1ql.ParseStatement(test.stmt)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		er := algebra.TypeCheck(stmt)
+		if test.valid && er != nil {
+			t.Errorf("Unexpected error for %s: %v", test.stmt, er)
+		} else if !test.valid && (er == nil || er.Code() != 4200) {
+			t.Errorf("Expected semantic error for %s, got %v", test.stmt, er)
+		}
+	}
+}
+
+func TestAdminMutations(t *testing.T) {
+	dir, er := ioutil.TempDir("", "admin")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := file.NewDatastore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	systemstore, err := system.NewDatastore(store, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, _ := store.RoleCatalog()
+	catalog.GrantRole("root", datastore.Role{Name: datastore.ROLE_ADMIN})
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_UPDATE})
+
+	stmt, er := n1ql.ParseStatement("DELETE FROM system:active_requests WHERE users[0] = \"alice\"")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	op, er := Build(stmt, systemstore, systemstore, "default", false, false)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	authorize, ok := op.(*plan.Sequence).Children()[0].(*plan.Authorize)
+	if !ok {
+		t.Fatalf("Expected authorization of %v", op)
+	}
+
+	if err := store.Authorize(authorize.Privileges(), datastore.Credentials{"bob": ""}); err == nil {
+		t.Errorf("Expected bob not to cancel the requests of alice")
+	}
+
+	if err := store.Authorize(authorize.Privileges(), datastore.Credentials{"root": ""}); err != nil {
+		t.Errorf("Expected root to cancel the requests of alice, got %v", err)
+	}
+}
+
+func TestOutfilePrivileges(t *testing.T) {
+	dir, er := ioutil.TempDir("", "outfile")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	er = os.MkdirAll(filepath.Join(dir, "default", "contacts"), 0755)
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	store, err := file.NewDatastore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, _ := store.RoleCatalog()
+	catalog.GrantRole("root", datastore.Role{Name: datastore.ROLE_ADMIN})
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_QUERY_SELECT})
+
+	tests := []struct {
+		stmt string
+		bob  bool
+	}{
+		{"SELECT name FROM contacts", true},
+		{"SELECT name FROM contacts INTO OUTFILE \"out.json\"", false},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := Build(stmt, store, store, "default", false, false)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		authorize, ok := op.(*plan.Sequence).Children()[0].(*plan.Authorize)
+		if !ok {
+			t.Fatalf("Expected authorization of %v", op)
+		}
+
+		err := store.Authorize(authorize.Privileges(), datastore.Credentials{"bob": ""})
+		if test.bob && err != nil {
+			t.Errorf("Expected bob to run %s, got %v", test.stmt, err)
+		} else if !test.bob && err == nil {
+			t.Errorf("Expected bob not to run %s", test.stmt)
+		}
+
+		if err := store.Authorize(authorize.Privileges(), datastore.Credentials{"root": ""}); err != nil {
+			t.Errorf("Expected root to run %s, got %v", test.stmt, err)
+		}
+	}
+}
+
+func TestMasks(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=1")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ssn, er := parser.Parse("ssn")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	path := ssn.(expression.Path)
+	if _, ok := policy.AddMask("p0", "b0", path, "full"); !ok {
+		t.Fatalf("Expected mask to be added")
+	}
+	defer policy.RemoveMask("p0", "b0", path)
+
+	tests := []struct {
+		stmt  string
+		valid bool
+	}{
+		{"SELECT name, ssn FROM b0 WHERE name = \"x\" ORDER BY name", true},
+		{"SELECT name FROM b0 WHERE ssn = \"123-45-6789\"", false},
+		{"SELECT name FROM b0 WHERE name = \"x\" OR ssn LIKE \"1%\"", false},
+		{"SELECT name FROM b0 ORDER BY ssn", false},
+		{"SELECT name FROM b0 GROUP BY name, ssn", false},
+		{"SELECT name FROM b0 GROUP BY name HAVING MIN(ssn) > \"1\"", false},
+		{"SELECT c.name FROM b0 JOIN b0 c ON KEYS b0.ssn", false},
+		{"DELETE FROM b0 WHERE ssn = \"123-45-6789\"", false},
+		{"UPDATE b0 SET name = \"x\" WHERE ssn < \"2\"", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS \"k\" WHERE b0.ssn = \"1\")", false},
+		{"SELECT name FROM b0 ORDER BY (SELECT RAW b0.ssn FROM b0 AS x USE KEYS \"k\")[0]", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS b0.ssn)", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS \"k\" LET y = b0 WHERE y.ssn = 1)", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS \"k\" UNNEST b0.ssn AS y)", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS \"k\" JOIN b0 AS y ON KEYS b0.ssn)", false},
+		{"SELECT name FROM b0 WHERE EXISTS (SELECT 1 FROM b0 AS x USE KEYS \"k\" GROUP BY b0.ssn)", false},
+		{"SELECT name FROM b0 LET s = (SELECT RAW 1 FROM b0 AS x USE KEYS \"k\" ORDER BY b0.ssn)", false},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		_, er = Build(stmt, store, nil, "p0", false, true)
+		if test.valid && er != nil {
+			t.Errorf("Unexpected error for %s: %v", test.stmt, er)
+		} else if !test.valid && (er == nil || !strings.Contains(er.Error(), "masked path")) {
+			t.Errorf("Expected masked path error for %s, got %v", test.stmt, er)
+		}
+
+		// Unrestricted requests see unmasked values
+		if _, er = Build(stmt, store, nil, "p0", false, false); er != nil {
+			t.Errorf("Unexpected error for unrestricted %s: %v", test.stmt, er)
+		}
+	}
+}
@@ -11,19 +11,43 @@ package planner
 
 import (
 	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
 	"github.com/couchbase/query/plan"
 )
 
 func (this *builder) VisitUpdate(stmt *algebra.Update) (interface{}, error) {
-	this.where = stmt.Where()
-
 	ksref := stmt.KeyspaceRef()
-	keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	keyspace, err := this.getMutationKeyspace(ksref)
 	if err != nil {
 		return nil, err
 	}
 
-	err = this.beginMutate(keyspace, ksref, stmt.Keys(), stmt.Indexes(), stmt.Limit())
+	this.mutated = keyspace
+
+	this.policy, err = this.termPolicy(keyspace, ksref.Alias())
+	if err != nil {
+		return nil, err
+	}
+
+	this.where = andPolicy(stmt.Where(), this.policy)
+
+	this.masks, err = this.termMasks(keyspace, ksref.Alias())
+	if err != nil {
+		return nil, err
+	}
+
+	err = checkMasks(this.masks, stmt.Where())
+	if err != nil {
+		return nil, err
+	}
+
+	// Kept as written, as covering the scan maps this.where
+	var where expression.Expression
+	if this.where != nil {
+		where = this.where.Copy()
+	}
+
+	err = this.beginMutate(keyspace, ksref, stmt.Keys(), stmt.KeyRange(), stmt.Indexes(), stmt.Limit())
 	if err != nil {
 		return nil, err
 	}
@@ -39,10 +63,10 @@ func (this *builder) VisitUpdate(stmt *algebra.Update) (interface{}, error) {
 		subChildren = append(subChildren, plan.NewUnset(stmt.Unset()))
 	}
 
-	subChildren = append(subChildren, plan.NewSendUpdate(keyspace, ksref.Alias(), stmt.Limit()))
+	subChildren = append(subChildren, plan.NewSendUpdate(keyspace, ksref.Alias(), stmt.Limit(), where))
 
 	if stmt.Returning() != nil {
-		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning()), plan.NewFinalProject())
+		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning(), this.masks), plan.NewFinalProject())
 	}
 
 	parallel := plan.NewParallel(plan.NewSequence(subChildren...), this.maxParallelism)
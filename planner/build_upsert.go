@@ -19,9 +19,9 @@ import (
 
 func (this *builder) VisitUpsert(stmt *algebra.Upsert) (interface{}, error) {
 	ksref := stmt.KeyspaceRef()
-	ksref.SetDefaultNamespace(this.namespace)
+	ksref.SetDefaultNamespace(this.defaultNamespace(ksref.Keyspace()))
 
-	keyspace, err := this.getNameKeyspace(ksref.Namespace(), ksref.Keyspace())
+	keyspace, err := this.getTargetKeyspace(ksref.Namespace(), ksref.Keyspace())
 	if err != nil {
 		return nil, err
 	}
@@ -43,10 +43,16 @@ func (this *builder) VisitUpsert(stmt *algebra.Upsert) (interface{}, error) {
 	}
 
 	subChildren := make([]plan.Operator, 0, 4)
-	subChildren = append(subChildren, plan.NewSendUpsert(keyspace, ksref.Alias(), stmt.Key(), stmt.Value()))
+	subChildren = append(subChildren, plan.NewSendUpsert(keyspace, ksref.Alias(),
+		stmt.Key(), stmt.Value(), this.keyspacePolicy(keyspace)))
 
 	if stmt.Returning() != nil {
-		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning()), plan.NewFinalProject())
+		masks, err := this.termMasks(keyspace, ksref.Alias())
+		if err != nil {
+			return nil, err
+		}
+
+		subChildren = append(subChildren, plan.NewInitialProject(stmt.Returning(), masks), plan.NewFinalProject())
 	} else {
 		subChildren = append(subChildren, plan.NewDiscard())
 	}
@@ -0,0 +1,269 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/view"
+)
+
+func (this *builder) VisitCreateView(stmt *algebra.CreateView) (interface{}, error) {
+	ns, err := this.getViewNamespace(stmt.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	query := stmt.Query()
+	if !stmt.Materialized() {
+		return this.createView(ns, stmt.Keyspace().Keyspace(), query)
+	}
+
+	key := this.viewKey(query)
+
+	var sourceNamespace, sourceKeyspace string
+	_, term, er := view.KeyPreserving(query)
+	keyPreserving := er == nil
+	if keyPreserving {
+		term.SetDefaultNamespace(this.defaultNamespace(term.Keyspace()))
+		sourceNamespace = term.Namespace()
+		sourceKeyspace = term.Keyspace()
+	}
+
+	return plan.NewCreateMaterializedView(ns, stmt.Keyspace().Keyspace(), query.String(), key,
+		keyPreserving, sourceNamespace, sourceKeyspace), nil
+}
+
+/*
+The keyspaces of the definition of a view that is not materialized
+are resolved when it is created, rather than in each query that reads
+it. The definition is planned, so that a view that cannot be read is
+not created.
+*/
+func (this *builder) createView(ns, name string, query *algebra.Select) (interface{}, error) {
+	this.resolveNamespaces(query.Subresult())
+	definition := query.String()
+
+	stmt, err := n1ql.ParseStatement(definition)
+	if err != nil {
+		return nil, err
+	}
+
+	builder := newBuilder(this.datastore, this.systemstore, ns, false, this.restricted)
+	builder.features = this.features
+	_, err = stmt.Accept(builder)
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewCreateView(ns, name, definition), nil
+}
+
+func (this *builder) resolveNamespaces(subresult algebra.Subresult) {
+	switch subresult := subresult.(type) {
+	case *algebra.Subselect:
+		if subresult.From() != nil {
+			this.resolveFromNamespaces(subresult.From())
+		}
+	case *algebra.SelectTerm:
+		this.resolveNamespaces(subresult.Select().Subresult())
+	case interface {
+		First() algebra.Subresult
+		Second() algebra.Subresult
+	}:
+		this.resolveNamespaces(subresult.First())
+		this.resolveNamespaces(subresult.Second())
+	}
+}
+
+func (this *builder) resolveFromNamespaces(from algebra.FromTerm) {
+	switch from := from.(type) {
+	case *algebra.KeyspaceTerm:
+		from.SetDefaultNamespace(this.defaultNamespace(from.Keyspace()))
+	case *algebra.SubqueryTerm:
+		this.resolveNamespaces(from.Subquery().Subresult())
+	case *algebra.Join:
+		this.resolveFromNamespaces(from.Left())
+		this.resolveFromNamespaces(from.Right())
+	case *algebra.Nest:
+		this.resolveFromNamespaces(from.Left())
+		this.resolveFromNamespaces(from.Right())
+	case *algebra.Unnest:
+		this.resolveFromNamespaces(from.Left())
+	}
+}
+
+func (this *builder) VisitDropView(stmt *algebra.DropView) (interface{}, error) {
+	ns, err := this.getViewNamespace(stmt.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewDropView(ns, stmt.Keyspace().Keyspace(), stmt.Materialized()), nil
+}
+
+func (this *builder) VisitRefreshView(stmt *algebra.RefreshView) (interface{}, error) {
+	ns, err := this.getViewNamespace(stmt.Keyspace())
+	if err != nil {
+		return nil, err
+	}
+
+	return plan.NewRefreshView(ns, stmt.Keyspace().Keyspace()), nil
+}
+
+func (this *builder) getViewNamespace(ksref *algebra.KeyspaceRef) (string, error) {
+	ns := ksref.Namespace()
+	if ns == "" {
+		ns = this.defaultNamespace(ksref.Keyspace())
+	}
+
+	if strings.ToLower(ns) == "#system" {
+		return "", fmt.Errorf("Materialized views not allowed on system namespace.")
+	}
+
+	if datastore.IsTemporary(ksref.Keyspace()) {
+		return "", fmt.Errorf("Views cannot be named as temporary keyspaces: %s", ksref.Keyspace())
+	}
+
+	return ns, nil
+}
+
+/*
+Returns the key of a SELECT, which matches the definitions of views
+that hold its results: the text of its subselect, with the namespace
+of its keyspace resolved. Returns "" if the results depend on ORDER
+BY, OFFSET or LIMIT, or are not those of a subselect.
+*/
+func (this *builder) viewKey(stmt *algebra.Select) string {
+	if stmt.Order() != nil || stmt.Offset() != nil || stmt.Limit() != nil {
+		return ""
+	}
+
+	sub, ok := stmt.Subresult().(*algebra.Subselect)
+	if !ok {
+		return ""
+	}
+
+	if term, ok := sub.From().(*algebra.KeyspaceTerm); ok {
+		term.SetDefaultNamespace(this.defaultNamespace(term.Keyspace()))
+	}
+
+	return sub.String()
+}
+
+/*
+With the view_rewrite feature, returns a subselect of the results of
+a materialized view whose definition matches the SELECT, or nil.
+Statements restricted by policies are not rewritten, as the view
+holds unrestricted results.
+*/
+func (this *builder) viewRewrite(stmt *algebra.Select) (algebra.Subresult, error) {
+	if !this.features.Enabled(feature.VIEW_REWRITE) || this.restricted ||
+		stmt.Outfile() != nil {
+		return nil, nil
+	}
+
+	key := this.viewKey(stmt)
+	if key == "" {
+		return nil, nil
+	}
+
+	v := view.Match(key)
+	if v == nil {
+		return nil, nil
+	}
+
+	term := algebra.NewKeyspaceTerm(v.Namespace(), v.Name(), nil, v.Name(), nil, nil)
+	sub := algebra.NewSubselect(term, nil, nil, nil,
+		algebra.NewRawProjection(false, expression.NewIdentifier(v.Name()), ""))
+	_, err := sub.Formalize(expression.NewFormalizer())
+	if err != nil {
+		return nil, err
+	}
+
+	return sub, nil
+}
+
+/*
+Returns the subselect with its primary term replaced by the
+definition of the view that it reads, as a subquery of the same
+alias, if the view is not materialized. Queries were formalized with
+the alias of the view, so that they refer to its results as they
+would to the documents of a keyspace.
+*/
+func (this *builder) expandView(node *algebra.Subselect) (*algebra.Subselect, error) {
+	if node.From() == nil {
+		return node, nil
+	}
+
+	term, ok := node.From().PrimaryTerm().(*algebra.KeyspaceTerm)
+	if !ok {
+		return node, nil
+	}
+
+	ns := term.Namespace()
+	if ns == "" {
+		ns = this.defaultNamespace(term.Keyspace())
+	}
+
+	v := view.Get(ns, term.Keyspace())
+	if v == nil || v.Materialized() {
+		return node, nil
+	}
+
+	key := view.Key(ns, term.Keyspace())
+	if term.Keys() != nil || term.Indexes() != nil || term.Projection() != nil {
+		return nil, fmt.Errorf("USE KEYS, USE INDEX and paths not allowed on view %s.", key)
+	}
+
+	if this.expanding[key] {
+		return nil, fmt.Errorf("View %s refers to itself.", key)
+	}
+
+	stmt, err := n1ql.ParseStatement(v.Definition())
+	if err != nil {
+		return nil, err
+	}
+
+	query, ok := stmt.(*algebra.Select)
+	if !ok {
+		return nil, fmt.Errorf("Invalid definition of view %s.", key)
+	}
+
+	subquery := algebra.NewSubqueryTerm(query, term.Alias())
+	if this.viewTerms == nil {
+		this.viewTerms = make(map[*algebra.SubqueryTerm]string)
+	}
+	this.viewTerms[subquery] = key
+
+	return algebra.NewSubselect(replacePrimaryTerm(node.From(), subquery), node.Let(),
+		node.Where(), node.Group(), node.Projection()), nil
+}
+
+func replacePrimaryTerm(from, primary algebra.FromTerm) algebra.FromTerm {
+	switch from := from.(type) {
+	case *algebra.Join:
+		return algebra.NewJoin(replacePrimaryTerm(from.Left(), primary), from.Outer(), from.Right())
+	case *algebra.Nest:
+		return algebra.NewNest(replacePrimaryTerm(from.Left(), primary), from.Outer(), from.Right())
+	case *algebra.Unnest:
+		return algebra.NewUnnest(replacePrimaryTerm(from.Left(), primary), from.Outer(),
+			from.Expression(), from.As())
+	default:
+		return primary
+	}
+}
@@ -0,0 +1,80 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/view"
+)
+
+func TestViewRewrite(t *testing.T) {
+	store, err := mock.NewDatastore("mock:keyspaces=2")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stmt, er := n1ql.ParseStatement("CREATE MATERIALIZED VIEW b1 AS SELECT name FROM b0 WHERE age > 50")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	op, er := stmt.Accept(newBuilder(store, nil, "p0", false, false))
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	create := op.(*plan.CreateView)
+	if !create.KeyPreserving() || create.SourceKeyspace() != "b0" {
+		t.Errorf("Expected a key-preserving view of b0, got %v", create)
+	}
+
+	view.Add(view.New(create.Namespace(), create.Name(), create.Definition(), create.Key(), false, nil))
+	defer view.Remove(create.Namespace(), create.Name())
+
+	tests := []struct {
+		stmt     string
+		features feature.Flags
+		keyspace string
+	}{
+		{"SELECT name FROM b0 WHERE age > 50", feature.Flags{feature.VIEW_REWRITE: true}, "b1"},
+		{"SELECT name FROM p0:b0 WHERE age > 50", feature.Flags{feature.VIEW_REWRITE: true}, "b1"},
+		{"SELECT name FROM b0 WHERE age > 50", nil, "b0"},
+		{"SELECT name FROM b0 WHERE age > 40", feature.Flags{feature.VIEW_REWRITE: true}, "b0"},
+		{"SELECT name FROM b0 WHERE age > 50 ORDER BY name", feature.Flags{feature.VIEW_REWRITE: true}, "b0"},
+	}
+
+	for _, test := range tests {
+		stmt, er := n1ql.ParseStatement(test.stmt)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		op, er := BuildFeatures(stmt, store, nil, "p0", false, false, test.features)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		if !strings.Contains(string(bytes), `"keyspace":"`+test.keyspace+`"`) {
+			t.Errorf("Expected a scan of %s for %s, got plan %s", test.keyspace, test.stmt, bytes)
+		}
+	}
+}
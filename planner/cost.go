@@ -0,0 +1,215 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"math"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Cost is a rough estimate of the work of a plan: the index entries
+scanned, the documents fetched, and the items the plan produces. In
+the absence of statistics, it relies on the document counts of the
+keyspaces and the default selectivities of ADVISE; filters and joins
+are assumed to pass all their input. Subqueries in expressions are
+not estimated.
+*/
+type Cost struct {
+	Scanned int64 `json:"scanned"`
+	Fetched int64 `json:"fetched"`
+	Results int64 `json:"results"`
+}
+
+/*
+Estimate the cost of a plan, whose keyspaces are found in the
+datastore.
+*/
+func EstimateCost(op plan.Operator, store datastore.Datastore) *Cost {
+	estimator := &estimator{store: store}
+	rv := &Cost{}
+	rv.Results = estimator.estimate(op, rv, 1)
+	return rv
+}
+
+type estimator struct {
+	store datastore.Datastore
+}
+
+// Returns the number of items the operator produces from its input.
+func (this *estimator) estimate(op plan.Operator, cost *Cost, input int64) int64 {
+	switch op := op.(type) {
+	case *plan.Sequence:
+		for _, child := range op.Children() {
+			input = this.estimate(child, cost, input)
+		}
+		return input
+	case *plan.Parallel:
+		return this.estimate(op.Child(), cost, input)
+	case *plan.Authorize:
+		return this.estimate(op.Child(), cost, input)
+	case *plan.UnionAll:
+		var rv int64
+		for _, child := range op.Children() {
+			rv += this.estimate(child, cost, input)
+		}
+		return rv
+	case *plan.IntersectAll:
+		rv := this.estimate(op.First(), cost, input)
+		this.estimate(op.Second(), cost, input)
+		return rv
+	case *plan.ExceptAll:
+		rv := this.estimate(op.First(), cost, input)
+		this.estimate(op.Second(), cost, input)
+		return rv
+	case *plan.PrimaryScan:
+		rv := limited(count(op.Keyspace()), op.Limit())
+		cost.Scanned += rv
+		return rv
+	case *plan.IndexScan:
+		rv := int64(math.Ceil(float64(this.count(op.Term())) * spanSelectivity(op.Spans())))
+		rv = limited(rv, op.Limit())
+		cost.Scanned += rv
+		return rv
+	case *plan.IntersectScan:
+		rv := int64(-1)
+		for _, scan := range op.Scans() {
+			n := this.estimate(scan, cost, input)
+			if rv < 0 || n < rv {
+				rv = n
+			}
+		}
+		return rv
+	case *plan.UnionScan:
+		var rv int64
+		for _, scan := range op.Scans() {
+			rv += this.estimate(scan, cost, input)
+		}
+		return rv
+	case *plan.KeyScan:
+		if keys := op.Keys().Value(); keys != nil && keys.Type() == value.ARRAY {
+			return int64(len(keys.Actual().([]interface{})))
+		}
+		return 1
+	case *plan.CountScan:
+		return 1
+	case *plan.Fetch:
+		cost.Fetched += input
+		return input
+	case *plan.Join:
+		cost.Fetched += input
+		return input
+	case *plan.Nest:
+		cost.Fetched += input
+		return input
+	case *plan.Limit:
+		return limited(input, op.Expression())
+	default:
+		return input
+	}
+}
+
+// The document count of the keyspace of a term.
+func (this *estimator) count(term *algebra.KeyspaceTerm) int64 {
+	if this.store == nil {
+		return 0
+	}
+
+	namespace, err := this.store.NamespaceByName(term.Namespace())
+	if err != nil {
+		return 0
+	}
+
+	keyspace, err := namespace.KeyspaceByName(term.Keyspace())
+	if err != nil {
+		return 0
+	}
+
+	return count(keyspace)
+}
+
+func count(keyspace datastore.Keyspace) int64 {
+	n, err := keyspace.Count()
+	if err != nil {
+		return 0
+	}
+	return n
+}
+
+// Apply a constant limit.
+func limited(n int64, limit expression.Expression) int64 {
+	if limit == nil {
+		return n
+	}
+
+	if lv := limit.Value(); lv != nil && lv.Type() == value.NUMBER {
+		if l := int64(lv.Actual().(float64)); l < n {
+			return l
+		}
+	}
+
+	return n
+}
+
+/*
+The fraction of the index entries that the spans select. Each key
+with equal bounds is sarged by equality, and each key with a bound
+by a range. The ranges of the keys are used when the span has them.
+*/
+func spanSelectivity(spans plan.Spans) float64 {
+	rv := 0.0
+	for _, span := range spans {
+		if span.Ranges != nil {
+			rv += rangesSelectivity(span.Ranges)
+			continue
+		}
+
+		sel := 1.0
+		for i := 0; i < len(span.Range.Low) || i < len(span.Range.High); i++ {
+			var low, high expression.Expression
+			if i < len(span.Range.Low) {
+				low = span.Range.Low[i]
+			}
+			if i < len(span.Range.High) {
+				high = span.Range.High[i]
+			}
+
+			switch {
+			case low != nil && high != nil && low.EquivalentTo(high):
+				sel *= _EQ_SELECTIVITY
+			case low != nil || high != nil:
+				sel *= _RANGE_SELECTIVITY
+			}
+		}
+
+		rv += sel
+	}
+
+	return math.Min(rv, 1.0)
+}
+
+func rangesSelectivity(ranges plan.Ranges2) float64 {
+	sel := 1.0
+	for _, r := range ranges {
+		switch {
+		case r.Low != nil && r.High != nil && r.Low.EquivalentTo(r.High):
+			sel *= _EQ_SELECTIVITY
+		case r.Low != nil || r.High != nil:
+			sel *= _RANGE_SELECTIVITY
+		}
+	}
+
+	return sel
+}
@@ -0,0 +1,58 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/policy"
+)
+
+func TestPolicyFilter(t *testing.T) {
+	store, err := mock.NewDatastore("mock:")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	pred, er := parser.Parse("id < 10")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	policy.Add("p0", "b0", pred)
+	defer policy.Remove("p0", "b0")
+
+	stmt, er := n1ql.ParseStatement("SELECT * FROM b0 AS b WHERE b.name = \"x\"")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	for _, restricted := range []bool{false, true} {
+		op, er := Build(stmt, store, nil, "p0", false, restricted)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		bytes, er := json.Marshal(op)
+		if er != nil {
+			t.Fatal(er)
+		}
+
+		filtered := strings.Contains(string(bytes), "(`b`.`id`) \\u003c 10")
+		if filtered != restricted {
+			t.Errorf("Expected policy filter %v, got plan %s", restricted, bytes)
+		}
+	}
+}
@@ -15,11 +15,29 @@ import (
 	"github.com/couchbase/query/plan"
 )
 
+// Scratch space for the cross product of composite key spans; at
+// most 16 spans are crossed with each span of the next key.
+var _SPANS_POOL = plan.NewSpansPool(16)
+
+/*
+The spans are exact if the spans of each key are exact, the keys
+before the last are sarged by equality, so that crossing their spans
+bounds the entries exactly, and each conjunct of the predicate is
+sarged by one of the keys. Spans that bound fewer keys than the index
+has are compared with the entries as prefixes, so the sargs of the
+last key are only exact if their bounds hold for prefixes.
+*/
 func SargFor(pred expression.Expression, sargKeys expression.Expressions, total int) (plan.Spans, error) {
 	n := len(sargKeys)
 	s := newSarg(pred)
 	s.SetMissingHigh(n < total)
+	if and, ok := s.(*sargAnd); ok {
+		and.conjuncts = true
+	}
+
 	var ns plan.Spans
+	pooled := false // ns is owned by _SPANS_POOL
+	sarged := n     // The keys that the spans bound
 
 	// Sarg compositive indexes right to left
 keys:
@@ -31,7 +49,11 @@ keys:
 
 		rs := r.(plan.Spans)
 		if len(rs) == 0 {
-			ns = nil
+			if pooled {
+				_SPANS_POOL.Put(ns)
+			}
+			ns, pooled = nil, false
+			sarged = i
 			continue
 		}
 
@@ -53,13 +75,16 @@ keys:
 		}
 
 		// Cross product of prev and next spans
-		sp := make(plan.Spans, 0, len(rs)*len(ns))
+		sp := _SPANS_POOL.Get()
 
 		for _, prev := range rs {
 			// Full span subsumes others
 			if prev == _FULL_SPANS[0] {
 				sp = append(sp, prev)
-				ns = sp
+				if pooled {
+					_SPANS_POOL.Put(ns)
+				}
+				ns, pooled = sp, true
 				continue keys
 			}
 		}
@@ -67,68 +92,217 @@ keys:
 	prevs:
 		for _, prev := range rs {
 			if len(prev.Range.Low) == 0 && len(prev.Range.High) == 0 {
-				sp = append(sp, prev)
+				sp = append(sp, inexactSpan(prev))
 				continue
 			}
 
 			// Limit fan-out
 			if len(ns) > 16 {
-				sp = append(sp, prev)
+				sp = append(sp, inexactSpan(prev))
 				continue
 			}
 
 			for _, next := range ns {
 				// Full span subsumes others
 				if next == _FULL_SPANS[0] || (len(next.Range.Low) == 0 && len(next.Range.High) == 0) {
-					sp = append(sp, prev)
+					sp = append(sp, inexactSpan(prev))
 					continue prevs
 				}
 			}
 
-			pn := make(plan.Spans, 0, len(ns))
+			// Cross prev with every next span, or else keep prev alone
+			start := len(sp)
 			for _, next := range ns {
-				add := false
-				pre := prev.Copy()
-
-				if len(pre.Range.Low) > 0 && len(next.Range.Low) > 0 {
-					pre.Range.Low = append(pre.Range.Low, next.Range.Low...)
-					pre.Range.Inclusion = (datastore.LOW & pre.Range.Inclusion & next.Range.Inclusion) |
-						(datastore.HIGH & pre.Range.Inclusion)
-					add = true
-				}
-
-				if len(pre.Range.High) > 0 && len(next.Range.High) > 0 {
-					pre.Range.High = append(pre.Range.High, next.Range.High...)
-					pre.Range.Inclusion = (datastore.HIGH & pre.Range.Inclusion & next.Range.Inclusion) |
-						(datastore.LOW & pre.Range.Inclusion)
-					add = true
-				}
-
-				if add {
-					pn = append(pn, pre)
-				} else {
+				pre := crossSpan(prev, next)
+				if pre == nil {
 					break
 				}
+
+				sp = append(sp, pre)
 			}
 
-			if len(pn) == len(ns) {
-				sp = append(sp, pn...)
-			} else {
-				sp = append(sp, prev)
+			if len(sp)-start != len(ns) {
+				sp = append(sp[0:start], inexactSpan(prev))
 			}
 		}
 
-		ns = sp
+		if pooled {
+			_SPANS_POOL.Put(ns)
+		}
+		ns, pooled = sp, true
 	}
 
 	if len(ns) == 0 || len(ns) > 256 {
+		if pooled {
+			_SPANS_POOL.Put(ns)
+		}
 		return _FULL_SPANS, nil
 	}
 
+	if pooled {
+		rv := make(plan.Spans, len(ns))
+		copy(rv, ns)
+		_SPANS_POOL.Put(ns)
+		ns = rv
+	}
+
+	if exactSpans(ns) && !sargsConjuncts(pred, sargKeys[0:sarged]) {
+		rv := make(plan.Spans, len(ns))
+		for i, span := range ns {
+			rv[i] = inexactSpan(span)
+		}
+		ns = rv
+	}
+
 	return ns, nil
 }
 
+func exactSpans(spans plan.Spans) bool {
+	for _, span := range spans {
+		if !span.Exact {
+			return false
+		}
+	}
+
+	return len(spans) > 0
+}
+
+// Returns the span, or an inexact copy of it; spans may be shared.
+func inexactSpan(span *plan.Span) *plan.Span {
+	if !span.Exact {
+		return span
+	}
+
+	rv := span.Copy()
+	rv.Exact = false
+	return rv
+}
+
+// True if each conjunct of the predicate is sarged by one of the keys.
+func sargsConjuncts(pred expression.Expression, keys expression.Expressions) bool {
+	conjuncts := expression.Expressions{pred}
+	if and, ok := pred.(*expression.And); ok {
+		conjuncts = flattenAnd(and)
+	}
+
+conjuncts:
+	for _, conjunct := range conjuncts {
+		for _, key := range keys {
+			spans, err := sargFor(conjunct, key, false)
+			if err == nil && len(spans) > 0 {
+				continue conjuncts
+			}
+		}
+
+		return false
+	}
+
+	return true
+}
+
+// True if the span bounds a single key to a single value.
+func equalitySpan(span *plan.Span) bool {
+	if len(span.Range.Low) != 1 || len(span.Range.High) != 1 {
+		return false
+	}
+
+	return span.Range.Inclusion == datastore.BOTH && sameBound(span.Range.Low[0], span.Range.High[0])
+}
+
+// NULL is not equivalent to itself, but bounds a span all the same.
+func sameBound(bound1, bound2 expression.Expression) bool {
+	value1, value2 := bound1.Value(), bound2.Value()
+	if value1 != nil && value2 != nil {
+		return value1.Collate(value2) == 0
+	}
+
+	return bound1.EquivalentTo(bound2)
+}
+
+/*
+Returns a copy of prev with the bounds of next appended, or nil if
+neither bound can be extended. Each bound is allocated once, at its
+final length.
+*/
+func crossSpan(prev, next *plan.Span) *plan.Span {
+	low := len(prev.Range.Low) > 0 && len(next.Range.Low) > 0
+	high := len(prev.Range.High) > 0 && len(next.Range.High) > 0
+	if !low && !high {
+		return nil
+	}
+
+	rv := &plan.Span{
+		Seek:  expression.CopyExpressions(prev.Seek),
+		Exact: prev.Exact && next.Exact && equalitySpan(prev),
+	}
+
+	rv.Range.Inclusion = prev.Range.Inclusion
+	if low {
+		rv.Range.Low = appendBounds(prev.Range.Low, next.Range.Low)
+		rv.Range.Inclusion = (datastore.LOW & rv.Range.Inclusion & next.Range.Inclusion) |
+			(datastore.HIGH & rv.Range.Inclusion)
+	} else {
+		rv.Range.Low = expression.CopyExpressions(prev.Range.Low)
+	}
+
+	if high {
+		rv.Range.High = appendBounds(prev.Range.High, next.Range.High)
+		rv.Range.Inclusion = (datastore.HIGH & rv.Range.Inclusion & next.Range.Inclusion) |
+			(datastore.LOW & rv.Range.Inclusion)
+	} else {
+		rv.Range.High = expression.CopyExpressions(prev.Range.High)
+	}
+
+	// The composite range loses the bounds and inclusion of the keys
+	// after the first whose bounds differ; the range of each key keeps them
+	prevRanges, nextRanges := keyRanges(prev), keyRanges(next)
+	if prevRanges != nil && nextRanges != nil {
+		rv.Ranges = make(plan.Ranges2, 0, len(prevRanges)+len(nextRanges))
+		rv.Ranges = append(append(rv.Ranges, prevRanges...), nextRanges...)
+	}
+
+	return rv
+}
+
+// The range of each key of the span, or nil if they are not known.
+func keyRanges(span *plan.Span) plan.Ranges2 {
+	if span.Ranges != nil {
+		return span.Ranges
+	}
+
+	if len(span.Range.Low) > 1 || len(span.Range.High) > 1 {
+		return nil
+	}
+
+	rv := &plan.Range2{
+		Inclusion: span.Range.Inclusion,
+	}
+
+	if len(span.Range.Low) > 0 {
+		rv.Low = span.Range.Low[0].Copy()
+	}
+
+	if len(span.Range.High) > 0 {
+		rv.High = span.Range.High[0].Copy()
+	}
+
+	return plan.Ranges2{rv}
+}
+
+func appendBounds(prev, next expression.Expressions) expression.Expressions {
+	rv := make(expression.Expressions, len(prev), len(prev)+len(next))
+	for i, expr := range prev {
+		rv[i] = expr.Copy()
+	}
+
+	return append(rv, next...)
+}
+
 func sargFor(pred, expr expression.Expression, missingHigh bool) (plan.Spans, error) {
+	if !arrayKeySargable(pred, expr) {
+		return nil, nil
+	}
+
 	s := newSarg(pred)
 	s.SetMissingHigh(missingHigh)
 
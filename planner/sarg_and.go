@@ -17,8 +17,15 @@ import (
 
 type sargAnd struct {
 	sargBase
+	conjuncts bool // Operands not sarged by the key are sarged by other keys
 }
 
+/*
+The spans are exact if each operand is sarged exactly and the bounds
+of the operands are combined exactly. An operand that the key does
+not sarg makes them inexact, unless the AND is the predicate of a
+composite index scan, whose other keys sarg its operands.
+*/
 func newSargAnd(pred *expression.And) *sargAnd {
 	rv := &sargAnd{}
 	rv.sarger = func(expr2 expression.Expression) (spans plan.Spans, err error) {
@@ -26,21 +33,31 @@ func newSargAnd(pred *expression.And) *sargAnd {
 			return _SELF_SPANS, nil
 		}
 
+		exact := true
 		var s plan.Spans
-		for _, op := range pred.Operands() {
+		for _, op := range flattenAnd(pred) {
 			s, err = sargFor(op, expr2, rv.MissingHigh())
 			if err != nil {
 				return nil, err
 			}
 
 			if len(s) == 0 {
+				exact = exact && rv.conjuncts
 				continue
 			}
 
 			if len(spans) == 0 {
 				spans = s.Copy()
 			} else {
-				spans = constrainSpans(spans, s)
+				var e bool
+				spans, e = constrainSpans(spans, s)
+				exact = exact && e
+			}
+		}
+
+		if !exact {
+			for _, span := range spans {
+				span.Exact = false
 			}
 		}
 
@@ -50,24 +67,46 @@ func newSargAnd(pred *expression.And) *sargAnd {
 	return rv
 }
 
-func constrainSpans(spans1, spans2 plan.Spans) plan.Spans {
+// The parser nests chained ANDs.
+func flattenAnd(pred *expression.And) expression.Expressions {
+	var rv expression.Expressions
+	for _, op := range pred.Operands() {
+		if and, ok := op.(*expression.And); ok {
+			rv = append(rv, flattenAnd(and)...)
+		} else {
+			rv = append(rv, op)
+		}
+	}
+
+	return rv
+}
+
+// Returns false if the spans are not constrained exactly.
+func constrainSpans(spans1, spans2 plan.Spans) (plan.Spans, bool) {
 	if len(spans2) != 1 {
 		if len(spans1) == 1 {
 			spans1, spans2 = spans2.Copy(), spans1
 		} else {
-			return spans1
+			return spans1, false
 		}
 	}
 
+	exact := true
 	span2 := spans2[0]
 	for _, span1 := range spans1 {
-		constrainSpan(span1, span2)
+		exact = constrainSpan(span1, span2) && exact
 	}
 
-	return spans1
+	return spans1, exact
 }
 
-func constrainSpan(span1, span2 *plan.Span) {
+/*
+Bounds that are not constant cannot be compared when the plan is
+built; the bound of span2 is then used, and the span is not exact.
+*/
+func constrainSpan(span1, span2 *plan.Span) bool {
+	exact := span1.Exact && span2.Exact
+
 	if len(span2.Range.Low) > 0 {
 		if len(span1.Range.Low) == 0 {
 			span1.Range.Low = span2.Range.Low
@@ -76,10 +115,16 @@ func constrainSpan(span1, span2 *plan.Span) {
 		} else {
 			low1 := span1.Range.Low[0].Value()
 			low2 := span2.Range.Low[0].Value()
+			if low1 == nil || low2 == nil {
+				exact = false
+			}
+
 			if low1 != nil && (low2 == nil || low1.Collate(low2) < 0) {
 				span1.Range.Low = span2.Range.Low
 				span1.Range.Inclusion = (span1.Range.Inclusion & datastore.HIGH) |
 					(span2.Range.Inclusion & datastore.LOW)
+			} else if low1 != nil && low1.Collate(low2) == 0 {
+				span1.Range.Inclusion &= span2.Range.Inclusion | datastore.HIGH
 			}
 		}
 	}
@@ -92,11 +137,20 @@ func constrainSpan(span1, span2 *plan.Span) {
 		} else {
 			high1 := span1.Range.High[0].Value()
 			high2 := span2.Range.High[0].Value()
-			if high1 != nil && (high2 == nil && high1.Collate(high2) > 0) {
+			if high1 == nil || high2 == nil {
+				exact = false
+			}
+
+			if high1 != nil && (high2 == nil || high1.Collate(high2) > 0) {
 				span1.Range.High = span2.Range.High
 				span1.Range.Inclusion = (span1.Range.Inclusion & datastore.LOW) |
 					(span2.Range.Inclusion & datastore.HIGH)
+			} else if high1 != nil && high1.Collate(high2) == 0 {
+				span1.Range.Inclusion &= span2.Range.Inclusion | datastore.LOW
 			}
 		}
 	}
+
+	span1.Exact = exact
+	return exact
 }
@@ -0,0 +1,35 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/plan"
+)
+
+type sargAny struct {
+	sargDefault
+}
+
+func newSargAny(pred *expression.Any) *sargAny {
+	rv := &sargAny{*newSargDefault(pred)}
+	sarger := rv.sarger
+	rv.sarger = func(expr2 expression.Expression) (plan.Spans, error) {
+		// Spans over the elements of an array index key
+		mapping := anyMapping(pred, expr2)
+		if mapping == nil {
+			return sarger(expr2)
+		}
+
+		return sargFor(pred.Satisfies(), mapping, rv.MissingHigh())
+	}
+
+	return rv
+}
@@ -40,12 +40,14 @@ func newSargEq(pred *expression.Eq) *sargEq {
 			return nil, nil
 		}
 
+		// Successors are approximate, and only exclude the keys above them
 		if rv.MissingHigh() {
 			span.Range.High = expression.Expressions{expression.NewSuccessor(span.Range.Low[0])}
 			span.Range.Inclusion = datastore.LOW
 		} else {
 			span.Range.High = span.Range.Low
 			span.Range.Inclusion = datastore.BOTH
+			span.Exact = true
 		}
 
 		return plan.Spans{span}, nil
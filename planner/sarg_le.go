@@ -53,6 +53,16 @@ func newSargLE(pred *expression.LE) *sargLE {
 			return nil, nil
 		}
 
+		// NULL and MISSING are less than any value, but not by comparison.
+		// Successors are approximate, and a low bound of fewer keys than
+		// the index excludes no entries.
+		if len(span.Range.Low) == 0 {
+			span.Range.Low = expression.Expressions{expression.NULL_EXPR}
+			span.Exact = !rv.MissingHigh()
+		} else {
+			span.Exact = true
+		}
+
 		return plan.Spans{span}, nil
 	}
 
@@ -43,7 +43,14 @@ func newSargLT(pred *expression.LT) *sargLT {
 			return nil, nil
 		}
 
+		// NULL and MISSING are less than any value, but not by comparison
+		if len(span.Range.Low) == 0 {
+			span.Range.Low = expression.Expressions{expression.NULL_EXPR}
+		}
+
+		// A low bound of fewer keys than the index excludes no entries
 		span.Range.Inclusion = datastore.NEITHER
+		span.Exact = !rv.MissingHigh()
 		return plan.Spans{span}, nil
 	}
 
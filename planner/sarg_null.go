@@ -17,12 +17,22 @@ import (
 
 var _NULL_SPANS plan.Spans
 
+// NULL followed by any keys
+var _NULL_PREFIX_SPANS plan.Spans
+
 func init() {
 	span := &plan.Span{}
 	span.Range.Low = expression.Expressions{expression.NULL_EXPR}
 	span.Range.High = span.Range.Low
 	span.Range.Inclusion = datastore.BOTH
+	span.Exact = true
 	_NULL_SPANS = plan.Spans{span}
+
+	span = &plan.Span{}
+	span.Range.Low = expression.Expressions{expression.NULL_EXPR}
+	span.Range.High = expression.Expressions{expression.NewSuccessor(expression.NULL_EXPR)}
+	span.Range.Inclusion = datastore.LOW
+	_NULL_PREFIX_SPANS = plan.Spans{span}
 }
 
 type sargNull struct {
@@ -37,6 +47,10 @@ func newSargNull(pred *expression.IsNull) *sargNull {
 		}
 
 		if pred.Operand().EquivalentTo(expr2) {
+			if rv.MissingHigh() {
+				return _NULL_PREFIX_SPANS, nil
+			}
+
 			return _NULL_SPANS, nil
 		}
 
@@ -0,0 +1,228 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"encoding/json"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/plan"
+)
+
+func sargInputs(tb testing.TB) (expression.Expression, expression.Expressions) {
+	pred, err := parser.Parse("(a = 1 OR a = 2 OR a = 3 OR a = 4) AND (b = 5 OR b = 6 OR b = 7) AND c = 10")
+	if err != nil {
+		tb.Fatal(err)
+	}
+
+	keys := make(expression.Expressions, 3)
+	for i, k := range []string{"a", "b", "c"} {
+		keys[i], err = parser.Parse(k)
+		if err != nil {
+			tb.Fatal(err)
+		}
+	}
+
+	return pred, keys
+}
+
+func TestSargForCrossProduct(t *testing.T) {
+	pred, keys := sargInputs(t)
+
+	spans, err := SargFor(pred, keys, len(keys))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(spans) != 12 {
+		b, _ := json.Marshal(spans)
+		t.Fatalf("Expected 12 spans, got %d: %s", len(spans), b)
+	}
+
+	for _, span := range spans {
+		if len(span.Range.Low) != 3 || len(span.Range.High) != 3 {
+			t.Errorf("Expected composite span over 3 keys, got %v", span)
+		}
+	}
+}
+
+func TestSargForKeyRanges(t *testing.T) {
+	pred, err := parser.Parse("a >= 1 AND a <= 5 AND b > 3 AND b < 8")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := expression.Expressions{expression.NewIdentifier("a"), expression.NewIdentifier("b")}
+	spans, err := SargFor(pred, keys, len(keys))
+	if err != nil || len(spans) != 1 {
+		t.Fatalf("Expected one span, got %v (%v)", spans, err)
+	}
+
+	// The composite range excludes its bounds, as b does; a includes them
+	span := spans[0]
+	if span.Range.Inclusion != datastore.NEITHER || len(span.Ranges) != 2 ||
+		span.Ranges[0].Inclusion != datastore.BOTH || span.Ranges[1].Inclusion != datastore.NEITHER {
+		b, _ := json.Marshal(span)
+		t.Fatalf("Expected the range of each key, got %s", b)
+	}
+
+	b, err := json.Marshal(span)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var rv plan.Span
+	err = json.Unmarshal(b, &rv)
+	if err != nil || len(rv.Ranges) != 2 || rv.Ranges[1].High.String() != "8" {
+		t.Errorf("Expected the ranges of %s to be unmarshaled, got %v (%v)", b, rv.Ranges, err)
+	}
+}
+
+func TestSargForExact(t *testing.T) {
+	keys := expression.Expressions{expression.NewIdentifier("a"), expression.NewIdentifier("b")}
+
+	for p, exact := range map[string]bool{
+		"a = 1 AND b = 2":                         true,
+		"a = $x AND b = 2":                        true,
+		"a = 1 AND b < 5":                         true,
+		"a = 1 AND b >= 1 AND b <= 5":             true,
+		"(a = 1 OR a = 2) AND b = 3":              true,
+		"a IS NULL AND b = 3":                     true,
+		"a = 0 AND b >= 1 AND b > 1 AND b <= 1.5": true,
+		"a = 1":                      false,
+		"a < 5":                      false,
+		"a IS NULL":                  false,
+		"a >= 1 AND a <= 5":          false,
+		"a = 1 AND b > 3":            false,
+		"a > 1 AND b = 3":            false,
+		"a = 1 AND c = 2":            false,
+		"a < 10 AND a < $x":          false,
+		"a LIKE 'x%'":                false,
+		"(a = 1 AND c = 2) OR a = 3": false,
+		"a = 1 AND (b = 2 OR c = 3)": false,
+	} {
+		pred, err := parser.Parse(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		n := SargableFor(pred, keys)
+		spans, err := SargFor(pred, keys[0:n], len(keys))
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if exactSpans(spans) != exact {
+			b, _ := json.Marshal(spans)
+			t.Errorf("Expected exact %v for %s, got %s", exact, p, b)
+		}
+	}
+}
+
+func BenchmarkSargFor(b *testing.B) {
+	pred, keys := sargInputs(b)
+
+	b.ReportAllocs()
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		_, err := SargFor(pred, keys, len(keys))
+		if err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestSargForParameters(t *testing.T) {
+	key, err := parser.Parse("a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, p := range []string{"a = $x", "a = $x + 1", "a >= lower($1)", "$x < a AND a < $y"} {
+		pred, err := parser.Parse(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if SargableFor(pred, expression.Expressions{key}) != 1 {
+			t.Errorf("Expected %s to be sargable", p)
+			continue
+		}
+
+		spans, err := SargFor(pred, expression.Expressions{key}, 1)
+		if err != nil || len(spans) != 1 || spans[0] == _FULL_SPANS[0] {
+			b, _ := json.Marshal(spans)
+			t.Errorf("Expected one parameterized span for %s, got %s (%v)", p, b, err)
+		}
+	}
+
+	pred, err := parser.Parse("a = $x + b")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	spans, err := SargFor(pred, expression.Expressions{key}, 1)
+	if err != nil || len(spans) > 0 && spans[0] != _FULL_SPANS[0] {
+		b, _ := json.Marshal(spans)
+		t.Errorf("Expected no span for a = $x + b, got %s (%v)", b, err)
+	}
+}
+
+func TestSargForNestedAny(t *testing.T) {
+	key, err := parser.Parse("ARRAY (ARRAY y.c FOR y IN x.b END) FOR x IN a END")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := expression.Expressions{key}
+
+	for _, p := range []string{
+		"ANY v IN a SATISFIES (ANY w IN v.b SATISFIES w.c = 5 END) END",
+		"ANY v IN a SATISFIES (ANY w IN v.b SATISFIES w.c = 5 END) END AND d > 1",
+	} {
+		pred, err := parser.Parse(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if SargableFor(pred, keys) != 1 {
+			t.Errorf("Expected %s to be sargable", p)
+			continue
+		}
+
+		spans, err := SargFor(pred, keys, 1)
+		if err != nil || len(spans) != 1 || len(spans[0].Range.Low) != 1 ||
+			spans[0].Range.Low[0].Value() == nil ||
+			spans[0].Range.Low[0].Value().Actual() != float64(5) {
+			b, _ := json.Marshal(spans)
+			t.Errorf("Expected span on 5 for %s, got %s (%v)", p, b, err)
+		}
+	}
+
+	for _, p := range []string{
+		"ANY v IN a SATISFIES v.b = 5 END",
+		"ANY v IN a SATISFIES (ANY w IN v.e SATISFIES w.c = 5 END) END",
+		"ANY v IN z SATISFIES (ANY w IN v.b SATISFIES w.c = 5 END) END",
+		"ARRAY (ARRAY y.c FOR y IN x.b END) FOR x IN a END = [5]",
+		"EVERY v IN a SATISFIES (ANY w IN v.b SATISFIES w.c = 5 END) END",
+	} {
+		pred, err := parser.Parse(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if SargableFor(pred, keys) != 0 {
+			t.Errorf("Expected %s not to be sargable", p)
+		}
+	}
+}
@@ -26,7 +26,7 @@ func SargableFor(pred expression.Expression, exprs expression.Expressions) int {
 
 	i := 0
 	for ; i < n; i++ {
-		if exprs[i].Value() != nil {
+		if exprs[i].Value() != nil || !arrayKeySargable(pred, exprs[i]) {
 			return i
 		}
 
@@ -0,0 +1,140 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package planner
+
+import (
+	"github.com/couchbase/query/expression"
+)
+
+type sargableAny struct {
+	predicate
+}
+
+func newSargableAny(pred *expression.Any) *sargableAny {
+	rv := &sargableAny{}
+	rv.test = func(expr2 expression.Expression) (bool, error) {
+		if SubsetOf(pred, expr2) {
+			return true, nil
+		}
+
+		mapping := anyMapping(pred, expr2)
+		return mapping != nil &&
+			SargableFor(pred.Satisfies(), expression.Expressions{mapping}) > 0, nil
+	}
+
+	return rv
+}
+
+/*
+An array index key ARRAY m FOR k IN c END indexes the elements m of
+the collection c, so it is sarged by ANY v IN c SATISFIES p END, where
+p is sarged against m with k renamed to v. If m is itself an array
+index key, p may be another ANY, so nested collections are sarged
+level by level. Returns the renamed mapping, or nil if the key is not
+an array index key over the same collection.
+*/
+func anyMapping(pred *expression.Any, key expression.Expression) expression.Expression {
+	array, ok := key.(*expression.Array)
+	if !ok {
+		return nil
+	}
+
+	pb, kb := pred.Bindings(), array.Bindings()
+	if len(pb) != 1 || len(kb) != 1 || pb[0].Descend() != kb[0].Descend() ||
+		!pb[0].Expression().EquivalentTo(kb[0].Expression()) {
+		return nil
+	}
+
+	from, to := kb[0].Variable(), pb[0].Variable()
+	mapping, ok := renameVariable(array.Mapping(), from, to)
+	if !ok {
+		return nil
+	}
+
+	// The index holds only the elements that satisfy WHEN
+	if array.When() != nil {
+		when, ok := renameVariable(array.When(), from, to)
+		if !ok || !SubsetOf(pred.Satisfies(), when) {
+			return nil
+		}
+	}
+
+	return mapping
+}
+
+/*
+Returns a copy of expr with the variable renamed, or false if a
+collection within expr binds either name, which would be shadowed.
+*/
+func renameVariable(expr expression.Expression, from, to string) (expression.Expression, bool) {
+	lister := &identifierLister{
+		identifiers: make(map[string]bool, 4),
+		bound:       make(map[string]bool, 4),
+	}
+	lister.SetTraverser(lister)
+
+	err := lister.Traverse(expr)
+	if err != nil || lister.subquery || lister.bound[from] || lister.bound[to] {
+		return nil, false
+	}
+
+	if from == to {
+		return expr, true
+	}
+
+	renamer := &variableRenamer{
+		from: from,
+		to:   to,
+	}
+	renamer.SetMapper(renamer)
+
+	rv, err := renamer.Map(expr.Copy())
+	return rv, err == nil
+}
+
+type variableRenamer struct {
+	expression.MapperBase
+
+	from string
+	to   string
+}
+
+func (this *variableRenamer) VisitIdentifier(expr *expression.Identifier) (interface{}, error) {
+	if expr.Identifier() == this.from {
+		return expression.NewIdentifier(this.to), nil
+	}
+
+	return expr, nil
+}
+
+// Array index keys are sarged only by ANY predicates, and their
+// conjunctions and disjunctions.
+func arrayKeySargable(pred, key expression.Expression) bool {
+	if _, ok := key.(*expression.Array); !ok {
+		return true
+	}
+
+	switch pred.(type) {
+	case *expression.Any, *expression.And, *expression.Or:
+		return true
+	default:
+		return false
+	}
+}
+
+func hasArrayKey(keys expression.Expressions) bool {
+	for _, key := range keys {
+		if _, ok := key.(*expression.Array); ok {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,242 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package policy provides row-level security policies and masking
+policies. A policy on a keyspace has a predicate over its documents;
+requests of users other than administrators only read, update and
+delete the documents that satisfy it, and only insert documents
+that satisfy it. A policy can also mask fields of the documents,
+which are then masked whenever such requests project them.
+
+Predicates and masked paths are written against the documents
+themselves, like index keys, and are qualified with the keyspace
+alias of each statement when they are applied.
+*/
+package policy
+
+import (
+	"sort"
+	"sync"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+Policy holds the predicate and the masks of a keyspace. Policies
+are immutable; changes replace them.
+*/
+type Policy struct {
+	namespace string
+	keyspace  string
+	predicate expression.Expression // nil if the keyspace only has masks
+	masks     []*Mask
+}
+
+func (this *Policy) Namespace() string {
+	return this.namespace
+}
+
+func (this *Policy) Keyspace() string {
+	return this.keyspace
+}
+
+// The predicate is shared; callers must copy it before mapping it.
+func (this *Policy) Predicate() expression.Expression {
+	return this.predicate
+}
+
+func (this *Policy) Masks() []*Mask {
+	return this.masks
+}
+
+func (this *Policy) empty() bool {
+	return this.predicate == nil && len(this.masks) == 0
+}
+
+func (this *Policy) copy() *Policy {
+	rv := *this
+	return &rv
+}
+
+/*
+Mask masks the value at a path of the documents with one of the
+mask types of the MASK function.
+*/
+type Mask struct {
+	path     expression.Path
+	maskType string
+}
+
+// The path is shared; callers must copy it before mapping it.
+func (this *Mask) Path() expression.Path {
+	return this.path
+}
+
+func (this *Mask) Type() string {
+	return this.maskType
+}
+
+func Key(namespace, keyspace string) string {
+	return namespace + ":" + keyspace
+}
+
+type policies struct {
+	sync.RWMutex
+	policies map[string]*Policy
+}
+
+var _POLICIES = &policies{
+	policies: make(map[string]*Policy),
+}
+
+// Returns false if the keyspace already has a predicate.
+func Add(namespace, keyspace string, predicate expression.Expression) (*Policy, bool) {
+	return update(namespace, keyspace, func(p *Policy) bool {
+		if p.predicate != nil {
+			return false
+		}
+
+		p.predicate = predicate
+		return true
+	})
+}
+
+// Removes the predicate; returns false if there is none.
+func Remove(namespace, keyspace string) bool {
+	_, ok := update(namespace, keyspace, func(p *Policy) bool {
+		if p.predicate == nil {
+			return false
+		}
+
+		p.predicate = nil
+		return true
+	})
+	return ok
+}
+
+// Returns false if the path is already masked.
+func AddMask(namespace, keyspace string, path expression.Path, maskType string) (*Policy, bool) {
+	return update(namespace, keyspace, func(p *Policy) bool {
+		if findMask(p.masks, path) >= 0 {
+			return false
+		}
+
+		masks := make([]*Mask, len(p.masks), len(p.masks)+1)
+		copy(masks, p.masks)
+		p.masks = append(masks, &Mask{path: path, maskType: maskType})
+		return true
+	})
+}
+
+// Returns false if the path is not masked.
+func RemoveMask(namespace, keyspace string, path expression.Path) bool {
+	_, ok := update(namespace, keyspace, func(p *Policy) bool {
+		i := findMask(p.masks, path)
+		if i < 0 {
+			return false
+		}
+
+		masks := make([]*Mask, 0, len(p.masks)-1)
+		masks = append(masks, p.masks[:i]...)
+		p.masks = append(masks, p.masks[i+1:]...)
+		return true
+	})
+	return ok
+}
+
+func findMask(masks []*Mask, path expression.Path) int {
+	for i, mask := range masks {
+		if mask.path.EquivalentTo(path) {
+			return i
+		}
+	}
+
+	return -1
+}
+
+/*
+Applies a change to a copy of the policy of a keyspace, and replaces
+the policy if the change succeeds. Policies left empty are removed.
+*/
+func update(namespace, keyspace string, change func(*Policy) bool) (*Policy, bool) {
+	key := Key(namespace, keyspace)
+
+	_POLICIES.Lock()
+	defer _POLICIES.Unlock()
+
+	var rv *Policy
+	if p, ok := _POLICIES.policies[key]; ok {
+		rv = p.copy()
+	} else {
+		rv = &Policy{
+			namespace: namespace,
+			keyspace:  keyspace,
+		}
+	}
+
+	if !change(rv) {
+		return nil, false
+	}
+
+	if rv.empty() {
+		delete(_POLICIES.policies, key)
+	} else {
+		_POLICIES.policies[key] = rv
+	}
+
+	return rv, true
+}
+
+func Get(namespace, keyspace string) *Policy {
+	_POLICIES.RLock()
+	defer _POLICIES.RUnlock()
+	return _POLICIES.policies[Key(namespace, keyspace)]
+}
+
+// All policies, sorted by key.
+func Policies() []*Policy {
+	_POLICIES.RLock()
+	defer _POLICIES.RUnlock()
+
+	keys := make([]string, 0, len(_POLICIES.policies))
+	for key, _ := range _POLICIES.policies {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rv := make([]*Policy, len(keys))
+	for i, key := range keys {
+		rv[i] = _POLICIES.policies[key]
+	}
+
+	return rv
+}
+
+func Count() int {
+	_POLICIES.RLock()
+	defer _POLICIES.RUnlock()
+	return len(_POLICIES.policies)
+}
+
+/*
+Exempt returns true if the request is made by an administrator: if
+the roles granted by its authenticator, or its credentials, as
+verified by the datastore, hold the admin privilege. The names of
+the users are not trusted.
+*/
+func Exempt(store datastore.Datastore, roles []datastore.Role, credentials datastore.Credentials) bool {
+	return datastore.AuthorizeAdmin(store, roles, credentials) == nil
+}
+
+// Returns true if policies apply to requests with the roles and credentials.
+func Applies(store datastore.Datastore, roles []datastore.Role, credentials datastore.Credentials) bool {
+	return Count() > 0 && !Exempt(store, roles, credentials)
+}
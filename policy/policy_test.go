@@ -0,0 +1,112 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package policy
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+)
+
+func TestPolicies(t *testing.T) {
+	pred, err := parser.Parse("owner = \"bob\"")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dir, er := ioutil.TempDir("", "policy")
+	if er != nil {
+		t.Fatal(er)
+	}
+	defer os.RemoveAll(dir)
+
+	store, err := file.NewDatastore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	catalog, _ := store.RoleCatalog()
+	catalog.GrantRole("root", datastore.Role{Name: datastore.ROLE_ADMIN})
+
+	users := datastore.Credentials{"local:bob": "pw"}
+	if Applies(store, nil, users) {
+		t.Errorf("Expected no policies to apply without policies")
+	}
+
+	if _, ok := Add("default", "beer", pred); !ok {
+		t.Fatalf("Expected policy to be added")
+	}
+	defer Remove("default", "beer")
+
+	if _, ok := Add("default", "beer", pred); ok {
+		t.Errorf("Expected duplicate policy to be rejected")
+	}
+
+	if p := Get("default", "beer"); p == nil || p.Predicate() != pred {
+		t.Errorf("Unexpected policy %v", p)
+	}
+
+	if !Applies(store, nil, users) {
+		t.Errorf("Expected policies to apply to %v", users)
+	}
+
+	// Names of administrators are not trusted
+	for _, admin := range []string{"Administrator", "local:Administrator", "admin:ops"} {
+		if !Applies(store, nil, datastore.Credentials{admin: "x", "local:bob": "pw"}) {
+			t.Errorf("Expected %s not to be exempt", admin)
+		}
+	}
+
+	if Applies(store, nil, datastore.Credentials{"root": "pw"}) {
+		t.Errorf("Expected root to be exempt")
+	}
+
+	if Applies(nil, []datastore.Role{{Name: datastore.ROLE_ADMIN}}, users) {
+		t.Errorf("Expected the admin role to be exempt")
+	}
+
+	if !Applies(nil, []datastore.Role{{Name: datastore.ROLE_QUERY_SELECT}}, users) {
+		t.Errorf("Expected the query_select role not to be exempt")
+	}
+}
+
+func TestMasks(t *testing.T) {
+	ssn, err := parser.Parse("ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	path := ssn.(expression.Path)
+	if _, ok := AddMask("default", "people", path, "partial"); !ok {
+		t.Fatalf("Expected mask to be added")
+	}
+
+	if _, ok := AddMask("default", "people", path.Copy().(expression.Path), "full"); ok {
+		t.Errorf("Expected duplicate mask to be rejected")
+	}
+
+	p := Get("default", "people")
+	if p == nil || p.Predicate() != nil || len(p.Masks()) != 1 || p.Masks()[0].Type() != "partial" {
+		t.Errorf("Unexpected policy %v", p)
+	}
+
+	if !RemoveMask("default", "people", path) || RemoveMask("default", "people", path) {
+		t.Errorf("Expected mask to be removed once")
+	}
+
+	if Get("default", "people") != nil {
+		t.Errorf("Expected empty policy to be removed")
+	}
+}
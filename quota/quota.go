@@ -0,0 +1,162 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package quota provides per-request resource limits, configured for
+users or keyspaces.
+*/
+package quota
+
+import (
+	"sort"
+	"sync"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+)
+
+type Kind string
+
+const (
+	USER     Kind = "user"
+	KEYSPACE Kind = "keyspace"
+)
+
+func ParseKind(kind string) (Kind, bool) {
+	switch Kind(kind) {
+	case USER, KEYSPACE:
+		return Kind(kind), true
+	default:
+		return "", false
+	}
+}
+
+// Limits applied to each request; zero means unlimited.
+type Limits struct {
+	Documents  int64 `json:"documents,omitempty"`   // Documents fetched
+	Mutations  int64 `json:"mutations,omitempty"`   // Documents mutated
+	ResultSize int64 `json:"result_size,omitempty"` // Result bytes
+}
+
+type Usage struct {
+	Requests   int64 `json:"requests"`
+	Exceeded   int64 `json:"exceeded"`
+	Documents  int64 `json:"documents"`
+	Mutations  int64 `json:"mutations"`
+	ResultSize int64 `json:"result_size"`
+}
+
+/*
+Quota holds the limits for a user or keyspace, along with the
+resources used so far by the requests it applied to.
+*/
+type Quota struct {
+	// Aligned ints need to be delared right at the top
+	// of the struct to avoid alignment issues on x86 platforms
+	requests   atomic.AlignedInt64
+	exceeded   atomic.AlignedInt64
+	documents  atomic.AlignedInt64
+	mutations  atomic.AlignedInt64
+	resultSize atomic.AlignedInt64
+
+	kind   Kind
+	name   string
+	limits Limits
+}
+
+func (this *Quota) Kind() Kind {
+	return this.kind
+}
+
+func (this *Quota) Name() string {
+	return this.name
+}
+
+func (this *Quota) Limits() Limits {
+	return this.limits
+}
+
+func (this *Quota) Usage() Usage {
+	return Usage{
+		Requests:   atomic.LoadInt64(&this.requests),
+		Exceeded:   atomic.LoadInt64(&this.exceeded),
+		Documents:  atomic.LoadInt64(&this.documents),
+		Mutations:  atomic.LoadInt64(&this.mutations),
+		ResultSize: atomic.LoadInt64(&this.resultSize),
+	}
+}
+
+func Key(kind Kind, name string) string {
+	return string(kind) + ":" + name
+}
+
+type quotas struct {
+	sync.RWMutex
+	quotas map[string]*Quota
+}
+
+var _QUOTAS = &quotas{
+	quotas: make(map[string]*Quota),
+}
+
+// Set the limits for a user or keyspace. Keyspaces are named
+// namespace:keyspace. Usage is reset.
+func Set(kind Kind, name string, limits Limits) *Quota {
+	rv := &Quota{
+		kind:   kind,
+		name:   name,
+		limits: limits,
+	}
+
+	_QUOTAS.Lock()
+	defer _QUOTAS.Unlock()
+	_QUOTAS.quotas[Key(kind, name)] = rv
+	return rv
+}
+
+func Remove(kind Kind, name string) bool {
+	key := Key(kind, name)
+
+	_QUOTAS.Lock()
+	defer _QUOTAS.Unlock()
+
+	_, ok := _QUOTAS.quotas[key]
+	delete(_QUOTAS.quotas, key)
+	return ok
+}
+
+func Get(kind Kind, name string) *Quota {
+	_QUOTAS.RLock()
+	defer _QUOTAS.RUnlock()
+	return _QUOTAS.quotas[Key(kind, name)]
+}
+
+// All quotas, sorted by key.
+func Quotas() []*Quota {
+	_QUOTAS.RLock()
+	defer _QUOTAS.RUnlock()
+
+	keys := make([]string, 0, len(_QUOTAS.quotas))
+	for key, _ := range _QUOTAS.quotas {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	rv := make([]*Quota, len(keys))
+	for i, key := range keys {
+		rv[i] = _QUOTAS.quotas[key]
+	}
+
+	return rv
+}
+
+func Count() int {
+	_QUOTAS.RLock()
+	defer _QUOTAS.RUnlock()
+	return len(_QUOTAS.quotas)
+}
@@ -0,0 +1,69 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package quota
+
+import (
+	"testing"
+)
+
+func TestTracker(t *testing.T) {
+	if NewTracker([]string{"bob"}) != nil {
+		t.Errorf("Expected nil tracker without quotas")
+	}
+
+	user := Set(USER, "bob", Limits{Documents: 10})
+	keyspace := Set(KEYSPACE, "default:beer", Limits{Mutations: 5, ResultSize: 100})
+	defer Remove(USER, "bob")
+	defer Remove(KEYSPACE, "default:beer")
+
+	tracker := NewTracker([]string{"bob"})
+	if tracker == nil {
+		t.Fatalf("Expected tracker")
+	}
+
+	if tracker.TracksResultSize() {
+		t.Errorf("Expected no result size tracking before keyspace access")
+	}
+
+	if err := tracker.AddDocuments("default:beer", 6); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if !tracker.TracksResultSize() {
+		t.Errorf("Expected result size tracking after keyspace access")
+	}
+
+	if err := tracker.AddMutations("default:wine", 8); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	// The first quota exceeded is charged
+	if err := tracker.AddMutations("default:beer", 6); err == nil || err.Code() != 5210 {
+		t.Errorf("Expected mutations quota error, got %v", err)
+	}
+
+	if err := tracker.AddDocuments("default:wine", 6); err == nil || err.Code() != 5200 {
+		t.Errorf("Expected documents quota error, got %v", err)
+	}
+
+	if err := tracker.AddResultSize(101); err == nil || err.Code() != 5220 {
+		t.Errorf("Expected result size quota error, got %v", err)
+	}
+
+	usage := user.Usage()
+	if usage.Requests != 1 || usage.Documents != 12 || usage.Exceeded != 0 {
+		t.Errorf("Unexpected user usage %v", usage)
+	}
+
+	usage = keyspace.Usage()
+	if usage.Requests != 1 || usage.Mutations != 6 || usage.ResultSize != 101 || usage.Exceeded != 1 {
+		t.Errorf("Unexpected keyspace usage %v", usage)
+	}
+}
@@ -0,0 +1,205 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package quota
+
+import (
+	"sync"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Tracker accounts for the resources used by a single request, and
+checks them against the quotas of the request's users and of the
+keyspaces it accesses. User quotas limit the request as a whole;
+keyspace quotas limit the documents fetched from and mutated in
+their keyspace, and the result size of any request accessing it.
+
+A nil Tracker accepts everything.
+*/
+type Tracker struct {
+	sync.Mutex
+	users      []*Quota
+	keyspaces  map[string]*keyspaceUsage
+	documents  int64
+	mutations  int64
+	resultSize int64
+	exceeded   bool
+}
+
+type keyspaceUsage struct {
+	quota     *Quota // nil if the keyspace has no quota
+	documents int64
+	mutations int64
+}
+
+// Returns nil if no quotas are configured.
+func NewTracker(users []string) *Tracker {
+	if Count() == 0 {
+		return nil
+	}
+
+	rv := &Tracker{
+		keyspaces: make(map[string]*keyspaceUsage, 4),
+	}
+
+	for _, user := range users {
+		q := Get(USER, user)
+		if q != nil {
+			atomic.AddInt64(&q.requests, 1)
+			rv.users = append(rv.users, q)
+		}
+	}
+
+	return rv
+}
+
+// Register a keyspace accessed by the request.
+func (this *Tracker) AddKeyspace(keyspace string) {
+	if this == nil {
+		return
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	this.keyspace(keyspace)
+}
+
+// Returns true if the result size needs to be tracked.
+func (this *Tracker) TracksResultSize() bool {
+	if this == nil {
+		return false
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	for _, q := range this.users {
+		if q.limits.ResultSize > 0 {
+			return true
+		}
+	}
+
+	for _, ks := range this.keyspaces {
+		if ks.quota != nil && ks.quota.limits.ResultSize > 0 {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (this *Tracker) AddDocuments(keyspace string, n int64) errors.Error {
+	if this == nil || n == 0 {
+		return nil
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.documents += n
+	for _, q := range this.users {
+		atomic.AddInt64(&q.documents, n)
+		if q.limits.Documents > 0 && this.documents > q.limits.Documents {
+			return this.exceed(q, errors.NewQuotaDocumentsError(string(q.kind), q.name, q.limits.Documents))
+		}
+	}
+
+	ks := this.keyspace(keyspace)
+	ks.documents += n
+	if q := ks.quota; q != nil {
+		atomic.AddInt64(&q.documents, n)
+		if q.limits.Documents > 0 && ks.documents > q.limits.Documents {
+			return this.exceed(q, errors.NewQuotaDocumentsError(string(q.kind), q.name, q.limits.Documents))
+		}
+	}
+
+	return nil
+}
+
+func (this *Tracker) AddMutations(keyspace string, n int64) errors.Error {
+	if this == nil || n == 0 {
+		return nil
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.mutations += n
+	for _, q := range this.users {
+		atomic.AddInt64(&q.mutations, n)
+		if q.limits.Mutations > 0 && this.mutations > q.limits.Mutations {
+			return this.exceed(q, errors.NewQuotaMutationsError(string(q.kind), q.name, q.limits.Mutations))
+		}
+	}
+
+	ks := this.keyspace(keyspace)
+	ks.mutations += n
+	if q := ks.quota; q != nil {
+		atomic.AddInt64(&q.mutations, n)
+		if q.limits.Mutations > 0 && ks.mutations > q.limits.Mutations {
+			return this.exceed(q, errors.NewQuotaMutationsError(string(q.kind), q.name, q.limits.Mutations))
+		}
+	}
+
+	return nil
+}
+
+func (this *Tracker) AddResultSize(n int64) errors.Error {
+	if this == nil || n == 0 {
+		return nil
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	this.resultSize += n
+	for _, q := range this.users {
+		atomic.AddInt64(&q.resultSize, n)
+		if q.limits.ResultSize > 0 && this.resultSize > q.limits.ResultSize {
+			return this.exceed(q, errors.NewQuotaResultSizeError(string(q.kind), q.name, q.limits.ResultSize))
+		}
+	}
+
+	for _, ks := range this.keyspaces {
+		if q := ks.quota; q != nil {
+			atomic.AddInt64(&q.resultSize, n)
+			if q.limits.ResultSize > 0 && this.resultSize > q.limits.ResultSize {
+				return this.exceed(q, errors.NewQuotaResultSizeError(string(q.kind), q.name, q.limits.ResultSize))
+			}
+		}
+	}
+
+	return nil
+}
+
+func (this *Tracker) keyspace(keyspace string) *keyspaceUsage {
+	ks, ok := this.keyspaces[keyspace]
+	if !ok {
+		ks = &keyspaceUsage{quota: Get(KEYSPACE, keyspace)}
+		if ks.quota != nil {
+			atomic.AddInt64(&ks.quota.requests, 1)
+		}
+		this.keyspaces[keyspace] = ks
+	}
+
+	return ks
+}
+
+// A request is counted once against the quota it exceeded first.
+func (this *Tracker) exceed(q *Quota, err errors.Error) errors.Error {
+	if !this.exceeded {
+		this.exceeded = true
+		atomic.AddInt64(&q.exceeded, 1)
+	}
+
+	return err
+}
@@ -0,0 +1,130 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"encoding/base64"
+	"net/http"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Identity is an authenticated client: the credentials passed on to
+the datastore, and any roles granted by the authenticator itself,
+which apply on top of those granted by the datastore.
+*/
+type Identity struct {
+	Credentials datastore.Credentials
+	Roles       []datastore.Role
+	Scheme      string // Set by Authenticators
+}
+
+/*
+Authenticator authenticates HTTP requests using one scheme. It
+returns nil if the request does not use the scheme, and an error
+if it does but cannot be authenticated.
+*/
+type Authenticator interface {
+	Scheme() string
+	Authenticate(req *http.Request) (*Identity, errors.Error)
+}
+
+const (
+	BASIC_SCHEME = "basic"
+	CERT_SCHEME  = "cert"
+	JWT_SCHEME   = "jwt"
+)
+
+/*
+Authenticators are tried in order, and the first one that applies
+authenticates the request. If no authenticators are configured,
+requests pass their credentials as request parameters or with
+HTTP basic authorization.
+*/
+type Authenticators []Authenticator
+
+func (this Authenticators) Authenticate(req *http.Request) (*Identity, errors.Error) {
+	for _, auth := range this {
+		identity, err := auth.Authenticate(req)
+		if identity != nil && err == nil {
+			identity.Scheme = auth.Scheme()
+		}
+
+		if identity != nil || err != nil {
+			return identity, err
+		}
+	}
+
+	return nil, errors.NewServiceErrorAuthentication(this.schemes(), "no credentials")
+}
+
+// Returns nil if there is no authenticator for the scheme.
+func (this Authenticators) Find(scheme string) Authenticator {
+	for _, auth := range this {
+		if auth.Scheme() == scheme {
+			return auth
+		}
+	}
+
+	return nil
+}
+
+func (this Authenticators) schemes() string {
+	schemes := make([]string, len(this))
+	for i, auth := range this {
+		schemes[i] = auth.Scheme()
+	}
+
+	return strings.Join(schemes, ", ")
+}
+
+/*
+BasicAuthenticator maps HTTP basic authorization to datastore
+credentials. User names may contain one colon, as in local:xxx
+and admin:xxx.
+*/
+type BasicAuthenticator struct {
+}
+
+func NewBasicAuthenticator() *BasicAuthenticator {
+	return &BasicAuthenticator{}
+}
+
+func (this *BasicAuthenticator) Scheme() string {
+	return BASIC_SCHEME
+}
+
+func (this *BasicAuthenticator) Authenticate(req *http.Request) (*Identity, errors.Error) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Basic ") {
+		return nil, nil
+	}
+
+	decoded, e := base64.StdEncoding.DecodeString(auth[len("Basic "):])
+	if e != nil {
+		return nil, errors.NewServiceErrorAuthentication(BASIC_SCHEME, "invalid encoding")
+	}
+
+	parts := strings.Split(string(decoded), ":")
+	creds := datastore.Credentials{}
+	switch len(parts) {
+	case 2:
+		creds[parts[0]] = parts[1]
+	case 3:
+		creds[parts[0]+":"+parts[1]] = parts[2]
+	default:
+		return nil, errors.NewServiceErrorAuthentication(BASIC_SCHEME, "invalid format")
+	}
+
+	return &Identity{Credentials: creds}, nil
+}
@@ -0,0 +1,89 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+CertAuthenticator authenticates TLS client certificates issued by
+the given certificate authorities. The subject common name of the
+certificate is mapped to a user; without a mapping, the common
+name is the user.
+*/
+type CertAuthenticator struct {
+	roots *x509.CertPool
+	users map[string]string
+}
+
+// The CA file holds PEM-encoded certificates.
+func NewCertAuthenticator(caFile string, users map[string]string) (*CertAuthenticator, error) {
+	pem, err := ioutil.ReadFile(caFile)
+	if err != nil {
+		return nil, err
+	}
+
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("No certificates in %s.", caFile)
+	}
+
+	return &CertAuthenticator{
+		roots: roots,
+		users: users,
+	}, nil
+}
+
+func (this *CertAuthenticator) Scheme() string {
+	return CERT_SCHEME
+}
+
+func (this *CertAuthenticator) Authenticate(req *http.Request) (*Identity, errors.Error) {
+	if req.TLS == nil || len(req.TLS.PeerCertificates) == 0 {
+		return nil, nil
+	}
+
+	certs := req.TLS.PeerCertificates
+	opts := x509.VerifyOptions{
+		Roots:         this.roots,
+		Intermediates: x509.NewCertPool(),
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageClientAuth},
+	}
+	for _, cert := range certs[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+
+	_, err := certs[0].Verify(opts)
+	if err != nil {
+		return nil, errors.NewServiceErrorAuthentication(CERT_SCHEME, err.Error())
+	}
+
+	user := certs[0].Subject.CommonName
+	if len(this.users) > 0 {
+		mapped, ok := this.users[user]
+		if !ok {
+			return nil, errors.NewServiceErrorAuthentication(CERT_SCHEME, "unknown subject "+user)
+		}
+		user = mapped
+	}
+
+	if user == "" {
+		return nil, errors.NewServiceErrorAuthentication(CERT_SCHEME, "no subject common name")
+	}
+
+	return &Identity{Credentials: datastore.Credentials{user: ""}}, nil
+}
@@ -0,0 +1,167 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"io/ioutil"
+	"math/big"
+	"net/http"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type testCert struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+}
+
+// Returns a certificate signed by the parent, or self-signed if the
+// parent is nil.
+func newTestCert(t *testing.T, name string, parent *testCert, usage x509.ExtKeyUsage,
+	notAfter time.Time) *testCert {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: name},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     notAfter,
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{usage},
+	}
+
+	signer, signerKey := template, key
+	if parent == nil {
+		template.IsCA = true
+		template.BasicConstraintsValid = true
+		template.KeyUsage |= x509.KeyUsageCertSign
+	} else {
+		signer, signerKey = parent.cert, parent.key
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return &testCert{cert, key}
+}
+
+func certRequest(certs ...*testCert) *http.Request {
+	req, _ := http.NewRequest("POST", "/query/service", nil)
+	req.TLS = &tls.ConnectionState{}
+	for _, c := range certs {
+		req.TLS.PeerCertificates = append(req.TLS.PeerCertificates, c.cert)
+	}
+	return req
+}
+
+func TestCertAuthenticator(t *testing.T) {
+	later := time.Now().Add(time.Hour)
+	ca := newTestCert(t, "ca", nil, x509.ExtKeyUsageAny, later)
+	other := newTestCert(t, "other", nil, x509.ExtKeyUsageAny, later)
+
+	file, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(file.Name())
+
+	pem.Encode(file, &pem.Block{Type: "CERTIFICATE", Bytes: ca.cert.Raw})
+	file.Close()
+
+	auth, err := NewCertAuthenticator(file.Name(), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Requests without client certificates are left to other authenticators
+	req, _ := http.NewRequest("POST", "/query/service", nil)
+	if identity, err := auth.Authenticate(req); identity != nil || err != nil {
+		t.Errorf("Expected no identity and no error, got %v %v", identity, err)
+	}
+
+	alice := newTestCert(t, "alice", ca, x509.ExtKeyUsageClientAuth, later)
+	identity, er := auth.Authenticate(certRequest(alice))
+	if er != nil {
+		t.Fatalf("Unexpected error %v", er)
+	}
+
+	if _, ok := identity.Credentials["alice"]; !ok || len(identity.Credentials) != 1 {
+		t.Errorf("Expected credentials of alice, got %v", identity.Credentials)
+	}
+
+	invalid := []struct {
+		name string
+		cert *testCert
+	}{
+		{"wrong CA", newTestCert(t, "alice", other, x509.ExtKeyUsageClientAuth, later)},
+		{"self-signed", newTestCert(t, "alice", nil, x509.ExtKeyUsageClientAuth, later)},
+		{"server certificate", newTestCert(t, "alice", ca, x509.ExtKeyUsageServerAuth, later)},
+		{"expired", newTestCert(t, "alice", ca, x509.ExtKeyUsageClientAuth, time.Now().Add(-time.Minute))},
+		{"no common name", newTestCert(t, "", ca, x509.ExtKeyUsageClientAuth, later)},
+	}
+
+	for _, test := range invalid {
+		if identity, err := auth.Authenticate(certRequest(test.cert)); identity != nil || err == nil {
+			t.Errorf("Expected %s to be rejected, got %v", test.name, identity)
+		}
+	}
+
+	// Mapped subjects are users; others are rejected
+	auth, err = NewCertAuthenticator(file.Name(), map[string]string{"alice": "local:alice"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity, er = auth.Authenticate(certRequest(alice))
+	if er != nil {
+		t.Fatalf("Unexpected error %v", er)
+	}
+
+	if _, ok := identity.Credentials["local:alice"]; !ok || len(identity.Credentials) != 1 {
+		t.Errorf("Expected credentials of local:alice, got %v", identity.Credentials)
+	}
+
+	bob := newTestCert(t, "bob", ca, x509.ExtKeyUsageClientAuth, later)
+	identity, er = auth.Authenticate(certRequest(bob))
+	if identity != nil || er == nil || !strings.HasSuffix(er.Error(), "unknown subject bob") {
+		t.Errorf("Expected bob to be rejected as unknown, got %v %v", identity, er)
+	}
+
+	// The CA file must hold certificates
+	empty, err := ioutil.TempFile("", "ca")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(empty.Name())
+	empty.Close()
+
+	if _, err = NewCertAuthenticator(empty.Name(), nil); err == nil {
+		t.Errorf("Expected error for a CA file without certificates")
+	}
+}
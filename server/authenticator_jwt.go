@@ -0,0 +1,152 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+JWTAuthenticator authenticates bearer tokens signed with HS256 and
+a shared secret. The subject claim is the user. The values of the
+role claim, a string or an array of strings, are mapped to roles;
+unmapped values are ignored. The expiry and not-before claims are
+enforced, and so is the issuer if one is configured.
+*/
+type JWTAuthenticator struct {
+	secret    []byte
+	issuer    string
+	roleClaim string
+	roles     map[string][]datastore.Role
+}
+
+func NewJWTAuthenticator(secret []byte, issuer, roleClaim string,
+	roles map[string][]datastore.Role) *JWTAuthenticator {
+	if roleClaim == "" {
+		roleClaim = "roles"
+	}
+
+	return &JWTAuthenticator{
+		secret:    secret,
+		issuer:    issuer,
+		roleClaim: roleClaim,
+		roles:     roles,
+	}
+}
+
+func (this *JWTAuthenticator) Scheme() string {
+	return JWT_SCHEME
+}
+
+func (this *JWTAuthenticator) Authenticate(req *http.Request) (*Identity, errors.Error) {
+	auth := req.Header.Get("Authorization")
+	if !strings.HasPrefix(auth, "Bearer ") {
+		return nil, nil
+	}
+
+	claims, reason := this.verify(strings.TrimSpace(auth[len("Bearer "):]), time.Now())
+	if reason != "" {
+		return nil, errors.NewServiceErrorAuthentication(JWT_SCHEME, reason)
+	}
+
+	user, _ := claims["sub"].(string)
+	if user == "" {
+		return nil, errors.NewServiceErrorAuthentication(JWT_SCHEME, "no subject")
+	}
+
+	var values []interface{}
+	switch claim := claims[this.roleClaim].(type) {
+	case string:
+		values = []interface{}{claim}
+	case []interface{}:
+		values = claim
+	}
+
+	var roles []datastore.Role
+	for _, v := range values {
+		if s, ok := v.(string); ok {
+			roles = append(roles, this.roles[s]...)
+		}
+	}
+
+	return &Identity{
+		Credentials: datastore.Credentials{user: ""},
+		Roles:       roles,
+	}, nil
+}
+
+// Returns the claims of a valid token, or the reason it is invalid.
+func (this *JWTAuthenticator) verify(token string, now time.Time) (map[string]interface{}, string) {
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, "malformed token"
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+	}
+	if !decodeSegment(parts[0], &header) {
+		return nil, "malformed header"
+	}
+
+	if header.Alg != "HS256" {
+		return nil, "unsupported algorithm " + header.Alg
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil {
+		return nil, "malformed signature"
+	}
+
+	mac := hmac.New(sha256.New, this.secret)
+	mac.Write([]byte(parts[0] + "." + parts[1]))
+	if !hmac.Equal(signature, mac.Sum(nil)) {
+		return nil, "invalid signature"
+	}
+
+	var claims map[string]interface{}
+	if !decodeSegment(parts[1], &claims) {
+		return nil, "malformed claims"
+	}
+
+	if exp, ok := claims["exp"].(float64); ok && now.Unix() >= int64(exp) {
+		return nil, "token expired"
+	}
+
+	if nbf, ok := claims["nbf"].(float64); ok && now.Unix() < int64(nbf) {
+		return nil, "token not yet valid"
+	}
+
+	if this.issuer != "" {
+		if iss, _ := claims["iss"].(string); iss != this.issuer {
+			return nil, "invalid issuer"
+		}
+	}
+
+	return claims, ""
+}
+
+func decodeSegment(segment string, v interface{}) bool {
+	bytes, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return false
+	}
+
+	return json.Unmarshal(bytes, v) == nil
+}
@@ -0,0 +1,136 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+)
+
+func signJWT(t *testing.T, alg string, claims map[string]interface{}, secret string) string {
+	header, err := json.Marshal(map[string]interface{}{"alg": alg, "typ": "JWT"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	unsigned := base64.RawURLEncoding.EncodeToString(header) + "." +
+		base64.RawURLEncoding.EncodeToString(payload)
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(unsigned))
+	return unsigned + "." + base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func bearerRequest(token string) *http.Request {
+	req, _ := http.NewRequest("POST", "/query/service", nil)
+	req.Header.Set("Authorization", "Bearer "+token)
+	return req
+}
+
+func TestJWTAuthenticator(t *testing.T) {
+	auth := NewJWTAuthenticator([]byte("secret"), "issuer", "", map[string][]datastore.Role{
+		"analyst": {{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "orders"}},
+		"writer":  {{Name: datastore.ROLE_QUERY_UPDATE, Keyspace: "orders"}},
+	})
+
+	now := time.Now().Unix()
+	claims := func(extra map[string]interface{}) map[string]interface{} {
+		rv := map[string]interface{}{"sub": "alice", "iss": "issuer", "exp": now + 60}
+		for k, v := range extra {
+			if v == nil {
+				delete(rv, k)
+			} else {
+				rv[k] = v
+			}
+		}
+		return rv
+	}
+
+	// Other schemes are left to other authenticators
+	req, _ := http.NewRequest("POST", "/query/service", nil)
+	req.SetBasicAuth("alice", "password")
+	if identity, err := auth.Authenticate(req); identity != nil || err != nil {
+		t.Errorf("Expected basic authorization to be ignored, got %v %v", identity, err)
+	}
+
+	valid := signJWT(t, "HS256", claims(map[string]interface{}{
+		"roles": []interface{}{"analyst", "writer", "unmapped"},
+	}), "secret")
+	identity, err := auth.Authenticate(bearerRequest(valid))
+	if err != nil {
+		t.Fatalf("Unexpected error %v", err)
+	}
+
+	if _, ok := identity.Credentials["alice"]; !ok || len(identity.Credentials) != 1 {
+		t.Errorf("Expected credentials of alice, got %v", identity.Credentials)
+	}
+
+	if len(identity.Roles) != 2 || identity.Roles[0].Name != datastore.ROLE_QUERY_SELECT ||
+		identity.Roles[1].Name != datastore.ROLE_QUERY_UPDATE {
+		t.Errorf("Expected the analyst and writer roles, got %v", identity.Roles)
+	}
+
+	// A single role is a string
+	single := signJWT(t, "HS256", claims(map[string]interface{}{"roles": "analyst"}), "secret")
+	if identity, err = auth.Authenticate(bearerRequest(single)); err != nil || len(identity.Roles) != 1 {
+		t.Errorf("Expected the analyst role, got %v %v", identity, err)
+	}
+
+	// Tampering with the claims invalidates the signature
+	parts := strings.Split(valid, ".")
+	admin, _ := json.Marshal(claims(map[string]interface{}{"sub": "admin"}))
+	tampered := parts[0] + "." + base64.RawURLEncoding.EncodeToString(admin) + "." + parts[2]
+
+	// None signed is unsigned
+	none := strings.Split(signJWT(t, "none", claims(nil), "secret"), ".")
+
+	invalid := []struct {
+		token  string
+		reason string
+	}{
+		{"not a token", "malformed token"},
+		{signJWT(t, "HS256", claims(nil), "forged"), "invalid signature"},
+		{tampered, "invalid signature"},
+		{none[0] + "." + none[1] + ".", "unsupported algorithm none"},
+		{signJWT(t, "HS512", claims(nil), "secret"), "unsupported algorithm HS512"},
+		{signJWT(t, "RS256", claims(nil), "secret"), "unsupported algorithm RS256"},
+		{signJWT(t, "HS256", claims(map[string]interface{}{"exp": now - 1}), "secret"), "token expired"},
+		{signJWT(t, "HS256", claims(map[string]interface{}{"nbf": now + 60}), "secret"), "token not yet valid"},
+		{signJWT(t, "HS256", claims(map[string]interface{}{"iss": "other"}), "secret"), "invalid issuer"},
+		{signJWT(t, "HS256", claims(map[string]interface{}{"iss": nil}), "secret"), "invalid issuer"},
+		{signJWT(t, "HS256", claims(map[string]interface{}{"sub": nil}), "secret"), "no subject"},
+	}
+
+	for _, test := range invalid {
+		identity, err := auth.Authenticate(bearerRequest(test.token))
+		if identity != nil || err == nil || !strings.HasSuffix(err.Error(), test.reason) {
+			t.Errorf("Expected %s for %s, got %v %v", test.reason, test.token, identity, err)
+		}
+	}
+
+	// Without a configured issuer, any issuer is accepted
+	auth = NewJWTAuthenticator([]byte("secret"), "", "groups", nil)
+	token := signJWT(t, "HS256", claims(map[string]interface{}{"iss": "other", "groups": "analyst"}), "secret")
+	if identity, err = auth.Authenticate(bearerRequest(token)); err != nil || len(identity.Roles) != 0 {
+		t.Errorf("Expected alice without roles, got %v %v", identity, err)
+	}
+}
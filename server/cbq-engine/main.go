@@ -16,6 +16,7 @@ import (
 	"os/signal"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"syscall"
 	"time"
 
@@ -38,6 +39,7 @@ var DATASTORE = flag.String("datastore", "", "Datastore address (http://URL or d
 var CONFIGSTORE = flag.String("configstore", "stub:", "Configuration store address (http://URL or stub:)")
 var ACCTSTORE = flag.String("acctstore", "gometrics:", "Accounting store address (http://URL or stub:)")
 var NAMESPACE = flag.String("namespace", "default", "Default namespace")
+var SEARCH_PATH = flag.String("search-path", "", "Comma-separated namespaces searched for keyspaces not in the default namespace")
 var TIMEOUT = flag.Duration("timeout", 0*time.Second, "Server execution timeout, e.g. 500ms or 2s; use zero or negative value to disable")
 var READONLY = flag.Bool("readonly", false, "Read-only mode")
 var SIGNATURE = flag.Bool("signature", true, "Whether to provide signature")
@@ -49,11 +51,21 @@ var SERVICERS = flag.Int("servicers", 4*runtime.NumCPU(), "Servicer count")
 var PLUS_SERVICERS = flag.Int("plus-servicers", 16*runtime.NumCPU(), "Plus servicer count")
 var MAX_PARALLELISM = flag.Int("max-parallelism", 1, "Maximum parallelism per query; use zero or negative value to disable")
 var ORDER_LIMIT = flag.Int64("order-limit", 0, "Maximum LIMIT for ORDER BY clauses; use zero or negative value to disable")
+var MAX_RESULT_COUNT = flag.Int("max-result-count", 0, "Maximum number of results per request; use zero to disable")
+var MAX_RESULT_SIZE = flag.Int("max-result-size", 0, "Maximum size in bytes of the results of a request; use zero to disable")
 var MUTATION_LIMIT = flag.Int64("mutation-limit", 0, "Maximum LIMIT for data modification statements; use zero or negative value to disable")
-var HTTP_ADDR = flag.String("http", ":8093", "HTTP service address")
-var HTTPS_ADDR = flag.String("https", ":18093", "HTTPS service address")
+var HTTP_ADDR = flag.String("http", ":8093", "Comma-separated HTTP service addresses, e.g. :8093, [::1]:8093, tcp4:127.0.0.1:8093 or unix:/tmp/cbq.sock")
+var HTTPS_ADDR = flag.String("https", ":18093", "Comma-separated HTTPS service addresses, in the same form as -http")
 var CERT_FILE = flag.String("certfile", "", "HTTPS certificate file")
 var KEY_FILE = flag.String("keyfile", "", "HTTPS private key file")
+var TLS_CIPHERS = flag.String("tls-ciphers", "", "Comma-separated HTTPS cipher suites, e.g. TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256; empty for the defaults")
+var AUTHENTICATORS = flag.String("authenticators", "", "Comma-separated HTTP authenticators: basic, cert, jwt; empty to accept request credentials as given")
+var CLIENT_CA_FILE = flag.String("client-cafile", "", "CA file for client certificates")
+var CLIENT_USERS = flag.String("client-users", "", "Comma-separated mappings of client certificate common names to users, e.g. cn=user")
+var JWT_SECRET = flag.String("jwt-secret", "", "HMAC secret of JSON web tokens")
+var JWT_ISSUER = flag.String("jwt-issuer", "", "Required issuer of JSON web tokens")
+var JWT_ROLE_CLAIM = flag.String("jwt-role-claim", "roles", "Claim of JSON web tokens holding role values")
+var JWT_ROLES = flag.String("jwt-roles", "", "Comma-separated mappings of role claim values to roles, e.g. value=role or value=role@namespace:keyspace")
 var LOGGER = flag.String("logger", "", "Logger implementation")
 var LOG_LEVEL = flag.String("loglevel", "info", "Log level: debug, trace, info, warn, error, severe, none")
 var DEBUG = flag.Bool("debug", false, "Debug mode")
@@ -61,6 +73,16 @@ var KEEP_ALIVE_LENGTH = flag.Int("keep-alive-length", server.KEEP_ALIVE_DEFAULT,
 var STATIC_PATH = flag.String("static-path", "static", "Path to static content")
 var PIPELINE_CAP = flag.Int("pipeline-cap", 512, "Maximum number of items each execution operator can buffer")
 var PIPELINE_BATCH = flag.Int("pipeline-batch", 16, "Number of items execution operators can batch")
+var MUTATION_BATCH = flag.Int("mutation-batch", 0, "Number of documents INSERT and UPSERT send to the datastore at a time; 0 means pipeline-batch")
+var MAX_CONTINUATIONS = flag.Int("max-continuations", server.MAX_CONTINUATIONS, "Maximum number of paged requests held at a time; use zero to disable")
+var MAX_USER_CONTINUATIONS = flag.Int("max-user-continuations", server.MAX_USER_CONTINUATIONS, "Maximum number of paged requests held at a time by each user; use zero to disable")
+var REPLAN_ATTEMPTS = flag.Int("replan-attempts", 0, "Maximum number of times a request is re-planned when an index is dropped or taken offline; use zero to disable")
+var REPLICA_POLICY = flag.String("replica-policy", "primary_only", "Routing of reads to keyspace replicas: primary_only, prefer_replica, round_robin")
+var ERROR_VERBOSITY = flag.String("error-verbosity", "expression", "Context of expression evaluation errors: terse, expression, document")
+var OUTFILE_DIR = flag.String("outfile-dir", "", "Directory of the files of SELECT INTO OUTFILE; if empty, INTO OUTFILE is disabled")
+var IMPORT_DIR = flag.String("import-dir", "", "Directory of the files of IMPORT and of their error files; if empty, IMPORT is disabled")
+var RESULT_CACHE_SIZE = flag.Int("result-cache-size", 0, "Maximum number of statements whose results are cached; use zero to disable")
+var RESULT_CACHE_TTL = flag.Duration("result-cache-ttl", 10*time.Second, "Time to live of cached results, e.g. 500ms or 2s")
 var ENTERPRISE = flag.Bool("enterprise", true, "Enterprise mode")
 
 //cpu and memory profiling flags
@@ -126,10 +148,10 @@ func main() {
 		acctstore.MetricReporter().Start(1, 1)
 	}
 
-	channel := make(server.RequestChannel, *REQUEST_CAP)
-	plusChannel := make(server.RequestChannel, *REQUEST_CAP)
+	queue := server.NewRequestQueue(*REQUEST_CAP)
+	plusQueue := server.NewRequestQueue(*REQUEST_CAP)
 	server, err := server.NewServer(datastore, configstore, acctstore, *NAMESPACE,
-		*READONLY, channel, plusChannel, *SERVICERS, *PLUS_SERVICERS,
+		*READONLY, queue, plusQueue, *SERVICERS, *PLUS_SERVICERS,
 		*MAX_PARALLELISM, *TIMEOUT, *SIGNATURE, *METRICS, *ENTERPRISE)
 	if err != nil {
 		logging.Errorp(err.Error())
@@ -141,8 +163,37 @@ func main() {
 	server.SetMemProfile(*MEM_PROFILE)
 	server.SetPipelineCap(*PIPELINE_CAP)
 	server.SetPipelineBatch(*PIPELINE_BATCH)
+	server.SetMutationBatch(*MUTATION_BATCH)
 	server.SetRequestSizeCap(*REQUEST_SIZE_CAP)
 	server.SetScanCap(*SCAN_CAP)
+	server.SetReplanAttempts(*REPLAN_ATTEMPTS)
+	server.SetMaxContinuations(*MAX_CONTINUATIONS, *MAX_USER_CONTINUATIONS)
+	if !server.SetReplicaPolicy(*REPLICA_POLICY) {
+		logging.Errorp("Invalid replica policy", logging.Pair{"replica-policy", *REPLICA_POLICY})
+		os.Exit(1)
+	}
+	if !server.SetErrorVerbosity(*ERROR_VERBOSITY) {
+		logging.Errorp("Invalid error verbosity", logging.Pair{"error-verbosity", *ERROR_VERBOSITY})
+		os.Exit(1)
+	}
+	server.SetOutfileDir(*OUTFILE_DIR)
+	server.SetImportDir(*IMPORT_DIR)
+	server.SetResultCacheLimit(*RESULT_CACHE_SIZE)
+	server.SetResultCacheTTL(*RESULT_CACHE_TTL)
+	server.SetMaxResultCount(*MAX_RESULT_COUNT)
+	server.SetMaxResultSize(*MAX_RESULT_SIZE)
+	if *SEARCH_PATH != "" {
+		server.SetSearchPath(strings.Split(*SEARCH_PATH, ","))
+	}
+
+	if *AUTHENTICATORS != "" {
+		auths, err := newAuthenticators(*AUTHENTICATORS)
+		if err != nil {
+			logging.Errorp("Invalid authenticators", logging.Pair{"error", err})
+			os.Exit(1)
+		}
+		server.SetAuthenticators(auths...)
+	}
 
 	if server.Enterprise() && os.Getenv("GOMAXPROCS") == "" {
 		runtime.GOMAXPROCS(runtime.NumCPU())
@@ -172,6 +223,7 @@ func main() {
 		logging.Pair{"plus-servicers", server.PlusServicers()},
 		logging.Pair{"pipeline-cap", server.PipelineCap()},
 		logging.Pair{"pipeline-batch", *PIPELINE_BATCH},
+		logging.Pair{"mutation-batch", *MUTATION_BATCH},
 		logging.Pair{"request-cap", *REQUEST_CAP},
 		logging.Pair{"request-size-cap", server.RequestSizeCap()},
 		logging.Pair{"timeout", server.Timeout()},
@@ -189,6 +241,15 @@ func main() {
 		os.Exit(1)
 	}
 	if server.Enterprise() && *CERT_FILE != "" && *KEY_FILE != "" {
+		if *TLS_CIPHERS != "" {
+			ciphers, er := http.ParseCipherSuites(strings.Split(*TLS_CIPHERS, ","))
+			if er != nil {
+				logging.Errorp("Invalid cipher suites", logging.Pair{"error", er})
+				os.Exit(1)
+			}
+			endpoint.SetCipherSuites(ciphers)
+		}
+
 		er := endpoint.ListenTLS()
 		if er != nil {
 			logging.Errorp("cbq-engine exiting with error",
@@ -204,11 +265,21 @@ func main() {
 // signalCatcher blocks until a signal is recieved and then takes appropriate action
 func signalCatcher(server *server.Server, endpoint *http.HttpEndpoint) {
 	sig_chan := make(chan os.Signal, 4)
-	signal.Notify(sig_chan, os.Interrupt, syscall.SIGTERM)
+	signal.Notify(sig_chan, os.Interrupt, syscall.SIGTERM, syscall.SIGHUP)
 
 	var s os.Signal
-	select {
-	case s = <-sig_chan:
+	for s = range sig_chan {
+		if s != syscall.SIGHUP {
+			break
+		}
+
+		// SIGHUP => reload the TLS certificate
+		err := endpoint.ReloadCertificate()
+		if err != nil {
+			logging.Errorp("Cannot reload certificate", logging.Pair{"error", err})
+		} else {
+			logging.Infop("Certificate reloaded")
+		}
 	}
 	if server.CpuProfile() != "" {
 		logging.Infop("Stopping CPU profile")
@@ -241,3 +312,71 @@ func signalCatcher(server *server.Server, endpoint *http.HttpEndpoint) {
 		logging.Errorp("error closing https listener", logging.Pair{"err", err})
 	}
 }
+
+func newAuthenticators(schemes string) ([]server.Authenticator, error) {
+	auths := make([]server.Authenticator, 0, 3)
+	for _, scheme := range strings.Split(schemes, ",") {
+		switch strings.TrimSpace(scheme) {
+		case server.BASIC_SCHEME:
+			auths = append(auths, server.NewBasicAuthenticator())
+		case server.CERT_SCHEME:
+			users, err := parseMappings(*CLIENT_USERS)
+			if err != nil {
+				return nil, err
+			}
+
+			auth, err := server.NewCertAuthenticator(*CLIENT_CA_FILE, users)
+			if err != nil {
+				return nil, err
+			}
+			auths = append(auths, auth)
+		case server.JWT_SCHEME:
+			if *JWT_SECRET == "" {
+				return nil, fmt.Errorf("Missing JWT secret.")
+			}
+
+			mappings, err := parseMappings(*JWT_ROLES)
+			if err != nil {
+				return nil, err
+			}
+
+			roles := make(map[string][]datastore_package.Role, len(mappings))
+			for claim, role := range mappings {
+				name, keyspace := role, ""
+				if i := strings.Index(role, "@"); i >= 0 {
+					name, keyspace = role[:i], role[i+1:]
+				}
+
+				if !datastore_package.IsRole(name) {
+					return nil, fmt.Errorf("Invalid role %s.", name)
+				}
+				roles[claim] = []datastore_package.Role{{Name: name, Keyspace: keyspace}}
+			}
+
+			auths = append(auths, server.NewJWTAuthenticator([]byte(*JWT_SECRET),
+				*JWT_ISSUER, *JWT_ROLE_CLAIM, roles))
+		default:
+			return nil, fmt.Errorf("Unknown authenticator %s.", scheme)
+		}
+	}
+
+	return auths, nil
+}
+
+// Parses comma-separated key=value mappings.
+func parseMappings(mappings string) (map[string]string, error) {
+	rv := make(map[string]string)
+	if mappings == "" {
+		return rv, nil
+	}
+
+	for _, mapping := range strings.Split(mappings, ",") {
+		kv := strings.SplitN(mapping, "=", 2)
+		if len(kv) != 2 || kv[0] == "" || kv[1] == "" {
+			return nil, fmt.Errorf("Invalid mapping %s.", mapping)
+		}
+		rv[kv[0]] = kv[1]
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,429 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/quota"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+)
+
+// Suspended requests not resumed for this long are stopped.
+const CONTINUATION_TIMEOUT = 5 * time.Minute
+
+// Default limits of the paged requests held at a time by the server
+// and by each user.
+const (
+	MAX_CONTINUATIONS      = 1024
+	MAX_USER_CONTINUATIONS = 64
+)
+
+/*
+continuation is a request that returns its results in pages. After
+each page, the pipeline is parked: it blocks on its next result
+until a request with the continuation token resumes it, or until
+the token expires. The pipeline keeps its snapshots and its place
+in the scans while it is parked.
+*/
+type continuation struct {
+	token     string
+	users     []string
+	signature value.Value
+	operator  execution.Operator
+	output    *pageOutput
+	timer     *time.Timer
+}
+
+/*
+A paged request is held from its first page until its pipeline is
+done or its token expires, whether it is parked or returning a page.
+The number held is limited for the server and for each user, as each
+holds a pipeline and its snapshots; zero means unlimited.
+*/
+type continuations struct {
+	sync.Mutex
+	pending map[string]*continuation
+	held    int
+	users   map[string]int
+	max     int
+	maxUser int
+}
+
+var _CONTINUATIONS = &continuations{
+	pending: make(map[string]*continuation),
+	users:   make(map[string]int),
+	max:     MAX_CONTINUATIONS,
+	maxUser: MAX_USER_CONTINUATIONS,
+}
+
+func (this *continuations) limits() (int, int) {
+	this.Lock()
+	defer this.Unlock()
+	return this.max, this.maxUser
+}
+
+func (this *continuations) setLimits(max, maxUser int) {
+	this.Lock()
+	defer this.Unlock()
+	this.max = max
+	this.maxUser = maxUser
+}
+
+// Hold a paged request for the users, unless a limit is reached.
+func (this *continuations) hold(users []string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.max > 0 && this.held >= this.max {
+		return errors.NewServiceErrorPaging(fmt.Sprintf(
+			"The server already holds the maximum of %d paged requests.", this.max))
+	}
+
+	for _, user := range users {
+		if this.maxUser > 0 && this.users[user] >= this.maxUser {
+			return errors.NewServiceErrorPaging(fmt.Sprintf(
+				"User %s already holds the maximum of %d paged requests.", user, this.maxUser))
+		}
+	}
+
+	this.held++
+	for _, user := range users {
+		this.users[user]++
+	}
+
+	return nil
+}
+
+func (this *continuations) release(users []string) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.held--
+	for _, user := range users {
+		this.users[user]--
+		if this.users[user] <= 0 {
+			delete(this.users, user)
+		}
+	}
+}
+
+// Park the continuation under a new token.
+func (this *continuations) add(c *continuation) string {
+	token, _ := util.UUID()
+
+	this.Lock()
+	defer this.Unlock()
+
+	c.token = token
+	c.timer = time.AfterFunc(CONTINUATION_TIMEOUT, func() {
+		if this.remove(token, c.users) != nil {
+			this.release(c.users)
+			c.output.resume <- nil
+		}
+	})
+	this.pending[token] = c
+	return token
+}
+
+// Take the continuation, if it belongs to the users.
+func (this *continuations) remove(token string, users []string) *continuation {
+	this.Lock()
+	defer this.Unlock()
+
+	c, ok := this.pending[token]
+	if !ok || !sameUsers(c.users, users) {
+		return nil
+	}
+
+	delete(this.pending, token)
+	return c
+}
+
+func sameUsers(users1, users2 []string) bool {
+	if len(users1) != len(users2) {
+		return false
+	}
+
+	sorted := make([]string, len(users2))
+	copy(sorted, users2)
+	sort.Strings(sorted)
+	for i, user := range sorted {
+		if user != users1[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+// Run a read-only statement, returning its first page of results.
+func (this *Server) servicePages(request Request, namespace string, prepared *plan.Prepared,
+	users []string, quotas *quota.Tracker) {
+	if !prepared.Readonly() {
+		request.Fail(errors.NewServiceErrorPaging("Only read-only statements can return results in pages."))
+		request.Failed(this)
+		return
+	}
+
+	sorted := make([]string, len(users))
+	copy(sorted, users)
+	sort.Strings(sorted)
+
+	if err := _CONTINUATIONS.hold(sorted); err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return
+	}
+
+	output := newPageOutput(request, request.PageSize())
+	context := this.newContext(request, namespace, prepared, quotas, nil, output)
+	operator, err := execution.Build(prepared, context)
+	if err != nil {
+		_CONTINUATIONS.release(sorted)
+		context.ReleaseSnapshots()
+		request.Fail(errors.NewError(err, ""))
+		request.Failed(this)
+		return
+	}
+
+	c := &continuation{
+		users:     sorted,
+		signature: prepared.Signature(),
+		operator:  operator,
+		output:    output,
+	}
+
+	stop := make(chan bool, 1)
+	go request.Execute(this, c.signature, stop)
+
+	go func() {
+		defer close(output.done)
+		defer context.ReleaseSnapshots()
+		operator.RunOnce(context, nil)
+	}()
+
+	this.servicePage(request, c, stop)
+}
+
+// Resume a suspended request, returning its next page of results.
+func (this *Server) resumeRequest(request Request, users []string) {
+	c := _CONTINUATIONS.remove(request.Continuation(), users)
+	if c == nil {
+		request.Fail(errors.NewServiceErrorContinuation(request.Continuation()))
+		request.Failed(this)
+		return
+	}
+
+	c.timer.Stop()
+
+	stop := make(chan bool, 1)
+	go request.Execute(this, c.signature, stop)
+
+	c.output.resume <- request
+	this.servicePage(request, c, stop)
+}
+
+// Wait until the page is full or the pipeline is done, then end the
+// request.
+func (this *Server) servicePage(request Request, c *continuation, stop chan bool) {
+	// Apply server execution timeout
+	if this.Timeout() > 0 {
+		timer := time.AfterFunc(this.Timeout(), func() { request.Expire() })
+		defer timer.Stop()
+	}
+
+	// Stop the pipeline if the request stops before the page is full
+	done := make(chan bool)
+	go func() {
+		select {
+		case <-stop:
+			if !c.output.parked() {
+				select {
+				case c.operator.StopChannel() <- false:
+				default:
+				}
+			}
+		case <-done:
+		}
+	}()
+
+	paused := c.output.wait()
+	close(done)
+
+	if paused {
+		request.SetNextContinuation(_CONTINUATIONS.add(c))
+	} else {
+		_CONTINUATIONS.release(c.users)
+	}
+
+	request.Output().CloseResults()
+}
+
+/*
+pageOutput passes on the results of a paged pipeline to the request
+that is returning them. When the page of the request is full, the
+next result blocks until the pipeline is resumed by another
+request, or stopped.
+*/
+type pageOutput struct {
+	sync.Mutex
+	request  Request
+	pageSize int
+	count    int
+	blocked  bool
+	paused   chan bool    // The page is full
+	resume   chan Request // The next request, or nil to stop
+	done     chan bool    // The pipeline is done
+}
+
+func newPageOutput(request Request, pageSize int) *pageOutput {
+	return &pageOutput{
+		request:  request,
+		pageSize: pageSize,
+		paused:   make(chan bool, 1),
+		resume:   make(chan Request),
+		done:     make(chan bool),
+	}
+}
+
+// Returns true if the page is full, false if the pipeline is done.
+func (this *pageOutput) wait() bool {
+	select {
+	case <-this.paused:
+		return true
+	case <-this.done:
+		return false
+	}
+}
+
+func (this *pageOutput) parked() bool {
+	this.Lock()
+	defer this.Unlock()
+	return this.blocked
+}
+
+func (this *pageOutput) output() execution.Output {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.request == nil {
+		return nil
+	}
+	return this.request.Output()
+}
+
+func (this *pageOutput) Result(item value.Value) bool {
+	this.Lock()
+	if this.count >= this.pageSize {
+		this.blocked = true
+		this.Unlock()
+
+		this.paused <- true
+		request := <-this.resume
+
+		this.Lock()
+		this.blocked = false
+		this.request = request
+		this.count = 0
+		if request == nil {
+			this.Unlock()
+			return false
+		}
+	}
+
+	this.count++
+	output := this.request.Output()
+	this.Unlock()
+
+	return output.Result(item)
+}
+
+// The server ends each request once its page is written.
+func (this *pageOutput) CloseResults() {
+}
+
+func (this *pageOutput) Fatal(err errors.Error) {
+	if output := this.output(); output != nil {
+		output.Fatal(err)
+	}
+}
+
+func (this *pageOutput) Error(err errors.Error) {
+	if output := this.output(); output != nil {
+		output.Error(err)
+	}
+}
+
+func (this *pageOutput) Warning(wrn errors.Error) {
+	if output := this.output(); output != nil {
+		output.Warning(wrn)
+	}
+}
+
+func (this *pageOutput) AddMutationCount(i uint64) {
+	if output := this.output(); output != nil {
+		output.AddMutationCount(i)
+	}
+}
+
+func (this *pageOutput) MutationCount() uint64 {
+	if output := this.output(); output != nil {
+		return output.MutationCount()
+	}
+	return 0
+}
+
+func (this *pageOutput) AchieveDurability(durability datastore.Durability) {
+	if output := this.output(); output != nil {
+		output.AchieveDurability(durability)
+	}
+}
+
+func (this *pageOutput) AchievedDurability() datastore.Durability {
+	if output := this.output(); output != nil {
+		return output.AchievedDurability()
+	}
+	return ""
+}
+
+func (this *pageOutput) SetSortCount(i uint64) {
+	if output := this.output(); output != nil {
+		output.SetSortCount(i)
+	}
+}
+
+func (this *pageOutput) SortCount() uint64 {
+	if output := this.output(); output != nil {
+		return output.SortCount()
+	}
+	return 0
+}
+
+func (this *pageOutput) AddPhaseTime(phase string, duration time.Duration) {
+	if output := this.output(); output != nil {
+		output.AddPhaseTime(phase, duration)
+	}
+}
+
+func (this *pageOutput) PhaseTimes() map[string]time.Duration {
+	if output := this.output(); output != nil {
+		return output.PhaseTimes()
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+)
+
+func TestContinuationLimits(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"n": 1}`,
+		"o2": `{"n": 2}`,
+	})
+	defer os.RemoveAll(dir)
+
+	srvr.SetMaxContinuations(2, 1)
+	defer srvr.SetMaxContinuations(MAX_CONTINUATIONS, MAX_USER_CONTINUATIONS)
+
+	// Returns the continuation token of the page, or the error
+	page := func(user, continuation string) (string, int) {
+		request := newTestRequest("SELECT n FROM orders", datastore.Credentials{user: ""})
+		request.SetPageSize(1)
+		request.SetContinuation(continuation)
+		srvr.serviceRequest(request)
+		if errs := request.wait(); len(errs) > 0 {
+			return "", int(errs[0].Code())
+		}
+
+		return request.NextContinuation(), 0
+	}
+
+	alice, code := page("alice", "")
+	if alice == "" || code != 0 {
+		t.Fatalf("Expected a continuation for alice, got error %d", code)
+	}
+
+	if _, code = page("alice", ""); code != 1200 {
+		t.Errorf("Expected the user limit to be reached, got %d", code)
+	}
+
+	bob, code := page("bob", "")
+	if bob == "" || code != 0 {
+		t.Fatalf("Expected a continuation for bob, got error %d", code)
+	}
+
+	if _, code = page("carol", ""); code != 1200 {
+		t.Errorf("Expected the server limit to be reached, got %d", code)
+	}
+
+	// Completed requests release theirs
+	if alice, code = page("alice", alice); alice != "" || code != 0 {
+		t.Fatalf("Expected the last page for alice, got %s and error %d", alice, code)
+	}
+
+	if carol, code := page("carol", ""); carol == "" || code != 0 {
+		t.Errorf("Expected a continuation for carol, got error %d", code)
+	}
+
+	if _, code = page("alice", ""); code != 1200 {
+		t.Errorf("Expected the server limit to be reached, got %d", code)
+	}
+}
@@ -0,0 +1,534 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/guard"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+	"github.com/couchbase/query/view"
+)
+
+/*
+The changes to the results of a continuous query.
+*/
+const (
+	DELTA_ADDED   = "added"   // A document started to match; the result is its result
+	DELTA_CHANGED = "changed" // The result of a matching document changed
+	DELTA_REMOVED = "removed" // A document stopped matching; the result is its last result
+)
+
+type Delta struct {
+	Op     string      `json:"op"`
+	Key    string      `json:"key"`
+	Result value.Value `json:"result"`
+}
+
+const (
+	_DELTA_CAP        = 256              // Deltas buffered for the channel of a continuous query
+	_MUTATION_BATCH   = 1024             // Mutations re-evaluated together
+	_WEBHOOK_ATTEMPTS = 3                // Attempts to deliver each batch of deltas
+	_WEBHOOK_TIMEOUT  = 10 * time.Second // Timeout of each attempt
+)
+
+var _WEBHOOK_CLIENT = &http.Client{Timeout: _WEBHOOK_TIMEOUT}
+
+/*
+ContinuousQuery is a standing SELECT over one keyspace, whose results
+are re-evaluated as the documents of the keyspace change. It
+subscribes to the mutation feed of the keyspace, and re-evaluates
+the statement for each mutated document, using USE KEYS. The changes
+to the results are pushed as deltas to a channel or, if the query has
+a webhook, POSTed to the webhook as JSON:
+
+	{"name": "adults", "deltas": [{"op": "added", "key": "c1",
+	 "result": {"name": "dave"}}]}
+
+The statement must select from a single keyspace, without USE KEYS,
+joins, nests, unnests, grouping, aggregates, DISTINCT, ORDER BY,
+OFFSET, LIMIT or INTO OUTFILE, so that each document has at most one
+result. Changes to other keyspaces, e.g. those read by subqueries, do
+not re-evaluate the statement.
+
+A continuous query is a task of the scheduler, listed in system:tasks
+while it runs, and stopped by deleting its document from
+system:tasks. It first delivers the current results as added deltas.
+*/
+type ContinuousQuery struct {
+	server      *Server
+	name        string
+	namespace   string
+	keyspace    string
+	statement   string
+	webhook     string
+	credentials datastore.Credentials
+	keyed       *plan.Prepared
+	feed        datastore.MutationFeed
+	results     map[string]value.Value // Results by key; only used by run
+	size        int64                  // Number of results
+	delivered   int64                  // Number of deltas delivered
+	deltas      chan *Delta
+	stop        chan bool
+	stopOnce    sync.Once
+	runOnce     sync.Once
+	err         errors.Error
+}
+
+/*
+Start a continuous query, and register it as a task of the given
+name. The credentials must allow reading the keyspace. If the webhook
+is empty, the deltas are sent to the channel of the query, which must
+be read until it is closed.
+*/
+func (this *Server) StartContinuousQuery(name, owner, statement, namespace, webhook string,
+	credentials datastore.Credentials) (*ContinuousQuery, errors.Error) {
+	if namespace == "" {
+		namespace = this.Namespace()
+	}
+
+	stmt, e := n1ql.ParseStatement(statement)
+	if e != nil {
+		return nil, errors.NewParseSyntaxError(e, "")
+	}
+
+	sub, term, err := continuousStatement(stmt)
+	if err != nil {
+		return nil, err
+	}
+
+	if term.Namespace() != "" {
+		namespace = term.Namespace()
+	}
+
+	rv := &ContinuousQuery{
+		server:      this,
+		name:        name,
+		namespace:   namespace,
+		keyspace:    term.Keyspace(),
+		statement:   statement,
+		webhook:     webhook,
+		credentials: credentials,
+		results:     make(map[string]value.Value),
+		stop:        make(chan bool),
+	}
+
+	if webhook == "" {
+		rv.deltas = make(chan *Delta, _DELTA_CAP)
+	}
+
+	// The keyed statement evaluates the statement for one document
+	keyedTerm := algebra.NewKeyspaceTerm(term.Namespace(), term.Keyspace(), term.Projection(),
+		term.As(), algebra.NewPositionalParameter(1), nil)
+	rv.keyed, err = rv.prepare(algebra.NewSubselect(keyedTerm, sub.Let(), sub.Where(),
+		nil, sub.Projection()).String())
+	if err != nil {
+		return nil, err
+	}
+
+	// The keys statement finds the matching documents
+	keys, err := rv.prepare(algebra.NewSubselect(term, sub.Let(), sub.Where(), nil,
+		algebra.NewRawProjection(false, expression.NewField(
+			expression.NewMeta(expression.NewIdentifier(term.Alias())),
+			expression.NewFieldName("id", false)), "")).String())
+	if err != nil {
+		return nil, err
+	}
+
+	// Subscribe before the first evaluation, so that no change is missed
+	rv.feed, err = this.Subscribe(namespace, rv.keyspace, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	matches, err := rv.evaluate(keys, nil)
+	if err == nil {
+		for _, key := range matches {
+			if k, ok := key.Actual().(string); ok {
+				_, err = rv.reevaluate(k)
+				if err != nil {
+					break
+				}
+			}
+		}
+	}
+
+	if err == nil {
+		err = this.scheduler.Start(name, owner, func(*Server) errors.Error { return rv.run() },
+			rv.Stop, rv.info)
+	}
+
+	if err != nil {
+		rv.feed.Close()
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+/*
+Only statements whose documents each have at most one result can be
+re-evaluated document by document.
+*/
+func continuousStatement(stmt algebra.Statement) (*algebra.Subselect, *algebra.KeyspaceTerm, errors.Error) {
+	sel, ok := stmt.(*algebra.Select)
+	if !ok {
+		return nil, nil, errors.NewAdminContinuousQueryError(nil, "not a SELECT.")
+	}
+
+	sub, term, err := view.KeyPreserving(sel)
+	if err != nil {
+		return nil, nil, errors.NewAdminContinuousQueryError(nil, err.Error())
+	}
+
+	return sub, term, nil
+}
+
+func (this *ContinuousQuery) Name() string {
+	return this.name
+}
+
+/*
+The deltas of the query, if it has no webhook. The channel is closed
+when the query stops.
+*/
+func (this *ContinuousQuery) Deltas() <-chan *Delta {
+	return this.deltas
+}
+
+// The error that stopped the query, if any, once it has stopped.
+func (this *ContinuousQuery) Err() errors.Error {
+	return this.err
+}
+
+// Stop the query. Its task is deleted through the scheduler.
+func (this *ContinuousQuery) Stop() {
+	this.stopOnce.Do(func() {
+		close(this.stop)
+		this.feed.Close()
+	})
+}
+
+// The fields of the query in its system:tasks entry.
+func (this *ContinuousQuery) info() map[string]interface{} {
+	rv := map[string]interface{}{
+		"statement": this.statement,
+		"namespace": this.namespace,
+		"keyspace":  this.keyspace,
+		"results":   atomic.LoadInt64(&this.size),
+		"deltas":    atomic.LoadInt64(&this.delivered),
+	}
+
+	if this.webhook != "" {
+		rv["webhook"] = this.webhook
+	}
+
+	return rv
+}
+
+/*
+Deliver the current results, then the deltas of each batch of
+mutations, until the query is stopped or its feed fails. The task
+may be triggered again after it ends, but the query runs only once.
+*/
+func (this *ContinuousQuery) run() errors.Error {
+	this.runOnce.Do(func() {
+		if this.deltas != nil {
+			defer close(this.deltas)
+		}
+
+		initial := make([]*Delta, 0, len(this.results))
+		for key, result := range this.results {
+			initial = append(initial, &Delta{Op: DELTA_ADDED, Key: key, Result: result})
+		}
+
+		if !this.deliver(initial) {
+			return
+		}
+
+		for {
+			var mutation *datastore.Mutation
+			var ok bool
+			select {
+			case mutation, ok = <-this.feed.Mutations():
+			case <-this.stop:
+				return
+			}
+
+			if !ok {
+				this.err = this.feed.Err()
+				return
+			}
+
+			// Re-evaluate each mutated document once per batch
+			keys := []string{mutation.Key}
+			seen := map[string]bool{mutation.Key: true}
+		batch:
+			for len(keys) < _MUTATION_BATCH {
+				select {
+				case mutation, ok = <-this.feed.Mutations():
+					if !ok {
+						break batch
+					}
+					if !seen[mutation.Key] {
+						seen[mutation.Key] = true
+						keys = append(keys, mutation.Key)
+					}
+				default:
+					break batch
+				}
+			}
+
+			deltas := make([]*Delta, 0, len(keys))
+			for _, key := range keys {
+				delta, err := this.reevaluate(key)
+				if err != nil {
+					this.err = err
+					return
+				}
+
+				if delta != nil {
+					deltas = append(deltas, delta)
+				}
+			}
+
+			if !this.deliver(deltas) {
+				return
+			}
+		}
+	})
+
+	return this.err
+}
+
+/*
+Evaluate the statement for one document, update its result, and
+return the change, if any.
+*/
+func (this *ContinuousQuery) reevaluate(key string) (*Delta, errors.Error) {
+	results, err := this.evaluate(this.keyed, value.Values{value.NewValue(key)})
+	if err != nil {
+		return nil, err
+	}
+
+	old, matched := this.results[key]
+	if len(results) == 0 {
+		if !matched {
+			return nil, nil
+		}
+
+		delete(this.results, key)
+		atomic.AddInt64(&this.size, -1)
+		return &Delta{Op: DELTA_REMOVED, Key: key, Result: old}, nil
+	}
+
+	result := results[0]
+	this.results[key] = result
+	if !matched {
+		atomic.AddInt64(&this.size, 1)
+		return &Delta{Op: DELTA_ADDED, Key: key, Result: result}, nil
+	}
+
+	if old.Equals(result).Truth() {
+		return nil, nil
+	}
+
+	return &Delta{Op: DELTA_CHANGED, Key: key, Result: result}, nil
+}
+
+/*
+Send deltas to the channel, or POST them to the webhook. Deliveries
+to the webhook that fail are logged and dropped. Returns false if the
+query was stopped.
+*/
+func (this *ContinuousQuery) deliver(deltas []*Delta) bool {
+	if len(deltas) == 0 {
+		return true
+	}
+
+	if this.deltas != nil {
+		for _, delta := range deltas {
+			select {
+			case this.deltas <- delta:
+				atomic.AddInt64(&this.delivered, 1)
+			case <-this.stop:
+				return false
+			}
+		}
+		return true
+	}
+
+	body, e := json.Marshal(map[string]interface{}{
+		"name":   this.name,
+		"deltas": deltas,
+	})
+	if e != nil {
+		logging.Errorp("Continuous query cannot encode deltas", logging.Pair{"name", this.name},
+			logging.Pair{"error", e})
+		return true
+	}
+
+	for attempt := 1; attempt <= _WEBHOOK_ATTEMPTS; attempt++ {
+		e = post(this.webhook, body)
+		if e == nil {
+			atomic.AddInt64(&this.delivered, int64(len(deltas)))
+			return true
+		}
+
+		select {
+		case <-time.After(time.Duration(attempt) * time.Second):
+		case <-this.stop:
+			return false
+		}
+	}
+
+	logging.Errorp("Continuous query cannot deliver deltas", logging.Pair{"name", this.name},
+		logging.Pair{"webhook", this.webhook}, logging.Pair{"deltas", len(deltas)},
+		logging.Pair{"error", e})
+	return true
+}
+
+func post(url string, body []byte) error {
+	resp, err := _WEBHOOK_CLIENT.Post(url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+
+	resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("webhook returned %s", resp.Status)
+	}
+
+	return nil
+}
+
+func (this *ContinuousQuery) prepare(statement string) (*plan.Prepared, errors.Error) {
+	stmt, err := n1ql.ParseStatement(statement)
+	if err != nil {
+		return nil, errors.NewParseSyntaxError(err, "")
+	}
+
+	prepared, err := planner.BuildPrepared(stmt, this.server.datastore, this.server.systemstore,
+		this.namespace, false, policy.Applies(this.server.datastore, nil, this.credentials), this.server.readonly, nil)
+	if err != nil {
+		return nil, errors.NewPlanError(err, "")
+	}
+
+	users := make([]string, 0, len(this.credentials))
+	for user, _ := range this.credentials {
+		users = append(users, user)
+	}
+
+	if err := guard.Check(users, prepared.Operator); err != nil {
+		return nil, err
+	}
+
+	return prepared, nil
+}
+
+// Run a prepared statement of the query, and return its results.
+func (this *ContinuousQuery) evaluate(prepared *plan.Prepared, args value.Values) (
+	value.Values, errors.Error) {
+	out := &continuousOutput{}
+	context := execution.NewContext(this.name, this.server.datastore, this.server.systemstore,
+		this.namespace, true, 1, nil, args, this.credentials, datastore.UNBOUNDED, nil, out)
+	defer context.ReleaseSnapshots()
+
+	operator, err := execution.Build(prepared, context)
+	if err != nil {
+		return nil, errors.NewError(err, "")
+	}
+
+	operator.RunOnce(context, nil)
+	if out.err != nil {
+		return nil, out.err
+	}
+
+	return out.results, nil
+}
+
+/*
+continuousOutput collects the results and the first error of one
+evaluation of a continuous query.
+*/
+type continuousOutput struct {
+	sync.Mutex
+	results value.Values
+	err     errors.Error
+}
+
+func (this *continuousOutput) Result(item value.Value) bool {
+	this.Lock()
+	defer this.Unlock()
+	this.results = append(this.results, item)
+	return true
+}
+
+func (this *continuousOutput) CloseResults() {
+}
+
+func (this *continuousOutput) Fatal(err errors.Error) {
+	this.Error(err)
+}
+
+func (this *continuousOutput) Error(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *continuousOutput) Warning(wrn errors.Error) {
+}
+
+func (this *continuousOutput) AddMutationCount(i uint64) {
+}
+
+func (this *continuousOutput) MutationCount() uint64 {
+	return 0
+}
+
+func (this *continuousOutput) AchieveDurability(durability datastore.Durability) {
+}
+
+func (this *continuousOutput) AchievedDurability() datastore.Durability {
+	return ""
+}
+
+func (this *continuousOutput) SetSortCount(i uint64) {
+}
+
+func (this *continuousOutput) SortCount() uint64 {
+	return 0
+}
+
+func (this *continuousOutput) AddPhaseTime(phase string, duration time.Duration) {
+}
+
+func (this *continuousOutput) PhaseTimes() map[string]time.Duration {
+	return nil
+}
@@ -0,0 +1,86 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"encoding/json"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/value"
+)
+
+var _DRY_RUN_SIGNATURE = value.NewValue(map[string]interface{}{
+	"plan": value.JSON.String(),
+	"cost": value.JSON.String(),
+})
+
+/*
+A dry run checks a statement as if it were to be executed, including
+the read-only mode, the statement rules and the privileges of the
+request, and returns its plan and estimated cost instead of executing
+it. Applications can dry run their statements to validate them.
+*/
+func (this *Server) serviceDryRun(request Request, prepared *plan.Prepared) {
+	result, err := this.dryRun(request, prepared)
+	if err != nil {
+		request.Fail(err)
+		request.Failed(this)
+		return
+	}
+
+	go request.Execute(this, _DRY_RUN_SIGNATURE, make(chan bool, 1))
+
+	output := request.Output()
+	output.Result(result)
+	output.CloseResults()
+}
+
+func (this *Server) dryRun(request Request, prepared *plan.Prepared) (value.Value, errors.Error) {
+	err := authorize(request, prepared.Operator)
+	if err != nil {
+		return nil, err
+	}
+
+	bytes, e := json.Marshal(map[string]interface{}{
+		"plan": prepared.Operator,
+		"cost": planner.EstimateCost(prepared.Operator, this.requestDatastore(request)),
+	})
+	if e != nil {
+		return nil, errors.NewError(e, "Failed to marshal JSON.")
+	}
+
+	return value.NewValue(bytes), nil
+}
+
+// Check the privileges of the request, as the plan would when run.
+func authorize(request Request, op plan.Operator) errors.Error {
+	if seq, ok := op.(*plan.Sequence); ok && len(seq.Children()) > 0 {
+		op = seq.Children()[0]
+	}
+
+	auth, ok := op.(*plan.Authorize)
+	if !ok {
+		return nil
+	}
+
+	// Roles granted by the authenticator take precedence
+	if _, granted := datastore.RolesGrant(request.Roles(), auth.Privileges()); granted {
+		return nil
+	}
+
+	if ds := datastore.GetDatastore(); ds != nil {
+		return ds.Authorize(auth.Privileges(), request.Credentials())
+	}
+
+	return nil
+}
@@ -0,0 +1,106 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/guard"
+	"github.com/couchbase/query/plan"
+)
+
+/*
+Guard is a hook for applications embedding the server, to deny
+statements that the rules of the statement-rules setting cannot
+express. Guards are invoked on the plan of every statement after the
+rules are checked and before the statement is executed, and reject
+it by returning an error such as errors.NewServiceErrorStatementDenied,
+which should explain why.
+*/
+type Guard interface {
+	Check(request Request, prepared *plan.Prepared) errors.Error
+}
+
+// GuardFunc adapts a function to the Guard interface.
+type GuardFunc func(request Request, prepared *plan.Prepared) errors.Error
+
+func (this GuardFunc) Check(request Request, prepared *plan.Prepared) errors.Error {
+	return this(request, prepared)
+}
+
+// Guards are copied on write, like rewriters.
+type guards struct {
+	sync.Mutex
+	list []Guard
+}
+
+func (this *Server) AddGuard(g Guard) {
+	this.guards.Lock()
+	defer this.guards.Unlock()
+
+	list := make([]Guard, len(this.guards.list), len(this.guards.list)+1)
+	copy(list, this.guards.list)
+	this.guards.list = append(list, g)
+}
+
+func (this *Server) Guards() []Guard {
+	this.guards.Lock()
+	defer this.guards.Unlock()
+	return this.guards.list
+}
+
+func (this *Server) StatementRules() guard.Rules {
+	if rules := guard.Get(); rules != nil {
+		return rules
+	}
+	return guard.Rules{}
+}
+
+func (this *Server) SetStatementRules(rules guard.Rules) {
+	guard.Set(rules)
+}
+
+func (this *Server) FullScanThreshold() int64 {
+	return guard.FullScanThreshold()
+}
+
+func (this *Server) SetFullScanThreshold(threshold int64) {
+	guard.SetFullScanThreshold(threshold)
+}
+
+// Check the plan of a statement against the rules, the full scan
+// threshold and the guards.
+func (this *Server) guard(request Request, prepared *plan.Prepared) errors.Error {
+	if guard.Count() > 0 {
+		users := make([]string, 0, len(request.Credentials()))
+		for user, _ := range request.Credentials() {
+			users = append(users, user)
+		}
+
+		if err := guard.Check(users, prepared.Operator); err != nil {
+			return err
+		}
+	}
+
+	if !request.AllowFullScan() {
+		if err := guard.CheckFullScans(prepared.Operator); err != nil {
+			return err
+		}
+	}
+
+	for _, g := range this.Guards() {
+		if err := g.Check(request, prepared); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
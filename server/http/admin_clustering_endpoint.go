@@ -20,10 +20,15 @@ import (
 	"time"
 
 	"github.com/couchbase/query/clustering"
+	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/guard"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/server"
 	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
 	"github.com/gorilla/mux"
 )
 
@@ -264,15 +269,11 @@ func doSslCert(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request)
 		return nil, err
 	}
 
-	// Auth clear: restart TLS listener to reload the SSL cert.
-	closeErr := endpoint.CloseTLS()
-	if closeErr != nil {
-		return nil, errors.NewAdminEndpointError(closeErr, "error closing tls listenener")
-	}
-
-	tlsErr := endpoint.ListenTLS()
+	// Auth clear: reload the SSL cert, keeping the TLS listener and
+	// its in-flight requests.
+	tlsErr := endpoint.ReloadCertificate()
 	if tlsErr != nil {
-		return nil, errors.NewAdminEndpointError(tlsErr, "error starting tls listenener")
+		return nil, errors.NewAdminEndpointError(tlsErr, "error reloading ssl certificate")
 	}
 
 	// response payload
@@ -287,15 +288,30 @@ func doSslCert(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request)
 const (
 	_CPUPROFILE      = "cpuprofile"
 	_DEBUG           = "debug"
+	_ERRORVERBOSITY  = "error-verbosity"
+	_FEATURES        = "features"
+	_FULLSCANTHRESH  = "full-scan-threshold"
 	_KEEPALIVELENGTH = "keep-alive-length"
 	_LOGLEVEL        = "loglevel"
 	_MAXPARALLELISM  = "max-parallelism"
+	_MAXRESULTCOUNT  = "max-result-count"
+	_MAXRESULTSIZE   = "max-result-size"
 	_MEMPROFILE      = "memprofile"
+	_MISSINGORDER    = "missing-order"
+	_MUTATIONBATCH   = "mutation-batch"
+	_MISSINGWARNINGS = "missing-warnings"
+	_NAMESPACE       = "namespace"
 	_REQUESTSIZECAP  = "request-size-cap"
 	_PIPELINEBATCH   = "pipeline-batch"
 	_PIPELINECAP     = "pipeline-cap"
+	_REPLANATTEMPTS  = "replan-attempts"
+	_REPLICAPOLICY   = "replica-policy"
+	_RESULTCACHESIZE = "result-cache-size"
+	_RESULTCACHETTL  = "result-cache-ttl"
 	_SCANCAP         = "scan-cap"
+	_SEARCHPATH      = "search-path"
 	_SERVICERS       = "servicers"
+	_STATEMENTRULES  = "statement-rules"
 	_TIMEOUT         = "timeout"
 )
 
@@ -311,11 +327,42 @@ func checkNumber(val interface{}) bool {
 	return ok
 }
 
+// Durations are strings such as "30s", or numbers of nanoseconds.
+func checkDuration(val interface{}) bool {
+	_, ok := getDuration(val)
+	return ok
+}
+
+func getDuration(val interface{}) (time.Duration, bool) {
+	switch val := val.(type) {
+	case float64:
+		return time.Duration(val), true
+	case string:
+		d, err := time.ParseDuration(val)
+		return d, err == nil
+	default:
+		return 0, false
+	}
+}
+
 func checkString(val interface{}) bool {
 	_, ok := val.(string)
 	return ok
 }
 
+func checkStrings(val interface{}) bool {
+	vals, ok := val.([]interface{})
+	if !ok {
+		return false
+	}
+	for _, v := range vals {
+		if !checkString(v) {
+			return false
+		}
+	}
+	return true
+}
+
 func checkLogLevel(val interface{}) bool {
 	level, is_string := val.(string)
 	if !is_string {
@@ -325,18 +372,70 @@ func checkLogLevel(val interface{}) bool {
 	return ok
 }
 
+func checkReplicaPolicy(val interface{}) bool {
+	policy, is_string := val.(string)
+	if !is_string {
+		return false
+	}
+	_, ok := datastore.ParseReplicaPolicy(policy)
+	return ok
+}
+
+func checkErrorVerbosity(val interface{}) bool {
+	verbosity, is_string := val.(string)
+	if !is_string {
+		return false
+	}
+	_, ok := execution.ParseErrorVerbosity(verbosity)
+	return ok
+}
+
+func checkMissingOrder(val interface{}) bool {
+	order, is_string := val.(string)
+	if !is_string {
+		return false
+	}
+	_, ok := value.ParseMissingOrder(order)
+	return ok
+}
+
+func checkStatementRules(val interface{}) bool {
+	_, err := guard.ParseRules(val)
+	return err == nil
+}
+
+func checkFeatures(val interface{}) bool {
+	_, err := feature.ParseFlags(val)
+	return err == nil
+}
+
 var _CHECKERS = map[string]checker{
 	_CPUPROFILE:      checkString,
 	_DEBUG:           checkBool,
+	_ERRORVERBOSITY:  checkErrorVerbosity,
+	_FEATURES:        checkFeatures,
+	_FULLSCANTHRESH:  checkNumber,
 	_KEEPALIVELENGTH: checkNumber,
 	_LOGLEVEL:        checkLogLevel,
 	_MAXPARALLELISM:  checkNumber,
+	_MAXRESULTCOUNT:  checkNumber,
+	_MAXRESULTSIZE:   checkNumber,
 	_MEMPROFILE:      checkString,
+	_MISSINGORDER:    checkMissingOrder,
+	_MISSINGWARNINGS: checkBool,
+	_NAMESPACE:       checkString,
 	_REQUESTSIZECAP:  checkNumber,
 	_PIPELINEBATCH:   checkNumber,
 	_PIPELINECAP:     checkNumber,
+	_MUTATIONBATCH:   checkNumber,
+	_REPLANATTEMPTS:  checkNumber,
+	_REPLICAPOLICY:   checkReplicaPolicy,
+	_RESULTCACHESIZE: checkNumber,
+	_RESULTCACHETTL:  checkDuration,
 	_SCANCAP:         checkNumber,
+	_SEARCHPATH:      checkStrings,
 	_SERVICERS:       checkNumber,
+	_STATEMENTRULES:  checkStatementRules,
 	_TIMEOUT:         checkNumber,
 }
 
@@ -351,6 +450,18 @@ var _SETTERS = map[string]setter{
 		value, _ := o.(bool)
 		s.SetDebug(value)
 	},
+	_ERRORVERBOSITY: func(s *server.Server, o interface{}) {
+		value, _ := o.(string)
+		s.SetErrorVerbosity(value)
+	},
+	_FEATURES: func(s *server.Server, o interface{}) {
+		value, _ := feature.ParseFlags(o)
+		s.SetFeatures(value)
+	},
+	_FULLSCANTHRESH: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetFullScanThreshold(int64(value))
+	},
 	_KEEPALIVELENGTH: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetKeepAlive(int(value))
@@ -363,10 +474,31 @@ var _SETTERS = map[string]setter{
 		value, _ := o.(float64)
 		s.SetMaxParallelism(int(value))
 	},
+	_MAXRESULTCOUNT: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetMaxResultCount(int(value))
+	},
+	_MAXRESULTSIZE: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetMaxResultSize(int(value))
+	},
 	_MEMPROFILE: func(s *server.Server, o interface{}) {
 		value, _ := o.(string)
 		s.SetMemProfile(value)
 	},
+	_MISSINGORDER: func(s *server.Server, o interface{}) {
+		name, _ := o.(string)
+		order, _ := value.ParseMissingOrder(name)
+		s.SetMissingOrder(order)
+	},
+	_MISSINGWARNINGS: func(s *server.Server, o interface{}) {
+		value, _ := o.(bool)
+		s.SetMissingWarnings(value)
+	},
+	_NAMESPACE: func(s *server.Server, o interface{}) {
+		value, _ := o.(string)
+		s.SetNamespace(value)
+	},
 	_PIPELINECAP: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetPipelineCap(int(value))
@@ -375,18 +507,50 @@ var _SETTERS = map[string]setter{
 		value, _ := o.(float64)
 		s.SetPipelineBatch(int(value))
 	},
+	_MUTATIONBATCH: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetMutationBatch(int(value))
+	},
+	_REPLANATTEMPTS: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetReplanAttempts(int(value))
+	},
+	_REPLICAPOLICY: func(s *server.Server, o interface{}) {
+		value, _ := o.(string)
+		s.SetReplicaPolicy(value)
+	},
 	_REQUESTSIZECAP: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetRequestSizeCap(int(value))
 	},
+	_RESULTCACHESIZE: func(s *server.Server, o interface{}) {
+		value, _ := o.(float64)
+		s.SetResultCacheLimit(int(value))
+	},
+	_RESULTCACHETTL: func(s *server.Server, o interface{}) {
+		value, _ := getDuration(o)
+		s.SetResultCacheTTL(value)
+	},
 	_SCANCAP: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetScanCap(int(value))
 	},
+	_SEARCHPATH: func(s *server.Server, o interface{}) {
+		values, _ := o.([]interface{})
+		path := make([]string, len(values))
+		for i, v := range values {
+			path[i], _ = v.(string)
+		}
+		s.SetSearchPath(path)
+	},
 	_SERVICERS: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetServicers(int(value))
 	},
+	_STATEMENTRULES: func(s *server.Server, o interface{}) {
+		value, _ := guard.ParseRules(o)
+		s.SetStatementRules(value)
+	},
 	_TIMEOUT: func(s *server.Server, o interface{}) {
 		value, _ := o.(float64)
 		s.SetTimeout(time.Duration(value))
@@ -433,13 +597,28 @@ func doSettings(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request
 func fillSettings(settings map[string]interface{}, srvr *server.Server) map[string]interface{} {
 	settings[_CPUPROFILE] = srvr.CpuProfile()
 	settings[_MEMPROFILE] = srvr.MemProfile()
+	settings[_MISSINGORDER] = srvr.MissingOrder().String()
+	settings[_MISSINGWARNINGS] = srvr.MissingWarnings()
+	settings[_NAMESPACE] = srvr.Namespace()
+	settings[_SEARCHPATH] = srvr.SearchPath()
 	settings[_SERVICERS] = srvr.Servicers()
+	settings[_STATEMENTRULES] = srvr.StatementRules()
 	settings[_SCANCAP] = srvr.ScanCap()
 	settings[_REQUESTSIZECAP] = srvr.RequestSizeCap()
 	settings[_DEBUG] = srvr.Debug()
+	settings[_ERRORVERBOSITY] = srvr.ErrorVerbosity()
+	settings[_FEATURES] = srvr.Features()
+	settings[_FULLSCANTHRESH] = srvr.FullScanThreshold()
 	settings[_PIPELINEBATCH] = srvr.PipelineBatch()
 	settings[_PIPELINECAP] = srvr.PipelineCap()
+	settings[_MUTATIONBATCH] = srvr.MutationBatch()
+	settings[_REPLANATTEMPTS] = srvr.ReplanAttempts()
+	settings[_REPLICAPOLICY] = srvr.ReplicaPolicy()
+	settings[_RESULTCACHESIZE] = srvr.ResultCacheLimit()
+	settings[_RESULTCACHETTL] = srvr.ResultCacheTTL().String()
 	settings[_MAXPARALLELISM] = srvr.MaxParallelism()
+	settings[_MAXRESULTCOUNT] = srvr.MaxResultCount()
+	settings[_MAXRESULTSIZE] = srvr.MaxResultSize()
 	settings[_TIMEOUT] = srvr.Timeout()
 	settings[_KEEPALIVELENGTH] = srvr.KeepAlive()
 	settings[_LOGLEVEL] = srvr.LogLevel()
@@ -0,0 +1,78 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"sort"
+
+	"github.com/couchbase/query/errors"
+)
+
+const (
+	continuousPrefix = adminPrefix + "/continuous"
+)
+
+func (this *HttpEndpoint) registerContinuousHandlers() {
+	continuousHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doContinuous)
+	}
+	this.mux.HandleFunc(continuousPrefix, continuousHandler).Methods("POST")
+}
+
+/*
+Start a continuous query, whose deltas are POSTed to a webhook, e.g.
+
+	{"name": "adults", "statement": "SELECT name FROM contacts WHERE age >= 18",
+	 "webhook": "http://localhost:9000/adults"}
+
+The credentials, as for statements, must allow reading the keyspace;
+the first user is the owner of the query. The response is the entry
+of the query in system:tasks. The query runs until its task is
+deleted, through the tasks API or from system:tasks.
+*/
+func doContinuous(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	creds, err := endpoint.credentials(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var settings struct {
+		Name      string `json:"name"`
+		Statement string `json:"statement"`
+		Namespace string `json:"namespace"`
+		Webhook   string `json:"webhook"`
+	}
+	decoder := json.NewDecoder(req.Body)
+	e := decoder.Decode(&settings)
+	if e != nil {
+		return nil, errors.NewAdminDecodingError(e)
+	}
+
+	if settings.Name == "" || settings.Statement == "" || settings.Webhook == "" {
+		return nil, errors.NewAdminContinuousQueryError(nil, "name, statement and webhook are required.")
+	}
+
+	users := make([]string, 0, len(creds))
+	for user, _ := range creds {
+		users = append(users, user)
+	}
+	sort.Strings(users)
+
+	owner := ""
+	if len(users) > 0 {
+		owner = users[0]
+	}
+
+	_, err = endpoint.server.StartContinuousQuery(settings.Name, owner, settings.Statement,
+		settings.Namespace, settings.Webhook, creds)
+	return taskData(err, settings.Name, endpoint)
+}
@@ -0,0 +1,120 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"strconv"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/gorilla/mux"
+)
+
+const (
+	feedPrefix = adminPrefix + "/feed"
+)
+
+func (this *HttpEndpoint) registerFeedHandlers() {
+	this.mux.HandleFunc(feedPrefix+"/{keyspace}", this.doFeed).Methods("GET")
+}
+
+/*
+Stream the mutations of a keyspace as JSON lines, until the client
+disconnects, e.g.
+
+	{"key":"c1","op":"upsert","cas":1445000000000000000,
+	 "vector":{"0":{"value":12,"guard":"7a3f..."}}}
+
+The namespace is the namespace parameter, or else the default. The
+credentials, as for statements, must allow reading the keyspace.
+*/
+func (this *HttpEndpoint) doFeed(w http.ResponseWriter, req *http.Request) {
+	creds, err := this.credentials(req)
+
+	var feed datastore.MutationFeed
+	if err == nil {
+		feed, err = this.server.Subscribe(req.FormValue("namespace"), mux.Vars(req)["keyspace"], creds)
+	}
+
+	if err != nil {
+		writeError(w, err)
+		return
+	}
+	defer feed.Close()
+
+	w.Header().Set("Content-Type", "application/x-ndjson")
+	w.WriteHeader(http.StatusOK)
+	w.(http.Flusher).Flush()
+
+	closeNotify := w.(http.CloseNotifier).CloseNotify()
+	encoder := json.NewEncoder(w)
+	for {
+		select {
+		case mutation, ok := <-feed.Mutations():
+			if !ok {
+				if feed.Err() != nil {
+					encoder.Encode(map[string]interface{}{"error": feed.Err()})
+				}
+				return
+			}
+
+			if encoder.Encode(mutationData(mutation)) != nil {
+				return
+			}
+
+			// Flush once the mutations read so far are written
+			if len(feed.Mutations()) == 0 {
+				w.(http.Flusher).Flush()
+			}
+		case <-closeNotify:
+			return
+		}
+	}
+}
+
+// The credentials of a request, as for statements.
+func (this *HttpEndpoint) credentials(req *http.Request) (datastore.Credentials, errors.Error) {
+	auths := this.server.Authenticators()
+	if len(auths) > 0 {
+		identity, err := auths.Authenticate(req)
+		if err != nil {
+			return nil, err
+		}
+		return identity.Credentials, nil
+	}
+
+	if user, password, ok := req.BasicAuth(); ok {
+		return datastore.Credentials{user: password}, nil
+	}
+
+	return nil, nil
+}
+
+// The vector is sparse, as in the scan_vector request parameter.
+func mutationData(mutation *datastore.Mutation) interface{} {
+	vector := make(map[string]interface{})
+	if mutation.Vector != nil {
+		for _, entry := range mutation.Vector.Entries() {
+			vector[strconv.FormatUint(uint64(entry.Position()), 10)] = map[string]interface{}{
+				"value": entry.Value(),
+				"guard": entry.Guard(),
+			}
+		}
+	}
+
+	return map[string]interface{}{
+		"key":    mutation.Key,
+		"op":     mutation.Op,
+		"cas":    mutation.Cas,
+		"vector": vector,
+	}
+}
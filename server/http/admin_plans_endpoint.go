@@ -0,0 +1,102 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+const (
+	plansPrefix = adminPrefix + "/plans"
+)
+
+func (this *HttpEndpoint) registerPlansHandlers() {
+	diffHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doPlanDiff)
+	}
+	routeMap := map[string]struct {
+		handler handlerFunc
+		methods []string
+	}{
+		plansPrefix + "/diff": {handler: diffHandler, methods: []string{"POST"}},
+	}
+
+	for route, h := range routeMap {
+		this.mux.HandleFunc(route, h.handler).Methods(h.methods...)
+	}
+}
+
+/*
+A plan to diff: the name of a prepared statement, an encoded plan, or
+a statement, which is planned as of now. Diffing a prepared statement
+with its text shows how an index change affects its plan.
+*/
+type planSource struct {
+	Prepared    string `json:"prepared"`
+	EncodedPlan string `json:"encoded_plan"`
+	Statement   string `json:"statement"`
+}
+
+func doPlanDiff(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	var sources struct {
+		Before *planSource `json:"before"`
+		After  *planSource `json:"after"`
+	}
+
+	decoder := json.NewDecoder(req.Body)
+	e := decoder.Decode(&sources)
+	if e != nil {
+		return nil, errors.NewAdminDecodingError(e)
+	}
+
+	before, err := endpoint.sourcePlan(sources.Before, "before")
+	if err != nil {
+		return nil, err
+	}
+
+	after, err := endpoint.sourcePlan(sources.After, "after")
+	if err != nil {
+		return nil, err
+	}
+
+	diffs, e := plan.Diff(before.Operator, after.Operator)
+	if e != nil {
+		return nil, errors.NewAdminPlanError("Unable to diff plans: " + e.Error())
+	}
+
+	return diffs, nil
+}
+
+func (this *HttpEndpoint) sourcePlan(source *planSource, name string) (*plan.Prepared, errors.Error) {
+	switch {
+	case source == nil:
+		return nil, errors.NewAdminPlanError("Missing plan: " + name)
+	case source.Prepared != "":
+		return plan.GetPrepared(value.NewValue(source.Prepared))
+	case source.EncodedPlan != "":
+		return plan.DecodePrepared(source.EncodedPlan)
+	case source.Statement != "":
+		return this.server.Plan(source.Statement)
+	default:
+		return nil, errors.NewAdminPlanError("The " + name + " plan needs a prepared name," +
+			" an encoded plan or a statement.")
+	}
+}
@@ -0,0 +1,109 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/quota"
+	"github.com/gorilla/mux"
+)
+
+const (
+	quotasPrefix = adminPrefix + "/quotas"
+)
+
+func (this *HttpEndpoint) registerQuotaHandlers() {
+	quotasHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doQuotas)
+	}
+	quotaHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doQuota)
+	}
+	routeMap := map[string]struct {
+		handler handlerFunc
+		methods []string
+	}{
+		quotasPrefix:                    {handler: quotasHandler, methods: []string{"GET"}},
+		quotasPrefix + "/{kind}/{name}": {handler: quotaHandler, methods: []string{"GET", "PUT", "DELETE"}},
+	}
+
+	for route, h := range routeMap {
+		this.mux.HandleFunc(route, h.handler).Methods(h.methods...)
+	}
+}
+
+func doQuotas(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		quotas := quota.Quotas()
+		rv := make([]interface{}, len(quotas))
+		for i, q := range quotas {
+			rv[i] = quotaData(q)
+		}
+		return rv, nil
+	default:
+		return nil, nil
+	}
+}
+
+func doQuota(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	vars := mux.Vars(req)
+	kind, ok := quota.ParseKind(vars["kind"])
+	if !ok {
+		return nil, errors.NewAdminQuotaKindError(vars["kind"])
+	}
+	name := vars["name"]
+
+	switch req.Method {
+	case "GET":
+		q := quota.Get(kind, name)
+		if q == nil {
+			return nil, nil
+		}
+		return quotaData(q), nil
+	case "PUT":
+		var limits quota.Limits
+		decoder := json.NewDecoder(req.Body)
+		err := decoder.Decode(&limits)
+		if err != nil {
+			return nil, errors.NewAdminDecodingError(err)
+		}
+		return quotaData(quota.Set(kind, name, limits)), nil
+	case "DELETE":
+		quota.Remove(kind, name)
+		return nil, nil
+	default:
+		return nil, nil
+	}
+}
+
+func quotaData(q *quota.Quota) map[string]interface{} {
+	return map[string]interface{}{
+		"kind":   q.Kind(),
+		"name":   q.Name(),
+		"limits": q.Limits(),
+		"usage":  q.Usage(),
+	}
+}
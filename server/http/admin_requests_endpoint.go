@@ -0,0 +1,109 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"net/http"
+	"time"
+
+	"github.com/couchbase/query/active"
+	"github.com/couchbase/query/errors"
+	"github.com/gorilla/mux"
+)
+
+const (
+	activeRequestsPrefix = adminPrefix + "/active_requests"
+)
+
+func (this *HttpEndpoint) registerActiveRequestsHandlers() {
+	requestsHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doActiveRequests)
+	}
+	requestHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doActiveRequest)
+	}
+	routeMap := map[string]struct {
+		handler handlerFunc
+		methods []string
+	}{
+		activeRequestsPrefix:           {handler: requestsHandler, methods: []string{"GET"}},
+		activeRequestsPrefix + "/{id}": {handler: requestHandler, methods: []string{"GET", "DELETE"}},
+	}
+
+	for route, h := range routeMap {
+		this.mux.HandleFunc(route, h.handler).Methods(h.methods...)
+	}
+}
+
+func doActiveRequests(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		requests := active.Requests()
+		rv := make([]interface{}, len(requests))
+		for i, r := range requests {
+			rv[i] = activeRequestData(r)
+		}
+		return rv, nil
+	default:
+		return nil, nil
+	}
+}
+
+// DELETE cancels the request; the response describes the request
+// as it was when cancelled.
+func doActiveRequest(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	r := active.Get(mux.Vars(req)["id"])
+	if r == nil {
+		return nil, nil
+	}
+
+	switch req.Method {
+	case "GET":
+		return activeRequestData(r), nil
+	case "DELETE":
+		rv := activeRequestData(r)
+		if !active.Cancel(r.Id()) {
+			return nil, nil
+		}
+		return rv, nil
+	default:
+		return nil, nil
+	}
+}
+
+func activeRequestData(r *active.Request) map[string]interface{} {
+	rv := map[string]interface{}{
+		"id":          r.Id(),
+		"statement":   r.Statement(),
+		"users":       r.Users(),
+		"requestTime": r.RequestTime().Format(time.RFC3339Nano),
+		"elapsedTime": time.Since(r.RequestTime()).String(),
+		"state":       r.State(),
+	}
+	if r.ClientId() != "" {
+		rv["clientContextID"] = r.ClientId()
+	}
+	if progress := r.Progress(); progress != nil {
+		rv["progress"] = progress
+	}
+	return rv
+}
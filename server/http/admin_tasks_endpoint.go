@@ -0,0 +1,127 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"encoding/json"
+	"net/http"
+	"time"
+
+	"github.com/couchbase/query/errors"
+	"github.com/gorilla/mux"
+)
+
+const (
+	tasksPrefix = adminPrefix + "/tasks"
+)
+
+func (this *HttpEndpoint) registerTasksHandlers() {
+	tasksHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doTasks)
+	}
+	taskHandler := func(w http.ResponseWriter, req *http.Request) {
+		this.wrapAPI(w, req, doTask)
+	}
+	routeMap := map[string]struct {
+		handler handlerFunc
+		methods []string
+	}{
+		tasksPrefix:             {handler: tasksHandler, methods: []string{"GET"}},
+		tasksPrefix + "/{name}": {handler: taskHandler, methods: []string{"GET", "POST", "PUT", "DELETE"}},
+	}
+
+	for route, h := range routeMap {
+		this.mux.HandleFunc(route, h.handler).Methods(h.methods...)
+	}
+}
+
+func doTasks(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	switch req.Method {
+	case "GET":
+		scheduler := endpoint.server.Scheduler()
+		names := scheduler.Ids()
+		rv := make([]interface{}, 0, len(names))
+		for _, name := range names {
+			if entry, ok := scheduler.Entry(name); ok {
+				rv = append(rv, entry)
+			}
+		}
+		return rv, nil
+	default:
+		return nil, nil
+	}
+}
+
+// POST triggers the task; PUT sets its interval; DELETE stops and
+// removes a long-running task, e.g. a continuous query. The response
+// describes the task afterwards, or before it was deleted.
+func doTask(endpoint *HttpEndpoint, w http.ResponseWriter, req *http.Request) (interface{}, errors.Error) {
+	// Admin auth required
+	err := endpoint.hasAdminAuth(req)
+	if err != nil {
+		return nil, err
+	}
+
+	scheduler := endpoint.server.Scheduler()
+	name := mux.Vars(req)["name"]
+
+	switch req.Method {
+	case "GET":
+	case "POST":
+		err = scheduler.Trigger(name)
+	case "PUT":
+		var settings struct {
+			Interval string `json:"interval"`
+		}
+		decoder := json.NewDecoder(req.Body)
+		err := decoder.Decode(&settings)
+		if err != nil {
+			return nil, errors.NewAdminDecodingError(err)
+		}
+
+		var interval time.Duration
+		if settings.Interval != "" {
+			interval, err = time.ParseDuration(settings.Interval)
+			if err != nil {
+				return nil, errors.NewAdminTaskIntervalError(name, settings.Interval)
+			}
+		}
+		return taskData(scheduler.SetInterval(name, interval), name, endpoint)
+	case "DELETE":
+		entry, ok := scheduler.Entry(name)
+		err = scheduler.Delete(name)
+		if err != nil || !ok {
+			return nil, err
+		}
+		return entry, nil
+	default:
+		return nil, nil
+	}
+
+	return taskData(err, name, endpoint)
+}
+
+func taskData(err errors.Error, name string, endpoint *HttpEndpoint) (interface{}, errors.Error) {
+	if err != nil {
+		return nil, err
+	}
+
+	entry, ok := endpoint.server.Scheduler().Entry(name)
+	if !ok {
+		return nil, nil
+	}
+	return entry, nil
+}
@@ -0,0 +1,173 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"crypto/tls"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/logging"
+)
+
+// Interval at which certificate files are checked for changes.
+const CERT_POLL_INTERVAL = 10 * time.Second
+
+/*
+certificate holds the TLS certificate of an endpoint. It is reloaded
+when its files change, or on demand. Only new handshakes see a
+reloaded certificate, so in-flight requests are not affected.
+*/
+type certificate struct {
+	sync.RWMutex
+	certFile string
+	keyFile  string
+	cert     *tls.Certificate
+	certMod  time.Time
+	keyMod   time.Time
+	done     chan bool
+}
+
+func newCertificate(certFile, keyFile string) (*certificate, error) {
+	rv := &certificate{
+		certFile: certFile,
+		keyFile:  keyFile,
+		done:     make(chan bool),
+	}
+
+	err := rv.Reload()
+	if err != nil {
+		return nil, err
+	}
+
+	return rv, nil
+}
+
+// A failed reload keeps the current certificate. The files are not
+// reloaded again by the watcher until they change once more.
+func (this *certificate) Reload() error {
+	certMod, keyMod, err := this.modTimes()
+	if err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+	this.certMod = certMod
+	this.keyMod = keyMod
+
+	cert, err := tls.LoadX509KeyPair(this.certFile, this.keyFile)
+	if err != nil {
+		return err
+	}
+
+	this.cert = &cert
+	return nil
+}
+
+func (this *certificate) getCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	this.RLock()
+	defer this.RUnlock()
+	return this.cert, nil
+}
+
+func (this *certificate) modTimes() (time.Time, time.Time, error) {
+	certInfo, err := os.Stat(this.certFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	keyInfo, err := os.Stat(this.keyFile)
+	if err != nil {
+		return time.Time{}, time.Time{}, err
+	}
+
+	return certInfo.ModTime(), keyInfo.ModTime(), nil
+}
+
+func (this *certificate) changed() bool {
+	certMod, keyMod, err := this.modTimes()
+	if err != nil {
+		return false
+	}
+
+	this.RLock()
+	defer this.RUnlock()
+	return !certMod.Equal(this.certMod) || !keyMod.Equal(this.keyMod)
+}
+
+// Reload the certificate whenever its files change, until closed.
+func (this *certificate) watch(interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-this.done:
+			return
+		case <-ticker.C:
+		}
+
+		if !this.changed() {
+			continue
+		}
+
+		err := this.Reload()
+		if err != nil {
+			logging.Errorp("HttpEndpoint: certificate reload failed",
+				logging.Pair{"certfile", this.certFile}, logging.Pair{"error", err})
+		} else {
+			logging.Infop("HttpEndpoint: certificate reloaded",
+				logging.Pair{"certfile", this.certFile})
+		}
+	}
+}
+
+func (this *certificate) close() {
+	close(this.done)
+}
+
+// Cipher suites used when none are configured.
+var _DEFAULT_CIPHER_SUITES = []uint16{tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
+	tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
+	tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256}
+
+// Parses cipher suites by their standard names, e.g.
+// TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256.
+func ParseCipherSuites(names []string) ([]uint16, error) {
+	suites := make(map[string]uint16)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites[suite.Name] = suite.ID
+	}
+
+	rv := make([]uint16, 0, len(names))
+	for _, name := range names {
+		id, ok := suites[strings.ToUpper(strings.TrimSpace(name))]
+		if !ok {
+			return nil, fmt.Errorf("Unknown cipher suite %s.", name)
+		}
+		rv = append(rv, id)
+	}
+
+	return rv, nil
+}
@@ -0,0 +1,207 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"sort"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Columnar results, for the request parameter format=columnar, avoid
+repeating the field names of every row. Rows are written in batches,
+each a result of the form
+
+	{
+	    "count": 2,
+	    "columns": ["age", "name"],
+	    "types": ["number", "string"],
+	    "data": [[46, 56], ["dave", "ian"]]
+	}
+
+The columns are the fields of the signature of the statement, followed
+by any other fields of the rows, in the order they are found; their
+types are those of the signature, or json for the other fields.
+Columns keep their positions from one batch to the next. A row without
+the field of a column has null in it. Results that are not objects,
+e.g. of SELECT RAW, are in a single column named $1.
+
+max_result_count counts rows, but max_result_size counts whole
+batches.
+*/
+const _COLUMNAR_BATCH_SIZE = 1024
+
+type columnarBatch struct {
+	columns []string
+	types   []string
+	index   map[string]int
+	data    [][]interface{}
+	count   int
+	batches int
+}
+
+type columnarResult struct {
+	Count   int             `json:"count"`
+	Columns []string        `json:"columns"`
+	Types   []string        `json:"types"`
+	Data    [][]interface{} `json:"data"`
+}
+
+func newColumnarBatch(signature value.Value) *columnarBatch {
+	rv := &columnarBatch{index: make(map[string]int)}
+	if signature == nil {
+		return rv
+	}
+
+	if signature.Type() != value.OBJECT {
+		rv.column("$1", signature)
+		return rv
+	}
+
+	fields := signature.Fields()
+	names := make([]string, 0, len(fields))
+	for name, _ := range fields {
+		if name != "*" {
+			names = append(names, name)
+		}
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		typ, _ := signature.Field(name)
+		rv.column(name, typ)
+	}
+
+	return rv
+}
+
+// The position of a column, which is added if it is new.
+func (this *columnarBatch) column(name string, typ value.Value) int {
+	i, ok := this.index[name]
+	if ok {
+		return i
+	}
+
+	t := "json"
+	if typ != nil && typ.Type() == value.STRING {
+		t = typ.Actual().(string)
+	}
+
+	i = len(this.columns)
+	this.index[name] = i
+	this.columns = append(this.columns, name)
+	this.types = append(this.types, t)
+	this.data = append(this.data, nil)
+	return i
+}
+
+func (this *columnarBatch) add(item value.Value) {
+	row := this.count
+	this.count++
+
+	if item.Type() != value.OBJECT {
+		this.set(this.column("$1", nil), row, item)
+		return
+	}
+
+	fields := item.Fields()
+	names := make([]string, 0, len(fields))
+	for name, _ := range fields {
+		names = append(names, name)
+	}
+
+	sort.Strings(names)
+	for _, name := range names {
+		field, _ := item.Field(name)
+		this.set(this.column(name, nil), row, field)
+	}
+}
+
+func (this *columnarBatch) set(column, row int, val value.Value) {
+	data := this.data[column]
+	for len(data) < row {
+		data = append(data, nil)
+	}
+
+	this.data[column] = append(data, val)
+}
+
+// Return the rows added since the last flush, and start a new batch.
+func (this *columnarBatch) flush() *columnarResult {
+	for i, data := range this.data {
+		for len(data) < this.count {
+			data = append(data, nil)
+		}
+		this.data[i] = data
+	}
+
+	rv := &columnarResult{
+		Count:   this.count,
+		Columns: this.columns,
+		Types:   this.types,
+		Data:    this.data,
+	}
+
+	this.count = 0
+	this.batches++
+	this.data = make([][]interface{}, len(this.columns))
+	this.columns = append([]string(nil), this.columns...)
+	this.types = append([]string(nil), this.types...)
+	return rv
+}
+
+func (this *httpRequest) addColumnar(item value.Value) bool {
+	if this.maxResultCount > 0 && this.resultCount >= this.maxResultCount {
+		return this.truncate(MAX_RESULT_COUNT, this.maxResultCount)
+	}
+
+	this.columnar.add(item)
+	this.resultCount++
+
+	if this.columnar.count < _COLUMNAR_BATCH_SIZE {
+		return true
+	}
+
+	return this.writeColumnar()
+}
+
+// Write the current batch, if it has any rows.
+func (this *httpRequest) writeColumnar() bool {
+	if this.columnar == nil || this.columnar.count == 0 {
+		return true
+	}
+
+	count := this.columnar.count
+	first := this.columnar.batches == 0
+	bytes, err := this.marshal(this.columnar.flush(), "        ")
+	if err != nil {
+		this.Errors() <- errors.NewServiceErrorInvalidJSON(err)
+		return false
+	}
+
+	if this.maxResultSize > 0 && this.resultSize+len(bytes) > this.maxResultSize {
+		this.resultCount -= count
+		return this.truncate(MAX_RESULT_SIZE, this.maxResultSize)
+	}
+
+	var rv bool
+	if first {
+		rv = this.writeString("\n")
+	} else {
+		rv = this.writeString(",\n")
+	}
+
+	this.resultSize += len(bytes)
+	return rv &&
+		this.writeString("        ") &&
+		this.writeJSON(bytes)
+}
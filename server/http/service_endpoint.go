@@ -16,23 +16,24 @@ import (
 	"time"
 
 	"github.com/couchbase/query/accounting"
-	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/server"
 	"github.com/gorilla/mux"
 )
 
 type HttpEndpoint struct {
-	server      *server.Server
-	metrics     bool
-	httpAddr    string
-	httpsAddr   string
-	certFile    string
-	keyFile     string
-	bufpool     BufferPool
-	listener    net.Listener
-	listenerTLS net.Listener
-	mux         *mux.Router
+	server       *server.Server
+	metrics      bool
+	httpAddr     string
+	httpsAddr    string
+	certFile     string
+	keyFile      string
+	ciphers      []uint16
+	cert         *certificate
+	bufpool      BufferPool
+	listeners    []net.Listener
+	listenersTLS []net.Listener
+	mux          *mux.Router
 }
 
 const (
@@ -55,44 +56,59 @@ func NewServiceEndpoint(server *server.Server, staticPath string, metrics bool,
 	return rv
 }
 
+// The addresses of the endpoint are comma-separated; see parseAddress.
 func (this *HttpEndpoint) Listen() error {
-	ln, err := net.Listen("tcp", this.httpAddr)
+	lns, err := listen(this.httpAddr)
 	if err == nil {
-		this.listener = ln
-		go http.Serve(ln, this.mux)
-		logging.Infop("HttpEndpoint: Listen", logging.Pair{"Address", ln.Addr()})
+		this.listeners = lns
+		for _, ln := range lns {
+			go http.Serve(ln, this.mux)
+			logging.Infop("HttpEndpoint: Listen", logging.Pair{"Address", ln.Addr()})
+		}
 	}
 	return err
 }
 
+// Cipher suites of the TLS listener; nil for the defaults.
+func (this *HttpEndpoint) SetCipherSuites(ciphers []uint16) {
+	this.ciphers = ciphers
+}
+
 func (this *HttpEndpoint) ListenTLS() error {
 	// create tls configuration
-	tlsCert, err := tls.LoadX509KeyPair(this.certFile, this.keyFile)
+	cert, err := newCertificate(this.certFile, this.keyFile)
 	if err != nil {
 		return err
 	}
 
-	ln, err := net.Listen("tcp", this.httpsAddr)
+	ciphers := this.ciphers
+	if len(ciphers) == 0 {
+		ciphers = _DEFAULT_CIPHER_SUITES
+	}
+
+	lns, err := listen(this.httpsAddr)
 	if err == nil {
 		cfg := &tls.Config{
-			Certificates: []tls.Certificate{tlsCert},
-			ClientAuth:   tls.NoClientCert,
-			MinVersion:   tls.VersionTLS10,
-			CipherSuites: []uint16{tls.TLS_RSA_WITH_3DES_EDE_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_3DES_EDE_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_256_CBC_SHA,
-				tls.TLS_ECDHE_RSA_WITH_AES_128_GCM_SHA256,
-				tls.TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256},
+			GetCertificate: cert.getCertificate,
+			ClientAuth:     tls.NoClientCert,
+			MinVersion:     tls.VersionTLS10,
+			CipherSuites:   ciphers,
 		}
-		tls_ln := tls.NewListener(ln, cfg)
-		this.listenerTLS = tls_ln
-		go http.Serve(tls_ln, this.mux)
-		logging.Infop("HttpEndpoint: ListenTLS", logging.Pair{"Address", ln.Addr()})
+
+		// Client certificates are verified by the certificate authenticator
+		if this.server.Authenticators().Find(server.CERT_SCHEME) != nil {
+			cfg.ClientAuth = tls.RequestClientCert
+		}
+
+		this.listenersTLS = make([]net.Listener, len(lns))
+		for i, ln := range lns {
+			tls_ln := tls.NewListener(ln, cfg)
+			this.listenersTLS[i] = tls_ln
+			go http.Serve(tls_ln, this.mux)
+			logging.Infop("HttpEndpoint: ListenTLS", logging.Pair{"Address", ln.Addr()})
+		}
+		this.cert = cert
+		go cert.watch(CERT_POLL_INTERVAL)
 	}
 	return err
 }
@@ -100,7 +116,8 @@ func (this *HttpEndpoint) ListenTLS() error {
 // If the server channel is full and we are unable to queue a request,
 // we respond with a timeout status.
 func (this *HttpEndpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request) {
-	request := newHttpRequest(resp, req, this.bufpool, this.server.RequestSizeCap())
+	request := newHttpRequest(resp, req, this.bufpool, this.server.RequestSizeCap(),
+		this.server.Authenticators())
 
 	defer this.doStats(request)
 
@@ -110,42 +127,61 @@ func (this *HttpEndpoint) ServeHTTP(resp http.ResponseWriter, req *http.Request)
 		return
 	}
 
-	if request.ScanConsistency() == datastore.UNBOUNDED {
-		select {
-		case this.server.Channel() <- request:
-			// Wait until the request exits.
-			<-request.CloseNotify()
-		default:
-			// Buffer is full.
-			resp.WriteHeader(http.StatusServiceUnavailable)
+	// Admin priority is reserved to administrators
+	if request.Priority() == server.PRIORITY_ADMIN {
+		err := this.hasAdminAuth(req)
+		if err != nil {
+			request.Fail(err)
+			request.Failed(this.server)
+			return
 		}
+	}
+
+	if this.server.Enqueue(request) {
+		// Wait until the request exits.
+		<-request.CloseNotify()
 	} else {
-		select {
-		case this.server.PlusChannel() <- request:
-			// Wait until the request exits.
-			<-request.CloseNotify()
-		default:
-			// Buffer is full.
-			resp.WriteHeader(http.StatusServiceUnavailable)
-		}
+		// Queue is full.
+		resp.WriteHeader(http.StatusServiceUnavailable)
 	}
 }
 
 func (this *HttpEndpoint) Close() error {
-	return this.closeListener(this.listener)
+	err := this.closeListeners(this.listeners)
+	this.listeners = nil
+	return err
 }
 
 func (this *HttpEndpoint) CloseTLS() error {
-	return this.closeListener(this.listenerTLS)
+	if this.cert != nil {
+		this.cert.close()
+		this.cert = nil
+	}
+	err := this.closeListeners(this.listenersTLS)
+	this.listenersTLS = nil
+	return err
+}
+
+// Reload the TLS certificate and key files. Connections already
+// established keep the certificate they negotiated.
+func (this *HttpEndpoint) ReloadCertificate() error {
+	if this.cert == nil {
+		return nil
+	}
+	return this.cert.Reload()
 }
 
-func (this *HttpEndpoint) closeListener(l net.Listener) error {
-	var err error
-	if l != nil {
-		err = l.Close()
+// Returns the first error; every listener is closed regardless.
+func (this *HttpEndpoint) closeListeners(ls []net.Listener) error {
+	var rv error
+	for _, l := range ls {
+		err := l.Close()
 		logging.Infop("HttpEndpoint: close listener ", logging.Pair{"Address", l.Addr()}, logging.Pair{"err", err})
+		if rv == nil {
+			rv = err
+		}
 	}
-	return err
+	return rv
 }
 
 func (this *HttpEndpoint) registerHandlers(staticPath string) {
@@ -160,6 +196,12 @@ func (this *HttpEndpoint) registerHandlers(staticPath string) {
 
 	this.registerClusterHandlers()
 	this.registerAccountingHandlers()
+	this.registerQuotaHandlers()
+	this.registerTasksHandlers()
+	this.registerActiveRequestsHandlers()
+	this.registerFeedHandlers()
+	this.registerContinuousHandlers()
+	this.registerPlansHandlers()
 	this.registerStaticHandlers(staticPath)
 }
 
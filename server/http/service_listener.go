@@ -0,0 +1,84 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package http
+
+import (
+	"fmt"
+	"net"
+	"os"
+	"strings"
+)
+
+/*
+Listen addresses are comma-separated. Each address is host:port,
+with IPv6 hosts in brackets, e.g. [::1]:8093. An address without a
+host listens on all interfaces, dual-stack where available. An
+address may be prefixed by its network: tcp4: or tcp6: to restrict
+it to IPv4 or IPv6, or unix: followed by the path of a unix domain
+socket.
+*/
+func parseAddress(addr string) (network, address string, err error) {
+	addr = strings.TrimSpace(addr)
+	network = "tcp"
+
+	for _, n := range []string{"tcp4", "tcp6", "tcp", "unix"} {
+		if strings.HasPrefix(addr, n+":") {
+			network, addr = n, addr[len(n)+1:]
+			break
+		}
+	}
+
+	if addr == "" {
+		return "", "", fmt.Errorf("Missing listen address.")
+	}
+
+	if network != "unix" {
+		_, _, err = net.SplitHostPort(addr)
+		if err != nil {
+			return "", "", err
+		}
+	}
+
+	return network, addr, nil
+}
+
+// Listen on every address; on error, no listener is left open.
+func listen(addrs string) ([]net.Listener, error) {
+	rv := make([]net.Listener, 0, 2)
+	for _, addr := range strings.Split(addrs, ",") {
+		ln, err := listenAddress(addr)
+		if err != nil {
+			for _, l := range rv {
+				l.Close()
+			}
+			return nil, err
+		}
+		rv = append(rv, ln)
+	}
+
+	return rv, nil
+}
+
+func listenAddress(addr string) (net.Listener, error) {
+	network, address, err := parseAddress(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	// Remove a socket left behind by an earlier process
+	if network == "unix" {
+		info, err := os.Stat(address)
+		if err == nil && info.Mode()&os.ModeSocket != 0 {
+			os.Remove(address)
+		}
+	}
+
+	return net.Listen(network, address)
+}
@@ -14,6 +14,7 @@ import (
 	"encoding/json"
 	"fmt"
 	"io/ioutil"
+	"math"
 	"net/http"
 	"strconv"
 	"strings"
@@ -21,8 +22,11 @@ import (
 
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/server"
+	"github.com/couchbase/query/session"
 	"github.com/couchbase/query/timestamp"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
@@ -30,18 +34,28 @@ import (
 
 type httpRequest struct {
 	server.BaseRequest
-	resp          http.ResponseWriter
-	req           *http.Request
-	requestNotify chan bool
-	writer        responseDataManager
-	httpRespCode  int
-	resultCount   int
-	resultSize    int
-	errorCount    int
-	warningCount  int
-}
-
-func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool, size int) *httpRequest {
+	resp           http.ResponseWriter
+	req            *http.Request
+	requestNotify  chan bool
+	writer         responseDataManager
+	httpRespCode   int
+	resultCount    int
+	resultSize     int
+	errorCount     int
+	warningCount   int
+	pretty         bool
+	format         Format
+	columnar       *columnarBatch
+	maxResultCount int
+	maxResultSize  int
+	truncated      bool
+	progress       time.Duration // Interval of progress events, if any
+	pending        value.Value   // Result received while writing progress events
+	drained        bool          // No results remain
+}
+
+func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool, size int,
+	auths server.Authenticators) *httpRequest {
 	var httpArgs httpRequestArgs
 	var err errors.Error
 
@@ -63,6 +77,15 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		httpArgs, err = getRequestParams(req)
 	}
 
+	// Session settings provide defaults for the request parameters
+	var sess *session.Session
+	if err == nil {
+		sess, err = getSession(httpArgs)
+		if sess != nil {
+			httpArgs = &sessionArgs{httpArgs, sess}
+		}
+	}
+
 	var statement string
 	if err == nil {
 		statement, err = httpArgs.getStatement()
@@ -90,10 +113,25 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		}
 	}
 
-	if err == nil && statement == "" && prepared == nil {
+	// A continuation resumes a suspended request
+	var continuation string
+	if err == nil {
+		continuation, err = httpArgs.getString(CONTINUATION, "")
+	}
+
+	if err == nil && continuation != "" && (statement != "" || prepared != nil) {
+		err = errors.NewServiceErrorMultipleValues("statement, prepared or continuation")
+	}
+
+	if err == nil && statement == "" && prepared == nil && continuation == "" {
 		err = errors.NewServiceErrorMissingValue("statement or prepared")
 	}
 
+	var page_size int
+	if err == nil {
+		page_size, err = getCap(httpArgs, PAGE_SIZE)
+	}
+
 	var namedArgs map[string]value.Value
 	if err == nil {
 		namedArgs, err = httpArgs.getNamedArgs()
@@ -127,6 +165,83 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		}
 	}
 
+	var scan_cap, pipeline_cap, pipeline_batch int
+	if err == nil {
+		scan_cap, err = getBoundedCap(httpArgs, SCAN_CAP, datastore.GetScanCap())
+	}
+
+	if err == nil {
+		pipeline_cap, err = getBoundedCap(httpArgs, PIPELINE_CAP, execution.GetPipelineCap())
+	}
+
+	if err == nil {
+		pipeline_batch, err = getBoundedCap(httpArgs, PIPELINE_BATCH, int64(execution.PipelineBatchSize()))
+	}
+
+	var mutation_batch int
+	if err == nil {
+		mutation_batch, err = getBoundedCap(httpArgs, MUTATION_BATCH, int64(execution.MutationBatchSize()))
+	}
+
+	var cas_retries int
+	if err == nil {
+		cas_retries, err = getCap(httpArgs, CAS_RETRIES)
+	}
+
+	var features feature.Flags
+	if err == nil {
+		features, err = getFeatures(httpArgs)
+	}
+
+	var seed int64
+	var seeded bool
+	if err == nil {
+		seed, seeded, err = getSeed(httpArgs)
+	}
+
+	var strict_types value.Tristate
+	if err == nil {
+		strict_types, err = httpArgs.getTristate(STRICT_TYPES)
+	}
+
+	var strict_cast value.Tristate
+	if err == nil {
+		strict_cast, err = httpArgs.getTristate(STRICT_CAST)
+	}
+
+	var missing_order string
+	if err == nil {
+		missing_order, err = httpArgs.getString(MISSING_ORDER, "")
+	}
+
+	var missingOrder value.MissingOrder
+	if err == nil && missing_order != "" {
+		var ok bool
+		missingOrder, ok = value.ParseMissingOrder(missing_order)
+		if !ok {
+			err = errors.NewServiceErrorUnrecognizedValue(MISSING_ORDER, missing_order)
+		}
+	}
+
+	var missing_warnings value.Tristate
+	if err == nil {
+		missing_warnings, err = httpArgs.getTristate(MISSING_WARNINGS)
+	}
+
+	var durability datastore.Durability
+	if err == nil {
+		durability, err = getDurability(httpArgs)
+	}
+
+	var max_result_count, max_result_size int
+	if err == nil {
+		max_result_count, err = getCap(httpArgs, MAX_RESULT_COUNT)
+	}
+
+	if err == nil {
+		max_result_size, err = getCap(httpArgs, MAX_RESULT_SIZE)
+	}
+
 	var readonly value.Tristate
 	if err == nil {
 		readonly, err = getReadonly(httpArgs, req.Method == "GET")
@@ -137,12 +252,37 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		metrics, err = httpArgs.getTristate(METRICS)
 	}
 
+	priority := server.PRIORITY_INTERACTIVE
+	if err == nil {
+		priority, err = getPriority(httpArgs)
+	}
+
+	var useCache value.Tristate
+	if err == nil {
+		useCache, err = httpArgs.getTristate(USE_CACHE)
+	}
+
+	var allowFullScan value.Tristate
+	if err == nil {
+		allowFullScan, err = httpArgs.getTristate(ALLOW_FULL_SCAN)
+	}
+
+	var dryRun value.Tristate
+	if err == nil {
+		dryRun, err = httpArgs.getTristate(DRY_RUN)
+	}
+
+	var progress time.Duration
+	if err == nil {
+		progress, err = httpArgs.getDuration(PROGRESS)
+	}
+
 	var format Format
 	if err == nil {
 		format, err = getFormat(httpArgs)
 	}
 
-	if err == nil && format != JSON {
+	if err == nil && format != JSON && format != COLUMNAR {
 		err = errors.NewServiceErrorNotImplemented("format", format.String())
 	}
 
@@ -174,10 +314,6 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		pretty, err = httpArgs.getTristate(PRETTY)
 	}
 
-	if err == nil && pretty == value.FALSE {
-		err = errors.NewServiceErrorNotImplemented("pretty", "false")
-	}
-
 	var consistency *scanConfigImpl
 
 	if err == nil {
@@ -185,8 +321,24 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 	}
 
 	var creds datastore.Credentials
+	var roles []datastore.Role
+	var scheme string
 	if err == nil {
-		creds, err = getCredentials(httpArgs, req.Header["Authorization"])
+		if len(auths) > 0 {
+			var identity *server.Identity
+			identity, err = auths.Authenticate(req)
+			if err == nil {
+				creds = identity.Credentials
+				roles = identity.Roles
+				scheme = identity.Scheme
+			}
+		} else {
+			creds, err = getCredentials(httpArgs, req.Header["Authorization"])
+		}
+	}
+
+	if err == nil && sess != nil && !sess.Owned(credentialUsers(creds)) {
+		err = errors.NewServiceErrorSession(sess.Id())
 	}
 
 	client_id := ""
@@ -198,13 +350,43 @@ func newHttpRequest(resp http.ResponseWriter, req *http.Request, bp BufferPool,
 		max_parallelism, readonly, metrics, signature, consistency, client_id, creds)
 
 	rv := &httpRequest{
-		BaseRequest:   *base,
-		resp:          resp,
-		req:           req,
-		requestNotify: make(chan bool, 1),
+		BaseRequest:    *base,
+		resp:           resp,
+		req:            req,
+		requestNotify:  make(chan bool, 1),
+		pretty:         pretty != value.FALSE,
+		format:         format,
+		maxResultCount: max_result_count,
+		maxResultSize:  max_result_size,
+		progress:       progress,
 	}
 
 	rv.SetTimeout(rv, timeout)
+	rv.SetUseCache(useCache)
+	rv.SetAllowFullScan(allowFullScan == value.TRUE)
+	rv.SetDryRun(dryRun == value.TRUE)
+	rv.SetPriority(priority)
+	rv.SetScanCap(int64(scan_cap))
+	rv.SetPipelineCap(int64(pipeline_cap))
+	rv.SetPipelineBatch(pipeline_batch)
+	rv.SetMutationBatch(mutation_batch)
+	rv.SetFeatures(features)
+	if seeded {
+		rv.SetSeed(seed)
+	}
+	rv.SetStrictTypes(strict_types == value.TRUE)
+	rv.SetStrictCast(strict_cast == value.TRUE)
+	if missing_order != "" {
+		rv.SetMissingOrder(missingOrder)
+	}
+	rv.SetMissingWarnings(missing_warnings)
+	rv.SetDurability(durability)
+	rv.SetCasRetries(cas_retries)
+	rv.SetSession(sess)
+	rv.SetRoles(roles)
+	rv.SetAuthScheme(scheme)
+	rv.SetPageSize(page_size)
+	rv.SetContinuation(continuation)
 
 	rv.writer = NewBufferedWriter(rv, bp)
 
@@ -248,6 +430,28 @@ const ( // Request argument names
 	SCAN_VECTOR       = "scan_vector"
 	CREDS             = "creds"
 	CLIENT_CONTEXT_ID = "client_context_id"
+	USE_CACHE         = "use_cache"
+	ALLOW_FULL_SCAN   = "allow_full_scan"
+	DRY_RUN           = "dry_run"
+	PROGRESS          = "progress"
+	CAS_RETRIES       = "cas_retries"
+	PRIORITY          = "priority"
+	SCAN_CAP          = "scan_cap"
+	PIPELINE_CAP      = "pipeline_cap"
+	PIPELINE_BATCH    = "pipeline_batch"
+	MUTATION_BATCH    = "mutation_batch"
+	FEATURES          = "features"
+	SEED              = "seed"
+	STRICT_TYPES      = "strict_types"
+	STRICT_CAST       = "strict_cast"
+	MISSING_ORDER     = "missing_order"
+	MISSING_WARNINGS  = "missing_warnings"
+	DURABILITY        = "durability"
+	SESSION_ID        = "session_id"
+	MAX_RESULT_COUNT  = "max_result_count"
+	MAX_RESULT_SIZE   = "max_result_size"
+	PAGE_SIZE         = "page_size"
+	CONTINUATION      = "continuation"
 )
 
 var _PARAMETERS = []string{
@@ -270,6 +474,28 @@ var _PARAMETERS = []string{
 	SIGNATURE,
 	PRETTY,
 	CLIENT_CONTEXT_ID,
+	USE_CACHE,
+	ALLOW_FULL_SCAN,
+	DRY_RUN,
+	PROGRESS,
+	CAS_RETRIES,
+	PRIORITY,
+	SCAN_CAP,
+	PIPELINE_CAP,
+	PIPELINE_BATCH,
+	MUTATION_BATCH,
+	FEATURES,
+	SEED,
+	STRICT_TYPES,
+	STRICT_CAST,
+	MISSING_ORDER,
+	MISSING_WARNINGS,
+	DURABILITY,
+	SESSION_ID,
+	MAX_RESULT_COUNT,
+	MAX_RESULT_SIZE,
+	PAGE_SIZE,
+	CONTINUATION,
 }
 
 func isValidParameter(a string) bool {
@@ -303,6 +529,36 @@ func getEncodedPlan(a httpRequestArgs) (*plan.Prepared, errors.Error) {
 	return plan.DecodePrepared(prepared_field)
 }
 
+// The overrides of the server feature flags; see package feature.
+func getFeatures(a httpRequestArgs) (feature.Flags, errors.Error) {
+	features_field, err := a.getValue(FEATURES)
+	if err != nil || features_field == nil {
+		return nil, err
+	}
+
+	features, e := feature.ParseFlags(features_field.Actual())
+	if e != nil {
+		return nil, errors.NewServiceErrorBadValue(e, FEATURES)
+	}
+
+	return features, nil
+}
+
+// The seed of deterministic evaluation, an integer.
+func getSeed(a httpRequestArgs) (int64, bool, errors.Error) {
+	seed_field, err := a.getValue(SEED)
+	if err != nil || seed_field == nil {
+		return 0, false, err
+	}
+
+	seed, ok := seed_field.Actual().(float64)
+	if !ok || seed != math.Trunc(seed) {
+		return 0, false, errors.NewServiceErrorBadValue(nil, SEED)
+	}
+
+	return int64(seed), true, nil
+}
+
 func getCompression(a httpRequestArgs) (Compression, errors.Error) {
 	var compression Compression
 
@@ -316,6 +572,29 @@ func getCompression(a httpRequestArgs) (Compression, errors.Error) {
 	return compression, err
 }
 
+func getSession(a httpRequestArgs) (*session.Session, errors.Error) {
+	id, err := a.getString(SESSION_ID, "")
+	if err != nil || id == "" {
+		return nil, err
+	}
+
+	sess := session.Get(id)
+	if sess == nil {
+		return nil, errors.NewServiceErrorSession(id)
+	}
+
+	return sess, nil
+}
+
+func credentialUsers(creds datastore.Credentials) []string {
+	users := make([]string, 0, len(creds))
+	for user, _ := range creds {
+		users = append(users, user)
+	}
+
+	return users
+}
+
 func getScanConfiguration(a httpRequestArgs) (*scanConfigImpl, errors.Error) {
 
 	scan_consistency_field, err := a.getString(SCAN_CONSISTENCY, "NOT_BOUNDED")
@@ -375,6 +654,76 @@ func getFormat(a httpRequestArgs) (Format, errors.Error) {
 	return format, err
 }
 
+// Per-request caps; zero means use the server setting.
+func getCap(a httpRequestArgs, f string) (int, errors.Error) {
+	cap_field, err := a.getString(f, "")
+	if err != nil || cap_field == "" {
+		return 0, err
+	}
+
+	cap_value, e := strconv.Atoi(cap_field)
+	if e != nil {
+		return 0, errors.NewServiceErrorBadValue(e, f)
+	}
+
+	if cap_value < 0 {
+		return 0, errors.NewServiceErrorUnrecognizedValue(f, cap_field)
+	}
+
+	return cap_value, nil
+}
+
+// Per-request caps that size execution buffers may lower the server
+// setting but not raise it; an unbounded server setting is bounded by
+// _MAX_REQUEST_CAP.
+const _MAX_REQUEST_CAP = 65536
+
+func getBoundedCap(a httpRequestArgs, f string, max int64) (int, errors.Error) {
+	cap_value, err := getCap(a, f)
+	if err != nil {
+		return 0, err
+	}
+
+	if max <= 0 {
+		max = _MAX_REQUEST_CAP
+	}
+
+	if int64(cap_value) > max {
+		return 0, errors.NewServiceErrorCapExceeded(f, int64(cap_value), max)
+	}
+
+	return cap_value, nil
+}
+
+func getPriority(a httpRequestArgs) (server.Priority, errors.Error) {
+	priority_field, err := a.getString(PRIORITY, "")
+	if err != nil || priority_field == "" {
+		return server.PRIORITY_INTERACTIVE, err
+	}
+
+	priority, ok := server.ParsePriority(priority_field)
+	if !ok {
+		return priority, errors.NewServiceErrorUnrecognizedValue(PRIORITY, priority_field)
+	}
+
+	return priority, nil
+}
+
+// The durability of mutations; empty if the request does not ask.
+func getDurability(a httpRequestArgs) (datastore.Durability, errors.Error) {
+	durability_field, err := a.getString(DURABILITY, "")
+	if err != nil || durability_field == "" {
+		return "", err
+	}
+
+	durability, ok := datastore.ParseDurability(durability_field)
+	if !ok {
+		return "", errors.NewServiceErrorUnrecognizedValue(DURABILITY, durability_field)
+	}
+
+	return durability, nil
+}
+
 func getReadonly(a httpRequestArgs, isGet bool) (value.Tristate, errors.Error) {
 	readonly, err := a.getTristate(READONLY)
 	if err == nil && isGet {
@@ -513,6 +862,34 @@ type httpRequestArgs interface {
 	getScanVector() (timestamp.Vector, errors.Error)
 }
 
+// sessionArgs is an implementation of httpRequestArgs that falls
+// back on the settings of a session for the arguments missing from
+// the request
+type sessionArgs struct {
+	httpRequestArgs
+	session *session.Session
+}
+
+func (this *sessionArgs) getString(f string, dflt string) (string, errors.Error) {
+	if setting, ok := this.session.Setting(f); ok {
+		dflt = setting
+	}
+	return this.httpRequestArgs.getString(f, dflt)
+}
+
+func (this *sessionArgs) getDuration(f string) (time.Duration, errors.Error) {
+	setting, ok := this.session.Setting(f)
+	if !ok {
+		return this.httpRequestArgs.getDuration(f)
+	}
+
+	duration, err := this.httpRequestArgs.getString(f, setting)
+	if err != nil {
+		return 0, err
+	}
+	return newDuration(duration)
+}
+
 // getRequestParams creates a httpRequestArgs implementation,
 // depending on the content type in the request
 func getRequestParams(req *http.Request) (httpRequestArgs, errors.Error) {
@@ -620,32 +997,20 @@ func (this *urlArgs) getPositionalArgs() (value.Values, errors.Error) {
 }
 
 func (this *urlArgs) getScanVector() (timestamp.Vector, errors.Error) {
-	var full_vector_data []*restArg
-	var sparse_vector_data map[string]*restArg
-
 	scan_vector_data_field, err := this.formValue(SCAN_VECTOR)
 
 	if err != nil || scan_vector_data_field == "" {
 		return nil, err
 	}
+
+	var scan_vector_data interface{}
 	decoder := json.NewDecoder(strings.NewReader(scan_vector_data_field))
-	e := decoder.Decode(&full_vector_data)
-	if e == nil {
-		return makeFullVector(full_vector_data)
-	}
-	decoder = json.NewDecoder(strings.NewReader(scan_vector_data_field))
-	e = decoder.Decode(&sparse_vector_data)
+	e := decoder.Decode(&scan_vector_data)
 	if e != nil {
 		return nil, errors.NewServiceErrorBadValue(e, SCAN_VECTOR)
 	}
-	// Check that the rest data has the expected names
-	for _, arg := range sparse_vector_data {
-		if arg.Value == 0 || arg.Guard == "" {
-			return nil, errors.NewServiceErrorBadValue(e, SCAN_VECTOR)
-		}
-	}
 
-	return makeSparseVector(sparse_vector_data)
+	return makeScanVector(scan_vector_data)
 }
 
 func (this *urlArgs) getDuration(f string) (time.Duration, errors.Error) {
@@ -817,76 +1182,12 @@ func (this *jsonArgs) getCredentials() ([]map[string]string, errors.Error) {
 }
 
 func (this *jsonArgs) getScanVector() (timestamp.Vector, errors.Error) {
-	var type_ok bool
-
 	scan_vector_data_field, in_request := this.getField(SCAN_VECTOR)
 	if !in_request {
 		return nil, nil
 	}
-	full_vector_data, type_ok := scan_vector_data_field.([]interface{})
-	if type_ok {
-		if len(full_vector_data) != SCAN_VECTOR_SIZE {
-			return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR,
-				fmt.Sprintf("array of %d entries", SCAN_VECTOR_SIZE))
-		}
-		entries := make([]timestamp.Entry, len(full_vector_data))
-		for index, arg := range full_vector_data {
-			nextEntry, err := makeVectorEntry(index, arg)
-			if err != nil {
-				return nil, err
-			}
-			entries[index] = nextEntry
-		}
-	}
-	sparse_vector_data, type_ok := scan_vector_data_field.(map[string]interface{})
-	if !type_ok {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	entries := make([]timestamp.Entry, len(sparse_vector_data))
-	i := 0
-	for key, arg := range sparse_vector_data {
-		index, e := strconv.Atoi(key)
-		if e != nil {
-			return nil, errors.NewServiceErrorBadValue(e, SCAN_VECTOR)
-		}
-		nextEntry, err := makeVectorEntry(index, arg)
-		if err != nil {
-			return nil, err
-		}
-		entries[i] = nextEntry
-		i = i + 1
-	}
-	return &scanVectorEntries{
-		entries: entries,
-	}, nil
-}
 
-func makeVectorEntry(index int, args interface{}) (*scanVectorEntry, errors.Error) {
-	data, is_map := args.(map[string]interface{})
-	if !is_map {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	value, has_value := data["value"]
-	if !has_value {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	value_val, is_number := value.(float64)
-	if !is_number {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	guard, has_guard := data["guard"]
-	if !has_guard {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	guard_val, guard_ok := guard.(string)
-	if !guard_ok {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
-	}
-	return &scanVectorEntry{
-		position: uint32(index),
-		value:    uint64(value_val),
-		guard:    guard_val,
-	}, nil
+	return makeScanVector(scan_vector_data_field)
 }
 
 func (this *jsonArgs) getDuration(f string) (time.Duration, errors.Error) {
@@ -973,6 +1274,7 @@ const (
 	XML
 	CSV
 	TSV
+	COLUMNAR
 	UNDEFINED_FORMAT
 )
 
@@ -986,6 +1288,8 @@ func newFormat(s string) Format {
 		return CSV
 	case "TSV":
 		return TSV
+	case "COLUMNAR":
+		return COLUMNAR
 	default:
 		return UNDEFINED_FORMAT
 	}
@@ -1002,6 +1306,8 @@ func (f Format) String() string {
 		s = "CSV"
 	case TSV:
 		s = "TSV"
+	case COLUMNAR:
+		s = "COLUMNAR"
 	default:
 		s = "UNDEFINED_FORMAT"
 	}
@@ -1055,78 +1361,27 @@ func (c Compression) String() string {
 	return s
 }
 
-// scanVectorEntry implements timestamp.Entry
-type scanVectorEntry struct {
-	position uint32
-	value    uint64
-	guard    string
-}
-
-func (this *scanVectorEntry) Position() uint32 {
-	return this.position
-}
-
-func (this *scanVectorEntry) Value() uint64 {
-	return this.value
-}
-
-func (this *scanVectorEntry) Guard() string {
-	return this.guard
-}
-
-// scanVectorEntries implements timestamp.Vector
-type scanVectorEntries struct {
-	entries []timestamp.Entry
-}
-
-func (this *scanVectorEntries) Entries() []timestamp.Entry {
-	return this.entries
-}
-
-// restArg captures how vector data is passed via REST
-type restArg struct {
-	Value uint64 `json:"value"`
-	Guard string `json:"guard"`
-}
-
-// makeFullVector is used when the request includes all entries
-func makeFullVector(args []*restArg) (*scanVectorEntries, errors.Error) {
-	if len(args) != SCAN_VECTOR_SIZE {
-		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR,
-			fmt.Sprintf("array of %d entries", SCAN_VECTOR_SIZE))
-	}
-	entries := make([]timestamp.Entry, len(args))
-	for i, arg := range args {
-		entries[i] = &scanVectorEntry{
-			position: uint32(i),
-			value:    arg.Value,
-			guard:    arg.Guard,
+// makeScanVector accepts either a full vector, as an array with
+// one entry per vbucket, or a sparse vector, as a map of vbuckets
+// to entries
+func makeScanVector(data interface{}) (timestamp.Vector, errors.Error) {
+	switch data := data.(type) {
+	case []interface{}:
+		if len(data) != SCAN_VECTOR_SIZE {
+			return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR,
+				fmt.Sprintf("array of %d entries", SCAN_VECTOR_SIZE))
 		}
+	case map[string]interface{}:
+	default:
+		return nil, errors.NewServiceErrorTypeMismatch(SCAN_VECTOR, "array or map of { number, string }")
 	}
-	return &scanVectorEntries{
-		entries: entries,
-	}, nil
-}
 
-// makeSparseVector is used when the request contains a sparse entry arg
-func makeSparseVector(args map[string]*restArg) (*scanVectorEntries, errors.Error) {
-	entries := make([]timestamp.Entry, len(args))
-	i := 0
-	for key, arg := range args {
-		index, err := strconv.Atoi(key)
-		if err != nil {
-			return nil, errors.NewServiceErrorBadValue(err, SCAN_VECTOR)
-		}
-		entries[i] = &scanVectorEntry{
-			position: uint32(index),
-			value:    arg.Value,
-			guard:    arg.Guard,
-		}
-		i = i + 1
+	vector, e := timestamp.ParseVector(data)
+	if e != nil {
+		return nil, errors.NewServiceErrorBadValue(e, SCAN_VECTOR)
 	}
-	return &scanVectorEntries{
-		entries: entries,
-	}, nil
+
+	return vector, nil
 }
 
 const SCAN_VECTOR_SIZE = 1024
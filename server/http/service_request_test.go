@@ -100,6 +100,342 @@ func TestPrepared(t *testing.T) {
 	doPreparedWithPlan(t, name, prepared.EncodedPlan())
 }
 
+func TestMultipleStatements(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select 1; select 2 as two, 3 as three;",
+	}
+
+	response := doStatements(t, payload)
+	if len(response.Results) != 2 {
+		t.Fatalf("Expected 2 statement results, actual: %v", response.Results)
+	}
+
+	for i, result := range response.Results {
+		if result.Statement != i+1 || result.Status != "success" || len(result.Results) != 1 {
+			t.Errorf("Unexpected result for statement %d: %v", i+1, result)
+		}
+	}
+
+	// The first failing statement stops the request
+	payload["statement"] = "select 1; select * from no_such_keyspace; select 3"
+	response = doStatements(t, payload)
+	if len(response.Results) != 2 || response.Results[1].Status != "errors" {
+		t.Fatalf("Expected the second statement to fail, actual: %v", response.Results)
+	}
+
+	if len(response.Errors) != 1 || response.Errors[0].Code != errors.STATEMENT_FAILED {
+		t.Errorf("Expected error: %v statement failed, actual: %v", errors.STATEMENT_FAILED, response.Errors)
+	}
+}
+
+func TestScriptVariables(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "set $n = 2; select raw $n * $m; set $n = $n + 1; select raw $n * $m",
+		"$m":        10,
+		"$n":        100,
+	}
+
+	response := doStatements(t, payload)
+	if len(response.Results) != 4 {
+		t.Fatalf("Expected 4 statement results, actual: %v", response.Results)
+	}
+
+	for i, expected := range []float64{20, 30} {
+		results := response.Results[2*i+1].Results
+		if len(results) != 1 || results[0] != expected {
+			t.Errorf("Expected %v, actual: %v", expected, results)
+		}
+	}
+}
+
+func TestTasks(t *testing.T) {
+	scheduler := query_server.Scheduler()
+
+	payload := map[string]interface{}{
+		"statement": "update system:tasks set `interval` = '5m' where id = 'purge_requests'",
+	}
+	res, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP request: %v", err)
+	}
+	res.Body.Close()
+
+	entry, _ := scheduler.Entry(server.TASK_PURGE_REQUESTS)
+	if entry["interval"] != "5m0s" || entry["status"] != "scheduled" {
+		t.Errorf("Expected task scheduled every 5m0s, actual: %v", entry)
+	}
+
+	err = scheduler.Trigger(server.TASK_PURGE_REQUESTS)
+	if err != nil {
+		t.Fatalf("Unexpected error triggering task: %v", err)
+	}
+
+	for i := 0; i < 100 && entry["runs"] != int64(1); i++ {
+		time.Sleep(10 * time.Millisecond)
+		entry, _ = scheduler.Entry(server.TASK_PURGE_REQUESTS)
+	}
+
+	if entry["runs"] != int64(1) || entry["result"] != "completed" {
+		t.Errorf("Expected task completed once, actual: %v", entry)
+	}
+}
+
+func TestSyntaxErrors(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select (a +), b\nfrom system:dual where (x = = 1)",
+	}
+	res, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP request: %v", err)
+	}
+	defer res.Body.Close()
+
+	var response struct {
+		Errors []struct {
+			Code   int    `json:"code"`
+			Line   int    `json:"line"`
+			Column int    `json:"column"`
+			Token  string `json:"token"`
+		} `json:"errors"`
+	}
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP response: %v", err)
+	}
+
+	errs := response.Errors
+	if len(errs) != 2 || errs[0].Code != 4100 ||
+		errs[0].Line != 1 || errs[0].Column != 12 || errs[0].Token != ")" ||
+		errs[1].Line != 2 || errs[1].Column != 29 || errs[1].Token != "=" {
+		t.Errorf("Expected 2 syntax errors with positions, actual: %v", errs)
+	}
+}
+
+func TestContinuations(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select raw 1 union all select raw 2 union all select raw 3 " +
+			"union all select raw 4 union all select raw 5",
+		"page_size": "2",
+	}
+
+	sum := 0.0
+	for _, expected := range []int{2, 2, 1} {
+		response := doPage(t, payload)
+		if len(response.Results) != expected {
+			t.Fatalf("Expected %d results, actual: %v", expected, response.Results)
+		}
+
+		for _, result := range response.Results {
+			sum += result.(float64)
+		}
+
+		if expected == 1 {
+			if response.Continuation != "" {
+				t.Errorf("Unexpected continuation after the last page")
+			}
+			break
+		}
+
+		if response.Continuation == "" {
+			t.Fatalf("Expected continuation after page of %d results", expected)
+		}
+
+		payload = map[string]interface{}{
+			"continuation": response.Continuation,
+		}
+	}
+
+	if sum != 15 {
+		t.Errorf("Expected results 1 to 5, actual sum: %v", sum)
+	}
+
+	// The continuation was used up by the last page
+	response := doPage(t, payload)
+	if len(response.Errors) != 1 || response.Errors[0].Code != 1190 {
+		t.Errorf("Expected error: 1190 unknown continuation, actual: %v", response.Errors)
+	}
+}
+
+func TestColumnar(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select 1 as a, \"x\" as b union all select 2 as a, {\"c\": 3} as d",
+		"format":    "columnar",
+	}
+
+	res, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP request: %v", err)
+	}
+	defer res.Body.Close()
+
+	var response struct {
+		Results []*columnarResult `json:"results"`
+	}
+
+	err = json.NewDecoder(res.Body).Decode(&response)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP response: %v", err)
+	}
+
+	if len(response.Results) != 1 {
+		t.Fatalf("Expected 1 batch, actual: %v", response.Results)
+	}
+
+	batch := response.Results[0]
+	actual, _ := json.Marshal(batch)
+	expected := `{"count":2,"columns":["a","b","d"],"types":["number","string","object"],` +
+		`"data":[[1,2],["x",null],[null,{"c":3}]]}`
+	if string(actual) != expected {
+		t.Errorf("Expected batch: %s, actual: %s", expected, actual)
+	}
+}
+
+func TestDryRun(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select raw 1 union all select raw 2",
+		"dry_run":   true,
+	}
+
+	response := doPage(t, payload)
+	if len(response.Results) != 1 {
+		t.Fatalf("Expected 1 result, actual: %v", response.Results)
+	}
+
+	result, _ := response.Results[0].(map[string]interface{})
+	if _, ok := result["plan"].(map[string]interface{}); !ok {
+		t.Errorf("Expected the plan, actual: %v", result)
+	}
+
+	cost, _ := result["cost"].(map[string]interface{})
+	if cost["results"] != 2.0 {
+		t.Errorf("Expected an estimate of 2 results, actual: %v", result["cost"])
+	}
+
+	// Each statement is dry run in turn
+	payload["statement"] = "select 1; select 2"
+	statements := doStatements(t, payload)
+	if len(statements.Results) != 2 {
+		t.Fatalf("Expected 2 statement results, actual: %v", statements.Results)
+	}
+
+	for i, result := range statements.Results {
+		if len(result.Results) != 1 || result.Results[0].(map[string]interface{})["plan"] == nil {
+			t.Errorf("Expected the plan of statement %d, actual: %v", i+1, result)
+		}
+	}
+}
+
+func TestProgress(t *testing.T) {
+	payload := map[string]interface{}{
+		"statement": "select raw 1",
+		"progress":  "1ms",
+	}
+
+	// The result follows the progress events
+	response := doPage(t, payload)
+	if len(response.Results) != 1 || response.Results[0] != 1.0 {
+		t.Errorf("Expected 1 result, actual: %v", response.Results)
+	}
+}
+
+func TestResultLimits(t *testing.T) {
+	statement := "select raw \"abc\" union all select raw \"abc\" union all select raw \"abc\""
+	truncated := func(response *pageResponse, count int) {
+		if len(response.Results) != count {
+			t.Errorf("Expected %d results, actual: %v", count, response.Results)
+		}
+		if len(response.Warnings) != 1 || response.Warnings[0].Code != 1170 {
+			t.Errorf("Expected warning: 1170 results truncated, actual: %v", response.Warnings)
+		}
+	}
+
+	truncated(doPage(t, map[string]interface{}{
+		"statement":        statement,
+		"max_result_count": 2,
+	}), 2)
+
+	// Each result marshals to 5 bytes
+	truncated(doPage(t, map[string]interface{}{
+		"statement":       statement,
+		"max_result_size": 12,
+	}), 2)
+
+	// A request can only lower the server limit
+	query_server.SetMaxResultCount(1)
+	defer query_server.SetMaxResultCount(0)
+	truncated(doPage(t, map[string]interface{}{
+		"statement":        statement,
+		"max_result_count": 3,
+	}), 1)
+
+	response := doPage(t, map[string]interface{}{
+		"statement": statement,
+	})
+	truncated(response, 1)
+
+	query_server.SetMaxResultCount(0)
+	response = doPage(t, map[string]interface{}{
+		"statement": statement,
+	})
+	if len(response.Results) != 3 || len(response.Warnings) != 0 {
+		t.Errorf("Expected 3 results and no warnings, actual: %v %v", response.Results, response.Warnings)
+	}
+}
+
+type pageResponse struct {
+	Results      []interface{} `json:"results"`
+	Continuation string        `json:"continuation"`
+	Errors       []struct {
+		Code int32 `json:"code"`
+	} `json:"errors"`
+	Warnings []struct {
+		Code int32 `json:"code"`
+	} `json:"warnings"`
+}
+
+func doPage(t *testing.T, payload map[string]interface{}) *pageResponse {
+	res, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP request: %v", err)
+	}
+	defer res.Body.Close()
+
+	response := &pageResponse{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP response: %v", err)
+	}
+
+	return response
+}
+
+type statementsResponse struct {
+	Results []struct {
+		Statement int           `json:"statement"`
+		Status    string        `json:"status"`
+		Results   []interface{} `json:"results"`
+	} `json:"results"`
+	Errors []struct {
+		Code int32 `json:"code"`
+	} `json:"errors"`
+}
+
+func doStatements(t *testing.T, payload map[string]interface{}) *statementsResponse {
+	res, err := doJsonEncodedPost(payload)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP request: %v", err)
+	}
+	defer res.Body.Close()
+
+	response := &statementsResponse{}
+	err = json.NewDecoder(res.Body).Decode(response)
+	if err != nil {
+		t.Fatalf("Unexpected error in HTTP response: %v", err)
+	}
+
+	return response
+}
+
 func doNoSuchPrepared(t *testing.T, name string) {
 	payload := map[string]interface{}{
 		"prepared": name,
@@ -187,10 +523,10 @@ func makeMockServer() *server.Server {
 		os.Exit(1)
 	}
 
-	channel := make(server.RequestChannel, 10)
-	plusChannel := make(server.RequestChannel, 10)
+	queue := server.NewRequestQueue(10)
+	plusQueue := server.NewRequestQueue(10)
 	server, err := server.NewServer(datastore, nil, nil, "default",
-		false, channel, plusChannel, 4, 4, 0, 0, false, false, false)
+		false, queue, plusQueue, 4, 4, 0, 0, false, false, false)
 	if err != nil {
 		logging.Errorp(err.Error())
 		os.Exit(1)
@@ -203,15 +539,13 @@ func makeMockServer() *server.Server {
 
 func testHandler() http.Handler {
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		query_request = newHttpRequest(w, r, NewSyncPool(1024), 1024)
+		query_request = newHttpRequest(w, r, NewSyncPool(1024), 1024, nil)
 		if query_request.State() == server.FATAL {
 			return
 		}
-		select {
-		case query_server.Channel() <- query_request:
+		if query_server.Enqueue(query_request) {
 			// Wait until the request exits.
 			<-query_request.CloseNotify()
-		default:
 		}
 	})
 }
@@ -16,6 +16,7 @@ import (
 	"io"
 	"net/http"
 	"strconv"
+	"strings"
 	"sync"
 	"time"
 
@@ -89,14 +90,29 @@ func (this *httpRequest) Execute(srvr *server.Server, signature value.Value, sto
 	defer this.stopAndClose(server.COMPLETED)
 
 	this.NotifyStop(stopNotify)
+	this.maxResultCount = resultLimit(this.maxResultCount, srvr.MaxResultCount())
+	this.maxResultSize = resultLimit(this.maxResultSize, srvr.MaxResultSize())
+
+	if this.format == COLUMNAR {
+		this.columnar = newColumnarBatch(signature)
+	}
 
 	this.setHttpCode(http.StatusOK)
 	_ = this.writePrefix(srvr, signature) &&
-		this.writeResults()
+		this.writeResults() &&
+		this.writeColumnar()
 	this.writeSuffix(srvr.Metrics(), "")
 	this.writer.noMoreData()
 }
 
+// Requests may lower the limits of the server, but not raise them.
+func resultLimit(request, server int) int {
+	if server > 0 && (request <= 0 || request > server) {
+		return server
+	}
+	return request
+}
+
 func (this *httpRequest) Expire() {
 	defer this.stopAndClose(server.TIMEOUT)
 
@@ -126,9 +142,65 @@ func (this *httpRequest) writePrefix(srvr *server.Server, signature value.Value)
 		this.writeRequestID() &&
 		this.writeClientContextID() &&
 		this.writeSignature(srvr.Signature(), signature) &&
+		this.writeProgress() &&
 		this.writeString(",\n    \"results\": [")
 }
 
+/*
+With the progress parameter, the response starts with an array of
+progress events, each holding the counts of the mutations of the
+request so far, written at each interval until the first result or
+the end of the results. Progress events are sent to the client as
+they are written, so that it can follow long-running UPDATE and
+DELETE statements.
+*/
+func (this *httpRequest) writeProgress() bool {
+	if this.progress <= 0 {
+		return true
+	}
+
+	ticker := time.NewTicker(this.progress)
+	defer ticker.Stop()
+
+	if !this.writeString(",\n    \"progress\": [") {
+		return false
+	}
+
+	events := 0
+	for {
+		select {
+		case item, ok := <-this.Results():
+			this.pending, this.drained = item, !ok
+			return this.writeString("\n    ]")
+		case <-this.StopExecute():
+			return this.writeString("\n    ]")
+		case <-ticker.C:
+			event := map[string]interface{}{
+				"elapsedTime": time.Since(this.RequestTime()).String(),
+			}
+			for name, count := range this.Progress().Values() {
+				event[name] = count
+			}
+
+			bytes, err := this.marshal(event, "        ")
+			if err != nil {
+				return false
+			}
+
+			sep := ",\n        "
+			if events == 0 {
+				sep = "\n        "
+			}
+			events++
+
+			if !this.writeString(sep) || !this.writeJSON(bytes) {
+				return false
+			}
+			this.writer.flush()
+		}
+	}
+}
+
 func (this *httpRequest) writeRequestID() bool {
 	return this.writeString(fmt.Sprintf("    \"requestID\": \"%s\"", this.Id().String()))
 }
@@ -153,7 +225,15 @@ func (this *httpRequest) writeSignature(server_flag bool, signature value.Value)
 func (this *httpRequest) writeResults() bool {
 	var item value.Value
 
-	ok := true
+	// The first result may have been received while writing progress
+	ok := !this.drained
+	if this.pending != nil {
+		item, this.pending = this.pending, nil
+		if done, rv := this.writeItem(item); done {
+			return rv
+		}
+	}
+
 	for ok {
 		select {
 		case <-this.StopExecute():
@@ -165,9 +245,8 @@ func (this *httpRequest) writeResults() bool {
 		select {
 		case item, ok = <-this.Results():
 			if ok {
-				if !this.writeResult(item) {
-					this.SetState(server.FATAL)
-					return false
+				if done, rv := this.writeItem(item); done {
+					return rv
 				}
 			}
 		case <-this.StopExecute():
@@ -180,51 +259,120 @@ func (this *httpRequest) writeResults() bool {
 	return true
 }
 
+// Returns true if no more results are to be written, with the
+// outcome of writeResults.
+func (this *httpRequest) writeItem(item value.Value) (bool, bool) {
+	if !this.writeResult(item) {
+		this.SetState(server.FATAL)
+		return true, false
+	}
+
+	// Stop execution; the response completes normally
+	if this.truncated {
+		this.Stop(server.COMPLETED)
+		return true, true
+	}
+
+	return false, false
+}
+
 func (this *httpRequest) writeResult(item value.Value) bool {
-	var rv bool
-	if this.resultCount == 0 {
-		rv = this.writeString("\n")
-	} else {
-		rv = this.writeString(",\n")
+	if this.columnar != nil {
+		return this.addColumnar(item)
 	}
 
-	bytes, err := json.MarshalIndent(item, "        ", "    ")
+	// The count is known before marshalling the item
+	if this.maxResultCount > 0 && this.resultCount >= this.maxResultCount {
+		return this.truncate(MAX_RESULT_COUNT, this.maxResultCount)
+	}
+
+	bytes, err := this.marshal(item, "        ")
 	if err != nil {
 		this.Errors() <- errors.NewServiceErrorInvalidJSON(err)
 		return false
 	}
 
+	if this.maxResultSize > 0 && this.resultSize+len(bytes) > this.maxResultSize {
+		return this.truncate(MAX_RESULT_SIZE, this.maxResultSize)
+	}
+
+	var rv bool
+	if this.resultCount == 0 {
+		rv = this.writeString("\n")
+	} else {
+		rv = this.writeString(",\n")
+	}
+
 	this.resultSize += len(bytes)
 	this.resultCount++
 
 	return rv &&
 		this.writeString("        ") &&
-		this.writeString(string(bytes))
+		this.writeJSON(bytes)
+}
+
+// The item exceeding the limit is not written.
+func (this *httpRequest) truncate(limit string, value int) bool {
+	this.truncated = true
+	this.Warning(errors.NewServiceWarningTruncated(limit, value, this.resultCount))
+	return true
 }
 
 func (this *httpRequest) writeValue(item value.Value) bool {
-	bytes, err := json.MarshalIndent(item, "    ", "    ")
+	bytes, err := this.marshal(item, "    ")
 	if err != nil {
 		s := fmt.Sprintf("\"ERROR: %v\"", err)
-		return this.writeString(s)
+		return this.writeJSON([]byte(s))
 	}
 
-	return this.writeString(string(bytes))
+	return this.writeJSON(bytes)
+}
+
+// Pretty responses are indented; compact responses have no
+// whitespace between JSON tokens.
+func (this *httpRequest) marshal(item interface{}, prefix string) ([]byte, error) {
+	if this.pretty {
+		return json.MarshalIndent(item, prefix, "    ")
+	}
+	return json.Marshal(item)
 }
 
 func (this *httpRequest) writeSuffix(metrics bool, state server.State) bool {
 	return this.writeString("\n    ]") &&
 		this.writeErrors() &&
 		this.writeWarnings() &&
+		this.writeContinuation() &&
 		this.writeState(state) &&
 		this.writeMetrics(metrics) &&
 		this.writeString("\n}\n")
 }
 
+var _COMPACT = strings.NewReplacer("\n", "", "    ", "", "\": ", "\":")
+
+// Writes the layout of the response, which is compacted for compact
+// responses.
 func (this *httpRequest) writeString(s string) bool {
+	if !this.pretty {
+		s = _COMPACT.Replace(s)
+	}
 	return this.writer.writeString(s)
 }
 
+// Writes marshaled JSON as is.
+func (this *httpRequest) writeJSON(bytes []byte) bool {
+	return this.writer.writeString(string(bytes))
+}
+
+// The token that resumes a suspended request.
+func (this *httpRequest) writeContinuation() bool {
+	token := this.NextContinuation()
+	if token == "" {
+		return true
+	}
+
+	return this.writeString(fmt.Sprintf(",\n    \"continuation\": \"%s\"", token))
+}
+
 func (this *httpRequest) writeState(state server.State) bool {
 	if state == "" {
 		state = this.State()
@@ -297,14 +445,27 @@ func (this *httpRequest) writeError(err errors.Error, count int) bool {
 		"code": err.Code(),
 		"msg":  err.Error(),
 	}
-	bytes, er := json.MarshalIndent(m, "        ", "    ")
+	if perr, ok := err.(errors.ParseError); ok {
+		m["line"] = perr.Line()
+		m["column"] = perr.Column()
+		m["token"] = perr.Token()
+	}
+	if eerr, ok := err.(errors.EvaluationContextError); ok {
+		if eerr.Expression() != "" {
+			m["expression"] = eerr.Expression()
+		}
+		if eerr.Key() != "" {
+			m["document_key"] = eerr.Key()
+		}
+	}
+	bytes, er := this.marshal(m, "        ")
 	if er != nil {
 		return false
 	}
 
 	return rv &&
 		this.writeString("        ") &&
-		this.writeString(string(bytes))
+		this.writeJSON(bytes)
 }
 
 func (this *httpRequest) writeMetrics(metrics bool) bool {
@@ -326,6 +487,14 @@ func (this *httpRequest) writeMetrics(metrics bool) bool {
 		rv = rv && this.writeString(fmt.Sprintf(",\n        \"mutationCount\": %d", this.MutationCount()))
 	}
 
+	if retried := this.Progress().Retried(); retried > 0 {
+		rv = rv && this.writeString(fmt.Sprintf(",\n        \"mutationRetries\": %d", retried))
+	}
+
+	if durability := this.AchievedDurability(); durability != "" {
+		rv = rv && this.writeString(fmt.Sprintf(",\n        \"durability\": \"%s\"", durability))
+	}
+
 	if this.SortCount() > 0 {
 		rv = rv && this.writeString(fmt.Sprintf(",\n        \"sortCount\": %d", this.SortCount()))
 	}
@@ -345,6 +514,7 @@ func (this *httpRequest) writeMetrics(metrics bool) bool {
 // the data in a response.
 type responseDataManager interface {
 	writeString(string) bool // write the given string for the response
+	flush()                  // send the data written so far to the client
 	noMoreData()             // action to take when there is no more data for the response
 }
 
@@ -393,6 +563,24 @@ func (this *bufferedWriter) writeString(s string) bool {
 	return err == nil
 }
 
+// Send the buffered data, and switch to writing directly.
+func (this *bufferedWriter) flush() {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.closed {
+		return
+	}
+
+	w := this.req.resp
+	w.WriteHeader(this.req.httpCode())
+	io.Copy(w, this.buffer)
+	w.(http.Flusher).Flush()
+	this.req.writer = NewDirectWriter(this.req)
+	this.buffer_pool.PutBuffer(this.buffer)
+	this.closed = true
+}
+
 func (this *bufferedWriter) noMoreData() {
 	this.Lock()
 	defer this.Unlock()
@@ -444,6 +632,10 @@ func (this *directWriter) writeString(s string) bool {
 	return err == nil
 }
 
+// Direct writes are flushed as they are written.
+func (this *directWriter) flush() {
+}
+
 func (this *directWriter) noMoreData() {
 	this.Lock()
 	defer this.Unlock()
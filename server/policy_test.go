@@ -0,0 +1,144 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/expression/parser"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/value"
+)
+
+func TestPolicyPrepared(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"n": 1}`,
+		"o2": `{"n": 2}`,
+	})
+	defer os.RemoveAll(dir)
+
+	// Without users, only the admin role is exempt from policies
+	admin := []datastore.Role{{Name: datastore.ROLE_ADMIN}}
+	run := func(statement string, prepared *plan.Prepared, roles []datastore.Role) []value.Value {
+		var metrics value.Tristate
+		request := &testRequest{
+			BaseRequest: *NewBaseRequest(statement, prepared, nil, nil, "default", 0, value.NONE,
+				metrics, value.FALSE, nil, "", nil),
+			done: make(chan bool),
+		}
+		request.SetRoles(roles)
+		srvr.serviceRequest(request)
+		if errs := request.wait(); len(errs) > 0 {
+			t.Fatalf("Unexpected errors for %s: %v", statement, errs)
+		}
+		return request.results
+	}
+
+	// The plan is prepared before the policy is created
+	run("PREPARE p FROM SELECT RAW n FROM orders ORDER BY n", nil, admin)
+
+	pred, err := parser.Parse("n = 1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.Add("default", "orders", pred)
+	defer policy.Remove("default", "orders")
+
+	cached, er := plan.GetPrepared(value.NewValue("p"))
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	decoded, er := plan.DecodePrepared(cached.EncodedPlan())
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	for _, test := range []struct {
+		statement string
+		prepared  *plan.Prepared
+	}{
+		{"EXECUTE p", nil},
+		{"", cached},
+		{"", decoded},
+	} {
+		results := run(test.statement, test.prepared, nil)
+		if len(results) != 1 || results[0].Actual() != 1.0 {
+			t.Errorf("Expected the policy to restrict %v to 1 result, got %v", test, results)
+		}
+
+		results = run(test.statement, test.prepared, admin)
+		if len(results) != 2 {
+			t.Errorf("Expected %v to be unrestricted for the admin, got %v", test, results)
+		}
+	}
+}
+
+func TestPolicyPreparedMasks(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"ssn": "123-45-6789"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	run := func(prepared *plan.Prepared) []value.Value {
+		var metrics value.Tristate
+		request := &testRequest{
+			BaseRequest: *NewBaseRequest("", prepared, nil, nil, "default", 0, value.NONE,
+				metrics, value.FALSE, nil, "", nil),
+			done: make(chan bool),
+		}
+		srvr.serviceRequest(request)
+		if errs := request.wait(); len(errs) > 0 {
+			t.Fatalf("Unexpected errors: %v", errs)
+		}
+		return request.results
+	}
+
+	// The plan is prepared before the mask is created
+	request := newTestRequest("PREPARE m FROM SELECT RAW ssn FROM orders", nil)
+	request.SetRoles([]datastore.Role{{Name: datastore.ROLE_ADMIN}})
+	srvr.serviceRequest(request)
+	if errs := request.wait(); len(errs) > 0 {
+		t.Fatalf("Unexpected errors: %v", errs)
+	}
+
+	ssn, err := parser.Parse("ssn")
+	if err != nil {
+		t.Fatal(err)
+	}
+	policy.AddMask("default", "orders", ssn.(expression.Path), "full")
+	defer policy.RemoveMask("default", "orders", ssn.(expression.Path))
+
+	cached, er := plan.GetPrepared(value.NewValue("m"))
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	decoded, er := plan.DecodePrepared(cached.EncodedPlan())
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	for _, prepared := range []*plan.Prepared{cached, decoded} {
+		results := run(prepared)
+		if len(results) != 1 || results[0].Actual() == "123-45-6789" {
+			t.Errorf("Expected the masked value, got %v", results)
+		}
+	}
+
+	results, errs := runTestRequest(srvr, "EXECUTE m", nil)
+	if len(errs) > 0 || len(results) != 1 || results[0].Actual() == "123-45-6789" {
+		t.Errorf("Expected the masked value, got %v and %v", results, errs)
+	}
+}
@@ -0,0 +1,54 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/quota"
+)
+
+func TestQuotaScans(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"status": "pending"}`,
+		"o2": `{"status": "pending"}`,
+		"o3": `{"status": "shipped"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	if _, errs := runTestRequest(srvr, "CREATE INDEX ix ON orders(status)", nil); len(errs) > 0 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+
+	quota.Set(quota.KEYSPACE, "default:orders", quota.Limits{Documents: 2})
+	defer quota.Remove(quota.KEYSPACE, "default:orders")
+
+	tests := []struct {
+		stmt     string
+		exceeded bool
+	}{
+		// Documents scanned and then fetched are charged once
+		{"SELECT * FROM orders WHERE status = \"pending\"", false},
+		{"SELECT * FROM orders WHERE status > \"\"", true},
+		{"SELECT status FROM orders WHERE status = \"pending\"", false},
+		{"SELECT status FROM orders WHERE status > \"\"", true},
+		{"SELECT META().id FROM orders WHERE status > \"\"", true},
+		{"SELECT COUNT(*) FROM orders", true},
+	}
+
+	for _, test := range tests {
+		_, errs := runTestRequest(srvr, test.stmt, nil)
+		exceeded := len(errs) == 1 && errs[0].Code() == 5200
+		if exceeded != test.exceeded || (!exceeded && len(errs) > 0) {
+			t.Errorf("Expected exceeded %v for %s, got %v", test.exceeded, test.stmt, errs)
+		}
+	}
+}
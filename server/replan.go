@@ -0,0 +1,166 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/value"
+)
+
+/*
+replanOutput sits between the execution pipeline and the request
+output. Errors caused by an index being dropped or taken offline
+while the request runs are held back, as long as no results have
+been returned yet, so that the server can re-plan the statement
+and run it again instead of failing the request.
+*/
+type replanOutput struct {
+	execution.Output
+	sync.Mutex
+	attempts int          // Remaining re-plan attempts
+	results  bool         // Results have been returned to the request
+	stopped  bool         // The request has been stopped
+	pending  errors.Error // Error held back for a re-plan
+	stop     chan bool    // Stop notification from the request
+}
+
+func newReplanOutput(output execution.Output, attempts int) *replanOutput {
+	return &replanOutput{
+		Output:   output,
+		attempts: attempts,
+		stop:     make(chan bool, 1),
+	}
+}
+
+func isReplanError(err errors.Error) bool {
+	switch err.Code() {
+	case errors.INDEX_NOT_FOUND, errors.INDEX_NOT_ONLINE:
+		return true
+	default:
+		return false
+	}
+}
+
+func (this *replanOutput) Result(item value.Value) bool {
+	this.Lock()
+	this.results = true
+	this.Unlock()
+
+	return this.Output.Result(item)
+}
+
+func (this *replanOutput) CloseResults() {
+	this.Lock()
+	pending := this.pending != nil
+	this.Unlock()
+
+	if !pending {
+		this.Output.CloseResults()
+	}
+}
+
+func (this *replanOutput) Error(err errors.Error) {
+	if !this.holdBack(err) {
+		this.Output.Error(err)
+	}
+}
+
+func (this *replanOutput) Fatal(err errors.Error) {
+	if !this.holdBack(err) {
+		this.Output.Fatal(err)
+	}
+}
+
+func (this *replanOutput) holdBack(err errors.Error) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	if isReplanError(err) && !this.results && !this.stopped {
+		if this.pending != nil {
+			return true
+		}
+
+		if this.attempts > 0 {
+			this.pending = err
+			return true
+		}
+	}
+
+	// Any other error makes the run final
+	this.attempts = 0
+	return false
+}
+
+// Run the pipeline, forwarding stop notifications from the request.
+func (this *replanOutput) run(operator execution.Operator, context *execution.Context) {
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		select {
+		case <-this.stop:
+			this.Lock()
+			this.stopped = true
+			this.Unlock()
+
+			select {
+			case operator.StopChannel() <- false:
+			default:
+			}
+		case <-done:
+		}
+	}()
+
+	operator.RunOnce(context, nil)
+}
+
+// Returns true if the run ended on a held back error and the
+// statement should be re-planned and run again.
+func (this *replanOutput) retry() bool {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.pending == nil {
+		return false
+	}
+
+	if this.attempts <= 0 || this.results || this.stopped {
+		this.release()
+		return false
+	}
+
+	this.attempts--
+	this.pending = nil
+	return true
+}
+
+// Give up on re-planning, and pass on the error that caused it.
+func (this *replanOutput) fail(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.attempts = 0
+	if err != nil {
+		this.Output.Error(err)
+	}
+	this.release()
+}
+
+func (this *replanOutput) release() {
+	pending := this.pending
+	this.pending = nil
+	if pending != nil {
+		this.Output.Error(pending)
+	}
+	this.Output.CloseResults()
+}
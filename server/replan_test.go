@@ -0,0 +1,114 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/plan"
+)
+
+func TestReplan(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"n": 1}`,
+		"o2": `{"n": 2}`,
+	})
+	defer os.RemoveAll(dir)
+
+	srvr.SetReplanAttempts(2)
+
+	// Drop the index after the statement is planned, before it runs
+	drop := ""
+	srvr.AddGuard(GuardFunc(func(request Request, prepared *plan.Prepared) errors.Error {
+		if drop == "" || !strings.HasPrefix(request.Statement(), drop) {
+			return nil
+		}
+
+		drop = ""
+		ns, _ := srvr.Datastore().NamespaceByName("default")
+		ks, _ := ns.KeyspaceByName("orders")
+		indexer, _ := ks.Indexer(datastore.DEFAULT)
+		index, err := indexer.IndexByName("ix")
+		if err != nil {
+			t.Fatal(err)
+		}
+		return index.Drop("")
+	}))
+
+	createIndex := func() {
+		if _, errs := runTestRequest(srvr, "CREATE INDEX ix ON orders(n)", nil); len(errs) > 0 {
+			t.Fatalf("Unexpected errors %v", errs)
+		}
+	}
+
+	// Read-only statements are re-planned
+	createIndex()
+	drop = "SELECT"
+	results, errs := runTestRequest(srvr, "SELECT n FROM orders WHERE n > 0", nil)
+	if len(errs) > 0 || len(results) != 2 {
+		t.Errorf("Expected 2 results without errors, got %v and %v", results, errs)
+	}
+
+	// Mutations are not, as they may have applied before failing
+	createIndex()
+	drop = "UPDATE"
+	_, errs = runTestRequest(srvr, "UPDATE orders SET n = n + 1 WHERE n > 0", nil)
+	if len(errs) != 1 || errs[0].Code() != errors.INDEX_NOT_ONLINE {
+		t.Errorf("Expected the UPDATE to fail for want of the index, got %v", errs)
+	}
+
+	results, errs = runTestRequest(srvr, "SELECT RAW n FROM orders ORDER BY n", nil)
+	if len(errs) > 0 || len(results) != 2 || results[0].Actual() != 1.0 || results[1].Actual() != 2.0 {
+		t.Errorf("Expected the documents not to be updated, got %v and %v", results, errs)
+	}
+}
+
+func TestReplanOutput(t *testing.T) {
+	request := newTestRequest("SELECT 1", nil)
+	notOnline := errors.NewIndexNotOnlineError("ix", "offline")
+
+	// Errors are held back for as many attempts as allowed
+	replan := newReplanOutput(request, 1)
+	replan.Error(notOnline)
+	if !replan.retry() {
+		t.Errorf("Expected a retry")
+	}
+
+	replan.Error(notOnline)
+	if replan.retry() {
+		t.Errorf("Expected no retry after the last attempt")
+	}
+
+	// Errors after results are passed on
+	request = newTestRequest("SELECT 1", nil)
+	replan = newReplanOutput(request, 1)
+	go func() {
+		for range request.Results() {
+		}
+	}()
+	replan.Result(nil)
+	replan.Error(notOnline)
+	if replan.retry() {
+		t.Errorf("Expected no retry after results")
+	}
+
+	select {
+	case err := <-request.Errors():
+		if err != notOnline {
+			t.Errorf("Expected %v, got %v", notOnline, err)
+		}
+	default:
+		t.Errorf("Expected the error to be passed on")
+	}
+}
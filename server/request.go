@@ -16,10 +16,13 @@ import (
 
 	atomic "github.com/couchbase/go-couchbase/platform"
 	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/temp"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/feature"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/session"
 	"github.com/couchbase/query/timestamp"
 	"github.com/couchbase/query/util"
 	"github.com/couchbase/query/value"
@@ -51,7 +54,24 @@ type Request interface {
 	Namespace() string
 	Timeout() time.Duration
 	MaxParallelism() int
+	ScanCap() int64
+	PipelineCap() int64
+	PipelineBatch() int
+	MutationBatch() int
+	Features() feature.Flags
+	Seed() (int64, bool)
+	StrictTypes() bool
+	StrictCast() bool
+	MissingOrder() (value.MissingOrder, bool)
+	MissingWarnings() value.Tristate
+	Durability() datastore.Durability
+	CasRetries() int
 	Readonly() value.Tristate
+	Priority() Priority
+	UseCache() value.Tristate
+	AllowFullScan() bool
+	DryRun() bool
+	Progress() *execution.Progress
 	Metrics() value.Tristate
 	Signature() value.Tristate
 	ScanConsistency() datastore.ScanConsistency
@@ -67,6 +87,14 @@ type Request interface {
 	Expire()
 	State() State
 	Credentials() datastore.Credentials
+	Session() *session.Session
+	Temps() *temp.Keyspaces
+	Roles() []datastore.Role
+	AuthScheme() string
+	PageSize() int
+	Continuation() string
+	SetNextContinuation(token string)
+	Cancel()
 }
 
 type RequestID interface {
@@ -110,11 +138,38 @@ type BaseRequest struct {
 	namespace      string
 	timeout        time.Duration
 	maxParallelism int
+	scanCap        int64
+	pipelineCap    int64
+	pipelineBatch  int
+	mutationBatch  int
+	features       feature.Flags
+	seed           int64
+	seeded         bool
+	strictTypes    bool
+	strictCast     bool
+	missingOrder   value.MissingOrder
+	hasMissing     bool
+	missingWarn    value.Tristate
+	durability     datastore.Durability
+	achieved       datastore.Durability
+	casRetries     int
 	readonly       value.Tristate
+	useCache       value.Tristate
+	allowFullScan  bool
+	dryRun         bool
+	progress       *execution.Progress
+	priority       Priority
 	signature      value.Tristate
 	metrics        value.Tristate
 	consistency    ScanConfiguration
 	credentials    datastore.Credentials
+	session        *session.Session
+	temps          *temp.Keyspaces
+	roles          []datastore.Role
+	authScheme     string
+	pageSize       int
+	continuation   string
+	next           string
 	phaseTimes     map[string]time.Duration
 	requestTime    time.Time
 	serviceTime    time.Time
@@ -168,14 +223,17 @@ func NewBaseRequest(statement string, prepared *plan.Prepared, namedArgs map[str
 		metrics:        metrics,
 		consistency:    consistency,
 		credentials:    creds,
+		temps:          temp.NewKeyspaces(),
 		requestTime:    time.Now(),
 		serviceTime:    time.Now(),
+		priority:       PRIORITY_INTERACTIVE,
 		state:          RUNNING,
 		errors:         make(errors.ErrorChannel, _ERROR_CAP),
 		warnings:       make(errors.ErrorChannel, _ERROR_CAP),
 		closeNotify:    make(chan bool, 1),
 		stopResult:     make(chan bool, 1),
 		stopExecute:    make(chan bool, 1),
+		progress:       execution.NewProgress(),
 	}
 
 	if maxParallelism <= 0 {
@@ -243,6 +301,158 @@ func (this *BaseRequest) Readonly() value.Tristate {
 	return this.readonly
 }
 
+// Per-request overrides of the server settings; zero means use the
+// server setting.
+
+func (this *BaseRequest) ScanCap() int64 {
+	return this.scanCap
+}
+
+func (this *BaseRequest) SetScanCap(scanCap int64) {
+	this.scanCap = scanCap
+}
+
+func (this *BaseRequest) PipelineCap() int64 {
+	return this.pipelineCap
+}
+
+func (this *BaseRequest) SetPipelineCap(pipelineCap int64) {
+	this.pipelineCap = pipelineCap
+}
+
+func (this *BaseRequest) PipelineBatch() int {
+	return this.pipelineBatch
+}
+
+func (this *BaseRequest) SetPipelineBatch(pipelineBatch int) {
+	this.pipelineBatch = pipelineBatch
+}
+
+func (this *BaseRequest) MutationBatch() int {
+	return this.mutationBatch
+}
+
+func (this *BaseRequest) SetMutationBatch(mutationBatch int) {
+	this.mutationBatch = mutationBatch
+}
+
+func (this *BaseRequest) Features() feature.Flags {
+	return this.features
+}
+
+func (this *BaseRequest) SetFeatures(features feature.Flags) {
+	this.features = features
+}
+
+// The seed of deterministic evaluation, if the request has one; see
+// execution.Context.SetSeed().
+func (this *BaseRequest) Seed() (int64, bool) {
+	return this.seed, this.seeded
+}
+
+func (this *BaseRequest) SetSeed(seed int64) {
+	this.seed = seed
+	this.seeded = true
+}
+
+// Whether statements are type checked when prepared; see
+// algebra.TypeCheck().
+func (this *BaseRequest) StrictTypes() bool {
+	return this.strictTypes
+}
+
+func (this *BaseRequest) SetStrictTypes(strictTypes bool) {
+	this.strictTypes = strictTypes
+}
+
+// Whether an invalid CAST is an error rather than NULL.
+func (this *BaseRequest) StrictCast() bool {
+	return this.strictCast
+}
+
+func (this *BaseRequest) SetStrictCast(strictCast bool) {
+	this.strictCast = strictCast
+}
+
+// The MISSING order of ORDER BY, if the request has one; otherwise
+// the server's applies.
+func (this *BaseRequest) MissingOrder() (value.MissingOrder, bool) {
+	return this.missingOrder, this.hasMissing
+}
+
+func (this *BaseRequest) SetMissingOrder(order value.MissingOrder) {
+	this.missingOrder = order
+	this.hasMissing = true
+}
+
+func (this *BaseRequest) MissingWarnings() value.Tristate {
+	return this.missingWarn
+}
+
+func (this *BaseRequest) SetMissingWarnings(warnings value.Tristate) {
+	this.missingWarn = warnings
+}
+
+// How durable mutations are to be made; empty if the request does
+// not ask.
+func (this *BaseRequest) Durability() datastore.Durability {
+	return this.durability
+}
+
+func (this *BaseRequest) SetDurability(durability datastore.Durability) {
+	this.durability = durability
+}
+
+// How many times updates of documents modified concurrently are
+// retried.
+func (this *BaseRequest) CasRetries() int {
+	return this.casRetries
+}
+
+func (this *BaseRequest) SetCasRetries(retries int) {
+	this.casRetries = retries
+}
+
+func (this *BaseRequest) SetPriority(priority Priority) {
+	this.priority = priority
+}
+
+func (this *BaseRequest) Priority() Priority {
+	return this.priority
+}
+
+// Set to value.FALSE to bypass the result cache.
+func (this *BaseRequest) SetUseCache(useCache value.Tristate) {
+	this.useCache = useCache
+}
+
+func (this *BaseRequest) UseCache() value.Tristate {
+	return this.useCache
+}
+
+// Set to true to run statements that scan large keyspaces in full.
+func (this *BaseRequest) SetAllowFullScan(allow bool) {
+	this.allowFullScan = allow
+}
+
+func (this *BaseRequest) AllowFullScan() bool {
+	return this.allowFullScan
+}
+
+// Set to true to plan and check statements without executing them.
+func (this *BaseRequest) SetDryRun(dryRun bool) {
+	this.dryRun = dryRun
+}
+
+func (this *BaseRequest) DryRun() bool {
+	return this.dryRun
+}
+
+// The progress of the mutations of the request.
+func (this *BaseRequest) Progress() *execution.Progress {
+	return this.progress
+}
+
 func (this *BaseRequest) Signature() value.Tristate {
 	return this.signature
 }
@@ -289,6 +499,76 @@ func (this *BaseRequest) Credentials() datastore.Credentials {
 	return this.credentials
 }
 
+func (this *BaseRequest) Session() *session.Session {
+	return this.session
+}
+
+func (this *BaseRequest) SetSession(session *session.Session) {
+	this.session = session
+}
+
+// The temporary keyspaces of the request. Those of a session last as
+// long as the session; otherwise they last as long as the request.
+func (this *BaseRequest) Temps() *temp.Keyspaces {
+	if this.session != nil {
+		return this.session.Temps()
+	}
+
+	return this.temps
+}
+
+// Roles granted by the authenticator of the request.
+func (this *BaseRequest) Roles() []datastore.Role {
+	return this.roles
+}
+
+func (this *BaseRequest) SetRoles(roles []datastore.Role) {
+	this.roles = roles
+}
+
+// Scheme of the authenticator of the request; empty if the request
+// passed its credentials without one.
+func (this *BaseRequest) AuthScheme() string {
+	return this.authScheme
+}
+
+func (this *BaseRequest) SetAuthScheme(scheme string) {
+	this.authScheme = scheme
+}
+
+// Number of results after which the request is suspended; 0 if
+// results are not paged.
+func (this *BaseRequest) PageSize() int {
+	return this.pageSize
+}
+
+func (this *BaseRequest) SetPageSize(pageSize int) {
+	this.pageSize = pageSize
+}
+
+// Token of the suspended request that this request resumes.
+func (this *BaseRequest) Continuation() string {
+	return this.continuation
+}
+
+func (this *BaseRequest) SetContinuation(token string) {
+	this.continuation = token
+}
+
+// Token that resumes the request after its last result, if it was
+// suspended.
+func (this *BaseRequest) NextContinuation() string {
+	this.RLock()
+	defer this.RUnlock()
+	return this.next
+}
+
+func (this *BaseRequest) SetNextContinuation(token string) {
+	this.Lock()
+	defer this.Unlock()
+	this.next = token
+}
+
 func (this *BaseRequest) CloseNotify() chan bool {
 	return this.closeNotify
 }
@@ -344,6 +624,19 @@ func (this *BaseRequest) MutationCount() uint64 {
 	return atomic.LoadUint64(&this.mutationCount)
 }
 
+// The weakest durability achieved by the mutations of the request.
+func (this *BaseRequest) AchieveDurability(durability datastore.Durability) {
+	this.Lock()
+	defer this.Unlock()
+	this.achieved = datastore.WeakerDurability(this.achieved, durability)
+}
+
+func (this *BaseRequest) AchievedDurability() datastore.Durability {
+	this.RLock()
+	defer this.RUnlock()
+	return this.achieved
+}
+
 func (this *BaseRequest) SetSortCount(i uint64) {
 	atomic.StoreUint64(&this.sortCount, i)
 }
@@ -402,6 +695,12 @@ func (this *BaseRequest) Stop(state State) {
 	this.SetState(state)
 }
 
+// Stop the request; its caller receives a request cancelled error.
+func (this *BaseRequest) Cancel() {
+	this.Error(errors.NewServiceErrorCancelled(this.id.String()))
+	this.Stop(STOPPED)
+}
+
 func (this *BaseRequest) Close() {
 	sendStop(this.closeNotify)
 }
@@ -0,0 +1,134 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"strings"
+
+	atomic "github.com/couchbase/go-couchbase/platform"
+)
+
+type Priority int
+
+const (
+	PRIORITY_BATCH Priority = iota
+	PRIORITY_INTERACTIVE
+	PRIORITY_ADMIN
+	_PRIORITIES
+)
+
+var _PRIORITY_NAMES = [_PRIORITIES]string{
+	PRIORITY_BATCH:       "batch",
+	PRIORITY_INTERACTIVE: "interactive",
+	PRIORITY_ADMIN:       "admin",
+}
+
+func (this Priority) String() string {
+	if this < 0 || this >= _PRIORITIES {
+		return "unknown"
+	}
+
+	return _PRIORITY_NAMES[this]
+}
+
+func ParsePriority(name string) (Priority, bool) {
+	for p, n := range _PRIORITY_NAMES {
+		if strings.EqualFold(name, n) {
+			return Priority(p), true
+		}
+	}
+
+	return PRIORITY_INTERACTIVE, false
+}
+
+/*
+RequestQueue holds the requests waiting for a servicer. It is
+bounded: once capacity requests are waiting, further requests
+are rejected. Servicers always take the waiting request of the
+highest priority, so that admin requests overtake interactive
+ones, and interactive requests overtake batch ones.
+*/
+type RequestQueue struct {
+	// Aligned ints need to be delared right at the top
+	// of the struct to avoid alignment issues on x86 platforms
+	queued atomic.AlignedInt64
+
+	capacity int64
+	queues   [_PRIORITIES]RequestChannel
+}
+
+func NewRequestQueue(capacity int) *RequestQueue {
+	rv := &RequestQueue{
+		capacity: int64(capacity),
+	}
+
+	// Each queue can hold the whole capacity, so that
+	// enqueueing never blocks once the bound is checked
+	for p, _ := range rv.queues {
+		rv.queues[p] = make(RequestChannel, capacity)
+	}
+
+	return rv
+}
+
+func (this *RequestQueue) Capacity() int {
+	return int(this.capacity)
+}
+
+// Number of waiting requests.
+func (this *RequestQueue) Size() int {
+	return int(atomic.LoadInt64(&this.queued))
+}
+
+// Number of waiting requests of the given priority.
+func (this *RequestQueue) Length(priority Priority) int {
+	return len(this.queues[priority])
+}
+
+// Returns false if the queue is full.
+func (this *RequestQueue) Enqueue(request Request) bool {
+	if atomic.AddInt64(&this.queued, 1) > this.capacity {
+		atomic.AddInt64(&this.queued, -1)
+		return false
+	}
+
+	priority := request.Priority()
+	if priority < 0 || priority >= _PRIORITIES {
+		priority = PRIORITY_INTERACTIVE
+	}
+
+	this.queues[priority] <- request
+	return true
+}
+
+// Wait for the highest priority request; returns false if done
+// is closed first.
+func (this *RequestQueue) dequeue(done chan bool) (Request, bool) {
+	for p := _PRIORITIES - 1; p > PRIORITY_BATCH; p-- {
+		select {
+		case request := <-this.queues[p]:
+			atomic.AddInt64(&this.queued, -1)
+			return request, true
+		default:
+		}
+	}
+
+	var request Request
+	select {
+	case request = <-this.queues[PRIORITY_ADMIN]:
+	case request = <-this.queues[PRIORITY_INTERACTIVE]:
+	case request = <-this.queues[PRIORITY_BATCH]:
+	case <-done:
+		return nil, false
+	}
+
+	atomic.AddInt64(&this.queued, -1)
+	return request, true
+}
@@ -0,0 +1,430 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/expression"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+// Maximum number of result rows kept for a single statement
+const RESULT_CACHE_MAX_ROWS = 1024
+
+/*
+ResultCache keeps the results of read-only statements, keyed by
+statement, namespace, arguments, credentials and scan vector. An
+entry is invalidated when its TTL expires, or when any keyspace
+read by the statement has been mutated since the entry was filled.
+All entries are invalidated when the default namespace or the search
+path changes.
+*/
+type ResultCache struct {
+	sync.Mutex
+	entries   map[string]*resultCacheEntry
+	limit     int
+	ttl       time.Duration
+	acctstore accounting.AccountingStore
+}
+
+type resultCacheEntry struct {
+	signature  value.Value
+	results    value.Values
+	keyspaces  map[string]uint64
+	privileges datastore.Privileges
+	expires    time.Time
+}
+
+/*
+Privileges may be revoked after an entry is filled, so a request is
+authorized as the Authorize operator of the plan would before it is
+served from the entry.
+*/
+func (this *resultCacheEntry) authorized(request Request) bool {
+	if len(this.privileges) == 0 {
+		return true
+	}
+
+	// Roles granted by the authenticator take precedence
+	if _, ok := datastore.RolesGrant(request.Roles(), this.privileges); ok {
+		return true
+	}
+
+	ds := datastore.GetDatastore()
+	return ds == nil || ds.Authorize(this.privileges, request.Credentials()) == nil
+}
+
+// Returns the privileges authorized at the top of a plan.
+func planPrivileges(op plan.Operator) datastore.Privileges {
+	switch op := op.(type) {
+	case *plan.Prepared:
+		return planPrivileges(op.Operator)
+	case *plan.Sequence:
+		for _, child := range op.Children() {
+			if privs := planPrivileges(child); privs != nil {
+				return privs
+			}
+		}
+	case *plan.Authorize:
+		return op.Privileges()
+	}
+
+	return nil
+}
+
+func newResultCache(acctstore accounting.AccountingStore) *ResultCache {
+	return &ResultCache{
+		entries:   make(map[string]*resultCacheEntry),
+		acctstore: acctstore,
+	}
+}
+
+func (this *ResultCache) Limit() int {
+	this.Lock()
+	defer this.Unlock()
+	return this.limit
+}
+
+// Maximum number of cached statements; zero disables the cache.
+func (this *ResultCache) SetLimit(limit int) {
+	this.Lock()
+	defer this.Unlock()
+
+	if limit < 0 {
+		limit = 0
+	}
+
+	this.limit = limit
+	for key, _ := range this.entries {
+		if len(this.entries) <= limit {
+			break
+		}
+		this.evict(key)
+	}
+}
+
+func (this *ResultCache) TTL() time.Duration {
+	this.Lock()
+	defer this.Unlock()
+	return this.ttl
+}
+
+func (this *ResultCache) SetTTL(ttl time.Duration) {
+	this.Lock()
+	defer this.Unlock()
+	this.ttl = ttl
+}
+
+func (this *ResultCache) Enabled() bool {
+	return this.Limit() > 0
+}
+
+func (this *ResultCache) Size() int {
+	this.Lock()
+	defer this.Unlock()
+	return len(this.entries)
+}
+
+func (this *ResultCache) get(key string) *resultCacheEntry {
+	this.Lock()
+	defer this.Unlock()
+
+	entry, ok := this.entries[key]
+	if ok && (time.Now().After(entry.expires) || execution.KeyspacesChanged(entry.keyspaces)) {
+		this.evict(key)
+		ok = false
+	}
+
+	if ok {
+		this.count(accounting.RESULT_CACHE_HITS, 1)
+		return entry
+	}
+
+	this.count(accounting.RESULT_CACHE_MISSES, 1)
+	return nil
+}
+
+func (this *ResultCache) put(key string, entry *resultCacheEntry) {
+	for k, _ := range entry.keyspaces {
+		// System keyspaces change without mutations
+		if strings.HasPrefix(k, "#system:") {
+			return
+		}
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if this.limit <= 0 {
+		return
+	}
+
+	if _, ok := this.entries[key]; !ok {
+		if len(this.entries) >= this.limit {
+			this.evictOne()
+		}
+		this.count(accounting.RESULT_CACHE_ENTRIES, 1)
+	}
+
+	entry.expires = time.Now().Add(this.ttl)
+	this.entries[key] = entry
+}
+
+// Evict an expired entry if there is one, or else any entry.
+func (this *ResultCache) evictOne() {
+	now := time.Now()
+	victim := ""
+	for key, entry := range this.entries {
+		victim = key
+		if now.After(entry.expires) {
+			break
+		}
+	}
+
+	if victim != "" {
+		this.evict(victim)
+	}
+}
+
+// Evict all expired entries. Returns the number evicted.
+func (this *ResultCache) purge() int {
+	this.Lock()
+	defer this.Unlock()
+
+	now := time.Now()
+	n := 0
+	for key, entry := range this.entries {
+		if now.After(entry.expires) {
+			this.evict(key)
+			n++
+		}
+	}
+
+	return n
+}
+
+// Evict all entries.
+func (this *ResultCache) flush() {
+	this.Lock()
+	defer this.Unlock()
+
+	for key, _ := range this.entries {
+		this.evict(key)
+	}
+}
+
+func (this *ResultCache) evict(key string) {
+	delete(this.entries, key)
+	this.count(accounting.RESULT_CACHE_ENTRIES, -1)
+	this.count(accounting.RESULT_CACHE_EVICTIONS, 1)
+}
+
+func (this *ResultCache) count(name string, n int64) {
+	if this.acctstore == nil {
+		return
+	}
+
+	counter := this.acctstore.MetricRegistry().Counter(name)
+	if n >= 0 {
+		counter.Inc(n)
+	} else {
+		counter.Dec(-n)
+	}
+}
+
+// The cache key covers everything that determines the results of
+// a read-only statement, including the credentials, roles and
+// authentication scheme used to authorize it.
+func resultCacheKey(request Request, namespace string) (string, bool) {
+	text := request.Statement()
+	if text == "" {
+		prepared := request.Prepared()
+		if prepared == nil || prepared.Name() == "" {
+			return "", false
+		}
+		text = "prepared:" + prepared.Name()
+	}
+
+	var vector []interface{}
+	if v := request.ScanVector(); v != nil {
+		for _, e := range v.Entries() {
+			vector = append(vector, []interface{}{e.Position(), e.Guard(), e.Value()})
+		}
+	}
+
+	var creds []string
+	for user, password := range request.Credentials() {
+		creds = append(creds, user+":"+password)
+	}
+	sort.Strings(creds)
+
+	roles := make([]string, 0, len(request.Roles()))
+	for _, role := range request.Roles() {
+		roles = append(roles, role.Name+":"+role.Keyspace)
+	}
+	sort.Strings(roles)
+
+	bytes, err := json.Marshal(map[string]interface{}{
+		"statement":   text,
+		"namespace":   namespace,
+		"named":       request.NamedArgs(),
+		"positional":  request.PositionalArgs(),
+		"consistency": request.ScanConsistency(),
+		"vector":      vector,
+		"creds":       creds,
+		"roles":       roles,
+		"scheme":      request.AuthScheme(),
+	})
+	if err != nil {
+		return "", false
+	}
+
+	sum := sha256.Sum256(bytes)
+	return hex.EncodeToString(sum[:]), true
+}
+
+// Cacheable requests are read-only and not dry runs, and do not
+// require scans to wait for pending mutations. The key does not cover
+// the script variables or the temporary keyspaces of a session.
+func cacheableRequest(request Request) bool {
+	return request.UseCache() != value.FALSE && request.PageSize() == 0 && !request.DryRun() &&
+		request.ScanConsistency() != datastore.SCAN_PLUS &&
+		(request.Session() == nil || (len(request.Session().Variables()) == 0 &&
+			request.Session().Temps().Count() == 0))
+}
+
+// PREPARE and SET are read-only, but have side effects. Statements
+// that call volatile functions, such as NOW_STR() or RANDOM(), return
+// different results each time.
+func cacheablePlan(request Request, prepared *plan.Prepared) bool {
+	if !prepared.Readonly() {
+		return false
+	}
+
+	if seq, ok := prepared.Operator.(*plan.Sequence); ok {
+		for _, child := range seq.Children() {
+			switch child.(type) {
+			case *plan.Prepare, *plan.SessionSet, *plan.SetVariable:
+				return false
+			}
+		}
+	}
+
+	text := prepared.Text()
+	if text == "" {
+		text = request.Statement()
+	}
+
+	stmt, err := n1ql.ParseStatement(text)
+	if err != nil {
+		return false
+	}
+
+	return !expression.HasVolatile(stmt.Expressions())
+}
+
+/*
+cacheOutput collects the results of a request as they are passed
+on to the request output, so that they can be added to the result
+cache once the request completes without errors.
+*/
+type cacheOutput struct {
+	execution.Output
+	sync.Mutex
+	results value.Values
+	failed  bool
+}
+
+func newCacheOutput(output execution.Output) *cacheOutput {
+	return &cacheOutput{
+		Output:  output,
+		results: make(value.Values, 0, 16),
+	}
+}
+
+func (this *cacheOutput) Result(item value.Value) bool {
+	ok := this.Output.Result(item)
+
+	this.Lock()
+	if !ok || len(this.results) >= RESULT_CACHE_MAX_ROWS {
+		this.failed = true
+		this.results = nil
+	} else if !this.failed {
+		this.results = append(this.results, item)
+	}
+	this.Unlock()
+
+	return ok
+}
+
+func (this *cacheOutput) Error(err errors.Error) {
+	this.fail()
+	this.Output.Error(err)
+}
+
+func (this *cacheOutput) Fatal(err errors.Error) {
+	this.fail()
+	this.Output.Fatal(err)
+}
+
+func (this *cacheOutput) Warning(wrn errors.Error) {
+	this.fail()
+	this.Output.Warning(wrn)
+}
+
+func (this *cacheOutput) fail() {
+	this.Lock()
+	this.failed = true
+	this.results = nil
+	this.Unlock()
+}
+
+func (this *cacheOutput) entry(prepared *plan.Prepared, context *execution.Context) *resultCacheEntry {
+	this.Lock()
+	defer this.Unlock()
+
+	if this.failed {
+		return nil
+	}
+
+	return &resultCacheEntry{
+		signature:  prepared.Signature(),
+		results:    this.results,
+		keyspaces:  context.Keyspaces(),
+		privileges: planPrivileges(prepared),
+	}
+}
+
+// Serve a request from a cache entry.
+func serveCached(server *Server, request Request, entry *resultCacheEntry) {
+	go request.Execute(server, entry.signature, make(chan bool, 1))
+
+	output := request.Output()
+	for _, item := range entry.results {
+		if !output.Result(item) {
+			break
+		}
+	}
+
+	output.CloseResults()
+}
@@ -0,0 +1,166 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/value"
+)
+
+func TestResultCacheVolatile(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"status": "pending"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	srvr.SetResultCacheLimit(16)
+	srvr.SetResultCacheTTL(time.Minute)
+
+	tests := []struct {
+		stmt   string
+		cached bool
+	}{
+		{"SELECT status FROM orders", true},
+		{"SELECT status, NOW_STR() AS now FROM orders", false},
+		{"SELECT status FROM orders WHERE CLOCK_MILLIS() > 0", false},
+		{"SELECT RANDOM() AS r FROM orders", false},
+		{"SELECT UUID() AS id FROM orders", false},
+		{"SELECT status FROM orders WHERE status IN (SELECT RAW NOW_STR() FROM orders o USE KEYS \"o1\")", false},
+	}
+
+	for _, test := range tests {
+		size := srvr.ResultCache().Size()
+		if _, errs := runTestRequest(srvr, test.stmt, nil); len(errs) > 0 {
+			t.Fatalf("Unexpected errors for %s: %v", test.stmt, errs)
+		}
+
+		if cached := srvr.ResultCache().Size() > size; cached != test.cached {
+			t.Errorf("Expected cached %v for %s, got %v", test.cached, test.stmt, cached)
+		}
+	}
+}
+
+func TestResultCacheKey(t *testing.T) {
+	key := func(creds datastore.Credentials, roles []datastore.Role, scheme string) string {
+		request := newTestRequest("SELECT 1", creds)
+		request.SetRoles(roles)
+		request.SetAuthScheme(scheme)
+		rv, ok := resultCacheKey(request, "default")
+		if !ok {
+			t.Fatalf("Expected a key for %v %v %s", creds, roles, scheme)
+		}
+		return rv
+	}
+
+	sub := datastore.Credentials{"sub": ""}
+	reader := []datastore.Role{{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "default:orders"}}
+	admin := []datastore.Role{{Name: datastore.ROLE_ADMIN}}
+
+	// A JWT subject is not the basic auth user of the same name
+	basic := key(sub, nil, BASIC_SCHEME)
+	jwt := key(sub, reader, JWT_SCHEME)
+	if basic == jwt {
+		t.Errorf("Expected the keys of basic and JWT requests to differ")
+	}
+
+	if jwt == key(sub, admin, JWT_SCHEME) {
+		t.Errorf("Expected the keys of requests with different roles to differ")
+	}
+
+	if jwt == key(sub, reader, CERT_SCHEME) {
+		t.Errorf("Expected the keys of requests with different schemes to differ")
+	}
+
+	if jwt != key(datastore.Credentials{"sub": ""}, reader, JWT_SCHEME) {
+		t.Errorf("Expected the keys of the same identity to be equal")
+	}
+}
+
+func TestResultCachePrivileges(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"status": "pending"}`,
+	})
+	defer os.RemoveAll(dir)
+
+	srvr.SetResultCacheLimit(16)
+	srvr.SetResultCacheTTL(time.Minute)
+
+	catalog, err := srvr.Datastore().RoleCatalog()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	reader := datastore.Role{Name: datastore.ROLE_QUERY_SELECT, Keyspace: "default:orders"}
+	catalog.GrantRole("alice", reader)
+	catalog.GrantRole("bob", datastore.Role{Name: datastore.ROLE_ADMIN})
+
+	alice := datastore.Credentials{"alice": ""}
+	stmt := "SELECT status FROM orders"
+	if _, errs := runTestRequest(srvr, stmt, alice); len(errs) > 0 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+
+	if size := srvr.ResultCache().Size(); size != 1 {
+		t.Fatalf("Expected 1 cached statement, got %d", size)
+	}
+
+	// Revoked privileges are checked before serving cached results
+	catalog.RevokeRole("alice", reader)
+	results, errs := runTestRequest(srvr, stmt, alice)
+	if len(errs) != 1 || len(results) != 0 {
+		t.Errorf("Expected an authorization error, got %v and %v", results, errs)
+	}
+}
+
+func TestResultCacheSearchPath(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"status": "pending"}`,
+	})
+	defer os.RemoveAll(dir)
+	defer srvr.SetSearchPath(nil)
+
+	srvr.SetResultCacheLimit(16)
+	srvr.SetResultCacheTTL(time.Minute)
+
+	// Unqualified keyspaces may resolve to other namespaces
+	changes := []func(){
+		func() { srvr.SetSearchPath([]string{"archive"}) },
+		func() { srvr.SetNamespace("archive") },
+	}
+
+	for i, change := range changes {
+		stmts := []string{"SELECT status FROM orders", "PREPARE p FROM SELECT status FROM orders"}
+		for _, stmt := range stmts {
+			if _, errs := runTestRequest(srvr, stmt, nil); len(errs) > 0 {
+				t.Fatalf("Unexpected errors for %s: %v", stmt, errs)
+			}
+		}
+
+		if size := srvr.ResultCache().Size(); size == 0 {
+			t.Fatalf("Expected cached statements")
+		}
+
+		change()
+		if size := srvr.ResultCache().Size(); size != 0 {
+			t.Errorf("Expected no cached statements after change %d, got %d", i, size)
+		}
+
+		if _, err := plan.GetPrepared(value.NewValue("p")); err == nil {
+			t.Errorf("Expected no prepared statement after change %d", i)
+		}
+
+		srvr.SetNamespace("default")
+	}
+}
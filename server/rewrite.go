@@ -0,0 +1,81 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/errors"
+)
+
+/*
+Rewriter is a hook for applications embedding the server. Rewriters
+are invoked on every ad hoc statement after it is parsed and before
+it is planned, and return the statement to plan instead. They can
+add filters, rename keyspaces, or reject the statement by returning
+an error such as errors.NewServiceErrorStatementRejected, for instance
+to confine each tenant to its own documents.
+A rewriter returning a nil statement leaves the statement unchanged.
+
+Statements are passed in formalized, and a rewriter that builds new
+expressions must formalize them before returning. A PREPARE statement
+is rewritten once, when it is prepared; executing the prepared
+statement later does not invoke the rewriters again.
+*/
+type Rewriter interface {
+	Rewrite(request Request, stmt algebra.Statement) (algebra.Statement, errors.Error)
+}
+
+// RewriterFunc adapts a function to the Rewriter interface.
+type RewriterFunc func(request Request, stmt algebra.Statement) (algebra.Statement, errors.Error)
+
+func (this RewriterFunc) Rewrite(request Request, stmt algebra.Statement) (algebra.Statement, errors.Error) {
+	return this(request, stmt)
+}
+
+// Rewriters are copied on write, so that requests can read them
+// without locking out one another.
+type rewriters struct {
+	sync.Mutex
+	list []Rewriter
+}
+
+// Rewriters run in the order they were added, each receiving the
+// statement returned by the previous one.
+func (this *Server) AddRewriter(rewriter Rewriter) {
+	this.rewriters.Lock()
+	defer this.rewriters.Unlock()
+
+	list := make([]Rewriter, len(this.rewriters.list), len(this.rewriters.list)+1)
+	copy(list, this.rewriters.list)
+	this.rewriters.list = append(list, rewriter)
+}
+
+func (this *Server) Rewriters() []Rewriter {
+	this.rewriters.Lock()
+	defer this.rewriters.Unlock()
+	return this.rewriters.list
+}
+
+func (this *Server) rewrite(request Request, stmt algebra.Statement) (algebra.Statement, errors.Error) {
+	for _, rewriter := range this.Rewriters() {
+		rv, err := rewriter.Rewrite(request, stmt)
+		if err != nil {
+			return nil, err
+		}
+
+		if rv != nil {
+			stmt = rv
+		}
+	}
+
+	return stmt, nil
+}
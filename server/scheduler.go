@@ -0,0 +1,448 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"runtime"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/session"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Maintenance tasks of the server. They are run in the background by
+the scheduler, periodically or when triggered through the admin
+API, and are listed in system:tasks. The interval of a task can be
+changed by updating its document in system:tasks. Long-running tasks,
+such as continuous queries, are stopped by deleting their documents.
+*/
+const (
+	TASK_PURGE_REQUESTS     = "purge_requests"     // Evict expired cached results and sessions
+	TASK_REFRESH_STATISTICS = "refresh_statistics" // Refresh the index metadata and statistics
+	TASK_COMPACT_INDEXES    = "compact_indexes"    // Compact the indexes that support it
+)
+
+const (
+	PURGE_REQUESTS_INTERVAL     = time.Minute
+	REFRESH_STATISTICS_INTERVAL = 10 * time.Minute
+	COMPACT_INDEXES_INTERVAL    = time.Hour
+)
+
+const TASK_OWNER = "system"
+
+type TaskFunc func(server *Server) errors.Error
+
+type task struct {
+	name     string
+	owner    string
+	run      TaskFunc
+	interval time.Duration
+	created  time.Time
+	started  time.Time
+	ended    time.Time
+	next     time.Time
+	runs     int64
+	running  bool
+	err      errors.Error
+	timer    *time.Timer
+	stop     func()                        // Set for tasks started by Start
+	info     func() map[string]interface{} // Additional fields of the entry
+}
+
+/*
+Scheduler runs the registered tasks. A task with no interval only
+runs when triggered. A task never runs concurrently with itself;
+its next run is scheduled when the current one ends.
+*/
+type Scheduler struct {
+	sync.Mutex
+	server *Server
+	tasks  map[string]*task
+}
+
+func newScheduler(server *Server) *Scheduler {
+	rv := &Scheduler{
+		server: server,
+		tasks:  make(map[string]*task),
+	}
+
+	rv.Register(TASK_PURGE_REQUESTS, TASK_OWNER, PURGE_REQUESTS_INTERVAL, purgeRequests)
+	rv.Register(TASK_REFRESH_STATISTICS, TASK_OWNER, REFRESH_STATISTICS_INTERVAL, refreshStatistics)
+	rv.Register(TASK_COMPACT_INDEXES, TASK_OWNER, COMPACT_INDEXES_INTERVAL, compactIndexes)
+	return rv
+}
+
+// Register a task, replacing any task of the same name.
+func (this *Scheduler) Register(name, owner string, interval time.Duration, run TaskFunc) {
+	this.Lock()
+	defer this.Unlock()
+
+	if t, ok := this.tasks[name]; ok && t.timer != nil {
+		t.timer.Stop()
+	}
+
+	t := &task{
+		name:     name,
+		owner:    owner,
+		run:      run,
+		interval: interval,
+		created:  time.Now(),
+	}
+
+	this.tasks[name] = t
+	this.schedule(t)
+}
+
+/*
+Start a task that runs until it is deleted, e.g. a continuous query.
+Unlike registered tasks, it is not replaced by a task of the same
+name. Stop is called when the task is deleted, and info, if not nil,
+adds fields to its entry in system:tasks.
+*/
+func (this *Scheduler) Start(name, owner string, run TaskFunc, stop func(),
+	info func() map[string]interface{}) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+
+	if _, ok := this.tasks[name]; ok {
+		return errors.NewAdminTaskExistsError(name)
+	}
+
+	t := &task{
+		name:    name,
+		owner:   owner,
+		run:     run,
+		created: time.Now(),
+		stop:    stop,
+		info:    info,
+	}
+
+	this.tasks[name] = t
+	t.running = true
+	t.started = t.created
+	go this.runTask(t)
+	return nil
+}
+
+/*
+Delete a task started by Start, stopping it if it is running. The
+maintenance tasks of the server cannot be deleted.
+*/
+func (this *Scheduler) Delete(name string) errors.Error {
+	this.Lock()
+	t, ok := this.tasks[name]
+	if !ok {
+		this.Unlock()
+		return errors.NewAdminTaskNotFoundError(name)
+	}
+
+	if t.stop == nil {
+		this.Unlock()
+		return errors.NewAdminTaskDeleteError(name)
+	}
+
+	delete(this.tasks, name)
+	this.Unlock()
+
+	t.stop()
+	return nil
+}
+
+// Set the interval of a task. Zero means the task only runs when
+// triggered.
+func (this *Scheduler) SetInterval(name string, interval time.Duration) errors.Error {
+	if interval < 0 {
+		return errors.NewAdminTaskIntervalError(name, interval)
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	t, ok := this.tasks[name]
+	if !ok {
+		return errors.NewAdminTaskNotFoundError(name)
+	}
+
+	t.interval = interval
+	if !t.running {
+		this.schedule(t)
+	}
+
+	return nil
+}
+
+// Run a task now, in the background.
+func (this *Scheduler) Trigger(name string) errors.Error {
+	this.Lock()
+	defer this.Unlock()
+
+	t, ok := this.tasks[name]
+	if !ok {
+		return errors.NewAdminTaskNotFoundError(name)
+	}
+
+	if t.running {
+		return errors.NewAdminTaskRunningError(name)
+	}
+
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+
+	t.running = true
+	t.started = time.Now()
+	t.next = time.Time{}
+	go this.runTask(t)
+	return nil
+}
+
+// Names of the tasks, sorted.
+func (this *Scheduler) Ids() []string {
+	this.Lock()
+	defer this.Unlock()
+
+	rv := make([]string, 0, len(this.tasks))
+	for name, _ := range this.tasks {
+		rv = append(rv, name)
+	}
+
+	sort.Strings(rv)
+	return rv
+}
+
+func (this *Scheduler) Entry(name string) (map[string]interface{}, bool) {
+	this.Lock()
+	defer this.Unlock()
+
+	t, ok := this.tasks[name]
+	if !ok {
+		return nil, false
+	}
+
+	status := "manual"
+	if t.running {
+		status = "running"
+	} else if t.interval > 0 {
+		status = "scheduled"
+	}
+
+	rv := map[string]interface{}{
+		"id":      t.name,
+		"status":  status,
+		"owner":   t.owner,
+		"created": t.created.Format(time.RFC3339),
+		"runs":    t.runs,
+	}
+
+	if t.interval > 0 {
+		rv["interval"] = t.interval.String()
+	}
+
+	if !t.started.IsZero() {
+		rv["started"] = t.started.Format(time.RFC3339)
+	}
+
+	if !t.ended.IsZero() && !t.running {
+		rv["ended"] = t.ended.Format(time.RFC3339)
+		if t.err != nil {
+			rv["result"] = "failed"
+			rv["error"] = t.err.Error()
+		} else {
+			rv["result"] = "completed"
+		}
+	}
+
+	if !t.next.IsZero() {
+		rv["next"] = t.next.Format(time.RFC3339)
+	}
+
+	if t.info != nil {
+		for k, v := range t.info() {
+			rv[k] = v
+		}
+	}
+
+	return rv, true
+}
+
+// Updating a task in system:tasks changes its interval. A missing,
+// null or empty interval means the task only runs when triggered.
+func (this *Scheduler) Update(name string, entry value.Value) errors.Error {
+	var interval time.Duration
+
+	val, ok := entry.Field("interval")
+	if ok {
+		switch a := val.Actual().(type) {
+		case nil:
+		case string:
+			if a != "" {
+				var err error
+				interval, err = time.ParseDuration(a)
+				if err != nil {
+					return errors.NewAdminTaskIntervalError(name, a)
+				}
+			}
+		default:
+			return errors.NewAdminTaskIntervalError(name, a)
+		}
+	}
+
+	return this.SetInterval(name, interval)
+}
+
+// Must be called with the lock held.
+func (this *Scheduler) schedule(t *task) {
+	if t.timer != nil {
+		t.timer.Stop()
+		t.timer = nil
+	}
+
+	t.next = time.Time{}
+	if t.interval <= 0 {
+		return
+	}
+
+	name := t.name
+	t.next = time.Now().Add(t.interval)
+	t.timer = time.AfterFunc(t.interval, func() {
+		this.Trigger(name)
+	})
+}
+
+func (this *Scheduler) runTask(t *task) {
+	var err errors.Error
+
+	defer func() {
+		if r := recover(); r != nil {
+			buf := make([]byte, 1<<16)
+			n := runtime.Stack(buf, false)
+			logging.Severep("Task panicked", logging.Pair{"name", t.name},
+				logging.Pair{"panic", r}, logging.Pair{"stack", string(buf[0:n])})
+			err = errors.NewError(nil, "Task panicked.")
+		}
+
+		if err != nil {
+			logging.Errorp("Task failed", logging.Pair{"name", t.name},
+				logging.Pair{"error", err})
+		}
+
+		this.Lock()
+		defer this.Unlock()
+
+		t.running = false
+		t.ended = time.Now()
+		t.runs++
+		t.err = err
+
+		// The task may have been replaced while it ran
+		if this.tasks[t.name] == t {
+			this.schedule(t)
+		}
+	}()
+
+	err = t.run(this.server)
+}
+
+func purgeRequests(server *Server) errors.Error {
+	results := server.resultCache.purge()
+	sessions := session.Purge()
+	if results > 0 || sessions > 0 {
+		logging.Infop("Purged expired requests", logging.Pair{"results", results},
+			logging.Pair{"sessions", sessions})
+	}
+
+	return nil
+}
+
+func refreshStatistics(server *Server) errors.Error {
+	return forEachIndexer(server.Datastore(), func(indexer datastore.Indexer) errors.Error {
+		return indexer.Refresh()
+	})
+}
+
+func compactIndexes(server *Server) errors.Error {
+	return forEachIndexer(server.Datastore(), func(indexer datastore.Indexer) errors.Error {
+		indexes, err := indexer.Indexes()
+		if err != nil {
+			return err
+		}
+
+		var rv errors.Error
+		for _, index := range indexes {
+			if index, ok := index.(datastore.CompactableIndex); ok {
+				err = index.Compact(TASK_COMPACT_INDEXES)
+				if err != nil && rv == nil {
+					rv = err
+				}
+			}
+		}
+
+		return rv
+	})
+}
+
+// Visit the indexers of every keyspace. All indexers are visited
+// even if some fail; the first error is returned.
+func forEachIndexer(store datastore.Datastore, f func(datastore.Indexer) errors.Error) errors.Error {
+	var rv errors.Error
+	fail := func(err errors.Error) {
+		if rv == nil {
+			rv = err
+		}
+	}
+
+	namespaces, err := store.NamespaceNames()
+	if err != nil {
+		return err
+	}
+
+	for _, nsName := range namespaces {
+		namespace, err := store.NamespaceByName(nsName)
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		keyspaces, err := namespace.KeyspaceNames()
+		if err != nil {
+			fail(err)
+			continue
+		}
+
+		for _, ksName := range keyspaces {
+			keyspace, err := namespace.KeyspaceByName(ksName)
+			if err != nil {
+				fail(err)
+				continue
+			}
+
+			indexers, err := keyspace.Indexers()
+			if err != nil {
+				fail(err)
+				continue
+			}
+
+			for _, indexer := range indexers {
+				if err := f(indexer); err != nil {
+					fail(err)
+				}
+			}
+		}
+	}
+
+	return rv
+}
@@ -15,20 +15,28 @@ import (
 	"os"
 	"runtime"
 	"runtime/pprof"
+	"strings"
 	"sync"
 	"time"
 
 	atomic "github.com/couchbase/go-couchbase/platform"
 	"github.com/couchbase/query/accounting"
+	"github.com/couchbase/query/active"
+	"github.com/couchbase/query/algebra"
 	"github.com/couchbase/query/clustering"
 	"github.com/couchbase/query/datastore"
 	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/datastore/temp"
 	"github.com/couchbase/query/errors"
 	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/feature"
+	"github.com/couchbase/query/guard"
 	"github.com/couchbase/query/logging"
 	"github.com/couchbase/query/parser/n1ql"
 	"github.com/couchbase/query/plan"
 	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/quota"
 	"github.com/couchbase/query/value"
 )
 
@@ -40,26 +48,38 @@ type Server struct {
 	maxParallelism atomic.AlignedInt64
 	keepAlive      atomic.AlignedInt64
 	requestSize    atomic.AlignedInt64
+	replanAttempts atomic.AlignedInt64
+	maxResultCount atomic.AlignedInt64
+	maxResultSize  atomic.AlignedInt64
 
 	sync.RWMutex
-	datastore   datastore.Datastore
-	systemstore datastore.Datastore
-	configstore clustering.ConfigurationStore
-	acctstore   accounting.AccountingStore
-	namespace   string
-	readonly    bool
-	channel     RequestChannel
-	plusChannel RequestChannel
-	done        chan bool
-	plusDone    chan bool
-	timeout     time.Duration
-	signature   bool
-	metrics     bool
-	wg          sync.WaitGroup
-	plusWg      sync.WaitGroup
-	memprofile  string
-	cpuprofile  string
-	enterprise  bool
+	datastore      datastore.Datastore
+	systemstore    datastore.Datastore
+	configstore    clustering.ConfigurationStore
+	acctstore      accounting.AccountingStore
+	namespace      string
+	readonly       bool
+	queue          *RequestQueue
+	plusQueue      *RequestQueue
+	done           chan bool
+	plusDone       chan bool
+	timeout        time.Duration
+	missingOrder   value.MissingOrder
+	missingWarn    bool
+	outfileDir     string
+	importDir      string
+	signature      bool
+	metrics        bool
+	wg             sync.WaitGroup
+	plusWg         sync.WaitGroup
+	memprofile     string
+	cpuprofile     string
+	enterprise     bool
+	resultCache    *ResultCache
+	scheduler      *Scheduler
+	rewriters      rewriters
+	guards         guards
+	authenticators Authenticators
 }
 
 // Default Keep Alive Length
@@ -68,7 +88,7 @@ const KEEP_ALIVE_DEFAULT = 1024 * 16
 
 func NewServer(store datastore.Datastore, config clustering.ConfigurationStore,
 	acctng accounting.AccountingStore, namespace string, readonly bool,
-	channel, plusChannel RequestChannel, servicers, plusServicers, maxParallelism int,
+	queue, plusQueue *RequestQueue, servicers, plusServicers, maxParallelism int,
 	timeout time.Duration, signature, metrics bool, enterprise bool) (*Server, errors.Error) {
 	rv := &Server{
 		datastore:   store,
@@ -76,14 +96,15 @@ func NewServer(store datastore.Datastore, config clustering.ConfigurationStore,
 		acctstore:   acctng,
 		namespace:   namespace,
 		readonly:    readonly,
-		channel:     channel,
-		plusChannel: plusChannel,
+		queue:       queue,
+		plusQueue:   plusQueue,
 		signature:   signature,
 		timeout:     timeout,
 		metrics:     metrics,
 		done:        make(chan bool),
 		plusDone:    make(chan bool),
 		enterprise:  enterprise,
+		resultCache: newResultCache(acctng),
 	}
 
 	// special case handling for the atomic specfic stuff
@@ -93,12 +114,15 @@ func NewServer(store datastore.Datastore, config clustering.ConfigurationStore,
 	store.SetLogLevel(logging.LogLevel())
 	rv.SetMaxParallelism(maxParallelism)
 
-	sys, err := system.NewDatastore(store)
+	sys, err := system.NewDatastore(store, acctng)
 	if err != nil {
 		return nil, err
 	}
 
 	rv.systemstore = sys
+	datastore.SetSystemstore(sys)
+	rv.scheduler = newScheduler(rv)
+	system.SetCatalog(system.KEYSPACE_NAME_TASKS, rv.scheduler)
 	return rv, nil
 }
 
@@ -106,6 +130,47 @@ func (this *Server) Datastore() datastore.Datastore {
 	return this.datastore
 }
 
+// The datastore as seen by a request, holding its temporary keyspaces.
+func (this *Server) requestDatastore(request Request) datastore.Datastore {
+	return temp.NewDatastore(this.datastore, request.Temps())
+}
+
+/*
+Subscribe to the mutations of a keyspace, including those of the DML
+run by this server, if the keyspace has a mutation feed. The
+credentials must allow reading the keyspace.
+*/
+func (this *Server) Subscribe(namespace, keyspace string, credentials datastore.Credentials) (
+	datastore.MutationFeed, errors.Error) {
+	if namespace == "" {
+		namespace = this.Namespace()
+	}
+
+	privs := datastore.NewPrivileges()
+	privs[namespace+":"+keyspace] = datastore.PRIV_READ
+	err := this.datastore.Authorize(privs, credentials)
+	if err != nil {
+		return nil, err
+	}
+
+	ns, err := this.datastore.NamespaceByName(namespace)
+	if err != nil {
+		return nil, err
+	}
+
+	ks, err := ns.KeyspaceByName(keyspace)
+	if err != nil {
+		return nil, err
+	}
+
+	feeds, ok := ks.(datastore.FeedKeyspace)
+	if !ok {
+		return nil, errors.NewOtherNotSupportedError(nil, "- mutation feeds of keyspace "+keyspace)
+	}
+
+	return feeds.Subscribe()
+}
+
 func (this *Server) ConfigurationStore() clustering.ConfigurationStore {
 	return this.configstore
 }
@@ -114,12 +179,96 @@ func (this *Server) AccountingStore() accounting.AccountingStore {
 	return this.acctstore
 }
 
-func (this *Server) Channel() RequestChannel {
-	return this.channel
+func (this *Server) Queue() *RequestQueue {
+	return this.queue
 }
 
-func (this *Server) PlusChannel() RequestChannel {
-	return this.plusChannel
+func (this *Server) PlusQueue() *RequestQueue {
+	return this.plusQueue
+}
+
+// Queue a request for the servicers; returns false if the
+// request queue is full.
+func (this *Server) Enqueue(request Request) bool {
+	queue := this.queue
+	if request.ScanConsistency() != datastore.UNBOUNDED {
+		queue = this.plusQueue
+	}
+
+	if !queue.Enqueue(request) {
+		this.countRequests(accounting.REJECTED_REQUESTS, 1)
+		return false
+	}
+
+	this.countRequests(accounting.QUEUED_REQUESTS, 1)
+	return true
+}
+
+func (this *Server) countRequests(name string, n int64) {
+	if this.acctstore == nil {
+		return
+	}
+
+	counter := this.acctstore.MetricRegistry().Counter(name)
+	if n >= 0 {
+		counter.Inc(n)
+	} else {
+		counter.Dec(-n)
+	}
+}
+
+func (this *Server) Authenticators() Authenticators {
+	this.RLock()
+	defer this.RUnlock()
+	return this.authenticators
+}
+
+// Once authenticators are set, every request must be authenticated
+// by one of them.
+func (this *Server) SetAuthenticators(authenticators ...Authenticator) {
+	this.Lock()
+	defer this.Unlock()
+	this.authenticators = authenticators
+}
+
+// Default namespace of requests that do not provide one.
+func (this *Server) Namespace() string {
+	this.RLock()
+	defer this.RUnlock()
+	return this.namespace
+}
+
+func (this *Server) SetNamespace(namespace string) {
+	if namespace == "" {
+		return
+	}
+
+	this.Lock()
+	changed := this.namespace != namespace
+	this.namespace = namespace
+	this.Unlock()
+
+	if changed {
+		this.flushCaches()
+	}
+}
+
+// Namespaces searched for unqualified keyspaces that are not in the
+// default namespace.
+func (this *Server) SearchPath() []string {
+	return datastore.GetSearchPath()
+}
+
+func (this *Server) SetSearchPath(path []string) {
+	datastore.SetSearchPath(path)
+	this.flushCaches()
+}
+
+// Plans and results depend on the namespaces in which unqualified
+// keyspaces are found.
+func (this *Server) flushCaches() {
+	plan.ClearPrepareds()
+	this.resultCache.flush()
 }
 
 func (this *Server) Signature() bool {
@@ -202,6 +351,26 @@ func (this *Server) PipelineBatch() int {
 	return execution.PipelineBatchSize()
 }
 
+// The number of documents INSERT and UPSERT send to the keyspace at a
+// time; zero or less means the pipeline batch size.
+func (this *Server) SetMutationBatch(mutation_batch int) {
+	execution.SetMutationBatch(mutation_batch)
+}
+
+func (this *Server) MutationBatch() int {
+	return execution.MutationBatchSize()
+}
+
+func (this *Server) Features() map[string]bool {
+	return feature.Settings()
+}
+
+func (this *Server) SetFeatures(features feature.Flags) {
+	for name, enabled := range features {
+		feature.Set(name, enabled)
+	}
+}
+
 func (this *Server) Debug() bool {
 	return logging.LogLevel() == logging.DEBUG
 }
@@ -253,6 +422,116 @@ func (this *Server) SetScanCap(size int) {
 	datastore.SetScanCap(int64(size))
 }
 
+func (this *Server) ReplicaPolicy() string {
+	return string(datastore.GetReplicaPolicy())
+}
+
+func (this *Server) SetReplicaPolicy(policy string) bool {
+	rp, ok := datastore.ParseReplicaPolicy(policy)
+	if ok {
+		datastore.SetReplicaPolicy(rp)
+	}
+	return ok
+}
+
+func (this *Server) ErrorVerbosity() string {
+	return string(execution.GetErrorVerbosity())
+}
+
+func (this *Server) SetErrorVerbosity(verbosity string) bool {
+	ev, ok := execution.ParseErrorVerbosity(verbosity)
+	if ok {
+		execution.SetErrorVerbosity(ev)
+	}
+	return ok
+}
+
+func (this *Server) MaxContinuations() int {
+	max, _ := _CONTINUATIONS.limits()
+	return max
+}
+
+func (this *Server) MaxUserContinuations() int {
+	_, maxUser := _CONTINUATIONS.limits()
+	return maxUser
+}
+
+// Maximum number of paged requests held by the server, and by each
+// user; zero means unlimited.
+func (this *Server) SetMaxContinuations(max, maxUser int) {
+	if max < 0 {
+		max = 0
+	}
+
+	if maxUser < 0 {
+		maxUser = 0
+	}
+
+	_CONTINUATIONS.setLimits(max, maxUser)
+}
+
+func (this *Server) ReplanAttempts() int {
+	return int(atomic.LoadInt64(&this.replanAttempts))
+}
+
+func (this *Server) MaxResultCount() int {
+	return int(atomic.LoadInt64(&this.maxResultCount))
+}
+
+// Maximum number of results of a request; zero means unlimited.
+func (this *Server) SetMaxResultCount(count int) {
+	if count < 0 {
+		count = 0
+	}
+	atomic.StoreInt64(&this.maxResultCount, int64(count))
+}
+
+func (this *Server) MaxResultSize() int {
+	return int(atomic.LoadInt64(&this.maxResultSize))
+}
+
+// Maximum size in bytes of the results of a request; zero means
+// unlimited.
+func (this *Server) SetMaxResultSize(size int) {
+	if size < 0 {
+		size = 0
+	}
+	atomic.StoreInt64(&this.maxResultSize, int64(size))
+}
+
+// Number of times a request is re-planned when an index it uses
+// is dropped or taken offline; zero disables re-planning.
+func (this *Server) SetReplanAttempts(attempts int) {
+	if attempts < 0 {
+		attempts = 0
+	}
+	atomic.StoreInt64(&this.replanAttempts, int64(attempts))
+}
+
+func (this *Server) ResultCache() *ResultCache {
+	return this.resultCache
+}
+
+func (this *Server) Scheduler() *Scheduler {
+	return this.scheduler
+}
+
+func (this *Server) ResultCacheLimit() int {
+	return this.resultCache.Limit()
+}
+
+func (this *Server) SetResultCacheLimit(limit int) {
+	this.resultCache.SetLimit(limit)
+}
+
+func (this *Server) ResultCacheTTL() time.Duration {
+	return this.resultCache.TTL()
+}
+
+func (this *Server) SetResultCacheTTL(ttl time.Duration) {
+	this.resultCache.SetTTL(ttl)
+}
+
 func (this *Server) Servicers() int {
 	return int(atomic.LoadInt64(&this.servicers))
 }
@@ -299,6 +578,42 @@ func (this *Server) SetTimeout(timeout time.Duration) {
 	this.timeout = timeout
 }
 
+// The default MISSING order of ORDER BY; see value.MissingOrder.
+func (this *Server) MissingOrder() value.MissingOrder {
+	return this.missingOrder
+}
+
+func (this *Server) SetMissingOrder(order value.MissingOrder) {
+	this.missingOrder = order
+}
+
+// The directory of INTO OUTFILE files; if empty, OUTFILE is disabled.
+func (this *Server) OutfileDir() string {
+	return this.outfileDir
+}
+
+func (this *Server) SetOutfileDir(dir string) {
+	this.outfileDir = dir
+}
+
+// The directory of IMPORT files; if empty, IMPORT is disabled.
+func (this *Server) ImportDir() string {
+	return this.importDir
+}
+
+func (this *Server) SetImportDir(dir string) {
+	this.importDir = dir
+}
+
+// Whether requests warn of comparisons involving MISSING by default.
+func (this *Server) MissingWarnings() bool {
+	return this.missingWarn
+}
+
+func (this *Server) SetMissingWarnings(warnings bool) {
+	this.missingWarn = warnings
+}
+
 func (this *Server) Enterprise() bool {
 	return this.enterprise
 }
@@ -317,14 +632,12 @@ func (this *Server) Serve() {
 
 func (this *Server) doServe() {
 	defer this.wg.Done()
-	ok := true
-	for ok {
-		select {
-		case request := <-this.channel:
-			this.serviceRequest(request)
-		case <-this.done:
-			ok = false
+	for {
+		request, ok := this.queue.dequeue(this.done)
+		if !ok {
+			return
 		}
+		this.runRequest(request)
 	}
 }
 
@@ -342,14 +655,45 @@ func (this *Server) PlusServe() {
 
 func (this *Server) doPlusServe() {
 	defer this.plusWg.Done()
-	ok := true
-	for ok {
-		select {
-		case request := <-this.plusChannel:
-			this.serviceRequest(request)
-		case <-this.plusDone:
-			ok = false
+	for {
+		request, ok := this.plusQueue.dequeue(this.plusDone)
+		if !ok {
+			return
 		}
+		this.runRequest(request)
+	}
+}
+
+func (this *Server) runRequest(request Request) {
+	this.countRequests(accounting.QUEUED_REQUESTS, -1)
+	this.countRequests(accounting.ACTIVE_REQUESTS, 1)
+	defer this.countRequests(accounting.ACTIVE_REQUESTS, -1)
+
+	this.serviceRequest(request)
+}
+
+/*
+Return a function computing the fingerprint of the statement of a
+request the first time it is called, so that requests that are never
+listed do not pay for it.
+*/
+func fingerprint(request Request) func() string {
+	var once sync.Once
+	var rv string
+
+	return func() string {
+		once.Do(func() {
+			text := request.Statement()
+			if text == "" && request.Prepared() != nil {
+				text = request.Prepared().Text()
+			}
+
+			if text != "" {
+				rv, _ = n1ql.Fingerprint(text)
+			}
+		})
+
+		return rv
 	}
 }
 
@@ -371,7 +715,48 @@ func (this *Server) serviceRequest(request Request) {
 
 	namespace := request.Namespace()
 	if namespace == "" {
-		namespace = this.namespace
+		namespace = this.Namespace()
+	}
+
+	users := make([]string, 0, len(request.Credentials()))
+	for user, _ := range request.Credentials() {
+		users = append(users, user)
+	}
+	quotas := quota.NewTracker(users)
+
+	id := request.Id().String()
+	active.Add(active.NewRequest(id, request.ClientID().String(), request.Statement(), users,
+		request.RequestTime(), func() string { return string(request.State()) },
+		fingerprint(request), request.Progress().Values, request.Cancel))
+	defer active.Remove(id)
+
+	if request.Continuation() != "" {
+		this.resumeRequest(request, users)
+		return
+	}
+
+	// Requests with several statements run them one after another
+	if request.Prepared() == nil && strings.Contains(request.Statement(), ";") {
+		stmts, err := n1ql.ParseStatements(request.Statement())
+		if err == nil && len(stmts) > 1 {
+			this.serviceStatements(request, namespace, stmts, quotas)
+			return
+		}
+	}
+
+	// Cached results would bypass quotas, rewriters, policies and rules
+	cacheKey := ""
+	if quotas == nil && len(this.Rewriters()) == 0 && policy.Count() == 0 &&
+		guard.Count() == 0 && len(this.Guards()) == 0 &&
+		this.resultCache.Enabled() && cacheableRequest(request) {
+		key, ok := resultCacheKey(request, namespace)
+		if ok {
+			if entry := this.resultCache.get(key); entry != nil && entry.authorized(request) {
+				serveCached(this, request, entry)
+				return
+			}
+			cacheKey = key
+		}
 	}
 
 	prepared, err := this.getPrepared(request, namespace)
@@ -385,19 +770,48 @@ func (this *Server) serviceRequest(request Request) {
 			" and cannot accept this write statement."))
 	}
 
+	if prepared != nil && request.State() != FATAL {
+		if err := this.guard(request, prepared); err != nil {
+			request.Fail(err)
+		}
+	}
+
 	if request.State() == FATAL {
 		request.Failed(this)
 		return
 	}
 
-	maxParallelism := request.MaxParallelism()
-	if maxParallelism <= 0 {
-		maxParallelism = this.MaxParallelism()
+	if request.DryRun() {
+		this.serviceDryRun(request, prepared)
+		return
+	}
+
+	if request.PageSize() > 0 {
+		this.servicePages(request, namespace, prepared, users, quotas)
+		return
+	}
+
+	output := request.Output()
+	var cached *cacheOutput
+	if cacheKey != "" && cacheablePlan(request, prepared) {
+		cached = newCacheOutput(output)
+		output = cached
+	}
+
+	// Ad hoc read-only statements can be re-planned if an index goes
+	// away; statements that mutate may have done so before failing
+	var replan *replanOutput
+	if attempts := this.ReplanAttempts(); attempts > 0 && request.Prepared() == nil &&
+		prepared.Readonly() {
+		replan = newReplanOutput(output, attempts)
+		output = replan
 	}
 
-	context := execution.NewContext(request.Id().String(), this.datastore, this.systemstore, namespace,
-		this.readonly, maxParallelism, request.NamedArgs(), request.PositionalArgs(),
-		request.Credentials(), request.ScanConsistency(), request.ScanVector(), request.Output())
+	// Re-planning replaces the context
+	context := this.newContext(request, namespace, prepared, quotas, nil, output)
+	defer func() {
+		context.ReleaseSnapshots()
+	}()
 
 	build := time.Now()
 	operator, er := execution.Build(prepared, context)
@@ -420,10 +834,20 @@ func (this *Server) serviceRequest(request Request) {
 		defer timer.Stop()
 	}
 
-	go request.Execute(this, prepared.Signature(), operator.StopChannel())
-
 	run := time.Now()
-	operator.RunOnce(context, nil)
+	if replan == nil {
+		go request.Execute(this, prepared.Signature(), operator.StopChannel())
+		operator.RunOnce(context, nil)
+	} else {
+		go request.Execute(this, prepared.Signature(), replan.stop)
+		context = this.runReplan(request, namespace, quotas, replan, operator, context)
+	}
+
+	if cached != nil {
+		if entry := cached.entry(prepared, context); entry != nil {
+			this.resultCache.put(cacheKey, entry)
+		}
+	}
 
 	if logging.LogLevel() >= logging.TRACE {
 		request.Output().AddPhaseTime("run", time.Since(run))
@@ -431,17 +855,161 @@ func (this *Server) serviceRequest(request Request) {
 	}
 }
 
+func (this *Server) newContext(request Request, namespace string, prepared *plan.Prepared,
+	quotas *quota.Tracker, vars map[string]value.Value, output execution.Output) *execution.Context {
+	maxParallelism := request.MaxParallelism()
+	if maxParallelism <= 0 {
+		maxParallelism = this.MaxParallelism()
+	}
+
+	context := execution.NewContext(request.Id().String(), this.requestDatastore(request), this.systemstore, namespace,
+		this.readonly, maxParallelism, namedArgs(request, vars), request.PositionalArgs(),
+		request.Credentials(), request.ScanConsistency(), request.ScanVector(), output)
+	context.SetQuotaTracker(quotas)
+	context.SetProgress(request.Progress())
+	context.SetSession(request.Session())
+	context.SetRoles(request.Roles())
+	context.SetScanCap(request.ScanCap())
+	context.SetPipelineCap(request.PipelineCap())
+	context.SetPipelineBatch(request.PipelineBatch())
+	context.SetMutationBatch(request.MutationBatch())
+	context.SetReplicaReads(prepared.Readonly())
+	context.SetFeatures(request.Features())
+	context.SetStrictCast(request.StrictCast())
+	context.SetAllowFullScan(request.AllowFullScan())
+	context.SetDurability(request.Durability())
+	context.SetCasRetries(request.CasRetries())
+	context.SetOutfileDir(this.OutfileDir())
+	context.SetImportDir(this.ImportDir())
+
+	missingOrder, ok := request.MissingOrder()
+	if !ok {
+		missingOrder = this.MissingOrder()
+	}
+	context.SetMissingOrder(missingOrder)
+
+	missingWarnings := request.MissingWarnings()
+	context.SetMissingWarnings(missingWarnings == value.TRUE ||
+		(missingWarnings == value.NONE && this.MissingWarnings()))
+
+	context.SetNow(request.RequestTime())
+	if seed, ok := request.Seed(); ok {
+		context.SetSeed(seed)
+	}
+	return context
+}
+
+/*
+The named parameters of a statement. Script variables set by the
+earlier statements of the request come first, then the named
+parameters of the request, then the script variables of the session.
+*/
+func namedArgs(request Request, vars map[string]value.Value) map[string]value.Value {
+	var sessionVars map[string]value.Value
+	if s := request.Session(); s != nil {
+		sessionVars = s.Variables()
+	}
+
+	if len(vars) == 0 && len(sessionVars) == 0 {
+		return request.NamedArgs()
+	}
+
+	rv := make(map[string]value.Value, len(sessionVars)+len(request.NamedArgs())+len(vars))
+	for name, val := range sessionVars {
+		rv[name] = val
+	}
+
+	for name, val := range request.NamedArgs() {
+		rv[name] = val
+	}
+
+	for name, val := range vars {
+		rv[name] = val
+	}
+
+	return rv
+}
+
+/*
+Run the statement until it does not fail for want of an index. Each
+attempt runs in a context of its own, so that nothing of a failed
+attempt carries over; the context of the last attempt is returned,
+and those of the others are released.
+*/
+func (this *Server) runReplan(request Request, namespace string, quotas *quota.Tracker,
+	replan *replanOutput, operator execution.Operator, context *execution.Context) *execution.Context {
+	for {
+		replan.run(operator, context)
+		if !replan.retry() {
+			return context
+		}
+
+		logging.Infop("Re-planning request", logging.Pair{"_id", request.Id()})
+
+		prepared, err := this.getPrepared(request, namespace)
+		if err != nil {
+			replan.fail(err)
+			return context
+		}
+
+		context.ReleaseSnapshots()
+		context = this.newContext(request, namespace, prepared, quotas, nil, replan)
+
+		var er error
+		operator, er = execution.Build(prepared, context)
+		if er != nil {
+			replan.fail(errors.NewError(er, ""))
+			return context
+		}
+	}
+}
+
 func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepared, errors.Error) {
+	restricted := policy.Applies(this.datastore, request.Roles(), request.Credentials())
 	prepared := request.Prepared()
-	if prepared == nil {
+	if prepared == nil || restricted {
+		var stmt algebra.Statement
+		var err error
+
 		parse := time.Now()
-		stmt, err := n1ql.ParseStatement(request.Statement())
-		if err != nil {
-			return nil, errors.NewParseSyntaxError(err, "")
+		if prepared == nil {
+			stmt, err = n1ql.ParseStatement(request.Statement())
+			if errs, ok := err.(errors.Errors); ok {
+				// Report all syntax errors, failing with the last one
+				for _, e := range errs[:len(errs)-1] {
+					request.Output().Error(e)
+				}
+
+				return nil, errs[len(errs)-1]
+			} else if err != nil {
+				return nil, errors.NewParseSyntaxError(err, "")
+			}
+		}
+
+		if restricted {
+			var er errors.Error
+			stmt, er = restrictedStatement(stmt, prepared)
+			if er != nil {
+				return nil, er
+			}
 		}
 
 		prep := time.Now()
-		prepared, err = planner.BuildPrepared(stmt, this.datastore, this.systemstore, namespace, false)
+		stmt, er := this.rewrite(request, stmt)
+		if er != nil {
+			return nil, er
+		}
+
+		if request.StrictTypes() {
+			er = algebra.TypeCheck(stmt)
+			if er != nil {
+				return nil, er
+			}
+		}
+
+		prepared, err = planner.BuildPrepared(stmt, this.requestDatastore(request), this.systemstore,
+			namespace, false, restricted, this.readonly || value.ToBool(request.Readonly()),
+			request.Features())
 		if err != nil {
 			return nil, errors.NewPlanError(err, "")
 		}
@@ -460,6 +1028,58 @@ func (this *Server) getPrepared(request Request, namespace string) (*plan.Prepar
 	return prepared, nil
 }
 
+/*
+Plans supplied by the client, or cached and run by EXECUTE, may not
+apply the policies that restrict a request: they may have been
+prepared by anyone before the policies were created, or forged.
+Restricted requests re-plan them from their text, under their own
+roles.
+*/
+func restrictedStatement(stmt algebra.Statement, prepared *plan.Prepared) (algebra.Statement, errors.Error) {
+	if exec, ok := stmt.(*algebra.Execute); ok && exec.Prepared() != nil {
+		var err errors.Error
+		prepared, err = plan.GetPrepared(exec.Prepared())
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	if prepared == nil {
+		return stmt, nil
+	}
+
+	stmt, err := n1ql.ParseStatement(prepared.Text())
+	if err != nil {
+		return nil, errors.NewUnrecognizedPreparedError(err)
+	}
+
+	if prep, ok := stmt.(*algebra.Prepare); ok {
+		stmt = prep.Statement()
+	}
+
+	return stmt, nil
+}
+
+/*
+Plan a statement in the namespace of the server, without executing
+it, as for a request without credentials. Used by the admin API to
+review plans.
+*/
+func (this *Server) Plan(statement string) (*plan.Prepared, errors.Error) {
+	stmt, err := n1ql.ParseStatement(statement)
+	if err != nil {
+		return nil, errors.NewParseSyntaxError(err, "")
+	}
+
+	prepared, err := planner.BuildPrepared(stmt, this.datastore, this.systemstore,
+		this.Namespace(), false, false, this.readonly, nil)
+	if err != nil {
+		return nil, errors.NewPlanError(err, "")
+	}
+
+	return prepared, nil
+}
+
 func logExplain(prepared *plan.Prepared) {
 	var pl plan.Operator = prepared
 	explain, err := json.MarshalIndent(pl, "", "    ")
@@ -0,0 +1,140 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	accounting_stub "github.com/couchbase/query/accounting/stub"
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/logging/logger_golog"
+	"github.com/couchbase/query/value"
+)
+
+func init() {
+	logging.SetLogger(logger_golog.NewLogger(os.Stderr, logging.WARN, false))
+}
+
+// testRequest collects the results and errors of a request.
+type testRequest struct {
+	BaseRequest
+	results []value.Value
+	errs    []errors.Error
+	done    chan bool
+}
+
+func newTestRequest(statement string, creds datastore.Credentials) *testRequest {
+	var metrics value.Tristate
+	rv := &testRequest{
+		BaseRequest: *NewBaseRequest(statement, nil, nil, nil, "default", 0, value.NONE, metrics,
+			value.FALSE, nil, "", creds),
+		done: make(chan bool),
+	}
+	return rv
+}
+
+func (this *testRequest) Output() execution.Output {
+	return this
+}
+
+func (this *testRequest) Fail(err errors.Error) {
+	this.Error(err)
+	this.Stop(FATAL)
+}
+
+func (this *testRequest) Execute(srvr *Server, signature value.Value, stopNotify chan bool) {
+	defer close(this.done)
+	defer this.Close()
+
+	this.NotifyStop(stopNotify)
+	for item := range this.Results() {
+		this.results = append(this.results, item)
+	}
+
+	this.Stop(COMPLETED)
+}
+
+func (this *testRequest) Failed(srvr *Server) {
+	defer close(this.done)
+	this.Stop(FATAL)
+	this.Close()
+}
+
+func (this *testRequest) Expire() {
+	this.Error(errors.NewError(nil, "Query timed out"))
+	this.Stop(TIMEOUT)
+}
+
+// Returns the errors of the request once it is done.
+func (this *testRequest) wait() []errors.Error {
+	<-this.done
+	for {
+		select {
+		case err := <-this.Errors():
+			this.errs = append(this.errs, err)
+		default:
+			return this.errs
+		}
+	}
+}
+
+// Returns a server on a file datastore in a new directory, with a
+// keyspace of the given documents, and the directory to remove.
+func newTestServer(t *testing.T, keyspace string, docs map[string]string) (*Server, string) {
+	dir, er := ioutil.TempDir("", "server")
+	if er != nil {
+		t.Fatal(er)
+	}
+
+	path := dir + "/default/" + keyspace
+	if er := os.MkdirAll(path, 0777); er != nil {
+		t.Fatal(er)
+	}
+
+	for key, doc := range docs {
+		if er := ioutil.WriteFile(path+"/"+key+".json", []byte(doc), 0666); er != nil {
+			t.Fatal(er)
+		}
+	}
+
+	store, err := file.NewDatastore(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	acctstore, err := accounting_stub.NewAccountingStore("")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	srvr, err := NewServer(store, nil, acctstore, "default", false, NewRequestQueue(4),
+		NewRequestQueue(4), 4, 4, 4, 0, false, false, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	datastore.SetDatastore(store)
+	return srvr, dir
+}
+
+// Runs the statement to completion.
+func runTestRequest(srvr *Server, statement string,
+	creds datastore.Credentials) ([]value.Value, []errors.Error) {
+	request := newTestRequest(statement, creds)
+	srvr.serviceRequest(request)
+	errs := request.wait()
+	return request.results, errs
+}
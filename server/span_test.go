@@ -0,0 +1,55 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"os"
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestParameterizedSpans(t *testing.T) {
+	srvr, dir := newTestServer(t, "orders", map[string]string{
+		"o1": `{"n": 1}`,
+		"o2": `{"n": 2}`,
+	})
+	defer os.RemoveAll(dir)
+
+	if _, errs := runTestRequest(srvr, "CREATE INDEX ix ON orders(n)", nil); len(errs) > 0 {
+		t.Fatalf("Unexpected errors %v", errs)
+	}
+
+	run := func(x value.Value) ([]value.Value, int) {
+		var metrics value.Tristate
+		request := &testRequest{
+			BaseRequest: *NewBaseRequest("SELECT RAW n FROM orders WHERE n = $x", nil,
+				map[string]value.Value{"x": x}, nil, "default", 0, value.NONE, metrics,
+				value.FALSE, nil, "", nil),
+			done: make(chan bool),
+		}
+		srvr.serviceRequest(request)
+		errs := request.wait()
+		return request.results, len(errs)
+	}
+
+	results, errs := run(value.NewValue(2))
+	if errs > 0 || len(results) != 1 || results[0].Actual() != 2.0 {
+		t.Errorf("Expected 1 result without errors, got %v and %d errors", results, errs)
+	}
+
+	// Spans bound by NULL or MISSING select no entries
+	for _, x := range []value.Value{value.NULL_VALUE, value.MISSING_VALUE} {
+		results, errs = run(x)
+		if errs > 0 || len(results) != 0 {
+			t.Errorf("Expected no results for %v, got %v and %d errors", x, results, errs)
+		}
+	}
+}
@@ -0,0 +1,313 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package server
+
+import (
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/logging"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/policy"
+	"github.com/couchbase/query/quota"
+	"github.com/couchbase/query/value"
+)
+
+/*
+Run the statements of a request one after another. Each statement
+returns a single result, holding its own results, status, errors,
+warnings, signature and metrics. The first statement that fails
+stops the request; the statements after it are not run. The
+datastores are not transactional, so the changes made by the
+statements before it are kept.
+
+A SET $name = expr statement binds a script variable, which the
+statements after it use as the named parameter $name. It hides a
+named parameter of the request with the same name, and lasts until
+the end of the request, or until it is set again.
+*/
+func (this *Server) serviceStatements(request Request, namespace string,
+	stmts []algebra.Statement, quotas *quota.Tracker) {
+	stop := make(chan bool, 1)
+	go request.Execute(this, value.NewValue(value.JSON.String()), stop)
+
+	// Apply server execution timeout
+	if this.Timeout() > 0 {
+		timer := time.AfterFunc(this.Timeout(), func() { request.Expire() })
+		defer timer.Stop()
+	}
+
+	output := request.Output()
+	defer output.CloseResults()
+
+	vars := make(map[string]value.Value, len(stmts))
+	for i, stmt := range stmts {
+		if request.State() != RUNNING {
+			return
+		}
+
+		start := time.Now()
+		out := newStatementOutput(output)
+		signature := this.runStatement(request, namespace, stmt, quotas, vars, out, stop)
+		if !output.Result(out.result(i+1, signature, time.Since(start))) {
+			return
+		}
+
+		if out.failed() {
+			logging.Infop("Statement failed", logging.Pair{"_id", request.Id()},
+				logging.Pair{"statement", i + 1})
+			output.Error(errors.NewServiceErrorStatementFailed(i+1, len(stmts)))
+			return
+		}
+
+		if set, ok := stmt.(*algebra.SetVariable); ok {
+			out.bind(set.Name(), vars)
+		}
+	}
+}
+
+// Plan and run one statement, returning its signature.
+func (this *Server) runStatement(request Request, namespace string, stmt algebra.Statement,
+	quotas *quota.Tracker, vars map[string]value.Value, out *statementOutput,
+	stop chan bool) value.Value {
+	restricted := policy.Applies(this.datastore, request.Roles(), request.Credentials())
+	if restricted {
+		var er errors.Error
+		stmt, er = restrictedStatement(stmt, nil)
+		if er != nil {
+			out.Error(er)
+			return nil
+		}
+	}
+
+	stmt, er := this.rewrite(request, stmt)
+	if er != nil {
+		out.Error(er)
+		return nil
+	}
+
+	prepared, err := planner.BuildPrepared(stmt, this.requestDatastore(request), this.systemstore,
+		namespace, false, restricted, this.readonly || value.ToBool(request.Readonly()),
+		request.Features())
+	if err != nil {
+		out.Error(errors.NewPlanError(err, ""))
+		return nil
+	}
+
+	if (this.readonly || value.ToBool(request.Readonly())) && !prepared.Readonly() {
+		out.Error(errors.NewServiceErrorReadonly("The server or request is read-only" +
+			" and cannot accept this write statement."))
+		return prepared.Signature()
+	}
+
+	if err := this.guard(request, prepared); err != nil {
+		out.Error(err)
+		return prepared.Signature()
+	}
+
+	if request.DryRun() {
+		result, err := this.dryRun(request, prepared)
+		if err != nil {
+			out.Error(err)
+		} else {
+			out.Result(result)
+		}
+		return _DRY_RUN_SIGNATURE
+	}
+
+	context := this.newContext(request, namespace, prepared, quotas, vars, out)
+	defer context.ReleaseSnapshots()
+
+	operator, err := execution.Build(prepared, context)
+	if err != nil {
+		out.Error(errors.NewError(err, ""))
+		return prepared.Signature()
+	}
+
+	// Forward stop notifications from the request
+	done := make(chan bool)
+	defer close(done)
+
+	go func() {
+		select {
+		case <-stop:
+			select {
+			case operator.StopChannel() <- false:
+			default:
+			}
+		case <-done:
+		}
+	}()
+
+	operator.RunOnce(context, nil)
+	return prepared.Signature()
+}
+
+/*
+statementOutput collects the results, errors, warnings and counts
+of one statement of a request. Mutation counts and phase times are
+also passed on to the request output.
+*/
+type statementOutput struct {
+	execution.Output
+	sync.Mutex
+	results       []interface{}
+	errors        []errors.Error
+	warnings      []errors.Error
+	mutationCount uint64
+	sortCount     uint64
+}
+
+func newStatementOutput(output execution.Output) *statementOutput {
+	return &statementOutput{
+		Output:  output,
+		results: make([]interface{}, 0, 16),
+	}
+}
+
+func (this *statementOutput) Result(item value.Value) bool {
+	this.Lock()
+	defer this.Unlock()
+
+	this.results = append(this.results, item)
+	return true
+}
+
+func (this *statementOutput) CloseResults() {
+}
+
+func (this *statementOutput) Fatal(err errors.Error) {
+	this.Error(err)
+}
+
+func (this *statementOutput) Error(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.errors = append(this.errors, err)
+}
+
+func (this *statementOutput) Warning(wrn errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.warnings = append(this.warnings, wrn)
+}
+
+func (this *statementOutput) AddMutationCount(i uint64) {
+	this.Lock()
+	this.mutationCount += i
+	this.Unlock()
+
+	this.Output.AddMutationCount(i)
+}
+
+func (this *statementOutput) MutationCount() uint64 {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.mutationCount
+}
+
+func (this *statementOutput) SetSortCount(i uint64) {
+	this.Lock()
+	defer this.Unlock()
+
+	this.sortCount = i
+}
+
+func (this *statementOutput) SortCount() uint64 {
+	this.Lock()
+	defer this.Unlock()
+
+	return this.sortCount
+}
+
+// Bind the value returned by a SET $name statement.
+func (this *statementOutput) bind(name string, vars map[string]value.Value) {
+	this.Lock()
+	defer this.Unlock()
+
+	if len(this.results) == 0 {
+		return
+	}
+
+	val, _ := this.results[0].(value.Value).Field("value")
+	vars[name] = val
+}
+
+func (this *statementOutput) failed() bool {
+	this.Lock()
+	defer this.Unlock()
+
+	return len(this.errors) > 0
+}
+
+// The result returned to the request for the statement.
+func (this *statementOutput) result(index int, signature value.Value,
+	elapsed time.Duration) value.Value {
+	this.Lock()
+	defer this.Unlock()
+
+	metrics := map[string]interface{}{
+		"elapsedTime": elapsed.String(),
+		"resultCount": len(this.results),
+	}
+
+	if this.mutationCount > 0 {
+		metrics["mutationCount"] = this.mutationCount
+	}
+
+	if this.sortCount > 0 {
+		metrics["sortCount"] = this.sortCount
+	}
+
+	status := "success"
+	if len(this.errors) > 0 {
+		status = "errors"
+		metrics["errorCount"] = len(this.errors)
+	}
+
+	rv := map[string]interface{}{
+		"statement": index,
+		"status":    status,
+		"results":   this.results,
+		"metrics":   metrics,
+	}
+
+	if signature != nil {
+		rv["signature"] = signature
+	}
+
+	if len(this.errors) > 0 {
+		rv["errors"] = errorObjects(this.errors)
+	}
+
+	if len(this.warnings) > 0 {
+		metrics["warningCount"] = len(this.warnings)
+		rv["warnings"] = errorObjects(this.warnings)
+	}
+
+	return value.NewValue(rv)
+}
+
+func errorObjects(errs []errors.Error) []interface{} {
+	rv := make([]interface{}, len(errs))
+	for i, err := range errs {
+		rv[i] = map[string]interface{}{
+			"code": err.Code(),
+			"msg":  err.Error(),
+		}
+	}
+	return rv
+}
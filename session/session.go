@@ -0,0 +1,363 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package session provides server-side sessions, which hold request
+settings made by SET statements. The settings of a session apply to
+every subsequent request that names it. Sessions also hold script
+variables, which provide defaults for the named parameters of those
+requests, and temporary keyspaces, which are dropped with the session.
+*/
+package session
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore/temp"
+	"github.com/couchbase/query/util"
+	"github.com/couchbase/query/value"
+)
+
+// Sessions not used for this long are discarded.
+const IDLE_TIMEOUT = 30 * time.Minute
+
+// Settings that can be made for a session, named after the request
+// parameters they provide defaults for.
+const (
+	TIMEOUT          = "timeout"
+	SCAN_CONSISTENCY = "scan_consistency"
+	SCAN_WAIT        = "scan_wait"
+	NAMESPACE        = "namespace"
+	MAX_PARALLELISM  = "max_parallelism"
+)
+
+/*
+Session holds the settings made by a client. It belongs to the users
+that created it; a session created without credentials can be used
+by anyone who knows its id.
+*/
+type Session struct {
+	sync.RWMutex
+	id       string
+	users    []string
+	created  time.Time
+	lastUsed time.Time
+	requests int64
+	settings map[string]string
+	vars     map[string]value.Value
+	temps    *temp.Keyspaces
+}
+
+func (this *Session) Id() string {
+	return this.id
+}
+
+func (this *Session) Users() []string {
+	return this.users
+}
+
+func (this *Session) Created() time.Time {
+	return this.created
+}
+
+func (this *Session) LastUsed() time.Time {
+	this.RLock()
+	defer this.RUnlock()
+	return this.lastUsed
+}
+
+// Number of requests made in the session.
+func (this *Session) Requests() int64 {
+	this.RLock()
+	defer this.RUnlock()
+	return this.requests
+}
+
+// Returns true if the session may be used by a request with the
+// given users.
+func (this *Session) Owned(users []string) bool {
+	if len(this.users) == 0 {
+		return true
+	}
+
+	if len(users) != len(this.users) {
+		return false
+	}
+
+	sorted := make([]string, len(users))
+	copy(sorted, users)
+	sort.Strings(sorted)
+	for i, user := range sorted {
+		if user != this.users[i] {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (this *Session) Setting(name string) (string, bool) {
+	this.RLock()
+	defer this.RUnlock()
+	setting, ok := this.settings[name]
+	return setting, ok
+}
+
+// A copy of the settings.
+func (this *Session) Settings() map[string]string {
+	this.RLock()
+	defer this.RUnlock()
+
+	rv := make(map[string]string, len(this.settings))
+	for name, setting := range this.settings {
+		rv[name] = setting
+	}
+
+	return rv
+}
+
+func (this *Session) Variable(name string) (value.Value, bool) {
+	this.RLock()
+	defer this.RUnlock()
+	val, ok := this.vars[name]
+	return val, ok
+}
+
+// A copy of the script variables.
+func (this *Session) Variables() map[string]value.Value {
+	this.RLock()
+	defer this.RUnlock()
+
+	rv := make(map[string]value.Value, len(this.vars))
+	for name, val := range this.vars {
+		rv[name] = val
+	}
+
+	return rv
+}
+
+// The temporary keyspaces of the session.
+func (this *Session) Temps() *temp.Keyspaces {
+	return this.temps
+}
+
+// Set a script variable. A MISSING value removes the variable.
+func (this *Session) SetVariable(name string, val value.Value) {
+	this.Lock()
+	defer this.Unlock()
+
+	if val.Type() == value.MISSING {
+		delete(this.vars, name)
+	} else {
+		this.vars[name] = val
+	}
+}
+
+// Validate and make a setting. A NULL value clears the setting.
+func (this *Session) Set(name string, val value.Value) error {
+	name = strings.ToLower(name)
+	setting, err := parseSetting(name, val)
+	if err != nil {
+		return err
+	}
+
+	this.Lock()
+	defer this.Unlock()
+
+	if val.Type() == value.NULL {
+		delete(this.settings, name)
+	} else {
+		this.settings[name] = setting
+	}
+
+	return nil
+}
+
+func (this *Session) use(now time.Time) {
+	this.Lock()
+	defer this.Unlock()
+	this.lastUsed = now
+	this.requests++
+}
+
+func (this *Session) expired(now time.Time) bool {
+	this.RLock()
+	defer this.RUnlock()
+	return now.Sub(this.lastUsed) > IDLE_TIMEOUT
+}
+
+func parseSetting(name string, val value.Value) (string, error) {
+	if val.Type() == value.NULL {
+		switch name {
+		case TIMEOUT, SCAN_CONSISTENCY, SCAN_WAIT, NAMESPACE, MAX_PARALLELISM:
+			return "", nil
+		default:
+			return "", fmt.Errorf("Unknown session setting %s.", name)
+		}
+	}
+
+	switch name {
+	case TIMEOUT, SCAN_WAIT:
+		s, ok := val.Actual().(string)
+		if ok {
+			if _, err := time.ParseDuration(s); err == nil {
+				return s, nil
+			}
+		}
+	case SCAN_CONSISTENCY:
+		s, ok := val.Actual().(string)
+		if ok {
+			s = strings.ToLower(s)
+			switch s {
+			case "not_bounded", "request_plus", "statement_plus":
+				return s, nil
+			}
+		}
+	case NAMESPACE:
+		s, ok := val.Actual().(string)
+		if ok && s != "" {
+			return s, nil
+		}
+	case MAX_PARALLELISM:
+		n, ok := val.Actual().(float64)
+		if ok && n > 0 && n == float64(int(n)) {
+			return strconv.Itoa(int(n)), nil
+		}
+	default:
+		return "", fmt.Errorf("Unknown session setting %s.", name)
+	}
+
+	return "", fmt.Errorf("Invalid value %v for session setting %s.", val, name)
+}
+
+type sessions struct {
+	sync.RWMutex
+	sessions map[string]*Session
+}
+
+var _SESSIONS = &sessions{
+	sessions: make(map[string]*Session),
+}
+
+// Create a session for the given users.
+func New(users []string) *Session {
+	sorted := make([]string, len(users))
+	copy(sorted, users)
+	sort.Strings(sorted)
+
+	id, _ := util.UUID()
+	now := time.Now()
+	rv := &Session{
+		id:       id,
+		users:    sorted,
+		created:  now,
+		lastUsed: now,
+		settings: make(map[string]string, 4),
+		vars:     make(map[string]value.Value, 4),
+		temps:    temp.NewKeyspaces(),
+	}
+
+	_SESSIONS.Lock()
+	defer _SESSIONS.Unlock()
+	_SESSIONS.sessions[id] = rv
+	return rv
+}
+
+// Returns nil if there is no such session, or if it has expired.
+// Marks the session as used.
+func Get(id string) *Session {
+	now := time.Now()
+
+	_SESSIONS.Lock()
+	defer _SESSIONS.Unlock()
+
+	rv, ok := _SESSIONS.sessions[id]
+	if !ok {
+		return nil
+	}
+
+	if rv.expired(now) {
+		delete(_SESSIONS.sessions, id)
+		rv.temps.DropAll()
+		return nil
+	}
+
+	rv.use(now)
+	return rv
+}
+
+func Remove(id string) bool {
+	_SESSIONS.Lock()
+	defer _SESSIONS.Unlock()
+
+	s, ok := _SESSIONS.sessions[id]
+	if !ok {
+		return false
+	}
+
+	delete(_SESSIONS.sessions, id)
+	s.temps.DropAll()
+	return true
+}
+
+// Discard expired sessions. Returns the number discarded.
+func Purge() int {
+	now := time.Now()
+
+	_SESSIONS.Lock()
+	defer _SESSIONS.Unlock()
+
+	n := 0
+	for id, s := range _SESSIONS.sessions {
+		if s.expired(now) {
+			delete(_SESSIONS.sessions, id)
+			s.temps.DropAll()
+			n++
+		}
+	}
+
+	return n
+}
+
+// All live sessions, sorted by id. Expired sessions are discarded.
+func Sessions() []*Session {
+	now := time.Now()
+
+	_SESSIONS.Lock()
+	defer _SESSIONS.Unlock()
+
+	ids := make([]string, 0, len(_SESSIONS.sessions))
+	for id, s := range _SESSIONS.sessions {
+		if s.expired(now) {
+			delete(_SESSIONS.sessions, id)
+			s.temps.DropAll()
+		} else {
+			ids = append(ids, id)
+		}
+	}
+	sort.Strings(ids)
+
+	rv := make([]*Session, len(ids))
+	for i, id := range ids {
+		rv[i] = _SESSIONS.sessions[id]
+	}
+
+	return rv
+}
+
+func Count() int {
+	_SESSIONS.RLock()
+	defer _SESSIONS.RUnlock()
+	return len(_SESSIONS.sessions)
+}
@@ -0,0 +1,91 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package session
+
+import (
+	"testing"
+	"time"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestSettings(t *testing.T) {
+	s := New([]string{"bob", "alice"})
+	defer Remove(s.Id())
+
+	if Get(s.Id()) != s {
+		t.Errorf("Expected session %s", s.Id())
+	}
+
+	if !s.Owned([]string{"alice", "bob"}) || s.Owned([]string{"bob"}) {
+		t.Errorf("Expected session owned by alice and bob only")
+	}
+
+	if err := s.Set("TIMEOUT", value.NewValue("10s")); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if err := s.Set(MAX_PARALLELISM, value.NewValue(4.0)); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if err := s.Set(SCAN_CONSISTENCY, value.NewValue("sometimes")); err == nil {
+		t.Errorf("Expected invalid scan consistency")
+	}
+
+	if err := s.Set("color", value.NewValue("blue")); err == nil {
+		t.Errorf("Expected unknown setting")
+	}
+
+	settings := s.Settings()
+	if len(settings) != 2 || settings[TIMEOUT] != "10s" || settings[MAX_PARALLELISM] != "4" {
+		t.Errorf("Unexpected settings %v", settings)
+	}
+
+	if err := s.Set(TIMEOUT, value.NULL_VALUE); err != nil {
+		t.Errorf("Unexpected error %v", err)
+	}
+
+	if _, ok := s.Setting(TIMEOUT); ok {
+		t.Errorf("Expected timeout to be cleared")
+	}
+}
+
+func TestExpiry(t *testing.T) {
+	s := New(nil)
+	defer Remove(s.Id())
+
+	if !s.Owned([]string{"anyone"}) {
+		t.Errorf("Expected session without users to be usable by anyone")
+	}
+
+	s.lastUsed = time.Now().Add(-IDLE_TIMEOUT - time.Minute)
+	if Get(s.Id()) != nil {
+		t.Errorf("Expected session %s to have expired", s.Id())
+	}
+}
+
+func TestVariables(t *testing.T) {
+	s := New(nil)
+	defer Remove(s.Id())
+
+	s.SetVariable("limit", value.NewValue(10.0))
+	s.SetVariable("name", value.NULL_VALUE)
+
+	vars := s.Variables()
+	if len(vars) != 2 || vars["limit"].Actual() != 10.0 || vars["name"].Type() != value.NULL {
+		t.Errorf("Unexpected variables %v", vars)
+	}
+
+	s.SetVariable("limit", value.MISSING_VALUE)
+	if _, ok := s.Variable("limit"); ok {
+		t.Errorf("Expected limit to be removed")
+	}
+}
@@ -98,7 +98,11 @@ func HandleInteractiveMode(tiServer, prompt string) {
 			}
 			if queryString != "" {
 				UpdateHistory(liner, homeDir, queryString+QRY_EOL)
-				err = execute_internal(tiServer, queryString, os.Stdout)
+				if isFormatCommand(queryString) {
+					err = format_internal(queryString, os.Stdout)
+				} else {
+					err = execute_internal(tiServer, queryString, os.Stdout)
+				}
 				if err != nil {
 					s_err := handleError(err, tiServer)
 					fmt.Println(fgRed, "ERROR", s_err.Code(), ":", s_err, reset)
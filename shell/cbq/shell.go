@@ -11,19 +11,27 @@ package main
 
 import (
 	"crypto/tls"
+	"encoding/json"
 	"flag"
 	"fmt"
-	"io"
+	"io/ioutil"
 	"net/http"
 	"os"
 	"path/filepath"
 	"strings"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/parser/n1ql"
 )
 
 var tiServer = flag.String("engine", "http://localhost:8093/", "URL to the query service(cbq-engine). By default, cbq connects to: http://localhost:8093\n\n Examples:\n\t cbq \n\t\t Connects to local query node. Same as: cbq -engine=http://localhost:8093\n\t cbq -engine=http://172.23.107.18:8093 \n\t\t Connects to query node at 172.23.107.18 Port 8093 \n\t cbq -engine=https://my.secure.node.com:8093 \n\t\t Connects to query node at my.secure.node.com:8093 using secure https protocol.\n")
 
 var quietFlag = flag.Bool("quiet", false, "Enable/Disable startup connection message for the shell \n\t\t Default : false \n\t\t Possible Values : true/false \n")
 
+var formatIndent = flag.Int("format-indent", 4, "Number of spaces per indentation level of statements printed by \\FORMAT \n")
+
+var formatUpper = flag.Bool("format-upper", true, "Print the keywords of statements printed by \\FORMAT in upper case \n\t\t Default : true \n\t\t Possible Values : true/false \n")
+
 func main() {
 	flag.Parse()
 	if strings.HasSuffix(*tiServer, "/") == false {
@@ -35,9 +43,16 @@ func main() {
 	HandleInteractiveMode(*tiServer, filepath.Base(os.Args[0]))
 }
 
+// Id of the session started by the first SET statement, which is
+// passed on to all subsequent requests.
+var sessionId string
+
 func execute_internal(tiServer, line string, w *os.File) error {
 
 	url := tiServer + "query"
+	if sessionId != "" {
+		url += "?session_id=" + sessionId
+	}
 	tr := &http.Transport{}
 	if strings.HasPrefix(url, "https") {
 		tr.TLSClientConfig = &tls.Config{InsecureSkipVerify: true}
@@ -48,9 +63,55 @@ func execute_internal(tiServer, line string, w *os.File) error {
 		return err
 	}
 	defer resp.Body.Close()
-	io.Copy(w, resp.Body)
+
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	w.Write(body)
 	w.WriteString("\n")
 	w.Sync()
 
+	if strings.HasPrefix(strings.ToLower(strings.TrimSpace(line)), "set ") {
+		setSession(body)
+	}
+
+	return nil
+}
+
+func setSession(body []byte) {
+	var response struct {
+		Results []struct {
+			Session string `json:"session"`
+		} `json:"results"`
+	}
+
+	err := json.Unmarshal(body, &response)
+	if err == nil && len(response.Results) == 1 && response.Results[0].Session != "" {
+		sessionId = response.Results[0].Session
+	}
+}
+
+// Shell command that prints a statement formatted instead of
+// running it, as in \FORMAT SELECT ...;
+const FORMAT_CMD = "\\format"
+
+func isFormatCommand(line string) bool {
+	fields := strings.Fields(line)
+	return len(fields) > 0 && strings.ToLower(fields[0]) == FORMAT_CMD
+}
+
+func format_internal(line string, w *os.File) error {
+	line = strings.TrimSpace(line)[len(FORMAT_CMD):]
+	formatter := algebra.NewFormatter(*formatIndent, *formatUpper)
+	text, err := n1ql.FormatStatement(line, formatter)
+	if err != nil {
+		return err
+	}
+
+	w.WriteString(text)
+	w.WriteString("\n")
+	w.Sync()
 	return nil
 }
@@ -0,0 +1,254 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package test benchmarks the execution operators. Each case is a
+statement that exercises a pipeline of operators, and is run against
+the mock datastore at several fan-outs (maximum parallelism).
+*/
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/execution"
+	"github.com/couchbase/query/logging"
+	log_resolver "github.com/couchbase/query/logging/resolver"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/plan"
+	"github.com/couchbase/query/planner"
+	"github.com/couchbase/query/value"
+)
+
+func init() {
+	logger, _ := log_resolver.NewLogger("golog")
+	logging.SetLogger(logger)
+}
+
+// Two keyspaces, b0 and b1, of the same orders, so that they join.
+const STORE = "mock:namespaces=1,keyspaces=2,items=10000,template=orders,seed=1"
+
+const NAMESPACE = "p0"
+
+var FanOuts = []int{1, 4, 16}
+
+type Case struct {
+	Name      string
+	Statement string
+}
+
+var Cases = []*Case{
+	{"scan", "SELECT RAW meta().id FROM b0"},
+	{"filter_project", "SELECT id, i * 2 AS d FROM b0 WHERE i % 3 = 0"},
+	{"project_nested", "SELECT customer.address.city, ARRAY l.qty FOR l IN lineitems END AS qty FROM b0"},
+	{"join", "SELECT b0.id, b1.total FROM b0 JOIN b1 ON KEYS b0.id"},
+	{"nest", "SELECT b0.id, ARRAY_LENGTH(n) AS c FROM b0 NEST b1 AS n ON KEYS [b0.id]"},
+	{"unnest", "SELECT l.product FROM b0 UNNEST b0.lineitems AS l"},
+	{"group", "SELECT status, COUNT(*) AS c, SUM(total) AS t FROM b0 GROUP BY status"},
+	{"group_many", "SELECT customer.id, COUNT(*) AS c FROM b0 GROUP BY customer.id"},
+	{"distinct", "SELECT DISTINCT customer.address.city FROM b0"},
+	{"sort", "SELECT id, total FROM b0 ORDER BY total DESC"},
+	{"sort_limit", "SELECT id, total FROM b0 ORDER BY total DESC LIMIT 10"},
+}
+
+/*
+Runner plans the cases once, and executes their plans. Only execution
+is measured.
+*/
+type Runner struct {
+	datastore   datastore.Datastore
+	systemstore datastore.Datastore
+	plans       map[string]plan.Operator
+}
+
+func NewRunner(path string) (*Runner, error) {
+	ds, err := mock.NewDatastore(path)
+	if err != nil {
+		return nil, err
+	}
+
+	sys, err := system.NewDatastore(ds, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Runner{
+		datastore:   ds,
+		systemstore: sys,
+		plans:       make(map[string]plan.Operator, len(Cases)),
+	}, nil
+}
+
+func (this *Runner) Plan(c *Case) (plan.Operator, error) {
+	if op, ok := this.plans[c.Name]; ok {
+		return op, nil
+	}
+
+	stmt, err := n1ql.ParseStatement(c.Statement)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %v", c.Statement, err)
+	}
+
+	op, err := planner.Build(stmt, this.datastore, this.systemstore, NAMESPACE, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("Error planning %s: %v", c.Statement, err)
+	}
+
+	this.plans[c.Name] = op
+	return op, nil
+}
+
+// Executes a case, and returns the number of results.
+func (this *Runner) Run(c *Case, fanOut int) (int, error) {
+	op, err := this.Plan(c)
+	if err != nil {
+		return 0, err
+	}
+
+	output := &output{}
+	context := execution.NewContext(c.Name, this.datastore, this.systemstore, NAMESPACE,
+		false, fanOut, nil, nil, nil, datastore.UNBOUNDED, nil, output)
+
+	exec, err := execution.Build(op, context)
+	if err != nil {
+		return 0, err
+	}
+
+	exec.RunOnce(context, nil)
+	for _ = range exec.ItemChannel() {
+	}
+
+	if output.err != nil {
+		return 0, output.err
+	}
+
+	return output.count, nil
+}
+
+// output counts the results of a case, and keeps its first error.
+type output struct {
+	sync.Mutex
+	count int
+	err   errors.Error
+}
+
+func (this *output) Result(item value.Value) bool {
+	this.Lock()
+	defer this.Unlock()
+	this.count++
+	return true
+}
+
+func (this *output) CloseResults() {
+}
+
+func (this *output) Fatal(err errors.Error) {
+	this.Error(err)
+}
+
+func (this *output) Error(err errors.Error) {
+	this.Lock()
+	defer this.Unlock()
+	if this.err == nil {
+		this.err = err
+	}
+}
+
+func (this *output) Warning(wrn errors.Error) {
+}
+
+func (this *output) AddMutationCount(uint64) {
+}
+
+func (this *output) MutationCount() uint64 {
+	return 0
+}
+
+func (this *output) AchieveDurability(datastore.Durability) {
+}
+
+func (this *output) AchievedDurability() datastore.Durability {
+	return ""
+}
+
+func (this *output) SetSortCount(uint64) {
+}
+
+func (this *output) SortCount() uint64 {
+	return 0
+}
+
+func (this *output) AddPhaseTime(phase string, duration time.Duration) {
+}
+
+func (this *output) PhaseTimes() map[string]time.Duration {
+	return nil
+}
+
+// Result of a benchmark, as written to a baseline.
+type Result struct {
+	Name        string `json:"name"`
+	NsPerOp     int64  `json:"ns_per_op"`
+	AllocsPerOp int64  `json:"allocs_per_op"`
+	BytesPerOp  int64  `json:"bytes_per_op"`
+}
+
+func ReadBaseline(path string) (map[string]*Result, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []*Result
+	err = json.Unmarshal(bytes, &results)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", path, err)
+	}
+
+	rv := make(map[string]*Result, len(results))
+	for _, r := range results {
+		rv[r.Name] = r
+	}
+
+	return rv, nil
+}
+
+// Results are sorted by name, so that baselines diff cleanly.
+func WriteBaseline(path string, results []*Result) error {
+	sort.Sort(byName(results))
+	bytes, err := json.MarshalIndent(results, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(bytes, '\n'), 0644)
+}
+
+type byName []*Result
+
+func (this byName) Len() int {
+	return len(this)
+}
+
+func (this byName) Less(i, j int) bool {
+	return this[i].Name < this[j].Name
+}
+
+func (this byName) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
@@ -0,0 +1,14 @@
+#!/bin/bash
+#
+# Run the operator benchmarks.
+#
+#   ./bench.sh            write the results to baseline.json
+#   ./bench.sh compare    compare the results with baseline.json
+
+cd `dirname $0`
+
+if [ "$1" == "compare" ]; then
+    go test -run TestBaseline -v -args -compare baseline.json
+else
+    go test -run TestBaseline -v -args -baseline baseline.json
+fi
@@ -0,0 +1,133 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package test
+
+import (
+	"flag"
+	"fmt"
+	"testing"
+)
+
+var baseline = flag.String("baseline", "", "Write the benchmark results to this baseline file")
+var compare = flag.String("compare", "", "Compare the benchmark results with this baseline file")
+var threshold = flag.Float64("threshold", 10, "Percentage by which a benchmark may slow down")
+
+func caseName(c *Case, fanOut int) string {
+	return fmt.Sprintf("%s/fanout=%d", c.Name, fanOut)
+}
+
+// Every case must run, and return results.
+func TestCases(t *testing.T) {
+	r, err := NewRunner(STORE)
+	if err != nil {
+		t.Fatalf("Error opening datastore: %v", err)
+	}
+
+	for _, c := range Cases {
+		count, err := r.Run(c, 4)
+		if err != nil {
+			t.Errorf("%s: %v", c.Name, err)
+		} else if count == 0 {
+			t.Errorf("%s: no results", c.Name)
+		}
+	}
+}
+
+func BenchmarkOperators(b *testing.B) {
+	r, err := NewRunner(STORE)
+	if err != nil {
+		b.Fatalf("Error opening datastore: %v", err)
+	}
+
+	for _, c := range Cases {
+		for _, fanOut := range FanOuts {
+			b.Run(caseName(c, fanOut), benchmarkCase(r, c, fanOut))
+		}
+	}
+}
+
+func benchmarkCase(r *Runner, c *Case, fanOut int) func(b *testing.B) {
+	return func(b *testing.B) {
+		_, err := r.Plan(c)
+		if err != nil {
+			b.Fatal(err)
+		}
+
+		b.ReportAllocs()
+		b.ResetTimer()
+		for i := 0; i < b.N; i++ {
+			_, err = r.Run(c, fanOut)
+			if err != nil {
+				b.Fatal(err)
+			}
+		}
+	}
+}
+
+/*
+Runs the benchmarks, and writes their results to a baseline, or
+compares them with one. Benchmarks that slow down by more than the
+threshold fail. Skipped unless -baseline or -compare is given.
+*/
+func TestBaseline(t *testing.T) {
+	if *baseline == "" && *compare == "" {
+		t.Skip("neither -baseline nor -compare given")
+	}
+
+	var expected map[string]*Result
+	if *compare != "" {
+		var err error
+		expected, err = ReadBaseline(*compare)
+		if err != nil {
+			t.Fatalf("Error reading baseline: %v", err)
+		}
+	}
+
+	r, err := NewRunner(STORE)
+	if err != nil {
+		t.Fatalf("Error opening datastore: %v", err)
+	}
+
+	results := make([]*Result, 0, len(Cases)*len(FanOuts))
+	for _, c := range Cases {
+		for _, fanOut := range FanOuts {
+			name := caseName(c, fanOut)
+			br := testing.Benchmark(benchmarkCase(r, c, fanOut))
+			result := &Result{
+				Name:        name,
+				NsPerOp:     br.NsPerOp(),
+				AllocsPerOp: br.AllocsPerOp(),
+				BytesPerOp:  br.AllocedBytesPerOp(),
+			}
+			results = append(results, result)
+
+			prev, ok := expected[name]
+			if !ok {
+				t.Logf("%s: %d ns/op, %d allocs/op", name, result.NsPerOp, result.AllocsPerOp)
+				continue
+			}
+
+			change := 100 * float64(result.NsPerOp-prev.NsPerOp) / float64(prev.NsPerOp)
+			t.Logf("%s: %d ns/op (%+.1f%%), %d allocs/op (was %d)", name, result.NsPerOp,
+				change, result.AllocsPerOp, prev.AllocsPerOp)
+			if change > *threshold {
+				t.Errorf("%s: slowed down by %.1f%%, from %d to %d ns/op", name, change,
+					prev.NsPerOp, result.NsPerOp)
+			}
+		}
+	}
+
+	if *baseline != "" {
+		err = WriteBaseline(*baseline, results)
+		if err != nil {
+			t.Errorf("Error writing baseline: %v", err)
+		}
+	}
+}
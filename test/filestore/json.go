@@ -138,11 +138,10 @@ func Run(mockServer *server.Server, q string) ([]interface{}, []errors.Error, er
 		response:    mr,
 	}
 
-	select {
-	case mockServer.Channel() <- query:
+	if mockServer.Enqueue(query) {
 		// Wait until the request exits.
 		<-query.CloseNotify()
-	default:
+	} else {
 		// Timeout.
 		return nil, nil, errors.NewError(nil, "Query timed out")
 	}
@@ -174,10 +173,10 @@ func Start(site, pool string) *server.Server {
 		)
 	}
 
-	channel := make(server.RequestChannel, 10)
-	plusChannel := make(server.RequestChannel, 10)
+	queue := server.NewRequestQueue(10)
+	plusQueue := server.NewRequestQueue(10)
 	server, err := server.NewServer(datastore, configstore, acctstore, "json",
-		false, channel, plusChannel, 4, 4, 0, 0, false, false, false)
+		false, queue, plusQueue, 4, 4, 0, 0, false, false, false)
 	if err != nil {
 		logging.Errorp(err.Error())
 		os.Exit(1)
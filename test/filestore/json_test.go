@@ -209,3 +209,19 @@ func doResultsMatch(t *testing.T, resultsActual, resultsExpected []interface{},
 		return
 	}
 }
+
+func TestNowInScript(t *testing.T) {
+	qc := start()
+
+	// The statements of a request observe the same instant
+	r, _, err := Run(qc, "SELECT NOW_MILLIS() AS n, NOW_STR() AS s; SELECT NOW_MILLIS() AS n, NOW_STR() AS s")
+	if err != nil || len(r) != 2 {
+		t.Fatalf("expected 2 statement results, got %v, err %v", r, err)
+	}
+
+	first, _ := json.Marshal(r[0].(map[string]interface{})["results"])
+	second, _ := json.Marshal(r[1].(map[string]interface{})["results"])
+	if string(first) != string(second) {
+		t.Errorf("expected the same instant, got %s and %s", first, second)
+	}
+}
@@ -0,0 +1,79 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package multistore
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/couchbase/query/server"
+)
+
+/*
+Runs every functionality test under test_cases against the target
+selected by DATASTORE_ENV: the inserts, the case files, and then
+the cleanup, which runs even if a case fails.
+*/
+func TestConformance(t *testing.T) {
+	target, err := SelectedTarget()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	qc := target.Start()
+
+	dirs, err := filepath.Glob("test_cases/*")
+	if err != nil {
+		t.Fatalf("glob failed: %v", err)
+	}
+
+	for _, dir := range dirs {
+		cases, err := filepath.Glob(filepath.Join(dir, "case_*.json"))
+		if err != nil {
+			t.Errorf("glob failed: %v", err)
+			continue
+		}
+
+		if len(cases) == 0 {
+			continue
+		}
+
+		t.Logf("Running %s against %s", dir, target.Name)
+		if runConformanceFile(t, qc, target, filepath.Join(dir, "insert.json")) {
+			for _, c := range cases {
+				if !runConformanceFile(t, qc, target, c) {
+					break
+				}
+			}
+		}
+
+		runConformanceFile(t, qc, target, filepath.Join(dir, "cleanup.json"))
+	}
+}
+
+// Missing files are ignored.
+func runConformanceFile(t *testing.T, qc *server.Server, target *Target, fname string) bool {
+	if _, err := os.Stat(fname); os.IsNotExist(err) {
+		return true
+	}
+
+	stmt, err := RunCaseFile(fname, qc, target)
+	if err != nil {
+		t.Errorf("%s: %v", target.Name, err)
+		return false
+	}
+
+	if stmt != "" {
+		t.Logf(" %v\n", stmt)
+	}
+
+	return true
+}
@@ -172,10 +172,21 @@ the input argument (q) string using the NewBaseRequest method
 as defined in the server request.go.
 */
 func Run(mockServer *server.Server, q, namespace string) ([]interface{}, []errors.Error, errors.Error) {
+	return RunSeeded(mockServer, q, namespace, nil)
+}
+
+/*
+Like Run, but a non-nil seed evaluates the query deterministically,
+so that its results are reproducible.
+*/
+func RunSeeded(mockServer *server.Server, q, namespace string, seed *int64) ([]interface{}, []errors.Error, errors.Error) {
 	var metrics value.Tristate
 	consistency := &scanConfigImpl{scan_level: datastore.SCAN_PLUS}
 
 	base := server.NewBaseRequest(q, nil, nil, nil, namespace, 0, value.FALSE, metrics, value.TRUE, consistency, "", nil)
+	if seed != nil {
+		base.SetSeed(*seed)
+	}
 
 	mr := &MockResponse{
 		results: []interface{}{}, warnings: []errors.Error{}, done: make(chan bool),
@@ -186,11 +197,10 @@ func Run(mockServer *server.Server, q, namespace string) ([]interface{}, []error
 		response:    mr,
 	}
 
-	select {
-	case mockServer.Channel() <- query:
+	if mockServer.Enqueue(query) {
 		// Wait until the request exits.
 		<-query.CloseNotify()
-	default:
+	} else {
 		// Timeout.
 		return nil, nil, errors.NewError(nil, "Query timed out")
 	}
@@ -226,10 +236,10 @@ func Start(site, pool, namespace string) *server.Server {
 		)
 	}
 
-	channel := make(server.RequestChannel, 10)
-	plusChannel := make(server.RequestChannel, 10)
+	queue := server.NewRequestQueue(10)
+	plusQueue := server.NewRequestQueue(10)
 	server, err := server.NewServer(datastore, configstore, acctstore, namespace,
-		false, channel, plusChannel, 4, 4, 0, 0, false, false, false)
+		false, queue, plusQueue, 4, 4, 0, 0, false, false, false)
 	if err != nil {
 		logging.Errorp(err.Error())
 		os.Exit(1)
@@ -241,7 +251,17 @@ func Start(site, pool, namespace string) *server.Server {
 }
 
 func FtestCaseFile(fname string, qc *server.Server, namespace string) (fin_stmt string, errstring error) {
+	return RunCaseFile(fname, qc, targetForNamespace(namespace))
+}
+
+/*
+Runs the cases of a file against a target. Cases skipped for the
+target are ignored, and the target's overrides replace the
+expected results of the other cases.
+*/
+func RunCaseFile(fname string, qc *server.Server, target *Target) (fin_stmt string, errstring error) {
 	fin_stmt = ""
+	namespace := target.Namespace
 
 	/* Reads the input file and returns its contents in the form
 	   of a byte array.
@@ -268,6 +288,11 @@ func FtestCaseFile(fname string, qc *server.Server, namespace string) (fin_stmt
 			}
 		}
 
+		if skipCase(c, target.Name) {
+			continue
+		}
+		overrideCase(c, target.Name)
+
 		/* Handles all queries to be run against CBServer and Datastore */
 		v, ok := c["statements"]
 		if !ok || v == nil {
@@ -277,7 +302,13 @@ func FtestCaseFile(fname string, qc *server.Server, namespace string) (fin_stmt
 		statements := v.(string)
 		//t.Logf("  %d: %v\n", i, statements)
 		fin_stmt = strconv.Itoa(i) + ": " + statements
-		resultsActual, _, errActual := Run(qc, statements, namespace)
+		var seed *int64
+		if v, ok := c["seed"].(float64); ok {
+			s := int64(v)
+			seed = &s
+		}
+
+		resultsActual, _, errActual := RunSeeded(qc, statements, namespace, seed)
 
 		errExpected := ""
 		v, ok = c["error"]
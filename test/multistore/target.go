@@ -0,0 +1,139 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package multistore
+
+import (
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+
+	"github.com/couchbase/query/server"
+)
+
+/*
+The datastore targeted by the conformance tests is selected by
+name with this environment variable; it defaults to file.
+*/
+const DATASTORE_ENV = "MULTISTORE_DATASTORE"
+
+const DEFAULT_TARGET = "file"
+
+/*
+Target is a datastore the JSON case files can be run against. Case
+files can skip individual cases for a target, and override their
+expected results, by target name:
+
+	{
+	    "statements": "...",
+	    "results": [ ... ],
+	    "skip": { "mock": "INSERT is not supported" },
+	    "overrides": { "couchbase": { "results": [ ... ] } }
+	}
+*/
+type Target struct {
+	Name      string
+	Site      string
+	Pool      string
+	Namespace string
+}
+
+func (this *Target) Start() *server.Server {
+	return Start(this.Site, this.Pool, this.Namespace)
+}
+
+var _TARGETS = map[string]*Target{}
+
+func RegisterTarget(target *Target) {
+	_TARGETS[target.Name] = target
+}
+
+func init() {
+	RegisterTarget(&Target{
+		Name:      "file",
+		Site:      "dir:",
+		Pool:      "data/",
+		Namespace: Namespace_FS,
+	})
+
+	RegisterTarget(&Target{
+		Name:      "mock",
+		Site:      "mock:",
+		Pool:      "",
+		Namespace: "p0",
+	})
+
+	RegisterTarget(&Target{
+		Name:      "couchbase",
+		Site:      Site_CBS,
+		Pool:      Auth_param + "@" + Pool_CBS,
+		Namespace: Namespace_CBS,
+	})
+}
+
+// The target selected by DATASTORE_ENV.
+func SelectedTarget() (*Target, error) {
+	name := os.Getenv(DATASTORE_ENV)
+	if name == "" {
+		name = DEFAULT_TARGET
+	}
+
+	target, ok := _TARGETS[name]
+	if !ok {
+		names := make([]string, 0, len(_TARGETS))
+		for n, _ := range _TARGETS {
+			names = append(names, n)
+		}
+		sort.Strings(names)
+
+		return nil, fmt.Errorf("Unknown datastore %s in %s; expected one of %s.",
+			name, DATASTORE_ENV, strings.Join(names, ", "))
+	}
+
+	return target, nil
+}
+
+// Tests that start their own server are matched to a target by
+// namespace, so that skips and overrides still apply.
+func targetForNamespace(namespace string) *Target {
+	for _, target := range _TARGETS {
+		if target.Namespace == namespace {
+			return target
+		}
+	}
+
+	return &Target{Namespace: namespace}
+}
+
+func skipCase(c map[string]interface{}, target string) bool {
+	skip, ok := c["skip"].(map[string]interface{})
+	if !ok {
+		return false
+	}
+
+	_, ok = skip[target]
+	return ok
+}
+
+func overrideCase(c map[string]interface{}, target string) {
+	overrides, ok := c["overrides"].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	override, ok := overrides[target].(map[string]interface{})
+	if !ok {
+		return
+	}
+
+	for k, v := range override {
+		c[k] = v
+	}
+}
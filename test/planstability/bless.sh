@@ -0,0 +1,6 @@
+#!/bin/bash
+#
+# Rewrite the plan snapshots from the current planner. Run this after an
+# intended planner change, and review the snapshot diff before committing.
+
+cd `dirname $0` && go test -run TestPlanStability -args -bless
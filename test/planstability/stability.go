@@ -0,0 +1,190 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package test checks the stability of query plans. It plans a corpus
+of statements against the file and mock datastores, and compares the
+normalized EXPLAIN output of each statement with a blessed snapshot.
+*/
+package test
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/file"
+	"github.com/couchbase/query/datastore/mock"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/errors"
+	"github.com/couchbase/query/parser/n1ql"
+	"github.com/couchbase/query/planner"
+)
+
+/*
+Store describes a datastore the corpus is planned against. Each
+store has its own corpus and snapshots, under corpus/<name> and
+snapshots/<name>.
+*/
+type Store struct {
+	Name      string
+	Path      string
+	Namespace string
+	open      func(path string) (datastore.Datastore, errors.Error)
+}
+
+var Stores = []*Store{
+	&Store{
+		Name:      "file",
+		Path:      "../filestore/json",
+		Namespace: "default",
+		open:      file.NewDatastore,
+	},
+	&Store{
+		Name:      "mock",
+		Path:      "mock:namespaces=1,keyspaces=2,items=100",
+		Namespace: "p0",
+		open:      mock.NewDatastore,
+	},
+}
+
+type Case struct {
+	Statements string          `json:"statements"`
+	Disabled   bool            `json:"disabled,omitempty"`
+	Plan       json.RawMessage `json:"plan,omitempty"`
+}
+
+/*
+Planner plans statements against a store. Plans do not depend on
+the data, so the same planner can be used for the whole corpus.
+*/
+type Planner struct {
+	store       *Store
+	datastore   datastore.Datastore
+	systemstore datastore.Datastore
+}
+
+func NewPlanner(store *Store) (*Planner, error) {
+	ds, err := store.open(store.Path)
+	if err != nil {
+		return nil, err
+	}
+
+	sys, err := system.NewDatastore(ds, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Planner{
+		store:       store,
+		datastore:   ds,
+		systemstore: sys,
+	}, nil
+}
+
+// Returns the normalized EXPLAIN output of a statement.
+func (this *Planner) Explain(statement string) ([]byte, error) {
+	stmt, err := n1ql.ParseStatement(statement)
+	if err != nil {
+		return nil, fmt.Errorf("Error parsing %s: %v", statement, err)
+	}
+
+	op, err := planner.Build(stmt, this.datastore, this.systemstore, this.store.Namespace, false, false)
+	if err != nil {
+		return nil, fmt.Errorf("Error planning %s: %v", statement, err)
+	}
+
+	bytes, err := json.Marshal(op)
+	if err != nil {
+		return nil, err
+	}
+
+	return Normalize(bytes)
+}
+
+/*
+Normalize re-encodes a plan with sorted object keys and fixed
+indentation, so that snapshots only change when plans do.
+*/
+func Normalize(plan []byte) ([]byte, error) {
+	var decoded interface{}
+	err := json.Unmarshal(plan, &decoded)
+	if err != nil {
+		return nil, err
+	}
+
+	return json.MarshalIndent(decoded, "", "    ")
+}
+
+// Corpus files of a store, by name.
+func CorpusFiles(store *Store) ([]string, error) {
+	matches, err := filepath.Glob(filepath.Join("corpus", store.Name, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	names := make([]string, len(matches))
+	for i, m := range matches {
+		names[i] = strings.TrimSuffix(filepath.Base(m), ".json")
+	}
+
+	return names, nil
+}
+
+func ReadCorpus(store *Store, name string) ([]*Case, error) {
+	return readCases(filepath.Join("corpus", store.Name, name+".json"))
+}
+
+// Returns nil if the snapshot has not been blessed yet.
+func ReadSnapshot(store *Store, name string) ([]*Case, error) {
+	cases, err := readCases(snapshotPath(store, name))
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+
+	return cases, err
+}
+
+func WriteSnapshot(store *Store, name string, cases []*Case) error {
+	path := snapshotPath(store, name)
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	bytes, err := json.MarshalIndent(cases, "", "    ")
+	if err != nil {
+		return err
+	}
+
+	return ioutil.WriteFile(path, append(bytes, '\n'), 0644)
+}
+
+func snapshotPath(store *Store, name string) string {
+	return filepath.Join("snapshots", store.Name, name+".json")
+}
+
+func readCases(path string) ([]*Case, error) {
+	bytes, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []*Case
+	err = json.Unmarshal(bytes, &cases)
+	if err != nil {
+		return nil, fmt.Errorf("Error reading %s: %v", path, err)
+	}
+
+	return cases, nil
+}
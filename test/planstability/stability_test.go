@@ -0,0 +1,94 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package test
+
+import (
+	"bytes"
+	"flag"
+	"testing"
+)
+
+var bless = flag.Bool("bless", false, "Rewrite the plan snapshots from the current planner")
+
+func TestPlanStability(t *testing.T) {
+	for _, store := range Stores {
+		p, err := NewPlanner(store)
+		if err != nil {
+			t.Fatalf("Error opening %s datastore: %v", store.Name, err)
+		}
+
+		names, err := CorpusFiles(store)
+		if err != nil {
+			t.Fatalf("Error listing %s corpus: %v", store.Name, err)
+		}
+
+		for _, name := range names {
+			testCorpus(t, p, store, name)
+		}
+	}
+}
+
+func testCorpus(t *testing.T, p *Planner, store *Store, name string) {
+	cases, err := ReadCorpus(store, name)
+	if err != nil {
+		t.Errorf("Error reading corpus %s/%s: %v", store.Name, name, err)
+		return
+	}
+
+	snapshot, err := ReadSnapshot(store, name)
+	if err != nil {
+		t.Errorf("Error reading snapshot %s/%s: %v", store.Name, name, err)
+		return
+	}
+
+	blessed := make(map[string][]byte, len(snapshot))
+	for _, c := range snapshot {
+		plan, err := Normalize(c.Plan)
+		if err != nil {
+			t.Errorf("Error normalizing snapshot %s/%s: %v", store.Name, name, err)
+			return
+		}
+		blessed[c.Statements] = plan
+	}
+
+	results := make([]*Case, 0, len(cases))
+	for _, c := range cases {
+		if c.Disabled {
+			continue
+		}
+
+		plan, err := p.Explain(c.Statements)
+		if err != nil {
+			t.Errorf("%s/%s: %v", store.Name, name, err)
+			continue
+		}
+
+		results = append(results, &Case{Statements: c.Statements, Plan: plan})
+		if *bless {
+			continue
+		}
+
+		expected, ok := blessed[c.Statements]
+		if !ok {
+			t.Errorf("%s/%s: no snapshot for %s; run bless.sh if the statement is new",
+				store.Name, name, c.Statements)
+		} else if !bytes.Equal(plan, expected) {
+			t.Errorf("%s/%s: plan changed for %s; run bless.sh if the change is intended\n"+
+				"expected:\n%s\nactual:\n%s", store.Name, name, c.Statements, expected, plan)
+		}
+	}
+
+	if *bless {
+		err = WriteSnapshot(store, name, results)
+		if err != nil {
+			t.Errorf("Error writing snapshot %s/%s: %v", store.Name, name, err)
+		}
+	}
+}
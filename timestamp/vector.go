@@ -0,0 +1,208 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package timestamp
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+)
+
+type entry struct {
+	position uint32
+	guard    string
+	value    uint64
+}
+
+func NewEntry(position uint32, guard string, value uint64) Entry {
+	return &entry{
+		position: position,
+		guard:    guard,
+		value:    value,
+	}
+}
+
+func (this *entry) Position() uint32 {
+	return this.position
+}
+
+func (this *entry) Guard() string {
+	return this.guard
+}
+
+func (this *entry) Value() uint64 {
+	return this.value
+}
+
+/*
+vector is a sparse Vector, with its entries sorted by position.
+*/
+type vector []Entry
+
+// Entries are sorted by position; zero entries are dropped, and
+// later entries replace earlier ones at the same position.
+func NewVector(entries []Entry) Vector {
+	byPosition := make(map[uint32]Entry, len(entries))
+	for _, e := range entries {
+		byPosition[e.Position()] = e
+	}
+
+	rv := make(vector, 0, len(byPosition))
+	for _, e := range byPosition {
+		if e.Value() != 0 {
+			rv = append(rv, e)
+		}
+	}
+
+	sort.Sort(rv)
+	return rv
+}
+
+func (this vector) Entries() []Entry {
+	return this
+}
+
+func (this vector) Len() int {
+	return len(this)
+}
+
+func (this vector) Less(i, j int) bool {
+	return this[i].Position() < this[j].Position()
+}
+
+func (this vector) Swap(i, j int) {
+	this[i], this[j] = this[j], this[i]
+}
+
+/*
+ParseVector parses a vector from decoded JSON. A full vector is an
+array of entries, one per position. A sparse vector is an object
+mapping positions to entries. Each entry is an object of the form
+{ "value": <sequence number>, "guard": <validation UUID> }.
+*/
+func ParseVector(data interface{}) (Vector, error) {
+	switch data := data.(type) {
+	case []interface{}:
+		entries := make([]Entry, len(data))
+		for i, e := range data {
+			entry, err := parseEntry(uint32(i), e)
+			if err != nil {
+				return nil, err
+			}
+			entries[i] = entry
+		}
+		return NewVector(entries), nil
+	case map[string]interface{}:
+		entries := make([]Entry, 0, len(data))
+		for key, e := range data {
+			position, err := strconv.ParseUint(key, 10, 32)
+			if err != nil {
+				return nil, fmt.Errorf("Invalid vector position %s.", key)
+			}
+
+			entry, err := parseEntry(uint32(position), e)
+			if err != nil {
+				return nil, err
+			}
+			entries = append(entries, entry)
+		}
+		return NewVector(entries), nil
+	default:
+		return nil, fmt.Errorf("Invalid vector %v of type %T.", data, data)
+	}
+}
+
+func parseEntry(position uint32, data interface{}) (Entry, error) {
+	fields, ok := data.(map[string]interface{})
+	if !ok {
+		return nil, fmt.Errorf("Invalid vector entry %v of type %T.", data, data)
+	}
+
+	value, ok := fields["value"].(float64)
+	if !ok || value < 0 || value != float64(uint64(value)) {
+		return nil, fmt.Errorf("Invalid value in vector entry %v.", data)
+	}
+
+	guard, ok := fields["guard"].(string)
+	if !ok {
+		return nil, fmt.Errorf("Invalid guard in vector entry %v.", data)
+	}
+
+	return NewEntry(position, guard, uint64(value)), nil
+}
+
+// Returns the entry at the given position, or nil if it is zero.
+func EntryAt(v Vector, position uint32) Entry {
+	if v == nil {
+		return nil
+	}
+
+	for _, e := range v.Entries() {
+		if e.Position() == position {
+			return e
+		}
+	}
+
+	return nil
+}
+
+/*
+Covers returns true if v is at or beyond other at every position.
+Entries with different guards belong to different histories, and
+are never covered.
+*/
+func Covers(v, other Vector) bool {
+	if other == nil {
+		return true
+	}
+
+	for _, o := range other.Entries() {
+		if o.Value() == 0 {
+			continue
+		}
+
+		e := EntryAt(v, o.Position())
+		if e == nil || e.Value() < o.Value() {
+			return false
+		}
+
+		if o.Guard() != "" && e.Guard() != o.Guard() {
+			return false
+		}
+	}
+
+	return true
+}
+
+func Equal(v, other Vector) bool {
+	return Covers(v, other) && Covers(other, v)
+}
+
+// Merge returns the highest entry of v and other at every position.
+func Merge(v, other Vector) Vector {
+	if v == nil {
+		return other
+	}
+
+	if other == nil {
+		return v
+	}
+
+	entries := make([]Entry, 0, len(v.Entries())+len(other.Entries()))
+	entries = append(entries, other.Entries()...)
+	for _, e := range v.Entries() {
+		o := EntryAt(other, e.Position())
+		if o == nil || e.Value() >= o.Value() {
+			entries = append(entries, e)
+		}
+	}
+
+	return NewVector(entries)
+}
@@ -0,0 +1,75 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package timestamp
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+func parse(t *testing.T, s string) Vector {
+	var data interface{}
+	err := json.Unmarshal([]byte(s), &data)
+	if err != nil {
+		t.Fatalf("Invalid JSON %s: %v", s, err)
+	}
+
+	v, err := ParseVector(data)
+	if err != nil {
+		t.Fatalf("Error parsing vector %s: %v", s, err)
+	}
+
+	return v
+}
+
+func TestParseVector(t *testing.T) {
+	full := parse(t, `[{"value": 5, "guard": "a"}, {"value": 0, "guard": "b"}, {"value": 7, "guard": "c"}]`)
+	if len(full.Entries()) != 2 {
+		t.Errorf("Expected 2 non-zero entries, got %v", full.Entries())
+	}
+
+	e := EntryAt(full, 2)
+	if e == nil || e.Value() != 7 || e.Guard() != "c" {
+		t.Errorf("Unexpected entry at position 2: %v", e)
+	}
+
+	sparse := parse(t, `{"2": {"value": 7, "guard": "c"}, "0": {"value": 5, "guard": "a"}}`)
+	if !Equal(full, sparse) {
+		t.Errorf("Expected %v to equal %v", full.Entries(), sparse.Entries())
+	}
+
+	for _, s := range []string{`{"x": {"value": 1, "guard": "a"}}`, `[{"value": -1, "guard": "a"}]`,
+		`[{"value": 1}]`, `"vector"`} {
+		var data interface{}
+		json.Unmarshal([]byte(s), &data)
+		if _, err := ParseVector(data); err == nil {
+			t.Errorf("Expected error parsing %s", s)
+		}
+	}
+}
+
+func TestCoversAndMerge(t *testing.T) {
+	a := parse(t, `{"0": {"value": 5, "guard": "a"}, "1": {"value": 3, "guard": "b"}}`)
+	b := parse(t, `{"0": {"value": 4, "guard": "a"}, "2": {"value": 1, "guard": "c"}}`)
+
+	if Covers(a, b) || Covers(b, a) {
+		t.Errorf("Expected %v and %v to be concurrent", a.Entries(), b.Entries())
+	}
+
+	m := Merge(a, b)
+	if !Covers(m, a) || !Covers(m, b) || len(m.Entries()) != 3 {
+		t.Errorf("Unexpected merge %v", m.Entries())
+	}
+
+	other := parse(t, `{"0": {"value": 1, "guard": "x"}}`)
+	if Covers(a, other) {
+		t.Errorf("Expected guard mismatch for %v", other.Entries())
+	}
+}
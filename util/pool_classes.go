@@ -0,0 +1,192 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package util
+
+import (
+	"github.com/couchbase/query/value"
+)
+
+/*
+CapacityClasses maps requested capacities onto power-of-two sizes
+between a minimum and a maximum, so that a pool can serve buffers
+of varying sizes from a small set of fixed-size pools. Capacities
+beyond the maximum are not pooled.
+*/
+type CapacityClasses struct {
+	min int
+	max int
+	n   int
+}
+
+func NewCapacityClasses(min, max int) CapacityClasses {
+	size := 1
+	for size < min {
+		size <<= 1
+	}
+
+	n := 1
+	for size<<uint(n) <= max {
+		n++
+	}
+
+	return CapacityClasses{
+		min: size,
+		max: size << uint(n-1),
+		n:   n,
+	}
+}
+
+// Number of classes.
+func (this CapacityClasses) Len() int {
+	return this.n
+}
+
+// Capacity of the i-th class.
+func (this CapacityClasses) Size(i int) int {
+	return this.min << uint(i)
+}
+
+// Smallest class of at least the given capacity, or -1 if the
+// capacity exceeds the largest class.
+func (this CapacityClasses) Class(capacity int) int {
+	if capacity > this.max {
+		return -1
+	}
+
+	i := 0
+	for size := this.min; size < capacity; size <<= 1 {
+		i++
+	}
+
+	return i
+}
+
+type StringClassPool struct {
+	classes CapacityClasses
+	pools   []*StringPool
+}
+
+func NewStringClassPool(min, max int) *StringClassPool {
+	classes := NewCapacityClasses(min, max)
+	rv := &StringClassPool{
+		classes: classes,
+		pools:   make([]*StringPool, classes.Len()),
+	}
+
+	for i, _ := range rv.pools {
+		rv.pools[i] = NewStringPool(classes.Size(i))
+	}
+
+	return rv
+}
+
+// Returns an empty slice with room for at least n strings.
+func (this *StringClassPool) Get(n int) []string {
+	i := this.classes.Class(n)
+	if i < 0 {
+		return make([]string, 0, n)
+	}
+
+	return this.pools[i].Get()
+}
+
+func (this *StringClassPool) Put(s []string) {
+	i := this.classes.Class(cap(s))
+	if i < 0 {
+		return
+	}
+
+	this.pools[i].Put(s)
+}
+
+type ValueClassPool struct {
+	classes CapacityClasses
+	pools   []*ValuePool
+}
+
+func NewValueClassPool(min, max int) *ValueClassPool {
+	classes := NewCapacityClasses(min, max)
+	rv := &ValueClassPool{
+		classes: classes,
+		pools:   make([]*ValuePool, classes.Len()),
+	}
+
+	for i, _ := range rv.pools {
+		rv.pools[i] = NewValuePool(classes.Size(i))
+	}
+
+	return rv
+}
+
+// Returns an empty slice with room for at least n values.
+func (this *ValueClassPool) Get(n int) value.Values {
+	i := this.classes.Class(n)
+	if i < 0 {
+		return make(value.Values, 0, n)
+	}
+
+	return this.pools[i].Get()
+}
+
+func (this *ValueClassPool) Put(s value.Values) {
+	i := this.classes.Class(cap(s))
+	if i < 0 {
+		return
+	}
+
+	this.pools[i].Put(s)
+}
+
+/*
+StringAnnotatedClassPool pools maps by their number of entries, as
+maps do not expose their capacity. A map is returned to the class
+that holds its current size.
+*/
+type StringAnnotatedClassPool struct {
+	classes CapacityClasses
+	pools   []*value.StringAnnotatedPool
+}
+
+func NewStringAnnotatedClassPool(min, max int) *StringAnnotatedClassPool {
+	classes := NewCapacityClasses(min, max)
+	rv := &StringAnnotatedClassPool{
+		classes: classes,
+		pools:   make([]*value.StringAnnotatedPool, classes.Len()),
+	}
+
+	for i, _ := range rv.pools {
+		rv.pools[i] = value.NewStringAnnotatedPool(classes.Size(i))
+	}
+
+	return rv
+}
+
+// Returns an empty map sized for at least n entries.
+func (this *StringAnnotatedClassPool) Get(n int) map[string]value.AnnotatedValue {
+	i := this.classes.Class(n)
+	if i < 0 {
+		return make(map[string]value.AnnotatedValue, n)
+	}
+
+	return this.pools[i].Get()
+}
+
+func (this *StringAnnotatedClassPool) Put(s map[string]value.AnnotatedValue) {
+	if s == nil {
+		return
+	}
+
+	i := this.classes.Class(len(s))
+	if i < 0 {
+		return
+	}
+
+	this.pools[i].Put(s)
+}
@@ -0,0 +1,57 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package util
+
+import (
+	"testing"
+
+	"github.com/couchbase/query/value"
+)
+
+func TestCapacityClasses(t *testing.T) {
+	classes := NewCapacityClasses(10, 100)
+	if classes.Len() != 3 || classes.Size(0) != 16 || classes.Size(2) != 64 {
+		t.Errorf("Expected classes 16, 32, 64, got %v", classes)
+	}
+
+	for _, c := range []struct{ capacity, class int }{
+		{0, 0}, {16, 0}, {17, 1}, {64, 2}, {65, -1},
+	} {
+		if class := classes.Class(c.capacity); class != c.class {
+			t.Errorf("Expected class %d for capacity %d, got %d", c.class, c.capacity, class)
+		}
+	}
+}
+
+func TestStringClassPool(t *testing.T) {
+	pool := NewStringClassPool(16, 64)
+
+	s := pool.Get(20)
+	if len(s) != 0 || cap(s) != 32 {
+		t.Errorf("Expected empty slice of capacity 32, got %d/%d", len(s), cap(s))
+	}
+	pool.Put(append(s, "a"))
+
+	s = pool.Get(100)
+	if cap(s) != 100 {
+		t.Errorf("Expected unpooled slice of capacity 100, got %d", cap(s))
+	}
+	pool.Put(s)
+}
+
+func TestValueClassPool(t *testing.T) {
+	pool := NewValueClassPool(4, 8)
+
+	s := append(pool.Get(3), value.NewValue(1))
+	pool.Put(s)
+	if s[0] != nil {
+		t.Errorf("Expected released values to be cleared, got %v", s[0])
+	}
+}
@@ -0,0 +1,52 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package util
+
+import (
+	"sync"
+
+	"github.com/couchbase/query/value"
+)
+
+type ValuePool struct {
+	pool *sync.Pool
+	size int
+}
+
+func NewValuePool(size int) *ValuePool {
+	rv := &ValuePool{
+		pool: &sync.Pool{
+			New: func() interface{} {
+				return make(value.Values, 0, size)
+			},
+		},
+		size: size,
+	}
+
+	return rv
+}
+
+func (this *ValuePool) Get() value.Values {
+	return this.pool.Get().(value.Values)
+}
+
+func (this *ValuePool) Put(s value.Values) {
+	if cap(s) != this.size {
+		return
+	}
+
+	// Do not keep the values alive
+	s = s[0:cap(s)]
+	for i := range s {
+		s[i] = nil
+	}
+
+	this.pool.Put(s[0:0])
+}
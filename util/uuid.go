@@ -38,9 +38,26 @@ func UUID() (string, error) {
 	if n != len(uuid) || err != nil {
 		return "", err
 	}
+	return formatUUID(uuid), nil
+}
+
+// UUIDFromBits returns the UUID of the given random bits, so that a
+// seeded generator gives reproducible UUIDs
+func UUIDFromBits(hi, lo uint64) string {
+	uuid := uuidPool.Get().([]byte)
+	defer uuidPool.Put(uuid)
+
+	for i := 0; i < 8; i++ {
+		uuid[i] = byte(hi >> uint(56-8*i))
+		uuid[8+i] = byte(lo >> uint(56-8*i))
+	}
+	return formatUUID(uuid)
+}
+
+func formatUUID(uuid []byte) string {
 	// variant bits; see section 4.1.1
 	uuid[8] = uuid[8]&^0xc0 | 0x80
 	// version 4 (pseudo-random); see section 4.1.3
 	uuid[6] = uuid[6]&^0xf0 | 0x40
-	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:]), nil
+	return fmt.Sprintf("%x-%x-%x-%x-%x", uuid[0:4], uuid[4:6], uuid[6:8], uuid[8:10], uuid[10:])
 }
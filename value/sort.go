@@ -65,3 +65,53 @@ func (this *Sorter) Swap(i, j int) {
 		actual[i], actual[j] = actual[j], actual[i]
 	}
 }
+
+/*
+MissingOrder is how ORDER BY sorts items whose sort keys are
+MISSING. By default MISSING sorts before NULL, as in collation.
+*/
+type MissingOrder int
+
+const (
+	MISSING_FIRST    MissingOrder = iota // MISSING sorts before NULL
+	MISSING_AS_NULL                      // MISSING sorts as NULL, as in SQL
+	MISSING_EXCLUDED                     // Items with MISSING keys are excluded
+)
+
+var _MISSING_ORDER_NAMES = []string{
+	MISSING_FIRST:    "first",
+	MISSING_AS_NULL:  "null",
+	MISSING_EXCLUDED: "exclude",
+}
+
+func (this MissingOrder) String() string {
+	return _MISSING_ORDER_NAMES[this]
+}
+
+func ParseMissingOrder(s string) (MissingOrder, bool) {
+	for i, name := range _MISSING_ORDER_NAMES {
+		if name == s {
+			return MissingOrder(i), true
+		}
+	}
+
+	return MISSING_FIRST, false
+}
+
+/*
+Collate two sort keys under a MissingOrder. Only the keys themselves
+are affected; MISSING nested in arrays and objects collates as usual.
+*/
+func CollateMissing(v1, v2 Value, order MissingOrder) int {
+	if order == MISSING_AS_NULL {
+		if v1.Type() == MISSING {
+			v1 = NULL_VALUE
+		}
+
+		if v2.Type() == MISSING {
+			v2 = NULL_VALUE
+		}
+	}
+
+	return v1.Collate(v2)
+}
@@ -0,0 +1,285 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package value
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"runtime"
+	"sync"
+)
+
+const _STREAM_CHUNK = 1024
+
+/*
+StreamValue is an array whose elements are added one at a time, such
+as the results of a subquery. Elements are kept in chunks, so that
+the array is not copied as it grows; beyond the spill limit, they are
+written to a temporary file as JSON, one per line.
+
+Consumers that only iterate over the elements, such as UNNEST, use
+Iterator(), and never materialize the array. Any other use of the
+value materializes it, like a parsedValue is parsed on demand.
+
+Elements are added by a single producer, and the value must be
+closed before it is read.
+*/
+type StreamValue struct {
+	chunks       [][]interface{}
+	length       int
+	limit        int
+	spill        *os.File
+	writer       *bufio.Writer
+	offset       int64
+	mutex        sync.Mutex
+	materialized Value
+}
+
+/*
+Elements beyond limit are spilled to a temporary file. A limit of 0
+keeps all the elements in memory.
+*/
+func NewStreamValue(limit int) *StreamValue {
+	return &StreamValue{
+		limit: limit,
+	}
+}
+
+func (this *StreamValue) Add(val interface{}) error {
+	if this.limit > 0 && this.length >= this.limit {
+		return this.write(val)
+	}
+
+	n := len(this.chunks)
+	if n == 0 || len(this.chunks[n-1]) == cap(this.chunks[n-1]) {
+		this.chunks = append(this.chunks, make([]interface{}, 0, _STREAM_CHUNK))
+		n++
+	}
+
+	this.chunks[n-1] = append(this.chunks[n-1], val)
+	this.length++
+	return nil
+}
+
+func (this *StreamValue) write(val interface{}) error {
+	if this.spill == nil {
+		spill, err := ioutil.TempFile("", "query-stream")
+		if err != nil {
+			return err
+		}
+
+		this.spill = spill
+		this.writer = bufio.NewWriter(spill)
+		runtime.SetFinalizer(this, (*StreamValue).release)
+	}
+
+	data, err := json.Marshal(val)
+	if err != nil {
+		return err
+	}
+
+	_, err = this.writer.Write(append(data, '\n'))
+	if err != nil {
+		return err
+	}
+
+	this.offset += int64(len(data) + 1)
+	this.length++
+	return nil
+}
+
+// Flush the spilled elements, if any. No more elements can be added.
+func (this *StreamValue) Close() error {
+	if this.writer == nil {
+		return nil
+	}
+
+	return this.writer.Flush()
+}
+
+func (this *StreamValue) Len() int {
+	return this.length
+}
+
+func (this *StreamValue) Spilled() bool {
+	return this.spill != nil
+}
+
+// Remove the spill file once the value is unreachable.
+func (this *StreamValue) release() {
+	if this.spill != nil {
+		this.spill.Close()
+		os.Remove(this.spill.Name())
+		this.spill = nil
+	}
+}
+
+/*
+Returns an iterator over the elements. Iterators are independent, so
+that the value can be iterated over by several consumers.
+*/
+func (this *StreamValue) Iterator() *StreamIterator {
+	rv := &StreamIterator{
+		stream: this,
+	}
+
+	if this.spill != nil {
+		rv.reader = bufio.NewReader(io.NewSectionReader(this.spill, 0, this.offset))
+	}
+
+	return rv
+}
+
+func (this *StreamValue) MarshalJSON() ([]byte, error) {
+	return this.unwrap().MarshalJSON()
+}
+
+func (this *StreamValue) Type() Type {
+	return ARRAY
+}
+
+func (this *StreamValue) Actual() interface{} {
+	return this.unwrap().Actual()
+}
+
+func (this *StreamValue) Equals(other Value) Value {
+	return this.unwrap().Equals(other)
+}
+
+func (this *StreamValue) Collate(other Value) int {
+	return this.unwrap().Collate(other)
+}
+
+func (this *StreamValue) Compare(other Value) Value {
+	return this.unwrap().Compare(other)
+}
+
+func (this *StreamValue) Truth() bool {
+	return this.length > 0
+}
+
+func (this *StreamValue) Copy() Value {
+	return this.unwrap().Copy()
+}
+
+func (this *StreamValue) CopyForUpdate() Value {
+	return this.unwrap().CopyForUpdate()
+}
+
+func (this *StreamValue) Field(field string) (Value, bool) {
+	return missingField(field), false
+}
+
+func (this *StreamValue) SetField(field string, val interface{}) error {
+	return Unsettable(field)
+}
+
+func (this *StreamValue) UnsetField(field string) error {
+	return Unsettable(field)
+}
+
+// Elements in memory are read without materializing the array.
+func (this *StreamValue) Index(index int) (Value, bool) {
+	if index >= 0 && index < this.length && (this.limit == 0 || index < this.limit) {
+		return NewValue(this.chunks[index/_STREAM_CHUNK][index%_STREAM_CHUNK]), true
+	}
+
+	return this.unwrap().Index(index)
+}
+
+func (this *StreamValue) SetIndex(index int, val interface{}) error {
+	return this.unwrap().SetIndex(index, val)
+}
+
+func (this *StreamValue) Slice(start, end int) (Value, bool) {
+	return this.unwrap().Slice(start, end)
+}
+
+func (this *StreamValue) SliceTail(start int) (Value, bool) {
+	return this.unwrap().SliceTail(start)
+}
+
+func (this *StreamValue) Descendants(buffer []interface{}) []interface{} {
+	return this.unwrap().Descendants(buffer)
+}
+
+func (this *StreamValue) Fields() map[string]interface{} {
+	return this.unwrap().Fields()
+}
+
+func (this *StreamValue) Successor() Value {
+	return this.unwrap().Successor()
+}
+
+/*
+Delayed materialization. Stream values may be shared, e.g. as cached
+subquery results, so this is synchronized.
+*/
+func (this *StreamValue) unwrap() Value {
+	this.mutex.Lock()
+	defer this.mutex.Unlock()
+
+	if this.materialized == nil {
+		values := make([]interface{}, 0, this.length)
+		it := this.Iterator()
+		for v, ok := it.Next(); ok; v, ok = it.Next() {
+			values = append(values, v)
+		}
+
+		if it.Err() != nil {
+			panic("Unexpected error reading spilled values: " + it.Err().Error())
+		}
+
+		this.materialized = sliceValue(values)
+	}
+
+	return this.materialized
+}
+
+/*
+StreamIterator iterates over the elements of a StreamValue, reading
+the spilled elements in order.
+*/
+type StreamIterator struct {
+	stream *StreamValue
+	next   int
+	reader *bufio.Reader
+	err    error
+}
+
+func (this *StreamIterator) Next() (Value, bool) {
+	if this.next >= this.stream.length || this.err != nil {
+		return nil, false
+	}
+
+	i := this.next
+	this.next++
+
+	if this.reader == nil || i < this.stream.limit {
+		return NewValue(this.stream.chunks[i/_STREAM_CHUNK][i%_STREAM_CHUNK]), true
+	}
+
+	line, err := this.reader.ReadBytes('\n')
+	if err != nil {
+		this.err = err
+		return nil, false
+	}
+
+	return NewValue(bytes.TrimSuffix(line, []byte{'\n'})), true
+}
+
+// Returns the error that ended the iteration, if any.
+func (this *StreamIterator) Err() error {
+	return this.err
+}
@@ -0,0 +1,81 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package value
+
+import (
+	"os"
+	"testing"
+)
+
+func TestStreamValue(t *testing.T) {
+	elements := []interface{}{1.0, "two", map[string]interface{}{"three": 3.0},
+		[]interface{}{4.0}, nil, true}
+
+	for _, limit := range []int{0, 2} {
+		stream := NewStreamValue(limit)
+		for _, e := range elements {
+			err := stream.Add(e)
+			if err != nil {
+				t.Fatalf("Error adding %v: %v", e, err)
+			}
+		}
+
+		err := stream.Close()
+		if err != nil {
+			t.Fatalf("Error closing stream: %v", err)
+		}
+
+		if stream.Spilled() != (limit > 0) || stream.Len() != len(elements) {
+			t.Errorf("Expected %d elements, spilled %v, got %d", len(elements), limit > 0, stream.Len())
+		}
+
+		// Iterate twice, without materializing
+		for i := 0; i < 2; i++ {
+			it := stream.Iterator()
+			n := 0
+			for v, ok := it.Next(); ok; v, ok = it.Next() {
+				if v.Collate(NewValue(elements[n])) != 0 {
+					t.Errorf("Expected element %d to be %v, got %v", n, elements[n], v)
+				}
+				n++
+			}
+
+			if it.Err() != nil || n != len(elements) {
+				t.Errorf("Expected %d elements, got %d, error %v", len(elements), n, it.Err())
+			}
+		}
+
+		if stream.materialized != nil {
+			t.Errorf("Expected iteration not to materialize the stream")
+		}
+
+		v, ok := stream.Index(1)
+		if !ok || v.Actual() != "two" {
+			t.Errorf("Expected index 1 to be two, got %v", v)
+		}
+
+		v, ok = stream.Index(5)
+		if !ok || v.Actual() != true {
+			t.Errorf("Expected index 5 to be true, got %v", v)
+		}
+
+		if stream.Collate(NewValue(elements)) != 0 {
+			t.Errorf("Expected %v, got %v", elements, stream)
+		}
+
+		if limit > 0 {
+			name := stream.spill.Name()
+			stream.release()
+			if _, err := os.Stat(name); !os.IsNotExist(err) {
+				t.Errorf("Expected the spill file to be removed")
+			}
+		}
+	}
+}
@@ -391,7 +391,9 @@ func newValueFromBytes(bytes []byte) Value {
 			var p interface{}
 			err := json.Unmarshal(bytes, &p)
 			if err != nil {
-				panic(fmt.Sprintf("Parse error on JSON data: %v", err))
+				// Valid JSON that cannot be decoded, such as
+				// numbers beyond the float64 range
+				return binaryValue(bytes)
 			}
 
 			return NewValue(p)
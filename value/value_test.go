@@ -558,3 +558,24 @@ func TestArraySetIndexLongerThanExistingArray(t *testing.T) {
 		t.Errorf("Expected [gerald] got %v", valval)
 	}
 }
+
+func TestCollateMissing(t *testing.T) {
+	if CollateMissing(MISSING_VALUE, NULL_VALUE, MISSING_FIRST) >= 0 {
+		t.Errorf("Expected MISSING before NULL")
+	}
+
+	if CollateMissing(MISSING_VALUE, NULL_VALUE, MISSING_AS_NULL) != 0 {
+		t.Errorf("Expected MISSING to collate as NULL")
+	}
+
+	if CollateMissing(NewValue(1.0), MISSING_VALUE, MISSING_AS_NULL) <= 0 {
+		t.Errorf("Expected MISSING before numbers")
+	}
+
+	for _, order := range []MissingOrder{MISSING_FIRST, MISSING_AS_NULL, MISSING_EXCLUDED} {
+		parsed, ok := ParseMissingOrder(order.String())
+		if !ok || parsed != order {
+			t.Errorf("Expected %v, got %v", order, parsed)
+		}
+	}
+}
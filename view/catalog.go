@@ -0,0 +1,95 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+package view
+
+import (
+	"time"
+
+	"github.com/couchbase/query/datastore"
+	"github.com/couchbase/query/datastore/system"
+	"github.com/couchbase/query/errors"
+)
+
+func init() {
+	system.SetCatalog(system.KEYSPACE_NAME_VIEWS, catalog{})
+	datastore.AddKeyspaceResolver("views", resolveView)
+}
+
+/*
+A view that is not materialized has no keyspace, so statements that
+need one, such as DML and CREATE INDEX, are told the name is a view
+rather than that the keyspace is missing.
+*/
+func resolveView(store datastore.Datastore, namespace, keyspace string) (datastore.Keyspace, errors.Error) {
+	v := Get(namespace, keyspace)
+	if v == nil || v.Materialized() {
+		return nil, nil
+	}
+
+	return nil, errors.NewViewTypeError(Key(namespace, keyspace), false)
+}
+
+/*
+catalog lists the views in system:views, by namespace and name.
+Deleting the document of a view that is not materialized drops it;
+materialized views are dropped by DROP MATERIALIZED VIEW, which also
+drops their keyspaces.
+*/
+type catalog struct{}
+
+func (catalog) Ids() []string {
+	views := Views()
+	ids := make([]string, len(views))
+	for i, v := range views {
+		ids[i] = Key(v.namespace, v.name)
+	}
+
+	return ids
+}
+
+func (catalog) Entry(id string) (map[string]interface{}, bool) {
+	_VIEWS.RLock()
+	v, ok := _VIEWS.views[id]
+	_VIEWS.RUnlock()
+	if !ok {
+		return nil, false
+	}
+
+	rv := map[string]interface{}{
+		"id":           id,
+		"namespace":    v.namespace,
+		"name":         v.name,
+		"definition":   v.definition,
+		"materialized": v.materialized,
+		"created":      v.created.Format(time.RFC3339),
+	}
+
+	if v.materialized {
+		rv["incremental"] = v.incremental
+	}
+
+	return rv, true
+}
+
+func (catalog) Delete(id string) errors.Error {
+	_VIEWS.RLock()
+	v, ok := _VIEWS.views[id]
+	_VIEWS.RUnlock()
+	if !ok {
+		return errors.NewViewNotFoundError(id)
+	}
+
+	if v.materialized {
+		return errors.NewViewTypeError(id, true)
+	}
+
+	Remove(v.namespace, v.name)
+	return nil
+}
@@ -0,0 +1,255 @@
+//  Copyright (c) 2015 Couchbase, Inc.
+//  Licensed under the Apache License, Version 2.0 (the "License"); you may not use this file
+//  except in compliance with the License. You may obtain a copy of the License at
+//    http://www.apache.org/licenses/LICENSE-2.0
+//  Unless required by applicable law or agreed to in writing, software distributed under the
+//  License is distributed on an "AS IS" BASIS, WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND,
+//  either express or implied. See the License for the specific language governing permissions
+//  and limitations under the License.
+
+/*
+Package view holds the definitions of views, which are listed in
+system:views. A view is a SELECT, its definition, that queries read
+by name, as they read a keyspace. The planner expands a view that is
+not materialized into the queries that read it, as a subquery.
+
+A materialized view is a keyspace that holds the results of its
+definition. CREATE MATERIALIZED VIEW creates the keyspace and fills
+it, and REFRESH MATERIALIZED VIEW recomputes its results.
+
+The results of a key-preserving definition, whose documents each
+have at most one result, are stored under the keys of the documents,
+and are also kept up to date from the mutation feed of the keyspace
+of the definition, if it has one. The results of other definitions
+are stored under their ordinals, and are only refreshed on demand.
+
+With the view_rewrite feature, the planner reads the results of
+queries that match the definition of a view from the view.
+*/
+package view
+
+import (
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/couchbase/query/algebra"
+	"github.com/couchbase/query/expression"
+)
+
+/*
+View is the definition of a view. The definition is the text of the
+SELECT, as formalized. The key of a materialized view is the text of
+its subselect, which queries read from the view must match.
+*/
+type View struct {
+	namespace    string
+	name         string
+	definition   string
+	key          string
+	materialized bool
+	incremental  bool
+	created      time.Time
+	stop         func() // Stops the incremental refresh, if any
+}
+
+// A materialized view.
+func New(namespace, name, definition, key string, incremental bool, stop func()) *View {
+	return &View{
+		namespace:    namespace,
+		name:         name,
+		definition:   definition,
+		key:          key,
+		materialized: true,
+		incremental:  incremental,
+		created:      time.Now(),
+		stop:         stop,
+	}
+}
+
+// A view that is not materialized.
+func NewVirtual(namespace, name, definition string) *View {
+	return &View{
+		namespace:  namespace,
+		name:       name,
+		definition: definition,
+		created:    time.Now(),
+	}
+}
+
+func (this *View) Namespace() string {
+	return this.namespace
+}
+
+// The name of the view, and of the keyspace that holds a
+// materialized view.
+func (this *View) Name() string {
+	return this.name
+}
+
+func (this *View) Definition() string {
+	return this.definition
+}
+
+func (this *View) Key() string {
+	return this.key
+}
+
+func (this *View) Materialized() bool {
+	return this.materialized
+}
+
+// True if the view is key-preserving and refreshed from a feed.
+func (this *View) Incremental() bool {
+	return this.incremental
+}
+
+func (this *View) Created() time.Time {
+	return this.created
+}
+
+func Key(namespace, name string) string {
+	return namespace + ":" + name
+}
+
+type views struct {
+	sync.RWMutex
+	views map[string]*View // By namespace and name
+	keys  map[string]*View // By the key of the definition
+}
+
+var _VIEWS = &views{
+	views: make(map[string]*View),
+	keys:  make(map[string]*View),
+}
+
+// Returns false if the view already exists.
+func Add(view *View) bool {
+	_VIEWS.Lock()
+	defer _VIEWS.Unlock()
+
+	key := Key(view.namespace, view.name)
+	if _, ok := _VIEWS.views[key]; ok {
+		return false
+	}
+
+	_VIEWS.views[key] = view
+	if _, ok := _VIEWS.keys[view.key]; !ok && view.key != "" {
+		_VIEWS.keys[view.key] = view
+	}
+
+	return true
+}
+
+// Removes the view and stops its incremental refresh; returns nil
+// if there is no such view.
+func Remove(namespace, name string) *View {
+	_VIEWS.Lock()
+	defer _VIEWS.Unlock()
+
+	key := Key(namespace, name)
+	view, ok := _VIEWS.views[key]
+	if !ok {
+		return nil
+	}
+
+	delete(_VIEWS.views, key)
+	if _VIEWS.keys[view.key] == view {
+		delete(_VIEWS.keys, view.key)
+
+		// Another view may have the same definition
+		for _, other := range _VIEWS.views {
+			if other.key == view.key {
+				_VIEWS.keys[view.key] = other
+				break
+			}
+		}
+	}
+
+	if view.stop != nil {
+		view.stop()
+	}
+
+	return view
+}
+
+func Get(namespace, name string) *View {
+	_VIEWS.RLock()
+	defer _VIEWS.RUnlock()
+	return _VIEWS.views[Key(namespace, name)]
+}
+
+// Returns a materialized view whose definition has the given key, or
+// nil.
+func Match(key string) *View {
+	_VIEWS.RLock()
+	defer _VIEWS.RUnlock()
+	return _VIEWS.keys[key]
+}
+
+// The views, sorted by namespace and name.
+func Views() []*View {
+	_VIEWS.RLock()
+	defer _VIEWS.RUnlock()
+
+	keys := make([]string, 0, len(_VIEWS.views))
+	for key, _ := range _VIEWS.views {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	rv := make([]*View, len(keys))
+	for i, key := range keys {
+		rv[i] = _VIEWS.views[key]
+	}
+
+	return rv
+}
+
+/*
+Returns the subselect and the keyspace term of a key-preserving
+SELECT, whose documents each have at most one result: a SELECT from
+a single keyspace, without USE KEYS, joins, nests, unnests, grouping,
+aggregates, DISTINCT, ORDER BY, OFFSET, LIMIT or INTO OUTFILE.
+Otherwise, returns an error saying why the SELECT is not.
+*/
+func KeyPreserving(stmt *algebra.Select) (*algebra.Subselect, *algebra.KeyspaceTerm, error) {
+	if stmt.Order() != nil || stmt.Offset() != nil || stmt.Limit() != nil || stmt.Outfile() != nil {
+		return nil, nil, fmt.Errorf("ORDER BY, OFFSET, LIMIT and INTO OUTFILE are not allowed.")
+	}
+
+	sub, ok := stmt.Subresult().(*algebra.Subselect)
+	if !ok {
+		return nil, nil, fmt.Errorf("set operations are not allowed.")
+	}
+
+	term, ok := sub.From().(*algebra.KeyspaceTerm)
+	if !ok || term.Keys() != nil {
+		return nil, nil, fmt.Errorf("the statement must select from one keyspace, without USE KEYS.")
+	}
+
+	if sub.Group() != nil || sub.Projection().Distinct() || hasAggregate(sub.Projection().Expressions()) {
+		return nil, nil, fmt.Errorf("grouping, aggregates and DISTINCT are not allowed.")
+	}
+
+	return sub, term, nil
+}
+
+func hasAggregate(exprs expression.Expressions) bool {
+	for _, expr := range exprs {
+		if _, ok := expr.(algebra.Aggregate); ok {
+			return true
+		}
+
+		if _, ok := expr.(*algebra.Subquery); ok {
+			continue
+		}
+
+		if hasAggregate(expr.Children()) {
+			return true
+		}
+	}
+
+	return false
+}